@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+)
+
+var (
+	errInvalidActorType = api.NewInvalidRequestError(errors.New("invalid actor type"))
+	errInvalidAfter     = api.NewInvalidRequestError(errors.New("invalid after"))
+)
+
+// AuditHandler interface to handle incoming requests to query the audit trail
+type AuditHandler interface {
+	HandleListAuditEntries() http.HandlerFunc
+	HandleVerifyAuditLog() http.HandlerFunc
+}
+
+type auditHandler struct {
+	service service.AuditService
+}
+
+// NewAuditHandler constructor to handle incoming requests to query the audit trail
+func NewAuditHandler(service service.AuditService) AuditHandler {
+	return auditHandler{
+		service: service,
+	}
+}
+
+// HandleListAuditEntries handles requests to list the audit entries, in the order they were appended
+func (h auditHandler) HandleListAuditEntries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseAuditFilter(r.URL.Query())
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		entries, err := h.service.ListAuditEntries(r.Context(), filter)
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, entries)
+	}
+}
+
+// HandleVerifyAuditLog handles requests to verify the hash chain of the audit trail
+func (h auditHandler) HandleVerifyAuditLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		verification, err := h.service.VerifyAuditLog(r.Context())
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, verification)
+	}
+}
+
+// parseAuditFilter reads the filter of the audit entries from the query string
+func parseAuditFilter(query url.Values) (*models.AuditFilter, error) {
+	filter := &models.AuditFilter{
+		Actor:        query.Get("actor"),
+		ActorType:    models.AuditActorType(query.Get("actor_type")),
+		ResourceType: query.Get("resource_type"),
+		ResourceID:   query.Get("resource_id"),
+	}
+
+	if filter.ActorType != "" && !filter.ActorType.IsValid() {
+		return nil, errInvalidActorType
+	}
+
+	var err error
+
+	filter.From, err = parseTimeParam(query, "from")
+	if err != nil {
+		return nil, err
+	}
+
+	filter.To, err = parseTimeParam(query, "to")
+	if err != nil {
+		return nil, err
+	}
+
+	if value := query.Get("after"); value != "" {
+		after, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || after < 0 {
+			return nil, errInvalidAfter
+		}
+
+		filter.AfterSequence = after
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, errInvalidLimit
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleListAuditEntries(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockAuditService{}
+
+	from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	expectedFilter := &models.AuditFilter{
+		ActorType:     models.AuditActorOperator,
+		ResourceType:  models.AuditResourceTransaction,
+		ResourceID:    "TXN_123",
+		From:          &from,
+		AfterSequence: 40,
+		Limit:         20,
+	}
+
+	mockService.On("ListAuditEntries", mock.Anything, expectedFilter).Return([]*models.AuditEntry{
+		{
+			AuditID:      "AUD_123",
+			Sequence:     41,
+			Actor:        "alice",
+			ActorType:    models.AuditActorOperator,
+			Action:       models.AuditActionForceStatus,
+			ResourceType: models.AuditResourceTransaction,
+			ResourceID:   "TXN_123",
+			Hash:         "9c2e",
+		},
+	}, nil)
+
+	handler := NewAuditHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/audit", http.HandlerFunc(handler.HandleListAuditEntries()))
+
+	req := httptest.NewRequest(http.MethodGet, "/audit?actor_type=operator&resource_type=transaction&resource_id=TXN_123&from=2024-03-01&after=40&limit=20", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+
+	var entries []*models.AuditEntry
+
+	err := json.NewDecoder(recorder.Body).Decode(&entries)
+	c.NoError(err)
+	c.Len(entries, 1)
+	c.Equal(int64(41), entries[0].Sequence)
+	c.Equal("9c2e", entries[0].Hash)
+}
+
+func TestHandleListAuditEntriesInvalidFilter(t *testing.T) {
+	handler := NewAuditHandler(&service.MockAuditService{})
+
+	router := chi.NewRouter()
+	router.Get("/audit", http.HandlerFunc(handler.HandleListAuditEntries()))
+
+	for _, query := range []string{"actor_type=robot", "from=yesterday", "after=-1", "limit=ten"} {
+		req := httptest.NewRequest(http.MethodGet, "/audit?"+query, nil)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		require.Equal(t, http.StatusBadRequest, recorder.Code, query)
+	}
+}
+
+func TestHandleVerifyAuditLog(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockAuditService{}
+
+	mockService.On("VerifyAuditLog", mock.Anything).Return(&models.AuditVerification{
+		Valid:                false,
+		Entries:              3,
+		FirstInvalidSequence: 3,
+		Reason:               "hash mismatch",
+	}, nil)
+
+	handler := NewAuditHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/audit/verify", http.HandlerFunc(handler.HandleVerifyAuditLog()))
+
+	req := httptest.NewRequest(http.MethodGet, "/audit/verify", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+
+	var verification models.AuditVerification
+
+	err := json.NewDecoder(recorder.Body).Decode(&verification)
+	c.NoError(err)
+	c.False(verification.Valid)
+	c.Equal(int64(3), verification.FirstInvalidSequence)
+}
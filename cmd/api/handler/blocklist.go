@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+var errInvalidActive = api.NewInvalidRequestError(errors.New("invalid active"))
+
+// BlockListHandler interface to handle incoming requests to manage the values payments are rejected for
+type BlockListHandler interface {
+	HandleCreateBlockListEntry() http.HandlerFunc
+	HandleGetBlockListEntry() http.HandlerFunc
+	HandleListBlockListEntries() http.HandlerFunc
+	HandleUpdateBlockListEntry() http.HandlerFunc
+	HandleDeleteBlockListEntry() http.HandlerFunc
+}
+
+type blockListHandler struct {
+	service service.BlockListService
+}
+
+// NewBlockListHandler constructor to handle incoming requests to manage the block list
+func NewBlockListHandler(service service.BlockListService) BlockListHandler {
+	return blockListHandler{
+		service: service,
+	}
+}
+
+// HandleCreateBlockListEntry handles requests to add a value to the block list
+func (h blockListHandler) HandleCreateBlockListEntry() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			api.WriteErrorResponse(w, errInvalidInput)
+			return
+		}
+
+		expiresAt, err := parseTimeParam(r.Form, "expires_at")
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		entry, err := h.service.CreateBlockListEntry(r.Context(), &models.BlockListEntry{
+			Type:      models.BlockListType(r.FormValue("type")),
+			Value:     r.FormValue("value"),
+			Reason:    r.FormValue("reason"),
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusCreated, entry)
+	}
+}
+
+// HandleGetBlockListEntry handles requests to fetch a block list entry
+func (h blockListHandler) HandleGetBlockListEntry() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry, err := h.service.GetBlockListEntry(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, entry)
+	}
+}
+
+// HandleListBlockListEntries handles requests to list the block list entries, newest first
+func (h blockListHandler) HandleListBlockListEntries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseBlockListFilter(r.URL.Query())
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		entries, err := h.service.ListBlockListEntries(r.Context(), filter)
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, entries)
+	}
+}
+
+// HandleUpdateBlockListEntry handles requests to replace the reason and expiration of a block list entry
+func (h blockListHandler) HandleUpdateBlockListEntry() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			api.WriteErrorResponse(w, errInvalidInput)
+			return
+		}
+
+		expiresAt, err := parseTimeParam(r.Form, "expires_at")
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		entry, err := h.service.UpdateBlockListEntry(r.Context(), &models.BlockListEntry{
+			EntryID:   chi.URLParam(r, "id"),
+			Reason:    r.FormValue("reason"),
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, entry)
+	}
+}
+
+// HandleDeleteBlockListEntry handles requests to remove a value from the block list
+func (h blockListHandler) HandleDeleteBlockListEntry() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := h.service.DeleteBlockListEntry(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseBlockListFilter reads the filter of the block list entries from the query string
+func parseBlockListFilter(query url.Values) (*models.BlockListFilter, error) {
+	filter := &models.BlockListFilter{}
+
+	if value := query.Get("type"); value != "" {
+		entryType := models.BlockListType(value)
+		if !entryType.IsValid() {
+			return nil, errInvalidType
+		}
+
+		filter.Types = []models.BlockListType{entryType}
+	}
+
+	if value := query.Get("active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errInvalidActive
+		}
+
+		if active {
+			now := time.Now().UTC()
+			filter.ActiveAt = &now
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, errInvalidLimit
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
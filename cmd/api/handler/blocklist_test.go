@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCreateBlockListEntry(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockBlockListService{}
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mockService.On("CreateBlockListEntry", mock.Anything, &models.BlockListEntry{
+		Type:      models.BlockListTypeCardFingerprint,
+		Value:     "fp_123",
+		Reason:    "chargebacks",
+		ExpiresAt: &expiresAt,
+	}).Return(&models.BlockListEntry{
+		EntryID:   "BLK_123",
+		Type:      models.BlockListTypeCardFingerprint,
+		Value:     "fp_123",
+		Reason:    "chargebacks",
+		ExpiresAt: &expiresAt,
+	}, nil)
+
+	handler := NewBlockListHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/blocklist", http.HandlerFunc(handler.HandleCreateBlockListEntry()))
+
+	body := url.Values{"type": {"card_fingerprint"}, "value": {"fp_123"}, "reason": {"chargebacks"}, "expires_at": {"2030-01-01"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/blocklist", strings.NewReader(body.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusCreated, recorder.Code)
+
+	var entry models.BlockListEntry
+
+	err := json.NewDecoder(recorder.Body).Decode(&entry)
+	c.NoError(err)
+	c.Equal("BLK_123", entry.EntryID)
+}
+
+func TestHandleListBlockListEntriesInvalidType(t *testing.T) {
+	c := require.New(t)
+
+	handler := NewBlockListHandler(&service.MockBlockListService{})
+
+	router := chi.NewRouter()
+	router.Get("/blocklist", http.HandlerFunc(handler.HandleListBlockListEntries()))
+
+	req := httptest.NewRequest(http.MethodGet, "/blocklist?type=phone", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func TestHandleListBlockListEntries(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockBlockListService{}
+
+	mockService.On("ListBlockListEntries", mock.Anything, mock.MatchedBy(func(filter *models.BlockListFilter) bool {
+		return len(filter.Types) == 1 && filter.Types[0] == models.BlockListTypeEmail && filter.ActiveAt != nil && filter.Limit == 10
+	})).Return([]*models.BlockListEntry{{EntryID: "BLK_123", Type: models.BlockListTypeEmail}}, nil)
+
+	handler := NewBlockListHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/blocklist", http.HandlerFunc(handler.HandleListBlockListEntries()))
+
+	req := httptest.NewRequest(http.MethodGet, "/blocklist?type=email&active=true&limit=10", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandleDeleteBlockListEntry(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockBlockListService{}
+
+	mockService.On("DeleteBlockListEntry", mock.Anything, "BLK_123").Return(nil)
+	mockService.On("DeleteBlockListEntry", mock.Anything, "BLK_404").Return(api.NewResourceNotFoundError(database.ErrBlockListEntryNotFound, "block_list_entry"))
+
+	handler := NewBlockListHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Delete("/blocklist/{id}", http.HandlerFunc(handler.HandleDeleteBlockListEntry()))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/blocklist/BLK_123", nil))
+
+	c.Equal(http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/blocklist/BLK_404", nil))
+
+	c.Equal(http.StatusNotFound, recorder.Code)
+}
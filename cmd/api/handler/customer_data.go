@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+)
+
+// CustomerDataHandler interface to handle incoming requests of operators answering the access and erasure requests of
+// paying customers. The customer is sent in the body, so it never shows up in URLs or access logs
+type CustomerDataHandler interface {
+	HandleExportCustomerData() http.HandlerFunc
+	HandleEraseCustomerData() http.HandlerFunc
+}
+
+type customerDataHandler struct {
+	service service.CustomerDataService
+}
+
+// NewCustomerDataHandler constructor to handle incoming requests about the data of paying customers
+func NewCustomerDataHandler(service service.CustomerDataService) CustomerDataHandler {
+	return customerDataHandler{
+		service: service,
+	}
+}
+
+// HandleExportCustomerData handles requests to export the data held about a customer as a JSON bundle
+func (h customerDataHandler) HandleExportCustomerData() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			api.WriteErrorResponse(w, errInvalidInput)
+			return
+		}
+
+		data, err := h.service.ExportCustomerData(r.Context(), r.FormValue("customer"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, data)
+	}
+}
+
+// HandleEraseCustomerData handles requests of the authenticated operator to erase the personal data of a customer
+func (h customerDataHandler) HandleEraseCustomerData() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		operator, ok := auth.OperatorFromContext(r.Context())
+		if !ok {
+			api.WriteErrorResponse(w, errMissingOperator)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			api.WriteErrorResponse(w, errInvalidInput)
+			return
+		}
+
+		erasure, err := h.service.EraseCustomerData(r.Context(), operator.Name, r.FormValue("customer"), r.FormValue("reason"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, erasure)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleExportCustomerData(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockCustomerDataService{}
+
+	mockService.On("ExportCustomerData", mock.Anything, "jane@example.com").Return(&models.CustomerData{
+		Customer:      "jane@example.com",
+		Transactions:  []*models.Transaction{{TransactionID: "TXN_123"}},
+		RiskDecisions: []*models.RiskDecision{{DecisionID: "RSK_123", TransactionID: "TXN_123"}},
+	}, nil)
+
+	handler := NewCustomerDataHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/customer-data/export", http.HandlerFunc(handler.HandleExportCustomerData()))
+
+	body := url.Values{"customer": {"jane@example.com"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/customer-data/export", strings.NewReader(body.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+
+	var data models.CustomerData
+	c.NoError(json.Unmarshal(recorder.Body.Bytes(), &data))
+	c.Equal("TXN_123", data.Transactions[0].TransactionID)
+	c.Equal("RSK_123", data.RiskDecisions[0].DecisionID)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandleEraseCustomerData(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockCustomerDataService{}
+
+	mockService.On("EraseCustomerData", mock.Anything, "alice", "jane@example.com", "right to erasure request").Return(&models.CustomerErasure{
+		ErasureID:     "ERS_123",
+		Customer:      "jane@example.com",
+		Transactions:  2,
+		RiskDecisions: 2,
+	}, nil)
+
+	handler := NewCustomerDataHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/customer-data/erase", http.HandlerFunc(handler.HandleEraseCustomerData()))
+
+	body := url.Values{"customer": {"jane@example.com"}, "reason": {"right to erasure request"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/customer-data/erase", strings.NewReader(body.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = authenticatedOperator(req, "alice")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+	c.Contains(recorder.Body.String(), `"erasure_id":"ERS_123"`)
+	c.NotContains(recorder.Body.String(), "jane@example.com", "the erased customer isn't echoed back")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHandleEraseCustomerDataUnauthenticated(t *testing.T) {
+	handler := NewCustomerDataHandler(&service.MockCustomerDataService{})
+
+	req := httptest.NewRequest(http.MethodPost, "/customer-data/erase", nil)
+
+	recorder := httptest.NewRecorder()
+	handler.HandleEraseCustomerData().ServeHTTP(recorder, req)
+
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/export"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+var (
+	errInvalidStatus = api.NewInvalidRequestError(errors.New("invalid status"))
+	errInvalidType   = api.NewInvalidRequestError(errors.New("invalid type"))
+	errInvalidLimit  = api.NewInvalidRequestError(errors.New("invalid limit"))
+)
+
+// HandleExportPayments handles requests to export the payments of the merchant as CSV or NDJSON. The response
+// is streamed, so it can't report errors happening after the first transaction was written
+func (h handler) HandleExportPayments() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		merchantID, ok := auth.MerchantIDFromContext(ctx)
+		if !ok {
+			api.WriteErrorResponse(w, errUnauthenticated)
+			return
+		}
+
+		query := r.URL.Query()
+
+		format, err := export.ParseFormat(query.Get("format"))
+		if err != nil {
+			api.WriteErrorResponse(w, api.NewInvalidRequestError(err))
+			return
+		}
+
+		filter, err := parseTransactionFilter(query)
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		stream := &streamWriter{
+			w:           w,
+			contentType: format.ContentType(),
+			filename:    fmt.Sprintf("transactions-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format.Extension()),
+		}
+
+		err = h.service.ExportPayments(ctx, merchantID, filter, format, stream)
+		if err != nil && !stream.started {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		if err != nil {
+			slog.ErrorContext(ctx, "export payments interrupted", slog.Any("error", err))
+
+			// aborting the response lets the client know the export is incomplete
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// parseTransactionFilter reads the filter of the transactions from the query string
+func parseTransactionFilter(query url.Values) (*models.TransactionFilter, error) {
+	filter := &models.TransactionFilter{
+		Status:   models.TransactionStatus(query.Get("status")),
+		Type:     models.TransactionType(query.Get("type")),
+		Provider: models.PaymentProvider(query.Get("provider")),
+		Currency: query.Get("currency"),
+		Search:   query.Get("search"),
+	}
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, errInvalidStatus
+	}
+
+	if filter.Type != "" && !filter.Type.IsValid() {
+		return nil, errInvalidType
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, errInvalidLimit
+		}
+
+		filter.Limit = limit
+	}
+
+	var err error
+
+	filter.CreatedFrom, err = parseTimeParam(query, "created_from")
+	if err != nil {
+		return nil, err
+	}
+
+	filter.CreatedTo, err = parseTimeParam(query, "created_to")
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam reads a date (YYYY-MM-DD) or an RFC 3339 time from the query string, returning nil when missing
+func parseTimeParam(query url.Values, name string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, api.NewInvalidRequestError(fmt.Errorf("invalid %s", name))
+}
+
+// streamWriter sets the headers of the export on the first write, so errors found before any data is
+// exported can still be answered with an error response
+type streamWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if !s.started {
+		s.started = true
+
+		s.w.Header().Set("Content-Type", s.contentType)
+		s.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", s.filename))
+		s.w.WriteHeader(http.StatusOK)
+	}
+
+	return s.w.Write(p)
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/export"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleExportPayments(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockOnlinePaymentService{}
+
+	createdFrom := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	expectedFilter := &models.TransactionFilter{
+		Status:      models.TransactionStatusSucceeded,
+		CreatedFrom: &createdFrom,
+	}
+
+	mockService.On("ExportPayments", mock.Anything, "MCH_123", expectedFilter, export.FormatNDJSON).Return(`{"transaction_id":"TXN_123"}`+"\n", nil)
+
+	handler := NewHandler(&mockService)
+
+	req := authenticated(httptest.NewRequest(http.MethodGet, "/payments/export?format=ndjson&status=succeeded&created_from=2024-03-01", nil))
+
+	recorder := httptest.NewRecorder()
+	handler.HandleExportPayments()(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	c.NoError(err)
+
+	c.Equal(http.StatusOK, response.StatusCode)
+	c.Equal("application/x-ndjson", response.Header.Get("Content-Type"))
+	c.Contains(response.Header.Get("Content-Disposition"), ".ndjson")
+	c.Equal(`{"transaction_id":"TXN_123"}`+"\n", string(body))
+}
+
+func TestHandleExportPaymentsInvalidFilter(t *testing.T) {
+	c := require.New(t)
+
+	handler := NewHandler(&service.MockOnlinePaymentService{})
+
+	for _, query := range []string{"format=xlsx", "status=refunded", "limit=-1", "created_to=yesterday"} {
+		req := authenticated(httptest.NewRequest(http.MethodGet, "/payments/export?"+query, nil))
+
+		recorder := httptest.NewRecorder()
+		handler.HandleExportPayments()(recorder, req)
+
+		c.Equal(http.StatusBadRequest, recorder.Code, query)
+	}
+}
+
+func TestHandleExportPaymentsFailure(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockOnlinePaymentService{}
+
+	mockService.On("ExportPayments", mock.Anything, "MCH_123", &models.TransactionFilter{}, export.FormatCSV).Return("", api.NewInternalServerError(errors.New("connection refused")))
+
+	handler := NewHandler(&mockService)
+
+	req := authenticated(httptest.NewRequest(http.MethodGet, "/payments/export", nil))
+
+	recorder := httptest.NewRecorder()
+	handler.HandleExportPayments()(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusInternalServerError, response.StatusCode)
+	c.Equal("application/json", response.Header.Get("Content-Type"))
+
+	var apiErr api.APIErr
+
+	err := json.NewDecoder(response.Body).Decode(&apiErr)
+	c.NoError(err)
+	c.Equal(api.ErrCodeInternalServerError, apiErr.Code())
+}
@@ -1,12 +1,13 @@
 package handler
 
 import (
-	"context"
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
 	"github.com/go-chi/chi/v5"
@@ -15,13 +16,17 @@ import (
 var (
 	errMissingTransactionID = api.NewInvalidRequestError(errors.New("missing transaction id"))
 	errInvalidInput         = api.NewInvalidRequestError(errors.New("invalid input"))
+	errUnauthenticated      = api.NewUnauthorizedError(auth.ErrMissingAPIKey)
+	errMissingOperator      = api.NewUnauthorizedError(auth.ErrMissingOperatorToken)
+	errInvalidIfMatch       = api.NewInvalidRequestError(errors.New("invalid If-Match header"))
 )
 
 // Handler interface to handle incoming requests to online payment plataform API
 type Handler interface {
-	HandleProcessPayment(ctx context.Context) http.HandlerFunc
-	HandleQueryPayment(ctx context.Context) http.HandlerFunc
-	HandleRefundPayment(ctx context.Context) http.HandlerFunc
+	HandleProcessPayment() http.HandlerFunc
+	HandleQueryPayment() http.HandlerFunc
+	HandleRefundPayment() http.HandlerFunc
+	HandleExportPayments() http.HandlerFunc
 }
 
 type handler struct {
@@ -36,8 +41,16 @@ func NewHandler(service service.OnlinePaymentService) Handler {
 }
 
 // HandleProcessPayments handles requests to create a payment
-func (h handler) HandleProcessPayment(ctx context.Context) http.HandlerFunc {
+func (h handler) HandleProcessPayment() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		merchantID, ok := auth.MerchantIDFromContext(ctx)
+		if !ok {
+			api.WriteErrorResponse(w, errUnauthenticated)
+			return
+		}
+
 		err := r.ParseForm()
 		if err != nil {
 			api.WriteErrorResponse(w, errInvalidInput)
@@ -50,50 +63,135 @@ func (h handler) HandleProcessPayment(ctx context.Context) http.HandlerFunc {
 			return
 		}
 
-		transaction, err := h.service.ProcessPayment(ctx, amount, r.FormValue("currency"), r.FormValue("payment_method"), r.FormValue("description"))
+		var applicationFeeAmount int64
+
+		if value := r.FormValue("application_fee_amount"); value != "" {
+			applicationFeeAmount, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				api.WriteErrorResponse(w, api.NewInvalidRequestError(models.ErrInvalidApplicationFeeAmount))
+				return
+			}
+		}
+
+		input := &models.TransactionInput{
+			Amount:               amount,
+			Currency:             r.FormValue("currency"),
+			PaymentMethod:        r.FormValue("payment_method"),
+			Description:          r.FormValue("description"),
+			TransferDestination:  r.FormValue("transfer_destination"),
+			ApplicationFeeAmount: applicationFeeAmount,
+			Customer:             r.FormValue("customer"),
+			IPAddress:            r.FormValue("ip_address"),
+			Country:              r.FormValue("country"),
+		}
+
+		transaction, err := h.service.ProcessPayment(ctx, merchantID, input)
 		if err != nil {
 			api.WriteErrorResponse(w, err)
 			return
 		}
 
+		w.Header().Set("ETag", etag(transaction.Version))
 		api.WriteJSONResponse(w, http.StatusOK, transaction)
 	}
 }
 
 // HandleQueryPayment handles requests to query a specific payment
-func (h handler) HandleQueryPayment(ctx context.Context) http.HandlerFunc {
+func (h handler) HandleQueryPayment() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		merchantID, ok := auth.MerchantIDFromContext(ctx)
+		if !ok {
+			api.WriteErrorResponse(w, errUnauthenticated)
+			return
+		}
+
 		transactionID := chi.URLParam(r, "id")
 		if transactionID == "" {
 			api.WriteErrorResponse(w, errMissingTransactionID)
 			return
 		}
 
-		transaction, err := h.service.QueryPayment(ctx, transactionID)
+		transaction, err := h.service.QueryPayment(ctx, merchantID, transactionID)
 		if err != nil {
 			api.WriteErrorResponse(w, err)
 			return
 		}
 
+		key, ok := auth.APIKeyFromContext(ctx)
+		if ok && key.Type == models.APIKeyTypePublishable {
+			api.WriteJSONResponse(w, http.StatusOK, transaction.PaymentStatus())
+			return
+		}
+
+		w.Header().Set("ETag", etag(transaction.Version))
 		api.WriteJSONResponse(w, http.StatusOK, transaction)
 	}
 }
 
 // HandleRefundPayment handles requests to refund a specific payment
-func (h handler) HandleRefundPayment(ctx context.Context) http.HandlerFunc {
+func (h handler) HandleRefundPayment() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		merchantID, ok := auth.MerchantIDFromContext(ctx)
+		if !ok {
+			api.WriteErrorResponse(w, errUnauthenticated)
+			return
+		}
+
 		transactionID := chi.URLParam(r, "id")
 		if transactionID == "" {
 			api.WriteErrorResponse(w, errMissingTransactionID)
 			return
 		}
 
-		transaction, err := h.service.RefundPayment(ctx, transactionID)
+		expectedVersion, err := parseIfMatch(r.Header.Get("If-Match"))
 		if err != nil {
 			api.WriteErrorResponse(w, err)
 			return
 		}
 
-		api.WriteJSONResponse(w, http.StatusOK, transaction)
+		transaction, err := h.service.RefundPayment(ctx, merchantID, transactionID, expectedVersion)
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		status := http.StatusOK
+
+		// refunds over the approval threshold are held until an operator approves them
+		if transaction.Status == models.TransactionStatusRefundRequested {
+			status = http.StatusAccepted
+		}
+
+		w.Header().Set("ETag", etag(transaction.Version))
+		api.WriteJSONResponse(w, status, transaction)
 	}
 }
+
+// etag formats the version of a transaction as a strong entity tag
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// parseIfMatch returns the version expected by the client, where 0 means any version
+func parseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	value, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		return 0, errInvalidIfMatch
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version <= 0 {
+		return 0, errInvalidIfMatch
+	}
+
+	return version, nil
+}
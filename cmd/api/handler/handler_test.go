@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -9,16 +8,39 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
 	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
+func authenticated(req *http.Request) *http.Request {
+	key := &models.APIKey{
+		KeyID:      "KEY_123",
+		MerchantID: "MCH_123",
+		Type:       models.APIKeyTypeSecret,
+		Mode:       models.APIKeyModeTest,
+	}
+
+	return req.WithContext(auth.WithAPIKey(req.Context(), key))
+}
+
+func authenticatedOperator(req *http.Request, name string) *http.Request {
+	operator := &models.Operator{
+		Name: name,
+		Role: models.OperatorRoleAdmin,
+	}
+
+	return req.WithContext(auth.WithOperator(req.Context(), operator))
+}
+
 func TestHandleProcessPayment(t *testing.T) {
 	c := require.New(t)
 
@@ -38,7 +60,12 @@ func TestHandleProcessPayment(t *testing.T) {
 		},
 	}
 
-	mockService.On("ProcessPayment", context.Background(), int64(2000), "usd", "card_pm_visa", "Transaction for payment amount of 2000").Return(expectedTransaction, nil)
+	mockService.On("ProcessPayment", mock.Anything, "MCH_123", &models.TransactionInput{
+		Amount:        2000,
+		Currency:      "usd",
+		PaymentMethod: "card_pm_visa",
+		Description:   "Transaction for payment amount of 2000",
+	}).Return(expectedTransaction, nil)
 
 	form := url.Values{}
 	form.Add("amount", "2000")
@@ -49,9 +76,10 @@ func TestHandleProcessPayment(t *testing.T) {
 	handler := NewHandler(&mockService)
 
 	router := chi.NewRouter()
-	router.Post("/payments", http.HandlerFunc(handler.HandleProcessPayment(context.Background())))
+	router.Post("/payments", http.HandlerFunc(handler.HandleProcessPayment()))
 
 	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(form.Encode()))
+	req = authenticated(req)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	recorder := httptest.NewRecorder()
@@ -76,9 +104,10 @@ func TestHandleProcessPaymentParseFormFailure(t *testing.T) {
 	handler := handler{}
 
 	router := chi.NewRouter()
-	router.Post("/payments", http.HandlerFunc(handler.HandleProcessPayment(context.Background())))
+	router.Post("/payments", http.HandlerFunc(handler.HandleProcessPayment()))
 
 	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader("foo%3z1%26bar%3D2"))
+	req = authenticated(req)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	recorder := httptest.NewRecorder()
@@ -110,9 +139,10 @@ func TestHandleProcessPaymentInvalidAmount(t *testing.T) {
 	handler := handler{}
 
 	router := chi.NewRouter()
-	router.Post("/payments", http.HandlerFunc(handler.HandleProcessPayment(context.Background())))
+	router.Post("/payments", http.HandlerFunc(handler.HandleProcessPayment()))
 
 	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(form.Encode()))
+	req = authenticated(req)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	recorder := httptest.NewRecorder()
@@ -139,7 +169,12 @@ func TestHandleProcessPaymentFailure(t *testing.T) {
 
 	unknownErr := errors.New("unknown error")
 
-	mockService.On("ProcessPayment", context.Background(), int64(2000), "usd", "card_pm_visa", "Transaction for payment amount of 2000").Return(nil, unknownErr)
+	mockService.On("ProcessPayment", mock.Anything, "MCH_123", &models.TransactionInput{
+		Amount:        2000,
+		Currency:      "usd",
+		PaymentMethod: "card_pm_visa",
+		Description:   "Transaction for payment amount of 2000",
+	}).Return(nil, unknownErr)
 
 	form := url.Values{}
 	form.Add("amount", "2000")
@@ -150,9 +185,10 @@ func TestHandleProcessPaymentFailure(t *testing.T) {
 	handler := NewHandler(&mockService)
 
 	router := chi.NewRouter()
-	router.Post("/payments", http.HandlerFunc(handler.HandleProcessPayment(context.Background())))
+	router.Post("/payments", http.HandlerFunc(handler.HandleProcessPayment()))
 
 	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(form.Encode()))
+	req = authenticated(req)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	recorder := httptest.NewRecorder()
@@ -190,14 +226,15 @@ func TestHandleGetPayment(t *testing.T) {
 		},
 	}
 
-	mockService.On("QueryPayment", context.Background(), "TXN_123").Return(expectedTransaction, nil)
+	mockService.On("QueryPayment", mock.Anything, "MCH_123", "TXN_123").Return(expectedTransaction, nil)
 
 	handler := NewHandler(&mockService)
 
 	router := chi.NewRouter()
-	router.Get("/payments/{id}", http.HandlerFunc(handler.HandleQueryPayment(context.Background())))
+	router.Get("/payments/{id}", http.HandlerFunc(handler.HandleQueryPayment()))
 
 	req := httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil)
+	req = authenticated(req)
 
 	recorder := httptest.NewRecorder()
 	router.ServeHTTP(recorder, req)
@@ -215,15 +252,65 @@ func TestHandleGetPayment(t *testing.T) {
 	c.Equal(expectedTransaction, transaction)
 }
 
+func TestHandleGetPaymentPublishableKey(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockOnlinePaymentService{}
+
+	updatedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mockService.On("QueryPayment", mock.Anything, "MCH_123", "TXN_123").Return(&models.Transaction{
+		TransactionID:    "TXN_123",
+		Status:           models.TransactionStatusFailure,
+		Description:      "order 42",
+		FailureReason:    "card_declined",
+		Amount:           2000,
+		Currency:         "usd",
+		AdditionalFields: map[string]interface{}{"customer_email": "jane@example.com"},
+		UpdatedAt:        updatedAt,
+	}, nil)
+
+	handler := NewHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/payments/{id}", http.HandlerFunc(handler.HandleQueryPayment()))
+
+	req := httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil)
+	req = req.WithContext(auth.WithAPIKey(req.Context(), &models.APIKey{KeyID: "KEY_456", MerchantID: "MCH_123", Type: models.APIKeyTypePublishable}))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusOK, response.StatusCode)
+
+	var status *models.PaymentStatus
+
+	err := json.NewDecoder(response.Body).Decode(&status)
+	c.NoError(err)
+	c.Equal(&models.PaymentStatus{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusFailure,
+		FailureReason: "card_declined",
+		Amount:        2000,
+		Currency:      "usd",
+		UpdatedAt:     updatedAt,
+	}, status, "only the outcome is shown to publishable keys")
+}
+
 func TestHandleQueryPaymentMissingTransactionID(t *testing.T) {
 	c := require.New(t)
 
 	handler := handler{}
 
 	router := chi.NewRouter()
-	router.Get("/payments/", http.HandlerFunc(handler.HandleQueryPayment(context.Background())))
+	router.Get("/payments/", http.HandlerFunc(handler.HandleQueryPayment()))
 
 	req := httptest.NewRequest(http.MethodGet, "/payments/", nil)
+	req = authenticated(req)
 
 	recorder := httptest.NewRecorder()
 	router.ServeHTTP(recorder, req)
@@ -249,14 +336,15 @@ func TestHandleGetPaymentTransactionNotFound(t *testing.T) {
 
 	errTransactionNotFound := api.NewResourceNotFoundError(database.ErrTransactionNotFound, "transaction")
 
-	mockService.On("QueryPayment", context.Background(), "TXN_123").Return(nil, errTransactionNotFound)
+	mockService.On("QueryPayment", mock.Anything, "MCH_123", "TXN_123").Return(nil, errTransactionNotFound)
 
 	handler := NewHandler(&mockService)
 
 	router := chi.NewRouter()
-	router.Get("/payments/{id}", http.HandlerFunc(handler.HandleQueryPayment(context.Background())))
+	router.Get("/payments/{id}", http.HandlerFunc(handler.HandleQueryPayment()))
 
 	req := httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil)
+	req = authenticated(req)
 
 	recorder := httptest.NewRecorder()
 	router.ServeHTTP(recorder, req)
@@ -293,16 +381,19 @@ func TestHandleRefundPayment(t *testing.T) {
 			"charge_id":         "ch_123",
 			"payment_intent_id": "pi_123",
 		},
+		Version: 4,
 	}
 
-	mockService.On("RefundPayment", context.Background(), "TXN_123").Return(expectedTransaction, nil)
+	mockService.On("RefundPayment", mock.Anything, "MCH_123", "TXN_123", 3).Return(expectedTransaction, nil)
 
 	handler := NewHandler(&mockService)
 
 	router := chi.NewRouter()
-	router.Post("/payments/{id}/refunds", http.HandlerFunc(handler.HandleRefundPayment(context.Background())))
+	router.Post("/payments/{id}/refunds", http.HandlerFunc(handler.HandleRefundPayment()))
 
 	req := httptest.NewRequest(http.MethodPost, "/payments/TXN_123/refunds", nil)
+	req.Header.Set("If-Match", `"3"`)
+	req = authenticated(req)
 
 	recorder := httptest.NewRecorder()
 	router.ServeHTTP(recorder, req)
@@ -312,6 +403,7 @@ func TestHandleRefundPayment(t *testing.T) {
 	defer response.Body.Close()
 
 	c.Equal(http.StatusOK, response.StatusCode)
+	c.Equal(`"4"`, response.Header.Get("ETag"))
 
 	var transaction *models.Transaction
 
@@ -326,9 +418,10 @@ func TestHandleRefundPaymentMissingTransactionID(t *testing.T) {
 	handler := handler{}
 
 	router := chi.NewRouter()
-	router.Get("/payments/refunds", http.HandlerFunc(handler.HandleRefundPayment(context.Background())))
+	router.Get("/payments/refunds", http.HandlerFunc(handler.HandleRefundPayment()))
 
 	req := httptest.NewRequest(http.MethodGet, "/payments/refunds", nil)
+	req = authenticated(req)
 
 	recorder := httptest.NewRecorder()
 	router.ServeHTTP(recorder, req)
@@ -347,6 +440,35 @@ func TestHandleRefundPaymentMissingTransactionID(t *testing.T) {
 	c.Contains(apiErr.Error(), errMissingTransactionID.Error())
 }
 
+func TestHandleRefundPaymentInvalidIfMatch(t *testing.T) {
+	c := require.New(t)
+
+	handler := handler{}
+
+	router := chi.NewRouter()
+	router.Post("/payments/{id}/refunds", http.HandlerFunc(handler.HandleRefundPayment()))
+
+	req := httptest.NewRequest(http.MethodPost, "/payments/TXN_123/refunds", nil)
+	req.Header.Set("If-Match", `"abc"`)
+	req = authenticated(req)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusBadRequest, response.StatusCode)
+
+	var apiErr api.APIErr
+
+	err := json.NewDecoder(response.Body).Decode(&apiErr)
+	c.NoError(err)
+	c.Equal(api.ErrCodeInvalidRequestError, apiErr.Code())
+	c.Contains(apiErr.Error(), errInvalidIfMatch.Error())
+}
+
 func TestHandleRefundPaymentChargeAlreadyRefunded(t *testing.T) {
 	c := require.New(t)
 
@@ -354,14 +476,15 @@ func TestHandleRefundPaymentChargeAlreadyRefunded(t *testing.T) {
 
 	errChargeAlreadyRefunded := api.NewInvalidRequestError(stripe.ErrChargeAlreadyRefunded)
 
-	mockService.On("RefundPayment", context.Background(), "TXN_123").Return(nil, errChargeAlreadyRefunded)
+	mockService.On("RefundPayment", mock.Anything, "MCH_123", "TXN_123", 0).Return(nil, errChargeAlreadyRefunded)
 
 	handler := NewHandler(&mockService)
 
 	router := chi.NewRouter()
-	router.Get("/payments/{id}/refunds", http.HandlerFunc(handler.HandleRefundPayment(context.Background())))
+	router.Get("/payments/{id}/refunds", http.HandlerFunc(handler.HandleRefundPayment()))
 
 	req := httptest.NewRequest(http.MethodGet, "/payments/TXN_123/refunds", nil)
+	req = authenticated(req)
 
 	recorder := httptest.NewRecorder()
 	router.ServeHTTP(recorder, req)
@@ -379,3 +502,29 @@ func TestHandleRefundPaymentChargeAlreadyRefunded(t *testing.T) {
 	c.Equal(api.ErrCodeInvalidRequestError, apiErr.Code())
 	c.Contains(apiErr.Error(), errChargeAlreadyRefunded.Error())
 }
+
+func TestHandleProcessPaymentUnauthenticated(t *testing.T) {
+	c := require.New(t)
+
+	handler := handler{}
+
+	router := chi.NewRouter()
+	router.Post("/payments", http.HandlerFunc(handler.HandleProcessPayment()))
+
+	req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusUnauthorized, response.StatusCode)
+
+	var apiErr api.APIErr
+
+	err := json.NewDecoder(response.Body).Decode(&apiErr)
+	c.NoError(err)
+	c.Equal(api.ErrCodeUnauthorized, apiErr.Code())
+}
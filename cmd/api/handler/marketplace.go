@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+)
+
+// MarketplaceHandler interface to handle incoming requests to manage the connected accounts of a merchant
+type MarketplaceHandler interface {
+	HandleCreateConnectedAccount() http.HandlerFunc
+	HandleListConnectedAccounts() http.HandlerFunc
+}
+
+type marketplaceHandler struct {
+	service service.MarketplaceService
+}
+
+// NewMarketplaceHandler constructor to handle incoming requests to manage connected accounts
+func NewMarketplaceHandler(service service.MarketplaceService) MarketplaceHandler {
+	return marketplaceHandler{
+		service: service,
+	}
+}
+
+// HandleCreateConnectedAccount handles requests to connect the Stripe account of one of the merchant's sellers
+func (h marketplaceHandler) HandleCreateConnectedAccount() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		merchantID, ok := auth.MerchantIDFromContext(ctx)
+		if !ok {
+			api.WriteErrorResponse(w, errUnauthenticated)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			api.WriteErrorResponse(w, errInvalidInput)
+			return
+		}
+
+		account, err := h.service.CreateConnectedAccount(ctx, merchantID, r.FormValue("name"), r.FormValue("provider_account_id"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusCreated, account)
+	}
+}
+
+// HandleListConnectedAccounts handles requests to list the connected accounts of the merchant
+func (h marketplaceHandler) HandleListConnectedAccounts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		merchantID, ok := auth.MerchantIDFromContext(ctx)
+		if !ok {
+			api.WriteErrorResponse(w, errUnauthenticated)
+			return
+		}
+
+		accounts, err := h.service.ListConnectedAccounts(ctx, merchantID)
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, accounts)
+	}
+}
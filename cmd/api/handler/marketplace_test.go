@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCreateConnectedAccount(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockMarketplaceService{}
+
+	expectedAccount := &models.ConnectedAccount{
+		AccountID:         "ACC_123",
+		MerchantID:        "MCH_123",
+		Name:              "Seller",
+		Provider:          models.PaymentProviderStripe,
+		ProviderAccountID: "acct_123",
+	}
+
+	mockService.On("CreateConnectedAccount", mock.Anything, "MCH_123", "Seller", "acct_123").Return(expectedAccount, nil)
+
+	form := url.Values{}
+	form.Add("name", "Seller")
+	form.Add("provider_account_id", "acct_123")
+
+	handler := NewMarketplaceHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/accounts", http.HandlerFunc(handler.HandleCreateConnectedAccount()))
+
+	req := httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(form.Encode()))
+	req = authenticated(req)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusCreated, response.StatusCode)
+
+	var account *models.ConnectedAccount
+
+	err := json.NewDecoder(response.Body).Decode(&account)
+	c.NoError(err)
+	c.Equal(expectedAccount, account)
+}
+
+func TestHandleListConnectedAccountsUnauthenticated(t *testing.T) {
+	c := require.New(t)
+
+	handler := NewMarketplaceHandler(&service.MockMarketplaceService{})
+
+	router := chi.NewRouter()
+	router.Get("/accounts", http.HandlerFunc(handler.HandleListConnectedAccounts()))
+
+	req := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// MerchantHandler interface to handle incoming requests to manage merchants and their API keys
+type MerchantHandler interface {
+	HandleCreateMerchant() http.HandlerFunc
+	HandleRotateAPIKey() http.HandlerFunc
+}
+
+type merchantHandler struct {
+	service service.MerchantService
+}
+
+// NewMerchantHandler constructor to handle incoming requests to manage merchants
+func NewMerchantHandler(service service.MerchantService) MerchantHandler {
+	return merchantHandler{
+		service: service,
+	}
+}
+
+// HandleCreateMerchant handles requests to create a merchant and issue its initial API keys
+func (h merchantHandler) HandleCreateMerchant() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		err := r.ParseForm()
+		if err != nil {
+			api.WriteErrorResponse(w, errInvalidInput)
+			return
+		}
+
+		credentials, err := h.service.CreateMerchant(ctx, r.FormValue("name"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusCreated, credentials)
+	}
+}
+
+// HandleRotateAPIKey handles requests to rotate one of the authenticated merchant's API keys
+func (h merchantHandler) HandleRotateAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		merchantID, ok := auth.MerchantIDFromContext(ctx)
+		if !ok {
+			api.WriteErrorResponse(w, errUnauthenticated)
+			return
+		}
+
+		credential, err := h.service.RotateAPIKey(ctx, merchantID, chi.URLParam(r, "id"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusCreated, credential)
+	}
+}
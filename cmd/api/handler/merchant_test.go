@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCreateMerchant(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockMerchantService{}
+
+	expectedCredentials := &models.MerchantCredentials{
+		Merchant: models.Merchant{
+			MerchantID: "MCH_123",
+			Name:       "Acme",
+		},
+		APIKeys: []*models.APIKeyCredential{
+			{
+				APIKey: models.APIKey{KeyID: "KEY_123", MerchantID: "MCH_123", Type: models.APIKeyTypeSecret, Mode: models.APIKeyModeTest, Prefix: "sk_test_abcd"},
+				Key:    "sk_test_abcdef",
+			},
+		},
+	}
+
+	mockService.On("CreateMerchant", mock.Anything, "Acme").Return(expectedCredentials, nil)
+
+	form := url.Values{}
+	form.Add("name", "Acme")
+
+	handler := NewMerchantHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/merchants", http.HandlerFunc(handler.HandleCreateMerchant()))
+
+	req := httptest.NewRequest(http.MethodPost, "/merchants", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusCreated, response.StatusCode)
+
+	var credentials *models.MerchantCredentials
+
+	err := json.NewDecoder(response.Body).Decode(&credentials)
+	c.NoError(err)
+	c.Equal(expectedCredentials, credentials)
+}
+
+func TestHandleRotateAPIKey(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockMerchantService{}
+
+	expectedCredential := &models.APIKeyCredential{
+		APIKey: models.APIKey{KeyID: "KEY_456", MerchantID: "MCH_123", Type: models.APIKeyTypeSecret, Mode: models.APIKeyModeTest, Prefix: "sk_test_abcd"},
+		Key:    "sk_test_abcdef",
+	}
+
+	mockService.On("RotateAPIKey", mock.Anything, "MCH_123", "KEY_123").Return(expectedCredential, nil)
+
+	handler := NewMerchantHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/keys/{id}/rotate", http.HandlerFunc(handler.HandleRotateAPIKey()))
+
+	req := httptest.NewRequest(http.MethodPost, "/keys/KEY_123/rotate", nil)
+	req = authenticated(req)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusCreated, response.StatusCode)
+
+	var credential *models.APIKeyCredential
+
+	err := json.NewDecoder(response.Body).Decode(&credential)
+	c.NoError(err)
+	c.Equal(expectedCredential, credential)
+}
+
+func TestHandleRotateAPIKeyUnauthenticated(t *testing.T) {
+	c := require.New(t)
+
+	handler := merchantHandler{}
+
+	router := chi.NewRouter()
+	router.Post("/keys/{id}/rotate", http.HandlerFunc(handler.HandleRotateAPIKey()))
+
+	req := httptest.NewRequest(http.MethodPost, "/keys/KEY_123/rotate", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusUnauthorized, response.StatusCode)
+
+	var apiErr api.APIErr
+
+	err := json.NewDecoder(response.Body).Decode(&apiErr)
+	c.NoError(err)
+	c.Equal(api.ErrCodeUnauthorized, apiErr.Code())
+}
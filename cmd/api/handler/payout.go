@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// PayoutHandler interface to handle incoming requests to reconcile the payouts of the platform
+type PayoutHandler interface {
+	HandleListPayouts() http.HandlerFunc
+	HandleListPayoutTransactions() http.HandlerFunc
+}
+
+type payoutHandler struct {
+	service service.PayoutService
+}
+
+// NewPayoutHandler constructor to handle incoming requests to reconcile payouts
+func NewPayoutHandler(service service.PayoutService) PayoutHandler {
+	return payoutHandler{
+		service: service,
+	}
+}
+
+// HandleListPayouts handles requests to list the payouts of the platform, most recent first
+func (h payoutHandler) HandleListPayouts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parsePayoutFilter(r.URL.Query())
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		payouts, err := h.service.ListPayouts(r.Context(), filter)
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, payouts)
+	}
+}
+
+// HandleListPayoutTransactions handles requests to list the transactions settled by a payout
+func (h payoutHandler) HandleListPayoutTransactions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		transactions, err := h.service.ListPayoutTransactions(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, transactions)
+	}
+}
+
+// parsePayoutFilter reads the filter of the payouts from the query string
+func parsePayoutFilter(query url.Values) (*models.PayoutFilter, error) {
+	filter := &models.PayoutFilter{
+		Status: models.PayoutStatus(query.Get("status")),
+	}
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, errInvalidStatus
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, errInvalidLimit
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleListPayouts(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockPayoutService{}
+
+	expectedPayouts := []*models.Payout{
+		{
+			PayoutID:         "PO_123",
+			Provider:         models.PaymentProviderStripe,
+			ProviderPayoutID: "po_123",
+			Status:           models.PayoutStatusPaid,
+			Amount:           1000,
+			Currency:         "usd",
+		},
+	}
+
+	mockService.On("ListPayouts", mock.Anything, &models.PayoutFilter{Status: models.PayoutStatusPaid, Limit: 10}).Return(expectedPayouts, nil)
+
+	handler := NewPayoutHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/payouts", http.HandlerFunc(handler.HandleListPayouts()))
+
+	req := httptest.NewRequest(http.MethodGet, "/payouts?status=paid&limit=10", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusOK, response.StatusCode)
+
+	var payouts []*models.Payout
+
+	err := json.NewDecoder(response.Body).Decode(&payouts)
+	c.NoError(err)
+	c.Equal(expectedPayouts, payouts)
+}
+
+func TestHandleListPayoutsInvalidStatus(t *testing.T) {
+	c := require.New(t)
+
+	handler := NewPayoutHandler(&service.MockPayoutService{})
+
+	router := chi.NewRouter()
+	router.Get("/payouts", http.HandlerFunc(handler.HandleListPayouts()))
+
+	req := httptest.NewRequest(http.MethodGet, "/payouts?status=settled", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func TestHandleListPayoutTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockPayoutService{}
+
+	expectedTransactions := []*models.Transaction{{TransactionID: "TXN_123", Status: models.TransactionStatusSucceeded}}
+
+	mockService.On("ListPayoutTransactions", mock.Anything, "PO_123").Return(expectedTransactions, nil)
+
+	handler := NewPayoutHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/payouts/{id}/transactions", http.HandlerFunc(handler.HandleListPayoutTransactions()))
+
+	req := httptest.NewRequest(http.MethodGet, "/payouts/PO_123/transactions", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusOK, response.StatusCode)
+
+	var transactions []*models.Transaction
+
+	err := json.NewDecoder(response.Body).Decode(&transactions)
+	c.NoError(err)
+	c.Equal(expectedTransactions, transactions)
+}
+
+func TestHandleListPayoutTransactionsNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockPayoutService{}
+
+	mockService.On("ListPayoutTransactions", mock.Anything, "PO_123").Return(nil, api.NewResourceNotFoundError(database.ErrPayoutNotFound, "payout"))
+
+	handler := NewPayoutHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/payouts/{id}/transactions", http.HandlerFunc(handler.HandleListPayoutTransactions()))
+
+	req := httptest.NewRequest(http.MethodGet, "/payouts/PO_123/transactions", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusNotFound, recorder.Code)
+}
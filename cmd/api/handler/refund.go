@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// RefundRequestHandler interface to handle incoming requests to work the queue of refunds held for approval
+type RefundRequestHandler interface {
+	HandleListRefundRequests() http.HandlerFunc
+	HandleApproveRefundRequest() http.HandlerFunc
+	HandleRejectRefundRequest() http.HandlerFunc
+}
+
+type refundRequestHandler struct {
+	service service.RefundRequestService
+}
+
+// NewRefundRequestHandler constructor to handle incoming requests to approve refunds
+func NewRefundRequestHandler(service service.RefundRequestService) RefundRequestHandler {
+	return refundRequestHandler{
+		service: service,
+	}
+}
+
+// HandleListRefundRequests handles requests to list the refunds held for approval, oldest first
+func (h refundRequestHandler) HandleListRefundRequests() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseRefundRequestFilter(r.URL.Query())
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		requests, err := h.service.ListRefundRequests(r.Context(), filter)
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, requests)
+	}
+}
+
+// HandleApproveRefundRequest handles requests of the authenticated operator to approve a held refund, issuing it with
+// the provider
+func (h refundRequestHandler) HandleApproveRefundRequest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		operator, ok := auth.OperatorFromContext(r.Context())
+		if !ok {
+			api.WriteErrorResponse(w, errMissingOperator)
+			return
+		}
+
+		request, err := h.service.ApproveRefundRequest(r.Context(), operator.Name, chi.URLParam(r, "id"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, request)
+	}
+}
+
+// HandleRejectRefundRequest handles requests of the authenticated operator to reject a held refund, restoring its
+// transaction
+func (h refundRequestHandler) HandleRejectRefundRequest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		operator, ok := auth.OperatorFromContext(r.Context())
+		if !ok {
+			api.WriteErrorResponse(w, errMissingOperator)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			api.WriteErrorResponse(w, errInvalidInput)
+			return
+		}
+
+		request, err := h.service.RejectRefundRequest(r.Context(), operator.Name, chi.URLParam(r, "id"), r.FormValue("reason"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, request)
+	}
+}
+
+// parseRefundRequestFilter reads the filter of the refund requests from the query string
+func parseRefundRequestFilter(query url.Values) (*models.RefundRequestFilter, error) {
+	filter := &models.RefundRequestFilter{
+		Status: models.RefundRequestStatus(query.Get("status")),
+	}
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, errInvalidStatus
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, errInvalidLimit
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleListRefundRequests(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockRefundRequestService{}
+
+	expectedRequests := []*models.RefundRequest{
+		{
+			RefundRequestID: "RFR_123",
+			TransactionID:   "TXN_123",
+			Status:          models.RefundRequestStatusPending,
+			Amount:          150000,
+			Currency:        "usd",
+			RequestedBy:     "merchant_123",
+		},
+	}
+
+	mockService.On("ListRefundRequests", mock.Anything, &models.RefundRequestFilter{Status: models.RefundRequestStatusPending, Limit: 20}).Return(expectedRequests, nil)
+
+	handler := NewRefundRequestHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/refunds", http.HandlerFunc(handler.HandleListRefundRequests()))
+
+	req := httptest.NewRequest(http.MethodGet, "/refunds?status=pending&limit=20", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+
+	var requests []*models.RefundRequest
+
+	err := json.NewDecoder(recorder.Body).Decode(&requests)
+	c.NoError(err)
+	c.Len(requests, 1)
+	c.Equal("RFR_123", requests[0].RefundRequestID)
+}
+
+func TestHandleListRefundRequestsInvalidStatus(t *testing.T) {
+	c := require.New(t)
+
+	handler := NewRefundRequestHandler(&service.MockRefundRequestService{})
+
+	router := chi.NewRouter()
+	router.Get("/refunds", http.HandlerFunc(handler.HandleListRefundRequests()))
+
+	req := httptest.NewRequest(http.MethodGet, "/refunds?status=escalated", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func TestHandleApproveRefundRequest(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockRefundRequestService{}
+
+	mockService.On("ApproveRefundRequest", mock.Anything, "alice", "RFR_123").Return(&models.RefundRequest{
+		RefundRequestID: "RFR_123",
+		Status:          models.RefundRequestStatusApproved,
+		DecidedBy:       "alice",
+	}, nil)
+
+	handler := NewRefundRequestHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/refunds/{id}/approve", http.HandlerFunc(handler.HandleApproveRefundRequest()))
+
+	req := httptest.NewRequest(http.MethodPost, "/refunds/RFR_123/approve", nil)
+	req = authenticatedOperator(req, "alice")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandleApproveRefundRequestSelfApproval(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockRefundRequestService{}
+
+	mockService.On("ApproveRefundRequest", mock.Anything, "alice", "RFR_123").Return(nil, service.ErrSelfApproval)
+
+	handler := NewRefundRequestHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/refunds/{id}/approve", http.HandlerFunc(handler.HandleApproveRefundRequest()))
+
+	req := httptest.NewRequest(http.MethodPost, "/refunds/RFR_123/approve", nil)
+	req = authenticatedOperator(req, "alice")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func TestHandleRejectRefundRequest(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockRefundRequestService{}
+
+	mockService.On("RejectRefundRequest", mock.Anything, "alice", "RFR_123", "goods shipped").Return(&models.RefundRequest{
+		RefundRequestID: "RFR_123",
+		Status:          models.RefundRequestStatusRejected,
+		DecidedBy:       "alice",
+		RejectionReason: "goods shipped",
+	}, nil)
+
+	handler := NewRefundRequestHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/refunds/{id}/reject", http.HandlerFunc(handler.HandleRejectRefundRequest()))
+
+	body := url.Values{"reason": {"goods shipped"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/refunds/RFR_123/reject", strings.NewReader(body.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = authenticatedOperator(req, "alice")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+	mockService.AssertExpectations(t)
+}
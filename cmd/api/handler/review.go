@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// ReviewHandler interface to handle incoming requests to work the queue of payments flagged for review
+type ReviewHandler interface {
+	HandleListReviews() http.HandlerFunc
+	HandleApproveReview() http.HandlerFunc
+	HandleRejectReview() http.HandlerFunc
+}
+
+type reviewHandler struct {
+	service service.ReviewService
+}
+
+// NewReviewHandler constructor to handle incoming requests to review payments
+func NewReviewHandler(service service.ReviewService) ReviewHandler {
+	return reviewHandler{
+		service: service,
+	}
+}
+
+// HandleListReviews handles requests to list the reviews of flagged payments, oldest first
+func (h reviewHandler) HandleListReviews() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseReviewFilter(r.URL.Query())
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		reviews, err := h.service.ListReviews(r.Context(), filter)
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, reviews)
+	}
+}
+
+// HandleApproveReview handles requests of the authenticated operator to approve a review, capturing its payment
+func (h reviewHandler) HandleApproveReview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		operator, ok := auth.OperatorFromContext(r.Context())
+		if !ok {
+			api.WriteErrorResponse(w, errMissingOperator)
+			return
+		}
+
+		review, err := h.service.ApproveReview(r.Context(), operator.Name, chi.URLParam(r, "id"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, review)
+	}
+}
+
+// HandleRejectReview handles requests of the authenticated operator to reject a review, canceling the authorization
+// of its payment
+func (h reviewHandler) HandleRejectReview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		operator, ok := auth.OperatorFromContext(r.Context())
+		if !ok {
+			api.WriteErrorResponse(w, errMissingOperator)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			api.WriteErrorResponse(w, errInvalidInput)
+			return
+		}
+
+		review, err := h.service.RejectReview(r.Context(), operator.Name, chi.URLParam(r, "id"), r.FormValue("reason"))
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, review)
+	}
+}
+
+// parseReviewFilter reads the filter of the reviews from the query string
+func parseReviewFilter(query url.Values) (*models.ReviewFilter, error) {
+	filter := &models.ReviewFilter{
+		Status: models.ReviewStatus(query.Get("status")),
+	}
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, errInvalidStatus
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, errInvalidLimit
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
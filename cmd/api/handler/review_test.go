@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleListReviews(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockReviewService{}
+
+	expectedReviews := []*models.Review{
+		{
+			ReviewID:      "REV_123",
+			TransactionID: "TXN_123",
+			Status:        models.ReviewStatusPending,
+			Amount:        150000,
+			Currency:      "usd",
+			Rules:         []string{"large_charge"},
+		},
+	}
+
+	mockService.On("ListReviews", mock.Anything, &models.ReviewFilter{Status: models.ReviewStatusPending, Limit: 20}).Return(expectedReviews, nil)
+
+	handler := NewReviewHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/reviews", http.HandlerFunc(handler.HandleListReviews()))
+
+	req := httptest.NewRequest(http.MethodGet, "/reviews?status=pending&limit=20", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+
+	var reviews []*models.Review
+
+	err := json.NewDecoder(recorder.Body).Decode(&reviews)
+	c.NoError(err)
+	c.Len(reviews, 1)
+	c.Equal("REV_123", reviews[0].ReviewID)
+}
+
+func TestHandleListReviewsInvalidStatus(t *testing.T) {
+	c := require.New(t)
+
+	handler := NewReviewHandler(&service.MockReviewService{})
+
+	router := chi.NewRouter()
+	router.Get("/reviews", http.HandlerFunc(handler.HandleListReviews()))
+
+	req := httptest.NewRequest(http.MethodGet, "/reviews?status=escalated", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func TestHandleApproveReview(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockReviewService{}
+
+	mockService.On("ApproveReview", mock.Anything, "alice", "REV_123").Return(&models.Review{
+		ReviewID: "REV_123",
+		Status:   models.ReviewStatusApproved,
+		Reviewer: "alice",
+	}, nil)
+
+	handler := NewReviewHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/reviews/{id}/approve", http.HandlerFunc(handler.HandleApproveReview()))
+
+	req := httptest.NewRequest(http.MethodPost, "/reviews/REV_123/approve", nil)
+	req = authenticatedOperator(req, "alice")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandleRejectReview(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockReviewService{}
+
+	mockService.On("RejectReview", mock.Anything, "alice", "REV_123", "stolen card").Return(&models.Review{
+		ReviewID: "REV_123",
+		Status:   models.ReviewStatusRejected,
+		Reviewer: "alice",
+		Reason:   "stolen card",
+	}, nil)
+
+	handler := NewReviewHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/reviews/{id}/reject", http.HandlerFunc(handler.HandleRejectReview()))
+
+	body := url.Values{"reason": {"stolen card"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/reviews/REV_123/reject", strings.NewReader(body.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = authenticatedOperator(req, "alice")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusOK, recorder.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandleRejectReviewMissingReason(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockReviewService{}
+
+	mockService.On("RejectReview", mock.Anything, "alice", "REV_123", "").Return(nil, service.ErrMissingReason)
+
+	handler := NewReviewHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Post("/reviews/{id}/reject", http.HandlerFunc(handler.HandleRejectReview()))
+
+	req := httptest.NewRequest(http.MethodPost, "/reviews/REV_123/reject", nil)
+	req = authenticatedOperator(req, "alice")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func TestHandleApproveReviewMissingOperator(t *testing.T) {
+	c := require.New(t)
+
+	handler := NewReviewHandler(&service.MockReviewService{})
+
+	router := chi.NewRouter()
+	router.Post("/reviews/{id}/approve", http.HandlerFunc(handler.HandleApproveReview()))
+
+	req := httptest.NewRequest(http.MethodPost, "/reviews/REV_123/approve", nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	c.Equal(http.StatusUnauthorized, recorder.Code)
+}
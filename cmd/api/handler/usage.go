@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+)
+
+// UsageHandler interface to handle incoming requests to read the running totals a merchant is limited on
+type UsageHandler interface {
+	HandleListUsage() http.HandlerFunc
+}
+
+type usageHandler struct {
+	service service.UsageService
+}
+
+// NewUsageHandler constructor to handle incoming requests to read the usage of a merchant
+func NewUsageHandler(service service.UsageService) UsageHandler {
+	return usageHandler{
+		service: service,
+	}
+}
+
+// HandleListUsage handles requests to list the usage and limits of the merchant per currency
+func (h usageHandler) HandleListUsage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		merchantID, ok := auth.MerchantIDFromContext(ctx)
+		if !ok {
+			api.WriteErrorResponse(w, errUnauthenticated)
+			return
+		}
+
+		usages, err := h.service.ListUsage(ctx, merchantID)
+		if err != nil {
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		api.WriteJSONResponse(w, http.StatusOK, usages)
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleListUsage(t *testing.T) {
+	c := require.New(t)
+
+	mockService := service.MockUsageService{}
+
+	expectedUsages := []*models.Usage{
+		{
+			MerchantID:    "MCH_123",
+			Currency:      "usd",
+			DailyVolume:   5000,
+			MonthlyVolume: 20000,
+			WindowVolume:  30000,
+			WindowRefunds: 1000,
+			Limits:        &models.MerchantLimits{DailyVolume: 100000},
+		},
+	}
+
+	mockService.On("ListUsage", mock.Anything, "MCH_123").Return(expectedUsages, nil)
+
+	handler := NewUsageHandler(&mockService)
+
+	router := chi.NewRouter()
+	router.Get("/usage", http.HandlerFunc(handler.HandleListUsage()))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, authenticated(httptest.NewRequest(http.MethodGet, "/usage", nil)))
+
+	c.Equal(http.StatusOK, recorder.Code)
+
+	var usages []*models.Usage
+
+	err := json.NewDecoder(recorder.Body).Decode(&usages)
+	c.NoError(err)
+	c.Equal(expectedUsages, usages)
+}
+
+func TestHandleListUsageUnauthenticated(t *testing.T) {
+	c := require.New(t)
+
+	handler := NewUsageHandler(&service.MockUsageService{})
+
+	router := chi.NewRouter()
+	router.Get("/usage", http.HandlerFunc(handler.HandleListUsage()))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/usage", nil))
+
+	c.Equal(http.StatusUnauthorized, recorder.Code)
+}
@@ -3,61 +3,241 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aledeltoro/simple-online-payment-platform/cmd/api/handler"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/archive"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/migrations"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/encryption"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/health"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/limits"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/metrics"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/pricing"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/ratelimit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/risk"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/server"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/tracing"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	err := godotenv.Load()
+	cfg, err := config.Load(config.ServiceAPI)
 	if err != nil {
-		log.Fatalf("load .env file failed: %s", err.Error())
+		slog.Error("load configuration failed", slog.Any("error", err))
+		os.Exit(1)
 	}
 
-	port := os.Getenv("API_PORT")
-	if port == "" {
-		port = "3000"
+	logger := logging.New(os.Stdout, logging.ParseLevel(cfg.LogLevel))
+	slog.SetDefault(logger)
+
+	api.SetDebugMode(cfg.DebugMode)
+
+	err = run(cfg, logger)
+	if err != nil {
+		slog.Error("api stopped", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, logger *slog.Logger) error {
+	writeLimit := ratelimit.Limit{Requests: cfg.API.RateLimit.WritesPerMinute, Period: time.Minute}
+	readLimit := ratelimit.Limit{Requests: cfg.API.RateLimit.ReadsPerMinute, Period: time.Minute}
+	ipLimit := ratelimit.Limit{Requests: cfg.API.RateLimit.IPPerMinute, Period: time.Minute}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Init(ctx, "online-payment-platform-api", cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("initialize tracing failed: %w", err)
 	}
 
-	ctx := context.Background()
+	defer shutdownTracing(context.WithoutCancel(ctx))
+
+	pgxPool, err := postgres.Connect(ctx, cfg.Database)
+	if err != nil {
+		return fmt.Errorf("initialize database failed: %w", err)
+	}
+
+	defer pgxPool.Close()
+
+	if cfg.Database.AutoMigrate {
+		err = migrations.Up(ctx, pgxPool)
+		if err != nil {
+			return fmt.Errorf("migrate database failed: %w", err)
+		}
+	}
+
+	encryptor, err := encryption.New(cfg.Encryption)
+	if err != nil {
+		return fmt.Errorf("initialize encryption failed: %w", err)
+	}
+
+	pool := postgres.New(pgxPool, encryptor)
+
+	if statter, ok := pool.(metrics.PoolStatter); ok {
+		err = metrics.RegisterPoolStats(statter)
+		if err != nil {
+			return fmt.Errorf("register database pool metrics failed: %w", err)
+		}
+	}
+
+	database := tracing.NewDatabase(metrics.NewDatabase(pool))
+
+	if cfg.Retention.ArchiveMode == models.ArchiveModeNDJSON {
+		database = archive.NewDatabase(database, archive.NewFiles(cfg.Retention.ArchiveDir, encryptor))
+	}
 
-	database, err := postgres.Init(ctx)
+	stripeService, err := stripe.New(cfg.Stripe)
 	if err != nil {
-		log.Fatalf("initialize database failed: %s \n", err.Error())
+		return fmt.Errorf("initialize stripe payment processor failed: %w", err)
 	}
 
-	defer database.Close()
+	paymentprocessor := tracing.NewPaymentProcessor(metrics.NewPaymentProcessor(stripeService, models.PaymentProviderStripe), models.PaymentProviderStripe)
 
-	paymentprocessor, err := stripe.New()
+	riskEngine, err := risk.New(cfg.Risk.RulesFile, database)
 	if err != nil {
-		log.Fatalf("initialize stripe payment processor failed: %s \n", err.Error())
+		return fmt.Errorf("load risk rules failed: %w", err)
 	}
 
-	onlinePaymentService := service.NewOnlinePaymentService(database, paymentprocessor)
+	go riskEngine.Watch(ctx, cfg.Risk.ReloadInterval)
+
+	merchantLimits := limits.New(cfg.Limits)
+
+	onlinePaymentService := service.NewOnlinePaymentService(database, paymentprocessor, pricing.New(cfg.Pricing), riskEngine, cfg.Risk.ReviewTimeout, merchantLimits)
+
+	merchantService := service.NewMerchantService(database, cfg.API.APIKeyRotationGracePeriod)
+
+	paymentHandler := handler.NewHandler(onlinePaymentService)
+	merchantHandler := handler.NewMerchantHandler(merchantService)
+	marketplaceHandler := handler.NewMarketplaceHandler(service.NewMarketplaceService(database))
+	payoutHandler := handler.NewPayoutHandler(service.NewPayoutService(database))
+	blockListHandler := handler.NewBlockListHandler(service.NewBlockListService(database))
+	usageHandler := handler.NewUsageHandler(service.NewUsageService(database, merchantLimits))
+
+	reviewService := service.NewReviewService(database, paymentprocessor)
+	reviewHandler := handler.NewReviewHandler(reviewService)
+	refundRequestHandler := handler.NewRefundRequestHandler(service.NewRefundRequestService(database, paymentprocessor))
+	auditHandler := handler.NewAuditHandler(service.NewAuditService(database))
+	customerDataHandler := handler.NewCustomerDataHandler(service.NewCustomerDataService(database))
 
-	handler := handler.NewHandler(onlinePaymentService)
+	go service.SweepExpiredReviews(ctx, reviewService, cfg.Risk.ReviewSweepInterval)
+
+	healthChecker := health.NewChecker(2 * time.Second)
+	healthChecker.AddCheck("database", pool.Ping)
+	healthChecker.AddCheck("stripe", func(ctx context.Context) error {
+		return stripe.CheckConfiguration(cfg.Stripe)
+	})
+
+	authenticate := auth.Authenticate(database, cfg.API.PlatformMode)
+	authenticateOperator := auth.AuthenticateOperator(cfg.Operators.TokenSecrets())
+	rateLimitStore := ratelimit.NewMemoryStore()
+	rateLimit := ratelimit.Middleware(rateLimitStore, writeLimit, readLimit)
+	ipRateLimit := ratelimit.IPMiddleware(rateLimitStore, ipLimit)
 
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	r.Use(tracing.Middleware("online-payment-platform-api"), logging.Middleware(logger), audit.Middleware)
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Hello World!"))
 	})
+	r.Get("/healthz", healthChecker.HandleLiveness())
+	r.Get("/readyz", healthChecker.HandleReadiness())
+	r.Handle("/metrics", metrics.Handler())
 	r.Route("/payments", func(r chi.Router) {
-		r.Post("/", http.HandlerFunc(handler.HandleProcessPayment(ctx)))
-		r.Get("/{id}", http.HandlerFunc(handler.HandleQueryPayment(ctx)))
-		r.Post("/{id}/refunds", http.HandlerFunc(handler.HandleRefundPayment(ctx)))
+		r.Use(ipRateLimit, authenticate, rateLimit)
+		// publishable keys, which are shipped to browsers, can follow the status of a payment
+		r.Get("/{id}", http.HandlerFunc(paymentHandler.HandleQueryPayment()))
+
+		// payments are confirmed as they are created, so publishable keys can't create them
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireSecretKey)
+			r.Post("/", http.HandlerFunc(paymentHandler.HandleProcessPayment()))
+			r.Get("/export", http.HandlerFunc(paymentHandler.HandleExportPayments()))
+			r.Post("/{id}/refunds", http.HandlerFunc(paymentHandler.HandleRefundPayment()))
+		})
+	})
+	r.Route("/accounts", func(r chi.Router) {
+		r.Use(ipRateLimit, authenticate, rateLimit, auth.RequireSecretKey)
+		r.Post("/", http.HandlerFunc(marketplaceHandler.HandleCreateConnectedAccount()))
+		r.Get("/", http.HandlerFunc(marketplaceHandler.HandleListConnectedAccounts()))
+	})
+	r.Route("/payouts", func(r chi.Router) {
+		r.Use(rateLimit, authenticateOperator, auth.RequirePermission(models.PermissionReadPayouts))
+		r.Get("/", http.HandlerFunc(payoutHandler.HandleListPayouts()))
+		r.Get("/{id}/transactions", http.HandlerFunc(payoutHandler.HandleListPayoutTransactions()))
 	})
+	r.Route("/reviews", func(r chi.Router) {
+		r.Use(rateLimit, authenticateOperator)
+		r.With(auth.RequirePermission(models.PermissionReadReviews)).Get("/", http.HandlerFunc(reviewHandler.HandleListReviews()))
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequirePermission(models.PermissionDecideReviews))
+			r.Post("/{id}/approve", http.HandlerFunc(reviewHandler.HandleApproveReview()))
+			r.Post("/{id}/reject", http.HandlerFunc(reviewHandler.HandleRejectReview()))
+		})
+	})
+	r.Route("/refunds", func(r chi.Router) {
+		r.Use(rateLimit, authenticateOperator)
+		r.With(auth.RequirePermission(models.PermissionReadRefundRequests)).Get("/", http.HandlerFunc(refundRequestHandler.HandleListRefundRequests()))
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequirePermission(models.PermissionDecideRefundRequests))
+			r.Post("/{id}/approve", http.HandlerFunc(refundRequestHandler.HandleApproveRefundRequest()))
+			r.Post("/{id}/reject", http.HandlerFunc(refundRequestHandler.HandleRejectRefundRequest()))
+		})
+	})
+	r.Route("/blocklist", func(r chi.Router) {
+		r.Use(rateLimit, authenticateOperator)
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequirePermission(models.PermissionReadBlockList))
+			r.Get("/", http.HandlerFunc(blockListHandler.HandleListBlockListEntries()))
+			r.Get("/{id}", http.HandlerFunc(blockListHandler.HandleGetBlockListEntry()))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequirePermission(models.PermissionManageBlockList))
+			r.Post("/", http.HandlerFunc(blockListHandler.HandleCreateBlockListEntry()))
+			r.Put("/{id}", http.HandlerFunc(blockListHandler.HandleUpdateBlockListEntry()))
+			r.Delete("/{id}", http.HandlerFunc(blockListHandler.HandleDeleteBlockListEntry()))
+		})
+	})
+	r.Route("/audit", func(r chi.Router) {
+		r.Use(rateLimit, authenticateOperator, auth.RequirePermission(models.PermissionReadAuditLog))
+		r.Get("/", http.HandlerFunc(auditHandler.HandleListAuditEntries()))
+		r.Get("/verify", http.HandlerFunc(auditHandler.HandleVerifyAuditLog()))
+	})
+	r.Route("/customer-data", func(r chi.Router) {
+		r.Use(rateLimit, authenticateOperator)
+		r.With(auth.RequirePermission(models.PermissionExportCustomerData)).Post("/export", http.HandlerFunc(customerDataHandler.HandleExportCustomerData()))
+		r.With(auth.RequirePermission(models.PermissionEraseCustomerData)).Post("/erase", http.HandlerFunc(customerDataHandler.HandleEraseCustomerData()))
+	})
+	r.With(ipRateLimit, authenticate, rateLimit, auth.RequireSecretKey).Get("/usage", http.HandlerFunc(usageHandler.HandleListUsage()))
+	r.With(ipRateLimit, authenticate, rateLimit, auth.RequireSecretKey).Post("/keys/{id}/rotate", http.HandlerFunc(merchantHandler.HandleRotateAPIKey()))
+	r.With(rateLimit, authenticateOperator, auth.RequirePermission(models.PermissionManageMerchants)).Post("/merchants", http.HandlerFunc(merchantHandler.HandleCreateMerchant()))
+
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.API.Port),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-	fmt.Printf("Listening on port %s \n", port)
+	httpServer.RegisterOnShutdown(healthChecker.Drain)
 
-	log.Fatalln(http.ListenAndServe(fmt.Sprintf(":%s", port), r))
+	return server.Run(ctx, httpServer, cfg.ShutdownTimeout)
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/migrations"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
+)
+
+const usage = `Usage: migrate <command>
+
+Commands:
+  status        list the migrations and whether they were applied
+  up            apply every pending migration
+  down [steps]  revert the latest applied migrations, 1 by default
+  to <version>  apply or revert migrations until version is the latest applied, 0 reverts all of them
+`
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	cfg, err := config.Load(config.ServiceMigrate)
+	if err != nil {
+		slog.Error("load configuration failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	slog.SetDefault(logging.New(os.Stdout, logging.ParseLevel(cfg.LogLevel)))
+
+	err = run(cfg, os.Args[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		slog.Error("migrate failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := postgres.Connect(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+
+	defer pool.Close()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+
+	defer conn.Release()
+
+	migrator, err := migrations.New(conn)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		return printStatus(ctx, migrator)
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("%w: steps must be a positive integer", errUsage)
+			}
+		}
+
+		return migrator.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			return errUsage
+		}
+
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("%w: version must be a non-negative integer", errUsage)
+		}
+
+		return migrator.To(ctx, version)
+	default:
+		return errUsage
+	}
+}
+
+func printStatus(ctx context.Context, migrator migrations.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+
+	return w.Flush()
+}
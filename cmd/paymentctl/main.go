@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/archive"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/encryption"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/export"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/limits"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+)
+
+const usage = `Usage: paymentctl [-output table|json] [-token operator-token] <command> [flags] [args]
+
+Commands:
+  token -role r [-ttl duration] <operator>      issue a signed token for an operator with the role viewer,
+                                                support, finance or admin, valid for 12h by default
+  get <transaction-id>                          show a transaction
+  list [filters]                                list transactions, most recent first
+  search [filters] <query>                      find transactions by ID, description or provider ID
+  refund [-reason text] <transaction-id>        refund a transaction of any merchant, held for the approval of
+                                                another operator above the refund approval threshold
+  replay [-reason text] <event-id>              process a stored webhook event again
+  reconcile [filters]                           compare transactions with their payment provider
+  export [filters] [-format csv|ndjson] [-file path]
+                                                write transactions, to stdout by default
+  force-status -status s -reason text <id>      override the status of a transaction
+  reencrypt                                     encrypt the encrypted additional fields of every transaction
+                                                with the active key, after rotating the encryption keys
+  customer-export [-file path] <customer>       write the data held about a customer as a JSON bundle, to
+                                                stdout by default
+  customer-erase -reason text <customer>        erase the personal data of a customer, keeping amounts, IDs and
+                                                provider references
+  archive                                       archive the transactions past their retention and drop the
+                                                payloads of old webhook events
+
+Filters:
+  -merchant id  -status s  -type t  -provider p  -currency c
+  -from date  -to date  (YYYY-MM-DD or RFC 3339, -to is exclusive)
+  -limit n
+
+Commands other than token require the operator token given by -token, which defaults to OPERATOR_TOKEN, and are
+limited by the role of the operator. Operations changing a transaction are recorded in the audit trail under the
+operator named by the token.
+`
+
+// defaultTokenTTL validity of the operator tokens issued without -ttl, kept short as a token works until it
+// expires or its key is rotated out
+const defaultTokenTTL = 12 * time.Hour
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	cfg, err := config.Load(config.ServicePaymentctl)
+	if err != nil {
+		slog.Error("load configuration failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// stdout is kept for the command output
+	slog.SetDefault(logging.New(os.Stderr, logging.ParseLevel(cfg.LogLevel)))
+
+	err = run(cfg, os.Args[1:], os.Stdout)
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "%s\n\n%s", err, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		slog.Error("paymentctl failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, args []string, stdout io.Writer) error {
+	global := flag.NewFlagSet("paymentctl", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+
+	output := global.String("output", "table", "output format, table or json")
+	token := global.String("token", os.Getenv("OPERATOR_TOKEN"), "signed token of the operator running the command")
+
+	err := global.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errUsage, err)
+	}
+
+	printer, err := newPrinter(*output, stdout)
+	if err != nil {
+		return err
+	}
+
+	if global.NArg() == 0 {
+		return errUsage
+	}
+
+	command, commandArgs := global.Arg(0), global.Args()[1:]
+
+	if command == "token" {
+		return issueToken(cfg, commandArgs, stdout)
+	}
+
+	operator, err := auth.VerifyOperatorToken(cfg.Operators.TokenSecrets(), *token, time.Now())
+	if err != nil {
+		return fmt.Errorf("authenticate operator failed: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pgxPool, err := postgres.Connect(ctx, cfg.Database)
+	if err != nil {
+		return fmt.Errorf("initialize database failed: %w", err)
+	}
+
+	defer pgxPool.Close()
+
+	stripeService, err := stripe.New(cfg.Stripe)
+	if err != nil {
+		return fmt.Errorf("initialize stripe payment processor failed: %w", err)
+	}
+
+	encryptor, err := encryption.New(cfg.Encryption)
+	if err != nil {
+		return fmt.Errorf("initialize encryption failed: %w", err)
+	}
+
+	database := postgres.New(pgxPool, encryptor)
+
+	var archiveFiles *archive.Files
+
+	if cfg.Retention.ArchiveMode == models.ArchiveModeNDJSON {
+		archiveFiles = archive.NewFiles(cfg.Retention.ArchiveDir, encryptor)
+		database = archive.NewDatabase(database, archiveFiles)
+	}
+
+	operatorService := service.NewOperatorService(database, stripeService, limits.New(cfg.Limits))
+	customerDataService := service.NewCustomerDataService(database)
+	retentionService := service.NewRetentionService(database, cfg.Retention, archiveFiles)
+
+	return runCommand(auth.WithOperator(ctx, operator), operatorService, customerDataService, retentionService, printer, operator.Name, command, commandArgs)
+}
+
+// issueToken signs a token for an operator, which only whoever holds the operator token secret can do
+func issueToken(cfg *config.Config, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	role := fs.String("role", "", "role of the operator, viewer, support, finance or admin")
+	ttl := fs.Duration("ttl", defaultTokenTTL, "how long the token is valid")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errUsage, err)
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: token expects 1 argument(s)", errUsage)
+	}
+
+	if !models.OperatorRole(*role).IsValid() {
+		return fmt.Errorf("%w: unknown role %q", errUsage, *role)
+	}
+
+	token, err := auth.IssueOperatorToken(cfg.Operators.TokenSecret, cfg.Operators.TokenKeyID, fs.Arg(0), models.OperatorRole(*role), *ttl)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errUsage, err)
+	}
+
+	_, err = fmt.Fprintln(stdout, token)
+
+	return err
+}
+
+func runCommand(ctx context.Context, operatorService service.OperatorService, customerDataService service.CustomerDataService, retentionService service.RetentionService, printer printer, actor, command string, args []string) error {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	parse := func(positional int) error {
+		err := fs.Parse(args)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errUsage, err)
+		}
+
+		if fs.NArg() != positional {
+			return fmt.Errorf("%w: %s expects %d argument(s)", errUsage, command, positional)
+		}
+
+		return nil
+	}
+
+	switch command {
+	case "get":
+		err := parse(1)
+		if err != nil {
+			return err
+		}
+
+		transaction, err := operatorService.GetTransaction(ctx, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		return printer.transactions([]*models.Transaction{transaction})
+	case "list", "search":
+		positional := 0
+		if command == "search" {
+			positional = 1
+		}
+
+		filter := filterFlags(fs)
+
+		err := parse(positional)
+		if err != nil {
+			return err
+		}
+
+		transactionFilter, err := filter()
+		if err != nil {
+			return err
+		}
+
+		transactionFilter.Search = fs.Arg(0)
+
+		transactions, err := operatorService.ListTransactions(ctx, transactionFilter)
+		if err != nil {
+			return err
+		}
+
+		return printer.transactions(transactions)
+	case "refund":
+		reason := fs.String("reason", "", "why the transaction is refunded")
+
+		err := parse(1)
+		if err != nil {
+			return err
+		}
+
+		transaction, err := operatorService.RefundTransaction(ctx, actor, fs.Arg(0), *reason)
+		if err != nil {
+			return err
+		}
+
+		return printer.transactions([]*models.Transaction{transaction})
+	case "replay":
+		reason := fs.String("reason", "", "why the event is processed again")
+
+		err := parse(1)
+		if err != nil {
+			return err
+		}
+
+		event, err := operatorService.ReplayWebhookEvent(ctx, actor, fs.Arg(0), *reason)
+		if err != nil {
+			return err
+		}
+
+		return printer.webhookEvent(event)
+	case "reconcile":
+		filter := filterFlags(fs)
+
+		err := parse(0)
+		if err != nil {
+			return err
+		}
+
+		transactionFilter, err := filter()
+		if err != nil {
+			return err
+		}
+
+		discrepancies, err := operatorService.Reconcile(ctx, transactionFilter)
+		if err != nil {
+			return err
+		}
+
+		return printer.discrepancies(discrepancies)
+	case "export":
+		filter := filterFlags(fs)
+		formatName := fs.String("format", "csv", "export format, csv or ndjson")
+		path := fs.String("file", "", "file the export is written to instead of stdout")
+
+		err := parse(0)
+		if err != nil {
+			return err
+		}
+
+		transactionFilter, err := filter()
+		if err != nil {
+			return err
+		}
+
+		format, err := export.ParseFormat(*formatName)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errUsage, err)
+		}
+
+		return exportTransactions(ctx, operatorService, transactionFilter, format, *path, printer.w)
+	case "force-status":
+		status := fs.String("status", "", "status the transaction is set to")
+		reason := fs.String("reason", "", "why the status is overridden, kept in the audit trail")
+
+		err := parse(1)
+		if err != nil {
+			return err
+		}
+
+		if *reason == "" {
+			return fmt.Errorf("%w: -reason is required", errUsage)
+		}
+
+		transaction, err := operatorService.ForceTransactionStatus(ctx, actor, fs.Arg(0), models.TransactionStatus(*status), *reason)
+		if err != nil {
+			return err
+		}
+
+		return printer.transactions([]*models.Transaction{transaction})
+	case "reencrypt":
+		err := parse(0)
+		if err != nil {
+			return err
+		}
+
+		reencrypted, err := operatorService.ReencryptTransactions(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printer.reencrypted(reencrypted)
+	case "customer-export":
+		path := fs.String("file", "", "file the bundle is written to instead of stdout")
+
+		err := parse(1)
+		if err != nil {
+			return err
+		}
+
+		return exportCustomerData(ctx, customerDataService, fs.Arg(0), *path, printer.w)
+	case "customer-erase":
+		reason := fs.String("reason", "", "why the data is erased, kept in the audit trail")
+
+		err := parse(1)
+		if err != nil {
+			return err
+		}
+
+		if *reason == "" {
+			return fmt.Errorf("%w: -reason is required", errUsage)
+		}
+
+		erasure, err := customerDataService.EraseCustomerData(ctx, actor, fs.Arg(0), *reason)
+		if err != nil {
+			return err
+		}
+
+		return printer.customerErasure(erasure)
+	case "archive":
+		err := parse(0)
+		if err != nil {
+			return err
+		}
+
+		run, err := retentionService.ApplyRetention(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printer.retentionRun(run)
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, command)
+	}
+}
+
+// filterFlags registers the transaction filter flags, returning a function that builds the filter once parsed
+func filterFlags(fs *flag.FlagSet) func() (*models.TransactionFilter, error) {
+	merchantID := fs.String("merchant", "", "merchant ID")
+	status := fs.String("status", "", "transaction status")
+	transactionType := fs.String("type", "", "transaction type")
+	provider := fs.String("provider", "", "payment provider")
+	currency := fs.String("currency", "", "currency code")
+	from := fs.String("from", "", "created at or after this date")
+	to := fs.String("to", "", "created before this date")
+	limit := fs.Int("limit", 0, "maximum number of transactions, 0 for no limit")
+
+	return func() (*models.TransactionFilter, error) {
+		filter := &models.TransactionFilter{
+			MerchantID: *merchantID,
+			Status:     models.TransactionStatus(*status),
+			Type:       models.TransactionType(*transactionType),
+			Provider:   models.PaymentProvider(*provider),
+			Currency:   *currency,
+			Limit:      *limit,
+		}
+
+		if filter.Status != "" && !filter.Status.IsValid() {
+			return nil, fmt.Errorf("%w: unknown status %q", errUsage, *status)
+		}
+
+		if filter.Type != "" && !filter.Type.IsValid() {
+			return nil, fmt.Errorf("%w: unknown type %q", errUsage, *transactionType)
+		}
+
+		if filter.Limit < 0 {
+			return nil, fmt.Errorf("%w: limit must not be negative", errUsage)
+		}
+
+		var err error
+
+		filter.CreatedFrom, err = parseTime("from", *from)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.CreatedTo, err = parseTime("to", *to)
+		if err != nil {
+			return nil, err
+		}
+
+		return filter, nil
+	}
+}
+
+func parseTime(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: -%s must be a date (YYYY-MM-DD) or an RFC 3339 time, got %q", errUsage, name, value)
+}
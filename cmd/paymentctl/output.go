@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/export"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/service"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var transactionColumns = []string{"TRANSACTION_ID", "MERCHANT_ID", "STATUS", "TYPE", "AMOUNT", "CURRENCY", "PROVIDER", "FAILURE_REASON", "VERSION", "CREATED_AT", "UPDATED_AT"}
+
+// printer writes the command results either as an aligned table or as indented JSON
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	if format != outputTable && format != outputJSON {
+		return printer{}, fmt.Errorf("%w: output must be table or json, got %q", errUsage, format)
+	}
+
+	return printer{format: format, w: w}, nil
+}
+
+func (p printer) transactions(transactions []*models.Transaction) error {
+	if p.format == outputJSON {
+		return p.json(transactions)
+	}
+
+	return p.table(transactionColumns, len(transactions), func(i int) []string {
+		return transactionRow(transactions[i])
+	})
+}
+
+func (p printer) webhookEvent(event *models.WebhookEvent) error {
+	if p.format == outputJSON {
+		return p.json(event)
+	}
+
+	processedAt := ""
+	if event.ProcessedAt != nil {
+		processedAt = event.ProcessedAt.Format(time.RFC3339)
+	}
+
+	return p.table([]string{"EVENT_ID", "PROVIDER", "TYPE", "ATTEMPTS", "RECEIVED_AT", "PROCESSED_AT", "LAST_ERROR"}, 1, func(int) []string {
+		return []string{
+			event.EventID,
+			string(event.Provider),
+			event.Type,
+			strconv.Itoa(event.Attempts),
+			event.ReceivedAt.Format(time.RFC3339),
+			processedAt,
+			event.LastError,
+		}
+	})
+}
+
+func (p printer) discrepancies(discrepancies []*models.Discrepancy) error {
+	if p.format == outputJSON {
+		return p.json(discrepancies)
+	}
+
+	return p.table([]string{"TRANSACTION_ID", "STATUS", "PROVIDER_STATUS", "TYPE", "PROVIDER_TYPE", "ERROR"}, len(discrepancies), func(i int) []string {
+		discrepancy := discrepancies[i]
+
+		return []string{
+			discrepancy.TransactionID,
+			string(discrepancy.Status),
+			string(discrepancy.ProviderStatus),
+			string(discrepancy.Type),
+			string(discrepancy.ProviderType),
+			discrepancy.Error,
+		}
+	})
+}
+
+func (p printer) reencrypted(transactions int) error {
+	if p.format == outputJSON {
+		return p.json(map[string]int{"reencrypted_transactions": transactions})
+	}
+
+	return p.table([]string{"REENCRYPTED_TRANSACTIONS"}, 1, func(int) []string {
+		return []string{strconv.Itoa(transactions)}
+	})
+}
+
+func (p printer) customerErasure(erasure *models.CustomerErasure) error {
+	if p.format == outputJSON {
+		return p.json(erasure)
+	}
+
+	return p.table([]string{"ERASURE_ID", "TRANSACTIONS", "RISK_DECISIONS", "WEBHOOK_EVENTS", "BLOCK_LIST_ENTRIES", "ERASED_AT"}, 1, func(int) []string {
+		return []string{
+			erasure.ErasureID,
+			strconv.Itoa(erasure.Transactions),
+			strconv.Itoa(erasure.RiskDecisions),
+			strconv.Itoa(erasure.WebhookEvents),
+			strconv.Itoa(erasure.BlockListEntries),
+			erasure.ErasedAt.Format(time.RFC3339),
+		}
+	})
+}
+
+func (p printer) retentionRun(run *models.RetentionRun) error {
+	if p.format == outputJSON {
+		return p.json(run)
+	}
+
+	return p.table([]string{"ARCHIVE_MODE", "ARCHIVED_BEFORE", "ARCHIVED_TRANSACTIONS", "ARCHIVE_FILES", "PRUNED_BEFORE", "PRUNED_WEBHOOK_PAYLOADS"}, 1, func(int) []string {
+		return []string{
+			string(run.ArchiveMode),
+			formatOptionalTime(run.ArchivedBefore),
+			strconv.Itoa(run.ArchivedTransactions),
+			strings.Join(run.ArchiveFiles, ","),
+			formatOptionalTime(run.PrunedBefore),
+			strconv.Itoa(run.PrunedWebhookPayloads),
+		}
+	})
+}
+
+func (p printer) json(v interface{}) error {
+	encoder := json.NewEncoder(p.w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+func (p printer) table(columns []string, rows int, row func(i int) []string) error {
+	w := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+
+	writeTableRow(w, columns)
+
+	for i := 0; i < rows; i++ {
+		writeTableRow(w, row(i))
+	}
+
+	return w.Flush()
+}
+
+func writeTableRow(w io.Writer, values []string) {
+	for i, value := range values {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+
+		fmt.Fprint(w, value)
+	}
+
+	fmt.Fprintln(w)
+}
+
+// formatOptionalTime formats the time, or a dash when it is unset
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// exportTransactions writes the export to the file at path, or to stdout when path is empty
+func exportTransactions(ctx context.Context, operatorService service.OperatorService, filter *models.TransactionFilter, format export.Format, path string, stdout io.Writer) error {
+	if path == "" {
+		return operatorService.ExportTransactions(ctx, filter, format, stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create export file: %w", err)
+	}
+
+	err = operatorService.ExportTransactions(ctx, filter, format, file)
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return file.Close()
+}
+
+// exportCustomerData writes the data held about the customer as an indented JSON bundle, whatever the output format
+func exportCustomerData(ctx context.Context, customerDataService service.CustomerDataService, customer, path string, stdout io.Writer) error {
+	data, err := customerDataService.ExportCustomerData(ctx, customer)
+	if err != nil {
+		return err
+	}
+
+	bundle := printer{format: outputJSON, w: stdout}
+
+	if path == "" {
+		return bundle.json(data)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create export file: %w", err)
+	}
+
+	bundle.w = file
+
+	err = bundle.json(data)
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return file.Close()
+}
+
+func transactionRow(transaction *models.Transaction) []string {
+	return []string{
+		transaction.TransactionID,
+		transaction.MerchantID,
+		string(transaction.Status),
+		string(transaction.Type),
+		strconv.Itoa(transaction.Amount),
+		transaction.Currency,
+		string(transaction.Provider),
+		transaction.FailureReason,
+		strconv.Itoa(transaction.Version),
+		transaction.CreatedAt.Format(time.RFC3339),
+		transaction.UpdatedAt.Format(time.RFC3339),
+	}
+}
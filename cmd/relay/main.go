@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/migrations"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/health"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/metrics"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/outbox"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/server"
+	"github.com/go-chi/chi/v5"
+)
+
+func main() {
+	cfg, err := config.Load(config.ServiceRelay)
+	if err != nil {
+		slog.Error("load configuration failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	logger := logging.New(os.Stdout, logging.ParseLevel(cfg.LogLevel))
+	slog.SetDefault(logger)
+
+	err = run(cfg, logger)
+	if err != nil {
+		slog.Error("relay stopped", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pgxPool, err := postgres.Connect(ctx, cfg.Database)
+	if err != nil {
+		return fmt.Errorf("initialize database failed: %w", err)
+	}
+
+	defer pgxPool.Close()
+
+	if cfg.Database.AutoMigrate {
+		err = migrations.Up(ctx, pgxPool)
+		if err != nil {
+			return fmt.Errorf("migrate database failed: %w", err)
+		}
+	}
+
+	sink, err := outbox.NewSink(cfg.Relay)
+	if err != nil {
+		return fmt.Errorf("initialize outbox sink failed: %w", err)
+	}
+
+	defer sink.Close()
+
+	relay := outbox.NewRelay(pgxPool, sink, cfg.Relay)
+
+	healthChecker := health.NewChecker(2 * time.Second)
+	healthChecker.AddCheck("database", pgxPool.Ping)
+
+	r := chi.NewRouter()
+
+	r.Use(logging.Middleware(logger))
+	r.Get("/healthz", healthChecker.HandleLiveness())
+	r.Get("/readyz", healthChecker.HandleReadiness())
+	r.Handle("/metrics", metrics.Handler())
+
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Relay.Port),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	httpServer.RegisterOnShutdown(healthChecker.Drain)
+
+	relayErr := make(chan error, 1)
+
+	go func() {
+		relayErr <- relay.Run(ctx)
+	}()
+
+	slog.InfoContext(ctx, "relaying outbox events", slog.String("sink", cfg.Relay.Sink))
+
+	err = server.Run(ctx, httpServer, cfg.ShutdownTimeout)
+
+	// stop relaying when the server failed to start
+	stop()
+
+	return errors.Join(err, <-relayErr)
+}
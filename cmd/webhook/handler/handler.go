@@ -1,61 +1,95 @@
 package handler
 
 import (
-	"context"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/events"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/metrics"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
 	"github.com/go-chi/chi/v5"
 )
 
 var newEventHandlerFunc = events.NewEvent
 
+const (
+	outcomeUnsupportedProvider = "unsupported_provider"
+	outcomeVerificationFailed  = "verification_failed"
+)
+
 // Handler interface to handle incoming events from payment processor providers
 type Handler interface {
-	HandlePaymentEvents(ctx context.Context) http.HandlerFunc
+	HandlePaymentEvents() http.HandlerFunc
 }
 
 type handler struct {
-	database database.Database
+	stripeConfig     config.Stripe
+	database         database.Database
+	paymentProcessor paymentprocessor.PaymentProcessor
 }
 
 // NewHandler constructor to handle incoming requests to API
-func NewHandler(database database.Database) Handler {
+func NewHandler(stripeConfig config.Stripe, database database.Database, paymentProcessor paymentprocessor.PaymentProcessor) Handler {
 	return handler{
-		database: database,
+		stripeConfig:     stripeConfig,
+		database:         database,
+		paymentProcessor: paymentProcessor,
 	}
 }
 
 // HandlePaymentsEvents validates and processes events from payment processor providers
-func (h handler) HandlePaymentEvents(ctx context.Context) http.HandlerFunc {
+func (h handler) HandlePaymentEvents() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		provider := chi.URLParam(r, "provider")
 
-		eventHandler, err := newEventHandlerFunc(models.PaymentProvider(provider), h.database, r)
+		eventHandler, err := newEventHandlerFunc(models.PaymentProvider(provider), h.stripeConfig, h.database, h.paymentProcessor, r)
 		if err != nil {
-			log.Println(err)
+			slog.WarnContext(ctx, "initialize event handler failed", slog.String("provider", provider), slog.Any("error", err))
+			metrics.ObserveWebhookEvent("unknown", "", outcomeUnsupportedProvider)
 			api.WriteErrorResponse(w, err)
 			return
 		}
 
 		err = eventHandler.VerifyEvent()
 		if err != nil {
-			log.Println(err)
+			slog.WarnContext(ctx, "verify payment event failed", slog.String("provider", provider), slog.Any("error", err))
+			metrics.ObserveWebhookEvent(provider, "", outcomeVerificationFailed)
+			api.WriteErrorResponse(w, err)
+			return
+		}
+
+		webhookEvent := eventHandler.WebhookEvent()
+
+		// store the event before processing it, so the provider retries when it couldn't be kept for replays
+		err = h.database.InsertWebhookEvent(ctx, webhookEvent)
+		if err != nil {
+			slog.ErrorContext(ctx, "store payment event failed", slog.String("provider", provider), slog.Any("error", err))
+			metrics.ObserveWebhookEvent(provider, eventHandler.Type(), metrics.OutcomeError)
 			api.WriteErrorResponse(w, err)
 			return
 		}
 
 		err = eventHandler.ProcessEvent(ctx)
+
+		outcomeErr := h.database.RecordWebhookEventOutcome(ctx, webhookEvent.EventID, err)
+		if outcomeErr != nil {
+			slog.WarnContext(ctx, "record payment event outcome failed", slog.String("provider", provider), slog.Any("error", outcomeErr))
+		}
+
 		if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "process payment event failed", slog.String("provider", provider), slog.Any("error", err))
+			metrics.ObserveWebhookEvent(provider, eventHandler.Type(), metrics.OutcomeError)
 			api.WriteErrorResponse(w, err)
 			return
 		}
 
+		metrics.ObserveWebhookEvent(provider, eventHandler.Type(), metrics.OutcomeSuccess)
+
 		w.WriteHeader(http.StatusOK)
 	}
 }
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -10,11 +9,15 @@ import (
 	"testing"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/events"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
 	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,11 +26,15 @@ func TestHandlePaymentEvents(t *testing.T) {
 
 	mockEvents := events.MockStripe{}
 
+	webhookEvent := &models.WebhookEvent{EventID: "evt_123", Provider: models.PaymentProviderStripe, Type: "payment_intent.succeeded"}
+
+	mockEvents.On("Type").Return("payment_intent.succeeded")
 	mockEvents.On("VerifyEvent").Return(nil)
+	mockEvents.On("WebhookEvent").Return(webhookEvent)
 	mockEvents.On("ProcessEvent").Return(nil)
 
 	copyNewEventHandlerFunc := newEventHandlerFunc
-	newEventHandlerFunc = func(provider models.PaymentProvider, database database.Database, request *http.Request) (events.Events, error) {
+	newEventHandlerFunc = func(provider models.PaymentProvider, cfg config.Stripe, database database.Database, paymentProcessor paymentprocessor.PaymentProcessor, request *http.Request) (events.Events, error) {
 		return &mockEvents, nil
 	}
 
@@ -36,11 +43,13 @@ func TestHandlePaymentEvents(t *testing.T) {
 	})
 
 	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("InsertWebhookEvent", mock.Anything, webhookEvent).Return(nil)
+	mockDatabase.On("RecordWebhookEventOutcome", mock.Anything, "evt_123", nil).Return(nil)
 
-	handler := NewHandler(&mockDatabase)
+	handler := NewHandler(config.Stripe{}, &mockDatabase, &stripe.MockStripe{})
 
 	router := chi.NewRouter()
-	router.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents(context.Background())))
+	router.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents()))
 
 	body := bytes.NewReader([]byte(`{"hello": "world"}`))
 
@@ -54,6 +63,7 @@ func TestHandlePaymentEvents(t *testing.T) {
 	defer response.Body.Close()
 
 	c.Equal(http.StatusOK, response.StatusCode)
+	mockDatabase.AssertExpectations(t)
 }
 
 func TestHandlerPaymentEventsUnsupportedProvider(t *testing.T) {
@@ -61,10 +71,10 @@ func TestHandlerPaymentEventsUnsupportedProvider(t *testing.T) {
 
 	mockDatabase := postgres.MockPostgres{}
 
-	handler := NewHandler(&mockDatabase)
+	handler := NewHandler(config.Stripe{}, &mockDatabase, &stripe.MockStripe{})
 
 	router := chi.NewRouter()
-	router.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents(context.Background())))
+	router.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents()))
 
 	req := httptest.NewRequest(http.MethodPost, "/payments/invalid/events", nil)
 
@@ -93,7 +103,7 @@ func TestHandlerPaymentEventsVerificationFailed(t *testing.T) {
 	mockEvents.On("VerifyEvent").Return(api.NewInvalidRequestError(events.ErrEventVerificationFailed))
 
 	copyNewEventHandlerFunc := newEventHandlerFunc
-	newEventHandlerFunc = func(provider models.PaymentProvider, database database.Database, request *http.Request) (events.Events, error) {
+	newEventHandlerFunc = func(provider models.PaymentProvider, cfg config.Stripe, database database.Database, paymentProcessor paymentprocessor.PaymentProcessor, request *http.Request) (events.Events, error) {
 		return &mockEvents, nil
 	}
 
@@ -103,10 +113,10 @@ func TestHandlerPaymentEventsVerificationFailed(t *testing.T) {
 
 	mockDatabase := postgres.MockPostgres{}
 
-	handler := NewHandler(&mockDatabase)
+	handler := NewHandler(config.Stripe{}, &mockDatabase, &stripe.MockStripe{})
 
 	router := chi.NewRouter()
-	router.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents(context.Background())))
+	router.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents()))
 
 	body := bytes.NewReader([]byte(`{"hello": "world"}`))
 
@@ -136,11 +146,16 @@ func TestHandlerPaymentEventsProcessEventFailure(t *testing.T) {
 
 	unknownErr := errors.New("unknown error")
 
+	webhookEvent := &models.WebhookEvent{EventID: "evt_123", Provider: models.PaymentProviderStripe, Type: "payment_intent.succeeded"}
+	processErr := api.NewInternalServerError(unknownErr)
+
+	mockEvents.On("Type").Return("payment_intent.succeeded")
 	mockEvents.On("VerifyEvent").Return(nil)
-	mockEvents.On("ProcessEvent").Return(api.NewInternalServerError(unknownErr))
+	mockEvents.On("WebhookEvent").Return(webhookEvent)
+	mockEvents.On("ProcessEvent").Return(processErr)
 
 	copyNewEventHandlerFunc := newEventHandlerFunc
-	newEventHandlerFunc = func(provider models.PaymentProvider, database database.Database, request *http.Request) (events.Events, error) {
+	newEventHandlerFunc = func(provider models.PaymentProvider, cfg config.Stripe, database database.Database, paymentProcessor paymentprocessor.PaymentProcessor, request *http.Request) (events.Events, error) {
 		return &mockEvents, nil
 	}
 
@@ -149,11 +164,13 @@ func TestHandlerPaymentEventsProcessEventFailure(t *testing.T) {
 	})
 
 	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("InsertWebhookEvent", mock.Anything, webhookEvent).Return(nil)
+	mockDatabase.On("RecordWebhookEventOutcome", mock.Anything, "evt_123", processErr).Return(nil)
 
-	handler := NewHandler(&mockDatabase)
+	handler := NewHandler(config.Stripe{}, &mockDatabase, &stripe.MockStripe{})
 
 	router := chi.NewRouter()
-	router.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents(context.Background())))
+	router.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents()))
 
 	body := bytes.NewReader([]byte(`{"hello": "world"}`))
 
@@ -175,3 +192,44 @@ func TestHandlerPaymentEventsProcessEventFailure(t *testing.T) {
 	c.Equal(api.ErrCodeInternalServerError, apiErr.Code())
 	c.Contains(apiErr.Error(), "Internal server error")
 }
+
+func TestHandlerPaymentEventsStoreFailure(t *testing.T) {
+	c := require.New(t)
+
+	mockEvents := events.MockStripe{}
+
+	webhookEvent := &models.WebhookEvent{EventID: "evt_123", Provider: models.PaymentProviderStripe, Type: "payment_intent.succeeded"}
+
+	mockEvents.On("Type").Return("payment_intent.succeeded")
+	mockEvents.On("VerifyEvent").Return(nil)
+	mockEvents.On("WebhookEvent").Return(webhookEvent)
+
+	copyNewEventHandlerFunc := newEventHandlerFunc
+	newEventHandlerFunc = func(provider models.PaymentProvider, cfg config.Stripe, database database.Database, paymentProcessor paymentprocessor.PaymentProcessor, request *http.Request) (events.Events, error) {
+		return &mockEvents, nil
+	}
+
+	t.Cleanup(func() {
+		newEventHandlerFunc = copyNewEventHandlerFunc
+	})
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("InsertWebhookEvent", mock.Anything, webhookEvent).Return(api.NewInternalServerError(errors.New("connection refused")))
+
+	handler := NewHandler(config.Stripe{}, &mockDatabase, &stripe.MockStripe{})
+
+	router := chi.NewRouter()
+	router.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents()))
+
+	req := httptest.NewRequest(http.MethodPost, "/payments/mock/events", bytes.NewReader([]byte(`{"hello": "world"}`)))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusInternalServerError, response.StatusCode)
+	mockEvents.AssertNotCalled(t, "ProcessEvent")
+}
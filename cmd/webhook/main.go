@@ -3,48 +3,129 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aledeltoro/simple-online-payment-platform/cmd/webhook/handler"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/archive"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/migrations"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/encryption"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/events"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/health"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/metrics"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/server"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/tracing"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	err := godotenv.Load()
+	cfg, err := config.Load(config.ServiceWebhooks)
 	if err != nil {
-		log.Fatalf("load .env file failed: %s", err.Error())
+		slog.Error("load configuration failed", slog.Any("error", err))
+		os.Exit(1)
 	}
 
-	port := os.Getenv("WEBHOOKS_PORT")
-	if port == "" {
-		port = "3001"
+	logger := logging.New(os.Stdout, logging.ParseLevel(cfg.LogLevel))
+	slog.SetDefault(logger)
+
+	api.SetDebugMode(cfg.DebugMode)
+
+	err = run(cfg, logger)
+	if err != nil {
+		slog.Error("webhooks stopped", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Init(ctx, "online-payment-platform-webhooks", cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("initialize tracing failed: %w", err)
 	}
 
-	ctx := context.Background()
+	defer shutdownTracing(context.WithoutCancel(ctx))
 
-	database, err := postgres.Init(ctx)
+	pgxPool, err := postgres.Connect(ctx, cfg.Database)
 	if err != nil {
-		log.Fatalf("initialize database failed: %s \n", err.Error())
+		return fmt.Errorf("initialize database failed: %w", err)
 	}
 
-	defer database.Close()
+	defer pgxPool.Close()
 
-	handler := handler.NewHandler(database)
+	if cfg.Database.AutoMigrate {
+		err = migrations.Up(ctx, pgxPool)
+		if err != nil {
+			return fmt.Errorf("migrate database failed: %w", err)
+		}
+	}
+
+	encryptor, err := encryption.New(cfg.Encryption)
+	if err != nil {
+		return fmt.Errorf("initialize encryption failed: %w", err)
+	}
+
+	pool := postgres.New(pgxPool, encryptor)
+
+	if statter, ok := pool.(metrics.PoolStatter); ok {
+		err = metrics.RegisterPoolStats(statter)
+		if err != nil {
+			return fmt.Errorf("register database pool metrics failed: %w", err)
+		}
+	}
+
+	database := tracing.NewDatabase(metrics.NewDatabase(pool))
+
+	if cfg.Retention.ArchiveMode == models.ArchiveModeNDJSON {
+		database = archive.NewDatabase(database, archive.NewFiles(cfg.Retention.ArchiveDir, encryptor))
+	}
+
+	stripeService, err := stripe.New(cfg.Stripe)
+	if err != nil {
+		return fmt.Errorf("initialize stripe payment processor failed: %w", err)
+	}
+
+	paymentprocessor := tracing.NewPaymentProcessor(metrics.NewPaymentProcessor(stripeService, models.PaymentProviderStripe), models.PaymentProviderStripe)
+
+	handler := handler.NewHandler(cfg.Stripe, database, paymentprocessor)
+
+	healthChecker := health.NewChecker(2 * time.Second)
+	healthChecker.AddCheck("database", pool.Ping)
+	healthChecker.AddCheck("stripe", func(ctx context.Context) error {
+		return events.CheckStripeConfiguration(cfg.Stripe)
+	})
 
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	r.Use(tracing.Middleware("online-payment-platform-webhooks"), logging.Middleware(logger), audit.Middleware)
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Hello World!"))
 	})
-	r.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents(ctx)))
+	r.Get("/healthz", healthChecker.HandleLiveness())
+	r.Get("/readyz", healthChecker.HandleReadiness())
+	r.Handle("/metrics", metrics.Handler())
+	r.Post("/payments/{provider}/events", http.HandlerFunc(handler.HandlePaymentEvents()))
+
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Webhooks.Port),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-	fmt.Printf("Listening on port %s \n", port)
+	httpServer.RegisterOnShutdown(healthChecker.Drain)
 
-	log.Fatalln(http.ListenAndServe(fmt.Sprintf(":%s", port), r))
+	return server.Run(ctx, httpServer, cfg.ShutdownTimeout)
 }
@@ -3,7 +3,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"os"
 )
 
 // ErrorCode error code for each type of error in the service
@@ -16,8 +15,30 @@ var (
 	ErrCodeInvalidRequestError ErrorCode = "invalid_request"
 	// ErrCodeResourceNotFound error code when resource was not found
 	ErrCodeResourceNotFound ErrorCode = "resource_not_found"
+	// ErrCodeUnauthorized error code when request could not be authenticated
+	ErrCodeUnauthorized ErrorCode = "unauthorized"
+	// ErrCodeForbidden error code when the authenticated client isn't allowed to perform the request
+	ErrCodeForbidden ErrorCode = "forbidden"
+	// ErrCodeRateLimited error code when client exceeded its request quota
+	ErrCodeRateLimited ErrorCode = "rate_limited"
+	// ErrCodeConflict error code when resource was modified concurrently
+	ErrCodeConflict ErrorCode = "conflict"
+	// ErrCodePreconditionFailed error code when resource doesn't match the version expected by the client
+	ErrCodePreconditionFailed ErrorCode = "precondition_failed"
+	// ErrCodePaymentBlocked error code when payment matched the block list
+	ErrCodePaymentBlocked ErrorCode = "payment_blocked"
+	// ErrCodeLimitExceeded error code when request would take the merchant over one of its limits
+	ErrCodeLimitExceeded ErrorCode = "limit_exceeded"
 )
 
+// debugMode exposes the cause of internal server errors in responses
+var debugMode bool
+
+// SetDebugMode sets whether the cause of internal server errors is included in responses
+func SetDebugMode(enabled bool) {
+	debugMode = enabled
+}
+
 // APIError interface to handle API errors in the service
 type APIError interface {
 	error
@@ -63,7 +84,7 @@ func NewInternalServerError(err error) APIErr {
 		err:        err,
 	}
 
-	if os.Getenv("DEBUG_MODE") == "true" {
+	if debugMode {
 		apiErr.Message = fmt.Sprintf("Internal server error: %s", err.Error())
 	}
 
@@ -89,3 +110,73 @@ func NewResourceNotFoundError(err error, resource string) APIErr {
 		err:        err,
 	}
 }
+
+// NewUnauthorizedError API error when request could not be authenticated
+func NewUnauthorizedError(err error) APIErr {
+	return APIErr{
+		ErrCode:    ErrCodeUnauthorized,
+		StatusCode: http.StatusUnauthorized,
+		Message:    fmt.Sprintf("Unauthorized: %s", err.Error()),
+		err:        err,
+	}
+}
+
+// NewForbiddenError API error when the authenticated client isn't allowed to perform the request
+func NewForbiddenError(err error) APIErr {
+	return APIErr{
+		ErrCode:    ErrCodeForbidden,
+		StatusCode: http.StatusForbidden,
+		Message:    fmt.Sprintf("Forbidden: %s", err.Error()),
+		err:        err,
+	}
+}
+
+// NewRateLimitedError API error when client exceeded its request quota
+func NewRateLimitedError(err error) APIErr {
+	return APIErr{
+		ErrCode:    ErrCodeRateLimited,
+		StatusCode: http.StatusTooManyRequests,
+		Message:    fmt.Sprintf("Too many requests: %s", err.Error()),
+		err:        err,
+	}
+}
+
+// NewConflictError API error when resource was modified concurrently
+func NewConflictError(err error) APIErr {
+	return APIErr{
+		ErrCode:    ErrCodeConflict,
+		StatusCode: http.StatusConflict,
+		Message:    fmt.Sprintf("Conflict: %s", err.Error()),
+		err:        err,
+	}
+}
+
+// NewPreconditionFailedError API error when resource doesn't match the version expected by the client
+func NewPreconditionFailedError(err error) APIErr {
+	return APIErr{
+		ErrCode:    ErrCodePreconditionFailed,
+		StatusCode: http.StatusPreconditionFailed,
+		Message:    fmt.Sprintf("Precondition failed: %s", err.Error()),
+		err:        err,
+	}
+}
+
+// NewPaymentBlockedError API error when payment matched the block list and was rejected without charging it
+func NewPaymentBlockedError(err error) APIErr {
+	return APIErr{
+		ErrCode:    ErrCodePaymentBlocked,
+		StatusCode: http.StatusPaymentRequired,
+		Message:    fmt.Sprintf("Payment blocked: %s", err.Error()),
+		err:        err,
+	}
+}
+
+// NewLimitExceededError API error when request would take the merchant over one of its limits
+func NewLimitExceededError(err error) APIErr {
+	return APIErr{
+		ErrCode:    ErrCodeLimitExceeded,
+		StatusCode: http.StatusUnprocessableEntity,
+		Message:    fmt.Sprintf("Limit exceeded: %s", err.Error()),
+		err:        err,
+	}
+}
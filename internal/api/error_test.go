@@ -52,6 +52,76 @@ func TestAPIErr(t *testing.T) {
 			resource:   "transaction",
 			err:        customErr,
 		},
+		{
+			runFunc: func(err error, resource string) error {
+				return NewUnauthorizedError(err)
+			},
+			errCode:    ErrCodeUnauthorized,
+			statusCode: http.StatusUnauthorized,
+			ErrMessage: fmt.Sprintf("(401) Unauthorized: %s", customErr.Error()),
+			resource:   "",
+			err:        customErr,
+		},
+		{
+			runFunc: func(err error, resource string) error {
+				return NewForbiddenError(err)
+			},
+			errCode:    ErrCodeForbidden,
+			statusCode: http.StatusForbidden,
+			ErrMessage: fmt.Sprintf("(403) Forbidden: %s", customErr.Error()),
+			resource:   "",
+			err:        customErr,
+		},
+		{
+			runFunc: func(err error, resource string) error {
+				return NewRateLimitedError(err)
+			},
+			errCode:    ErrCodeRateLimited,
+			statusCode: http.StatusTooManyRequests,
+			ErrMessage: fmt.Sprintf("(429) Too many requests: %s", customErr.Error()),
+			resource:   "",
+			err:        customErr,
+		},
+		{
+			runFunc: func(err error, resource string) error {
+				return NewConflictError(err)
+			},
+			errCode:    ErrCodeConflict,
+			statusCode: http.StatusConflict,
+			ErrMessage: fmt.Sprintf("(409) Conflict: %s", customErr.Error()),
+			resource:   "",
+			err:        customErr,
+		},
+		{
+			runFunc: func(err error, resource string) error {
+				return NewPreconditionFailedError(err)
+			},
+			errCode:    ErrCodePreconditionFailed,
+			statusCode: http.StatusPreconditionFailed,
+			ErrMessage: fmt.Sprintf("(412) Precondition failed: %s", customErr.Error()),
+			resource:   "",
+			err:        customErr,
+		},
+		{
+			runFunc: func(err error, resource string) error {
+				return NewPaymentBlockedError(err)
+			},
+			errCode:    ErrCodePaymentBlocked,
+			statusCode: http.StatusPaymentRequired,
+			ErrMessage: fmt.Sprintf("(402) Payment blocked: %s", customErr.Error()),
+			resource:   "",
+			err:        customErr,
+		},
+		{
+			runFunc: func(err error, resource string) error {
+				return NewLimitExceededError(err)
+			},
+			errCode:    ErrCodeLimitExceeded,
+			statusCode: http.StatusUnprocessableEntity,
+			ErrMessage: fmt.Sprintf("(422) Limit exceeded: %s", customErr.Error()),
+			resource:   "",
+			err:        customErr,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -0,0 +1,276 @@
+// Package archive exports the transactions past their retention to gzip compressed NDJSON files, one per month of
+// creation, and reads them back when an archived transaction is looked up.
+//
+// Transactions are written as they are stored, so their encrypted additional fields stay encrypted in the files and
+// the keys they were encrypted with must be kept for as long as the files. Every run appends a new gzip member to the
+// file of the month. Files are only rewritten to erase the personal data of a customer, or to discard the copies a
+// run appended of transactions updated before they could be removed from the database, always holding the lock of the
+// file in the database so appends and rewrites never overlap.
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/encryption"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// Files archive files of a directory
+type Files struct {
+	dir       string
+	encryptor *encryption.FieldEncryptor
+}
+
+// NewFiles builds the archive files of the directory, decrypting the transactions read back with the encryptor
+func NewFiles(dir string, encryptor *encryption.FieldEncryptor) *Files {
+	return &Files{
+		dir:       dir,
+		encryptor: encryptor,
+	}
+}
+
+// Name returns the name of the file holding the transactions created in the month of the time
+func Name(month time.Time) string {
+	return fmt.Sprintf("transactions-%s.ndjson.gz", month.UTC().Format("2006-01"))
+}
+
+// Append writes the transactions at the end of the file of the month, creating the directory and the file when
+// missing, and returns the name of the file once its content is synced to disk
+func (f *Files) Append(month time.Time, transactions []*models.Transaction) (string, error) {
+	name := Name(month)
+
+	err := os.MkdirAll(f.dir, 0o750)
+	if err != nil {
+		return "", fmt.Errorf("create archive directory failed: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(f.dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return "", fmt.Errorf("open archive file failed: %w", err)
+	}
+
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	for _, transaction := range transactions {
+		err = encoder.Encode(transaction)
+		if err != nil {
+			return "", fmt.Errorf("write archived transaction failed: %w", err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return "", fmt.Errorf("compress archive file failed: %w", err)
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return "", fmt.Errorf("sync archive file failed: %w", err)
+	}
+
+	return name, nil
+}
+
+// Find reads the transaction back from the archive file, decrypting its additional fields. A transaction written
+// more than once, by a run interrupted before removing it from the database, is returned at its latest version
+func (f *Files) Find(ctx context.Context, name, transactionID string) (*models.Transaction, error) {
+	var found *models.Transaction
+
+	err := f.read(name, func(transaction *models.Transaction) {
+		if transaction.TransactionID == transactionID && (found == nil || transaction.Version > found.Version) {
+			found = transaction
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, api.NewResourceNotFoundError(database.ErrTransactionNotFound, "transaction")
+	}
+
+	found.AdditionalFields, err = f.encryptor.Decrypt(ctx, found.AdditionalFields)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt additional fields failed: %w", err)
+	}
+
+	found.Archived = true
+
+	return found, nil
+}
+
+// Erase erases the description and the additional fields other than the provider references of every copy of the
+// transactions in the archive file, returning the IDs of the transactions it held. A missing file holds nothing
+func (f *Files) Erase(name string, transactionIDs []string) ([]string, error) {
+	erase := map[string]bool{}
+
+	for _, transactionID := range transactionIDs {
+		erase[transactionID] = true
+	}
+
+	erased := map[string]bool{}
+
+	err := f.rewrite(name, func(transaction *models.Transaction) bool {
+		if !erase[transaction.TransactionID] {
+			return true
+		}
+
+		transaction.Description = ""
+
+		// encrypted fields are kept encrypted, as only the names of the fields are read
+		fields := map[string]interface{}{}
+
+		for _, field := range models.ProviderReferenceFields {
+			value, ok := transaction.AdditionalFields[field]
+			if ok {
+				fields[field] = value
+			}
+		}
+
+		transaction.AdditionalFields = fields
+		erased[transaction.TransactionID] = true
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(erased))
+
+	for transactionID := range erased {
+		ids = append(ids, transactionID)
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// Discard removes the copies of the transactions at their version from the archive file, undoing an append whose
+// transactions were updated before they could be removed from the database, so stale copies don't outlive an erasure
+func (f *Files) Discard(name string, transactions []*models.Transaction) error {
+	discard := map[string]int{}
+
+	for _, transaction := range transactions {
+		discard[transaction.TransactionID] = transaction.Version
+	}
+
+	return f.rewrite(name, func(transaction *models.Transaction) bool {
+		version, ok := discard[transaction.TransactionID]
+
+		return !ok || version != transaction.Version
+	})
+}
+
+// read decodes every transaction of the archive file, across the gzip members appended by each run
+func (f *Files) read(name string, fn func(transaction *models.Transaction)) error {
+	// the name comes from the database, but it is never allowed to leave the directory
+	file, err := os.Open(filepath.Join(f.dir, filepath.Base(name)))
+	if err != nil {
+		return fmt.Errorf("open archive file failed: %w", err)
+	}
+
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("decompress archive file failed: %w", err)
+	}
+
+	defer reader.Close()
+
+	decoder := json.NewDecoder(reader)
+
+	for {
+		var transaction models.Transaction
+
+		err = decoder.Decode(&transaction)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("read archived transaction failed: %w", err)
+		}
+
+		fn(&transaction)
+	}
+}
+
+// rewrite replaces the archive file by a copy holding the transactions fn keeps, as fn changed them. The copy is
+// synced before it's renamed over the file, so an interrupted rewrite leaves the file as it was. A missing file is
+// left missing
+func (f *Files) rewrite(name string, fn func(transaction *models.Transaction) bool) error {
+	path := filepath.Join(f.dir, filepath.Base(name))
+
+	var transactions []*models.Transaction
+
+	err := f.read(name, func(transaction *models.Transaction) {
+		if fn(transaction) {
+			transactions = append(transactions, transaction)
+		}
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(f.dir, filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create archive file failed: %w", err)
+	}
+
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	err = file.Chmod(0o640)
+	if err != nil {
+		return fmt.Errorf("create archive file failed: %w", err)
+	}
+
+	writer := gzip.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	for _, transaction := range transactions {
+		err = encoder.Encode(transaction)
+		if err != nil {
+			return fmt.Errorf("write archived transaction failed: %w", err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("compress archive file failed: %w", err)
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return fmt.Errorf("sync archive file failed: %w", err)
+	}
+
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return fmt.Errorf("replace archive file failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var archivedTransaction = &models.Transaction{
+	TransactionID:    "TXN_123",
+	MerchantID:       "MCH_123",
+	Status:           models.TransactionStatusSucceeded,
+	Description:      "order 42",
+	Provider:         models.PaymentProviderStripe,
+	Amount:           2000,
+	Currency:         "usd",
+	Type:             models.TransactionTypeCharge,
+	AdditionalFields: map[string]interface{}{"charge_id": "ch_123"},
+	Version:          1,
+	CreatedAt:        time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	UpdatedAt:        time.Date(2024, 3, 1, 10, 5, 0, 0, time.UTC),
+}
+
+func TestAppendFind(t *testing.T) {
+	c := require.New(t)
+
+	dir := filepath.Join(t.TempDir(), "archive")
+	files := NewFiles(dir, nil)
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	name, err := files.Append(month, []*models.Transaction{archivedTransaction, {TransactionID: "TXN_456", Version: 1}})
+	c.NoError(err)
+	c.Equal("transactions-2024-03.ndjson.gz", name)
+
+	updated := *archivedTransaction
+	updated.Version = 2
+	updated.Status = models.TransactionStatusFailure
+
+	_, err = files.Append(month, []*models.Transaction{&updated})
+	c.NoError(err)
+
+	entries, err := os.ReadDir(dir)
+	c.NoError(err)
+	c.Len(entries, 1, "runs append to the file of the month")
+
+	transaction, err := files.Find(context.Background(), name, "TXN_123")
+	c.NoError(err)
+	c.Equal(2, transaction.Version, "the latest copy is returned")
+	c.Equal(models.TransactionStatusFailure, transaction.Status)
+	c.Equal("ch_123", transaction.AdditionalFields["charge_id"])
+	c.True(transaction.Archived)
+	c.True(archivedTransaction.CreatedAt.Equal(transaction.CreatedAt))
+
+	_, err = files.Find(context.Background(), name, "TXN_789")
+	c.ErrorIs(err, database.ErrTransactionNotFound)
+
+	_, err = files.Find(context.Background(), "../"+name, "TXN_123")
+	c.NoError(err, "names are resolved inside the directory")
+}
+
+func TestDatabaseGetTransaction(t *testing.T) {
+	c := require.New(t)
+
+	files := NewFiles(t.TempDir(), nil)
+
+	name, err := files.Append(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), []*models.Transaction{archivedTransaction})
+	c.NoError(err)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_LIVE").Return(&models.Transaction{TransactionID: "TXN_LIVE"}, nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return((*models.Transaction)(nil), database.ErrTransactionNotFound)
+	mockDatabase.On("GetArchiveFile", mock.Anything, "TXN_123").Return(name, nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_404").Return((*models.Transaction)(nil), database.ErrTransactionNotFound)
+	mockDatabase.On("GetArchiveFile", mock.Anything, "TXN_404").Return("", database.ErrTransactionNotFound)
+
+	archived := NewDatabase(&mockDatabase, files)
+
+	transaction, err := archived.GetTransaction(context.Background(), "TXN_LIVE")
+	c.NoError(err)
+	c.Equal("TXN_LIVE", transaction.TransactionID)
+
+	transaction, err = archived.GetTransaction(context.Background(), "TXN_123")
+	c.NoError(err)
+	c.Equal("order 42", transaction.Description)
+
+	_, err = archived.GetTransaction(context.Background(), "TXN_404")
+	c.ErrorIs(err, database.ErrTransactionNotFound)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestEraseDiscard(t *testing.T) {
+	c := require.New(t)
+
+	files := NewFiles(t.TempDir(), nil)
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	updated := *archivedTransaction
+	updated.Version = 2
+	updated.AdditionalFields = map[string]interface{}{"charge_id": "ch_123", "email": "jane@example.com"}
+
+	other := &models.Transaction{TransactionID: "TXN_456", Description: "order 43", Version: 1}
+
+	name, err := files.Append(month, []*models.Transaction{archivedTransaction, other})
+	c.NoError(err)
+
+	_, err = files.Append(month, []*models.Transaction{&updated})
+	c.NoError(err)
+
+	erased, err := files.Erase(name, []string{"TXN_123", "TXN_789"})
+	c.NoError(err)
+	c.Equal([]string{"TXN_123"}, erased)
+
+	transaction, err := files.Find(context.Background(), name, "TXN_123")
+	c.NoError(err)
+	c.Equal(2, transaction.Version)
+	c.Empty(transaction.Description)
+	c.Equal(map[string]interface{}{"charge_id": "ch_123"}, transaction.AdditionalFields, "provider references are kept")
+
+	transaction, err = files.Find(context.Background(), name, "TXN_456")
+	c.NoError(err)
+	c.Equal("order 43", transaction.Description, "other transactions are kept as they are")
+
+	err = files.Discard(name, []*models.Transaction{&updated})
+	c.NoError(err)
+
+	transaction, err = files.Find(context.Background(), name, "TXN_123")
+	c.NoError(err)
+	c.Equal(1, transaction.Version, "only the copy at the discarded version is removed")
+	c.Empty(transaction.Description, "every copy is erased")
+
+	erased, err = files.Erase("transactions-2024-04.ndjson.gz", []string{"TXN_123"})
+	c.NoError(err)
+	c.Empty(erased, "missing files hold nothing")
+
+	entries, err := os.ReadDir(files.dir)
+	c.NoError(err)
+	c.Len(entries, 1, "rewrites leave no temporary file behind")
+}
+
+func TestDatabaseExportCustomerData(t *testing.T) {
+	c := require.New(t)
+
+	files := NewFiles(t.TempDir(), nil)
+
+	name, err := files.Append(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), []*models.Transaction{archivedTransaction})
+	c.NoError(err)
+
+	live := &models.Transaction{TransactionID: "TXN_LIVE", CreatedAt: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)}
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("ExportCustomerData", mock.Anything, "jane@example.com").Return(&models.CustomerData{
+		Customer:     "jane@example.com",
+		Transactions: []*models.Transaction{live},
+		RiskDecisions: []*models.RiskDecision{
+			{TransactionID: "TXN_LIVE"},
+			{TransactionID: "TXN_123"},
+			{TransactionID: "TXN_123"},
+			{TransactionID: "TXN_404"},
+		},
+	}, nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return((*models.Transaction)(nil), database.ErrTransactionNotFound).Once()
+	mockDatabase.On("GetArchiveFile", mock.Anything, "TXN_123").Return(name, nil).Once()
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_404").Return((*models.Transaction)(nil), database.ErrTransactionNotFound)
+	mockDatabase.On("GetArchiveFile", mock.Anything, "TXN_404").Return("", database.ErrTransactionNotFound)
+
+	data, err := NewDatabase(&mockDatabase, files).ExportCustomerData(context.Background(), "jane@example.com")
+	c.NoError(err)
+	c.Len(data.Transactions, 2)
+	c.Equal("TXN_123", data.Transactions[0].TransactionID, "archived transactions are sorted by creation")
+	c.Equal("order 42", data.Transactions[0].Description)
+	c.True(data.Transactions[0].Archived)
+	c.Equal("TXN_LIVE", data.Transactions[1].TransactionID)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestDatabaseEraseCustomerData(t *testing.T) {
+	c := require.New(t)
+
+	files := NewFiles(t.TempDir(), nil)
+
+	name, err := files.Append(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), []*models.Transaction{archivedTransaction})
+	c.NoError(err)
+
+	// a copy left in the file of its month by an interrupted run, while the transaction is still in the database
+	left := &models.Transaction{TransactionID: "TXN_LEFT", Description: "order 44", Version: 1, CreatedAt: time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)}
+
+	leftName, err := files.Append(left.CreatedAt, []*models.Transaction{left})
+	c.NoError(err)
+
+	erasure := &models.CustomerErasure{ErasureID: "ERS_123", Customer: "jane@example.com"}
+	entry := &models.AuditEntry{Action: models.AuditActionEraseCustomerData}
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("EraseCustomerData", mock.Anything, erasure, entry).Run(func(args mock.Arguments) {
+		erased := args.Get(1).(*models.CustomerErasure)
+		erased.Transactions = 1
+		erased.TransactionIDs = []string{"TXN_123", "TXN_123", "TXN_LEFT", "TXN_404"}
+	}).Return(nil)
+	mockDatabase.On("GetArchiveFile", mock.Anything, "TXN_123").Return(name, nil).Once()
+	mockDatabase.On("GetArchiveFile", mock.Anything, "TXN_LEFT").Return("", database.ErrTransactionNotFound)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_LEFT").Return(left, nil)
+	mockDatabase.On("GetArchiveFile", mock.Anything, "TXN_404").Return("", database.ErrTransactionNotFound)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_404").Return((*models.Transaction)(nil), database.ErrTransactionNotFound)
+	mockDatabase.On("LockArchiveFile", mock.Anything, name).Return(nil).Once()
+	mockDatabase.On("LockArchiveFile", mock.Anything, leftName).Return(nil).Once()
+
+	err = NewDatabase(&mockDatabase, files).EraseCustomerData(context.Background(), erasure, entry)
+	c.NoError(err)
+	c.Equal(2, erasure.Transactions, "only the transactions removed from the database are added")
+
+	transaction, err := files.Find(context.Background(), name, "TXN_123")
+	c.NoError(err)
+	c.Empty(transaction.Description)
+
+	transaction, err = files.Find(context.Background(), leftName, "TXN_LEFT")
+	c.NoError(err)
+	c.Empty(transaction.Description)
+
+	mockDatabase.AssertExpectations(t)
+}
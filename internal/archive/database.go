@@ -0,0 +1,176 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sort"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// archivedDatabase embeds the wrapped database so every other operation is forwarded
+type archivedDatabase struct {
+	database.Database
+	files *Files
+}
+
+// NewDatabase decorates a database to read the transactions missing from it from the archive files they were
+// exported to
+func NewDatabase(next database.Database, files *Files) database.Database {
+	return archivedDatabase{
+		Database: next,
+		files:    files,
+	}
+}
+
+// GetTransaction fetches the transaction from the wrapped database, falling back to its archive file
+func (a archivedDatabase) GetTransaction(ctx context.Context, transactionID string) (*models.Transaction, error) {
+	transaction, err := a.Database.GetTransaction(ctx, transactionID)
+	if !errors.Is(err, database.ErrTransactionNotFound) {
+		return transaction, err
+	}
+
+	file, err := a.Database.GetArchiveFile(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err = a.files.Find(ctx, file, transactionID)
+	if err != nil && !errors.Is(err, database.ErrTransactionNotFound) {
+		slog.ErrorContext(ctx, "read archived transaction failed", slog.String("file", file), slog.Any("error", err))
+
+		return nil, api.NewInternalServerError(err)
+	}
+
+	return transaction, err
+}
+
+// ExportCustomerData exports the customer data held by the wrapped database, adding the transactions of their risk
+// decisions that were exported to archive files
+func (a archivedDatabase) ExportCustomerData(ctx context.Context, customer string) (*models.CustomerData, error) {
+	data, err := a.Database.ExportCustomerData(ctx, customer)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := map[string]bool{}
+
+	for _, transaction := range data.Transactions {
+		exported[transaction.TransactionID] = true
+	}
+
+	archived := false
+
+	for _, decision := range data.RiskDecisions {
+		if exported[decision.TransactionID] {
+			continue
+		}
+
+		exported[decision.TransactionID] = true
+
+		transaction, err := a.GetTransaction(ctx, decision.TransactionID)
+		if errors.Is(err, database.ErrTransactionNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		data.Transactions = append(data.Transactions, transaction)
+		archived = true
+	}
+
+	if archived {
+		sort.SliceStable(data.Transactions, func(i, j int) bool {
+			if data.Transactions[i].CreatedAt.Equal(data.Transactions[j].CreatedAt) {
+				return data.Transactions[i].TransactionID < data.Transactions[j].TransactionID
+			}
+
+			return data.Transactions[i].CreatedAt.Before(data.Transactions[j].CreatedAt)
+		})
+	}
+
+	return data, nil
+}
+
+// EraseCustomerData erases the customer data held by the wrapped database, then the copies of their transactions held
+// by archive files. Transactions still in the database are looked up in the file of the month they were created in,
+// which may hold a copy left by an interrupted run. The transactions erased from files are added to the erasure, while
+// the audit entry, written along with the database erasure, counts those of the database. A file failing to be
+// rewritten is logged along with the transactions left in it, as the database erasure is already committed
+func (a archivedDatabase) EraseCustomerData(ctx context.Context, erasure *models.CustomerErasure, entry *models.AuditEntry) error {
+	err := a.Database.EraseCustomerData(ctx, erasure, entry)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]string{}
+	archived := map[string]bool{}
+
+	for _, transactionID := range erasure.TransactionIDs {
+		if archived[transactionID] {
+			continue
+		}
+
+		file, err := a.Database.GetArchiveFile(ctx, transactionID)
+		if err == nil {
+			archived[transactionID] = true
+			files[file] = append(files[file], transactionID)
+
+			continue
+		}
+
+		if !errors.Is(err, database.ErrTransactionNotFound) {
+			return err
+		}
+
+		transaction, err := a.Database.GetTransaction(ctx, transactionID)
+		if errors.Is(err, database.ErrTransactionNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		file = Name(transaction.CreatedAt)
+
+		if !slices.Contains(files[file], transactionID) {
+			files[file] = append(files[file], transactionID)
+		}
+	}
+
+	for file, transactionIDs := range files {
+		err = a.Database.LockArchiveFile(ctx, file, func() error {
+			erased, err := a.files.Erase(file, transactionIDs)
+			if err != nil {
+				slog.ErrorContext(ctx, "erase archived transactions failed",
+					slog.String("file", file),
+					slog.Any("transaction_ids", transactionIDs),
+					slog.Any("error", err),
+				)
+
+				return api.NewInternalServerError(err)
+			}
+
+			// transactions left in the database were counted when they were erased there
+			for _, transactionID := range erased {
+				if archived[transactionID] {
+					erasure.Transactions++
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
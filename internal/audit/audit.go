@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+type contextKey string
+
+const (
+	actorContextKey       contextKey = "audit_actor"
+	fingerprintContextKey contextKey = "audit_request_fingerprint"
+)
+
+// SystemActor actor of the operations the platform performs on its own
+const SystemActor = "system"
+
+type actor struct {
+	actorType models.AuditActorType
+	name      string
+}
+
+// WithActor returns a copy of the context whose operations are audited on behalf of the actor
+func WithActor(ctx context.Context, actorType models.AuditActorType, name string) context.Context {
+	return context.WithValue(ctx, actorContextKey, actor{actorType: actorType, name: name})
+}
+
+// ActorFromContext returns the actor the operations of the context are audited on behalf of, which is the platform
+// itself unless set with WithActor
+func ActorFromContext(ctx context.Context) (models.AuditActorType, string) {
+	if actor, ok := ctx.Value(actorContextKey).(actor); ok {
+		return actor.actorType, actor.name
+	}
+
+	return models.AuditActorSystem, SystemActor
+}
+
+// WithRequestFingerprint returns a copy of the context carrying the fingerprint of the request performing its operations
+func WithRequestFingerprint(ctx context.Context, fingerprint string) context.Context {
+	return context.WithValue(ctx, fingerprintContextKey, fingerprint)
+}
+
+// RequestFingerprintFromContext returns the fingerprint of the request stored in the context, if any
+func RequestFingerprintFromContext(ctx context.Context) string {
+	fingerprint, _ := ctx.Value(fingerprintContextKey).(string)
+
+	return fingerprint
+}
+
+// Stamp fills the actor and the request fingerprint of the entry from the context, keeping the ones already set
+func Stamp(ctx context.Context, entry *models.AuditEntry) {
+	actorType, name := ActorFromContext(ctx)
+
+	if entry.ActorType == "" {
+		entry.ActorType = actorType
+	}
+
+	if entry.Actor == "" {
+		entry.Actor = name
+	}
+
+	if entry.RequestFingerprint == "" {
+		entry.RequestFingerprint = RequestFingerprintFromContext(ctx)
+	}
+}
@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActorFromContext(t *testing.T) {
+	c := require.New(t)
+
+	actorType, actor := ActorFromContext(context.Background())
+	c.Equal(models.AuditActorSystem, actorType)
+	c.Equal(SystemActor, actor)
+
+	ctx := WithActor(context.Background(), models.AuditActorAPIKey, "KEY_123")
+
+	actorType, actor = ActorFromContext(WithActor(ctx, models.AuditActorOperator, "alice"))
+	c.Equal(models.AuditActorOperator, actorType)
+	c.Equal("alice", actor)
+}
+
+func TestStampKeepsExplicitActor(t *testing.T) {
+	c := require.New(t)
+
+	ctx := WithRequestFingerprint(WithActor(context.Background(), models.AuditActorOperator, "alice"), "3f1c")
+
+	entry := &models.AuditEntry{Actor: models.ReviewerTimeout}
+
+	Stamp(ctx, entry)
+	c.Equal(models.ReviewerTimeout, entry.Actor)
+	c.Equal(models.AuditActorOperator, entry.ActorType)
+	c.Equal("3f1c", entry.RequestFingerprint)
+}
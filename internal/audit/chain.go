@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+var (
+	// ErrSequenceGap error when an entry doesn't follow the previous one, meaning entries were removed
+	ErrSequenceGap = errors.New("sequence gap")
+	// ErrBrokenChain error when an entry doesn't carry the hash of the previous one
+	ErrBrokenChain = errors.New("previous hash mismatch")
+	// ErrHashMismatch error when the hash of an entry doesn't match its content, meaning it was modified
+	ErrHashMismatch = errors.New("hash mismatch")
+	// ErrMissingHash error when an entry appended after the chain started carries no hash
+	ErrMissingHash = errors.New("missing hash")
+)
+
+// Chain checks the entries of the audit trail, fed in the order they were appended, are linked to each other and
+// weren't modified. Entries written before the trail was chained carry no hash and are only checked for gaps
+type Chain struct {
+	sequence int64
+	hash     string
+	started  bool
+}
+
+// Check verifies the entry against the previous ones, returning why it breaks the chain
+func (c *Chain) Check(entry *models.AuditEntry) error {
+	if entry.Sequence != c.sequence+1 {
+		return fmt.Errorf("%w: expected sequence %d, got %d", ErrSequenceGap, c.sequence+1, entry.Sequence)
+	}
+
+	c.sequence = entry.Sequence
+
+	if entry.Hash == "" {
+		if c.started {
+			return ErrMissingHash
+		}
+
+		return nil
+	}
+
+	if entry.PreviousHash != c.hash {
+		return ErrBrokenChain
+	}
+
+	hash, err := entry.ComputeHash()
+	if err != nil {
+		return err
+	}
+
+	if hash != entry.Hash {
+		return ErrHashMismatch
+	}
+
+	c.started = true
+	c.hash = entry.Hash
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// chainedEntries builds entries appended after the legacy ones, chained as the database does
+func chainedEntries(t *testing.T, legacy, chained int) []*models.AuditEntry {
+	entries := []*models.AuditEntry{}
+	previousHash := ""
+
+	for i := 1; i <= legacy+chained; i++ {
+		entry := &models.AuditEntry{
+			AuditID:      fmt.Sprintf("AUD_%d", i),
+			Sequence:     int64(i),
+			Actor:        "alice",
+			ActorType:    models.AuditActorOperator,
+			Action:       models.AuditActionRefund,
+			ResourceType: models.AuditResourceTransaction,
+			ResourceID:   "TXN_123",
+			Details:      map[string]interface{}{"attempt": i},
+			CreatedAt:    time.Date(2024, 3, 1, 10, i, 0, 0, time.UTC),
+		}
+
+		if i > legacy {
+			entry.PreviousHash = previousHash
+
+			hash, err := entry.ComputeHash()
+			require.NoError(t, err)
+
+			entry.Hash = hash
+			previousHash = hash
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func checkChain(entries []*models.AuditEntry) (int64, error) {
+	chain := &Chain{}
+
+	for _, entry := range entries {
+		err := chain.Check(entry)
+		if err != nil {
+			return entry.Sequence, err
+		}
+	}
+
+	return 0, nil
+}
+
+func TestChainValid(t *testing.T) {
+	c := require.New(t)
+
+	_, err := checkChain(chainedEntries(t, 2, 3))
+	c.NoError(err)
+}
+
+func TestChainModifiedEntry(t *testing.T) {
+	c := require.New(t)
+
+	entries := chainedEntries(t, 0, 4)
+	entries[2].Reason = "customer complaint"
+
+	sequence, err := checkChain(entries)
+	c.ErrorIs(err, ErrHashMismatch)
+	c.Equal(int64(3), sequence)
+}
+
+func TestChainRemovedEntry(t *testing.T) {
+	c := require.New(t)
+
+	entries := chainedEntries(t, 0, 4)
+	entries = append(entries[:1], entries[2:]...)
+
+	sequence, err := checkChain(entries)
+	c.ErrorIs(err, ErrSequenceGap)
+	c.Equal(int64(3), sequence)
+}
+
+func TestChainRewrittenEntry(t *testing.T) {
+	c := require.New(t)
+
+	entries := chainedEntries(t, 0, 4)
+
+	// rewriting an entry along with its hash still breaks the link with the next one
+	entries[1].Reason = "customer complaint"
+	hash, err := entries[1].ComputeHash()
+	c.NoError(err)
+	entries[1].Hash = hash
+
+	sequence, err := checkChain(entries)
+	c.ErrorIs(err, ErrBrokenChain)
+	c.Equal(int64(3), sequence)
+}
+
+func TestChainMissingHashAfterStart(t *testing.T) {
+	c := require.New(t)
+
+	entries := chainedEntries(t, 1, 3)
+	entries[3].Hash = ""
+
+	sequence, err := checkChain(entries)
+	c.ErrorIs(err, ErrMissingHash)
+	c.Equal(int64(4), sequence)
+}
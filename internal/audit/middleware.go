@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+)
+
+// maxFingerprintBody bytes of the request body included in its fingerprint
+const maxFingerprintBody = 1 << 20
+
+// Middleware stores the fingerprint of every request in its context, so the audit entries of the operations it
+// performs can be traced back to it
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+
+		if r.Body != nil {
+			read, err := io.ReadAll(io.LimitReader(r.Body, maxFingerprintBody))
+			if err == nil {
+				body = read
+			}
+
+			// the handlers read the body again, including anything past the part fingerprinted
+			r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(read), r.Body), Closer: r.Body}
+		}
+
+		ctx := WithRequestFingerprint(r.Context(), Fingerprint(r, body))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Fingerprint digest of the method, URL, body, client address and user agent of the request. Repeating the same
+// request from the same client gives the same fingerprint
+func Fingerprint(r *http.Request, body []byte) string {
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+
+	bodySum := sha256.Sum256(body)
+
+	hash := sha256.New()
+
+	for _, part := range []string{r.Method, r.URL.RequestURI(), hex.EncodeToString(bodySum[:]), clientIP, r.UserAgent()} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
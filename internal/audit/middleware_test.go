@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareFingerprintsRequest(t *testing.T) {
+	c := require.New(t)
+
+	var (
+		fingerprint string
+		body        string
+	)
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fingerprint = RequestFingerprintFromContext(r.Context())
+
+		read, err := io.ReadAll(r.Body)
+		c.NoError(err)
+
+		body = string(read)
+	}))
+
+	send := func(payload string) string {
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(payload))
+		req.RemoteAddr = "203.0.113.7:52311"
+		req.Header.Set("User-Agent", "curl/8.4.0")
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		return fingerprint
+	}
+
+	first := send("amount=2000&currency=usd")
+	c.Len(first, 64)
+	c.Equal("amount=2000&currency=usd", body)
+
+	c.Equal(first, send("amount=2000&currency=usd"))
+	c.NotEqual(first, send("amount=3000&currency=usd"))
+}
+
+func TestFingerprintDependsOnClient(t *testing.T) {
+	c := require.New(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/payments/TXN_123/refunds", nil)
+	req.RemoteAddr = "203.0.113.7:52311"
+
+	other := req.Clone(req.Context())
+	other.RemoteAddr = "198.51.100.4:52311"
+
+	c.NotEqual(Fingerprint(req, nil), Fingerprint(other, nil))
+
+	// the port changes between connections of the same client
+	samePort := req.Clone(req.Context())
+	samePort.RemoteAddr = "203.0.113.7:40000"
+
+	c.Equal(Fingerprint(req, nil), Fingerprint(samePort, nil))
+}
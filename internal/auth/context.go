@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+type contextKey string
+
+const (
+	apiKeyContextKey   contextKey = "api_key"
+	operatorContextKey contextKey = "operator"
+)
+
+// WithAPIKey returns a copy of the context carrying the authenticated API key
+func WithAPIKey(ctx context.Context, key *models.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, key)
+}
+
+// APIKeyFromContext returns the authenticated API key stored in the context, if any
+func APIKeyFromContext(ctx context.Context) (*models.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(*models.APIKey)
+
+	return key, ok && key != nil
+}
+
+// MerchantIDFromContext returns the merchant owning the authenticated API key, if any
+func MerchantIDFromContext(ctx context.Context) (string, bool) {
+	key, ok := APIKeyFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	return key.MerchantID, true
+}
+
+// WithOperator returns a copy of the context carrying the authenticated operator
+func WithOperator(ctx context.Context, operator *models.Operator) context.Context {
+	return context.WithValue(ctx, operatorContextKey, operator)
+}
+
+// OperatorFromContext returns the authenticated operator stored in the context, if any
+func OperatorFromContext(ctx context.Context) (*models.Operator, bool) {
+	operator, ok := ctx.Value(operatorContextKey).(*models.Operator)
+
+	return operator, ok && operator != nil
+}
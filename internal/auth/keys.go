@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	secretLength        = 24
+	visiblePrefixLength = 4
+)
+
+var keyTypePrefixes = map[models.APIKeyType]string{
+	models.APIKeyTypeSecret:      "sk",
+	models.APIKeyTypePublishable: "pk",
+}
+
+// GenerateAPIKey issues a new API key for a merchant, returning its plaintext value alongside the hashed record
+func GenerateAPIKey(merchantID string, keyType models.APIKeyType, mode models.APIKeyMode) (*models.APIKeyCredential, error) {
+	typePrefix, ok := keyTypePrefixes[keyType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported api key type: %s", keyType)
+	}
+
+	secret := make([]byte, secretLength)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, fmt.Errorf("generate api key secret failed: %w", err)
+	}
+
+	encodedSecret := hex.EncodeToString(secret)
+	plaintext := fmt.Sprintf("%s_%s_%s", typePrefix, mode, encodedSecret)
+
+	key := models.APIKey{
+		KeyID:      fmt.Sprintf("KEY_%s", ulid.Make().String()),
+		MerchantID: merchantID,
+		Type:       keyType,
+		Mode:       mode,
+		Prefix:     fmt.Sprintf("%s_%s_%s", typePrefix, mode, encodedSecret[:visiblePrefixLength]),
+		Hash:       HashAPIKey(plaintext),
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	return &models.APIKeyCredential{
+		APIKey: key,
+		Key:    plaintext,
+	}, nil
+}
+
+// HashAPIKey returns the hex encoded SHA-256 digest used to store and look up an API key
+func HashAPIKey(plaintext string) string {
+	digest := sha256.Sum256([]byte(strings.TrimSpace(plaintext)))
+
+	return hex.EncodeToString(digest[:])
+}
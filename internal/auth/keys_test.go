@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	c := require.New(t)
+
+	credential, err := GenerateAPIKey("MCH_123", models.APIKeyTypePublishable, models.APIKeyModeLive)
+	c.NoError(err)
+	c.True(strings.HasPrefix(credential.Key, "pk_live_"))
+	c.True(strings.HasPrefix(credential.Key, credential.Prefix))
+	c.Equal(HashAPIKey(credential.Key), credential.Hash)
+	c.NotContains(credential.Hash, credential.Key)
+	c.Equal("MCH_123", credential.MerchantID)
+	c.Nil(credential.ExpiresAt)
+
+	other, err := GenerateAPIKey("MCH_123", models.APIKeyTypePublishable, models.APIKeyModeLive)
+	c.NoError(err)
+	c.NotEqual(credential.Key, other.Key)
+}
+
+func TestGenerateAPIKeyUnsupportedType(t *testing.T) {
+	c := require.New(t)
+
+	_, err := GenerateAPIKey("MCH_123", models.APIKeyType("restricted"), models.APIKeyModeTest)
+	c.Error(err)
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+var (
+	// ErrMissingAPIKey error when request does not carry an API key
+	ErrMissingAPIKey = errors.New("missing api key")
+	// ErrInvalidAPIKey error when API key does not match any issued key
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrExpiredAPIKey error when API key has been rotated or expired
+	ErrExpiredAPIKey = errors.New("api key expired")
+	// ErrAPIKeyModeMismatch error when API key mode doesn't match the platform mode
+	ErrAPIKeyModeMismatch = errors.New("api key mode mismatch")
+	// ErrSecretKeyRequired error when a publishable key is used on a secret-only route
+	ErrSecretKeyRequired = errors.New("secret api key required")
+)
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// Authenticate middleware to authenticate merchants through the API key sent as a bearer token
+func Authenticate(store database.MerchantStore, mode models.APIKeyMode) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			plaintext, ok := bearerToken(r)
+			if !ok {
+				api.WriteErrorResponse(w, api.NewUnauthorizedError(ErrMissingAPIKey))
+				return
+			}
+
+			key, err := store.GetAPIKeyByHash(r.Context(), HashAPIKey(plaintext))
+			if errors.Is(err, database.ErrAPIKeyNotFound) {
+				api.WriteErrorResponse(w, api.NewUnauthorizedError(ErrInvalidAPIKey))
+				return
+			}
+
+			if err != nil {
+				api.WriteErrorResponse(w, err)
+				return
+			}
+
+			if !key.IsActive(time.Now()) {
+				api.WriteErrorResponse(w, api.NewUnauthorizedError(ErrExpiredAPIKey))
+				return
+			}
+
+			if key.Mode != mode {
+				api.WriteErrorResponse(w, api.NewUnauthorizedError(ErrAPIKeyModeMismatch))
+				return
+			}
+
+			// the operations of the request are audited on behalf of the key
+			ctx := audit.WithActor(WithAPIKey(r.Context(), key), models.AuditActorAPIKey, key.KeyID)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireSecretKey middleware to reject requests authenticated with a publishable key
+func RequireSecretKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := APIKeyFromContext(r.Context())
+		if !ok || key.Type != models.APIKeyTypeSecret {
+			api.WriteErrorResponse(w, api.NewUnauthorizedError(ErrSecretKeyRequired))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthenticateOperator middleware to authenticate platform operators through the signed token sent as a bearer
+// token, verified with the secret of its key ID. No secrets rejects every request
+func AuthenticateOperator(secrets map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				api.WriteErrorResponse(w, api.NewUnauthorizedError(ErrMissingOperatorToken))
+				return
+			}
+
+			operator, err := VerifyOperatorToken(secrets, token, time.Now())
+			if err != nil {
+				api.WriteErrorResponse(w, api.NewUnauthorizedError(err))
+				return
+			}
+
+			// the operations of the request are audited on behalf of the operator
+			ctx := audit.WithActor(WithOperator(r.Context(), operator), models.AuditActorOperator, operator.Name)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequirePermission middleware to reject requests of operators whose role isn't granted the permission
+func RequirePermission(permission models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := Authorize(r.Context(), permission)
+			if err != nil {
+				api.WriteErrorResponse(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get(authorizationHeader)
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return "", false
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix))
+
+	return token, token != ""
+}
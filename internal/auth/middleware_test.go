@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(handler http.Handler, req *http.Request) (*http.Response, api.APIErr) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	response := recorder.Result()
+
+	var apiErr api.APIErr
+
+	if response.StatusCode != http.StatusOK {
+		_ = json.NewDecoder(response.Body).Decode(&apiErr)
+	}
+
+	return response, apiErr
+}
+
+func TestAuthenticate(t *testing.T) {
+	c := require.New(t)
+
+	key := &models.APIKey{
+		KeyID:      "KEY_123",
+		MerchantID: "MCH_123",
+		Type:       models.APIKeyTypeSecret,
+		Mode:       models.APIKeyModeTest,
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("GetAPIKeyByHash", context.Background(), HashAPIKey("sk_test_abc")).Return(key, nil)
+
+	var merchantID, actor string
+	var actorType models.AuditActorType
+
+	handler := Authenticate(&mockDatabase, models.APIKeyModeTest)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		merchantID, _ = MerchantIDFromContext(r.Context())
+		actorType, actor = audit.ActorFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil)
+	req.Header.Set("Authorization", "Bearer sk_test_abc")
+
+	response, _ := serve(handler, req)
+	c.Equal(http.StatusOK, response.StatusCode)
+	c.Equal("MCH_123", merchantID)
+	c.Equal(models.AuditActorAPIKey, actorType)
+	c.Equal("KEY_123", actor)
+}
+
+func TestAuthenticateFailures(t *testing.T) {
+	c := require.New(t)
+
+	expiredAt := time.Now().Add(-time.Minute)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("GetAPIKeyByHash", context.Background(), HashAPIKey("sk_test_unknown")).Return(nil, api.NewResourceNotFoundError(database.ErrAPIKeyNotFound, "api_key"))
+	mockDatabase.On("GetAPIKeyByHash", context.Background(), HashAPIKey("sk_test_expired")).Return(&models.APIKey{Mode: models.APIKeyModeTest, ExpiresAt: &expiredAt}, nil)
+	mockDatabase.On("GetAPIKeyByHash", context.Background(), HashAPIKey("sk_live_abc")).Return(&models.APIKey{Mode: models.APIKeyModeLive}, nil)
+
+	handler := Authenticate(&mockDatabase, models.APIKeyModeTest)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	testCases := []struct {
+		header string
+		err    error
+	}{
+		{header: "", err: ErrMissingAPIKey},
+		{header: "Basic abc", err: ErrMissingAPIKey},
+		{header: "Bearer sk_test_unknown", err: ErrInvalidAPIKey},
+		{header: "Bearer sk_test_expired", err: ErrExpiredAPIKey},
+		{header: "Bearer sk_live_abc", err: ErrAPIKeyModeMismatch},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil)
+		req.Header.Set("Authorization", testCase.header)
+
+		response, apiErr := serve(handler, req)
+		c.Equal(http.StatusUnauthorized, response.StatusCode)
+		c.Equal(api.ErrCodeUnauthorized, apiErr.Code())
+		c.Contains(apiErr.Message, testCase.err.Error())
+	}
+}
+
+func TestRequireSecretKey(t *testing.T) {
+	c := require.New(t)
+
+	handler := RequireSecretKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil)
+	req = req.WithContext(WithAPIKey(req.Context(), &models.APIKey{Type: models.APIKeyTypePublishable}))
+
+	response, apiErr := serve(handler, req)
+	c.Equal(http.StatusUnauthorized, response.StatusCode)
+	c.Contains(apiErr.Message, ErrSecretKeyRequired.Error())
+
+	req = req.WithContext(WithAPIKey(req.Context(), &models.APIKey{Type: models.APIKeyTypeSecret}))
+
+	response, _ = serve(handler, req)
+	c.Equal(http.StatusOK, response.StatusCode)
+}
+
+func TestAuthenticateOperator(t *testing.T) {
+	c := require.New(t)
+
+	token, err := IssueOperatorToken(testOperatorSecret, "1", "alice", models.OperatorRoleFinance, time.Hour)
+	c.NoError(err)
+
+	var operator *models.Operator
+	var actorType models.AuditActorType
+	var actor string
+
+	handler := AuthenticateOperator(testOperatorSecrets)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		operator, _ = OperatorFromContext(r.Context())
+		actorType, actor = audit.ActorFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/refunds", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	response, _ := serve(handler, req)
+	c.Equal(http.StatusOK, response.StatusCode)
+	c.Equal("alice", operator.Name)
+	c.Equal(models.OperatorRoleFinance, operator.Role)
+	c.Equal(models.AuditActorOperator, actorType)
+	c.Equal("alice", actor)
+
+	response, apiErr := serve(AuthenticateOperator(map[string]string{})(handler), req)
+	c.Equal(http.StatusUnauthorized, response.StatusCode)
+	c.Contains(apiErr.Message, ErrInvalidOperatorToken.Error())
+
+	req.Header.Del("Authorization")
+
+	response, apiErr = serve(handler, req)
+	c.Equal(http.StatusUnauthorized, response.StatusCode)
+	c.Contains(apiErr.Message, ErrMissingOperatorToken.Error())
+}
+
+func TestRequirePermission(t *testing.T) {
+	c := require.New(t)
+
+	handler := RequirePermission(models.PermissionDecideRefundRequests)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "/refunds/RFR_123/approve", nil)
+
+	response, apiErr := serve(handler, req)
+	c.Equal(http.StatusUnauthorized, response.StatusCode)
+	c.Equal(api.ErrCodeUnauthorized, apiErr.Code())
+
+	req = req.WithContext(WithOperator(req.Context(), &models.Operator{Name: "bob", Role: models.OperatorRoleSupport}))
+
+	response, apiErr = serve(handler, req)
+	c.Equal(http.StatusForbidden, response.StatusCode)
+	c.Equal(api.ErrCodeForbidden, apiErr.Code())
+	c.Contains(apiErr.Message, ErrPermissionDenied.Error())
+
+	req = req.WithContext(WithOperator(req.Context(), &models.Operator{Name: "carol", Role: models.OperatorRoleFinance}))
+
+	response, _ = serve(handler, req)
+	c.Equal(http.StatusOK, response.StatusCode)
+}
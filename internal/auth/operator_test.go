@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+const testOperatorSecret = "3b1f6c0e9a8d4f27b5c1e0d9a7f3b2c4"
+
+var testOperatorSecrets = map[string]string{"1": testOperatorSecret}
+
+func TestIssueOperatorToken(t *testing.T) {
+	c := require.New(t)
+
+	token, err := IssueOperatorToken(testOperatorSecret, "1", "alice", models.OperatorRoleSupport, time.Hour)
+	c.NoError(err)
+	c.True(strings.HasPrefix(token, "opt_"))
+
+	operator, err := VerifyOperatorToken(testOperatorSecrets, token, time.Now())
+	c.NoError(err)
+	c.Equal("alice", operator.Name)
+	c.Equal(models.OperatorRoleSupport, operator.Role)
+	c.Equal("1", operator.KeyID)
+	c.WithinDuration(time.Now().Add(time.Hour), operator.ExpiresAt, time.Minute)
+}
+
+func TestIssueOperatorTokenInvalid(t *testing.T) {
+	c := require.New(t)
+
+	_, err := IssueOperatorToken("", "1", "alice", models.OperatorRoleAdmin, time.Hour)
+	c.Error(err)
+
+	_, err = IssueOperatorToken(testOperatorSecret, "1", "", models.OperatorRoleAdmin, time.Hour)
+	c.Error(err)
+
+	_, err = IssueOperatorToken(testOperatorSecret, "1", "alice", "root", time.Hour)
+	c.Error(err)
+
+	_, err = IssueOperatorToken(testOperatorSecret, "1", "alice", models.OperatorRoleAdmin, 0)
+	c.Error(err)
+
+	_, err = IssueOperatorToken(testOperatorSecret, "", "alice", models.OperatorRoleAdmin, time.Hour)
+	c.Error(err)
+}
+
+func TestVerifyOperatorTokenFailures(t *testing.T) {
+	c := require.New(t)
+
+	token, err := IssueOperatorToken(testOperatorSecret, "1", "alice", models.OperatorRoleViewer, time.Hour)
+	c.NoError(err)
+
+	payload, signature, _ := strings.Cut(token, ".")
+
+	// a token issued as a viewer and edited into an admin keeps the signature of the viewer claims
+	forged := strings.Replace(payload, payload[len(operatorTokenPrefix):], "eyJzdWIiOiJhbGljZSIsInJvbGUiOiJhZG1pbiJ9", 1) + "." + signature
+
+	testCases := []struct {
+		secrets map[string]string
+		token   string
+		now     time.Time
+		err     error
+	}{
+		{secrets: map[string]string{}, token: token, now: time.Now(), err: ErrInvalidOperatorToken},
+		{secrets: map[string]string{"1": "another secret"}, token: token, now: time.Now(), err: ErrInvalidOperatorToken},
+		{secrets: testOperatorSecrets, token: "sk_test_abc", now: time.Now(), err: ErrInvalidOperatorToken},
+		{secrets: testOperatorSecrets, token: payload, now: time.Now(), err: ErrInvalidOperatorToken},
+		{secrets: testOperatorSecrets, token: forged, now: time.Now(), err: ErrInvalidOperatorToken},
+		{secrets: testOperatorSecrets, token: token, now: time.Now().Add(2 * time.Hour), err: ErrExpiredOperatorToken},
+	}
+
+	for _, testCase := range testCases {
+		_, err := VerifyOperatorToken(testCase.secrets, testCase.token, testCase.now)
+		c.ErrorIs(err, testCase.err)
+	}
+}
+
+func TestVerifyOperatorTokenRotatedKey(t *testing.T) {
+	c := require.New(t)
+
+	token, err := IssueOperatorToken(testOperatorSecret, "1", "alice", models.OperatorRoleSupport, time.Hour)
+	c.NoError(err)
+
+	// key 2 is active and key 1 is still accepted while its tokens expire
+	operator, err := VerifyOperatorToken(map[string]string{"1": testOperatorSecret, "2": "8e2d5a1f7c4b9e06d3a8f1c5b7e2d940"}, token, time.Now())
+	c.NoError(err)
+	c.Equal("alice", operator.Name)
+
+	// key 1 was rotated out, revoking its tokens
+	_, err = VerifyOperatorToken(map[string]string{"2": "8e2d5a1f7c4b9e06d3a8f1c5b7e2d940"}, token, time.Now())
+	c.ErrorIs(err, ErrInvalidOperatorToken)
+}
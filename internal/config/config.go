@@ -0,0 +1,908 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Service identifies the executable loading the configuration, which determines the settings it requires
+type Service string
+
+const (
+	// ServiceAPI online payment platform API
+	ServiceAPI Service = "api"
+	// ServiceWebhooks online payment webhooks
+	ServiceWebhooks Service = "webhooks"
+	// ServiceMigrate command to manage the database schema
+	ServiceMigrate Service = "migrate"
+	// ServiceRelay process publishing the outbox events
+	ServiceRelay Service = "relay"
+	// ServicePaymentctl command used by operators to investigate and fix payments
+	ServicePaymentctl Service = "paymentctl"
+)
+
+// ErrInvalidConfig error when the configuration failed to load or validate
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Config settings shared by the platform services
+type Config struct {
+	LogLevel        string        `yaml:"log_level"`
+	DebugMode       bool          `yaml:"debug_mode"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	API             API           `yaml:"api"`
+	Webhooks        Webhooks      `yaml:"webhooks"`
+	Relay           Relay         `yaml:"relay"`
+	Database        Database      `yaml:"database"`
+	Stripe          Stripe        `yaml:"stripe"`
+	Tracing         Tracing       `yaml:"tracing"`
+	Pricing         Pricing       `yaml:"pricing"`
+	Limits          Limits        `yaml:"limits"`
+	Risk            Risk          `yaml:"risk"`
+	Operators       Operators     `yaml:"operators"`
+	Encryption      Encryption    `yaml:"encryption"`
+	Retention       Retention     `yaml:"retention"`
+}
+
+// API settings of the online payment platform API
+type API struct {
+	Port                      string            `yaml:"port"`
+	PlatformMode              models.APIKeyMode `yaml:"platform_mode"`
+	APIKeyRotationGracePeriod time.Duration     `yaml:"api_key_rotation_grace_period"`
+	RateLimit                 RateLimit         `yaml:"rate_limit"`
+}
+
+// RateLimit requests per minute allowed for each merchant, and for each client IP before requests are authenticated
+type RateLimit struct {
+	WritesPerMinute int `yaml:"writes_per_minute"`
+	ReadsPerMinute  int `yaml:"reads_per_minute"`
+	IPPerMinute     int `yaml:"ip_per_minute"`
+}
+
+// Webhooks settings of the online payment webhooks
+type Webhooks struct {
+	Port string `yaml:"port"`
+}
+
+// Relay settings of the process publishing the outbox events to the configured sink
+type Relay struct {
+	Port         string        `yaml:"port"`
+	Sink         string        `yaml:"sink"`
+	BatchSize    int           `yaml:"batch_size"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	HTTPURL      string        `yaml:"http_url"`
+	NATSURL      string        `yaml:"nats_url"`
+	NATSSubject  string        `yaml:"nats_subject"`
+}
+
+// Database connection settings of PostgreSQL
+type Database struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+	// AutoMigrate applies pending schema migrations when a service starts
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// Stripe credentials used by the payment processor and to verify webhook events
+type Stripe struct {
+	SecretKey        string `yaml:"secret_key"`
+	WebhookSecretKey string `yaml:"webhook_secret_key"`
+}
+
+// Tracing settings of the span exporter, which defaults to otlp when an OTLP endpoint is set
+type Tracing struct {
+	Exporter string `yaml:"exporter"`
+}
+
+// Fee charged by the platform, as a percentage of the amount plus a fixed amount in the currency minor unit
+type Fee struct {
+	Percentage float64 `yaml:"percentage"`
+	Fixed      int     `yaml:"fixed"`
+}
+
+// PricingPlan fees charged per currency, falling back to the default fee for other currencies
+type PricingPlan struct {
+	Default    Fee            `yaml:"default"`
+	Currencies map[string]Fee `yaml:"currencies"`
+}
+
+// Pricing plan applied to every merchant, unless the merchant has its own plan
+type Pricing struct {
+	PricingPlan `yaml:",inline"`
+	Merchants   map[string]PricingPlan `yaml:"merchants"`
+}
+
+// Limit caps on the payments of a merchant in a currency, in the currency minor unit, where zero means no cap
+type Limit struct {
+	MaxCharge     int `yaml:"max_charge"`
+	DailyVolume   int `yaml:"daily_volume"`
+	MonthlyVolume int `yaml:"monthly_volume"`
+	// MaxRefundRatio percentage of the volume charged over the last 30 days that can be refunded
+	MaxRefundRatio float64 `yaml:"max_refund_ratio"`
+	// RefundApprovalThreshold amount above which refunds are held until an operator approves them
+	RefundApprovalThreshold int `yaml:"refund_approval_threshold"`
+}
+
+// LimitPlan limits per currency, falling back to the default limit for other currencies
+type LimitPlan struct {
+	Default    Limit            `yaml:"default"`
+	Currencies map[string]Limit `yaml:"currencies"`
+}
+
+// Limits plan applied to every merchant, unless the merchant has its own plan
+type Limits struct {
+	LimitPlan `yaml:",inline"`
+	Merchants map[string]LimitPlan `yaml:"merchants"`
+}
+
+// Risk settings of the rules evaluated before charging a payment
+type Risk struct {
+	// RulesFile YAML file with the risk rules, where no file means every payment is allowed
+	RulesFile string `yaml:"rules_file"`
+	// ReloadInterval how often the rules file is checked for changes
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+	// ReviewTimeout how long a payment flagged for review waits for a decision before it's rejected
+	ReviewTimeout time.Duration `yaml:"review_timeout"`
+	// ReviewSweepInterval how often expired reviews are looked for
+	ReviewSweepInterval time.Duration `yaml:"review_sweep_interval"`
+}
+
+// Operators settings of the signed tokens authenticating the platform operators
+type Operators struct {
+	// TokenSecret key signing the operator tokens, where no key disables the operator routes of the API
+	TokenSecret string `yaml:"token_secret"`
+	// TokenKeyID ID of the token secret, carried by the tokens it signs
+	TokenKeyID string `yaml:"token_key_id"`
+	// PreviousTokenSecrets keys rotated out by their key ID, whose tokens are accepted until the key is removed
+	PreviousTokenSecrets map[string]string `yaml:"previous_token_secrets"`
+}
+
+// TokenSecrets keys accepted to verify operator tokens by their key ID, which is empty without a token secret
+func (o Operators) TokenSecrets() map[string]string {
+	secrets := map[string]string{}
+	if o.TokenSecret == "" {
+		return secrets
+	}
+
+	for keyID, secret := range o.PreviousTokenSecrets {
+		secrets[keyID] = secret
+	}
+
+	secrets[o.TokenKeyID] = o.TokenSecret
+
+	return secrets
+}
+
+// Encryption settings of the additional fields of transactions encrypted at rest
+type Encryption struct {
+	// KeyFile YAML file with the master keys and the blind index key
+	KeyFile string `yaml:"key_file"`
+	// Fields additional fields encrypted at rest, where no fields disables encryption
+	Fields []string `yaml:"fields"`
+	// IndexedFields encrypted fields transactions can still be searched by, through a blind index of their value
+	IndexedFields []string `yaml:"indexed_fields"`
+}
+
+// Retention settings of how long transactions and webhook event payloads are kept
+type Retention struct {
+	// TransactionMonths months settled transactions stay in transactions_history before they're archived, where zero
+	// keeps them forever
+	TransactionMonths int `yaml:"transaction_months"`
+	// ArchiveMode where archived transactions are moved to, the transactions_archive table or NDJSON files
+	ArchiveMode models.ArchiveMode `yaml:"archive_mode"`
+	// ArchiveDir directory of the compressed NDJSON files, which every service reads archived transactions from
+	ArchiveDir string `yaml:"archive_dir"`
+	// WebhookPayloads how long the payloads of processed webhook events are kept, where zero keeps them forever
+	WebhookPayloads time.Duration `yaml:"webhook_payloads"`
+	// RefundWindow how long succeeded charges can still be refunded, during which they're never archived, as archived
+	// transactions can't be updated
+	RefundWindow time.Duration `yaml:"refund_window"`
+}
+
+// unencryptableFields additional fields payouts look transactions up by, which must stay in plaintext
+var unencryptableFields = map[string]bool{
+	"charge_id": true,
+	"refund_id": true,
+}
+
+// minOperatorTokenSecretLength shortest key accepted to sign operator tokens
+const minOperatorTokenSecretLength = 32
+
+// maxReviewTimeout how long Stripe holds the funds of an uncaptured card payment
+const maxReviewTimeout = 7 * 24 * time.Hour
+
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+var validLogLevels = map[string]bool{
+	"debug": true,
+	"info":  true,
+	"warn":  true,
+	"error": true,
+}
+
+var validRelaySinks = map[string]bool{
+	"http":   true,
+	"nats":   true,
+	"stdout": true,
+}
+
+var validTracingExporters = map[string]bool{
+	"otlp":   true,
+	"stdout": true,
+	"none":   true,
+}
+
+// Default returns the configuration used when a setting is not provided
+func Default() *Config {
+	return &Config{
+		LogLevel:        "info",
+		ShutdownTimeout: 30 * time.Second,
+		API: API{
+			Port:                      "3000",
+			PlatformMode:              models.APIKeyModeTest,
+			APIKeyRotationGracePeriod: 24 * time.Hour,
+			RateLimit: RateLimit{
+				WritesPerMinute: 60,
+				ReadsPerMinute:  300,
+				IPPerMinute:     600,
+			},
+		},
+		Webhooks: Webhooks{
+			Port: "3001",
+		},
+		Relay: Relay{
+			Port:         "3002",
+			Sink:         "stdout",
+			BatchSize:    100,
+			PollInterval: time.Second,
+			NATSSubject:  "payments",
+		},
+		Database: Database{
+			Host:    "localhost",
+			Port:    "5432",
+			SSLMode: "disable",
+		},
+		Risk: Risk{
+			ReloadInterval:      30 * time.Second,
+			ReviewTimeout:       24 * time.Hour,
+			ReviewSweepInterval: time.Minute,
+		},
+		Operators: Operators{
+			TokenKeyID: "1",
+		},
+		Retention: Retention{
+			ArchiveMode:  models.ArchiveModeTable,
+			RefundWindow: 180 * 24 * time.Hour,
+		},
+	}
+}
+
+// Load reads the configuration of the given service and validates it.
+//
+// Settings are taken from the defaults, then the YAML file referenced by CONFIG_FILE, then the
+// environment, where a `.env` file is loaded without overriding variables that are already set.
+// Every variable can also be read from a file by setting the variable name suffixed with `_FILE`.
+func Load(service Service) (*Config, error) {
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: load .env file: %s", ErrInvalidConfig, err)
+	}
+
+	cfg := Default()
+
+	path, _, err := lookupEnv("CONFIG_FILE")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, err)
+	}
+
+	if path != "" {
+		err = cfg.loadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, err)
+		}
+	}
+
+	err = errors.Join(cfg.loadEnv(), cfg.Validate(service))
+	if err != nil {
+		return nil, fmt.Errorf("%w:\n%s", ErrInvalidConfig, err)
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decode config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+type envVar struct {
+	key string
+	set func(value string) error
+}
+
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		{"LOG_LEVEL", stringVar(&c.LogLevel)},
+		{"DEBUG_MODE", boolVar(&c.DebugMode)},
+		{"SHUTDOWN_TIMEOUT", durationVar(&c.ShutdownTimeout)},
+		{"API_PORT", stringVar(&c.API.Port)},
+		{"PLATFORM_MODE", func(value string) error {
+			c.API.PlatformMode = models.APIKeyMode(value)
+			return nil
+		}},
+		{"API_KEY_ROTATION_GRACE_PERIOD", durationVar(&c.API.APIKeyRotationGracePeriod)},
+		{"RATE_LIMIT_WRITES_PER_MINUTE", intVar(&c.API.RateLimit.WritesPerMinute)},
+		{"RATE_LIMIT_READS_PER_MINUTE", intVar(&c.API.RateLimit.ReadsPerMinute)},
+		{"RATE_LIMIT_IP_PER_MINUTE", intVar(&c.API.RateLimit.IPPerMinute)},
+		{"WEBHOOKS_PORT", stringVar(&c.Webhooks.Port)},
+		{"RELAY_PORT", stringVar(&c.Relay.Port)},
+		{"RELAY_SINK", stringVar(&c.Relay.Sink)},
+		{"RELAY_BATCH_SIZE", intVar(&c.Relay.BatchSize)},
+		{"RELAY_POLL_INTERVAL", durationVar(&c.Relay.PollInterval)},
+		{"RELAY_HTTP_URL", stringVar(&c.Relay.HTTPURL)},
+		{"RELAY_NATS_URL", stringVar(&c.Relay.NATSURL)},
+		{"RELAY_NATS_SUBJECT", stringVar(&c.Relay.NATSSubject)},
+		{"DATABASE_HOST", stringVar(&c.Database.Host)},
+		{"DATABASE_PORT", stringVar(&c.Database.Port)},
+		{"DATABASE_USER", stringVar(&c.Database.User)},
+		{"DATABASE_PASSWORD", stringVar(&c.Database.Password)},
+		{"DATABASE_NAME", stringVar(&c.Database.Name)},
+		{"DATABASE_SSLMODE", stringVar(&c.Database.SSLMode)},
+		{"DATABASE_AUTO_MIGRATE", boolVar(&c.Database.AutoMigrate)},
+		{"STRIPE_SECRET_KEY", stringVar(&c.Stripe.SecretKey)},
+		{"STRIPE_WEBHOOK_SECRET_KEY", stringVar(&c.Stripe.WebhookSecretKey)},
+		{"OTEL_TRACES_EXPORTER", stringVar(&c.Tracing.Exporter)},
+		{"PLATFORM_FEE_PERCENTAGE", floatVar(&c.Pricing.Default.Percentage)},
+		{"PLATFORM_FEE_FIXED", intVar(&c.Pricing.Default.Fixed)},
+		{"LIMIT_MAX_CHARGE", intVar(&c.Limits.Default.MaxCharge)},
+		{"LIMIT_DAILY_VOLUME", intVar(&c.Limits.Default.DailyVolume)},
+		{"LIMIT_MONTHLY_VOLUME", intVar(&c.Limits.Default.MonthlyVolume)},
+		{"LIMIT_MAX_REFUND_RATIO", floatVar(&c.Limits.Default.MaxRefundRatio)},
+		{"LIMIT_REFUND_APPROVAL_THRESHOLD", intVar(&c.Limits.Default.RefundApprovalThreshold)},
+		{"RISK_RULES_FILE", stringVar(&c.Risk.RulesFile)},
+		{"RISK_RULES_RELOAD_INTERVAL", durationVar(&c.Risk.ReloadInterval)},
+		{"RISK_REVIEW_TIMEOUT", durationVar(&c.Risk.ReviewTimeout)},
+		{"RISK_REVIEW_SWEEP_INTERVAL", durationVar(&c.Risk.ReviewSweepInterval)},
+		{"OPERATOR_TOKEN_SECRET", stringVar(&c.Operators.TokenSecret)},
+		{"OPERATOR_TOKEN_KEY_ID", stringVar(&c.Operators.TokenKeyID)},
+		{"OPERATOR_TOKEN_PREVIOUS_SECRETS", mapVar(&c.Operators.PreviousTokenSecrets)},
+		{"ENCRYPTION_KEY_FILE", stringVar(&c.Encryption.KeyFile)},
+		{"ENCRYPTION_FIELDS", listVar(&c.Encryption.Fields)},
+		{"ENCRYPTION_INDEXED_FIELDS", listVar(&c.Encryption.IndexedFields)},
+		{"RETENTION_TRANSACTION_MONTHS", intVar(&c.Retention.TransactionMonths)},
+		{"RETENTION_ARCHIVE_MODE", func(value string) error {
+			c.Retention.ArchiveMode = models.ArchiveMode(value)
+			return nil
+		}},
+		{"RETENTION_ARCHIVE_DIR", stringVar(&c.Retention.ArchiveDir)},
+		{"RETENTION_WEBHOOK_PAYLOADS", durationVar(&c.Retention.WebhookPayloads)},
+		{"RETENTION_REFUND_WINDOW", durationVar(&c.Retention.RefundWindow)},
+	}
+}
+
+func (c *Config) loadEnv() error {
+	var errs []error
+
+	for _, variable := range c.envVars() {
+		value, ok, err := lookupEnv(variable.key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		err = variable.set(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", variable.key, err))
+		}
+	}
+
+	if c.Tracing.Exporter == "" {
+		c.Tracing.Exporter = "none"
+
+		if otlpEndpointConfigured() {
+			c.Tracing.Exporter = "otlp"
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the settings required by the given service
+func (c *Config) Validate(service Service) error {
+	var errs []error
+
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if !validLogLevels[strings.ToLower(c.LogLevel)] {
+		invalid("LOG_LEVEL", "must be one of debug, info, warn or error, got %q", c.LogLevel)
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		invalid("SHUTDOWN_TIMEOUT", "must be a positive duration")
+	}
+
+	if !validTracingExporters[c.Tracing.Exporter] {
+		invalid("OTEL_TRACES_EXPORTER", "must be one of otlp, stdout or none, got %q", c.Tracing.Exporter)
+	}
+
+	if c.Database.Host == "" {
+		invalid("DATABASE_HOST", "is required")
+	}
+
+	if !validPort(c.Database.Port) {
+		invalid("DATABASE_PORT", "must be a valid port, got %q", c.Database.Port)
+	}
+
+	if c.Database.User == "" {
+		invalid("DATABASE_USER", "is required")
+	}
+
+	if c.Database.Name == "" {
+		invalid("DATABASE_NAME", "is required")
+	}
+
+	if !validSSLModes[c.Database.SSLMode] {
+		invalid("DATABASE_SSLMODE", "must be a libpq sslmode such as disable, require or verify-full, got %q", c.Database.SSLMode)
+	}
+
+	errs = append(errs, c.Encryption.validate()...)
+	errs = append(errs, c.Retention.validate()...)
+
+	switch service {
+	case ServiceAPI:
+		if !validPort(c.API.Port) {
+			invalid("API_PORT", "must be a valid port, got %q", c.API.Port)
+		}
+
+		if c.API.PlatformMode != models.APIKeyModeTest && c.API.PlatformMode != models.APIKeyModeLive {
+			invalid("PLATFORM_MODE", "must be test or live, got %q", c.API.PlatformMode)
+		}
+
+		if c.API.APIKeyRotationGracePeriod < 0 {
+			invalid("API_KEY_ROTATION_GRACE_PERIOD", "must not be negative")
+		}
+
+		if c.API.RateLimit.WritesPerMinute <= 0 {
+			invalid("RATE_LIMIT_WRITES_PER_MINUTE", "must be a positive integer")
+		}
+
+		if c.API.RateLimit.ReadsPerMinute <= 0 {
+			invalid("RATE_LIMIT_READS_PER_MINUTE", "must be a positive integer")
+		}
+
+		if c.API.RateLimit.IPPerMinute <= 0 {
+			invalid("RATE_LIMIT_IP_PER_MINUTE", "must be a positive integer")
+		}
+
+		if !strings.HasPrefix(c.Stripe.SecretKey, "sk_") && !strings.HasPrefix(c.Stripe.SecretKey, "rk_") {
+			invalid("STRIPE_SECRET_KEY", "must be a Stripe secret or restricted key")
+		}
+
+		errs = append(errs, c.Pricing.validate()...)
+		errs = append(errs, c.Limits.validate()...)
+
+		if c.Risk.ReloadInterval <= 0 {
+			invalid("RISK_RULES_RELOAD_INTERVAL", "must be a positive duration")
+		}
+
+		if c.Risk.ReviewTimeout <= 0 || c.Risk.ReviewTimeout > maxReviewTimeout {
+			invalid("RISK_REVIEW_TIMEOUT", "must be a positive duration of at most %s", maxReviewTimeout)
+		}
+
+		if c.Risk.ReviewSweepInterval <= 0 {
+			invalid("RISK_REVIEW_SWEEP_INTERVAL", "must be a positive duration")
+		}
+
+		errs = append(errs, c.Operators.validate(false)...)
+	case ServiceWebhooks:
+		if !validPort(c.Webhooks.Port) {
+			invalid("WEBHOOKS_PORT", "must be a valid port, got %q", c.Webhooks.Port)
+		}
+
+		if !strings.HasPrefix(c.Stripe.WebhookSecretKey, "whsec_") {
+			invalid("STRIPE_WEBHOOK_SECRET_KEY", "must be a Stripe webhook signing secret")
+		}
+
+		// payout events are linked to the transactions they settled by listing them from Stripe
+		if !strings.HasPrefix(c.Stripe.SecretKey, "sk_") && !strings.HasPrefix(c.Stripe.SecretKey, "rk_") {
+			invalid("STRIPE_SECRET_KEY", "must be a Stripe secret or restricted key")
+		}
+	case ServiceRelay:
+		if !validPort(c.Relay.Port) {
+			invalid("RELAY_PORT", "must be a valid port, got %q", c.Relay.Port)
+		}
+
+		if !validRelaySinks[c.Relay.Sink] {
+			invalid("RELAY_SINK", "must be one of http, nats or stdout, got %q", c.Relay.Sink)
+		}
+
+		if c.Relay.BatchSize <= 0 {
+			invalid("RELAY_BATCH_SIZE", "must be a positive integer")
+		}
+
+		if c.Relay.PollInterval <= 0 {
+			invalid("RELAY_POLL_INTERVAL", "must be a positive duration")
+		}
+
+		if c.Relay.Sink == "http" && !validURL(c.Relay.HTTPURL) {
+			invalid("RELAY_HTTP_URL", "must be an http or https URL when the sink is http")
+		}
+
+		if c.Relay.Sink == "nats" && c.Relay.NATSURL == "" {
+			invalid("RELAY_NATS_URL", "is required when the sink is nats")
+		}
+
+		if c.Relay.Sink == "nats" && c.Relay.NATSSubject == "" {
+			invalid("RELAY_NATS_SUBJECT", "is required when the sink is nats")
+		}
+	case ServicePaymentctl:
+		if !strings.HasPrefix(c.Stripe.SecretKey, "sk_") && !strings.HasPrefix(c.Stripe.SecretKey, "rk_") {
+			invalid("STRIPE_SECRET_KEY", "must be a Stripe secret or restricted key")
+		}
+
+		// every command verifies the token of the operator running it
+		errs = append(errs, c.Operators.validate(true)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate checks the encrypted fields can be encrypted and searched
+func (e Encryption) validate() []error {
+	var errs []error
+
+	encrypted := map[string]bool{}
+
+	for _, field := range e.Fields {
+		if unencryptableFields[field] {
+			errs = append(errs, fmt.Errorf("ENCRYPTION_FIELDS: %s must stay in plaintext, as payouts look transactions up by it", field))
+		}
+
+		encrypted[field] = true
+	}
+
+	if len(e.Fields) > 0 && e.KeyFile == "" {
+		errs = append(errs, errors.New("ENCRYPTION_KEY_FILE: is required when fields are encrypted"))
+	}
+
+	for _, field := range e.IndexedFields {
+		if !encrypted[field] {
+			errs = append(errs, fmt.Errorf("ENCRYPTION_INDEXED_FIELDS: %s must be one of the encrypted fields", field))
+		}
+	}
+
+	return errs
+}
+
+// validate checks the keys signing and verifying operator tokens, which are only required when the service can't run
+// without operators
+func (o Operators) validate(required bool) []error {
+	if o.TokenSecret == "" && !required {
+		return nil
+	}
+
+	var errs []error
+
+	if len(o.TokenSecret) < minOperatorTokenSecretLength {
+		errs = append(errs, fmt.Errorf("OPERATOR_TOKEN_SECRET: must be at least %d characters long", minOperatorTokenSecretLength))
+	}
+
+	if o.TokenKeyID == "" {
+		errs = append(errs, errors.New("OPERATOR_TOKEN_KEY_ID: is required"))
+	}
+
+	for keyID, secret := range o.PreviousTokenSecrets {
+		if keyID == o.TokenKeyID {
+			errs = append(errs, fmt.Errorf("OPERATOR_TOKEN_PREVIOUS_SECRETS: %s is the ID of the active key", keyID))
+		}
+
+		if len(secret) < minOperatorTokenSecretLength {
+			errs = append(errs, fmt.Errorf("OPERATOR_TOKEN_PREVIOUS_SECRETS: %s must be at least %d characters long", keyID, minOperatorTokenSecretLength))
+		}
+	}
+
+	return errs
+}
+
+// validate checks every fee of the pricing, naming the invalid ones after their position in the YAML file
+func (p Pricing) validate() []error {
+	var errs []error
+
+	checkPlan := func(prefix string, plan PricingPlan) {
+		errs = append(errs, plan.Default.validate(prefix+".default")...)
+
+		for currency, fee := range plan.Currencies {
+			errs = append(errs, fee.validate(fmt.Sprintf("%s.currencies.%s", prefix, currency))...)
+		}
+	}
+
+	checkPlan("pricing", p.PricingPlan)
+
+	for merchantID, plan := range p.Merchants {
+		checkPlan("pricing.merchants."+merchantID, plan)
+	}
+
+	return errs
+}
+
+func (f Fee) validate(key string) []error {
+	var errs []error
+
+	if f.Percentage < 0 || f.Percentage >= 100 {
+		errs = append(errs, fmt.Errorf("%s.percentage: must be at least 0 and less than 100, got %v", key, f.Percentage))
+	}
+
+	if f.Fixed < 0 {
+		errs = append(errs, fmt.Errorf("%s.fixed: must not be negative", key))
+	}
+
+	return errs
+}
+
+// validate checks every limit, naming the invalid ones after their position in the YAML file
+func (l Limits) validate() []error {
+	var errs []error
+
+	checkPlan := func(prefix string, plan LimitPlan) {
+		errs = append(errs, plan.Default.validate(prefix+".default")...)
+
+		for currency, limit := range plan.Currencies {
+			errs = append(errs, limit.validate(fmt.Sprintf("%s.currencies.%s", prefix, currency))...)
+		}
+	}
+
+	checkPlan("limits", l.LimitPlan)
+
+	for merchantID, plan := range l.Merchants {
+		checkPlan("limits.merchants."+merchantID, plan)
+	}
+
+	return errs
+}
+
+func (l Limit) validate(key string) []error {
+	var errs []error
+
+	if l.MaxCharge < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_charge: must not be negative", key))
+	}
+
+	if l.DailyVolume < 0 {
+		errs = append(errs, fmt.Errorf("%s.daily_volume: must not be negative", key))
+	}
+
+	if l.MonthlyVolume < 0 {
+		errs = append(errs, fmt.Errorf("%s.monthly_volume: must not be negative", key))
+	}
+
+	if l.MaxRefundRatio < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_refund_ratio: must not be negative, got %v", key, l.MaxRefundRatio))
+	}
+
+	if l.RefundApprovalThreshold < 0 {
+		errs = append(errs, fmt.Errorf("%s.refund_approval_threshold: must not be negative", key))
+	}
+
+	return errs
+}
+
+// ConnectionString returns the PostgreSQL connection URL
+func (d Database) ConnectionString() string {
+	connectionURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     net.JoinHostPort(d.Host, d.Port),
+		Path:     d.Name,
+		RawQuery: url.Values{"sslmode": []string{d.SSLMode}}.Encode(),
+	}
+
+	return connectionURL.String()
+}
+
+// lookupEnv returns the value of the variable, or the content of the file referenced by the variable suffixed
+// with _FILE. Empty variables are considered unset
+func lookupEnv(key string) (string, bool, error) {
+	if value := os.Getenv(key); value != "" {
+		return value, true, nil
+	}
+
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return "", false, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: read secret file: %w", key, err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+func otlpEndpointConfigured() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+func validURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func validPort(port string) bool {
+	parsed, err := strconv.Atoi(port)
+
+	return err == nil && parsed > 0 && parsed <= 65535
+}
+
+func stringVar(target *string) func(string) error {
+	return func(value string) error {
+		*target = value
+		return nil
+	}
+}
+
+func boolVar(target *bool) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", value)
+		}
+
+		*target = parsed
+
+		return nil
+	}
+}
+
+func intVar(target *int) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", value)
+		}
+
+		*target = parsed
+
+		return nil
+	}
+}
+
+func floatVar(target *float64) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %q", value)
+		}
+
+		*target = parsed
+
+		return nil
+	}
+}
+
+func durationVar(target *time.Duration) func(string) error {
+	return func(value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 30s or 24h, got %q", value)
+		}
+
+		*target = parsed
+
+		return nil
+	}
+}
+
+// mapVar reads a comma-separated list of key:value pairs, ignoring blank items. Values may be secrets, so they are
+// never echoed in errors
+func mapVar(target *map[string]string) func(string) error {
+	return func(value string) error {
+		items := map[string]string{}
+
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+
+			key, itemValue, ok := strings.Cut(item, ":")
+			if !ok || strings.TrimSpace(key) == "" {
+				return errors.New("must be a list of key:value pairs")
+			}
+
+			items[strings.TrimSpace(key)] = strings.TrimSpace(itemValue)
+		}
+
+		*target = items
+
+		return nil
+	}
+}
+
+// listVar reads a comma-separated list, ignoring blank items
+func listVar(target *[]string) func(string) error {
+	return func(value string) error {
+		var items []string
+
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+
+		*target = items
+
+		return nil
+	}
+}
+
+// validate checks transactions can be archived and read back wherever they are archived to
+func (r Retention) validate() []error {
+	var errs []error
+
+	if r.TransactionMonths < 0 {
+		errs = append(errs, errors.New("RETENTION_TRANSACTION_MONTHS: must not be negative"))
+	}
+
+	if !r.ArchiveMode.IsValid() {
+		errs = append(errs, fmt.Errorf("RETENTION_ARCHIVE_MODE: must be table or ndjson, got %q", r.ArchiveMode))
+	}
+
+	if r.ArchiveMode == models.ArchiveModeNDJSON && r.ArchiveDir == "" {
+		errs = append(errs, errors.New("RETENTION_ARCHIVE_DIR: is required when transactions are archived as ndjson"))
+	}
+
+	if r.WebhookPayloads < 0 {
+		errs = append(errs, errors.New("RETENTION_WEBHOOK_PAYLOADS: must not be negative"))
+	}
+
+	if r.RefundWindow < 0 {
+		errs = append(errs, errors.New("RETENTION_REFUND_WINDOW: must not be negative"))
+	}
+
+	return errs
+}
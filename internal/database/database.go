@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 )
@@ -12,12 +13,231 @@ var (
 	ErrTransactionNotFound = errors.New("transaction not found")
 	// ErrMultipleRowsAffected error when multiple rows were affeted in an operation
 	ErrMultipleRowsAffected = errors.New("multiple rows affected")
+	// ErrTransactionVersionConflict error when a transaction was modified since it was read
+	ErrTransactionVersionConflict = errors.New("transaction was modified concurrently")
+	// ErrAPIKeyNotFound error when API key was not found
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrAPIKeyRotated error when an API key was already rotated or expired
+	ErrAPIKeyRotated = errors.New("api key already rotated")
+	// ErrWebhookEventNotFound error when a stored webhook event was not found
+	ErrWebhookEventNotFound = errors.New("webhook event not found")
+	// ErrConnectedAccountNotFound error when connected account was not found
+	ErrConnectedAccountNotFound = errors.New("connected account not found")
+	// ErrPayoutNotFound error when payout was not found
+	ErrPayoutNotFound = errors.New("payout not found")
+	// ErrReviewNotFound error when review was not found
+	ErrReviewNotFound = errors.New("review not found")
+	// ErrReviewAlreadyDecided error when a review was decided since it was read
+	ErrReviewAlreadyDecided = errors.New("review already decided")
+	// ErrReviewClaimed error when another reviewer is deciding on the review
+	ErrReviewClaimed = errors.New("review claimed by another reviewer")
+	// ErrBlockListEntryNotFound error when block list entry was not found
+	ErrBlockListEntryNotFound = errors.New("block list entry not found")
+	// ErrBlockListEntryExists error when the value is already in the block list
+	ErrBlockListEntryExists = errors.New("block list entry already exists")
+	// ErrRefundRequestNotFound error when refund request was not found
+	ErrRefundRequestNotFound = errors.New("refund request not found")
+	// ErrRefundRequestAlreadyDecided error when a refund request was decided since it was read
+	ErrRefundRequestAlreadyDecided = errors.New("refund request already decided")
+	// ErrRefundRequestClaimed error when another operator is deciding on the refund request
+	ErrRefundRequestClaimed = errors.New("refund request claimed by another operator")
+	// ErrCustomerNotFound error when no risk decision names the customer
+	ErrCustomerNotFound = errors.New("customer not found")
 )
 
 // Database service to handle database integrations
 type Database interface {
-	InsertTransaction(context.Context, *models.Transaction) error
+	// InsertTransaction stores the transaction along with its transfers, risk decision, review and audit entry, whose
+	// after snapshot is the stored transaction
+	InsertTransaction(ctx context.Context, transaction *models.Transaction, entry *models.AuditEntry) error
 	GetTransaction(ctx context.Context, transactionID string) (*models.Transaction, error)
-	UpdateTransaction(ctx context.Context, transactionID string, updatedTransaction *models.Transaction) (*models.Transaction, error)
+	// UpdateTransaction applies the update only if the stored version matches updatedTransaction.Version, storing
+	// the transfers of updatedTransaction along with it, and the audit entry when given, whose after snapshot is the
+	// updated transaction
+	UpdateTransaction(ctx context.Context, transactionID string, updatedTransaction *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error)
+	ListTransactions(ctx context.Context, filter *models.TransactionFilter) ([]*models.Transaction, error)
+	// StreamTransactions calls fn for every transaction matching the filter without loading all of them in memory,
+	// stopping at the first error returned by fn
+	StreamTransactions(ctx context.Context, filter *models.TransactionFilter, fn func(*models.Transaction) error) error
+	ListAdditionalFieldKeys(ctx context.Context, filter *models.TransactionFilter) ([]string, error)
+	// ForceTransactionStatus overrides the status regardless of its version, recording the audit entry in the same transaction
+	ForceTransactionStatus(ctx context.Context, transactionID string, status models.TransactionStatus, entry *models.AuditEntry) (*models.Transaction, error)
+	// ReencryptTransactions encrypts with the active key the encrypted additional fields of up to limit transactions,
+	// stored or archived in the database, still stored with an older key or in plaintext, returning how many were updated
+	ReencryptTransactions(ctx context.Context, limit int) (int, error)
+	MerchantStore
+	MarketplaceStore
+	PayoutStore
+	RiskStore
+	ReviewStore
+	RefundRequestStore
+	BlockListStore
+	UsageStore
+	WebhookEventStore
+	AuditStore
+	DataSubjectStore
+	RetentionStore
+	Ping(context.Context) error
 	Close()
 }
+
+// MerchantStore service to handle merchant accounts and their API keys
+type MerchantStore interface {
+	// InsertMerchant stores the merchant along with the audit entry
+	InsertMerchant(ctx context.Context, merchant *models.Merchant, entry *models.AuditEntry) error
+	InsertAPIKey(context.Context, *models.APIKey) error
+	GetAPIKey(ctx context.Context, keyID string) (*models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	// RotateAPIKey expires an active API key at expiresAt and inserts its replacement along with the audit entry,
+	// failing when the key was already rotated or expired
+	RotateAPIKey(ctx context.Context, keyID string, expiresAt time.Time, replacement *models.APIKey, entry *models.AuditEntry) error
+}
+
+// MarketplaceStore service to handle the connected accounts of marketplace merchants and the transfers made to them
+type MarketplaceStore interface {
+	// InsertConnectedAccount stores the connected account along with the audit entry
+	InsertConnectedAccount(ctx context.Context, account *models.ConnectedAccount, entry *models.AuditEntry) error
+	GetConnectedAccount(ctx context.Context, accountID string) (*models.ConnectedAccount, error)
+	ListConnectedAccounts(ctx context.Context, merchantID string) ([]*models.ConnectedAccount, error)
+	// ListTransfers fetches the transfers of a transaction, oldest first
+	ListTransfers(ctx context.Context, transactionID string) ([]*models.Transfer, error)
+}
+
+// PayoutStore service to handle the payouts of the platform and the transactions they settled
+type PayoutStore interface {
+	// UpsertPayout stores the payout or updates the stored one with the same provider payout ID, filling payout with
+	// the stored payout ID and timestamps, along with the audit entry whose after snapshot is the stored payout. A
+	// payout in a final status keeps it
+	UpsertPayout(ctx context.Context, payout *models.Payout, entry *models.AuditEntry) error
+	GetPayout(ctx context.Context, payoutID string) (*models.Payout, error)
+	ListPayouts(ctx context.Context, filter *models.PayoutFilter) ([]*models.Payout, error)
+	// LinkPayoutTransactions links the payout to the transactions its items come from, skipping items without a
+	// stored transaction or already linked, and returns the number of items linked
+	LinkPayoutTransactions(ctx context.Context, payoutID string, items []*models.PayoutItem) (int, error)
+	// ListPayoutTransactions fetches the transactions settled by a payout, oldest first
+	ListPayoutTransactions(ctx context.Context, payoutID string) ([]*models.Transaction, error)
+}
+
+// RiskStore service to handle the decisions taken by the risk rules, which are stored along with their transaction
+type RiskStore interface {
+	// CountRiskDecisions counts the decisions taken since the given moment on payments of the merchant with the same
+	// field value
+	CountRiskDecisions(ctx context.Context, merchantID string, field models.RiskField, value string, since time.Time) (int, error)
+}
+
+// ReviewStore service to handle the manual reviews of payments flagged by the risk rules
+type ReviewStore interface {
+	GetReview(ctx context.Context, reviewID string) (*models.Review, error)
+	// ListReviews fetches the reviews matching the filter, oldest first
+	ListReviews(ctx context.Context, filter *models.ReviewFilter) ([]*models.Review, error)
+	// ClaimReview claims a pending review for the reviewer until the given moment, unless another reviewer holds an
+	// unexpired claim on it, so only one decision reaches the payment provider
+	ClaimReview(ctx context.Context, reviewID, reviewer string, until time.Time) error
+	// ReleaseReview gives up the claim of the reviewer on a review left undecided
+	ReleaseReview(ctx context.Context, reviewID, reviewer string) error
+	// DecideReview records the status, reviewer and reason of a pending review claimed by the reviewer along with the
+	// audit entry, and applies
+	// the outcome of the decision to its transaction, along with the transfers of decided. A transaction already moved
+	// out of the review status by its provider keeps its status
+	DecideReview(ctx context.Context, review *models.Review, decided *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error)
+}
+
+// RefundRequestStore service to handle the refunds held until an operator other than their requester approves them
+type RefundRequestStore interface {
+	// InsertRefundRequest stores the request along with the audit entry and moves its transaction to the
+	// refund_requested status, as long as the transaction is still at the expected version
+	InsertRefundRequest(ctx context.Context, request *models.RefundRequest, expectedVersion int, entry *models.AuditEntry) (*models.Transaction, error)
+	GetRefundRequest(ctx context.Context, refundRequestID string) (*models.RefundRequest, error)
+	// ListRefundRequests fetches the requests matching the filter, oldest first
+	ListRefundRequests(ctx context.Context, filter *models.RefundRequestFilter) ([]*models.RefundRequest, error)
+	// ClaimRefundRequest claims a pending request for the operator until the given moment, unless another operator
+	// holds an unexpired claim on it, so only one decision reaches the payment provider
+	ClaimRefundRequest(ctx context.Context, refundRequestID, operator string, until time.Time) error
+	// ReleaseRefundRequest gives up the claim of the operator on a request left undecided
+	ReleaseRefundRequest(ctx context.Context, refundRequestID, operator string) error
+	// DecideRefundRequest records the status and decider of a pending request claimed by the decider along with the
+	// audit entry. Rejected requests restore the previous status of their transaction, unless it already moved out of
+	// refund_requested
+	DecideRefundRequest(ctx context.Context, request *models.RefundRequest, entry *models.AuditEntry) error
+	// CloseRefundRequest closes the pending request of the transaction, whose refund the provider reported as issued,
+	// along with the audit entry. It returns nil when the transaction has no pending request
+	CloseRefundRequest(ctx context.Context, transactionID, closedBy string, entry *models.AuditEntry) (*models.RefundRequest, error)
+}
+
+// BlockListStore service to handle the values payments are rejected for before reaching the payment provider
+type BlockListStore interface {
+	// InsertBlockListEntry stores the block list entry along with the audit entry, failing when its type and value are
+	// already in the block list
+	InsertBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) error
+	GetBlockListEntry(ctx context.Context, entryID string) (*models.BlockListEntry, error)
+	// ListBlockListEntries fetches the entries matching the filter, newest first
+	ListBlockListEntries(ctx context.Context, filter *models.BlockListFilter) ([]*models.BlockListEntry, error)
+	// UpdateBlockListEntry updates the reason and expiration of the block list entry along with the audit entry
+	UpdateBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) (*models.BlockListEntry, error)
+	// DeleteBlockListEntry removes the block list entry along with the audit entry
+	DeleteBlockListEntry(ctx context.Context, entryID string, entry *models.AuditEntry) error
+	// MatchBlockList fetches the entries active at query.At matching any of the attributes of the payment
+	MatchBlockList(ctx context.Context, query *models.BlockListQuery) ([]*models.BlockListEntry, error)
+}
+
+// UsageStore service to keep the running totals of the payments of merchants the limits are enforced against
+type UsageStore interface {
+	// ReserveUsage adds the reservation to the running totals of the merchant and calls check with the resulting usage
+	// in the currency, while no other reservation of the merchant in the currency can change it. The reservation is
+	// discarded when check fails, failing with its error
+	ReserveUsage(ctx context.Context, reservation *models.UsageReservation, check func(*models.Usage) error) (*models.Usage, error)
+	// ReleaseUsage subtracts the reservation from the running totals of the merchant
+	ReleaseUsage(ctx context.Context, reservation *models.UsageReservation) error
+	// ListUsage computes the running totals of the merchant at the given moment, for every currency with payments
+	ListUsage(ctx context.Context, merchantID string, at time.Time) ([]*models.Usage, error)
+}
+
+// WebhookEventStore service to keep the events received from payment providers
+type WebhookEventStore interface {
+	// InsertWebhookEvent stores a verified event, ignoring events that were already received
+	InsertWebhookEvent(context.Context, *models.WebhookEvent) error
+	GetWebhookEvent(ctx context.Context, eventID string) (*models.WebhookEvent, error)
+	// RecordWebhookEventOutcome counts an attempt to process the event, which is marked as processed when processingErr is nil
+	RecordWebhookEventOutcome(ctx context.Context, eventID string, processingErr error) error
+}
+
+// AuditStore service to keep the append-only audit trail of the operations performed by operators, merchants and
+// payment providers
+type AuditStore interface {
+	// InsertAuditEntry appends the entry to the trail, filling its sequence and chaining its hash to the last entry
+	InsertAuditEntry(context.Context, *models.AuditEntry) error
+	// ListAuditEntries fetches the entries matching the filter in the order they were appended
+	ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error)
+}
+
+// DataSubjectStore service to answer the access and erasure requests of paying customers, identified by the customer
+// their risk decisions were evaluated for
+type DataSubjectStore interface {
+	// ExportCustomerData fetches the transactions, risk decisions, block list entries and audit entries of the customer
+	ExportCustomerData(ctx context.Context, customer string) (*models.CustomerData, error)
+	// EraseCustomerData replaces the customer by the erasure ID and erases the personal data of their transactions,
+	// keeping amounts, IDs and provider references, recording the audit entry in the same transaction
+	EraseCustomerData(ctx context.Context, erasure *models.CustomerErasure, entry *models.AuditEntry) error
+}
+
+// RetentionStore service to move settled transactions past their retention out of transactions_history and to prune
+// the payloads of old webhook events
+type RetentionStore interface {
+	// ArchiveTransactions moves up to limit settled transactions created before the given time to the monthly
+	// partitions of transactions_archive, keeping the succeeded charges created since refundableSince, returning how
+	// many were moved
+	ArchiveTransactions(ctx context.Context, before, refundableSince time.Time, limit int) (int, error)
+	// ListArchivableTransactions fetches up to limit settled transactions created before the given time, as stored,
+	// leaving out the succeeded charges created since refundableSince
+	ListArchivableTransactions(ctx context.Context, before, refundableSince time.Time, limit int) ([]*models.Transaction, error)
+	// RemoveArchivedTransactions deletes the transactions written to the archive file, skipping those updated since
+	// they were read, and returns the IDs of the removed transactions
+	RemoveArchivedTransactions(ctx context.Context, file string, transactions []*models.Transaction) ([]string, error)
+	// GetArchiveFile returns the archive file a transaction was exported to
+	GetArchiveFile(ctx context.Context, transactionID string) (string, error)
+	// LockArchiveFile runs fn holding the lock of the archive file, so runs appending to it and erasures rewriting it
+	// never overlap, even across processes
+	LockArchiveFile(ctx context.Context, file string, fn func() error) error
+	// PruneWebhookEventPayloads drops the payloads of the processed webhook events received before the given time
+	PruneWebhookEventPayloads(ctx context.Context, before time.Time) (int, error)
+}
@@ -8,4 +8,4 @@ CREATE TABLE IF NOT EXISTS transactions_history (
   currency CHAR(3) NOT NULL,
   type VARCHAR(10) NOT NULL,
   additional_fields JSONB
-);
\ No newline at end of file
+);
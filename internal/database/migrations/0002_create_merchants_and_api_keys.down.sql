@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS transactions_history_merchant_id_idx;
+
+ALTER TABLE transactions_history DROP COLUMN IF EXISTS merchant_id;
+
+DROP TABLE IF EXISTS api_keys;
+
+DROP TABLE IF EXISTS merchants;
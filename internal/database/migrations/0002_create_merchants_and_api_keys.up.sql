@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS merchants (
+  merchant_id VARCHAR PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  key_id VARCHAR PRIMARY KEY,
+  merchant_id VARCHAR NOT NULL REFERENCES merchants(merchant_id),
+  type VARCHAR(20) NOT NULL,
+  mode VARCHAR(10) NOT NULL,
+  prefix VARCHAR(20) NOT NULL,
+  hash CHAR(64) NOT NULL UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMPTZ
+);
+
+ALTER TABLE transactions_history ADD COLUMN IF NOT EXISTS merchant_id VARCHAR REFERENCES merchants(merchant_id);
+
+CREATE INDEX IF NOT EXISTS transactions_history_merchant_id_idx ON transactions_history(merchant_id);
@@ -0,0 +1 @@
+ALTER TABLE transactions_history DROP COLUMN IF EXISTS version;
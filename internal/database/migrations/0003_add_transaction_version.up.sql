@@ -0,0 +1 @@
+ALTER TABLE transactions_history ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS outbox (
+  id BIGSERIAL PRIMARY KEY,
+  event_id VARCHAR NOT NULL UNIQUE,
+  event_type VARCHAR(50) NOT NULL,
+  aggregate_type VARCHAR(50) NOT NULL,
+  aggregate_id VARCHAR NOT NULL,
+  payload JSONB NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  published_at TIMESTAMPTZ,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error VARCHAR
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox(aggregate_type, aggregate_id, id) WHERE published_at IS NULL;
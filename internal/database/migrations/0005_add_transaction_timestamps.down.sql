@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS transactions_history_created_at_idx;
+
+ALTER TABLE transactions_history DROP COLUMN IF EXISTS updated_at;
+
+ALTER TABLE transactions_history DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,5 @@
+ALTER TABLE transactions_history ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+ALTER TABLE transactions_history ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS transactions_history_created_at_idx ON transactions_history(created_at DESC, transaction_id DESC);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS webhook_events (
+  event_id VARCHAR PRIMARY KEY,
+  provider VARCHAR(20) NOT NULL,
+  type VARCHAR(100) NOT NULL,
+  payload JSONB NOT NULL,
+  received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  processed_at TIMESTAMPTZ,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error VARCHAR
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+  audit_id VARCHAR PRIMARY KEY,
+  actor VARCHAR(100) NOT NULL,
+  action VARCHAR(50) NOT NULL,
+  resource_type VARCHAR(50) NOT NULL,
+  resource_id VARCHAR NOT NULL,
+  reason VARCHAR,
+  details JSONB,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_resource_idx ON audit_log(resource_type, resource_id, created_at);
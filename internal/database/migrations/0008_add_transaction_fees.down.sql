@@ -0,0 +1,7 @@
+ALTER TABLE transactions_history DROP COLUMN IF EXISTS fee_currency;
+
+ALTER TABLE transactions_history DROP COLUMN IF EXISTS net_amount;
+
+ALTER TABLE transactions_history DROP COLUMN IF EXISTS platform_fee;
+
+ALTER TABLE transactions_history DROP COLUMN IF EXISTS provider_fee;
@@ -0,0 +1,9 @@
+ALTER TABLE transactions_history ADD COLUMN IF NOT EXISTS provider_fee NUMERIC NOT NULL DEFAULT 0;
+
+ALTER TABLE transactions_history ADD COLUMN IF NOT EXISTS platform_fee NUMERIC NOT NULL DEFAULT 0;
+
+ALTER TABLE transactions_history ADD COLUMN IF NOT EXISTS net_amount NUMERIC NOT NULL DEFAULT 0;
+
+ALTER TABLE transactions_history ADD COLUMN IF NOT EXISTS fee_currency CHAR(3);
+
+UPDATE transactions_history SET net_amount = amount WHERE status = 'succeeded';
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS connected_accounts (
+  account_id VARCHAR PRIMARY KEY,
+  merchant_id VARCHAR NOT NULL REFERENCES merchants(merchant_id),
+  name VARCHAR(100) NOT NULL,
+  payment_provider VARCHAR(20) NOT NULL,
+  provider_account_id VARCHAR NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (merchant_id, payment_provider, provider_account_id)
+);
+
+CREATE TABLE IF NOT EXISTS transfers (
+  transfer_id VARCHAR PRIMARY KEY,
+  transaction_id VARCHAR NOT NULL REFERENCES transactions_history(transaction_id),
+  account_id VARCHAR NOT NULL REFERENCES connected_accounts(account_id),
+  type VARCHAR(20) NOT NULL,
+  amount NUMERIC NOT NULL,
+  currency CHAR(3) NOT NULL,
+  provider_transfer_id VARCHAR,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS transfers_transaction_id_idx ON transfers(transaction_id, created_at);
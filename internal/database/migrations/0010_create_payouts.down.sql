@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS payout_transactions;
+
+DROP TABLE IF EXISTS payouts;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS payouts (
+  payout_id VARCHAR PRIMARY KEY,
+  payment_provider VARCHAR(20) NOT NULL,
+  provider_payout_id VARCHAR NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  amount NUMERIC NOT NULL,
+  currency CHAR(3) NOT NULL,
+  failure_reason VARCHAR(100),
+  arrival_date TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (payment_provider, provider_payout_id)
+);
+
+CREATE INDEX IF NOT EXISTS payouts_created_at_idx ON payouts(created_at DESC, payout_id DESC);
+
+CREATE TABLE IF NOT EXISTS payout_transactions (
+  provider_balance_transaction_id VARCHAR PRIMARY KEY,
+  payout_id VARCHAR NOT NULL REFERENCES payouts(payout_id),
+  transaction_id VARCHAR NOT NULL REFERENCES transactions_history(transaction_id),
+  amount NUMERIC NOT NULL,
+  fee NUMERIC NOT NULL,
+  net NUMERIC NOT NULL,
+  currency CHAR(3) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS payout_transactions_payout_id_idx ON payout_transactions(payout_id);
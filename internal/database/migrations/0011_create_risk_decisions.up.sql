@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS risk_decisions (
+  decision_id VARCHAR PRIMARY KEY,
+  transaction_id VARCHAR NOT NULL REFERENCES transactions_history(transaction_id),
+  merchant_id VARCHAR,
+  outcome VARCHAR(10) NOT NULL,
+  rules JSONB NOT NULL,
+  payment_method VARCHAR NOT NULL,
+  customer VARCHAR,
+  ip_address VARCHAR(45),
+  country CHAR(2),
+  amount NUMERIC NOT NULL,
+  currency CHAR(3) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS risk_decisions_transaction_id_idx ON risk_decisions(transaction_id);
+
+CREATE INDEX IF NOT EXISTS risk_decisions_payment_method_idx ON risk_decisions(payment_method, created_at);
+
+CREATE INDEX IF NOT EXISTS risk_decisions_customer_idx ON risk_decisions(customer, created_at) WHERE customer IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS risk_decisions_ip_address_idx ON risk_decisions(ip_address, created_at) WHERE ip_address IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS risk_decisions_country_idx ON risk_decisions(country, created_at) WHERE country IS NOT NULL;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS reviews (
+  review_id VARCHAR PRIMARY KEY,
+  transaction_id VARCHAR NOT NULL UNIQUE REFERENCES transactions_history(transaction_id),
+  merchant_id VARCHAR,
+  status VARCHAR(20) NOT NULL,
+  amount NUMERIC NOT NULL,
+  currency CHAR(3) NOT NULL,
+  rules JSONB NOT NULL,
+  reviewer VARCHAR,
+  reason VARCHAR,
+  expires_at TIMESTAMPTZ NOT NULL,
+  decided_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS reviews_pending_idx ON reviews(expires_at) WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS reviews_status_idx ON reviews(status, created_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS block_list (
+  entry_id VARCHAR PRIMARY KEY,
+  type VARCHAR(20) NOT NULL,
+  value VARCHAR NOT NULL,
+  reason VARCHAR NOT NULL,
+  expires_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (type, value)
+);
+
+CREATE INDEX IF NOT EXISTS block_list_expires_at_idx ON block_list(expires_at);
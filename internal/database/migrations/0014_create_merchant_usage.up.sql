@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS merchant_usage (
+  merchant_id VARCHAR NOT NULL,
+  currency CHAR(3) NOT NULL,
+  day DATE NOT NULL,
+  charged_volume BIGINT NOT NULL DEFAULT 0,
+  refunded_volume BIGINT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (merchant_id, currency, day)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS refund_requests (
+  refund_request_id VARCHAR PRIMARY KEY,
+  transaction_id VARCHAR NOT NULL REFERENCES transactions_history(transaction_id),
+  merchant_id VARCHAR,
+  status VARCHAR(20) NOT NULL,
+  amount NUMERIC NOT NULL,
+  currency CHAR(3) NOT NULL,
+  previous_status VARCHAR(20) NOT NULL,
+  requested_by VARCHAR NOT NULL,
+  reason VARCHAR,
+  decided_by VARCHAR,
+  rejection_reason VARCHAR,
+  decided_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS refund_requests_pending_idx ON refund_requests(transaction_id) WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS refund_requests_status_idx ON refund_requests(status, created_at);
@@ -0,0 +1,25 @@
+DROP TRIGGER IF EXISTS audit_log_append_only_truncate ON audit_log;
+
+DROP TRIGGER IF EXISTS audit_log_append_only_rows ON audit_log;
+
+DROP FUNCTION IF EXISTS audit_log_append_only();
+
+DROP INDEX IF EXISTS audit_log_created_at_idx;
+
+DROP INDEX IF EXISTS audit_log_actor_idx;
+
+DROP INDEX IF EXISTS audit_log_sequence_idx;
+
+ALTER TABLE audit_log DROP COLUMN IF EXISTS hash;
+
+ALTER TABLE audit_log DROP COLUMN IF EXISTS previous_hash;
+
+ALTER TABLE audit_log DROP COLUMN IF EXISTS after;
+
+ALTER TABLE audit_log DROP COLUMN IF EXISTS before;
+
+ALTER TABLE audit_log DROP COLUMN IF EXISTS request_fingerprint;
+
+ALTER TABLE audit_log DROP COLUMN IF EXISTS actor_type;
+
+ALTER TABLE audit_log DROP COLUMN IF EXISTS sequence;
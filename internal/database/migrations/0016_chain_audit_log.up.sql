@@ -0,0 +1,39 @@
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS sequence BIGINT;
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS actor_type VARCHAR(20) NOT NULL DEFAULT 'operator';
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS request_fingerprint VARCHAR;
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS before JSONB;
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS after JSONB;
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS previous_hash VARCHAR(64);
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS hash VARCHAR(64);
+
+-- entries written before the chain existed are numbered in the order they were created and carry no hash
+UPDATE audit_log
+SET sequence = numbered.sequence
+FROM (SELECT audit_id, ROW_NUMBER() OVER (ORDER BY created_at, audit_id) AS sequence FROM audit_log) numbered
+WHERE audit_log.audit_id = numbered.audit_id;
+
+ALTER TABLE audit_log ALTER COLUMN sequence SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS audit_log_sequence_idx ON audit_log(sequence);
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log(actor, created_at);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log(created_at);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only_rows BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_append_only_truncate BEFORE TRUNCATE ON audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
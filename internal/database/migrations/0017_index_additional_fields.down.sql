@@ -0,0 +1 @@
+DROP INDEX IF EXISTS transactions_history_additional_fields_idx;
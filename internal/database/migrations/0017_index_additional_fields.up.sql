@@ -0,0 +1,2 @@
+-- encrypted fields are searched by the blind index of their envelope, matched by containment
+CREATE INDEX IF NOT EXISTS transactions_history_additional_fields_idx ON transactions_history USING GIN (additional_fields jsonb_path_ops);
@@ -0,0 +1,58 @@
+UPDATE webhook_events SET payload = '{}'::jsonb WHERE payload IS NULL;
+
+ALTER TABLE webhook_events ALTER COLUMN payload SET NOT NULL;
+
+INSERT INTO transactions_history(
+  transaction_id,
+  merchant_id,
+  status,
+  failure_reason,
+  payment_provider,
+  description,
+  amount,
+  currency,
+  type,
+  additional_fields,
+  provider_fee,
+  platform_fee,
+  net_amount,
+  fee_currency,
+  version,
+  created_at,
+  updated_at
+)
+SELECT
+  transaction_id,
+  merchant_id,
+  status,
+  failure_reason,
+  payment_provider,
+  description,
+  amount,
+  currency,
+  type,
+  additional_fields,
+  provider_fee,
+  platform_fee,
+  net_amount,
+  fee_currency,
+  version,
+  created_at,
+  updated_at
+FROM transactions_archive
+ON CONFLICT (transaction_id) DO NOTHING;
+
+DROP TABLE IF EXISTS transaction_archive_files;
+
+DROP TABLE IF EXISTS transactions_archive;
+
+-- transactions archived to files are not restored, so existing rows are not checked against the restored keys
+ALTER TABLE refund_requests ADD CONSTRAINT refund_requests_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions_history(transaction_id) NOT VALID;
+
+ALTER TABLE reviews ADD CONSTRAINT reviews_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions_history(transaction_id) NOT VALID;
+
+ALTER TABLE risk_decisions ADD CONSTRAINT risk_decisions_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions_history(transaction_id) NOT VALID;
+
+ALTER TABLE payout_transactions ADD CONSTRAINT payout_transactions_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions_history(transaction_id) NOT VALID;
+
+ALTER TABLE transfers ADD CONSTRAINT transfers_transaction_id_fkey FOREIGN KEY (transaction_id) REFERENCES transactions_history(transaction_id) NOT VALID;
@@ -0,0 +1,45 @@
+-- archived transactions are moved out of transactions_history, so the rows referencing them can no longer be checked
+ALTER TABLE transfers DROP CONSTRAINT IF EXISTS transfers_transaction_id_fkey;
+
+ALTER TABLE payout_transactions DROP CONSTRAINT IF EXISTS payout_transactions_transaction_id_fkey;
+
+ALTER TABLE risk_decisions DROP CONSTRAINT IF EXISTS risk_decisions_transaction_id_fkey;
+
+ALTER TABLE reviews DROP CONSTRAINT IF EXISTS reviews_transaction_id_fkey;
+
+ALTER TABLE refund_requests DROP CONSTRAINT IF EXISTS refund_requests_transaction_id_fkey;
+
+-- monthly partitions, named transactions_archive_YYYY_MM, are created as transactions of the month are archived
+CREATE TABLE IF NOT EXISTS transactions_archive (
+  transaction_id VARCHAR NOT NULL,
+  merchant_id VARCHAR,
+  status VARCHAR(20) NOT NULL,
+  failure_reason VARCHAR(50),
+  payment_provider VARCHAR(20) NOT NULL,
+  description VARCHAR(100) NOT NULL,
+  amount NUMERIC NOT NULL,
+  currency CHAR(3) NOT NULL,
+  type VARCHAR(10) NOT NULL,
+  additional_fields JSONB,
+  provider_fee NUMERIC NOT NULL DEFAULT 0,
+  platform_fee NUMERIC NOT NULL DEFAULT 0,
+  net_amount NUMERIC NOT NULL DEFAULT 0,
+  fee_currency CHAR(3),
+  version INTEGER NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (transaction_id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE INDEX IF NOT EXISTS transactions_archive_transaction_id_idx ON transactions_archive(transaction_id);
+
+-- compressed NDJSON file holding each transaction archived outside the database
+CREATE TABLE IF NOT EXISTS transaction_archive_files (
+  transaction_id VARCHAR PRIMARY KEY,
+  file VARCHAR NOT NULL,
+  archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- payloads of processed webhook events are dropped once they're past retention
+ALTER TABLE webhook_events ALTER COLUMN payload DROP NOT NULL;
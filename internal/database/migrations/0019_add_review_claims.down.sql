@@ -0,0 +1,18 @@
+INSERT INTO transfers(transfer_id, transaction_id, account_id, type, amount, currency, created_at)
+SELECT
+  transfer->>'transfer_id',
+  transfer->>'transaction_id',
+  transfer->>'account_id',
+  transfer->>'type',
+  (transfer->>'amount')::NUMERIC,
+  transfer->>'currency',
+  (transfer->>'created_at')::TIMESTAMPTZ
+FROM reviews
+WHERE transfer IS NOT NULL AND status = 'pending'
+ON CONFLICT (transfer_id) DO NOTHING;
+
+ALTER TABLE reviews DROP COLUMN IF EXISTS transfer;
+
+ALTER TABLE reviews DROP COLUMN IF EXISTS claimed_until;
+
+ALTER TABLE reviews DROP COLUMN IF EXISTS claimed_by;
@@ -0,0 +1,25 @@
+-- a reviewer claims a pending review before the provider captures or cancels its payment, so concurrent decisions
+-- never reach the provider twice. The claim lapses once claimed_until passes, in case the decision never completes
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS claimed_by VARCHAR;
+
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMPTZ;
+
+-- the transfer to the connected account of a destination charge under review, only recorded once it's captured
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS transfer JSONB;
+
+UPDATE reviews
+SET transfer = jsonb_build_object(
+  'transfer_id', transfers.transfer_id,
+  'transaction_id', transfers.transaction_id,
+  'account_id', transfers.account_id,
+  'type', transfers.type,
+  'amount', transfers.amount,
+  'currency', transfers.currency,
+  'created_at', transfers.created_at
+)
+FROM transfers
+WHERE transfers.transaction_id = reviews.transaction_id AND transfers.type = 'transfer' AND reviews.status = 'pending';
+
+DELETE FROM transfers
+USING reviews
+WHERE transfers.transaction_id = reviews.transaction_id AND transfers.type = 'transfer' AND reviews.status = 'pending';
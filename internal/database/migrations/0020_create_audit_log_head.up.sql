@@ -0,0 +1,12 @@
+-- single row holding the sequence and hash of the last entry of the audit trail. Writers lock it to chain their entry,
+-- so entries are chained in the order they are committed without locking audit_log itself
+CREATE TABLE IF NOT EXISTS audit_log_head (
+  id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+  sequence BIGINT NOT NULL,
+  hash VARCHAR(64)
+);
+
+INSERT INTO audit_log_head(id, sequence, hash)
+SELECT TRUE, COALESCE(MAX(sequence), 0), (SELECT hash FROM audit_log ORDER BY sequence DESC LIMIT 1)
+FROM audit_log
+ON CONFLICT (id) DO NOTHING;
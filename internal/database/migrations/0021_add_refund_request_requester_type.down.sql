@@ -0,0 +1 @@
+ALTER TABLE refund_requests DROP COLUMN IF EXISTS requester_type;
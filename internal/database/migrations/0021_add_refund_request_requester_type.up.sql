@@ -0,0 +1,5 @@
+-- kind of actor who requested the refund, as operator names and API key IDs share requested_by
+ALTER TABLE refund_requests ADD COLUMN IF NOT EXISTS requester_type VARCHAR(20) NOT NULL DEFAULT 'operator';
+
+-- refunds requested through the API recorded the merchant ID of the key, which no operator can be mistaken for
+UPDATE refund_requests SET requester_type = 'api_key' WHERE requested_by = merchant_id;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS webhook_events_transaction_id_idx;
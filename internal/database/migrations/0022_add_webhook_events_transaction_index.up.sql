@@ -0,0 +1,2 @@
+-- customer data requests look the webhook events of their transactions up by the metadata of the event object
+CREATE INDEX IF NOT EXISTS webhook_events_transaction_id_idx ON webhook_events ((payload->'data'->'object'->'metadata'->>'transaction_id'));
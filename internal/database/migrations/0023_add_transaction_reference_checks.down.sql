@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS transactions_history_archived_check ON transactions_history;
+
+DROP TRIGGER IF EXISTS refund_requests_transaction_id_check ON refund_requests;
+
+DROP TRIGGER IF EXISTS reviews_transaction_id_check ON reviews;
+
+DROP TRIGGER IF EXISTS risk_decisions_transaction_id_check ON risk_decisions;
+
+DROP TRIGGER IF EXISTS payout_transactions_transaction_id_check ON payout_transactions;
+
+DROP TRIGGER IF EXISTS transfers_transaction_id_check ON transfers;
+
+DROP FUNCTION IF EXISTS check_transaction_archived();
+
+DROP FUNCTION IF EXISTS check_transaction_reference();
+
+DROP FUNCTION IF EXISTS transaction_exists(VARCHAR);
@@ -0,0 +1,50 @@
+-- the foreign keys on transaction_id were dropped when transactions started being archived, as archived transactions
+-- are moved out of transactions_history. These checks replace them: every reference must name a transaction that is
+-- stored in transactions_history, in transactions_archive or in an archive file, and a transaction can only leave
+-- transactions_history by being archived, so references are never orphaned. They run at commit, once a transaction
+-- being archived was recorded in its new place
+CREATE OR REPLACE FUNCTION transaction_exists(id VARCHAR) RETURNS BOOLEAN AS $$
+  SELECT EXISTS (SELECT 1 FROM transactions_history WHERE transaction_id = id)
+  OR EXISTS (SELECT 1 FROM transactions_archive WHERE transaction_id = id)
+  OR EXISTS (SELECT 1 FROM transaction_archive_files WHERE transaction_id = id)
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION check_transaction_reference() RETURNS TRIGGER AS $$
+BEGIN
+  IF NOT transaction_exists(NEW.transaction_id) THEN
+    RAISE EXCEPTION 'transaction % referenced by % does not exist', NEW.transaction_id, TG_TABLE_NAME
+      USING ERRCODE = 'foreign_key_violation';
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION check_transaction_archived() RETURNS TRIGGER AS $$
+BEGIN
+  IF NOT transaction_exists(OLD.transaction_id) THEN
+    RAISE EXCEPTION 'transaction % removed without being archived', OLD.transaction_id
+      USING ERRCODE = 'foreign_key_violation';
+  END IF;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER transfers_transaction_id_check AFTER INSERT OR UPDATE OF transaction_id ON transfers
+DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION check_transaction_reference();
+
+CREATE CONSTRAINT TRIGGER payout_transactions_transaction_id_check AFTER INSERT OR UPDATE OF transaction_id ON payout_transactions
+DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION check_transaction_reference();
+
+CREATE CONSTRAINT TRIGGER risk_decisions_transaction_id_check AFTER INSERT OR UPDATE OF transaction_id ON risk_decisions
+DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION check_transaction_reference();
+
+CREATE CONSTRAINT TRIGGER reviews_transaction_id_check AFTER INSERT OR UPDATE OF transaction_id ON reviews
+DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION check_transaction_reference();
+
+CREATE CONSTRAINT TRIGGER refund_requests_transaction_id_check AFTER INSERT OR UPDATE OF transaction_id ON refund_requests
+DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION check_transaction_reference();
+
+CREATE CONSTRAINT TRIGGER transactions_history_archived_check AFTER DELETE ON transactions_history
+DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION check_transaction_archived();
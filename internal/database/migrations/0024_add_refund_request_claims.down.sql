@@ -0,0 +1,3 @@
+ALTER TABLE refund_requests DROP COLUMN IF EXISTS claimed_until;
+
+ALTER TABLE refund_requests DROP COLUMN IF EXISTS claimed_by;
@@ -0,0 +1,5 @@
+-- an operator claims a pending refund request before the provider issues its refund, so concurrent decisions never
+-- reach the provider twice. The claim lapses once claimed_until passes, in case the decision never completes
+ALTER TABLE refund_requests ADD COLUMN IF NOT EXISTS claimed_by VARCHAR;
+
+ALTER TABLE refund_requests ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+-- the customers as they were stored before being normalized aren't kept, so they can't be restored
+SELECT 1;
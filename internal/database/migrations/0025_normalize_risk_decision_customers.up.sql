@@ -0,0 +1,3 @@
+-- risk decisions stored the customer lowercased but untrimmed, so customers with surrounding spaces could neither be
+-- exported nor erased. They are stored as models.NormalizeCustomer returns them from now on
+UPDATE risk_decisions SET customer = lower(btrim(customer)) WHERE customer <> lower(btrim(customer));
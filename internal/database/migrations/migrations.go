@@ -0,0 +1,395 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockID key of the advisory lock held while migrating, so services starting together don't migrate concurrently
+const lockID int64 = 7_264_918_305_117
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var (
+	// ErrInvalidMigration error when a migration file is malformed or misses its up or down script
+	ErrInvalidMigration = errors.New("invalid migration")
+	// ErrUnknownVersion error when the requested version does not match any migration
+	ErrUnknownVersion = errors.New("unknown migration version")
+	// ErrUnknownAppliedVersion error when the database has a migration applied that this build doesn't know about
+	ErrUnknownAppliedVersion = errors.New("database has an unknown migration applied")
+)
+
+// Migration versioned schema change with the scripts to apply and revert it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status migration along with the moment it was applied, which is nil while pending
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Conn database connection used to migrate. The advisory lock belongs to the session, so every
+// statement must run on the same connection
+type Conn interface {
+	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
+	Begin(context.Context) (pgx.Tx, error)
+}
+
+// Migrator applies and reverts the schema migrations
+type Migrator interface {
+	Status(ctx context.Context) ([]Status, error)
+	Up(ctx context.Context) error
+	Down(ctx context.Context, steps int) error
+	To(ctx context.Context, version int64) error
+}
+
+type migrator struct {
+	conn       Conn
+	migrations []Migration
+}
+
+// New constructor of the migrator for the migrations embedded in the binary
+func New(conn Conn) (Migrator, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithMigrations(conn, migrations), nil
+}
+
+// NewWithMigrations constructor of the migrator for the given migrations, sorted by version
+func NewWithMigrations(conn Conn, migrations []Migration) Migrator {
+	return migrator{
+		conn:       conn,
+		migrations: migrations,
+	}
+}
+
+// Up applies every pending migration using a connection acquired from the pool
+func Up(ctx context.Context, pool *pgxpool.Pool) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+
+	defer conn.Release()
+
+	migrator, err := New(conn)
+	if err != nil {
+		return err
+	}
+
+	return migrator.Up(ctx)
+}
+
+// Load reads the migrations found in fsys, named `<version>_<name>.up.sql` and `<version>_<name>.down.sql`
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("%w: unexpected file name %s", ErrInvalidMigration, entry.Name())
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: invalid version in %s", ErrInvalidMigration, entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("%w: version %d is used by %s and %s", ErrInvalidMigration, version, migration.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("%w: version %d must have both up and down scripts", ErrInvalidMigration, migration.Version)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Status lists every known migration and when it was applied
+func (m migrator) Status(ctx context.Context) ([]Status, error) {
+	err := m.createTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		status := Status{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Up applies every pending migration
+func (m migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+
+	return m.To(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down reverts the given number of applied migrations, most recent first
+func (m migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(applied map[int64]time.Time) error {
+		versions := m.appliedVersions(applied)
+
+		target := int64(0)
+		if steps < len(versions) {
+			target = versions[len(versions)-1-steps]
+		}
+
+		return m.migrate(ctx, applied, target)
+	})
+}
+
+// To applies or reverts migrations until the given version is the latest applied, where 0 reverts all of them
+func (m migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(applied map[int64]time.Time) error {
+		return m.migrate(ctx, applied, version)
+	})
+}
+
+func (m migrator) migrate(ctx context.Context, applied map[int64]time.Time, target int64) error {
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > target {
+			continue
+		}
+
+		err := m.apply(ctx, migration, migration.Up, `INSERT INTO schema_migrations(version, name) VALUES($1, $2)`, migration.Version, migration.Name)
+		if err != nil {
+			return err
+		}
+
+		slog.InfoContext(ctx, "migration applied", slog.Int64("version", migration.Version), slog.String("name", migration.Name))
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= target {
+			continue
+		}
+
+		err := m.apply(ctx, migration, migration.Down, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+		if err != nil {
+			return err
+		}
+
+		slog.InfoContext(ctx, "migration reverted", slog.Int64("version", migration.Version), slog.String("name", migration.Name))
+	}
+
+	return nil
+}
+
+// apply runs a migration script and records it in the same transaction
+func (m migrator) apply(ctx context.Context, migration Migration, script, record string, args ...interface{}) error {
+	tx, err := m.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin migration %d: %w", migration.Version, err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, script)
+	if err != nil {
+		return fmt.Errorf("run migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = tx.Exec(ctx, record, args...)
+	if err != nil {
+		return fmt.Errorf("record migration %d: %w", migration.Version, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("commit migration %d: %w", migration.Version, err)
+	}
+
+	return nil
+}
+
+// withLock holds the advisory lock while running fn with the migrations applied so far
+func (m migrator) withLock(ctx context.Context, fn func(applied map[int64]time.Time) error) error {
+	_, err := m.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		_, err := m.conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID)
+		if err != nil {
+			slog.WarnContext(ctx, "release migration lock failed", slog.Any("error", err))
+		}
+	}()
+
+	err = m.createTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for version := range applied {
+		if m.find(version) == nil {
+			return fmt.Errorf("%w: %d", ErrUnknownAppliedVersion, version)
+		}
+	}
+
+	return fn(applied)
+}
+
+func (m migrator) createTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`
+
+	_, err := m.conn.Exec(ctx, query)
+	if err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
+	}
+
+	return nil
+}
+
+func (m migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := m.conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query applied migrations: %w", err)
+	}
+
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan applied migration: %w", err)
+		}
+
+		applied[version] = appliedAt
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("query applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+func (m migrator) appliedVersions(applied map[int64]time.Time) []int64 {
+	versions := make([]int64, 0, len(applied))
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			versions = append(versions, migration.Version)
+		}
+	}
+
+	return versions
+}
+
+func (m migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+
+	return nil
+}
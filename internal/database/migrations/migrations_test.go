@@ -0,0 +1,183 @@
+package migrations
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_payments", Up: "CREATE TABLE payments()", Down: "DROP TABLE payments"},
+	{Version: 2, Name: "create_refunds", Up: "CREATE TABLE refunds()", Down: "DROP TABLE refunds"},
+	{Version: 3, Name: "create_payouts", Up: "CREATE TABLE payouts()", Down: "DROP TABLE payouts"},
+}
+
+func expectLocked(mock pgxmock.PgxConnIface, applied ...int64) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(lockID).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+
+	rows := mock.NewRows([]string{"version", "applied_at"})
+	for _, version := range applied {
+		rows.AddRow(version, time.Now())
+	}
+
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(rows)
+}
+
+func expectApply(mock pgxmock.PgxConnIface, migration Migration) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(migration.Up)).WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(migration.Version, migration.Name).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+}
+
+func expectRevert(mock pgxmock.PgxConnIface, migration Migration) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(migration.Down)).WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(migration.Version).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+}
+
+func expectUnlocked(mock pgxmock.PgxConnIface) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(lockID).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	c := require.New(t)
+
+	migrations, err := Load(files)
+	c.NoError(err)
+	c.NotEmpty(migrations)
+
+	for i, migration := range migrations {
+		c.Equal(int64(i+1), migration.Version)
+		c.NotEmpty(migration.Up)
+		c.NotEmpty(migration.Down)
+	}
+}
+
+func TestLoadMissingDownScript(t *testing.T) {
+	c := require.New(t)
+
+	_, err := Load(fstest.MapFS{
+		"0001_create_payments.up.sql": {Data: []byte("CREATE TABLE payments()")},
+	})
+	c.ErrorIs(err, ErrInvalidMigration)
+}
+
+func TestLoadUnexpectedFileName(t *testing.T) {
+	c := require.New(t)
+
+	_, err := Load(fstest.MapFS{
+		"create_payments.sql": {Data: []byte("CREATE TABLE payments()")},
+	})
+	c.ErrorIs(err, ErrInvalidMigration)
+}
+
+func TestUp(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewConn()
+	c.NoError(err)
+
+	defer mock.Close(context.Background())
+
+	expectLocked(mock, 1)
+	expectApply(mock, testMigrations[1])
+	expectApply(mock, testMigrations[2])
+	expectUnlocked(mock)
+
+	err = NewWithMigrations(mock, testMigrations).Up(context.Background())
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewConn()
+	c.NoError(err)
+
+	defer mock.Close(context.Background())
+
+	expectLocked(mock, 1, 2, 3)
+	expectRevert(mock, testMigrations[2])
+	expectRevert(mock, testMigrations[1])
+	expectUnlocked(mock)
+
+	err = NewWithMigrations(mock, testMigrations).Down(context.Background(), 2)
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestToUnknownVersion(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewConn()
+	c.NoError(err)
+
+	defer mock.Close(context.Background())
+
+	err = NewWithMigrations(mock, testMigrations).To(context.Background(), 7)
+	c.ErrorIs(err, ErrUnknownVersion)
+}
+
+func TestUpUnknownAppliedVersion(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewConn()
+	c.NoError(err)
+
+	defer mock.Close(context.Background())
+
+	expectLocked(mock, 1, 4)
+	expectUnlocked(mock)
+
+	err = NewWithMigrations(mock, testMigrations).Up(context.Background())
+	c.ErrorIs(err, ErrUnknownAppliedVersion)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestUpFailedMigrationRollsBack(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewConn()
+	c.NoError(err)
+
+	defer mock.Close(context.Background())
+
+	expectLocked(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(testMigrations[2].Up)).WillReturnError(context.DeadlineExceeded)
+	mock.ExpectRollback()
+	expectUnlocked(mock)
+
+	err = NewWithMigrations(mock, testMigrations).Up(context.Background())
+	c.ErrorIs(err, context.DeadlineExceeded)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestStatus(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewConn()
+	c.NoError(err)
+
+	defer mock.Close(context.Background())
+
+	appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(mock.NewRows([]string{"version", "applied_at"}).AddRow(int64(1), appliedAt))
+
+	statuses, err := NewWithMigrations(mock, testMigrations).Status(context.Background())
+	c.NoError(err)
+	c.Len(statuses, 3)
+	c.Equal(&appliedAt, statuses[0].AppliedAt)
+	c.Nil(statuses[1].AppliedAt)
+}
@@ -0,0 +1,225 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+const auditColumns = `
+		audit_id,
+		sequence,
+		actor,
+		actor_type,
+		action,
+		resource_type,
+		resource_id,
+		COALESCE(reason, ''),
+		details,
+		COALESCE(request_fingerprint, ''),
+		before,
+		after,
+		COALESCE(previous_hash, ''),
+		COALESCE(hash, ''),
+		created_at`
+
+// InsertAuditEntry appends an entry to the audit trail
+func (p postgresService) InsertAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+}
+
+// insertAuditEntry writes the audit entry within the given transaction, filling its ID, creation time, actor and request
+// fingerprint when missing, and chains it to the last entry of the trail. The head of the trail is locked against other writers
+// until the transaction ends, so entries are chained in the order they are committed. Encrypted additional fields of transaction
+// snapshots are stored and hashed encrypted
+func (p postgresService) insertAuditEntry(ctx context.Context, tx pgx.Tx, entry *models.AuditEntry) error {
+	headQuery := `SELECT sequence, COALESCE(hash, '') FROM audit_log_head FOR UPDATE`
+
+	advanceQuery := `UPDATE audit_log_head SET sequence = $1, hash = $2`
+
+	query := `
+	INSERT INTO audit_log(
+		audit_id,
+		sequence,
+		actor,
+		actor_type,
+		action,
+		resource_type,
+		resource_id,
+		reason,
+		details,
+		request_fingerprint,
+		before,
+		after,
+		previous_hash,
+		hash,
+		created_at
+	) VALUES($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, NULLIF($10, ''), $11, $12, NULLIF($13, ''), $14, $15)`
+
+	if entry.AuditID == "" {
+		entry.AuditID = fmt.Sprintf("AUD_%s", ulid.Make().String())
+	}
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+
+	audit.Stamp(ctx, entry)
+
+	var err error
+
+	entry.Before, err = p.encryptSnapshot(ctx, entry.Before)
+	if err != nil {
+		return err
+	}
+
+	entry.After, err = p.encryptSnapshot(ctx, entry.After)
+	if err != nil {
+		return err
+	}
+
+	// hashed as stored, since PostgreSQL keeps timestamps to the microsecond
+	entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Microsecond)
+
+	var sequence int64
+
+	err = tx.QueryRow(ctx, headQuery).Scan(&sequence, &entry.PreviousHash)
+	if err != nil {
+		return internalError(ctx, "lock audit log head failed", err)
+	}
+
+	entry.Sequence = sequence + 1
+
+	entry.Hash, err = entry.ComputeHash()
+	if err != nil {
+		return internalError(ctx, "hash audit entry failed", err)
+	}
+
+	_, err = tx.Exec(ctx, query, entry.AuditID, entry.Sequence, entry.Actor, entry.ActorType, entry.Action, entry.ResourceType, entry.ResourceID, entry.Reason, entry.Details, entry.RequestFingerprint, entry.Before, entry.After, entry.PreviousHash, entry.Hash, entry.CreatedAt)
+	if err != nil {
+		return internalError(ctx, "insert audit entry failed", err)
+	}
+
+	_, err = tx.Exec(ctx, advanceQuery, entry.Sequence, entry.Hash)
+	if err != nil {
+		return internalError(ctx, "advance audit log head failed", err)
+	}
+
+	return nil
+}
+
+// ListAuditEntries fetches the audit entries matching the filter in the order they were appended
+func (p postgresService) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	query := `
+	SELECT` + auditColumns + `
+	FROM audit_log`
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+
+	if filter.ActorType != "" {
+		addCondition("actor_type = $%d", filter.ActorType)
+	}
+
+	if filter.ResourceType != "" {
+		addCondition("resource_type = $%d", filter.ResourceType)
+	}
+
+	if filter.ResourceID != "" {
+		addCondition("resource_id = $%d", filter.ResourceID)
+	}
+
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	if filter.AfterSequence > 0 {
+		addCondition("sequence > $%d", filter.AfterSequence)
+	}
+
+	if len(conditions) > 0 {
+		query += "\n\tWHERE " + strings.Join(conditions, "\n\tAND ")
+	}
+
+	query += "\n\tORDER BY sequence"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\tLIMIT $%d", len(args))
+	}
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return entries, nil
+}
+
+func scanAuditEntry(row pgx.Row) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+
+	err := row.Scan(
+		&entry.AuditID,
+		&entry.Sequence,
+		&entry.Actor,
+		&entry.ActorType,
+		&entry.Action,
+		&entry.ResourceType,
+		&entry.ResourceID,
+		&entry.Reason,
+		&entry.Details,
+		&entry.RequestFingerprint,
+		&entry.Before,
+		&entry.After,
+		&entry.PreviousHash,
+		&entry.Hash,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+const lastAuditHash = "5d41402abc4b2a76b9719d911017c592aaf4c5b4c3d8a1f2e0b6c7d8e9f0a1b2"
+
+var auditColumnNames = []string{"audit_id", "sequence", "actor", "actor_type", "action", "resource_type", "resource_id", "reason", "details", "request_fingerprint", "before", "after", "previous_hash", "hash", "created_at"}
+
+// expectAuditEntry expects the entry to be chained after the last one of the trail, at sequence 41, and appended
+func expectAuditEntry(mock pgxmock.PgxPoolIface, actor string, action models.AuditAction, resourceType, resourceID, reason string) {
+	mock.ExpectQuery("FROM audit_log_head FOR UPDATE").WillReturnRows(mock.NewRows([]string{"sequence", "hash"}).AddRow(int64(41), lastAuditHash))
+	mock.ExpectExec("INSERT INTO audit_log").WithArgs(
+		pgxmock.AnyArg(),
+		int64(42),
+		actor,
+		pgxmock.AnyArg(),
+		action,
+		resourceType,
+		resourceID,
+		reason,
+		pgxmock.AnyArg(),
+		pgxmock.AnyArg(),
+		pgxmock.AnyArg(),
+		pgxmock.AnyArg(),
+		lastAuditHash,
+		pgxmock.AnyArg(),
+		pgxmock.AnyArg(),
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("UPDATE audit_log_head").WithArgs(int64(42), pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+}
+
+func TestInsertAuditEntryChainsHash(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	entry := &models.AuditEntry{
+		Actor:        "KEY_123",
+		ActorType:    models.AuditActorAPIKey,
+		Action:       models.AuditActionRefund,
+		ResourceType: models.AuditResourceTransaction,
+		ResourceID:   "TXN_123",
+		Before:       []byte(`{"status":"succeeded"}`),
+		After:        []byte(`{"status":"pending"}`),
+	}
+
+	mock.ExpectBegin()
+	expectAuditEntry(mock, "KEY_123", models.AuditActionRefund, models.AuditResourceTransaction, "TXN_123", "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	err = service.InsertAuditEntry(context.Background(), entry)
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+
+	c.Equal(int64(42), entry.Sequence)
+	c.Equal(lastAuditHash, entry.PreviousHash)
+
+	hash, err := entry.ComputeHash()
+	c.NoError(err)
+	c.Equal(hash, entry.Hash)
+}
+
+func TestInsertAuditEntryFirstOfTrailStampsActor(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	entry := &models.AuditEntry{
+		Action:       models.AuditActionForceStatus,
+		ResourceType: models.AuditResourceTransaction,
+		ResourceID:   "TXN_123",
+		Reason:       "stuck",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM audit_log_head FOR UPDATE").WillReturnRows(mock.NewRows([]string{"sequence", "hash"}).AddRow(int64(0), ""))
+	mock.ExpectExec("INSERT INTO audit_log").WithArgs(
+		pgxmock.AnyArg(), int64(1), "alice", models.AuditActorOperator, models.AuditActionForceStatus, models.AuditResourceTransaction, "TXN_123", "stuck",
+		pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), "", pgxmock.AnyArg(), pgxmock.AnyArg(),
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("UPDATE audit_log_head").WithArgs(int64(1), pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	ctx := audit.WithRequestFingerprint(audit.WithActor(context.Background(), models.AuditActorOperator, "alice"), "3f1c")
+
+	err = service.InsertAuditEntry(ctx, entry)
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+	c.Equal("alice", entry.Actor)
+	c.Equal("3f1c", entry.RequestFingerprint)
+	c.Empty(entry.PreviousHash)
+}
+
+func TestListAuditEntries(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	rows := mock.NewRows(auditColumnNames).
+		AddRow("AUD_1", int64(7), "KEY_123", models.AuditActorAPIKey, models.AuditActionRefund, models.AuditResourceTransaction, "TXN_123", "", nil, "3f1c", []byte(`{"status":"succeeded"}`), []byte(`{"status":"pending"}`), "aa", "bb", from.Add(time.Hour))
+
+	query := `
+	FROM audit_log
+	WHERE actor = $1
+	AND resource_type = $2
+	AND resource_id = $3
+	AND created_at >= $4
+	AND created_at < $5
+	ORDER BY sequence
+	LIMIT $6`
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("KEY_123", models.AuditResourceTransaction, "TXN_123", from, to, 50).WillReturnRows(rows)
+
+	service := postgresService{pool: mock}
+
+	entries, err := service.ListAuditEntries(context.Background(), &models.AuditFilter{
+		Actor:        "KEY_123",
+		ResourceType: models.AuditResourceTransaction,
+		ResourceID:   "TXN_123",
+		From:         &from,
+		To:           &to,
+		Limit:        50,
+	})
+	c.NoError(err)
+	c.Len(entries, 1)
+	c.Equal(int64(7), entries[0].Sequence)
+	c.JSONEq(`{"status":"pending"}`, string(entries[0].After))
+}
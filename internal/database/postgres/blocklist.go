@@ -0,0 +1,290 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// InsertBlockListEntry inserts a new block list entry along with the audit entry, failing with a conflict when its type
+// and value are already in the block list
+func (p postgresService) InsertBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) error {
+	query := `
+	INSERT INTO block_list(
+		entry_id,
+		type,
+		value,
+		reason,
+		expires_at,
+		created_at,
+		updated_at
+	) VALUES($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (type, value) DO NOTHING`
+
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, query, listEntry.EntryID, listEntry.Type, listEntry.Value, listEntry.Reason, listEntry.ExpiresAt, listEntry.CreatedAt, listEntry.UpdatedAt)
+		if err != nil {
+			return internalError(ctx, "execute query failed", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			return api.NewConflictError(database.ErrBlockListEntryExists)
+		}
+
+		entry.After = models.AuditSnapshot(listEntry)
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+}
+
+// GetBlockListEntry fetches a block list entry given its ID
+func (p postgresService) GetBlockListEntry(ctx context.Context, entryID string) (*models.BlockListEntry, error) {
+	query := `
+	SELECT
+		entry_id,
+		type,
+		value,
+		reason,
+		expires_at,
+		created_at,
+		updated_at
+	FROM block_list
+	WHERE entry_id = $1
+	`
+
+	entry, err := scanBlockListEntry(p.pool.QueryRow(ctx, query, entryID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, api.NewResourceNotFoundError(database.ErrBlockListEntryNotFound, "block_list_entry")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
+
+	return entry, nil
+}
+
+// ListBlockListEntries fetches the block list entries matching the filter, newest first
+func (p postgresService) ListBlockListEntries(ctx context.Context, filter *models.BlockListFilter) ([]*models.BlockListEntry, error) {
+	query := `
+	SELECT
+		entry_id,
+		type,
+		value,
+		reason,
+		expires_at,
+		created_at,
+		updated_at
+	FROM block_list`
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if len(filter.Types) > 0 {
+		types := make([]string, 0, len(filter.Types))
+		for _, entryType := range filter.Types {
+			types = append(types, string(entryType))
+		}
+
+		args = append(args, types)
+		conditions = append(conditions, fmt.Sprintf("type = ANY($%d)", len(args)))
+	}
+
+	if filter.ActiveAt != nil {
+		args = append(args, *filter.ActiveAt)
+		conditions = append(conditions, fmt.Sprintf("(expires_at IS NULL OR expires_at > $%d)", len(args)))
+	}
+
+	if len(conditions) > 0 {
+		query += "\n\tWHERE " + strings.Join(conditions, "\n\tAND ")
+	}
+
+	query += "\n\tORDER BY created_at DESC, entry_id DESC"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\tLIMIT $%d", len(args))
+	}
+
+	return p.queryBlockListEntries(ctx, query, args...)
+}
+
+// UpdateBlockListEntry updates the reason and expiration of a block list entry along with the audit entry, which
+// records the entry before and after the update
+func (p postgresService) UpdateBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) (*models.BlockListEntry, error) {
+	currentQuery := `
+	SELECT
+		entry_id,
+		type,
+		value,
+		reason,
+		expires_at,
+		created_at,
+		updated_at
+	FROM block_list
+	WHERE entry_id = $1
+	FOR UPDATE
+	`
+
+	query := `
+	UPDATE block_list
+	SET
+		reason = $1,
+		expires_at = $2,
+		updated_at = NOW()
+	WHERE entry_id = $3
+	RETURNING
+		entry_id,
+		type,
+		value,
+		reason,
+		expires_at,
+		created_at,
+		updated_at
+	`
+
+	var updated *models.BlockListEntry
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		current, err := scanBlockListEntry(tx.QueryRow(ctx, currentQuery, listEntry.EntryID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return api.NewResourceNotFoundError(database.ErrBlockListEntryNotFound, "block_list_entry")
+		}
+
+		if err != nil {
+			return internalError(ctx, "scan row failed", err)
+		}
+
+		updated, err = scanBlockListEntry(tx.QueryRow(ctx, query, listEntry.Reason, listEntry.ExpiresAt, listEntry.EntryID))
+		if err != nil {
+			return internalError(ctx, "update and scan row failed", err)
+		}
+
+		entry.Before = models.AuditSnapshot(current)
+		entry.After = models.AuditSnapshot(updated)
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteBlockListEntry deletes a block list entry given its ID along with the audit entry, which records the deleted
+// entry
+func (p postgresService) DeleteBlockListEntry(ctx context.Context, entryID string, entry *models.AuditEntry) error {
+	query := `
+	DELETE FROM block_list
+	WHERE entry_id = $1
+	RETURNING
+		entry_id,
+		type,
+		value,
+		reason,
+		expires_at,
+		created_at,
+		updated_at
+	`
+
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		deleted, err := scanBlockListEntry(tx.QueryRow(ctx, query, entryID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return api.NewResourceNotFoundError(database.ErrBlockListEntryNotFound, "block_list_entry")
+		}
+
+		if err != nil {
+			return internalError(ctx, "delete and scan row failed", err)
+		}
+
+		entry.Before = models.AuditSnapshot(deleted)
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+}
+
+// MatchBlockList fetches the active entries matching any attribute of the payment. BIN ranges are compared against
+// as many leading digits of the card BIN as the range holds
+func (p postgresService) MatchBlockList(ctx context.Context, query *models.BlockListQuery) ([]*models.BlockListEntry, error) {
+	matchQuery := `
+	SELECT
+		entry_id,
+		type,
+		value,
+		reason,
+		expires_at,
+		created_at,
+		updated_at
+	FROM block_list
+	WHERE (expires_at IS NULL OR expires_at > $1)
+	AND (
+		(type = 'payment_method' AND value = $2)
+		OR (type = 'card_fingerprint' AND value = $3)
+		OR (type = 'email' AND value = $4)
+		OR (type = 'ip_address' AND value = $5)
+		OR (
+			type = 'bin'
+			AND length($6::text) >= length(split_part(value, '-', 1))
+			AND left($6::text, length(split_part(value, '-', 1))) BETWEEN split_part(value, '-', 1) AND split_part(value, '-', 2)
+		)
+	)
+	ORDER BY created_at, entry_id
+	`
+
+	return p.queryBlockListEntries(ctx, matchQuery, query.At, query.PaymentMethod, query.CardFingerprint, query.Email, query.IPAddress, query.BIN)
+}
+
+func (p postgresService) queryBlockListEntries(ctx context.Context, query string, args ...interface{}) ([]*models.BlockListEntry, error) {
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	entries := []*models.BlockListEntry{}
+
+	for rows.Next() {
+		entry, err := scanBlockListEntry(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return entries, nil
+}
+
+func scanBlockListEntry(row pgx.Row) (*models.BlockListEntry, error) {
+	var entry models.BlockListEntry
+
+	err := row.Scan(
+		&entry.EntryID,
+		&entry.Type,
+		&entry.Value,
+		&entry.Reason,
+		&entry.ExpiresAt,
+		&entry.CreatedAt,
+		&entry.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+var blockListColumns = []string{"entry_id", "type", "value", "reason", "expires_at", "created_at", "updated_at"}
+
+func TestInsertBlockListEntryExists(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	now := time.Now()
+
+	entry := &models.BlockListEntry{
+		EntryID:   "BLK_123",
+		Type:      models.BlockListTypeEmail,
+		Value:     "fraudster@example.com",
+		Reason:    "chargebacks",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO block_list").
+		WithArgs(entry.EntryID, entry.Type, entry.Value, entry.Reason, entry.ExpiresAt, entry.CreatedAt, entry.UpdatedAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	err = service.InsertBlockListEntry(context.Background(), entry, &models.AuditEntry{})
+	c.ErrorIs(err, database.ErrBlockListEntryExists)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestInsertBlockListEntry(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	now := time.Now()
+
+	entry := &models.BlockListEntry{
+		EntryID:   "BLK_123",
+		Type:      models.BlockListTypeEmail,
+		Value:     "fraudster@example.com",
+		Reason:    "chargebacks",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO block_list").
+		WithArgs(entry.EntryID, entry.Type, entry.Value, entry.Reason, entry.ExpiresAt, entry.CreatedAt, entry.UpdatedAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, "alice", models.AuditActionCreateBlockListEntry, models.AuditResourceBlockListEntry, "BLK_123", "chargebacks")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	auditEntry := &models.AuditEntry{
+		Actor:        "alice",
+		Action:       models.AuditActionCreateBlockListEntry,
+		ResourceType: models.AuditResourceBlockListEntry,
+		ResourceID:   "BLK_123",
+		Reason:       "chargebacks",
+	}
+
+	err = service.InsertBlockListEntry(context.Background(), entry, auditEntry)
+	c.NoError(err)
+	c.NotEmpty(auditEntry.After, "the created entry is recorded")
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestUpdateBlockListEntry(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	now := time.Now()
+	expiresAt := now.Add(24 * time.Hour)
+
+	entry := &models.BlockListEntry{EntryID: "BLK_123", Reason: "card testing", ExpiresAt: &expiresAt}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs("BLK_123").
+		WillReturnRows(mock.NewRows(blockListColumns).AddRow("BLK_123", models.BlockListTypeEmail, "fraudster@example.com", "chargebacks", nil, now, now))
+	mock.ExpectQuery("UPDATE block_list").WithArgs("card testing", &expiresAt, "BLK_123").
+		WillReturnRows(mock.NewRows(blockListColumns).AddRow("BLK_123", models.BlockListTypeEmail, "fraudster@example.com", "card testing", &expiresAt, now, now))
+	expectAuditEntry(mock, "alice", models.AuditActionUpdateBlockListEntry, models.AuditResourceBlockListEntry, "BLK_123", "card testing")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	auditEntry := &models.AuditEntry{
+		Actor:        "alice",
+		Action:       models.AuditActionUpdateBlockListEntry,
+		ResourceType: models.AuditResourceBlockListEntry,
+		ResourceID:   "BLK_123",
+		Reason:       "card testing",
+	}
+
+	updated, err := service.UpdateBlockListEntry(context.Background(), entry, auditEntry)
+	c.NoError(err)
+	c.Equal("card testing", updated.Reason)
+	c.Contains(string(auditEntry.Before), `"reason":"chargebacks"`)
+	c.Contains(string(auditEntry.After), `"reason":"card testing"`)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestListBlockListEntries(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	rows := mock.NewRows(blockListColumns).
+		AddRow("BLK_1", models.BlockListTypeBIN, "424242-424242", "card testing", nil, now, now)
+
+	query := `
+	FROM block_list
+	WHERE type = ANY($1)
+	AND (expires_at IS NULL OR expires_at > $2)
+	ORDER BY created_at DESC, entry_id DESC
+	LIMIT $3`
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs([]string{"card_fingerprint", "bin"}, now, 1).WillReturnRows(rows)
+
+	service := postgresService{pool: mock}
+
+	entries, err := service.ListBlockListEntries(context.Background(), &models.BlockListFilter{
+		Types:    []models.BlockListType{models.BlockListTypeCardFingerprint, models.BlockListTypeBIN},
+		ActiveAt: &now,
+		Limit:    1,
+	})
+	c.NoError(err)
+	c.Len(entries, 1)
+	c.Nil(entries[0].ExpiresAt)
+}
+
+func TestMatchBlockList(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+
+	rows := mock.NewRows(blockListColumns).
+		AddRow("BLK_1", models.BlockListTypeIPAddress, "10.0.0.1", "botnet", &expiresAt, now, now)
+
+	query := &models.BlockListQuery{
+		PaymentMethod: "pm_card_visa",
+		Email:         "customer@example.com",
+		IPAddress:     "10.0.0.1",
+		At:            now,
+	}
+
+	mock.ExpectQuery("FROM block_list").WithArgs(now, "pm_card_visa", "", "customer@example.com", "10.0.0.1", "").WillReturnRows(rows)
+
+	service := postgresService{pool: mock}
+
+	entries, err := service.MatchBlockList(context.Background(), query)
+	c.NoError(err)
+	c.Len(entries, 1)
+	c.Equal(models.BlockListTypeIPAddress, entries[0].Type)
+	c.Equal(expiresAt, *entries[0].ExpiresAt)
+}
+
+func TestDeleteBlockListEntryNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM block_list").WithArgs("BLK_123").WillReturnError(pgx.ErrNoRows)
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	err = service.DeleteBlockListEntry(context.Background(), "BLK_123", &models.AuditEntry{})
+	c.ErrorIs(err, database.ErrBlockListEntryNotFound)
+	c.NoError(mock.ExpectationsWereMet())
+}
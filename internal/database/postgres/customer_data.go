@@ -0,0 +1,436 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// referenceFieldsOnly keeps the provider reference fields, bound to $1, of the additional fields of a transaction
+const referenceFieldsOnly = `CASE WHEN jsonb_typeof(%[1]s) = 'object'
+		THEN (SELECT COALESCE(jsonb_object_agg(key, value), '{}'::jsonb) FROM jsonb_each(%[1]s) WHERE key = ANY($1))
+		ELSE %[1]s END`
+
+// webhookEventTransactionID transaction a stored Stripe event is about, read from the metadata of its object
+const webhookEventTransactionID = `(payload->'data'->'object'->'metadata'->>'transaction_id')`
+
+// ExportCustomerData fetches everything stored about the customer: the risk decisions naming them, the transactions
+// those decisions were taken on, the block list entries matching their email, payment methods or addresses, and the
+// audit entries and webhook events of their transactions
+func (p postgresService) ExportCustomerData(ctx context.Context, customer string) (*models.CustomerData, error) {
+	decisionsQuery := `
+	SELECT
+		decision_id,
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		outcome,
+		rules,
+		payment_method,
+		COALESCE(customer, ''),
+		COALESCE(ip_address, ''),
+		COALESCE(country, ''),
+		amount,
+		currency,
+		created_at
+	FROM risk_decisions
+	WHERE customer = $1
+	ORDER BY created_at, decision_id`
+
+	transactionsQuery := `
+	SELECT
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	FROM (
+		SELECT` + archivedColumns + ` FROM transactions_history WHERE transaction_id = ANY($1)
+		UNION ALL
+		SELECT` + archivedColumns + ` FROM transactions_archive WHERE transaction_id = ANY($1)
+	) AS transactions
+	ORDER BY created_at, transaction_id`
+
+	blockListQuery := `
+	SELECT
+		entry_id,
+		type,
+		value,
+		reason,
+		expires_at,
+		created_at,
+		updated_at
+	FROM block_list
+	WHERE (type = 'email' AND value = $1)
+	OR (type = 'payment_method' AND value = ANY($2))
+	OR (type = 'ip_address' AND value = ANY($3))
+	ORDER BY created_at, entry_id`
+
+	auditQuery := `
+	SELECT` + auditColumns + `
+	FROM audit_log
+	WHERE resource_type = $1 AND resource_id = ANY($2)
+	ORDER BY sequence`
+
+	webhookEventsQuery := `
+	SELECT` + webhookEventColumns + `
+	FROM webhook_events
+	WHERE ` + webhookEventTransactionID + ` = ANY($1)
+	ORDER BY received_at, event_id`
+
+	data := &models.CustomerData{
+		Customer:   customer,
+		ExportedAt: time.Now().UTC(),
+	}
+
+	var err error
+
+	data.RiskDecisions, err = p.queryRiskDecisions(ctx, decisionsQuery, customer)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactionIDs, paymentMethods, ipAddresses []string
+
+	for _, decision := range data.RiskDecisions {
+		transactionIDs = append(transactionIDs, decision.TransactionID)
+		paymentMethods = append(paymentMethods, decision.PaymentMethod)
+
+		if decision.IPAddress != "" {
+			ipAddresses = append(ipAddresses, decision.IPAddress)
+		}
+	}
+
+	rows, err := p.pool.Query(ctx, transactionsQuery, transactionIDs)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	data.Transactions, err = scanTransactions(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transaction := range data.Transactions {
+		err = p.decryptTransaction(ctx, transaction)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data.BlockListEntries, err = p.queryBlockListEntries(ctx, blockListQuery, customer, paymentMethods, ipAddresses)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = p.pool.Query(ctx, auditQuery, models.AuditResourceTransaction, transactionIDs)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	data.AuditEntries = []*models.AuditEntry{}
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		data.AuditEntries = append(data.AuditEntries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	for _, entry := range data.AuditEntries {
+		err = p.decryptAuditEntry(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err = p.pool.Query(ctx, webhookEventsQuery, transactionIDs)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	data.WebhookEvents = []*models.WebhookEvent{}
+
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		data.WebhookEvents = append(data.WebhookEvents, event)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return data, nil
+}
+
+// EraseCustomerData pseudonymizes the customer and payment method of their risk decisions with the erasure ID, dropping
+// the address and country they paid from, and erases the description and the additional fields other than the
+// provider references of their transactions, along with the ones of the outbox events of those transactions. The
+// payloads of the webhook events about those transactions, which carry the billing details of the customer, are
+// dropped, and so are the block list entries listing their email. Block list entries listing the payment methods or
+// addresses they paid with are kept to prevent further fraud. Amounts, fees, statuses and IDs are kept for accounting.
+// Every erased transaction gets a transaction.updated event, and the number of erased records is recorded in the
+// details of the audit entry.
+//
+// Audit log entries are left as they are: the trail is kept to meet the record keeping obligations of the platform and
+// to establish or defend legal claims, which the right to erasure doesn't override, and rewriting a snapshot would
+// break the hash chain. Their additional fields are encrypted when designated for encryption
+func (p postgresService) EraseCustomerData(ctx context.Context, erasure *models.CustomerErasure, entry *models.AuditEntry) error {
+	decisionsQuery := `
+	UPDATE risk_decisions
+	SET
+		customer = $1,
+		payment_method = $1,
+		ip_address = NULL,
+		country = NULL
+	WHERE customer = $2
+	RETURNING transaction_id`
+
+	transactionsQuery := `
+	UPDATE transactions_history
+	SET
+		description = '',
+		additional_fields = ` + fmt.Sprintf(referenceFieldsOnly, "additional_fields") + `,
+		version = version + 1,
+		updated_at = NOW()
+	WHERE transaction_id = ANY($2)
+	RETURNING
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	`
+
+	archiveQuery := `
+	UPDATE transactions_archive
+	SET
+		description = '',
+		additional_fields = ` + fmt.Sprintf(referenceFieldsOnly, "additional_fields") + `
+	WHERE transaction_id = ANY($2)`
+
+	outboxQuery := `
+	UPDATE outbox
+	SET payload = payload || jsonb_build_object('description', '', 'additional_fields', ` + fmt.Sprintf(referenceFieldsOnly, "payload->'additional_fields'") + `)
+	WHERE aggregate_type = $2 AND aggregate_id = ANY($3)`
+
+	webhookEventsQuery := `
+	UPDATE webhook_events
+	SET payload = NULL
+	WHERE ` + webhookEventTransactionID + ` = ANY($1)`
+
+	blockListQuery := `
+	DELETE FROM block_list
+	WHERE type = 'email' AND value = $1`
+
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, decisionsQuery, erasure.ErasureID, erasure.Customer)
+		if err != nil {
+			return internalError(ctx, "erase risk decisions failed", err)
+		}
+
+		var transactionIDs []string
+
+		for rows.Next() {
+			var transactionID string
+
+			err = rows.Scan(&transactionID)
+			if err != nil {
+				rows.Close()
+				return internalError(ctx, "scan row failed", err)
+			}
+
+			transactionIDs = append(transactionIDs, transactionID)
+		}
+
+		rows.Close()
+
+		err = rows.Err()
+		if err != nil {
+			return internalError(ctx, "iterate rows failed", err)
+		}
+
+		if len(transactionIDs) == 0 {
+			return api.NewResourceNotFoundError(database.ErrCustomerNotFound, "customer")
+		}
+
+		erasure.RiskDecisions = len(transactionIDs)
+		erasure.TransactionIDs = transactionIDs
+
+		_, err = tx.Exec(ctx, outboxQuery, models.ProviderReferenceFields, models.AggregateTransaction, transactionIDs)
+		if err != nil {
+			return internalError(ctx, "erase outbox events failed", err)
+		}
+
+		rows, err = tx.Query(ctx, transactionsQuery, models.ProviderReferenceFields, transactionIDs)
+		if err != nil {
+			return internalError(ctx, "erase transactions failed", err)
+		}
+
+		// every erased row is read before the events are inserted, as the connection can't run another command
+		// while rows are still pending
+		transactions, err := scanTransactions(ctx, rows)
+		if err != nil {
+			return err
+		}
+
+		erasure.Transactions = len(transactions)
+
+		// archived transactions are erased as well, without events, as they are no longer published
+		result, err := tx.Exec(ctx, archiveQuery, models.ProviderReferenceFields, transactionIDs)
+		if err != nil {
+			return internalError(ctx, "erase archived transactions failed", err)
+		}
+
+		erasure.Transactions += int(result.RowsAffected())
+
+		result, err = tx.Exec(ctx, webhookEventsQuery, transactionIDs)
+		if err != nil {
+			return internalError(ctx, "erase webhook events failed", err)
+		}
+
+		erasure.WebhookEvents = int(result.RowsAffected())
+
+		result, err = tx.Exec(ctx, blockListQuery, erasure.Customer)
+		if err != nil {
+			return internalError(ctx, "erase block list entries failed", err)
+		}
+
+		erasure.BlockListEntries = int(result.RowsAffected())
+
+		for _, transaction := range transactions {
+			err = insertEvent(ctx, tx, models.EventTypeTransactionUpdated, models.AggregateTransaction, transaction.TransactionID, transaction)
+			if err != nil {
+				return err
+			}
+		}
+
+		entry.Details = map[string]interface{}{
+			"transactions":       erasure.Transactions,
+			"risk_decisions":     erasure.RiskDecisions,
+			"webhook_events":     erasure.WebhookEvents,
+			"block_list_entries": erasure.BlockListEntries,
+		}
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+}
+
+// queryRiskDecisions runs a query selecting risk decisions and scans every row
+func (p postgresService) queryRiskDecisions(ctx context.Context, query string, args ...interface{}) ([]*models.RiskDecision, error) {
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	decisions := []*models.RiskDecision{}
+
+	for rows.Next() {
+		decision, err := scanRiskDecision(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		decisions = append(decisions, decision)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return decisions, nil
+}
+
+// scanTransactions scans and closes the rows of a query returning transactions
+func scanTransactions(ctx context.Context, rows pgx.Rows) ([]*models.Transaction, error) {
+	defer rows.Close()
+
+	transactions := []*models.Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	err := rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return transactions, nil
+}
+
+func scanRiskDecision(row pgx.Row) (*models.RiskDecision, error) {
+	var decision models.RiskDecision
+
+	err := row.Scan(
+		&decision.DecisionID,
+		&decision.TransactionID,
+		&decision.MerchantID,
+		&decision.Outcome,
+		&decision.Rules,
+		&decision.PaymentMethod,
+		&decision.Customer,
+		&decision.IPAddress,
+		&decision.Country,
+		&decision.Amount,
+		&decision.Currency,
+		&decision.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decision, nil
+}
@@ -0,0 +1,189 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	riskDecisionColumnNames = []string{"decision_id", "transaction_id", "merchant_id", "outcome", "rules", "payment_method", "customer", "ip_address", "country", "amount", "currency", "created_at"}
+	webhookEventColumnNames = []string{"event_id", "provider", "type", "payload", "received_at", "processed_at", "attempts", "last_error"}
+	transactionColumnNames  = []string{"transaction_id", "merchant_id", "status", "description", "failure_reason", "payment_provider", "amount", "currency", "type", "additional_fields", "provider_fee", "platform_fee", "net_amount", "fee_currency", "version", "created_at", "updated_at"}
+)
+
+func TestExportCustomerData(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("FROM risk_decisions").WithArgs("jane@example.com").WillReturnRows(mock.NewRows(riskDecisionColumnNames).
+		AddRow("RSK_1", "TXN_1", "MCH_123", models.RiskOutcomeAllow, []*models.RiskRuleResult{{Rule: "velocity", Type: "velocity", Action: models.RiskOutcomeReview}}, "pm_card_visa", "jane@example.com", "203.0.113.7", "MX", 2000, "usd", createdAt).
+		AddRow("RSK_2", "TXN_2", "MCH_123", models.RiskOutcomeAllow, []*models.RiskRuleResult{}, "pm_card_mastercard", "jane@example.com", "", "", 500, "usd", createdAt))
+
+	mock.ExpectQuery("FROM transactions_history").WithArgs([]string{"TXN_1", "TXN_2"}).WillReturnRows(mock.NewRows(transactionColumnNames).
+		AddRow("TXN_1", "MCH_123", models.TransactionStatusSucceeded, "order 42", "", models.PaymentProviderStripe, 2000, "usd", models.TransactionTypeCharge, `{"charge_id":"ch_1"}`, 0, 0, 0, "", 1, createdAt, createdAt))
+
+	blockListQuery := `
+	WHERE (type = 'email' AND value = $1)
+	OR (type = 'payment_method' AND value = ANY($2))
+	OR (type = 'ip_address' AND value = ANY($3))`
+
+	mock.ExpectQuery(regexp.QuoteMeta(blockListQuery)).WithArgs("jane@example.com", []string{"pm_card_visa", "pm_card_mastercard"}, []string{"203.0.113.7"}).
+		WillReturnRows(mock.NewRows([]string{"entry_id", "type", "value", "reason", "expires_at", "created_at", "updated_at"}))
+
+	mock.ExpectQuery("FROM audit_log").WithArgs(models.AuditResourceTransaction, []string{"TXN_1", "TXN_2"}).WillReturnRows(mock.NewRows(auditColumnNames).
+		AddRow("AUD_1", int64(7), "KEY_123", models.AuditActorAPIKey, models.AuditActionRefund, models.AuditResourceTransaction, "TXN_1", "", nil, "", nil, nil, "aa", "bb", createdAt))
+	mock.ExpectQuery("FROM webhook_events").WithArgs([]string{"TXN_1", "TXN_2"}).WillReturnRows(mock.NewRows(webhookEventColumnNames).
+		AddRow("evt_1", models.PaymentProviderStripe, "payment_intent.succeeded", `{"data":{"object":{"metadata":{"transaction_id":"TXN_1"}}}}`, createdAt, &createdAt, 1, "").
+		AddRow("evt_2", models.PaymentProviderStripe, "charge.refunded", "", createdAt, &createdAt, 1, ""))
+
+	service := postgresService{pool: mock}
+
+	data, err := service.ExportCustomerData(context.Background(), "jane@example.com")
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+
+	c.Equal("jane@example.com", data.Customer)
+	c.Len(data.RiskDecisions, 2)
+	c.Equal("203.0.113.7", data.RiskDecisions[0].IPAddress)
+	c.Len(data.Transactions, 1)
+	c.Equal("order 42", data.Transactions[0].Description)
+	c.Empty(data.BlockListEntries)
+	c.Len(data.AuditEntries, 1)
+	c.Len(data.WebhookEvents, 2)
+	c.JSONEq(`{"data":{"object":{"metadata":{"transaction_id":"TXN_1"}}}}`, string(data.WebhookEvents[0].Payload))
+	c.Nil(data.WebhookEvents[1].Payload, "pruned payloads are left empty")
+}
+
+func TestExportCustomerDataDecryptsAuditSnapshots(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	encryptor := newTestEncryptor("key-1")
+
+	stored, err := encryptor.Encrypt(context.Background(), map[string]interface{}{"charge_id": "ch_123", "customer_email": "jane@example.com"})
+	c.NoError(err)
+
+	snapshot, err := json.Marshal(map[string]interface{}{"transaction_id": "TXN_1", "additional_fields": stored})
+	c.NoError(err)
+
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("FROM risk_decisions").WithArgs("jane@example.com").WillReturnRows(mock.NewRows(riskDecisionColumnNames).
+		AddRow("RSK_1", "TXN_1", "MCH_123", models.RiskOutcomeAllow, []*models.RiskRuleResult{}, "pm_card_visa", "jane@example.com", "", "", 2000, "usd", createdAt))
+	mock.ExpectQuery("FROM transactions_history").WithArgs([]string{"TXN_1"}).WillReturnRows(mock.NewRows(transactionColumnNames))
+	mock.ExpectQuery("FROM block_list").WithArgs("jane@example.com", []string{"pm_card_visa"}, []string(nil)).WillReturnRows(mock.NewRows([]string{"entry_id", "type", "value", "reason", "expires_at", "created_at", "updated_at"}))
+	mock.ExpectQuery("FROM audit_log").WithArgs(models.AuditResourceTransaction, []string{"TXN_1"}).WillReturnRows(mock.NewRows(auditColumnNames).
+		AddRow("AUD_1", int64(7), "KEY_123", models.AuditActorAPIKey, models.AuditActionCreatePayment, models.AuditResourceTransaction, "TXN_1", "", nil, "", nil, snapshot, "aa", "bb", createdAt))
+	mock.ExpectQuery("FROM webhook_events").WithArgs([]string{"TXN_1"}).WillReturnRows(mock.NewRows(webhookEventColumnNames))
+
+	// a key rotated since the snapshot was encrypted can still decrypt it
+	service := postgresService{pool: mock, encryptor: newTestEncryptor("key-2")}
+
+	data, err := service.ExportCustomerData(context.Background(), "jane@example.com")
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+
+	c.Len(data.AuditEntries, 1)
+	c.Nil(data.AuditEntries[0].Before)
+	c.JSONEq(`{"transaction_id":"TXN_1","additional_fields":{"charge_id":"ch_123","customer_email":"jane@example.com"}}`, string(data.AuditEntries[0].After))
+}
+
+func TestEraseCustomerData(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	erasure := &models.CustomerErasure{ErasureID: "ERS_123", Customer: "jane@example.com"}
+
+	entry := &models.AuditEntry{
+		Actor:        "alice",
+		Action:       models.AuditActionEraseCustomerData,
+		ResourceType: models.AuditResourceCustomer,
+		ResourceID:   "ERS_123",
+		Reason:       "right to erasure request",
+	}
+
+	mock.ExpectBegin()
+	decisionsQuery := `
+	SET
+		customer = $1,
+		payment_method = $1,
+		ip_address = NULL,
+		country = NULL
+	WHERE customer = $2`
+
+	mock.ExpectQuery(regexp.QuoteMeta(decisionsQuery)).WithArgs("ERS_123", "jane@example.com").WillReturnRows(mock.NewRows([]string{"transaction_id"}).AddRow("TXN_1").AddRow("TXN_2"))
+	mock.ExpectExec("UPDATE outbox").WithArgs(models.ProviderReferenceFields, models.AggregateTransaction, []string{"TXN_1", "TXN_2"}).WillReturnResult(pgxmock.NewResult("UPDATE", 3))
+	mock.ExpectQuery("UPDATE transactions_history").WithArgs(models.ProviderReferenceFields, []string{"TXN_1", "TXN_2"}).WillReturnRows(mock.NewRows(transactionColumnNames).
+		AddRow("TXN_1", "MCH_123", models.TransactionStatusSucceeded, "", "", models.PaymentProviderStripe, 2000, "usd", models.TransactionTypeCharge, `{"charge_id":"ch_1"}`, 0, 0, 0, "", 2, createdAt, createdAt).
+		AddRow("TXN_2", "MCH_123", models.TransactionStatusFailure, "", "card_declined", models.PaymentProviderStripe, 500, "usd", models.TransactionTypeCharge, `{}`, 0, 0, 0, "", 2, createdAt, createdAt))
+	mock.ExpectExec("UPDATE transactions_archive").WithArgs(models.ProviderReferenceFields, []string{"TXN_1", "TXN_2"}).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE webhook_events\n\tSET payload = NULL")).WithArgs([]string{"TXN_1", "TXN_2"}).WillReturnResult(pgxmock.NewResult("UPDATE", 4))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM block_list\n\tWHERE type = 'email' AND value = $1")).WithArgs("jane@example.com").WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionUpdated, models.AggregateTransaction, "TXN_1", pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionUpdated, models.AggregateTransaction, "TXN_2", pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, "alice", models.AuditActionEraseCustomerData, models.AuditResourceCustomer, "ERS_123", "right to erasure request")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	err = service.EraseCustomerData(context.Background(), erasure, entry)
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+
+	c.Equal(3, erasure.Transactions, "archived transactions are counted too")
+	c.Equal(2, erasure.RiskDecisions)
+	c.Equal(4, erasure.WebhookEvents)
+	c.Equal(1, erasure.BlockListEntries)
+	c.Equal([]string{"TXN_1", "TXN_2"}, erasure.TransactionIDs, "the transactions are kept for the copies outside the database")
+	c.Equal(map[string]interface{}{"transactions": 3, "risk_decisions": 2, "webhook_events": 4, "block_list_entries": 1}, entry.Details)
+}
+
+func TestEraseCustomerDataNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE risk_decisions").WithArgs("ERS_123", "jane@example.com").WillReturnRows(mock.NewRows([]string{"transaction_id"}))
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	err = service.EraseCustomerData(context.Background(), &models.CustomerErasure{ErasureID: "ERS_123", Customer: "jane@example.com"}, &models.AuditEntry{})
+	c.ErrorIs(err, database.ErrCustomerNotFound)
+
+	var apiErr api.APIErr
+	c.True(errors.As(err, &apiErr))
+	c.Equal(http.StatusNotFound, apiErr.StatusCode)
+	c.NoError(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,263 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/encryption"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// encryptFields encrypts the designated additional fields before they are written. Everything derived from the stored
+// row, such as the outbox events and audit snapshots, carries them encrypted as well
+func (p postgresService) encryptFields(ctx context.Context, fields map[string]interface{}) (map[string]interface{}, error) {
+	encrypted, err := p.encryptor.Encrypt(ctx, fields)
+	if err != nil {
+		return nil, internalError(ctx, "encrypt additional fields failed", err)
+	}
+
+	return encrypted, nil
+}
+
+// decryptTransaction decrypts the additional fields of a stored transaction before it's handed to the caller
+func (p postgresService) decryptTransaction(ctx context.Context, transaction *models.Transaction) error {
+	decrypted, err := p.encryptor.Decrypt(ctx, transaction.AdditionalFields)
+	if err != nil {
+		return internalError(ctx, "decrypt additional fields failed", err)
+	}
+
+	transaction.AdditionalFields = decrypted
+
+	return nil
+}
+
+// encryptSnapshot encrypts the designated additional fields of an audit snapshot of a transaction, which is kept for
+// as long as the audit log. Snapshots of other resources are returned as they are
+func (p postgresService) encryptSnapshot(ctx context.Context, snapshot json.RawMessage) (json.RawMessage, error) {
+	if p.encryptor == nil || len(snapshot) == 0 {
+		return snapshot, nil
+	}
+
+	var resource map[string]interface{}
+
+	err := json.Unmarshal(snapshot, &resource)
+	if err != nil {
+		return nil, internalError(ctx, "unmarshal audit snapshot failed", err)
+	}
+
+	fields, ok := resource["additional_fields"].(map[string]interface{})
+	if !ok {
+		return snapshot, nil
+	}
+
+	resource["additional_fields"], err = p.encryptFields(ctx, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := json.Marshal(resource)
+	if err != nil {
+		return nil, internalError(ctx, "marshal audit snapshot failed", err)
+	}
+
+	return encrypted, nil
+}
+
+// decryptAuditEntry decrypts the designated additional fields of the snapshots of an audit entry before they leave the
+// platform, such as when exporting the data of a customer
+func (p postgresService) decryptAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	var err error
+
+	entry.Before, err = p.decryptSnapshot(ctx, entry.Before)
+	if err != nil {
+		return err
+	}
+
+	entry.After, err = p.decryptSnapshot(ctx, entry.After)
+
+	return err
+}
+
+// decryptSnapshot decrypts the designated additional fields of an audit snapshot of a transaction. Snapshots of other
+// resources are returned as they are
+func (p postgresService) decryptSnapshot(ctx context.Context, snapshot json.RawMessage) (json.RawMessage, error) {
+	if p.encryptor == nil || len(snapshot) == 0 {
+		return snapshot, nil
+	}
+
+	var resource map[string]interface{}
+
+	err := json.Unmarshal(snapshot, &resource)
+	if err != nil {
+		return nil, internalError(ctx, "unmarshal audit snapshot failed", err)
+	}
+
+	fields, ok := resource["additional_fields"].(map[string]interface{})
+	if !ok {
+		return snapshot, nil
+	}
+
+	resource["additional_fields"], err = p.encryptor.Decrypt(ctx, fields)
+	if err != nil {
+		return nil, internalError(ctx, "decrypt additional fields failed", err)
+	}
+
+	decrypted, err := json.Marshal(resource)
+	if err != nil {
+		return nil, internalError(ctx, "marshal audit snapshot failed", err)
+	}
+
+	return decrypted, nil
+}
+
+// searchDocuments builds the JSON documents additional fields contain when one of their indexed fields holds the
+// searched value, matching its blind index instead of the encrypted value
+func (p postgresService) searchDocuments(ctx context.Context, search string) ([]string, error) {
+	if search == "" {
+		return nil, nil
+	}
+
+	indexes, err := p.encryptor.BlindIndexes(ctx, search)
+	if err != nil {
+		return nil, internalError(ctx, "compute blind indexes failed", err)
+	}
+
+	documents := make([]string, 0, len(indexes))
+
+	for field, index := range indexes {
+		document, err := json.Marshal(map[string]interface{}{
+			field: map[string]string{encryption.EnvelopeBlindIndex: index},
+		})
+		if err != nil {
+			return nil, internalError(ctx, "marshal search document failed", err)
+		}
+
+		documents = append(documents, string(document))
+	}
+
+	return documents, nil
+}
+
+// reencryptedTables tables holding the additional fields of transactions, re-encrypted in order
+var reencryptedTables = []string{"transactions_history", "transactions_archive"}
+
+// ReencryptTransactions encrypts with the active key the designated additional fields of up to limit transactions
+// which were encrypted with an older key, or stored before their field was designated, starting with the stored
+// transactions and going on with the archived ones. Rows locked by another writer are skipped and left for the next
+// batch. Transactions exported to archive files are not re-encrypted
+func (p postgresService) ReencryptTransactions(ctx context.Context, limit int) (int, error) {
+	fields := p.encryptor.Fields()
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	activeKeyID, err := p.encryptor.ActiveKeyID(ctx)
+	if err != nil {
+		return 0, internalError(ctx, "get active encryption key failed", err)
+	}
+
+	reencrypted := 0
+
+	for _, table := range reencryptedTables {
+		if reencrypted >= limit {
+			break
+		}
+
+		count, err := p.reencryptTable(ctx, table, fields, activeKeyID, limit-reencrypted)
+		reencrypted += count
+
+		if err != nil {
+			return reencrypted, err
+		}
+	}
+
+	return reencrypted, nil
+}
+
+// reencryptTable re-encrypts the additional fields of up to limit transactions of the table in a database transaction
+func (p postgresService) reencryptTable(ctx context.Context, table string, fields []string, activeKeyID string, limit int) (int, error) {
+	selectQuery := `
+	SELECT transaction_id, additional_fields
+	FROM ` + table + `
+	WHERE EXISTS (
+		SELECT 1
+		FROM unnest($1::text[]) AS field
+		WHERE jsonb_typeof(additional_fields->field) <> 'null'
+		AND additional_fields->field->>'key_id' IS DISTINCT FROM $2
+	)
+	ORDER BY transaction_id
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED`
+
+	updateQuery := `UPDATE ` + table + ` SET additional_fields = $1 WHERE transaction_id = $2`
+
+	reencrypted := 0
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, selectQuery, fields, activeKeyID, limit)
+		if err != nil {
+			return internalError(ctx, "execute query failed", err)
+		}
+
+		stale := map[string]map[string]interface{}{}
+
+		var ids []string
+
+		for rows.Next() {
+			var (
+				transactionID        string
+				additionalFieldsJSON string
+			)
+
+			err = rows.Scan(&transactionID, &additionalFieldsJSON)
+			if err != nil {
+				rows.Close()
+				return internalError(ctx, "scan row failed", err)
+			}
+
+			var additionalFields map[string]interface{}
+
+			err = json.Unmarshal([]byte(additionalFieldsJSON), &additionalFields)
+			if err != nil {
+				rows.Close()
+				return internalError(ctx, "unmarshal additional fields failed", err)
+			}
+
+			ids = append(ids, transactionID)
+			stale[transactionID] = additionalFields
+		}
+
+		rows.Close()
+
+		err = rows.Err()
+		if err != nil {
+			return internalError(ctx, "iterate rows failed", err)
+		}
+
+		for _, transactionID := range ids {
+			rotated, changed, err := p.encryptor.Rotate(ctx, stale[transactionID])
+			if err != nil {
+				return internalError(ctx, "reencrypt additional fields failed", err)
+			}
+
+			if !changed {
+				continue
+			}
+
+			_, err = tx.Exec(ctx, updateQuery, rotated, transactionID)
+			if err != nil {
+				return internalError(ctx, "update additional fields failed", err)
+			}
+
+			reencrypted++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return reencrypted, nil
+}
@@ -0,0 +1,205 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/encryption"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// staticKeyProvider key provider holding its keys in memory
+type staticKeyProvider struct {
+	activeKeyID string
+}
+
+func (p staticKeyProvider) ActiveKey(ctx context.Context) (*encryption.Key, error) {
+	return p.Key(ctx, p.activeKeyID)
+}
+
+func (p staticKeyProvider) Key(_ context.Context, keyID string) (*encryption.Key, error) {
+	return &encryption.Key{ID: keyID, Material: bytes.Repeat([]byte(keyID[len(keyID)-1:]), 32)}, nil
+}
+
+func (p staticKeyProvider) IndexKey(context.Context) ([]byte, error) {
+	return bytes.Repeat([]byte{3}, 32), nil
+}
+
+func newTestEncryptor(activeKeyID string) *encryption.FieldEncryptor {
+	return encryption.NewFieldEncryptor(staticKeyProvider{activeKeyID: activeKeyID}, []string{"customer_email"}, []string{"customer_email"})
+}
+
+// encryptedFields matches additional fields whose customer email is encrypted with the given key
+type encryptedFields struct {
+	keyID string
+}
+
+func (e encryptedFields) Match(value interface{}) bool {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	envelope, ok := fields["customer_email"].(map[string]interface{})
+
+	return ok && envelope["key_id"] == e.keyID && fields["charge_id"] == "ch_123"
+}
+
+func TestInsertTransactionEncrypted(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN123",
+		Status:        models.TransactionStatusSucceeded,
+		Provider:      models.PaymentProviderStripe,
+		Amount:        2000,
+		Currency:      "USD",
+		Type:          models.TransactionTypeCharge,
+		AdditionalFields: map[string]interface{}{
+			"charge_id":      "ch_123",
+			"customer_email": "jane@example.com",
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO transactions_history").WithArgs(
+		pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+		encryptedFields{keyID: "key-1"},
+		pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionCreated, models.AggregateTransaction, transaction.TransactionID, pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, audit.SystemActor, models.AuditActionCreatePayment, models.AuditResourceTransaction, transaction.TransactionID, "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock, encryptor: newTestEncryptor("key-1")}
+
+	entry := &models.AuditEntry{Action: models.AuditActionCreatePayment, ResourceType: models.AuditResourceTransaction, ResourceID: transaction.TransactionID}
+
+	err = service.InsertTransaction(context.Background(), transaction, entry)
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+	c.Equal("jane@example.com", transaction.AdditionalFields["customer_email"], "the caller keeps the plaintext")
+	c.NotContains(string(entry.After), "jane@example.com", "the audit snapshot holds the fields as stored")
+}
+
+func TestGetTransactionDecrypted(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	encryptor := newTestEncryptor("key-1")
+
+	stored, err := encryptor.Encrypt(context.Background(), map[string]interface{}{"charge_id": "ch_123", "customer_email": "jane@example.com"})
+	c.NoError(err)
+
+	storedJSON, err := json.Marshal(stored)
+	c.NoError(err)
+
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	columns := []string{"transaction_id", "merchant_id", "status", "description", "failure_reason", "payment_provider", "amount", "currency", "type", "additional_fields", "provider_fee", "platform_fee", "net_amount", "fee_currency", "version", "created_at", "updated_at"}
+
+	rows := mock.NewRows(columns).
+		AddRow("TXN123", "MCH_123", models.TransactionStatusSucceeded, "", "", models.PaymentProviderStripe, 2000, "usd", models.TransactionTypeCharge, string(storedJSON), 0, 0, 0, "", 1, createdAt, createdAt)
+
+	mock.ExpectQuery("FROM transactions_history").WithArgs("TXN123").WillReturnRows(rows)
+
+	// a key rotated since the value was encrypted can still decrypt it
+	service := postgresService{pool: mock, encryptor: newTestEncryptor("key-2")}
+
+	transaction, err := service.GetTransaction(context.Background(), "TXN123")
+	c.NoError(err)
+	c.Equal(map[string]interface{}{"charge_id": "ch_123", "customer_email": "jane@example.com"}, transaction.AdditionalFields)
+}
+
+func TestListTransactionsSearchBlindIndex(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	encryptor := newTestEncryptor("key-1")
+
+	indexes, err := encryptor.BlindIndexes(context.Background(), "jane@example.com")
+	c.NoError(err)
+
+	query := `
+	FROM transactions_history
+	WHERE (transaction_id = $1 OR description ILIKE '%' || $1 || '%' OR additional_fields::text LIKE '%"' || $1 || '"%' OR additional_fields @> ANY($2::jsonb[]))
+	ORDER BY created_at DESC, transaction_id DESC`
+
+	document := `{"customer_email":{"blind_index":"` + indexes["customer_email"] + `"}}`
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("jane@example.com", []string{document}).WillReturnRows(mock.NewRows([]string{"transaction_id"}))
+
+	service := postgresService{pool: mock, encryptor: encryptor}
+
+	transactions, err := service.ListTransactions(context.Background(), &models.TransactionFilter{Search: "jane@example.com"})
+	c.NoError(err)
+	c.Empty(transactions)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestReencryptTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	stored, err := newTestEncryptor("key-1").Encrypt(context.Background(), map[string]interface{}{"charge_id": "ch_123", "customer_email": "jane@example.com"})
+	c.NoError(err)
+
+	storedJSON, err := json.Marshal(stored)
+	c.NoError(err)
+
+	rows := mock.NewRows([]string{"transaction_id", "additional_fields"}).
+		AddRow("TXN_1", string(storedJSON)).
+		AddRow("TXN_2", `{"charge_id":"ch_123","customer_email":"jane@example.com"}`)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE SKIP LOCKED").WithArgs([]string{"customer_email"}, "key-2", 100).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE transactions_history SET additional_fields").WithArgs(encryptedFields{keyID: "key-2"}, "TXN_1").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("UPDATE transactions_history SET additional_fields").WithArgs(encryptedFields{keyID: "key-2"}, "TXN_2").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM transactions_archive").WithArgs([]string{"customer_email"}, "key-2", 98).
+		WillReturnRows(mock.NewRows([]string{"transaction_id", "additional_fields"}).AddRow("TXN_3", string(storedJSON)))
+	mock.ExpectExec("UPDATE transactions_archive SET additional_fields").WithArgs(encryptedFields{keyID: "key-2"}, "TXN_3").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock, encryptor: newTestEncryptor("key-2")}
+
+	reencrypted, err := service.ReencryptTransactions(context.Background(), 100)
+	c.NoError(err)
+	c.Equal(3, reencrypted, "archived transactions are re-encrypted with the rest of the batch")
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestReencryptTransactionsWithoutEncryption(t *testing.T) {
+	c := require.New(t)
+
+	service := postgresService{}
+
+	reencrypted, err := service.ReencryptTransactions(context.Background(), 100)
+	c.NoError(err)
+	c.Zero(reencrypted)
+}
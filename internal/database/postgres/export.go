@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// exportBatchSize rows fetched from the cursor at a time, which bounds the memory used by an export
+const exportBatchSize = 500
+
+// StreamTransactions calls fn for every transaction matching the filter, most recent first. Rows are read in
+// batches through a server-side cursor, so the result set is never held in memory
+func (p postgresService) StreamTransactions(ctx context.Context, filter *models.TransactionFilter, fn func(*models.Transaction) error) error {
+	searchDocuments, err := p.searchDocuments(ctx, filter.Search)
+	if err != nil {
+		return err
+	}
+
+	query, args := transactionsQuery(filter, searchDocuments)
+
+	// cursors only live within a database transaction, which is never committed as it doesn't write anything
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return internalError(ctx, "begin transaction failed", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DECLARE transactions_export NO SCROLL CURSOR FOR "+query, args...)
+	if err != nil {
+		return internalError(ctx, "declare cursor failed", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM transactions_export", exportBatchSize)
+
+	for {
+		fetched, err := p.fetchTransactions(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+
+		if fetched < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// fetchTransactions reads the next batch of the cursor, returning how many rows it contained
+func (p postgresService) fetchTransactions(ctx context.Context, tx pgx.Tx, fetch string, fn func(*models.Transaction) error) (int, error) {
+	rows, err := tx.Query(ctx, fetch)
+	if err != nil {
+		return 0, internalError(ctx, "fetch cursor failed", err)
+	}
+
+	defer rows.Close()
+
+	fetched := 0
+
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return 0, internalError(ctx, "scan row failed", err)
+		}
+
+		err = p.decryptTransaction(ctx, transaction)
+		if err != nil {
+			return 0, err
+		}
+
+		fetched++
+
+		err = fn(transaction)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return 0, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return fetched, nil
+}
+
+// ListAdditionalFieldKeys returns the sorted keys found in the additional fields of the transactions matching the
+// filter, regardless of its limit
+func (p postgresService) ListAdditionalFieldKeys(ctx context.Context, filter *models.TransactionFilter) ([]string, error) {
+	query := `
+	SELECT DISTINCT jsonb_object_keys(additional_fields) AS key
+	FROM transactions_history
+	WHERE jsonb_typeof(additional_fields) = 'object'`
+
+	searchDocuments, err := p.searchDocuments(ctx, filter.Search)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions, args := filterConditions(filter, searchDocuments)
+	if len(conditions) > 0 {
+		query += "\n\tAND " + strings.Join(conditions, "\n\tAND ")
+	}
+
+	query += "\n\tORDER BY key"
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
+
+	return keys, nil
+}
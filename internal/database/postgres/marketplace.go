@@ -0,0 +1,204 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// InsertConnectedAccount inserts a new connected account to the database along with its connected_account.created event
+// and the audit entry
+func (p postgresService) InsertConnectedAccount(ctx context.Context, account *models.ConnectedAccount, entry *models.AuditEntry) error {
+	query := `
+	INSERT INTO connected_accounts(
+		account_id,
+		merchant_id,
+		name,
+		payment_provider,
+		provider_account_id,
+		created_at
+	) VALUES($1, $2, $3, $4, $5, $6)`
+
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, query, account.AccountID, account.MerchantID, account.Name, account.Provider, account.ProviderAccountID, account.CreatedAt)
+		if err != nil {
+			return internalError(ctx, "execute query failed", err)
+		}
+
+		err = insertEvent(ctx, tx, models.EventTypeConnectedAccountCreated, models.AggregateConnectedAccount, account.AccountID, account)
+		if err != nil {
+			return err
+		}
+
+		entry.After = models.AuditSnapshot(account)
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+}
+
+// GetConnectedAccount fetches a connected account given its ID
+func (p postgresService) GetConnectedAccount(ctx context.Context, accountID string) (*models.ConnectedAccount, error) {
+	query := `
+	SELECT
+		account_id,
+		merchant_id,
+		name,
+		payment_provider,
+		provider_account_id,
+		created_at
+	FROM connected_accounts
+	WHERE account_id = $1
+	`
+
+	account, err := scanConnectedAccount(p.pool.QueryRow(ctx, query, accountID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, api.NewResourceNotFoundError(database.ErrConnectedAccountNotFound, "connected_account")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
+
+	return account, nil
+}
+
+// ListConnectedAccounts fetches the connected accounts of a merchant, oldest first
+func (p postgresService) ListConnectedAccounts(ctx context.Context, merchantID string) ([]*models.ConnectedAccount, error) {
+	query := `
+	SELECT
+		account_id,
+		merchant_id,
+		name,
+		payment_provider,
+		provider_account_id,
+		created_at
+	FROM connected_accounts
+	WHERE merchant_id = $1
+	ORDER BY created_at, account_id
+	`
+
+	rows, err := p.pool.Query(ctx, query, merchantID)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	accounts := []*models.ConnectedAccount{}
+
+	for rows.Next() {
+		account, err := scanConnectedAccount(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return accounts, nil
+}
+
+// ListTransfers fetches the transfers of a transaction, oldest first
+func (p postgresService) ListTransfers(ctx context.Context, transactionID string) ([]*models.Transfer, error) {
+	query := `
+	SELECT
+		transfer_id,
+		transaction_id,
+		account_id,
+		type,
+		amount,
+		currency,
+		COALESCE(provider_transfer_id, ''),
+		created_at
+	FROM transfers
+	WHERE transaction_id = $1
+	ORDER BY created_at, transfer_id
+	`
+
+	rows, err := p.pool.Query(ctx, query, transactionID)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	transfers := []*models.Transfer{}
+
+	for rows.Next() {
+		var transfer models.Transfer
+
+		err := rows.Scan(
+			&transfer.TransferID,
+			&transfer.TransactionID,
+			&transfer.AccountID,
+			&transfer.Type,
+			&transfer.Amount,
+			&transfer.Currency,
+			&transfer.ProviderTransferID,
+			&transfer.CreatedAt,
+		)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		transfers = append(transfers, &transfer)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return transfers, nil
+}
+
+// insertTransfers writes the transfers of a transaction in the same database transaction as the transaction itself
+func insertTransfers(ctx context.Context, tx pgx.Tx, transfers []*models.Transfer) error {
+	query := `
+	INSERT INTO transfers(
+		transfer_id,
+		transaction_id,
+		account_id,
+		type,
+		amount,
+		currency,
+		provider_transfer_id,
+		created_at
+	) VALUES($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)`
+
+	for _, transfer := range transfers {
+		_, err := tx.Exec(ctx, query, transfer.TransferID, transfer.TransactionID, transfer.AccountID, transfer.Type, transfer.Amount, transfer.Currency, transfer.ProviderTransferID, transfer.CreatedAt)
+		if err != nil {
+			return internalError(ctx, "insert transfer failed", err)
+		}
+	}
+
+	return nil
+}
+
+func scanConnectedAccount(row pgx.Row) (*models.ConnectedAccount, error) {
+	var account models.ConnectedAccount
+
+	err := row.Scan(
+		&account.AccountID,
+		&account.MerchantID,
+		&account.Name,
+		&account.Provider,
+		&account.ProviderAccountID,
+		&account.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertTransactionWithTransfers(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	transfer := &models.Transfer{
+		TransferID:         "TRF_123",
+		TransactionID:      "TXN_123",
+		AccountID:          "ACC_123",
+		Type:               models.TransferTypeTransfer,
+		Amount:             1800,
+		Currency:           "usd",
+		ProviderTransferID: "tr_123",
+		CreatedAt:          time.Now(),
+	}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusSucceeded,
+		Provider:      models.PaymentProviderStripe,
+		Amount:        2000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+		Transfers:     []*models.Transfer{transfer},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO transactions_history").WithArgs(
+		transaction.TransactionID,
+		transaction.MerchantID,
+		transaction.Status,
+		transaction.Description,
+		transaction.FailureReason,
+		transaction.Provider,
+		transaction.Amount,
+		transaction.Currency,
+		transaction.Type,
+		transaction.AdditionalFields,
+		transaction.ProviderFee,
+		transaction.PlatformFee,
+		transaction.NetAmount,
+		transaction.FeeCurrency,
+		pgxmock.AnyArg(),
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO transfers").WithArgs(
+		transfer.TransferID,
+		transfer.TransactionID,
+		transfer.AccountID,
+		transfer.Type,
+		transfer.Amount,
+		transfer.Currency,
+		transfer.ProviderTransferID,
+		transfer.CreatedAt,
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionCreated, models.AggregateTransaction, transaction.TransactionID, pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, audit.SystemActor, models.AuditActionCreatePayment, models.AuditResourceTransaction, transaction.TransactionID, "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	err = service.InsertTransaction(context.Background(), transaction, &models.AuditEntry{Action: models.AuditActionCreatePayment, ResourceType: models.AuditResourceTransaction, ResourceID: transaction.TransactionID})
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetConnectedAccountNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	mock.ExpectQuery("FROM connected_accounts").WithArgs("ACC_123").WillReturnError(pgx.ErrNoRows)
+
+	service := postgresService{pool: mock}
+
+	account, err := service.GetConnectedAccount(context.Background(), "ACC_123")
+	c.Nil(account)
+	c.ErrorIs(err, database.ErrConnectedAccountNotFound)
+}
+
+func TestListTransfers(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	rows := mock.NewRows([]string{"transfer_id", "transaction_id", "account_id", "type", "amount", "currency", "provider_transfer_id", "created_at"}).
+		AddRow("TRF_1", "TXN_123", "ACC_123", models.TransferTypeTransfer, 1800, "usd", "tr_123", createdAt).
+		AddRow("TRF_2", "TXN_123", "ACC_123", models.TransferTypeReversal, 1800, "usd", "trr_123", createdAt)
+
+	mock.ExpectQuery("FROM transfers").WithArgs("TXN_123").WillReturnRows(rows)
+
+	service := postgresService{pool: mock}
+
+	transfers, err := service.ListTransfers(context.Background(), "TXN_123")
+	c.NoError(err)
+	c.Len(transfers, 2)
+	c.Equal(models.TransferTypeReversal, transfers[1].Type)
+	c.Equal("trr_123", transfers[1].ProviderTransferID)
+}
@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// InsertMerchant inserts a new merchant to the database along with its merchant.created event and the audit entry
+func (p postgresService) InsertMerchant(ctx context.Context, merchant *models.Merchant, entry *models.AuditEntry) error {
+	query := `
+	INSERT INTO merchants(
+		merchant_id,
+		name,
+		created_at
+	) VALUES($1, $2, $3)`
+
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, query, merchant.MerchantID, merchant.Name, merchant.CreatedAt)
+		if err != nil {
+			return internalError(ctx, "execute query failed", err)
+		}
+
+		err = insertEvent(ctx, tx, models.EventTypeMerchantCreated, models.AggregateMerchant, merchant.MerchantID, merchant)
+		if err != nil {
+			return err
+		}
+
+		entry.After = models.AuditSnapshot(merchant)
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+}
+
+// InsertAPIKey inserts a new hashed API key to the database along with its api_key.created event, which never includes the hash
+func (p postgresService) InsertAPIKey(ctx context.Context, key *models.APIKey) error {
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		return insertAPIKey(ctx, tx, key)
+	})
+}
+
+// insertAPIKey inserts the API key and its api_key.created event in the transaction
+func insertAPIKey(ctx context.Context, tx pgx.Tx, key *models.APIKey) error {
+	query := `
+	INSERT INTO api_keys(
+		key_id,
+		merchant_id,
+		type,
+		mode,
+		prefix,
+		hash,
+		created_at,
+		expires_at
+	) VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := tx.Exec(ctx, query, key.KeyID, key.MerchantID, key.Type, key.Mode, key.Prefix, key.Hash, key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		return internalError(ctx, "execute query failed", err)
+	}
+
+	return insertEvent(ctx, tx, models.EventTypeAPIKeyCreated, models.AggregateAPIKey, key.KeyID, key)
+}
+
+// GetAPIKey fetches an API key given its ID
+func (p postgresService) GetAPIKey(ctx context.Context, keyID string) (*models.APIKey, error) {
+	query := `
+	SELECT
+		key_id,
+		merchant_id,
+		type,
+		mode,
+		prefix,
+		hash,
+		created_at,
+		expires_at
+	FROM api_keys
+	WHERE key_id = $1
+	`
+
+	return scanAPIKey(ctx, p.pool.QueryRow(ctx, query, keyID))
+}
+
+// GetAPIKeyByHash fetches an API key given the hash of its plaintext value
+func (p postgresService) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	query := `
+	SELECT
+		key_id,
+		merchant_id,
+		type,
+		mode,
+		prefix,
+		hash,
+		created_at,
+		expires_at
+	FROM api_keys
+	WHERE hash = $1
+	`
+
+	return scanAPIKey(ctx, p.pool.QueryRow(ctx, query, hash))
+}
+
+// RotateAPIKey sets the moment after which an active API key is no longer accepted and inserts its replacement, along
+// with their api_key.expired and api_key.created events and the audit entry. Only keys without an expiration are
+// rotated, so concurrent rotations of the same key never leave more than one replacement
+func (p postgresService) RotateAPIKey(ctx context.Context, keyID string, expiresAt time.Time, replacement *models.APIKey, entry *models.AuditEntry) error {
+	query := `
+	UPDATE api_keys
+	SET expires_at = $1
+	WHERE key_id = $2 AND expires_at IS NULL`
+
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, query, expiresAt, keyID)
+		if err != nil {
+			return internalError(ctx, "execute query failed", err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return api.NewConflictError(database.ErrAPIKeyRotated)
+		}
+
+		payload := map[string]interface{}{
+			"key_id":     keyID,
+			"expires_at": expiresAt,
+		}
+
+		err = insertEvent(ctx, tx, models.EventTypeAPIKeyExpired, models.AggregateAPIKey, keyID, payload)
+		if err != nil {
+			return err
+		}
+
+		err = insertAPIKey(ctx, tx, replacement)
+		if err != nil {
+			return err
+		}
+
+		entry.After = models.AuditSnapshot(replacement)
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+}
+
+func scanAPIKey(ctx context.Context, row pgx.Row) (*models.APIKey, error) {
+	var key models.APIKey
+
+	err := row.Scan(
+		&key.KeyID,
+		&key.MerchantID,
+		&key.Type,
+		&key.Mode,
+		&key.Prefix,
+		&key.Hash,
+		&key.CreatedAt,
+		&key.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, api.NewResourceNotFoundError(database.ErrAPIKeyNotFound, "api_key")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
+
+	return &key, nil
+}
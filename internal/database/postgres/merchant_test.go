@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertMerchant(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	merchant := &models.Merchant{MerchantID: "MCH_123", Name: "Acme", CreatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO merchants").WithArgs(merchant.MerchantID, merchant.Name, merchant.CreatedAt).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeMerchantCreated, models.AggregateMerchant, "MCH_123", pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, "alice", models.AuditActionCreateMerchant, models.AuditResourceMerchant, "MCH_123", "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	entry := &models.AuditEntry{
+		Actor:        "alice",
+		Action:       models.AuditActionCreateMerchant,
+		ResourceType: models.AuditResourceMerchant,
+		ResourceID:   "MCH_123",
+	}
+
+	err = service.InsertMerchant(context.Background(), merchant, entry)
+	c.NoError(err)
+	c.Contains(string(entry.After), `"name":"Acme"`)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestInsertAPIKey(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	key := &models.APIKey{
+		KeyID:      "KEY_123",
+		MerchantID: "MCH_123",
+		Type:       models.APIKeyTypeSecret,
+		Mode:       models.APIKeyModeTest,
+		Prefix:     "sk_test_abcd",
+		Hash:       "hash",
+		CreatedAt:  time.Now(),
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO api_keys").WithArgs(
+		key.KeyID,
+		key.MerchantID,
+		key.Type,
+		key.Mode,
+		key.Prefix,
+		key.Hash,
+		key.CreatedAt,
+		key.ExpiresAt,
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeAPIKeyCreated, models.AggregateAPIKey, key.KeyID, pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	err = service.InsertAPIKey(context.Background(), key)
+	c.NoError(err)
+}
+
+func TestGetAPIKeyByHash(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	expectedKey := &models.APIKey{
+		KeyID:      "KEY_123",
+		MerchantID: "MCH_123",
+		Type:       models.APIKeyTypeSecret,
+		Mode:       models.APIKeyModeTest,
+		Prefix:     "sk_test_abcd",
+		Hash:       "hash",
+		CreatedAt:  time.Now(),
+		ExpiresAt:  &expiresAt,
+	}
+
+	columns := []string{"key_id", "merchant_id", "type", "mode", "prefix", "hash", "created_at", "expires_at"}
+
+	rows := mock.NewRows(columns)
+
+	rows.AddRow(
+		expectedKey.KeyID,
+		expectedKey.MerchantID,
+		expectedKey.Type,
+		expectedKey.Mode,
+		expectedKey.Prefix,
+		expectedKey.Hash,
+		expectedKey.CreatedAt,
+		expectedKey.ExpiresAt,
+	)
+
+	mock.ExpectQuery("FROM api_keys").WithArgs("hash").WillReturnRows(rows)
+
+	service := postgresService{pool: mock}
+
+	key, err := service.GetAPIKeyByHash(context.Background(), "hash")
+	c.NoError(err)
+	c.Equal(expectedKey, key)
+}
+
+func TestGetAPIKeyByHashNoRows(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	mock.ExpectQuery("FROM api_keys").WithArgs("hash").WillReturnError(pgx.ErrNoRows)
+
+	service := postgresService{pool: mock}
+
+	key, err := service.GetAPIKeyByHash(context.Background(), "hash")
+	c.Nil(key)
+	c.ErrorIs(err, database.ErrAPIKeyNotFound)
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	expiresAt := time.Now()
+	replacement := &models.APIKey{KeyID: "KEY_456", MerchantID: "MCH_123", Type: models.APIKeyTypeSecret, Mode: models.APIKeyModeTest, CreatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE api_keys").WithArgs(expiresAt, "KEY_123").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeAPIKeyExpired, models.AggregateAPIKey, "KEY_123", pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO api_keys").WithArgs(
+		replacement.KeyID,
+		replacement.MerchantID,
+		replacement.Type,
+		replacement.Mode,
+		replacement.Prefix,
+		replacement.Hash,
+		replacement.CreatedAt,
+		replacement.ExpiresAt,
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeAPIKeyCreated, models.AggregateAPIKey, "KEY_456", pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, "KEY_789", models.AuditActionRotateAPIKey, models.AuditResourceAPIKey, "KEY_123", "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	err = service.RotateAPIKey(context.Background(), "KEY_123", expiresAt, replacement, &models.AuditEntry{
+		Actor:        "KEY_789",
+		Action:       models.AuditActionRotateAPIKey,
+		ResourceType: models.AuditResourceAPIKey,
+		ResourceID:   "KEY_123",
+	})
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestRotateAPIKeyFailure(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	expiresAt := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE api_keys").WithArgs(expiresAt, "KEY_123").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	err = service.RotateAPIKey(context.Background(), "KEY_123", expiresAt, &models.APIKey{KeyID: "KEY_456"}, &models.AuditEntry{})
+	c.ErrorIs(err, sql.ErrConnDone)
+}
+
+func TestRotateAPIKeyAlreadyRotated(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	expiresAt := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE api_keys").WithArgs(expiresAt, "KEY_123").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	err = service.RotateAPIKey(context.Background(), "KEY_123", expiresAt, &models.APIKey{KeyID: "KEY_456"}, &models.AuditEntry{})
+	c.ErrorIs(err, database.ErrAPIKeyRotated, "no replacement is inserted")
+	c.NoError(mock.ExpectationsWereMet())
+}
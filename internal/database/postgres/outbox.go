@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// withTx runs fn within a database transaction, which is only committed when fn succeeds
+func (p postgresService) withTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return internalError(ctx, "begin transaction failed", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return internalError(ctx, "commit transaction failed", err)
+	}
+
+	return nil
+}
+
+// insertEvent writes a domain event to the outbox in the same transaction as the change it describes,
+// so the event is published if and only if the change is committed
+func insertEvent(ctx context.Context, tx pgx.Tx, eventType models.EventType, aggregateType, aggregateID string, payload interface{}) error {
+	marshalledPayload, err := json.Marshal(payload)
+	if err != nil {
+		return internalError(ctx, "marshal event payload failed", err)
+	}
+
+	query := `
+	INSERT INTO outbox(
+		event_id,
+		event_type,
+		aggregate_type,
+		aggregate_id,
+		payload
+	) VALUES($1, $2, $3, $4, $5)`
+
+	eventID := fmt.Sprintf("EVT_%s", ulid.Make().String())
+
+	_, err = tx.Exec(ctx, query, eventID, eventType, aggregateType, aggregateID, string(marshalledPayload))
+	if err != nil {
+		return internalError(ctx, "insert outbox event failed", err)
+	}
+
+	return nil
+}
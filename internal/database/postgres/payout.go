@@ -0,0 +1,278 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// UpsertPayout inserts the payout or updates the one with the same provider payout ID, keeping final statuses since
+// the provider doesn't guarantee the order its events are delivered in, along with the audit entry of the stored payout
+func (p postgresService) UpsertPayout(ctx context.Context, payout *models.Payout, entry *models.AuditEntry) error {
+	query := `
+	INSERT INTO payouts(
+		payout_id,
+		payment_provider,
+		provider_payout_id,
+		status,
+		amount,
+		currency,
+		failure_reason,
+		arrival_date,
+		created_at,
+		updated_at
+	) VALUES($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $9)
+	ON CONFLICT (payment_provider, provider_payout_id) DO UPDATE SET
+		status = CASE WHEN payouts.status IN ('paid', 'failed', 'canceled') THEN payouts.status ELSE EXCLUDED.status END,
+		amount = EXCLUDED.amount,
+		currency = EXCLUDED.currency,
+		failure_reason = COALESCE(EXCLUDED.failure_reason, payouts.failure_reason),
+		arrival_date = EXCLUDED.arrival_date,
+		updated_at = EXCLUDED.updated_at
+	RETURNING
+		payout_id,
+		payment_provider,
+		provider_payout_id,
+		status,
+		amount,
+		currency,
+		COALESCE(failure_reason, ''),
+		arrival_date,
+		created_at,
+		updated_at
+	`
+
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		row := tx.QueryRow(ctx, query, payout.PayoutID, payout.Provider, payout.ProviderPayoutID, payout.Status, payout.Amount, payout.Currency, payout.FailureReason, payout.ArrivalDate, payout.UpdatedAt)
+
+		stored, err := scanPayout(row)
+		if err != nil {
+			return internalError(ctx, "upsert payout failed", err)
+		}
+
+		*payout = *stored
+
+		// a payout already stored keeps its ID
+		entry.ResourceID = stored.PayoutID
+		entry.After = models.AuditSnapshot(stored)
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+}
+
+// GetPayout fetches a payout given its ID
+func (p postgresService) GetPayout(ctx context.Context, payoutID string) (*models.Payout, error) {
+	query := `
+	SELECT
+		payout_id,
+		payment_provider,
+		provider_payout_id,
+		status,
+		amount,
+		currency,
+		COALESCE(failure_reason, ''),
+		arrival_date,
+		created_at,
+		updated_at
+	FROM payouts
+	WHERE payout_id = $1
+	`
+
+	payout, err := scanPayout(p.pool.QueryRow(ctx, query, payoutID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, api.NewResourceNotFoundError(database.ErrPayoutNotFound, "payout")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
+
+	return payout, nil
+}
+
+// ListPayouts fetches the payouts matching the filter, most recent first
+func (p postgresService) ListPayouts(ctx context.Context, filter *models.PayoutFilter) ([]*models.Payout, error) {
+	query := `
+	SELECT
+		payout_id,
+		payment_provider,
+		provider_payout_id,
+		status,
+		amount,
+		currency,
+		COALESCE(failure_reason, ''),
+		arrival_date,
+		created_at,
+		updated_at
+	FROM payouts`
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if len(conditions) > 0 {
+		query += "\n\tWHERE " + strings.Join(conditions, "\n\tAND ")
+	}
+
+	query += "\n\tORDER BY created_at DESC, payout_id DESC"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\tLIMIT $%d", len(args))
+	}
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	payouts := []*models.Payout{}
+
+	for rows.Next() {
+		payout, err := scanPayout(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		payouts = append(payouts, payout)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return payouts, nil
+}
+
+// LinkPayoutTransactions links every item of the payout to the transaction holding its charge or refund ID
+func (p postgresService) LinkPayoutTransactions(ctx context.Context, payoutID string, items []*models.PayoutItem) (int, error) {
+	query := `
+	INSERT INTO payout_transactions(
+		provider_balance_transaction_id,
+		payout_id,
+		transaction_id,
+		amount,
+		fee,
+		net,
+		currency
+	)
+	SELECT $1, $2, transaction_id, $4, $5, $6, $7
+	FROM transactions_history
+	WHERE additional_fields->>'charge_id' = $3 OR additional_fields->>'refund_id' = $3
+	LIMIT 1
+	ON CONFLICT (provider_balance_transaction_id) DO NOTHING`
+
+	linked := 0
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		for _, item := range items {
+			tag, err := tx.Exec(ctx, query, item.ProviderBalanceTransactionID, payoutID, item.ProviderSourceID, item.Amount, item.Fee, item.Net, item.Currency)
+			if err != nil {
+				return internalError(ctx, "link payout transaction failed", err)
+			}
+
+			linked += int(tag.RowsAffected())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return linked, nil
+}
+
+// ListPayoutTransactions fetches the transactions settled by a payout, oldest first
+func (p postgresService) ListPayoutTransactions(ctx context.Context, payoutID string) ([]*models.Transaction, error) {
+	query := `
+	SELECT
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	FROM transactions_history
+	WHERE transaction_id IN (SELECT transaction_id FROM payout_transactions WHERE payout_id = $1)
+	ORDER BY created_at, transaction_id
+	`
+
+	rows, err := p.pool.Query(ctx, query, payoutID)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	transactions := []*models.Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		err = p.decryptTransaction(ctx, transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return transactions, nil
+}
+
+func scanPayout(row pgx.Row) (*models.Payout, error) {
+	var payout models.Payout
+
+	err := row.Scan(
+		&payout.PayoutID,
+		&payout.Provider,
+		&payout.ProviderPayoutID,
+		&payout.Status,
+		&payout.Amount,
+		&payout.Currency,
+		&payout.FailureReason,
+		&payout.ArrivalDate,
+		&payout.CreatedAt,
+		&payout.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &payout, nil
+}
@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+var payoutColumns = []string{"payout_id", "payment_provider", "provider_payout_id", "status", "amount", "currency", "failure_reason", "arrival_date", "created_at", "updated_at"}
+
+func TestUpsertPayoutKeepsStoredPayoutID(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	arrivalDate := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	payout := &models.Payout{
+		PayoutID:         "PO_NEW",
+		Provider:         models.PaymentProviderStripe,
+		ProviderPayoutID: "po_123",
+		Status:           models.PayoutStatusPaid,
+		Amount:           1912,
+		Currency:         "usd",
+		ArrivalDate:      arrivalDate,
+		UpdatedAt:        time.Now(),
+	}
+
+	rows := mock.NewRows(payoutColumns).
+		AddRow("PO_123", models.PaymentProviderStripe, "po_123", models.PayoutStatusPaid, 1912, "usd", "", arrivalDate, createdAt, payout.UpdatedAt)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO payouts").WithArgs(
+		payout.PayoutID,
+		payout.Provider,
+		payout.ProviderPayoutID,
+		payout.Status,
+		payout.Amount,
+		payout.Currency,
+		payout.FailureReason,
+		payout.ArrivalDate,
+		payout.UpdatedAt,
+	).WillReturnRows(rows)
+	expectAuditEntry(mock, audit.SystemActor, models.AuditActionUpdatePayout, models.AuditResourcePayout, "PO_123", "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	entry := &models.AuditEntry{Action: models.AuditActionUpdatePayout, ResourceType: models.AuditResourcePayout}
+
+	err = service.UpsertPayout(context.Background(), payout, entry)
+	c.NoError(err)
+	c.Equal("PO_123", payout.PayoutID)
+	c.Equal(createdAt, payout.CreatedAt)
+	c.Equal("PO_123", entry.ResourceID, "the entry names the stored payout")
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetPayoutNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	mock.ExpectQuery("FROM payouts").WithArgs("PO_123").WillReturnError(pgx.ErrNoRows)
+
+	service := postgresService{pool: mock}
+
+	payout, err := service.GetPayout(context.Background(), "PO_123")
+	c.Nil(payout)
+	c.ErrorIs(err, database.ErrPayoutNotFound)
+}
+
+func TestListPayouts(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	rows := mock.NewRows(payoutColumns).
+		AddRow("PO_123", models.PaymentProviderStripe, "po_123", models.PayoutStatusFailed, 1912, "usd", "account_closed", createdAt, createdAt, createdAt)
+
+	mock.ExpectQuery(`FROM payouts\s+WHERE status = \$1\s+ORDER BY created_at DESC, payout_id DESC\s+LIMIT \$2`).
+		WithArgs(models.PayoutStatusFailed, 5).
+		WillReturnRows(rows)
+
+	service := postgresService{pool: mock}
+
+	payouts, err := service.ListPayouts(context.Background(), &models.PayoutFilter{Status: models.PayoutStatusFailed, Limit: 5})
+	c.NoError(err)
+	c.Len(payouts, 1)
+	c.Equal("account_closed", payouts[0].FailureReason)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestLinkPayoutTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	items := []*models.PayoutItem{
+		{ProviderBalanceTransactionID: "txn_1", ProviderSourceID: "ch_123", Amount: 2000, Fee: 88, Net: 1912, Currency: "usd"},
+		{ProviderBalanceTransactionID: "txn_2", ProviderSourceID: "ch_unknown", Amount: 500, Fee: 45, Net: 455, Currency: "usd"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO payout_transactions").WithArgs("txn_1", "PO_123", "ch_123", 2000, 88, 1912, "usd").WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO payout_transactions").WithArgs("txn_2", "PO_123", "ch_unknown", 500, 45, 455, "usd").WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	linked, err := service.LinkPayoutTransactions(context.Background(), "PO_123", items)
+	c.NoError(err)
+	c.Equal(1, linked)
+	c.NoError(mock.ExpectationsWereMet())
+}
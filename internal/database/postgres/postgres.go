@@ -5,10 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/encryption"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -19,37 +23,74 @@ import (
 type pgxIface interface {
 	Begin(context.Context) (pgx.Tx, error)
 	Close()
+	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
 	QueryRow(context.Context, string, ...interface{}) pgx.Row
 	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+	Ping(context.Context) error
 }
 
 type postgresService struct {
 	pool pgxIface
+	// encryptor encrypts the designated additional fields of transactions, where nil stores them in plaintext
+	encryptor *encryption.FieldEncryptor
 }
 
 // Init initializes PostgreSQL implementation
-func Init(ctx context.Context) (database.Database, error) {
-	host := os.Getenv("DATABASE_HOST")
-	port := os.Getenv("DATABASE_PORT")
-	user := os.Getenv("DATABASE_USER")
-	password := os.Getenv("DATABASE_PASSWORD")
-	databaseName := os.Getenv("DATABASE_NAME")
+func Init(ctx context.Context, cfg config.Database, encryptor *encryption.FieldEncryptor) (database.Database, error) {
+	pool, err := Connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	connectionString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, databaseName)
+	return New(pool, encryptor), nil
+}
 
-	pool, err := pgxpool.New(ctx, connectionString)
+// Connect creates a connection pool to PostgreSQL and checks the database is reachable
+func Connect(ctx context.Context, cfg config.Database) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.New(ctx, cfg.ConnectionString())
 	if err != nil {
 		return nil, fmt.Errorf("create new user pool failed: %w", err)
 	}
 
 	err = pool.Ping(ctx)
 	if err != nil {
+		pool.Close()
+
 		return nil, fmt.Errorf("check connection to database failed: %w", err)
 	}
 
+	return pool, nil
+}
+
+// New initializes PostgreSQL implementation on top of an existing pool, encrypting the additional fields designated
+// by the encryptor
+func New(pool *pgxpool.Pool, encryptor *encryption.FieldEncryptor) database.Database {
 	return postgresService{
-		pool: pool,
-	}, nil
+		pool:      pool,
+		encryptor: encryptor,
+	}
+}
+
+// internalError logs a failed database operation and wraps it as an internal server error
+func internalError(ctx context.Context, message string, err error) error {
+	slog.ErrorContext(ctx, "database operation failed", slog.String("operation", message), slog.Any("error", err))
+
+	return api.NewInternalServerError(fmt.Errorf("%s: %w", message, err))
+}
+
+// Stat returns the connection statistics of the underlying pool
+func (p postgresService) Stat() *pgxpool.Stat {
+	pool, ok := p.pool.(*pgxpool.Pool)
+	if !ok {
+		return nil
+	}
+
+	return pool.Stat()
+}
+
+// Ping checks that a connection to the database can be acquired
+func (p postgresService) Ping(ctx context.Context) error {
+	return p.pool.Ping(ctx)
 }
 
 // Close closes the pool connection
@@ -57,11 +98,31 @@ func (p postgresService) Close() {
 	p.pool.Close()
 }
 
-// InsertTransaction inserts a new item to the database
-func (p postgresService) InsertTransaction(ctx context.Context, transaction *models.Transaction) error {
+// updateConflict tells apart a missing transaction from one updated concurrently after a compare-and-swap matched no rows
+func updateConflict(ctx context.Context, tx pgx.Tx, transactionID string) error {
+	query := `SELECT version FROM transactions_history WHERE transaction_id = $1`
+
+	var version int
+
+	err := tx.QueryRow(ctx, query, transactionID).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return api.NewResourceNotFoundError(database.ErrTransactionNotFound, "transaction")
+	}
+
+	if err != nil {
+		return internalError(ctx, "scan row failed", err)
+	}
+
+	return api.NewConflictError(database.ErrTransactionVersionConflict)
+}
+
+// InsertTransaction inserts a new item to the database along with its transfers, risk decision, review, transaction.created
+// event and audit entry
+func (p postgresService) InsertTransaction(ctx context.Context, transaction *models.Transaction, entry *models.AuditEntry) error {
 	query := `
 	INSERT INTO transactions_history(
 		transaction_id,
+		merchant_id,
 		status,
 		description,
 		failure_reason,
@@ -69,15 +130,61 @@ func (p postgresService) InsertTransaction(ctx context.Context, transaction *mod
 		amount,
 		currency,
 		type,
-		additional_fields
-	) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		fee_currency,
+		created_at,
+		updated_at
+	) VALUES($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), $15, $15)`
+
+	now := time.Now().UTC()
 
-	_, err := p.pool.Exec(ctx, query, transaction.TransactionID, transaction.Status, transaction.Description, transaction.FailureReason, transaction.Provider, transaction.Amount, transaction.Currency, transaction.Type, transaction.AdditionalFields)
+	additionalFields, err := p.encryptFields(ctx, transaction.AdditionalFields)
 	if err != nil {
-		return api.NewInternalServerError(fmt.Errorf("execute query failed: %w", err))
+		return err
 	}
 
-	return nil
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, query, transaction.TransactionID, transaction.MerchantID, transaction.Status, transaction.Description, transaction.FailureReason, transaction.Provider, transaction.Amount, transaction.Currency, transaction.Type, additionalFields, transaction.ProviderFee, transaction.PlatformFee, transaction.NetAmount, transaction.FeeCurrency, now)
+		if err != nil {
+			return internalError(ctx, "execute query failed", err)
+		}
+
+		// rows start at version 1
+		transaction.Version = 1
+		transaction.CreatedAt = now
+		transaction.UpdatedAt = now
+
+		err = insertTransfers(ctx, tx, transaction.Transfers)
+		if err != nil {
+			return err
+		}
+
+		err = insertRiskDecision(ctx, tx, transaction.RiskDecision)
+		if err != nil {
+			return err
+		}
+
+		err = insertReview(ctx, tx, transaction.Review)
+		if err != nil {
+			return err
+		}
+
+		// the event carries the additional fields as stored
+		stored := *transaction
+		stored.AdditionalFields = additionalFields
+
+		err = insertEvent(ctx, tx, models.EventTypeTransactionCreated, models.AggregateTransaction, transaction.TransactionID, &stored)
+		if err != nil {
+			return err
+		}
+
+		entry.After = models.AuditSnapshot(&stored)
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
 }
 
 // GetTransaction fetches an item given its ID
@@ -85,6 +192,7 @@ func (p postgresService) GetTransaction(ctx context.Context, transactionID strin
 	query := `
 	SELECT
 		transaction_id,
+		COALESCE(merchant_id, ''),
 		status,
 		description,
 		failure_reason,
@@ -92,56 +200,173 @@ func (p postgresService) GetTransaction(ctx context.Context, transactionID strin
 		amount,
 		currency,
 		type,
-		additional_fields
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
 	FROM transactions_history
 	WHERE transaction_id = $1
 	`
 
-	row := p.pool.QueryRow(ctx, query, transactionID)
+	transaction, err := scanTransaction(p.pool.QueryRow(ctx, query, transactionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return p.getArchivedTransaction(ctx, transactionID)
+	}
 
-	var transaction models.Transaction
-	var additionalFieldsJSON string
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
 
-	err := row.Scan(
-		&transaction.TransactionID,
-		&transaction.Status,
-		&transaction.Description,
-		&transaction.FailureReason,
-		&transaction.Provider,
-		&transaction.Amount,
-		&transaction.Currency,
-		&transaction.Type,
-		&additionalFieldsJSON,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, api.NewResourceNotFoundError(database.ErrTransactionNotFound, "transaction")
+	err = p.decryptTransaction(ctx, transaction)
+	if err != nil {
+		return nil, err
 	}
 
+	return transaction, nil
+}
+
+// UpdateTransaction updates an item given its ID, as long as its version still matches the one of the updated transaction,
+// along with the transfers of the updated transaction, its transaction.updated event and the audit entry when given. The
+// provider fee of the updated transaction is added to the stored one
+func (p postgresService) UpdateTransaction(ctx context.Context, transactionID string, updatedTransaction *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error) {
+	query := `
+	UPDATE transactions_history
+	SET
+		status = COALESCE($1, status),
+		type = COALESCE($2, type),
+		additional_fields = COALESCE($3, additional_fields),
+		provider_fee = provider_fee + $4,
+		fee_currency = COALESCE(NULLIF($5, ''), fee_currency),
+		version = version + 1,
+		updated_at = NOW()
+	WHERE transaction_id = $6 AND version = $7
+	RETURNING
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	`
+
+	var transaction *models.Transaction
+
+	additionalFields, err := p.encryptFields(ctx, updatedTransaction.AdditionalFields)
 	if err != nil {
-		return nil, api.NewInternalServerError(fmt.Errorf("scan row failed: %w", err))
+		return nil, err
 	}
 
-	if additionalFieldsJSON != "" {
-		err = json.Unmarshal([]byte(additionalFieldsJSON), &transaction.AdditionalFields)
+	err = p.withTx(ctx, func(tx pgx.Tx) error {
+		var err error
+
+		transaction, err = scanTransaction(tx.QueryRow(ctx, query, updatedTransaction.Status, updatedTransaction.Type, additionalFields, updatedTransaction.ProviderFee, updatedTransaction.FeeCurrency, transactionID, updatedTransaction.Version))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return updateConflict(ctx, tx, transactionID)
+		}
+
+		if err != nil {
+			return internalError(ctx, "update and scan row failed", err)
+		}
+
+		err = insertTransfers(ctx, tx, updatedTransaction.Transfers)
+		if err != nil {
+			return err
+		}
+
+		transaction.Transfers = updatedTransaction.Transfers
+
+		err = insertEvent(ctx, tx, models.EventTypeTransactionUpdated, models.AggregateTransaction, transaction.TransactionID, transaction)
 		if err != nil {
-			return nil, api.NewInternalServerError(fmt.Errorf("unmarshal value failed: %w", err))
+			return err
+		}
+
+		if entry == nil {
+			return nil
 		}
+
+		entry.After = models.AuditSnapshot(transaction)
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &transaction, nil
+	err = p.decryptTransaction(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
 }
 
-// UpdateTransaction updates an item given its ID
-func (p postgresService) UpdateTransaction(ctx context.Context, transactionID string, updatedTransaction *models.Transaction) (*models.Transaction, error) {
+// ListTransactions fetches the transactions matching the filter, most recent first
+func (p postgresService) ListTransactions(ctx context.Context, filter *models.TransactionFilter) ([]*models.Transaction, error) {
+	searchDocuments, err := p.searchDocuments(ctx, filter.Search)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args := transactionsQuery(filter, searchDocuments)
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	transactions := []*models.Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		err = p.decryptTransaction(ctx, transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return transactions, nil
+}
+
+// ForceTransactionStatus overrides the status of a transaction, along with its transaction.updated event and the audit entry
+func (p postgresService) ForceTransactionStatus(ctx context.Context, transactionID string, status models.TransactionStatus, entry *models.AuditEntry) (*models.Transaction, error) {
 	query := `
 	UPDATE transactions_history
 	SET
-		status = COALESCE($1, status),
-		type = COALESCE($2, type),
-		additional_fields = COALESCE($3, additional_fields)
-	WHERE transaction_id = $4
+		status = $1,
+		version = version + 1,
+		updated_at = NOW()
+	WHERE transaction_id = $2
 	RETURNING
 		transaction_id,
+		COALESCE(merchant_id, ''),
 		status,
 		description,
 		failure_reason,
@@ -149,16 +374,151 @@ func (p postgresService) UpdateTransaction(ctx context.Context, transactionID st
 		amount,
 		currency,
 		type,
-		additional_fields
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
 	`
 
-	row := p.pool.QueryRow(ctx, query, updatedTransaction.Status, updatedTransaction.Type, updatedTransaction.AdditionalFields, transactionID)
+	var transaction *models.Transaction
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		var err error
+
+		transaction, err = scanTransaction(tx.QueryRow(ctx, query, status, transactionID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return api.NewResourceNotFoundError(database.ErrTransactionNotFound, "transaction")
+		}
+
+		if err != nil {
+			return internalError(ctx, "update and scan row failed", err)
+		}
+
+		err = insertEvent(ctx, tx, models.EventTypeTransactionUpdated, models.AggregateTransaction, transaction.TransactionID, transaction)
+		if err != nil {
+			return err
+		}
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.decryptTransaction(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// transactionsQuery builds the query selecting every column of the transactions matching the filter, most recent first
+func transactionsQuery(filter *models.TransactionFilter, searchDocuments []string) (string, []interface{}) {
+	query := `
+	SELECT
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	FROM transactions_history`
+
+	conditions, args := filterConditions(filter, searchDocuments)
+	if len(conditions) > 0 {
+		query += "\n\tWHERE " + strings.Join(conditions, "\n\tAND ")
+	}
+
+	query += "\n\tORDER BY created_at DESC, transaction_id DESC"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\tLIMIT $%d", len(args))
+	}
+
+	return query, args
+}
+
+// filterConditions builds the WHERE conditions matching the filter along with their arguments. Encrypted fields are
+// searched by matching any of the search documents built from the blind indexes of the searched value
+func filterConditions(filter *models.TransactionFilter, searchDocuments []string) ([]string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.MerchantID != "" {
+		add("merchant_id = $%d", filter.MerchantID)
+	}
+
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+
+	if filter.Type != "" {
+		add("type = $%d", filter.Type)
+	}
+
+	if filter.Provider != "" {
+		add("payment_provider = $%d", filter.Provider)
+	}
+
+	if filter.Currency != "" {
+		add("LOWER(currency) = LOWER($%d)", filter.Currency)
+	}
+
+	if filter.CreatedFrom != nil {
+		add("created_at >= $%d", *filter.CreatedFrom)
+	}
+
+	if filter.CreatedTo != nil {
+		add("created_at < $%d", *filter.CreatedTo)
+	}
+
+	if filter.Search != "" {
+		args = append(args, filter.Search)
+		search := fmt.Sprintf("transaction_id = $%[1]d OR description ILIKE '%%' || $%[1]d || '%%' OR additional_fields::text LIKE '%%\"' || $%[1]d || '\"%%'", len(args))
+
+		if len(searchDocuments) > 0 {
+			args = append(args, searchDocuments)
+			search += fmt.Sprintf(" OR additional_fields @> ANY($%d::jsonb[])", len(args))
+		}
+
+		conditions = append(conditions, "("+search+")")
+	}
+
+	return conditions, args
+}
 
+// scanTransaction reads a transaction selected with every column of transactions_history
+func scanTransaction(row pgx.Row) (*models.Transaction, error) {
 	var transaction models.Transaction
 	var additionalFieldsJSON string
 
 	err := row.Scan(
 		&transaction.TransactionID,
+		&transaction.MerchantID,
 		&transaction.Status,
 		&transaction.Description,
 		&transaction.FailureReason,
@@ -167,15 +527,22 @@ func (p postgresService) UpdateTransaction(ctx context.Context, transactionID st
 		&transaction.Currency,
 		&transaction.Type,
 		&additionalFieldsJSON,
+		&transaction.ProviderFee,
+		&transaction.PlatformFee,
+		&transaction.NetAmount,
+		&transaction.FeeCurrency,
+		&transaction.Version,
+		&transaction.CreatedAt,
+		&transaction.UpdatedAt,
 	)
 	if err != nil {
-		return nil, api.NewInternalServerError(fmt.Errorf("update and scan row failed: %w", err))
+		return nil, err
 	}
 
 	if additionalFieldsJSON != "" {
 		err = json.Unmarshal([]byte(additionalFieldsJSON), &transaction.AdditionalFields)
 		if err != nil {
-			return nil, api.NewInternalServerError(fmt.Errorf("unmarshal value failed: %w", err))
+			return nil, fmt.Errorf("unmarshal additional fields: %w", err)
 		}
 	}
 
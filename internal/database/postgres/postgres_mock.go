@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"time"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/stretchr/testify/mock"
@@ -13,8 +14,8 @@ type MockPostgres struct {
 }
 
 // InsertTransaction mocks operation to insert an item to the database
-func (m *MockPostgres) InsertTransaction(ctx context.Context, transaction *models.Transaction) error {
-	args := m.Called(ctx, transaction)
+func (m *MockPostgres) InsertTransaction(ctx context.Context, transaction *models.Transaction, entry *models.AuditEntry) error {
+	args := m.Called(ctx, transaction, entry)
 
 	return args.Error(0)
 }
@@ -27,11 +28,533 @@ func (m *MockPostgres) GetTransaction(ctx context.Context, transactionID string)
 }
 
 // UpdateTransaction mocks operation to update an item given its ID
-func (m *MockPostgres) UpdateTransaction(ctx context.Context, transactionID string, updatedTransaction *models.Transaction) (*models.Transaction, error) {
-	args := m.Called(ctx, transactionID, updatedTransaction)
+func (m *MockPostgres) UpdateTransaction(ctx context.Context, transactionID string, updatedTransaction *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error) {
+	args := m.Called(ctx, transactionID, updatedTransaction, entry)
 
 	return args.Get(0).(*models.Transaction), args.Error(1)
 }
 
+// ListTransactions mocks operation to list the items matching a filter
+func (m *MockPostgres) ListTransactions(ctx context.Context, filter *models.TransactionFilter) ([]*models.Transaction, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Transaction), args.Error(1)
+}
+
+// StreamTransactions mocks operation to iterate over the items matching a filter, calling fn with each of the
+// transactions the mock returns
+func (m *MockPostgres) StreamTransactions(ctx context.Context, filter *models.TransactionFilter, fn func(*models.Transaction) error) error {
+	args := m.Called(ctx, filter, fn)
+
+	if transactions, ok := args.Get(0).([]*models.Transaction); ok {
+		for _, transaction := range transactions {
+			err := fn(transaction)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return args.Error(1)
+}
+
+// ListAdditionalFieldKeys mocks operation to list the additional field keys of the items matching a filter
+func (m *MockPostgres) ListAdditionalFieldKeys(ctx context.Context, filter *models.TransactionFilter) ([]string, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// ForceTransactionStatus mocks operation to override the status of an item
+func (m *MockPostgres) ForceTransactionStatus(ctx context.Context, transactionID string, status models.TransactionStatus, entry *models.AuditEntry) (*models.Transaction, error) {
+	args := m.Called(ctx, transactionID, status, entry)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.Transaction), args.Error(1)
+}
+
+// ReencryptTransactions mocks operation to encrypt the additional fields of items with the active key
+func (m *MockPostgres) ReencryptTransactions(ctx context.Context, limit int) (int, error) {
+	args := m.Called(ctx, limit)
+
+	return args.Int(0), args.Error(1)
+}
+
+// InsertMerchant mocks operation to insert a merchant to the database
+func (m *MockPostgres) InsertMerchant(ctx context.Context, merchant *models.Merchant, entry *models.AuditEntry) error {
+	args := m.Called(ctx, merchant, entry)
+
+	return args.Error(0)
+}
+
+// InsertAPIKey mocks operation to insert an API key to the database
+func (m *MockPostgres) InsertAPIKey(ctx context.Context, key *models.APIKey) error {
+	args := m.Called(ctx, key)
+
+	return args.Error(0)
+}
+
+// GetAPIKey mocks operation to fetch an API key given its ID
+func (m *MockPostgres) GetAPIKey(ctx context.Context, keyID string) (*models.APIKey, error) {
+	args := m.Called(ctx, keyID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+// GetAPIKeyByHash mocks operation to fetch an API key given its hash
+func (m *MockPostgres) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	args := m.Called(ctx, hash)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+// RotateAPIKey mocks operation to expire an API key and insert its replacement
+func (m *MockPostgres) RotateAPIKey(ctx context.Context, keyID string, expiresAt time.Time, replacement *models.APIKey, entry *models.AuditEntry) error {
+	args := m.Called(ctx, keyID, expiresAt, replacement, entry)
+
+	return args.Error(0)
+}
+
+// InsertWebhookEvent mocks operation to store a webhook event
+func (m *MockPostgres) InsertWebhookEvent(ctx context.Context, event *models.WebhookEvent) error {
+	args := m.Called(ctx, event)
+
+	return args.Error(0)
+}
+
+// GetWebhookEvent mocks operation to fetch a stored webhook event given its ID
+func (m *MockPostgres) GetWebhookEvent(ctx context.Context, eventID string) (*models.WebhookEvent, error) {
+	args := m.Called(ctx, eventID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.WebhookEvent), args.Error(1)
+}
+
+// RecordWebhookEventOutcome mocks operation to record an attempt to process a webhook event
+func (m *MockPostgres) RecordWebhookEventOutcome(ctx context.Context, eventID string, processingErr error) error {
+	args := m.Called(ctx, eventID, processingErr)
+
+	return args.Error(0)
+}
+
+// InsertConnectedAccount mocks operation to insert a connected account to the database
+func (m *MockPostgres) InsertConnectedAccount(ctx context.Context, account *models.ConnectedAccount, entry *models.AuditEntry) error {
+	args := m.Called(ctx, account, entry)
+
+	return args.Error(0)
+}
+
+// GetConnectedAccount mocks operation to fetch a connected account given its ID
+func (m *MockPostgres) GetConnectedAccount(ctx context.Context, accountID string) (*models.ConnectedAccount, error) {
+	args := m.Called(ctx, accountID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.ConnectedAccount), args.Error(1)
+}
+
+// ListConnectedAccounts mocks operation to list the connected accounts of a merchant
+func (m *MockPostgres) ListConnectedAccounts(ctx context.Context, merchantID string) ([]*models.ConnectedAccount, error) {
+	args := m.Called(ctx, merchantID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.ConnectedAccount), args.Error(1)
+}
+
+// ListTransfers mocks operation to list the transfers of a transaction
+func (m *MockPostgres) ListTransfers(ctx context.Context, transactionID string) ([]*models.Transfer, error) {
+	args := m.Called(ctx, transactionID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Transfer), args.Error(1)
+}
+
+// UpsertPayout mocks operation to insert or update a payout
+func (m *MockPostgres) UpsertPayout(ctx context.Context, payout *models.Payout, entry *models.AuditEntry) error {
+	args := m.Called(ctx, payout, entry)
+
+	return args.Error(0)
+}
+
+// GetPayout mocks operation to fetch a payout given its ID
+func (m *MockPostgres) GetPayout(ctx context.Context, payoutID string) (*models.Payout, error) {
+	args := m.Called(ctx, payoutID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.Payout), args.Error(1)
+}
+
+// ListPayouts mocks operation to list the payouts matching a filter
+func (m *MockPostgres) ListPayouts(ctx context.Context, filter *models.PayoutFilter) ([]*models.Payout, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Payout), args.Error(1)
+}
+
+// LinkPayoutTransactions mocks operation to link a payout to the transactions it settled
+func (m *MockPostgres) LinkPayoutTransactions(ctx context.Context, payoutID string, items []*models.PayoutItem) (int, error) {
+	args := m.Called(ctx, payoutID, items)
+
+	return args.Int(0), args.Error(1)
+}
+
+// ListPayoutTransactions mocks operation to list the transactions settled by a payout
+func (m *MockPostgres) ListPayoutTransactions(ctx context.Context, payoutID string) ([]*models.Transaction, error) {
+	args := m.Called(ctx, payoutID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Transaction), args.Error(1)
+}
+
+// CountRiskDecisions mocks operation to count the recent risk decisions of payments sharing a field value
+func (m *MockPostgres) CountRiskDecisions(ctx context.Context, merchantID string, field models.RiskField, value string, since time.Time) (int, error) {
+	args := m.Called(ctx, merchantID, field, value, since)
+
+	return args.Int(0), args.Error(1)
+}
+
+// GetReview mocks operation to fetch a review
+func (m *MockPostgres) GetReview(ctx context.Context, reviewID string) (*models.Review, error) {
+	args := m.Called(ctx, reviewID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.Review), args.Error(1)
+}
+
+// ListReviews mocks operation to list the reviews matching a filter
+func (m *MockPostgres) ListReviews(ctx context.Context, filter *models.ReviewFilter) ([]*models.Review, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Review), args.Error(1)
+}
+
+// ClaimReview mocks operation to claim a pending review for a reviewer
+func (m *MockPostgres) ClaimReview(ctx context.Context, reviewID, reviewer string, until time.Time) error {
+	args := m.Called(ctx, reviewID, reviewer, until)
+
+	return args.Error(0)
+}
+
+// ReleaseReview mocks operation to give up the claim on a review
+func (m *MockPostgres) ReleaseReview(ctx context.Context, reviewID, reviewer string) error {
+	args := m.Called(ctx, reviewID, reviewer)
+
+	return args.Error(0)
+}
+
+// DecideReview mocks operation to record the decision on a review and apply it to its transaction
+func (m *MockPostgres) DecideReview(ctx context.Context, review *models.Review, decided *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error) {
+	args := m.Called(ctx, review, decided, entry)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.Transaction), args.Error(1)
+}
+
+// InsertRefundRequest mocks operation to hold a transaction until its refund is approved
+func (m *MockPostgres) InsertRefundRequest(ctx context.Context, request *models.RefundRequest, expectedVersion int, entry *models.AuditEntry) (*models.Transaction, error) {
+	args := m.Called(ctx, request, expectedVersion, entry)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.Transaction), args.Error(1)
+}
+
+// GetRefundRequest mocks operation to fetch a refund request
+func (m *MockPostgres) GetRefundRequest(ctx context.Context, refundRequestID string) (*models.RefundRequest, error) {
+	args := m.Called(ctx, refundRequestID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.RefundRequest), args.Error(1)
+}
+
+// ListRefundRequests mocks operation to list the refund requests matching a filter
+func (m *MockPostgres) ListRefundRequests(ctx context.Context, filter *models.RefundRequestFilter) ([]*models.RefundRequest, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.RefundRequest), args.Error(1)
+}
+
+// ClaimRefundRequest mocks operation to claim a pending refund request for an operator
+func (m *MockPostgres) ClaimRefundRequest(ctx context.Context, refundRequestID, operator string, until time.Time) error {
+	args := m.Called(ctx, refundRequestID, operator, until)
+
+	return args.Error(0)
+}
+
+// ReleaseRefundRequest mocks operation to give up the claim on a refund request
+func (m *MockPostgres) ReleaseRefundRequest(ctx context.Context, refundRequestID, operator string) error {
+	args := m.Called(ctx, refundRequestID, operator)
+
+	return args.Error(0)
+}
+
+// DecideRefundRequest mocks operation to record the decision on a refund request
+func (m *MockPostgres) DecideRefundRequest(ctx context.Context, request *models.RefundRequest, entry *models.AuditEntry) error {
+	args := m.Called(ctx, request, entry)
+
+	return args.Error(0)
+}
+
+// CloseRefundRequest mocks operation to close the pending refund request of a refunded transaction
+func (m *MockPostgres) CloseRefundRequest(ctx context.Context, transactionID, closedBy string, entry *models.AuditEntry) (*models.RefundRequest, error) {
+	args := m.Called(ctx, transactionID, closedBy, entry)
+
+	request, _ := args.Get(0).(*models.RefundRequest)
+
+	return request, args.Error(1)
+}
+
+// InsertBlockListEntry mocks operation to add an entry to the block list
+func (m *MockPostgres) InsertBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) error {
+	args := m.Called(ctx, listEntry, entry)
+
+	return args.Error(0)
+}
+
+// GetBlockListEntry mocks operation to fetch a block list entry
+func (m *MockPostgres) GetBlockListEntry(ctx context.Context, entryID string) (*models.BlockListEntry, error) {
+	args := m.Called(ctx, entryID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.BlockListEntry), args.Error(1)
+}
+
+// ListBlockListEntries mocks operation to list the block list entries matching a filter
+func (m *MockPostgres) ListBlockListEntries(ctx context.Context, filter *models.BlockListFilter) ([]*models.BlockListEntry, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.BlockListEntry), args.Error(1)
+}
+
+// UpdateBlockListEntry mocks operation to update the reason and expiration of a block list entry
+func (m *MockPostgres) UpdateBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) (*models.BlockListEntry, error) {
+	args := m.Called(ctx, listEntry, entry)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.BlockListEntry), args.Error(1)
+}
+
+// DeleteBlockListEntry mocks operation to delete a block list entry
+func (m *MockPostgres) DeleteBlockListEntry(ctx context.Context, entryID string, entry *models.AuditEntry) error {
+	args := m.Called(ctx, entryID, entry)
+
+	return args.Error(0)
+}
+
+// MatchBlockList mocks operation to fetch the block list entries matching a payment
+func (m *MockPostgres) MatchBlockList(ctx context.Context, query *models.BlockListQuery) ([]*models.BlockListEntry, error) {
+	args := m.Called(ctx, query)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.BlockListEntry), args.Error(1)
+}
+
+// ReserveUsage mocks operation to add a reservation to the running totals of a merchant, calling check with the
+// usage returned by the mock
+func (m *MockPostgres) ReserveUsage(ctx context.Context, reservation *models.UsageReservation, check func(*models.Usage) error) (*models.Usage, error) {
+	args := m.Called(ctx, reservation, check)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	usage := args.Get(0).(*models.Usage)
+
+	err := check(usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, args.Error(1)
+}
+
+// ReleaseUsage mocks operation to subtract a reservation from the running totals of a merchant
+func (m *MockPostgres) ReleaseUsage(ctx context.Context, reservation *models.UsageReservation) error {
+	args := m.Called(ctx, reservation)
+
+	return args.Error(0)
+}
+
+// ListUsage mocks operation to compute the running totals of a merchant
+func (m *MockPostgres) ListUsage(ctx context.Context, merchantID string, at time.Time) ([]*models.Usage, error) {
+	args := m.Called(ctx, merchantID, at)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Usage), args.Error(1)
+}
+
+// InsertAuditEntry mocks operation to append an entry to the audit trail
+func (m *MockPostgres) InsertAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	args := m.Called(ctx, entry)
+
+	return args.Error(0)
+}
+
+// ListAuditEntries mocks operation to list the entries of the audit trail
+func (m *MockPostgres) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.AuditEntry), args.Error(1)
+}
+
+// ExportCustomerData mocks operation to fetch the data held about a customer
+func (m *MockPostgres) ExportCustomerData(ctx context.Context, customer string) (*models.CustomerData, error) {
+	args := m.Called(ctx, customer)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.CustomerData), args.Error(1)
+}
+
+// EraseCustomerData mocks operation to erase the personal data of a customer
+func (m *MockPostgres) EraseCustomerData(ctx context.Context, erasure *models.CustomerErasure, entry *models.AuditEntry) error {
+	args := m.Called(ctx, erasure, entry)
+
+	return args.Error(0)
+}
+
+// ArchiveTransactions mocks operation to move old transactions to the archive tables
+func (m *MockPostgres) ArchiveTransactions(ctx context.Context, before, refundableSince time.Time, limit int) (int, error) {
+	args := m.Called(ctx, before, refundableSince, limit)
+
+	return args.Int(0), args.Error(1)
+}
+
+// ListArchivableTransactions mocks operation to fetch the transactions past their retention
+func (m *MockPostgres) ListArchivableTransactions(ctx context.Context, before, refundableSince time.Time, limit int) ([]*models.Transaction, error) {
+	args := m.Called(ctx, before, refundableSince, limit)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Transaction), args.Error(1)
+}
+
+// RemoveArchivedTransactions mocks operation to delete the transactions written to an archive file
+func (m *MockPostgres) RemoveArchivedTransactions(ctx context.Context, file string, transactions []*models.Transaction) ([]string, error) {
+	args := m.Called(ctx, file, transactions)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// GetArchiveFile mocks operation to fetch the archive file of a transaction
+func (m *MockPostgres) GetArchiveFile(ctx context.Context, transactionID string) (string, error) {
+	args := m.Called(ctx, transactionID)
+
+	return args.String(0), args.Error(1)
+}
+
+// LockArchiveFile mocks operation to run fn holding the lock of an archive file, running it unless an error is mocked
+func (m *MockPostgres) LockArchiveFile(ctx context.Context, file string, fn func() error) error {
+	args := m.Called(ctx, file)
+
+	err := args.Error(0)
+	if err != nil {
+		return err
+	}
+
+	return fn()
+}
+
+// PruneWebhookEventPayloads mocks operation to drop the payloads of old webhook events
+func (m *MockPostgres) PruneWebhookEventPayloads(ctx context.Context, before time.Time) (int, error) {
+	args := m.Called(ctx, before)
+
+	return args.Int(0), args.Error(1)
+}
+
+// Ping mocks operation to check the database connection
+func (m *MockPostgres) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+
+	return args.Error(0)
+}
+
 // Close mock operation to close a database connection
 func (m *MockPostgres) Close() {}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/jackc/pgx/v5"
@@ -24,6 +26,7 @@ func TestInsertTransaction(t *testing.T) {
 
 	transaction := &models.Transaction{
 		TransactionID: "TXN123",
+		MerchantID:    "MCH_123",
 		Status:        models.TransactionStatusSucceeded,
 		Description:   "Sample description",
 		Provider:      models.PaymentProviderStripe,
@@ -35,8 +38,10 @@ func TestInsertTransaction(t *testing.T) {
 		},
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO transactions_history").WithArgs(
 		transaction.TransactionID,
+		transaction.MerchantID,
 		transaction.Status,
 		transaction.Description,
 		transaction.FailureReason,
@@ -45,12 +50,21 @@ func TestInsertTransaction(t *testing.T) {
 		transaction.Currency,
 		transaction.Type,
 		transaction.AdditionalFields,
+		transaction.ProviderFee,
+		transaction.PlatformFee,
+		transaction.NetAmount,
+		transaction.FeeCurrency,
+		pgxmock.AnyArg(),
 	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionCreated, models.AggregateTransaction, transaction.TransactionID, pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, audit.SystemActor, models.AuditActionCreatePayment, models.AuditResourceTransaction, transaction.TransactionID, "")
+	mock.ExpectCommit()
 
 	service := postgresService{pool: mock}
 
-	err = service.InsertTransaction(context.Background(), transaction)
+	err = service.InsertTransaction(context.Background(), transaction, &models.AuditEntry{Action: models.AuditActionCreatePayment, ResourceType: models.AuditResourceTransaction, ResourceID: transaction.TransactionID})
 	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
 }
 
 func TestInsertTransactionFailure(t *testing.T) {
@@ -63,6 +77,7 @@ func TestInsertTransactionFailure(t *testing.T) {
 
 	transaction := &models.Transaction{
 		TransactionID: "TXN123",
+		MerchantID:    "MCH_123",
 		Status:        models.TransactionStatusSucceeded,
 		Description:   "Sample description",
 		Provider:      models.PaymentProviderStripe,
@@ -74,8 +89,10 @@ func TestInsertTransactionFailure(t *testing.T) {
 		},
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO transactions_history").WithArgs(
 		transaction.TransactionID,
+		transaction.MerchantID,
 		transaction.Status,
 		transaction.Description,
 		transaction.FailureReason,
@@ -84,11 +101,17 @@ func TestInsertTransactionFailure(t *testing.T) {
 		transaction.Currency,
 		transaction.Type,
 		transaction.AdditionalFields,
+		transaction.ProviderFee,
+		transaction.PlatformFee,
+		transaction.NetAmount,
+		transaction.FeeCurrency,
+		pgxmock.AnyArg(),
 	).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
 	service := postgresService{pool: mock}
 
-	err = service.InsertTransaction(context.Background(), transaction)
+	err = service.InsertTransaction(context.Background(), transaction, &models.AuditEntry{Action: models.AuditActionCreatePayment, ResourceType: models.AuditResourceTransaction, ResourceID: transaction.TransactionID})
 	c.ErrorIs(err, sql.ErrConnDone)
 }
 
@@ -100,12 +123,13 @@ func TestGetTransaction(t *testing.T) {
 
 	defer mock.Close()
 
-	columns := []string{"transaction_id", "status", "description", "failure_reason", "payment_provider", "amount", "currency", "type", "additional_fields"}
+	columns := []string{"transaction_id", "merchant_id", "status", "description", "failure_reason", "payment_provider", "amount", "currency", "type", "additional_fields", "provider_fee", "platform_fee", "net_amount", "fee_currency", "version", "created_at", "updated_at"}
 
 	rows := mock.NewRows(columns)
 
 	expectedtransaction := &models.Transaction{
 		TransactionID: "TXN123",
+		MerchantID:    "MCH_123",
 		Status:        models.TransactionStatusSucceeded,
 		Description:   "Sample description",
 		Provider:      models.PaymentProviderStripe,
@@ -115,6 +139,9 @@ func TestGetTransaction(t *testing.T) {
 		AdditionalFields: map[string]interface{}{
 			"charge_id": "ch_123",
 		},
+		Version:   2,
+		CreatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 3, 1, 10, 5, 0, 0, time.UTC),
 	}
 
 	marshalledAdditionalFields, err := json.Marshal(expectedtransaction.AdditionalFields)
@@ -122,6 +149,7 @@ func TestGetTransaction(t *testing.T) {
 
 	rows.AddRow(
 		expectedtransaction.TransactionID,
+		expectedtransaction.MerchantID,
 		expectedtransaction.Status,
 		expectedtransaction.Description,
 		expectedtransaction.FailureReason,
@@ -130,11 +158,19 @@ func TestGetTransaction(t *testing.T) {
 		expectedtransaction.Currency,
 		expectedtransaction.Type,
 		string(marshalledAdditionalFields),
+		expectedtransaction.ProviderFee,
+		expectedtransaction.PlatformFee,
+		expectedtransaction.NetAmount,
+		expectedtransaction.FeeCurrency,
+		expectedtransaction.Version,
+		expectedtransaction.CreatedAt,
+		expectedtransaction.UpdatedAt,
 	)
 
 	query := `
 	SELECT
 		transaction_id,
+		COALESCE(merchant_id, ''),
 		status,
 		description,
 		failure_reason,
@@ -142,7 +178,14 @@ func TestGetTransaction(t *testing.T) {
 		amount,
 		currency,
 		type,
-		additional_fields
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
 	FROM transactions_history
 	WHERE transaction_id = $1
 	`
@@ -167,6 +210,7 @@ func TestGetTransactionNoRows(t *testing.T) {
 	query := `
 	SELECT
 		transaction_id,
+		COALESCE(merchant_id, ''),
 		status,
 		description,
 		failure_reason,
@@ -174,18 +218,27 @@ func TestGetTransactionNoRows(t *testing.T) {
 		amount,
 		currency,
 		type,
-		additional_fields
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
 	FROM transactions_history
 	WHERE transaction_id = $1
 	`
 
 	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("TXN123").WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery("FROM transactions_archive").WithArgs("TXN123").WillReturnError(pgx.ErrNoRows)
 
 	service := postgresService{pool: mock}
 
 	transaction, err := service.GetTransaction(context.Background(), "TXN123")
 	c.Nil(transaction)
 	c.ErrorIs(err, database.ErrTransactionNotFound)
+	c.NoError(mock.ExpectationsWereMet())
 }
 
 func TestGetTransactionFailure(t *testing.T) {
@@ -199,6 +252,7 @@ func TestGetTransactionFailure(t *testing.T) {
 	query := `
 	SELECT
 		transaction_id,
+		COALESCE(merchant_id, ''),
 		status,
 		description,
 		failure_reason,
@@ -206,7 +260,14 @@ func TestGetTransactionFailure(t *testing.T) {
 		amount,
 		currency,
 		type,
-		additional_fields
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
 	FROM transactions_history
 	WHERE transaction_id = $1
 	`
@@ -230,6 +291,7 @@ func TestUpdateTransaction(t *testing.T) {
 
 	expectedTransaction := &models.Transaction{
 		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
 		Status:        models.TransactionStatusSucceeded,
 		Description:   "Sample description",
 		Amount:        2000,
@@ -240,17 +302,21 @@ func TestUpdateTransaction(t *testing.T) {
 			"charge_id": "ch_123",
 			"refund_id": "re_123",
 		},
+		ProviderFee: 15,
+		FeeCurrency: "usd",
+		Version:     2,
 	}
 
 	marshalledAdditionalFields, err := json.Marshal(expectedTransaction.AdditionalFields)
 	c.NoError(err)
 
-	columns := []string{"transaction_id", "status", "description", "failure_reason", "provider", "amount", "currency", "type", "additional_fields"}
+	columns := []string{"transaction_id", "merchant_id", "status", "description", "failure_reason", "provider", "amount", "currency", "type", "additional_fields", "provider_fee", "platform_fee", "net_amount", "fee_currency", "version", "created_at", "updated_at"}
 
 	rows := mock.NewRows(columns)
 
 	rows.AddRow(
 		expectedTransaction.TransactionID,
+		expectedTransaction.MerchantID,
 		expectedTransaction.Status,
 		expectedTransaction.Description,
 		expectedTransaction.FailureReason,
@@ -259,6 +325,13 @@ func TestUpdateTransaction(t *testing.T) {
 		expectedTransaction.Currency,
 		expectedTransaction.Type,
 		string(marshalledAdditionalFields),
+		expectedTransaction.ProviderFee,
+		expectedTransaction.PlatformFee,
+		expectedTransaction.NetAmount,
+		expectedTransaction.FeeCurrency,
+		expectedTransaction.Version+1,
+		expectedTransaction.CreatedAt,
+		expectedTransaction.UpdatedAt,
 	)
 
 	query := `
@@ -266,16 +339,28 @@ func TestUpdateTransaction(t *testing.T) {
 	SET
 		status = COALESCE($1, status),
 		type = COALESCE($2, type),
-		additional_fields = COALESCE($3, additional_fields)
-	WHERE transaction_id = $4`
-
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(expectedTransaction.Status, expectedTransaction.Type, expectedTransaction.AdditionalFields, expectedTransaction.TransactionID).WillReturnRows(rows)
+		additional_fields = COALESCE($3, additional_fields),
+		provider_fee = provider_fee + $4,
+		fee_currency = COALESCE(NULLIF($5, ''), fee_currency),
+		version = version + 1,
+		updated_at = NOW()
+	WHERE transaction_id = $6 AND version = $7`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(expectedTransaction.Status, expectedTransaction.Type, expectedTransaction.AdditionalFields, expectedTransaction.ProviderFee, expectedTransaction.FeeCurrency, expectedTransaction.TransactionID, expectedTransaction.Version).WillReturnRows(rows)
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionUpdated, models.AggregateTransaction, expectedTransaction.TransactionID, pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, audit.SystemActor, models.AuditActionRefund, models.AuditResourceTransaction, "TXN_123", "")
+	mock.ExpectCommit()
 
 	service := postgresService{pool: mock}
 
-	transaction, err := service.UpdateTransaction(context.Background(), "TXN_123", expectedTransaction)
+	entry := &models.AuditEntry{Action: models.AuditActionRefund, ResourceType: models.AuditResourceTransaction, ResourceID: "TXN_123"}
+
+	transaction, err := service.UpdateTransaction(context.Background(), "TXN_123", expectedTransaction, entry)
 	c.NoError(err)
-	c.Equal(expectedTransaction, transaction)
+	c.Equal(3, transaction.Version)
+	c.Contains(string(entry.After), `"version":3`, "the audit entry holds the updated transaction")
+	c.NoError(mock.ExpectationsWereMet())
 }
 
 func TestUpdateTransactionFailure(t *testing.T) {
@@ -315,10 +400,15 @@ func TestUpdateTransactionFailure(t *testing.T) {
 	SET
 		status = COALESCE($1, status),
 		type = COALESCE($2, type),
-		additional_fields = COALESCE($3, additional_fields)
-	WHERE transaction_id = $4
+		additional_fields = COALESCE($3, additional_fields),
+		provider_fee = provider_fee + $4,
+		fee_currency = COALESCE(NULLIF($5, ''), fee_currency),
+		version = version + 1,
+		updated_at = NOW()
+	WHERE transaction_id = $6 AND version = $7
 	RETURNING
 		transaction_id,
+		COALESCE(merchant_id, ''),
 		status,
 		description,
 		failure_reason,
@@ -326,13 +416,189 @@ func TestUpdateTransactionFailure(t *testing.T) {
 		amount,
 		currency,
 		type,
-		additional_fields`
-
-	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(transaction.Status, transaction.Type, transaction.AdditionalFields, transaction.TransactionID).WillReturnError(sql.ErrConnDone)
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(transaction.Status, transaction.Type, transaction.AdditionalFields, transaction.ProviderFee, transaction.FeeCurrency, transaction.TransactionID, transaction.Version).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
 	service := postgresService{pool: mock}
 
-	_, err = service.UpdateTransaction(context.Background(), "TXN_123", transaction)
+	_, err = service.UpdateTransaction(context.Background(), "TXN_123", transaction, nil)
 	c.ErrorIs(err, sql.ErrConnDone)
 
 }
+
+func TestUpdateTransactionVersionConflict(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusSucceeded,
+		Version:       1,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE transactions_history").WithArgs(transaction.Status, transaction.Type, transaction.AdditionalFields, transaction.ProviderFee, transaction.FeeCurrency, transaction.TransactionID, transaction.Version).WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version FROM transactions_history WHERE transaction_id = $1`)).WithArgs("TXN_123").WillReturnRows(mock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	_, err = service.UpdateTransaction(context.Background(), "TXN_123", transaction, nil)
+	c.ErrorIs(err, database.ErrTransactionVersionConflict)
+}
+
+func TestUpdateTransactionNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusSucceeded,
+		Version:       1,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE transactions_history").WithArgs(transaction.Status, transaction.Type, transaction.AdditionalFields, transaction.ProviderFee, transaction.FeeCurrency, transaction.TransactionID, transaction.Version).WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version FROM transactions_history WHERE transaction_id = $1`)).WithArgs("TXN_123").WillReturnError(pgx.ErrNoRows)
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	_, err = service.UpdateTransaction(context.Background(), "TXN_123", transaction, nil)
+	c.ErrorIs(err, database.ErrTransactionNotFound)
+}
+
+func TestListTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	columns := []string{"transaction_id", "merchant_id", "status", "description", "failure_reason", "payment_provider", "amount", "currency", "type", "additional_fields", "provider_fee", "platform_fee", "net_amount", "fee_currency", "version", "created_at", "updated_at"}
+
+	rows := mock.NewRows(columns).
+		AddRow("TXN_2", "MCH_123", models.TransactionStatusSucceeded, "Second", "", models.PaymentProviderStripe, 2000, "usd", models.TransactionTypeCharge, `{"charge_id":"ch_2"}`, 0, 0, 0, "", 1, createdAt, createdAt).
+		AddRow("TXN_1", "MCH_123", models.TransactionStatusSucceeded, "First", "", models.PaymentProviderStripe, 1000, "usd", models.TransactionTypeCharge, `{"charge_id":"ch_1"}`, 0, 0, 0, "", 1, createdAt, createdAt)
+
+	query := `
+	FROM transactions_history
+	WHERE merchant_id = $1
+	AND status = $2
+	AND created_at >= $3
+	ORDER BY created_at DESC, transaction_id DESC
+	LIMIT $4`
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("MCH_123", models.TransactionStatusSucceeded, createdAt, 10).WillReturnRows(rows)
+
+	service := postgresService{pool: mock}
+
+	transactions, err := service.ListTransactions(context.Background(), &models.TransactionFilter{
+		MerchantID:  "MCH_123",
+		Status:      models.TransactionStatusSucceeded,
+		CreatedFrom: &createdAt,
+		Limit:       10,
+	})
+	c.NoError(err)
+	c.Len(transactions, 2)
+	c.Equal("TXN_2", transactions[0].TransactionID)
+	c.Equal("ch_1", transactions[1].AdditionalFields["charge_id"])
+}
+
+func TestForceTransactionStatusNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	entry := &models.AuditEntry{
+		Actor:        "ops",
+		Action:       models.AuditActionForceStatus,
+		ResourceType: models.AuditResourceTransaction,
+		ResourceID:   "TXN_123",
+		Reason:       "stuck after provider outage",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE transactions_history").WithArgs(models.TransactionStatusFailure, "TXN_123").WillReturnError(pgx.ErrNoRows)
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	_, err = service.ForceTransactionStatus(context.Background(), "TXN_123", models.TransactionStatusFailure, entry)
+	c.ErrorIs(err, database.ErrTransactionNotFound)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestStreamTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	columns := []string{"transaction_id", "merchant_id", "status", "description", "failure_reason", "payment_provider", "amount", "currency", "type", "additional_fields", "provider_fee", "platform_fee", "net_amount", "fee_currency", "version", "created_at", "updated_at"}
+
+	rows := mock.NewRows(columns).
+		AddRow("TXN_1", "MCH_123", models.TransactionStatusSucceeded, "First", "", models.PaymentProviderStripe, 1000, "usd", models.TransactionTypeCharge, `{"charge_id":"ch_1"}`, 0, 0, 0, "", 1, createdAt, createdAt)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DECLARE transactions_export NO SCROLL CURSOR FOR")).WithArgs("MCH_123").WillReturnResult(pgxmock.NewResult("DECLARE CURSOR", 0))
+	mock.ExpectQuery(regexp.QuoteMeta("FETCH FORWARD 500 FROM transactions_export")).WillReturnRows(rows)
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	var streamed []string
+
+	err = service.StreamTransactions(context.Background(), &models.TransactionFilter{MerchantID: "MCH_123"}, func(transaction *models.Transaction) error {
+		streamed = append(streamed, transaction.TransactionID)
+
+		return nil
+	})
+	c.NoError(err)
+	c.Equal([]string{"TXN_1"}, streamed)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestPing(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	mock.ExpectPing().WillReturnError(sql.ErrConnDone)
+
+	service := postgresService{pool: mock}
+
+	err = service.Ping(context.Background())
+	c.ErrorIs(err, sql.ErrConnDone)
+}
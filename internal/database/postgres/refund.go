@@ -0,0 +1,357 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const refundRequestColumns = `
+		refund_request_id,
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		amount,
+		currency,
+		previous_status,
+		requested_by,
+		requester_type,
+		COALESCE(reason, ''),
+		COALESCE(decided_by, ''),
+		COALESCE(rejection_reason, ''),
+		decided_at,
+		created_at`
+
+// InsertRefundRequest stores the refund request and holds its transaction in the refund_requested status, along with
+// the transaction.updated event and the audit entry
+func (p postgresService) InsertRefundRequest(ctx context.Context, request *models.RefundRequest, expectedVersion int, entry *models.AuditEntry) (*models.Transaction, error) {
+	transactionQuery := `
+	UPDATE transactions_history
+	SET
+		status = 'refund_requested',
+		version = version + 1,
+		updated_at = NOW()
+	WHERE transaction_id = $1 AND version = $2
+	RETURNING
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	`
+
+	requestQuery := `
+	INSERT INTO refund_requests(
+		refund_request_id,
+		transaction_id,
+		merchant_id,
+		status,
+		amount,
+		currency,
+		previous_status,
+		requested_by,
+		requester_type,
+		reason,
+		created_at
+	) VALUES($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11)`
+
+	var transaction *models.Transaction
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		var err error
+
+		transaction, err = scanTransaction(tx.QueryRow(ctx, transactionQuery, request.TransactionID, expectedVersion))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return updateConflict(ctx, tx, request.TransactionID)
+		}
+
+		if err != nil {
+			return internalError(ctx, "update and scan row failed", err)
+		}
+
+		_, err = tx.Exec(ctx, requestQuery, request.RefundRequestID, request.TransactionID, request.MerchantID, request.Status, request.Amount, request.Currency, request.PreviousStatus, request.RequestedBy, request.RequesterType, request.Reason, request.CreatedAt)
+		if err != nil {
+			return internalError(ctx, "insert refund request failed", err)
+		}
+
+		err = insertEvent(ctx, tx, models.EventTypeTransactionUpdated, models.AggregateTransaction, transaction.TransactionID, transaction)
+		if err != nil {
+			return err
+		}
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.decryptTransaction(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// GetRefundRequest fetches a refund request given its ID
+func (p postgresService) GetRefundRequest(ctx context.Context, refundRequestID string) (*models.RefundRequest, error) {
+	query := `
+	SELECT` + refundRequestColumns + `
+	FROM refund_requests
+	WHERE refund_request_id = $1
+	`
+
+	request, err := scanRefundRequest(p.pool.QueryRow(ctx, query, refundRequestID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, api.NewResourceNotFoundError(database.ErrRefundRequestNotFound, "refund_request")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
+
+	return request, nil
+}
+
+// ListRefundRequests fetches the refund requests matching the filter, oldest first so they are worked in arrival order
+func (p postgresService) ListRefundRequests(ctx context.Context, filter *models.RefundRequestFilter) ([]*models.RefundRequest, error) {
+	query := `
+	SELECT` + refundRequestColumns + `
+	FROM refund_requests`
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if len(conditions) > 0 {
+		query += "\n\tWHERE " + strings.Join(conditions, "\n\tAND ")
+	}
+
+	query += "\n\tORDER BY created_at, refund_request_id"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\tLIMIT $%d", len(args))
+	}
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	requests := []*models.RefundRequest{}
+
+	for rows.Next() {
+		request, err := scanRefundRequest(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		requests = append(requests, request)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return requests, nil
+}
+
+// ClaimRefundRequest claims a pending refund request for the operator until the given moment. A claim is only taken
+// over once it lapsed, so an operator whose decision never completed doesn't hold the request forever
+func (p postgresService) ClaimRefundRequest(ctx context.Context, refundRequestID, operator string, until time.Time) error {
+	query := `
+	UPDATE refund_requests
+	SET
+		claimed_by = $1,
+		claimed_until = $2
+	WHERE refund_request_id = $3 AND status = 'pending' AND (claimed_until IS NULL OR claimed_until < NOW())`
+
+	tag, err := p.pool.Exec(ctx, query, operator, until, refundRequestID)
+	if err != nil {
+		return internalError(ctx, "claim refund request failed", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return api.NewConflictError(database.ErrRefundRequestClaimed)
+	}
+
+	return nil
+}
+
+// ReleaseRefundRequest gives up the claim of the operator on a refund request, once the payment provider failed to
+// issue its refund
+func (p postgresService) ReleaseRefundRequest(ctx context.Context, refundRequestID, operator string) error {
+	query := `
+	UPDATE refund_requests
+	SET
+		claimed_by = NULL,
+		claimed_until = NULL
+	WHERE refund_request_id = $1 AND status = 'pending' AND claimed_by = $2`
+
+	_, err := p.pool.Exec(ctx, query, refundRequestID, operator)
+	if err != nil {
+		return internalError(ctx, "release refund request failed", err)
+	}
+
+	return nil
+}
+
+// DecideRefundRequest records the decision on a pending refund request claimed by its decider along with the audit
+// entry. Rejecting it restores the previous status of its transaction, along with the transaction.updated event, as
+// long as no webhook of its provider moved it out of the refund_requested status
+func (p postgresService) DecideRefundRequest(ctx context.Context, request *models.RefundRequest, entry *models.AuditEntry) error {
+	requestQuery := `
+	UPDATE refund_requests
+	SET
+		status = $1,
+		decided_by = $2,
+		rejection_reason = NULLIF($3, ''),
+		decided_at = $4
+	WHERE refund_request_id = $5 AND status = 'pending' AND claimed_by = $2`
+
+	transactionQuery := `
+	UPDATE transactions_history
+	SET
+		status = $1,
+		version = version + 1,
+		updated_at = NOW()
+	WHERE transaction_id = $2 AND status = 'refund_requested'
+	RETURNING
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	`
+
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, requestQuery, request.Status, request.DecidedBy, request.RejectionReason, request.DecidedAt, request.RefundRequestID)
+		if err != nil {
+			return internalError(ctx, "update refund request failed", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			return api.NewConflictError(database.ErrRefundRequestAlreadyDecided)
+		}
+
+		if request.Status == models.RefundRequestStatusRejected {
+			transaction, err := scanTransaction(tx.QueryRow(ctx, transactionQuery, request.PreviousStatus, request.TransactionID))
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return internalError(ctx, "update and scan row failed", err)
+			}
+
+			if err == nil {
+				err = insertEvent(ctx, tx, models.EventTypeTransactionUpdated, models.AggregateTransaction, transaction.TransactionID, transaction)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+}
+
+// CloseRefundRequest closes the pending refund request of a transaction whose refund the provider reported as issued,
+// along with the audit entry. Its amount stays reserved, since the refund went through
+func (p postgresService) CloseRefundRequest(ctx context.Context, transactionID, closedBy string, entry *models.AuditEntry) (*models.RefundRequest, error) {
+	query := `
+	UPDATE refund_requests
+	SET
+		status = 'closed',
+		decided_by = $1,
+		decided_at = NOW()
+	WHERE transaction_id = $2 AND status = 'pending'
+	RETURNING` + refundRequestColumns
+
+	var request *models.RefundRequest
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		var err error
+
+		request, err = scanRefundRequest(tx.QueryRow(ctx, query, closedBy, transactionID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+
+		if err != nil {
+			return internalError(ctx, "update and scan row failed", err)
+		}
+
+		entry.ResourceID = request.RefundRequestID
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func scanRefundRequest(row pgx.Row) (*models.RefundRequest, error) {
+	var request models.RefundRequest
+
+	err := row.Scan(
+		&request.RefundRequestID,
+		&request.TransactionID,
+		&request.MerchantID,
+		&request.Status,
+		&request.Amount,
+		&request.Currency,
+		&request.PreviousStatus,
+		&request.RequestedBy,
+		&request.RequesterType,
+		&request.Reason,
+		&request.DecidedBy,
+		&request.RejectionReason,
+		&request.DecidedAt,
+		&request.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
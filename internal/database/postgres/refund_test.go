@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertRefundRequestVersionConflict(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	request := &models.RefundRequest{
+		RefundRequestID: "RFR_123",
+		TransactionID:   "TXN_123",
+		MerchantID:      "MCH_123",
+		Status:          models.RefundRequestStatusPending,
+		Amount:          150000,
+		Currency:        "usd",
+		PreviousStatus:  models.TransactionStatusSucceeded,
+		RequestedBy:     "KEY_123",
+		RequesterType:   models.AuditActorAPIKey,
+		CreatedAt:       time.Now(),
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE transactions_history").WithArgs("TXN_123", 1).WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery("SELECT version FROM transactions_history").WithArgs("TXN_123").WillReturnRows(mock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	_, err = service.InsertRefundRequest(context.Background(), request, 1, &models.AuditEntry{})
+	c.ErrorIs(err, database.ErrTransactionVersionConflict)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestDecideRefundRequestRejected(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	decidedAt := time.Now()
+
+	request := &models.RefundRequest{
+		RefundRequestID: "RFR_123",
+		TransactionID:   "TXN_123",
+		Status:          models.RefundRequestStatusRejected,
+		PreviousStatus:  models.TransactionStatusSucceeded,
+		DecidedBy:       "alice",
+		RejectionReason: "goods shipped",
+		DecidedAt:       &decidedAt,
+	}
+
+	entry := &models.AuditEntry{
+		Actor:        "alice",
+		Action:       models.AuditActionRejectRefundRequest,
+		ResourceType: models.AuditResourceRefundRequest,
+		ResourceID:   "RFR_123",
+		Reason:       "goods shipped",
+	}
+
+	columns := []string{"transaction_id", "merchant_id", "status", "description", "failure_reason", "payment_provider", "amount", "currency", "type", "additional_fields", "provider_fee", "platform_fee", "net_amount", "fee_currency", "version", "created_at", "updated_at"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("WHERE refund_request_id = $5 AND status = 'pending' AND claimed_by = $2")).
+		WithArgs(models.RefundRequestStatusRejected, "alice", "goods shipped", &decidedAt, "RFR_123").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery("UPDATE transactions_history").
+		WithArgs(models.TransactionStatusSucceeded, "TXN_123").
+		WillReturnRows(mock.NewRows(columns).AddRow("TXN_123", "MCH_123", models.TransactionStatusSucceeded, "", "", models.PaymentProviderStripe, 150000, "usd", models.TransactionTypeCharge, `{"charge_id":"ch_123"}`, 0, 0, 0, "", 3, decidedAt, decidedAt))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionUpdated, models.AggregateTransaction, "TXN_123", pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, "alice", models.AuditActionRejectRefundRequest, models.AuditResourceRefundRequest, "RFR_123", "goods shipped")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	err = service.DecideRefundRequest(context.Background(), request, entry)
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestDecideRefundRequestAlreadyDecided(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	decidedAt := time.Now()
+
+	request := &models.RefundRequest{
+		RefundRequestID: "RFR_123",
+		TransactionID:   "TXN_123",
+		Status:          models.RefundRequestStatusApproved,
+		DecidedBy:       "alice",
+		DecidedAt:       &decidedAt,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE refund_requests").WithArgs(request.Status, "alice", "", &decidedAt, "RFR_123").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	err = service.DecideRefundRequest(context.Background(), request, &models.AuditEntry{})
+	c.ErrorIs(err, database.ErrRefundRequestAlreadyDecided)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestClaimRefundRequest(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	until := time.Now().Add(2 * time.Minute)
+
+	mock.ExpectExec(regexp.QuoteMeta("WHERE refund_request_id = $3 AND status = 'pending' AND (claimed_until IS NULL OR claimed_until < NOW())")).
+		WithArgs("alice", until, "RFR_123").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("UPDATE refund_requests").WithArgs("bob", until, "RFR_123").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	service := postgresService{pool: mock}
+
+	err = service.ClaimRefundRequest(context.Background(), "RFR_123", "alice", until)
+	c.NoError(err)
+
+	err = service.ClaimRefundRequest(context.Background(), "RFR_123", "bob", until)
+	c.ErrorIs(err, database.ErrRefundRequestClaimed, "the claim of alice hasn't lapsed")
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestCloseRefundRequest(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	now := time.Now()
+
+	columns := []string{"refund_request_id", "transaction_id", "merchant_id", "status", "amount", "currency", "previous_status", "requested_by", "requester_type", "reason", "decided_by", "rejection_reason", "decided_at", "created_at"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE transaction_id = $2 AND status = 'pending'")).
+		WithArgs("stripe", "TXN_123").
+		WillReturnRows(mock.NewRows(columns).AddRow("RFR_123", "TXN_123", "MCH_123", models.RefundRequestStatusClosed, 150000, "usd", models.TransactionStatusSucceeded, "KEY_123", models.AuditActorAPIKey, "", "stripe", "", &now, now))
+	expectAuditEntry(mock, "stripe", models.AuditActionCloseRefundRequest, models.AuditResourceRefundRequest, "RFR_123", "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	ctx := audit.WithActor(context.Background(), models.AuditActorWebhook, "stripe")
+
+	request, err := service.CloseRefundRequest(ctx, "TXN_123", "stripe", &models.AuditEntry{
+		Action:       models.AuditActionCloseRefundRequest,
+		ResourceType: models.AuditResourceRefundRequest,
+	})
+	c.NoError(err)
+	c.Equal("RFR_123", request.RefundRequestID)
+	c.Equal(models.RefundRequestStatusClosed, request.Status)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestCloseRefundRequestNotPending(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE refund_requests").WithArgs("stripe", "TXN_123").WillReturnError(pgx.ErrNoRows)
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	request, err := service.CloseRefundRequest(context.Background(), "TXN_123", "stripe", &models.AuditEntry{})
+	c.NoError(err)
+	c.Nil(request, "no audit entry is recorded without a pending request")
+	c.NoError(mock.ExpectationsWereMet())
+}
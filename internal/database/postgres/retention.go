@@ -0,0 +1,351 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// archivedColumns columns of transactions_history copied as they are to transactions_archive
+const archivedColumns = `
+		transaction_id,
+		merchant_id,
+		status,
+		failure_reason,
+		payment_provider,
+		description,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		fee_currency,
+		version,
+		created_at,
+		updated_at`
+
+// notRefundable keeps out of the archive the succeeded charges created since $2, which can still be refunded, as
+// archived transactions can't be updated
+const notRefundable = `NOT (type = 'charge' AND status = 'succeeded' AND created_at >= $2)`
+
+// getArchivedTransaction fetches a transaction from transactions_archive, scanning the index of every partition
+func (p postgresService) getArchivedTransaction(ctx context.Context, transactionID string) (*models.Transaction, error) {
+	query := `
+	SELECT
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	FROM transactions_archive
+	WHERE transaction_id = $1
+	`
+
+	transaction, err := scanTransaction(p.pool.QueryRow(ctx, query, transactionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, api.NewResourceNotFoundError(database.ErrTransactionNotFound, "transaction")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
+
+	err = p.decryptTransaction(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.Archived = true
+
+	return transaction, nil
+}
+
+// ArchiveTransactions moves up to limit settled transactions created before the given time to the monthly partitions
+// of transactions_archive, creating the partitions of their months when missing. Succeeded charges created since
+// refundableSince are kept, as they can still be refunded. Rows locked by another writer are skipped and left for the
+// next batch
+func (p postgresService) ArchiveTransactions(ctx context.Context, before, refundableSince time.Time, limit int) (int, error) {
+	// transactions waiting for a review, a refund approval or their provider are never archived, as they are still updated
+	selectQuery := `
+	SELECT transaction_id, created_at
+	FROM transactions_history
+	WHERE created_at < $1
+	AND status IN ('succeeded', 'failure')
+	AND ` + notRefundable + `
+	ORDER BY created_at, transaction_id
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED`
+
+	moveQuery := `
+	WITH moved AS (
+		DELETE FROM transactions_history
+		WHERE transaction_id = ANY($1)
+		RETURNING` + archivedColumns + `
+	)
+	INSERT INTO transactions_archive(` + archivedColumns + `
+	)
+	SELECT` + archivedColumns + `
+	FROM moved`
+
+	archived := 0
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, selectQuery, before, refundableSince, limit)
+		if err != nil {
+			return internalError(ctx, "execute query failed", err)
+		}
+
+		var (
+			ids    []string
+			months []time.Time
+		)
+
+		for rows.Next() {
+			var (
+				transactionID string
+				createdAt     time.Time
+			)
+
+			err = rows.Scan(&transactionID, &createdAt)
+			if err != nil {
+				rows.Close()
+				return internalError(ctx, "scan row failed", err)
+			}
+
+			ids = append(ids, transactionID)
+
+			// rows are sorted by creation, so the transactions of a month are next to each other
+			month := archiveMonth(createdAt)
+			if len(months) == 0 || !months[len(months)-1].Equal(month) {
+				months = append(months, month)
+			}
+		}
+
+		rows.Close()
+
+		err = rows.Err()
+		if err != nil {
+			return internalError(ctx, "iterate rows failed", err)
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		for _, month := range months {
+			_, err = tx.Exec(ctx, archivePartitionQuery(month))
+			if err != nil {
+				return internalError(ctx, "create archive partition failed", err)
+			}
+		}
+
+		result, err := tx.Exec(ctx, moveQuery, ids)
+		if err != nil {
+			return internalError(ctx, "archive transactions failed", err)
+		}
+
+		archived = int(result.RowsAffected())
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return archived, nil
+}
+
+// ListArchivableTransactions fetches up to limit settled transactions created before the given time, oldest first, as
+// they are stored, so their encrypted additional fields stay encrypted wherever they are archived to. Succeeded charges
+// created since refundableSince are left out, as they can still be refunded
+func (p postgresService) ListArchivableTransactions(ctx context.Context, before, refundableSince time.Time, limit int) ([]*models.Transaction, error) {
+	query := `
+	SELECT
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	FROM transactions_history
+	WHERE created_at < $1
+	AND status IN ('succeeded', 'failure')
+	AND ` + notRefundable + `
+	ORDER BY created_at, transaction_id
+	LIMIT $3`
+
+	rows, err := p.pool.Query(ctx, query, before, refundableSince, limit)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	return scanTransactions(ctx, rows)
+}
+
+// RemoveArchivedTransactions deletes the transactions written to the archive file from transactions_history, recording
+// the file they can be read from. Transactions updated since they were read are kept, as the archived copy is stale,
+// and are archived again by a later run
+func (p postgresService) RemoveArchivedTransactions(ctx context.Context, file string, transactions []*models.Transaction) ([]string, error) {
+	deleteQuery := `
+	DELETE FROM transactions_history
+	USING unnest($1::text[], $2::int[]) AS archived(transaction_id, version)
+	WHERE transactions_history.transaction_id = archived.transaction_id
+	AND transactions_history.version = archived.version
+	AND transactions_history.status IN ('succeeded', 'failure')
+	RETURNING transactions_history.transaction_id`
+
+	insertQuery := `
+	INSERT INTO transaction_archive_files(transaction_id, file)
+	SELECT transaction_id, $2 FROM unnest($1::text[]) AS transaction_id
+	ON CONFLICT (transaction_id) DO UPDATE SET file = EXCLUDED.file, archived_at = NOW()`
+
+	ids := make([]string, 0, len(transactions))
+	versions := make([]int, 0, len(transactions))
+
+	for _, transaction := range transactions {
+		ids = append(ids, transaction.TransactionID)
+		versions = append(versions, transaction.Version)
+	}
+
+	removed := []string{}
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, deleteQuery, ids, versions)
+		if err != nil {
+			return internalError(ctx, "delete archived transactions failed", err)
+		}
+
+		for rows.Next() {
+			var transactionID string
+
+			err = rows.Scan(&transactionID)
+			if err != nil {
+				rows.Close()
+				return internalError(ctx, "scan row failed", err)
+			}
+
+			removed = append(removed, transactionID)
+		}
+
+		rows.Close()
+
+		err = rows.Err()
+		if err != nil {
+			return internalError(ctx, "iterate rows failed", err)
+		}
+
+		if len(removed) == 0 {
+			return nil
+		}
+
+		_, err = tx.Exec(ctx, insertQuery, removed, file)
+		if err != nil {
+			return internalError(ctx, "record archive file failed", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// GetArchiveFile returns the archive file holding a transaction archived outside the database
+func (p postgresService) GetArchiveFile(ctx context.Context, transactionID string) (string, error) {
+	query := `SELECT file FROM transaction_archive_files WHERE transaction_id = $1`
+
+	var file string
+
+	err := p.pool.QueryRow(ctx, query, transactionID).Scan(&file)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", api.NewResourceNotFoundError(database.ErrTransactionNotFound, "transaction")
+	}
+
+	if err != nil {
+		return "", internalError(ctx, "scan row failed", err)
+	}
+
+	return file, nil
+}
+
+// LockArchiveFile runs fn holding an advisory lock named after the archive file, taken in a database transaction so
+// it's released even if the process dies while holding it
+func (p postgresService) LockArchiveFile(ctx context.Context, file string, fn func() error) error {
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "archive_file:"+file)
+		if err != nil {
+			return internalError(ctx, "lock archive file failed", err)
+		}
+
+		return fn()
+	})
+}
+
+// PruneWebhookEventPayloads drops the payloads of the processed webhook events received before the given time, keeping
+// the rest of the event so redeliveries are still recognized. Events that were never processed keep their payload, so
+// they can be replayed
+func (p postgresService) PruneWebhookEventPayloads(ctx context.Context, before time.Time) (int, error) {
+	query := `
+	UPDATE webhook_events
+	SET payload = NULL
+	WHERE received_at < $1
+	AND processed_at IS NOT NULL
+	AND payload IS NOT NULL`
+
+	result, err := p.pool.Exec(ctx, query, before)
+	if err != nil {
+		return 0, internalError(ctx, "execute query failed", err)
+	}
+
+	return int(result.RowsAffected()), nil
+}
+
+// archiveMonth returns the first instant of the month of the time, in UTC, which bounds its archive partition
+func archiveMonth(t time.Time) time.Time {
+	t = t.UTC()
+
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// archivePartitionQuery creates the partition of transactions_archive holding the transactions created in the month
+func archivePartitionQuery(month time.Time) string {
+	return fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS transactions_archive_%s PARTITION OF transactions_archive FOR VALUES FROM ('%s') TO ('%s')`,
+		month.Format("2006_01"),
+		month.Format(time.RFC3339),
+		month.AddDate(0, 1, 0).Format(time.RFC3339),
+	)
+}
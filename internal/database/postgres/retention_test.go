@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	refundableSince := time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC)
+
+	selectQuery := `
+	AND status IN ('succeeded', 'failure')
+	AND NOT (type = 'charge' AND status = 'succeeded' AND created_at >= $2)
+	ORDER BY created_at, transaction_id
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs(before, refundableSince, 100).WillReturnRows(mock.NewRows([]string{"transaction_id", "created_at"}).
+		AddRow("TXN_1", time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)).
+		AddRow("TXN_2", time.Date(2024, 4, 1, 1, 0, 0, 0, time.UTC)).
+		AddRow("TXN_3", time.Date(2024, 4, 20, 1, 0, 0, 0, time.UTC)))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS transactions_archive_2024_03 PARTITION OF transactions_archive FOR VALUES FROM ('2024-03-01T00:00:00Z') TO ('2024-04-01T00:00:00Z')")).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS transactions_archive_2024_04 PARTITION OF transactions_archive FOR VALUES FROM ('2024-04-01T00:00:00Z') TO ('2024-05-01T00:00:00Z')")).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("INSERT INTO transactions_archive").WithArgs([]string{"TXN_1", "TXN_2", "TXN_3"}).WillReturnResult(pgxmock.NewResult("INSERT", 3))
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	archived, err := service.ArchiveTransactions(context.Background(), before, refundableSince, 100)
+	c.NoError(err)
+	c.Equal(3, archived)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestArchiveTransactionsNothingToArchive(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	refundableSince := time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE SKIP LOCKED").WithArgs(before, refundableSince, 100).WillReturnRows(mock.NewRows([]string{"transaction_id", "created_at"}))
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	archived, err := service.ArchiveTransactions(context.Background(), before, refundableSince, 100)
+	c.NoError(err)
+	c.Zero(archived)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestRemoveArchivedTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	transactions := []*models.Transaction{
+		{TransactionID: "TXN_1", Version: 1},
+		{TransactionID: "TXN_2", Version: 4},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM transactions_history").WithArgs([]string{"TXN_1", "TXN_2"}, []int{1, 4}).
+		WillReturnRows(mock.NewRows([]string{"transaction_id"}).AddRow("TXN_1"))
+	mock.ExpectExec("INSERT INTO transaction_archive_files").WithArgs([]string{"TXN_1"}, "transactions-2024-03.ndjson.gz").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	removed, err := service.RemoveArchivedTransactions(context.Background(), "transactions-2024-03.ndjson.gz", transactions)
+	c.NoError(err)
+	c.Equal([]string{"TXN_1"}, removed, "transactions updated since they were read are kept")
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetTransactionArchived(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	createdAt := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("FROM transactions_history").WithArgs("TXN_1").WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery("FROM transactions_archive").WithArgs("TXN_1").WillReturnRows(mock.NewRows(transactionColumnNames).
+		AddRow("TXN_1", "MCH_123", models.TransactionStatusSucceeded, "order 42", "", models.PaymentProviderStripe, 2000, "usd", models.TransactionTypeCharge, `{"charge_id":"ch_1"}`, 0, 0, 0, "", 2, createdAt, createdAt))
+
+	service := postgresService{pool: mock}
+
+	transaction, err := service.GetTransaction(context.Background(), "TXN_1")
+	c.NoError(err)
+	c.Equal("order 42", transaction.Description)
+	c.Equal("ch_1", transaction.AdditionalFields["charge_id"])
+	c.True(transaction.Archived)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetArchiveFileNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	mock.ExpectQuery("FROM transaction_archive_files").WithArgs("TXN_1").WillReturnError(pgx.ErrNoRows)
+
+	service := postgresService{pool: mock}
+
+	_, err = service.GetArchiveFile(context.Background(), "TXN_1")
+	c.ErrorIs(err, database.ErrTransactionNotFound)
+
+	var apiErr api.APIErr
+	c.True(errors.As(err, &apiErr))
+	c.Equal(http.StatusNotFound, apiErr.StatusCode)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestPruneWebhookEventPayloads(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("SET payload = NULL").WithArgs(before).WillReturnResult(pgxmock.NewResult("UPDATE", 12))
+
+	service := postgresService{pool: mock}
+
+	pruned, err := service.PruneWebhookEventPayloads(context.Background(), before)
+	c.NoError(err)
+	c.Equal(12, pruned)
+	c.NoError(mock.ExpectationsWereMet())
+}
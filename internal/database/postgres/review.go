@@ -0,0 +1,308 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetReview fetches a review given its ID
+func (p postgresService) GetReview(ctx context.Context, reviewID string) (*models.Review, error) {
+	query := `
+	SELECT
+		review_id,
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		amount,
+		currency,
+		rules,
+		transfer,
+		COALESCE(reviewer, ''),
+		COALESCE(reason, ''),
+		expires_at,
+		decided_at,
+		created_at
+	FROM reviews
+	WHERE review_id = $1
+	`
+
+	review, err := scanReview(p.pool.QueryRow(ctx, query, reviewID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, api.NewResourceNotFoundError(database.ErrReviewNotFound, "review")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
+
+	return review, nil
+}
+
+// ListReviews fetches the reviews matching the filter, oldest first so the queue is worked in arrival order
+func (p postgresService) ListReviews(ctx context.Context, filter *models.ReviewFilter) ([]*models.Review, error) {
+	query := `
+	SELECT
+		review_id,
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		amount,
+		currency,
+		rules,
+		transfer,
+		COALESCE(reviewer, ''),
+		COALESCE(reason, ''),
+		expires_at,
+		decided_at,
+		created_at
+	FROM reviews`
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if filter.ExpiredBefore != nil {
+		args = append(args, *filter.ExpiredBefore)
+		conditions = append(conditions, fmt.Sprintf("expires_at < $%d", len(args)))
+	}
+
+	if len(conditions) > 0 {
+		query += "\n\tWHERE " + strings.Join(conditions, "\n\tAND ")
+	}
+
+	query += "\n\tORDER BY created_at, review_id"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf("\n\tLIMIT $%d", len(args))
+	}
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	reviews := []*models.Review{}
+
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return reviews, nil
+}
+
+// ClaimReview claims a pending review for the reviewer until the given moment. A claim is only taken over once it
+// lapsed, so a reviewer whose decision never completed doesn't hold the review forever
+func (p postgresService) ClaimReview(ctx context.Context, reviewID, reviewer string, until time.Time) error {
+	query := `
+	UPDATE reviews
+	SET
+		claimed_by = $1,
+		claimed_until = $2
+	WHERE review_id = $3 AND status = 'pending' AND (claimed_until IS NULL OR claimed_until < NOW())`
+
+	tag, err := p.pool.Exec(ctx, query, reviewer, until, reviewID)
+	if err != nil {
+		return internalError(ctx, "claim review failed", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return api.NewConflictError(database.ErrReviewClaimed)
+	}
+
+	return nil
+}
+
+// ReleaseReview gives up the claim of the reviewer on a review, once the payment provider failed to decide on it
+func (p postgresService) ReleaseReview(ctx context.Context, reviewID, reviewer string) error {
+	query := `
+	UPDATE reviews
+	SET
+		claimed_by = NULL,
+		claimed_until = NULL
+	WHERE review_id = $1 AND status = 'pending' AND claimed_by = $2`
+
+	_, err := p.pool.Exec(ctx, query, reviewID, reviewer)
+	if err != nil {
+		return internalError(ctx, "release review failed", err)
+	}
+
+	return nil
+}
+
+// DecideReview records the decision on a pending review claimed by its reviewer and applies it to its transaction, merging the additional
+// fields reported by the provider, along with the transaction.updated event and the audit entry. The status of a
+// transaction already moved out of review by a webhook of its provider is kept
+func (p postgresService) DecideReview(ctx context.Context, review *models.Review, decided *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error) {
+	reviewQuery := `
+	UPDATE reviews
+	SET
+		status = $1,
+		reviewer = $2,
+		reason = NULLIF($3, ''),
+		decided_at = $4
+	WHERE review_id = $5 AND status = 'pending' AND claimed_by = $2`
+
+	transactionQuery := `
+	UPDATE transactions_history
+	SET
+		status = CASE WHEN status = 'review' THEN $1 ELSE status END,
+		failure_reason = CASE WHEN status = 'review' THEN $2 ELSE failure_reason END,
+		additional_fields = COALESCE(additional_fields, '{}'::jsonb) || COALESCE($3, '{}'::jsonb),
+		provider_fee = provider_fee + $4,
+		fee_currency = COALESCE(NULLIF($5, ''), fee_currency),
+		platform_fee = $6,
+		net_amount = $7,
+		version = version + 1,
+		updated_at = NOW()
+	WHERE transaction_id = $8
+	RETURNING
+		transaction_id,
+		COALESCE(merchant_id, ''),
+		status,
+		description,
+		failure_reason,
+		payment_provider,
+		amount,
+		currency,
+		type,
+		additional_fields,
+		provider_fee,
+		platform_fee,
+		net_amount,
+		COALESCE(fee_currency, ''),
+		version,
+		created_at,
+		updated_at
+	`
+
+	var transaction *models.Transaction
+
+	additionalFields, err := p.encryptFields(ctx, decided.AdditionalFields)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.withTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, reviewQuery, review.Status, review.Reviewer, review.Reason, review.DecidedAt, review.ReviewID)
+		if err != nil {
+			return internalError(ctx, "update review failed", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			return api.NewConflictError(database.ErrReviewAlreadyDecided)
+		}
+
+		transaction, err = scanTransaction(tx.QueryRow(ctx, transactionQuery, decided.Status, decided.FailureReason, additionalFields, decided.ProviderFee, decided.FeeCurrency, decided.PlatformFee, decided.NetAmount, review.TransactionID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return api.NewResourceNotFoundError(database.ErrTransactionNotFound, "transaction")
+		}
+
+		if err != nil {
+			return internalError(ctx, "update and scan row failed", err)
+		}
+
+		err = insertTransfers(ctx, tx, decided.Transfers)
+		if err != nil {
+			return err
+		}
+
+		transaction.Transfers = decided.Transfers
+
+		err = insertEvent(ctx, tx, models.EventTypeTransactionUpdated, models.AggregateTransaction, transaction.TransactionID, transaction)
+		if err != nil {
+			return err
+		}
+
+		return p.insertAuditEntry(ctx, tx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.decryptTransaction(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// insertReview writes the review of a transaction in the same database transaction as the transaction itself
+func insertReview(ctx context.Context, tx pgx.Tx, review *models.Review) error {
+	if review == nil {
+		return nil
+	}
+
+	query := `
+	INSERT INTO reviews(
+		review_id,
+		transaction_id,
+		merchant_id,
+		status,
+		amount,
+		currency,
+		rules,
+		transfer,
+		expires_at,
+		created_at
+	) VALUES($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := tx.Exec(ctx, query, review.ReviewID, review.TransactionID, review.MerchantID, review.Status, review.Amount, review.Currency, review.Rules, review.Transfer, review.ExpiresAt, review.CreatedAt)
+	if err != nil {
+		return internalError(ctx, "insert review failed", err)
+	}
+
+	return nil
+}
+
+func scanReview(row pgx.Row) (*models.Review, error) {
+	var review models.Review
+
+	err := row.Scan(
+		&review.ReviewID,
+		&review.TransactionID,
+		&review.MerchantID,
+		&review.Status,
+		&review.Amount,
+		&review.Currency,
+		&review.Rules,
+		&review.Transfer,
+		&review.Reviewer,
+		&review.Reason,
+		&review.ExpiresAt,
+		&review.DecidedAt,
+		&review.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
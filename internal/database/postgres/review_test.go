@@ -0,0 +1,252 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+var reviewColumns = []string{"review_id", "transaction_id", "merchant_id", "status", "amount", "currency", "rules", "transfer", "reviewer", "reason", "expires_at", "decided_at", "created_at"}
+
+func TestGetReviewNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	mock.ExpectQuery("FROM reviews").WithArgs("REV_123").WillReturnError(pgx.ErrNoRows)
+
+	service := postgresService{pool: mock}
+
+	_, err = service.GetReview(context.Background(), "REV_123")
+	c.ErrorIs(err, database.ErrReviewNotFound)
+}
+
+func TestListReviews(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	rows := mock.NewRows(reviewColumns).
+		AddRow("REV_1", "TXN_1", "MCH_123", models.ReviewStatusPending, 150000, "usd", []string{"large_charge"}, nil, "", "", now.Add(-time.Minute), nil, now.Add(-24*time.Hour))
+
+	query := `
+	FROM reviews
+	WHERE status = $1
+	AND expires_at < $2
+	ORDER BY created_at, review_id
+	LIMIT $3`
+
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(models.ReviewStatusPending, now, 50).WillReturnRows(rows)
+
+	service := postgresService{pool: mock}
+
+	reviews, err := service.ListReviews(context.Background(), &models.ReviewFilter{
+		Status:        models.ReviewStatusPending,
+		ExpiredBefore: &now,
+		Limit:         50,
+	})
+	c.NoError(err)
+	c.Len(reviews, 1)
+	c.Equal([]string{"large_charge"}, reviews[0].Rules)
+	c.Nil(reviews[0].DecidedAt)
+	c.Nil(reviews[0].Transfer)
+}
+
+func TestInsertTransactionWithReview(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	now := time.Now()
+
+	review := &models.Review{
+		ReviewID:      "REV_123",
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.ReviewStatusPending,
+		Amount:        150000,
+		Currency:      "usd",
+		Rules:         []string{"large_charge"},
+		Transfer:      &models.Transfer{TransferID: "TRF_123", TransactionID: "TXN_123", AccountID: "ACC_123", Type: models.TransferTypeTransfer, Amount: 135000, Currency: "usd"},
+		ExpiresAt:     now.Add(24 * time.Hour),
+		CreatedAt:     now,
+	}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusReview,
+		Provider:      models.PaymentProviderStripe,
+		Amount:        150000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+		Review:        review,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO transactions_history").WithArgs(
+		transaction.TransactionID,
+		transaction.MerchantID,
+		transaction.Status,
+		transaction.Description,
+		transaction.FailureReason,
+		transaction.Provider,
+		transaction.Amount,
+		transaction.Currency,
+		transaction.Type,
+		transaction.AdditionalFields,
+		transaction.ProviderFee,
+		transaction.PlatformFee,
+		transaction.NetAmount,
+		transaction.FeeCurrency,
+		pgxmock.AnyArg(),
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO reviews").WithArgs(
+		review.ReviewID,
+		review.TransactionID,
+		review.MerchantID,
+		review.Status,
+		review.Amount,
+		review.Currency,
+		review.Rules,
+		review.Transfer,
+		review.ExpiresAt,
+		review.CreatedAt,
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionCreated, models.AggregateTransaction, transaction.TransactionID, pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, audit.SystemActor, models.AuditActionCreatePayment, models.AuditResourceTransaction, transaction.TransactionID, "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	err = service.InsertTransaction(context.Background(), transaction, &models.AuditEntry{Action: models.AuditActionCreatePayment, ResourceType: models.AuditResourceTransaction, ResourceID: transaction.TransactionID})
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestDecideReview(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	decidedAt := time.Now()
+
+	review := &models.Review{
+		ReviewID:      "REV_123",
+		TransactionID: "TXN_123",
+		Status:        models.ReviewStatusApproved,
+		Reviewer:      "alice",
+		DecidedAt:     &decidedAt,
+	}
+
+	decided := &models.Transaction{
+		Status:           models.TransactionStatusSucceeded,
+		AdditionalFields: map[string]interface{}{"charge_id": "ch_123", "payment_intent_id": "pi_123"},
+		ProviderFee:      4380,
+		FeeCurrency:      "usd",
+		PlatformFee:      4380,
+		NetAmount:        145620,
+	}
+
+	entry := &models.AuditEntry{
+		Actor:        "alice",
+		Action:       models.AuditActionApproveReview,
+		ResourceType: models.AuditResourceReview,
+		ResourceID:   "REV_123",
+	}
+
+	columns := []string{"transaction_id", "merchant_id", "status", "description", "failure_reason", "payment_provider", "amount", "currency", "type", "additional_fields", "provider_fee", "platform_fee", "net_amount", "fee_currency", "version", "created_at", "updated_at"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE reviews").WithArgs(models.ReviewStatusApproved, "alice", "", &decidedAt, "REV_123").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery("UPDATE transactions_history").
+		WithArgs(decided.Status, decided.FailureReason, decided.AdditionalFields, decided.ProviderFee, decided.FeeCurrency, decided.PlatformFee, decided.NetAmount, "TXN_123").
+		WillReturnRows(mock.NewRows(columns).AddRow("TXN_123", "MCH_123", models.TransactionStatusSucceeded, "Flagged", "", models.PaymentProviderStripe, 150000, "usd", models.TransactionTypeCharge, `{"charge_id":"ch_123","payment_intent_id":"pi_123"}`, 4380, 4380, 145620, "usd", 2, decidedAt, decidedAt))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionUpdated, models.AggregateTransaction, "TXN_123", pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, "alice", models.AuditActionApproveReview, models.AuditResourceReview, "REV_123", "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	transaction, err := service.DecideReview(context.Background(), review, decided, entry)
+	c.NoError(err)
+	c.Equal(models.TransactionStatusSucceeded, transaction.Status)
+	c.Equal(2, transaction.Version)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestDecideReviewAlreadyDecided(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	decidedAt := time.Now()
+
+	review := &models.Review{
+		ReviewID:      "REV_123",
+		TransactionID: "TXN_123",
+		Status:        models.ReviewStatusRejected,
+		Reviewer:      models.ReviewerTimeout,
+		Reason:        "review window expired",
+		DecidedAt:     &decidedAt,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE reviews").WithArgs(review.Status, review.Reviewer, review.Reason, &decidedAt, "REV_123").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	_, err = service.DecideReview(context.Background(), review, &models.Transaction{}, &models.AuditEntry{})
+	c.ErrorIs(err, database.ErrReviewAlreadyDecided)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestClaimReview(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	until := time.Now().Add(2 * time.Minute)
+
+	mock.ExpectExec(regexp.QuoteMeta("WHERE review_id = $3 AND status = 'pending' AND (claimed_until IS NULL OR claimed_until < NOW())")).
+		WithArgs("alice", until, "REV_123").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("UPDATE reviews").WithArgs("bob", until, "REV_123").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	service := postgresService{pool: mock}
+
+	err = service.ClaimReview(context.Background(), "REV_123", "alice", until)
+	c.NoError(err)
+
+	err = service.ClaimReview(context.Background(), "REV_123", "bob", until)
+	c.ErrorIs(err, database.ErrReviewClaimed, "the claim of alice hasn't lapsed")
+	c.NoError(mock.ExpectationsWereMet())
+}
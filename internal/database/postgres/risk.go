@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// riskFieldColumns columns of the risk decisions holding each payment attribute, so fields never reach the query text
+var riskFieldColumns = map[models.RiskField]string{
+	models.RiskFieldPaymentMethod: "payment_method",
+	models.RiskFieldCustomer:      "customer",
+	models.RiskFieldIPAddress:     "ip_address",
+	models.RiskFieldCountry:       "country",
+}
+
+// CountRiskDecisions counts the decisions taken since the given moment on payments of the merchant with the same field
+// value, so the traffic of a merchant never counts towards the limits of another one
+func (p postgresService) CountRiskDecisions(ctx context.Context, merchantID string, field models.RiskField, value string, since time.Time) (int, error) {
+	column, ok := riskFieldColumns[field]
+	if !ok {
+		return 0, internalError(ctx, "count risk decisions failed", fmt.Errorf("unknown risk field %q", field))
+	}
+
+	query := fmt.Sprintf(`
+	SELECT COUNT(*)
+	FROM risk_decisions
+	WHERE %s = $1 AND created_at >= $2
+	AND merchant_id IS NOT DISTINCT FROM NULLIF($3, '')
+	`, column)
+
+	var count int
+
+	err := p.pool.QueryRow(ctx, query, value, since, merchantID).Scan(&count)
+	if err != nil {
+		return 0, internalError(ctx, "scan row failed", err)
+	}
+
+	return count, nil
+}
+
+// insertRiskDecision writes the risk decision of a transaction in the same database transaction as the transaction itself
+func insertRiskDecision(ctx context.Context, tx pgx.Tx, decision *models.RiskDecision) error {
+	if decision == nil {
+		return nil
+	}
+
+	query := `
+	INSERT INTO risk_decisions(
+		decision_id,
+		transaction_id,
+		merchant_id,
+		outcome,
+		rules,
+		payment_method,
+		customer,
+		ip_address,
+		country,
+		amount,
+		currency,
+		created_at
+	) VALUES($1, $2, NULLIF($3, ''), $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $11, $12)`
+
+	_, err := tx.Exec(ctx, query, decision.DecisionID, decision.TransactionID, decision.MerchantID, decision.Outcome, decision.Rules, decision.PaymentMethod, decision.Customer, decision.IPAddress, decision.Country, decision.Amount, decision.Currency, decision.CreatedAt)
+	if err != nil {
+		return internalError(ctx, "insert risk decision failed", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertTransactionWithRiskDecision(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	decision := &models.RiskDecision{
+		DecisionID:    "RSK_123",
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Outcome:       models.RiskOutcomeBlock,
+		Rules:         []*models.RiskRuleResult{{Rule: "known_fraudsters", Type: "block_list", Matched: true, Action: models.RiskOutcomeBlock}},
+		PaymentMethod: "pm_card_visa",
+		Customer:      "fraud@example.com",
+		Amount:        2000,
+		Currency:      "usd",
+		CreatedAt:     time.Now(),
+	}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusFailure,
+		FailureReason: models.FailureReasonRiskBlocked,
+		Provider:      models.PaymentProviderStripe,
+		Amount:        2000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+		RiskDecision:  decision,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO transactions_history").WithArgs(
+		transaction.TransactionID,
+		transaction.MerchantID,
+		transaction.Status,
+		transaction.Description,
+		transaction.FailureReason,
+		transaction.Provider,
+		transaction.Amount,
+		transaction.Currency,
+		transaction.Type,
+		transaction.AdditionalFields,
+		transaction.ProviderFee,
+		transaction.PlatformFee,
+		transaction.NetAmount,
+		transaction.FeeCurrency,
+		pgxmock.AnyArg(),
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO risk_decisions").WithArgs(
+		decision.DecisionID,
+		decision.TransactionID,
+		decision.MerchantID,
+		decision.Outcome,
+		decision.Rules,
+		decision.PaymentMethod,
+		decision.Customer,
+		decision.IPAddress,
+		decision.Country,
+		decision.Amount,
+		decision.Currency,
+		decision.CreatedAt,
+	).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(pgxmock.AnyArg(), models.EventTypeTransactionCreated, models.AggregateTransaction, transaction.TransactionID, pgxmock.AnyArg()).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectAuditEntry(mock, audit.SystemActor, models.AuditActionCreatePayment, models.AuditResourceTransaction, transaction.TransactionID, "")
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	err = service.InsertTransaction(context.Background(), transaction, &models.AuditEntry{Action: models.AuditActionCreatePayment, ResourceType: models.AuditResourceTransaction, ResourceID: transaction.TransactionID})
+	c.NoError(err)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestCountRiskDecisions(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	since := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery(`FROM risk_decisions\s+WHERE ip_address = \$1 AND created_at >= \$2\s+AND merchant_id IS NOT DISTINCT FROM NULLIF\(\$3, ''\)`).
+		WithArgs("203.0.113.7", since, "MCH_123").
+		WillReturnRows(mock.NewRows([]string{"count"}).AddRow(4))
+
+	service := postgresService{pool: mock}
+
+	count, err := service.CountRiskDecisions(context.Background(), "MCH_123", models.RiskFieldIPAddress, "203.0.113.7", since)
+	c.NoError(err)
+	c.Equal(4, count)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestCountRiskDecisionsUnknownField(t *testing.T) {
+	c := require.New(t)
+
+	service := postgresService{}
+
+	_, err := service.CountRiskDecisions(context.Background(), "MCH_123", models.RiskField("email; DROP TABLE"), "x", time.Now())
+	c.Error(err)
+}
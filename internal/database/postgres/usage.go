@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// usageQuery aggregates the running totals of a merchant per currency over the periods of the limits, given the
+// merchant, the day, the first day of its month and the first day of the refund ratio window
+const usageQuery = `
+	SELECT
+		currency,
+		COALESCE(SUM(charged_volume) FILTER (WHERE day = $2::date), 0)::bigint,
+		COALESCE(SUM(charged_volume) FILTER (WHERE day >= $3::date), 0)::bigint,
+		COALESCE(SUM(charged_volume) FILTER (WHERE day >= $4::date), 0)::bigint,
+		COALESCE(SUM(refunded_volume) FILTER (WHERE day >= $4::date), 0)::bigint
+	FROM merchant_usage
+	WHERE merchant_id = $1 AND day >= LEAST($3::date, $4::date) AND day <= $2::date`
+
+// ReserveUsage adds the reservation to the running totals of its day, which locks the totals of the merchant in the
+// currency until the database transaction ends, and calls check with the resulting usage. The reservation is rolled
+// back when check fails, so concurrent payments can't exceed a limit together
+func (p postgresService) ReserveUsage(ctx context.Context, reservation *models.UsageReservation, check func(*models.Usage) error) (*models.Usage, error) {
+	upsertQuery := `
+	INSERT INTO merchant_usage(
+		merchant_id,
+		currency,
+		day,
+		charged_volume,
+		refunded_volume
+	) VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (merchant_id, currency, day) DO UPDATE
+	SET
+		charged_volume = merchant_usage.charged_volume + EXCLUDED.charged_volume,
+		refunded_volume = merchant_usage.refunded_volume + EXCLUDED.refunded_volume,
+		updated_at = NOW()`
+
+	day, month, window := models.UsagePeriods(reservation.Day)
+
+	var usage *models.Usage
+
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, upsertQuery, reservation.MerchantID, reservation.Currency, day, reservation.Charged, reservation.Refunded)
+		if err != nil {
+			return internalError(ctx, "upsert usage failed", err)
+		}
+
+		usage, err = scanUsage(tx.QueryRow(ctx, usageQuery+"\n\tAND currency = $5\n\tGROUP BY currency", reservation.MerchantID, day, month, window, reservation.Currency))
+		if err != nil {
+			return internalError(ctx, "scan row failed", err)
+		}
+
+		usage.MerchantID = reservation.MerchantID
+
+		return check(usage)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// ReleaseUsage subtracts the reservation from the running totals of its day
+func (p postgresService) ReleaseUsage(ctx context.Context, reservation *models.UsageReservation) error {
+	query := `
+	UPDATE merchant_usage
+	SET
+		charged_volume = charged_volume - $4,
+		refunded_volume = refunded_volume - $5,
+		updated_at = NOW()
+	WHERE merchant_id = $1 AND currency = $2 AND day = $3`
+
+	day, _, _ := models.UsagePeriods(reservation.Day)
+
+	_, err := p.pool.Exec(ctx, query, reservation.MerchantID, reservation.Currency, day, reservation.Charged, reservation.Refunded)
+	if err != nil {
+		return internalError(ctx, "execute query failed", err)
+	}
+
+	return nil
+}
+
+// ListUsage computes the running totals of the merchant at the given moment for every currency it charged or
+// refunded in over the periods of the limits
+func (p postgresService) ListUsage(ctx context.Context, merchantID string, at time.Time) ([]*models.Usage, error) {
+	day, month, window := models.UsagePeriods(at)
+
+	rows, err := p.pool.Query(ctx, usageQuery+"\n\tGROUP BY currency\n\tORDER BY currency", merchantID, day, month, window)
+	if err != nil {
+		return nil, internalError(ctx, "execute query failed", err)
+	}
+
+	defer rows.Close()
+
+	usages := []*models.Usage{}
+
+	for rows.Next() {
+		usage, err := scanUsage(rows)
+		if err != nil {
+			return nil, internalError(ctx, "scan row failed", err)
+		}
+
+		usage.MerchantID = merchantID
+		usages = append(usages, usage)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, internalError(ctx, "iterate rows failed", err)
+	}
+
+	return usages, nil
+}
+
+func scanUsage(row pgx.Row) (*models.Usage, error) {
+	var usage models.Usage
+
+	err := row.Scan(
+		&usage.Currency,
+		&usage.DailyVolume,
+		&usage.MonthlyVolume,
+		&usage.WindowVolume,
+		&usage.WindowRefunds,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+)
+
+var usageColumns = []string{"currency", "daily_volume", "monthly_volume", "window_volume", "window_refunds"}
+
+func TestReserveUsage(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	at := time.Date(2024, 3, 15, 18, 30, 0, 0, time.UTC)
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	window := time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC)
+
+	reservation := &models.UsageReservation{MerchantID: "MCH_123", Currency: "usd", Day: at, Charged: 2000}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO merchant_usage").WithArgs("MCH_123", "usd", day, 2000, 0).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectQuery("FROM merchant_usage").WithArgs("MCH_123", day, month, window, "usd").
+		WillReturnRows(mock.NewRows(usageColumns).AddRow("usd", 5000, 12000, 30000, 1000))
+	mock.ExpectCommit()
+
+	service := postgresService{pool: mock}
+
+	usage, err := service.ReserveUsage(context.Background(), reservation, func(usage *models.Usage) error {
+		return nil
+	})
+	c.NoError(err)
+	c.Equal(&models.Usage{MerchantID: "MCH_123", Currency: "usd", DailyVolume: 5000, MonthlyVolume: 12000, WindowVolume: 30000, WindowRefunds: 1000}, usage)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestReserveUsageCheckFailure(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	reservation := &models.UsageReservation{MerchantID: "MCH_123", Currency: "usd", Day: time.Now(), Charged: 2000}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO merchant_usage").WithArgs("MCH_123", "usd", pgxmock.AnyArg(), 2000, 0).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectQuery("FROM merchant_usage").WithArgs("MCH_123", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), "usd").WillReturnRows(mock.NewRows(usageColumns).AddRow("usd", 6000, 6000, 6000, 0))
+	mock.ExpectRollback()
+
+	service := postgresService{pool: mock}
+
+	customErr := errors.New("daily volume exceeded")
+
+	_, err = service.ReserveUsage(context.Background(), reservation, func(usage *models.Usage) error {
+		return customErr
+	})
+	c.ErrorIs(err, customErr)
+	c.NoError(mock.ExpectationsWereMet())
+}
+
+func TestListUsage(t *testing.T) {
+	c := require.New(t)
+
+	mock, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer mock.Close()
+
+	rows := mock.NewRows(usageColumns).
+		AddRow("eur", 0, 0, 4000, 0).
+		AddRow("usd", 2000, 8000, 8000, 500)
+
+	mock.ExpectQuery("GROUP BY currency").WithArgs("MCH_123", pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).WillReturnRows(rows)
+
+	service := postgresService{pool: mock}
+
+	usages, err := service.ListUsage(context.Background(), "MCH_123", time.Now())
+	c.NoError(err)
+	c.Len(usages, 2)
+	c.Equal("MCH_123", usages[1].MerchantID)
+	c.Equal(500, usages[1].WindowRefunds)
+}
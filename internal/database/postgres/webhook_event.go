@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// InsertWebhookEvent stores a verified webhook event, ignoring redeliveries of an event already stored
+func (p postgresService) InsertWebhookEvent(ctx context.Context, event *models.WebhookEvent) error {
+	query := `
+	INSERT INTO webhook_events(
+		event_id,
+		provider,
+		type,
+		payload,
+		received_at
+	) VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (event_id) DO NOTHING`
+
+	_, err := p.pool.Exec(ctx, query, event.EventID, event.Provider, event.Type, string(event.Payload), event.ReceivedAt)
+	if err != nil {
+		return internalError(ctx, "execute query failed", err)
+	}
+
+	return nil
+}
+
+const webhookEventColumns = `
+		event_id,
+		provider,
+		type,
+		COALESCE(payload::text, ''),
+		received_at,
+		processed_at,
+		attempts,
+		COALESCE(last_error, '')`
+
+// GetWebhookEvent fetches a stored webhook event given its ID
+func (p postgresService) GetWebhookEvent(ctx context.Context, eventID string) (*models.WebhookEvent, error) {
+	query := `
+	SELECT` + webhookEventColumns + `
+	FROM webhook_events
+	WHERE event_id = $1
+	`
+
+	event, err := scanWebhookEvent(p.pool.QueryRow(ctx, query, eventID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, api.NewResourceNotFoundError(database.ErrWebhookEventNotFound, "webhook_event")
+	}
+
+	if err != nil {
+		return nil, internalError(ctx, "scan row failed", err)
+	}
+
+	return event, nil
+}
+
+// RecordWebhookEventOutcome counts an attempt to process a webhook event, keeping the error of the latest failed attempt
+func (p postgresService) RecordWebhookEventOutcome(ctx context.Context, eventID string, processingErr error) error {
+	query := `
+	UPDATE webhook_events
+	SET
+		attempts = attempts + 1,
+		processed_at = CASE WHEN $2 = '' THEN NOW() ELSE processed_at END,
+		last_error = NULLIF($2, '')
+	WHERE event_id = $1`
+
+	lastError := ""
+	if processingErr != nil {
+		lastError = processingErr.Error()
+	}
+
+	_, err := p.pool.Exec(ctx, query, eventID, lastError)
+	if err != nil {
+		return internalError(ctx, "execute query failed", err)
+	}
+
+	return nil
+}
+
+func scanWebhookEvent(row pgx.Row) (*models.WebhookEvent, error) {
+	var (
+		event   models.WebhookEvent
+		payload string
+	)
+
+	err := row.Scan(
+		&event.EventID,
+		&event.Provider,
+		&event.Type,
+		&payload,
+		&event.ReceivedAt,
+		&event.ProcessedAt,
+		&event.Attempts,
+		&event.LastError,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// payloads pruned by the retention policy are left empty
+	if payload != "" {
+		event.Payload = []byte(payload)
+	}
+
+	return &event, nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// MaxUpdateAttempts times an update is attempted before a version conflict is returned to the caller
+const MaxUpdateAttempts = 3
+
+// UpdateTransactionWithRetry applies the update returned by fn on top of the current transaction, re-reading it
+// and trying again when it was modified concurrently. When current is nil, the transaction is read first. When fn
+// returns nil, the transaction is left as it is and returned unchanged. The audit entry, when given, is stored along
+// with the update, with the transaction it was applied on as its before snapshot
+func UpdateTransactionWithRetry(ctx context.Context, db Database, transactionID string, current *models.Transaction, entry *models.AuditEntry, fn func(current *models.Transaction) *models.Transaction) (*models.Transaction, error) {
+	var err error
+
+	for attempt := 1; attempt <= MaxUpdateAttempts; attempt++ {
+		if current == nil {
+			current, err = db.GetTransaction(ctx, transactionID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		updatedTransaction := fn(current)
+		if updatedTransaction == nil {
+			return current, nil
+		}
+
+		updatedTransaction.Version = current.Version
+
+		if entry != nil {
+			entry.Before = models.AuditSnapshot(current)
+		}
+
+		var transaction *models.Transaction
+
+		transaction, err = db.UpdateTransaction(ctx, transactionID, updatedTransaction, entry)
+		if !errors.Is(err, ErrTransactionVersionConflict) {
+			return transaction, err
+		}
+
+		slog.WarnContext(ctx, "transaction modified concurrently, retrying update",
+			slog.Int("attempt", attempt),
+			slog.Int("version", current.Version),
+		)
+
+		current = nil
+	}
+
+	return nil, err
+}
@@ -0,0 +1,68 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateTransactionWithRetry(t *testing.T) {
+	c := require.New(t)
+
+	stale := &models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusPending, Version: 1}
+	latest := &models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusSucceeded, Version: 2}
+	expectedTransaction := &models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusSucceeded, Type: models.TransactionTypeRefund, Version: 3}
+
+	entry := &models.AuditEntry{Action: models.AuditActionRefund}
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("UpdateTransaction", context.Background(), "TXN_123", &models.Transaction{Type: models.TransactionTypeRefund, Version: 1}, entry).Return((*models.Transaction)(nil), api.NewConflictError(database.ErrTransactionVersionConflict)).Once()
+	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(latest, nil).Once()
+	mockDatabase.On("UpdateTransaction", context.Background(), "TXN_123", &models.Transaction{Type: models.TransactionTypeRefund, Version: 2}, entry).Return(expectedTransaction, nil).Once()
+
+	transaction, err := database.UpdateTransactionWithRetry(context.Background(), &mockDatabase, "TXN_123", stale, entry, func(current *models.Transaction) *models.Transaction {
+		return &models.Transaction{Type: models.TransactionTypeRefund}
+	})
+	c.NoError(err)
+	c.Equal(expectedTransaction, transaction)
+	c.Contains(string(entry.Before), `"version":2`, "the entry is recorded on top of the transaction it was applied on")
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestUpdateTransactionWithRetryExhausted(t *testing.T) {
+	c := require.New(t)
+
+	current := &models.Transaction{TransactionID: "TXN_123", Version: 4}
+	conflictErr := api.NewConflictError(database.ErrTransactionVersionConflict)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(current, nil).Times(database.MaxUpdateAttempts)
+	mockDatabase.On("UpdateTransaction", context.Background(), "TXN_123", &models.Transaction{Status: models.TransactionStatusSucceeded, Version: 4}, (*models.AuditEntry)(nil)).Return((*models.Transaction)(nil), conflictErr).Times(database.MaxUpdateAttempts)
+
+	_, err := database.UpdateTransactionWithRetry(context.Background(), &mockDatabase, "TXN_123", nil, nil, func(current *models.Transaction) *models.Transaction {
+		return &models.Transaction{Status: models.TransactionStatusSucceeded}
+	})
+	c.ErrorIs(err, database.ErrTransactionVersionConflict)
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestUpdateTransactionWithRetrySkipped(t *testing.T) {
+	c := require.New(t)
+
+	current := &models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusReview, Version: 1}
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(current, nil).Once()
+
+	transaction, err := database.UpdateTransactionWithRetry(context.Background(), &mockDatabase, "TXN_123", nil, &models.AuditEntry{}, func(current *models.Transaction) *models.Transaction {
+		return nil
+	})
+	c.NoError(err)
+	c.Equal(current, transaction)
+	mockDatabase.AssertNotCalled(t, "UpdateTransaction")
+}
@@ -0,0 +1,386 @@
+// Package encryption encrypts designated additional fields of transactions at rest.
+//
+// Every value is encrypted with its own data key using AES-256-GCM, and the data key is wrapped with the master key
+// active at the time (envelope encryption). The value is replaced in place by an envelope holding the ID of the master
+// key, the wrapped data key and the ciphertext, so a new master key can be made active without re-encrypting anything.
+// Envelopes are copied into outbox payloads, audit log snapshots and archive files, which are never re-encrypted, so
+// master keys are kept in the key ring for as long as any of those are retained, even once the stored transactions
+// were re-encrypted. Fields that must stay searchable carry a blind index, a keyed hash of their value that can be
+// matched without decrypting anything.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+)
+
+// ErrDecryptionFailed error when an envelope can't be decrypted with the key it names
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+const (
+	envelopeKeyID      = "key_id"
+	envelopeDataKey    = "data_key"
+	envelopeCiphertext = "ciphertext"
+	// EnvelopeBlindIndex name of the envelope attribute holding the blind index of the value
+	EnvelopeBlindIndex = "blind_index"
+)
+
+// FieldEncryptor encrypts and decrypts the designated fields of a set of additional fields. A nil FieldEncryptor
+// leaves every field in plaintext
+type FieldEncryptor struct {
+	provider KeyProvider
+	fields   []string
+	indexed  map[string]bool
+}
+
+// New builds the encryptor of the configured fields with the keys of the configured file, returning nil when no field
+// is encrypted
+func New(cfg config.Encryption) (*FieldEncryptor, error) {
+	if len(cfg.Fields) == 0 {
+		return nil, nil
+	}
+
+	provider, err := NewFileKeyProvider(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFieldEncryptor(provider, cfg.Fields, cfg.IndexedFields), nil
+}
+
+// NewFieldEncryptor builds the encryptor of the given fields, computing blind indexes for the indexed ones
+func NewFieldEncryptor(provider KeyProvider, fields, indexedFields []string) *FieldEncryptor {
+	indexed := map[string]bool{}
+	for _, field := range indexedFields {
+		indexed[field] = true
+	}
+
+	return &FieldEncryptor{
+		provider: provider,
+		fields:   fields,
+		indexed:  indexed,
+	}
+}
+
+// Fields returns the names of the encrypted fields
+func (e *FieldEncryptor) Fields() []string {
+	if e == nil {
+		return nil
+	}
+
+	return e.fields
+}
+
+// ActiveKeyID returns the ID of the key new values are encrypted with
+func (e *FieldEncryptor) ActiveKeyID(ctx context.Context) (string, error) {
+	key, err := e.provider.ActiveKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return key.ID, nil
+}
+
+// Encrypt returns a copy of the fields where the values of the designated fields are replaced by their envelope.
+// Values already encrypted are kept as they are
+func (e *FieldEncryptor) Encrypt(ctx context.Context, fields map[string]interface{}) (map[string]interface{}, error) {
+	if e == nil || fields == nil {
+		return fields, nil
+	}
+
+	var key *Key
+
+	encrypted := copyFields(fields)
+
+	for _, field := range e.fields {
+		value, ok := fields[field]
+		if !ok || value == nil || isEnvelope(value) {
+			continue
+		}
+
+		if key == nil {
+			var err error
+
+			key, err = e.provider.ActiveKey(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		sealed, err := e.seal(ctx, key, field, value)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt %s: %w", field, err)
+		}
+
+		encrypted[field] = sealed
+	}
+
+	return encrypted, nil
+}
+
+// Decrypt returns a copy of the fields where the envelopes of the designated fields are replaced by their value.
+// Values stored before their field was designated are kept as they are
+func (e *FieldEncryptor) Decrypt(ctx context.Context, fields map[string]interface{}) (map[string]interface{}, error) {
+	if e == nil || fields == nil {
+		return fields, nil
+	}
+
+	decrypted := copyFields(fields)
+
+	for _, field := range e.fields {
+		value, ok := fields[field]
+		if !ok || !isEnvelope(value) {
+			continue
+		}
+
+		opened, err := e.open(ctx, field, value.(map[string]interface{}))
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", field, err)
+		}
+
+		decrypted[field] = opened
+	}
+
+	return decrypted, nil
+}
+
+// Rotate returns a copy of the fields where the designated fields encrypted with an older key, or not encrypted at
+// all, are encrypted with the active key, reporting whether any field changed
+func (e *FieldEncryptor) Rotate(ctx context.Context, fields map[string]interface{}) (map[string]interface{}, bool, error) {
+	if e == nil || fields == nil {
+		return fields, false, nil
+	}
+
+	key, err := e.provider.ActiveKey(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rotated := copyFields(fields)
+	changed := false
+
+	for _, field := range e.fields {
+		value, ok := fields[field]
+		if !ok || value == nil {
+			continue
+		}
+
+		if isEnvelope(value) {
+			envelope := value.(map[string]interface{})
+			if envelope[envelopeKeyID] == key.ID {
+				continue
+			}
+
+			value, err = e.open(ctx, field, envelope)
+			if err != nil {
+				return nil, false, fmt.Errorf("decrypt %s: %w", field, err)
+			}
+		}
+
+		rotated[field], err = e.seal(ctx, key, field, value)
+		if err != nil {
+			return nil, false, fmt.Errorf("encrypt %s: %w", field, err)
+		}
+
+		changed = true
+	}
+
+	return rotated, changed, nil
+}
+
+// BlindIndexes returns the blind index the value would have in each indexed field, so fields holding it can be
+// matched without decrypting them
+func (e *FieldEncryptor) BlindIndexes(ctx context.Context, value string) (map[string]string, error) {
+	if e == nil || len(e.indexed) == 0 {
+		return nil, nil
+	}
+
+	indexKey, err := e.provider.IndexKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := map[string]string{}
+
+	for _, field := range e.fields {
+		if e.indexed[field] {
+			indexes[field] = blindIndex(indexKey, field, value)
+		}
+	}
+
+	return indexes, nil
+}
+
+// seal encrypts the JSON encoding of the value with a new data key, wrapped with the given master key. The field name
+// is authenticated along with the value, so an envelope can't be moved to another field
+func (e *FieldEncryptor) seal(ctx context.Context, key *Key, field string, value interface{}) (map[string]interface{}, error) {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, keySize)
+
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := encrypt(dataKey, plaintext, []byte(field))
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := encrypt(key.Material, dataKey, []byte(key.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := map[string]interface{}{
+		envelopeKeyID:      key.ID,
+		envelopeDataKey:    base64.StdEncoding.EncodeToString(wrappedKey),
+		envelopeCiphertext: base64.StdEncoding.EncodeToString(ciphertext),
+	}
+
+	if text, ok := value.(string); ok && e.indexed[field] {
+		indexKey, err := e.provider.IndexKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		envelope[EnvelopeBlindIndex] = blindIndex(indexKey, field, text)
+	}
+
+	return envelope, nil
+}
+
+// open unwraps the data key of the envelope with the master key it names and decrypts the value
+func (e *FieldEncryptor) open(ctx context.Context, field string, envelope map[string]interface{}) (interface{}, error) {
+	keyID := envelope[envelopeKeyID].(string)
+
+	key, err := e.provider.Key(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(envelope[envelopeDataKey].(string))
+	if err != nil {
+		return nil, fmt.Errorf("%w: decode data key: %s", ErrDecryptionFailed, err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope[envelopeCiphertext].(string))
+	if err != nil {
+		return nil, fmt.Errorf("%w: decode ciphertext: %s", ErrDecryptionFailed, err)
+	}
+
+	dataKey, err := decrypt(key.Material, wrappedKey, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := decrypt(dataKey, ciphertext, []byte(field))
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+
+	err = json.Unmarshal(plaintext, &value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unmarshal value: %s", ErrDecryptionFailed, err)
+	}
+
+	return value, nil
+}
+
+// isEnvelope reports whether the value is an envelope written by seal
+func isEnvelope(value interface{}) bool {
+	envelope, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, attribute := range []string{envelopeKeyID, envelopeDataKey, envelopeCiphertext} {
+		if _, ok := envelope[attribute].(string); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// blindIndex computes the keyed hash of the value of a field, which only matches the same value in the same field
+func blindIndex(indexKey []byte, field, value string) string {
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encrypt seals the plaintext with AES-GCM, prefixing the ciphertext with its random nonce
+func encrypt(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func decrypt(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecryptionFailed)
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDecryptionFailed, err)
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		copied[key] = value
+	}
+
+	return copied
+}
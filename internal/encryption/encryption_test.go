@@ -0,0 +1,132 @@
+package encryption
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEncryptor(t *testing.T, keyFile string) *FieldEncryptor {
+	t.Helper()
+
+	provider, err := NewFileKeyProvider(writeKeyFile(t, keyFile))
+	require.NoError(t, err)
+
+	return NewFieldEncryptor(provider, []string{"customer_email", "card_details"}, []string{"customer_email"})
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c := require.New(t)
+
+	encryptor := newTestEncryptor(t, testKeyFile)
+	ctx := context.Background()
+
+	fields := map[string]interface{}{
+		"charge_id":      "ch_123",
+		"customer_email": "jane@example.com",
+		"card_details":   map[string]interface{}{"last4": "4242", "exp_year": float64(2030)},
+	}
+
+	encrypted, err := encryptor.Encrypt(ctx, fields)
+	c.NoError(err)
+	c.Equal("ch_123", encrypted["charge_id"])
+	c.Equal("jane@example.com", fields["customer_email"], "the fields of the caller are left untouched")
+
+	email, ok := encrypted["customer_email"].(map[string]interface{})
+	c.True(ok)
+	c.Equal("2026-10", email["key_id"])
+	c.NotContains(email["ciphertext"], "jane")
+
+	indexes, err := encryptor.BlindIndexes(ctx, "jane@example.com")
+	c.NoError(err)
+	c.Equal(map[string]string{"customer_email": email[EnvelopeBlindIndex].(string)}, indexes)
+
+	card, ok := encrypted["card_details"].(map[string]interface{})
+	c.True(ok)
+	c.NotContains(card, EnvelopeBlindIndex)
+
+	again, err := encryptor.Encrypt(ctx, encrypted)
+	c.NoError(err)
+	c.Equal(encrypted, again, "encrypted values are not encrypted twice")
+
+	decrypted, err := encryptor.Decrypt(ctx, encrypted)
+	c.NoError(err)
+	c.Equal(fields, decrypted)
+}
+
+func TestDecryptTampered(t *testing.T) {
+	c := require.New(t)
+
+	encryptor := newTestEncryptor(t, testKeyFile)
+	ctx := context.Background()
+
+	encrypted, err := encryptor.Encrypt(ctx, map[string]interface{}{"customer_email": "jane@example.com", "card_details": "visa"})
+	c.NoError(err)
+
+	// an envelope moved to another field fails to authenticate
+	encrypted["card_details"], encrypted["customer_email"] = encrypted["customer_email"], encrypted["card_details"]
+
+	_, err = encryptor.Decrypt(ctx, encrypted)
+	c.ErrorIs(err, ErrDecryptionFailed)
+
+	encrypted["customer_email"].(map[string]interface{})["key_id"] = "2026-01"
+
+	_, err = encryptor.Decrypt(ctx, encrypted)
+	c.ErrorIs(err, ErrUnknownKey)
+}
+
+func TestRotate(t *testing.T) {
+	c := require.New(t)
+
+	ctx := context.Background()
+
+	previous := newTestEncryptor(t, `
+active_key: "2026-09"
+keys:
+  "2026-09": AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=
+index_key: AwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwM=
+`)
+
+	encrypted, err := previous.Encrypt(ctx, map[string]interface{}{"customer_email": "jane@example.com"})
+	c.NoError(err)
+
+	// a value stored before its field was designated is encrypted by the rotation
+	encrypted["card_details"] = "visa"
+
+	encryptor := newTestEncryptor(t, testKeyFile)
+
+	rotated, changed, err := encryptor.Rotate(ctx, encrypted)
+	c.NoError(err)
+	c.True(changed)
+	c.Equal("2026-10", rotated["customer_email"].(map[string]interface{})["key_id"])
+	c.Equal("2026-10", rotated["card_details"].(map[string]interface{})["key_id"])
+	c.Equal(encrypted["customer_email"].(map[string]interface{})[EnvelopeBlindIndex], rotated["customer_email"].(map[string]interface{})[EnvelopeBlindIndex])
+
+	decrypted, err := encryptor.Decrypt(ctx, rotated)
+	c.NoError(err)
+	c.Equal(map[string]interface{}{"customer_email": "jane@example.com", "card_details": "visa"}, decrypted)
+
+	_, changed, err = encryptor.Rotate(ctx, rotated)
+	c.NoError(err)
+	c.False(changed)
+}
+
+func TestNilEncryptor(t *testing.T) {
+	c := require.New(t)
+
+	encryptor, err := New(config.Encryption{})
+	c.NoError(err)
+	c.Nil(encryptor)
+
+	fields := map[string]interface{}{"customer_email": "jane@example.com"}
+
+	encrypted, err := encryptor.Encrypt(context.Background(), fields)
+	c.NoError(err)
+	c.Equal(fields, encrypted)
+
+	indexes, err := encryptor.BlindIndexes(context.Background(), "jane@example.com")
+	c.NoError(err)
+	c.Empty(indexes)
+}
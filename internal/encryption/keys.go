@@ -0,0 +1,156 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// keySize length of the keys, which select AES-256
+const keySize = 32
+
+// ErrUnknownKey error when a value was encrypted with a key the provider doesn't hold
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Key master key wrapping the data keys of the values encrypted while it was active
+type Key struct {
+	ID       string
+	Material []byte
+}
+
+// KeyProvider source of the master keys, such as a file or a key management service
+type KeyProvider interface {
+	// ActiveKey returns the key new values are encrypted with
+	ActiveKey(ctx context.Context) (*Key, error)
+	// Key returns the key with the given ID, which is kept after another key becomes active so older values can still
+	// be decrypted
+	Key(ctx context.Context, keyID string) (*Key, error)
+	// IndexKey returns the key the blind indexes are computed with
+	IndexKey(ctx context.Context) ([]byte, error)
+}
+
+// keyFile layout of the YAML file read by FileKeyProvider, where keys are base64 encoded
+type keyFile struct {
+	ActiveKey string            `yaml:"active_key"`
+	Keys      map[string]string `yaml:"keys"`
+	IndexKey  string            `yaml:"index_key"`
+}
+
+// FileKeyProvider provider of the keys stored in a local YAML file
+type FileKeyProvider struct {
+	activeKeyID string
+	keys        map[string]*Key
+	indexKey    []byte
+}
+
+// NewFileKeyProvider reads and validates the keys of a YAML file
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open encryption key file: %w", err)
+	}
+
+	defer file.Close()
+
+	var content keyFile
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(&content)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decode encryption key file %s: %w", path, err)
+	}
+
+	provider, err := content.provider()
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key file %s:\n%w", path, err)
+	}
+
+	return provider, nil
+}
+
+func (f keyFile) provider() (*FileKeyProvider, error) {
+	var errs []error
+
+	provider := &FileKeyProvider{
+		activeKeyID: f.ActiveKey,
+		keys:        map[string]*Key{},
+	}
+
+	ids := make([]string, 0, len(f.Keys))
+	for id := range f.Keys {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		material, err := decodeKey(f.Keys[id])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("keys.%s: %w", id, err))
+			continue
+		}
+
+		provider.keys[id] = &Key{ID: id, Material: material}
+	}
+
+	if f.ActiveKey == "" {
+		errs = append(errs, errors.New("active_key: is required"))
+	} else if _, ok := f.Keys[f.ActiveKey]; !ok {
+		errs = append(errs, fmt.Errorf("active_key: must be one of the keys, got %q", f.ActiveKey))
+	}
+
+	indexKey, err := decodeKey(f.IndexKey)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("index_key: %w", err))
+	}
+
+	provider.indexKey = indexKey
+
+	err = errors.Join(errs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	material, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("must be base64 encoded")
+	}
+
+	if len(material) != keySize {
+		return nil, fmt.Errorf("must be %d bytes long, got %d", keySize, len(material))
+	}
+
+	return material, nil
+}
+
+// ActiveKey returns the key named by active_key
+func (p *FileKeyProvider) ActiveKey(ctx context.Context) (*Key, error) {
+	return p.Key(ctx, p.activeKeyID)
+}
+
+// Key returns the key with the given ID
+func (p *FileKeyProvider) Key(_ context.Context, keyID string) (*Key, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	return key, nil
+}
+
+// IndexKey returns the key named by index_key
+func (p *FileKeyProvider) IndexKey(context.Context) ([]byte, error) {
+	return p.indexKey, nil
+}
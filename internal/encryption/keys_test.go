@@ -0,0 +1,75 @@
+package encryption
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testKeyFile = `
+active_key: "2026-10"
+keys:
+  "2026-09": AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=
+  "2026-10": AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=
+index_key: AwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwM=
+`
+
+func writeKeyFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "encryption_keys.yaml")
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	require.NoError(t, err)
+
+	return path
+}
+
+func TestNewFileKeyProvider(t *testing.T) {
+	c := require.New(t)
+
+	provider, err := NewFileKeyProvider(writeKeyFile(t, testKeyFile))
+	c.NoError(err)
+
+	ctx := context.Background()
+
+	active, err := provider.ActiveKey(ctx)
+	c.NoError(err)
+	c.Equal("2026-10", active.ID)
+	c.Len(active.Material, keySize)
+
+	previous, err := provider.Key(ctx, "2026-09")
+	c.NoError(err)
+	c.Equal(byte(1), previous.Material[0])
+
+	_, err = provider.Key(ctx, "2026-08")
+	c.ErrorIs(err, ErrUnknownKey)
+
+	indexKey, err := provider.IndexKey(ctx)
+	c.NoError(err)
+	c.Len(indexKey, keySize)
+}
+
+func TestNewFileKeyProviderInvalid(t *testing.T) {
+	c := require.New(t)
+
+	_, err := NewFileKeyProvider(filepath.Join(t.TempDir(), "missing.yaml"))
+	c.ErrorContains(err, "open encryption key file")
+
+	_, err = NewFileKeyProvider(writeKeyFile(t, `
+active_key: "2026-11"
+keys:
+  "2026-10": c2hvcnQ=
+  "2026-09": not base64
+`))
+	c.ErrorContains(err, "keys.2026-09: must be base64 encoded")
+	c.ErrorContains(err, "keys.2026-10: must be 32 bytes long, got 5")
+	c.ErrorContains(err, `active_key: must be one of the keys, got "2026-11"`)
+	c.ErrorContains(err, "index_key: must be 32 bytes long, got 0")
+
+	_, err = NewFileKeyProvider(writeKeyFile(t, "active: 2026-10\n"))
+	c.ErrorContains(err, "decode encryption key file")
+}
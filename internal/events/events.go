@@ -7,8 +7,10 @@ import (
 	"net/http"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
 )
 
 var (
@@ -18,19 +20,35 @@ var (
 	ErrUnsupportedEvent = errors.New("unsupported event")
 	// ErrEventVerificationFailed error when event couldn't be verified by event handler
 	ErrEventVerificationFailed = errors.New("event verification failed")
+	// ErrMissingWebhookSecret error when the secret used to verify provider events is not configured
+	ErrMissingWebhookSecret = errors.New("missing webhook secret")
 )
 
 // Events interface to implement business logic to handle incoming events from the payment provider
 type Events interface {
+	Type() string
 	VerifyEvent() error
 	ProcessEvent(ctx context.Context) error
+	// WebhookEvent returns the verified event as it's stored for later inspection and replay
+	WebhookEvent() *models.WebhookEvent
 }
 
-// NewEvent constructor to return the proper event handler
-func NewEvent(provider models.PaymentProvider, database database.Database, request *http.Request) (Events, error) {
+// NewEvent constructor to return the proper event handler, where paymentProcessor is the integration of the same
+// provider, used to fetch what the event payload doesn't carry
+func NewEvent(provider models.PaymentProvider, cfg config.Stripe, database database.Database, paymentProcessor paymentprocessor.PaymentProcessor, request *http.Request) (Events, error) {
 	if provider == models.PaymentProviderStripe {
-		return newStripeEvent(database, request), nil
+		return newStripeEvent(cfg, database, paymentProcessor, request), nil
 	}
 
 	return nil, api.NewInvalidRequestError(fmt.Errorf("%w: %s", ErrUnsupportedProvider, provider))
 }
+
+// NewStoredEvent constructor to return the event handler of an event stored when it was received, which was
+// already verified back then
+func NewStoredEvent(event *models.WebhookEvent, database database.Database, paymentProcessor paymentprocessor.PaymentProcessor) (Events, error) {
+	if event.Provider == models.PaymentProviderStripe {
+		return newStoredStripeEvent(event, database, paymentProcessor)
+	}
+
+	return nil, api.NewInvalidRequestError(fmt.Errorf("%w: %s", ErrUnsupportedProvider, event.Provider))
+}
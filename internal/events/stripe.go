@@ -5,20 +5,38 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/tracing"
+	"github.com/oklog/ulid/v2"
 	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/webhook"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var supportedStripeEvents = map[stripe.EventType]bool{
 	stripe.EventTypePaymentIntentSucceeded:     true,
 	stripe.EventTypePaymentIntentPaymentFailed: true,
 	stripe.EventTypeChargeRefunded:             true,
+	stripe.EventTypePayoutCreated:              true,
+	stripe.EventTypePayoutPaid:                 true,
+	stripe.EventTypePayoutFailed:               true,
+}
+
+var payoutEvents = map[stripe.EventType]bool{
+	stripe.EventTypePayoutCreated: true,
+	stripe.EventTypePayoutPaid:    true,
+	stripe.EventTypePayoutFailed:  true,
 }
 
 var eventTypeToStatus = map[stripe.EventType]models.TransactionStatus{
@@ -28,21 +46,62 @@ var eventTypeToStatus = map[stripe.EventType]models.TransactionStatus{
 }
 
 type stripeEvents struct {
-	database database.Database
-	request  *http.Request
-	event    stripe.Event
+	webhookSecret    string
+	database         database.Database
+	paymentProcessor paymentprocessor.PaymentProcessor
+	request          *http.Request
+	event            stripe.Event
+	payload          []byte
+	// stored whether the event was loaded from the store, which means it was verified when received
+	stored bool
 }
 
-func newStripeEvent(database database.Database, request *http.Request) Events {
+func newStripeEvent(cfg config.Stripe, database database.Database, paymentProcessor paymentprocessor.PaymentProcessor, request *http.Request) Events {
+	return &stripeEvents{
+		webhookSecret:    cfg.WebhookSecretKey,
+		database:         database,
+		paymentProcessor: paymentProcessor,
+		request:          request,
+	}
+}
+
+func newStoredStripeEvent(stored *models.WebhookEvent, database database.Database, paymentProcessor paymentprocessor.PaymentProcessor) (Events, error) {
+	var event stripe.Event
+
+	err := json.Unmarshal(stored.Payload, &event)
+	if err != nil {
+		return nil, api.NewInternalServerError(fmt.Errorf("unmarshal stored event: %w", err))
+	}
+
 	return &stripeEvents{
-		database: database,
-		request:  request,
+		database:         database,
+		paymentProcessor: paymentProcessor,
+		event:            event,
+		payload:          stored.Payload,
+		stored:           true,
+	}, nil
+}
+
+// CheckStripeConfiguration verifies the secret used to validate Stripe signatures is configured
+func CheckStripeConfiguration(cfg config.Stripe) error {
+	if !strings.HasPrefix(cfg.WebhookSecretKey, "whsec_") {
+		return ErrMissingWebhookSecret
 	}
+
+	return nil
+}
+
+// Type returns the type of the verified event
+func (e *stripeEvents) Type() string {
+	return string(e.event.Type)
 }
 
 // VerifyEvent validates the incoming event
 func (e *stripeEvents) VerifyEvent() error {
-	webhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET_KEY")
+	if e.stored {
+		return nil
+	}
+
 	stripeSignature := e.request.Header.Get("Stripe-Signature")
 
 	payload, err := io.ReadAll(e.request.Body)
@@ -50,24 +109,47 @@ func (e *stripeEvents) VerifyEvent() error {
 		return api.NewInternalServerError(err)
 	}
 
-	event, err := webhook.ConstructEvent(payload, stripeSignature, webhookSecret)
+	event, err := webhook.ConstructEvent(payload, stripeSignature, e.webhookSecret)
 	if err != nil {
 		return api.NewInvalidRequestError(ErrEventVerificationFailed)
 	}
 
 	e.event = event
+	e.payload = payload
 
 	return nil
 }
 
+// WebhookEvent returns the verified event along with its raw payload
+func (e *stripeEvents) WebhookEvent() *models.WebhookEvent {
+	return &models.WebhookEvent{
+		EventID:    e.event.ID,
+		Provider:   models.PaymentProviderStripe,
+		Type:       string(e.event.Type),
+		Payload:    e.payload,
+		ReceivedAt: time.Now().UTC(),
+	}
+}
+
 // ProcessEvent handles the incoming event according to its type
 func (e *stripeEvents) ProcessEvent(ctx context.Context) error {
+	ctx = logging.With(ctx, slog.String(logging.EventIDKey, e.event.ID), slog.String("event_type", string(e.event.Type)))
+	ctx = audit.WithActor(ctx, models.AuditActorWebhook, string(models.PaymentProviderStripe))
+
 	if _, ok := supportedStripeEvents[e.event.Type]; !ok {
+		slog.WarnContext(ctx, "unsupported stripe event received")
+
 		return api.NewInvalidRequestError(fmt.Errorf("%w: %s", ErrUnsupportedEvent, e.event.Type))
 	}
 
+	if payoutEvents[e.event.Type] {
+		return e.processPayoutEvent(ctx)
+	}
+
 	transaction := &models.Transaction{}
 
+	var metadata map[string]string
+
 	switch e.event.Type {
 	case stripe.EventTypePaymentIntentSucceeded, stripe.EventTypePaymentIntentPaymentFailed:
 		var paymentIntent *stripe.PaymentIntent
@@ -77,6 +159,7 @@ func (e *stripeEvents) ProcessEvent(ctx context.Context) error {
 			return api.NewInternalServerError(err)
 		}
 
+		metadata = paymentIntent.Metadata
 		transaction.TransactionID = paymentIntent.Metadata["transaction_id"]
 		transaction.Status = eventTypeToStatus[e.event.Type]
 		transaction.Type = models.TransactionTypeCharge
@@ -88,15 +171,219 @@ func (e *stripeEvents) ProcessEvent(ctx context.Context) error {
 			return api.NewInternalServerError(err)
 		}
 
+		metadata = charge.Metadata
 		transaction.TransactionID = charge.Metadata["transaction_id"]
 		transaction.Status = eventTypeToStatus[e.event.Type]
 		transaction.Type = models.TransactionTypeRefund
 	}
 
-	_, err := e.database.UpdateTransaction(ctx, transaction.TransactionID, transaction)
+	ctx = logging.With(ctx,
+		slog.String(logging.TransactionIDKey, transaction.TransactionID),
+		slog.String(logging.OriginRequestIDKey, metadata["request_id"]),
+	)
+
+	ctx, span := tracing.StartLinked(ctx, "events.process_stripe_event", metadata,
+		attribute.String("event.id", e.event.ID),
+		attribute.String("event.type", string(e.event.Type)),
+		attribute.String("payment.transaction_id", transaction.TransactionID),
+		attribute.String("payment.status", string(transaction.Status)),
+	)
+
+	var (
+		before  *models.Transaction
+		ignored string
+	)
+
+	entry := &models.AuditEntry{
+		Action:       models.AuditActionProcessEvent,
+		ResourceType: models.AuditResourceTransaction,
+		ResourceID:   transaction.TransactionID,
+		Details:      map[string]interface{}{"event_id": e.event.ID, "event_type": string(e.event.Type)},
+	}
+
+	_, err := database.UpdateTransactionWithRetry(ctx, e.database, transaction.TransactionID, nil, entry, func(current *models.Transaction) *models.Transaction {
+		before = current
+
+		ignored = ignoredReason(current, transaction)
+		if ignored != "" {
+			return nil
+		}
+
+		return transaction
+	})
+
+	tracing.End(span, err)
+
 	if err != nil {
 		return err
 	}
 
+	// the event is acknowledged, so Stripe doesn't keep delivering an event that will never be applied
+	if ignored != "" {
+		slog.WarnContext(ctx, "stripe event ignored",
+			slog.String("reason", ignored),
+			slog.String("current_status", string(before.Status)),
+			slog.String("status", string(transaction.Status)),
+		)
+
+		return nil
+	}
+
+	// refunds reach the provider once approved, but can also be issued outside of the platform, and either way close
+	// the request held for them. Closing is checked on every refund event, so a redelivered event closes a request
+	// left pending by an earlier failure
+	if transaction.Type == models.TransactionTypeRefund {
+		err = e.closeRefundRequest(ctx, transaction.TransactionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// charges failing after they were created no longer count towards the volume of the merchant
+	if transaction.Status == models.TransactionStatusFailure && before.Type == models.TransactionTypeCharge && before.Status != models.TransactionStatusFailure {
+		e.releaseUsage(ctx, before)
+	}
+
+	slog.InfoContext(ctx, "stripe event processed",
+		slog.String("status", string(transaction.Status)),
+		slog.String("type", string(transaction.Type)),
+	)
+
 	return nil
 }
+
+// releaseUsage subtracts the amount of the charge from the totals of the day it was reserved on. The event was already
+// applied, so failing to release it is reported without failing the event
+func (e *stripeEvents) releaseUsage(ctx context.Context, charge *models.Transaction) {
+	reservation := &models.UsageReservation{
+		MerchantID: charge.MerchantID,
+		Currency:   strings.ToLower(charge.Currency),
+		Day:        charge.CreatedAt,
+		Charged:    charge.Amount,
+	}
+
+	err := e.database.ReleaseUsage(ctx, reservation)
+	if err != nil {
+		slog.ErrorContext(ctx, "release usage failed", slog.String("merchant_id", reservation.MerchantID), slog.String("currency", reservation.Currency), slog.Any("error", err))
+	}
+}
+
+// ignoredReason returns why the event can't be applied to the transaction, or an empty string when it can. Archived
+// transactions are no longer updated, and transactions held for a review or a refund approval only leave that status
+// through the decision of an operator, which takes the status reported by Stripe at that point. A refund reported for
+// a transaction held for a refund approval already went through, so it's applied
+func ignoredReason(current, transaction *models.Transaction) string {
+	if current.Archived {
+		return "transaction archived"
+	}
+
+	if current.Status == models.TransactionStatusReview {
+		return "transaction awaiting decision"
+	}
+
+	if current.Status == models.TransactionStatusRefundRequested && transaction.Type != models.TransactionTypeRefund {
+		return "transaction awaiting decision"
+	}
+
+	return ""
+}
+
+// closeRefundRequest closes the request held for the refund of the transaction, if any. Its amount stays reserved
+// against the limits of the merchant, since the refund went through
+func (e *stripeEvents) closeRefundRequest(ctx context.Context, transactionID string) error {
+	request, err := e.database.CloseRefundRequest(ctx, transactionID, string(models.PaymentProviderStripe), &models.AuditEntry{
+		Action:       models.AuditActionCloseRefundRequest,
+		ResourceType: models.AuditResourceRefundRequest,
+		Details:      map[string]interface{}{"transaction_id": transactionID, "event_id": e.event.ID},
+	})
+	if err != nil {
+		return err
+	}
+
+	if request != nil {
+		slog.InfoContext(ctx, "refund request closed", slog.String("refund_request_id", request.RefundRequestID))
+	}
+
+	return nil
+}
+
+// processPayoutEvent stores the payout of the event and, once it's paid, links it to the transactions it settled
+func (e *stripeEvents) processPayoutEvent(ctx context.Context) error {
+	var payout *stripe.Payout
+
+	err := json.Unmarshal(e.event.Data.Raw, &payout)
+	if err != nil {
+		return api.NewInternalServerError(err)
+	}
+
+	ctx = logging.With(ctx, slog.String("payout_id", payout.ID))
+
+	ctx, span := tracing.StartLinked(ctx, "events.process_stripe_payout_event", payout.Metadata,
+		attribute.String("event.id", e.event.ID),
+		attribute.String("event.type", string(e.event.Type)),
+		attribute.String("payout.provider_id", payout.ID),
+		attribute.String("payout.status", string(payout.Status)),
+	)
+
+	stored := &models.Payout{
+		PayoutID:         fmt.Sprintf("PO_%s", ulid.Make().String()),
+		Provider:         models.PaymentProviderStripe,
+		ProviderPayoutID: payout.ID,
+		Status:           models.PayoutStatus(payout.Status),
+		Amount:           int(payout.Amount),
+		Currency:         string(payout.Currency),
+		FailureReason:    string(payout.FailureCode),
+		ArrivalDate:      time.Unix(payout.ArrivalDate, 0).UTC(),
+		UpdatedAt:        time.Now().UTC(),
+	}
+
+	err = e.database.UpsertPayout(ctx, stored, &models.AuditEntry{
+		Action:       models.AuditActionUpdatePayout,
+		ResourceType: models.AuditResourcePayout,
+		Details:      map[string]interface{}{"event_id": e.event.ID, "event_type": string(e.event.Type)},
+	})
+	if err != nil {
+		tracing.End(span, err)
+
+		return err
+	}
+
+	linked := 0
+
+	if e.event.Type == stripe.EventTypePayoutPaid {
+		linked, err = e.linkPayoutTransactions(ctx, stored)
+	}
+
+	tracing.End(span, err)
+
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "stripe payout event processed",
+		slog.String("status", string(stored.Status)),
+		slog.Int("linked_transactions", linked),
+	)
+
+	return nil
+}
+
+// linkPayoutTransactions fetches the charges and refunds settled by the payout and links them to their transactions
+func (e *stripeEvents) linkPayoutTransactions(ctx context.Context, payout *models.Payout) (int, error) {
+	items, err := e.paymentProcessor.ListPayoutItems(ctx, payout.ProviderPayoutID)
+	if err != nil {
+		return 0, err
+	}
+
+	linked, err := e.database.LinkPayoutTransactions(ctx, payout.PayoutID, items)
+	if err != nil {
+		return 0, err
+	}
+
+	// items of charges made outside the platform, or already linked by a previous delivery of the event
+	if skipped := len(items) - linked; skipped > 0 {
+		slog.InfoContext(ctx, "payout items not linked to a transaction", slog.Int("skipped", skipped))
+	}
+
+	return linked, nil
+}
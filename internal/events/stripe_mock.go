@@ -3,6 +3,7 @@ package events
 import (
 	"context"
 
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -11,6 +12,13 @@ type MockStripe struct {
 	mock.Mock
 }
 
+// Type mock implementation
+func (m *MockStripe) Type() string {
+	args := m.Called()
+
+	return args.String(0)
+}
+
 // VerifyEvent mock implementation
 func (m *MockStripe) VerifyEvent() error {
 	args := m.Called()
@@ -24,3 +32,10 @@ func (m *MockStripe) ProcessEvent(ctx context.Context) error {
 
 	return args.Error(0)
 }
+
+// WebhookEvent mock implementation
+func (m *MockStripe) WebhookEvent() *models.WebhookEvent {
+	args := m.Called()
+
+	return args.Get(0).(*models.WebhookEvent)
+}
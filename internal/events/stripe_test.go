@@ -5,19 +5,29 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	stripeprocessor "github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/webhook"
 )
 
-func TestVerifyEvent(t *testing.T) {
+func TestCheckStripeConfiguration(t *testing.T) {
 	c := require.New(t)
 
-	t.Setenv("STRIPE_WEBHOOK_SECRET_KEY", "stripe_webhook_secret_key")
+	c.ErrorIs(CheckStripeConfiguration(config.Stripe{}), ErrMissingWebhookSecret)
+	c.NoError(CheckStripeConfiguration(config.Stripe{WebhookSecretKey: "whsec_123"}))
+}
+
+func TestVerifyEvent(t *testing.T) {
+	c := require.New(t)
 
 	stripeEvent := stripe.Event{
 		Type:       stripe.EventTypeAccountUpdated,
@@ -37,7 +47,8 @@ func TestVerifyEvent(t *testing.T) {
 	req.Header.Set("Stripe-Signature", signedPayload.Header)
 
 	eventHandler := stripeEvents{
-		request: req,
+		webhookSecret: "stripe_webhook_secret_key",
+		request:       req,
 	}
 
 	err = eventHandler.VerifyEvent()
@@ -74,12 +85,14 @@ func TestProcessEventPaymentIntentEvent(t *testing.T) {
 		TransactionID: paymentIntent.Metadata["transaction_id"],
 		Status:        models.TransactionStatus(paymentIntent.Status),
 		Type:          models.TransactionTypeCharge,
+		Version:       2,
 	}
 
 	rawData, err := json.Marshal(paymentIntent)
 	c.NoError(err)
 
 	stripeEvent := stripe.Event{
+		ID:   "evt_123",
 		Type: stripe.EventTypePaymentIntentSucceeded,
 		Data: &stripe.EventData{
 			Raw: rawData,
@@ -88,7 +101,13 @@ func TestProcessEventPaymentIntentEvent(t *testing.T) {
 
 	mockDatabase := postgres.MockPostgres{}
 
-	mockDatabase.On("UpdateTransaction", context.Background(), "TXN_123", transaction).Return(transaction, nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(&models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusPending, Version: 2}, nil)
+	mockDatabase.On("UpdateTransaction", mock.Anything, "TXN_123", transaction, mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionProcessEvent &&
+			entry.ResourceID == "TXN_123" &&
+			entry.Details["event_id"] == "evt_123" &&
+			strings.Contains(string(entry.Before), `"status":"pending"`)
+	})).Return(transaction, nil)
 
 	eventHandler := stripeEvents{
 		event:    stripeEvent,
@@ -97,6 +116,49 @@ func TestProcessEventPaymentIntentEvent(t *testing.T) {
 
 	err = eventHandler.ProcessEvent(context.Background())
 	c.NoError(err)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestProcessEventPaymentFailedEvent(t *testing.T) {
+	c := require.New(t)
+
+	createdAt := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	rawData, err := json.Marshal(&stripe.PaymentIntent{ID: "pi_123", Metadata: map[string]string{"transaction_id": "TXN_123"}})
+	c.NoError(err)
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusFailure,
+		Type:          models.TransactionTypeCharge,
+		Version:       2,
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(&models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusPending,
+		Type:          models.TransactionTypeCharge,
+		Amount:        2000,
+		Currency:      "USD",
+		CreatedAt:     createdAt,
+		Version:       2,
+	}, nil)
+	mockDatabase.On("UpdateTransaction", mock.Anything, "TXN_123", transaction, mock.Anything).Return(transaction, nil)
+	mockDatabase.On("ReleaseUsage", mock.Anything, &models.UsageReservation{MerchantID: "MCH_123", Currency: "usd", Day: createdAt, Charged: 2000}).Return(nil)
+
+	eventHandler := stripeEvents{
+		event:    stripe.Event{ID: "evt_123", Type: stripe.EventTypePaymentIntentPaymentFailed, Data: &stripe.EventData{Raw: rawData}},
+		database: &mockDatabase,
+	}
+
+	err = eventHandler.ProcessEvent(context.Background())
+	c.NoError(err)
+
+	mockDatabase.AssertExpectations(t)
 }
 
 func TestProcessEventChargeRefundedEvent(t *testing.T) {
@@ -114,6 +176,7 @@ func TestProcessEventChargeRefundedEvent(t *testing.T) {
 		TransactionID: charge.Metadata["transaction_id"],
 		Status:        models.TransactionStatus(charge.Status),
 		Type:          models.TransactionTypeRefund,
+		Version:       2,
 	}
 
 	rawData, err := json.Marshal(charge)
@@ -128,7 +191,9 @@ func TestProcessEventChargeRefundedEvent(t *testing.T) {
 
 	mockDatabase := postgres.MockPostgres{}
 
-	mockDatabase.On("UpdateTransaction", context.Background(), "TXN_123", transaction).Return(transaction, nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(&models.Transaction{TransactionID: "TXN_123", Version: 2}, nil)
+	mockDatabase.On("UpdateTransaction", mock.Anything, "TXN_123", transaction, mock.Anything).Return(transaction, nil)
+	mockDatabase.On("CloseRefundRequest", mock.Anything, "TXN_123", "stripe", mock.Anything).Return(nil, nil)
 
 	eventHandler := stripeEvents{
 		event:    stripeEvent,
@@ -138,3 +203,157 @@ func TestProcessEventChargeRefundedEvent(t *testing.T) {
 	err = eventHandler.ProcessEvent(context.Background())
 	c.NoError(err)
 }
+
+func TestProcessEventChargeRefundedEventRefundRequested(t *testing.T) {
+	c := require.New(t)
+
+	rawData, err := json.Marshal(&stripe.Charge{ID: "ch_123", Metadata: map[string]string{"transaction_id": "TXN_123"}})
+	c.NoError(err)
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusSucceeded,
+		Type:          models.TransactionTypeRefund,
+		Version:       3,
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(&models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusRefundRequested, Version: 3}, nil)
+	mockDatabase.On("UpdateTransaction", mock.Anything, "TXN_123", transaction, mock.Anything).Return(transaction, nil)
+	mockDatabase.On("CloseRefundRequest", mock.Anything, "TXN_123", "stripe", mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionCloseRefundRequest &&
+			entry.ResourceType == models.AuditResourceRefundRequest &&
+			entry.Details["event_id"] == "evt_123"
+	})).Return(&models.RefundRequest{RefundRequestID: "RFR_123", Status: models.RefundRequestStatusClosed}, nil)
+
+	eventHandler := stripeEvents{
+		event:    stripe.Event{ID: "evt_123", Type: stripe.EventTypeChargeRefunded, Data: &stripe.EventData{Raw: rawData}},
+		database: &mockDatabase,
+	}
+
+	err = eventHandler.ProcessEvent(context.Background())
+	c.NoError(err, "the refund is applied rather than ignored")
+
+	mockDatabase.AssertExpectations(t)
+	mockDatabase.AssertNotCalled(t, "ReleaseUsage", mock.Anything, mock.Anything)
+}
+
+func TestProcessEventIgnored(t *testing.T) {
+	held := map[string]*models.Transaction{
+		"refund requested": {TransactionID: "TXN_123", Status: models.TransactionStatusRefundRequested, Version: 3},
+		"under review":     {TransactionID: "TXN_123", Status: models.TransactionStatusReview, Version: 1},
+		"archived":         {TransactionID: "TXN_123", Status: models.TransactionStatusPending, Version: 2, Archived: true},
+	}
+
+	for name, current := range held {
+		t.Run(name, func(t *testing.T) {
+			c := require.New(t)
+
+			rawData, err := json.Marshal(&stripe.PaymentIntent{ID: "pi_123", Metadata: map[string]string{"transaction_id": "TXN_123"}})
+			c.NoError(err)
+
+			mockDatabase := postgres.MockPostgres{}
+			mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(current, nil)
+
+			eventHandler := stripeEvents{
+				event:    stripe.Event{ID: "evt_123", Type: stripe.EventTypePaymentIntentPaymentFailed, Data: &stripe.EventData{Raw: rawData}},
+				database: &mockDatabase,
+			}
+
+			err = eventHandler.ProcessEvent(context.Background())
+			c.NoError(err, "the event is acknowledged")
+
+			mockDatabase.AssertNotCalled(t, "UpdateTransaction", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestProcessEventPayoutPaidEvent(t *testing.T) {
+	c := require.New(t)
+
+	payout := &stripe.Payout{
+		ID:          "po_123",
+		Status:      stripe.PayoutStatusPaid,
+		Amount:      1912,
+		Currency:    stripe.CurrencyUSD,
+		ArrivalDate: 1709337600,
+	}
+
+	rawData, err := json.Marshal(payout)
+	c.NoError(err)
+
+	stripeEvent := stripe.Event{
+		Type: stripe.EventTypePayoutPaid,
+		Data: &stripe.EventData{
+			Raw: rawData,
+		},
+	}
+
+	items := []*models.PayoutItem{{ProviderBalanceTransactionID: "txn_123", ProviderSourceID: "ch_123", Amount: 2000, Fee: 88, Net: 1912, Currency: "usd"}}
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripeprocessor.MockStripe{}
+
+	mockDatabase.On("UpsertPayout", mock.Anything, mock.MatchedBy(func(stored *models.Payout) bool {
+		return stored.ProviderPayoutID == "po_123" && stored.Status == models.PayoutStatusPaid && stored.Amount == 1912 &&
+			stored.ArrivalDate.Equal(time.Unix(1709337600, 0))
+	}), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionUpdatePayout && entry.ResourceType == models.AuditResourcePayout
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Payout).PayoutID = "PO_123"
+	}).Return(nil)
+	mockPaymentProcessor.On("ListPayoutItems", mock.Anything, "po_123").Return(items, nil)
+	mockDatabase.On("LinkPayoutTransactions", mock.Anything, "PO_123", items).Return(1, nil)
+
+	eventHandler := stripeEvents{
+		event:            stripeEvent,
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	err = eventHandler.ProcessEvent(context.Background())
+	c.NoError(err)
+
+	mockDatabase.AssertExpectations(t)
+	mockPaymentProcessor.AssertExpectations(t)
+}
+
+func TestProcessEventPayoutCreatedEvent(t *testing.T) {
+	c := require.New(t)
+
+	payout := &stripe.Payout{
+		ID:       "po_123",
+		Status:   stripe.PayoutStatusPending,
+		Amount:   1912,
+		Currency: stripe.CurrencyUSD,
+	}
+
+	rawData, err := json.Marshal(payout)
+	c.NoError(err)
+
+	stripeEvent := stripe.Event{
+		Type: stripe.EventTypePayoutCreated,
+		Data: &stripe.EventData{
+			Raw: rawData,
+		},
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripeprocessor.MockStripe{}
+
+	mockDatabase.On("UpsertPayout", mock.Anything, mock.MatchedBy(func(stored *models.Payout) bool {
+		return stored.ProviderPayoutID == "po_123" && stored.Status == models.PayoutStatusPending
+	}), mock.Anything).Return(nil)
+
+	eventHandler := stripeEvents{
+		event:            stripeEvent,
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	err = eventHandler.ProcessEvent(context.Background())
+	c.NoError(err)
+
+	mockPaymentProcessor.AssertNotCalled(t, "ListPayoutItems", mock.Anything, mock.Anything)
+}
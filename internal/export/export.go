@@ -0,0 +1,200 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// Format encoding of an export
+type Format string
+
+var (
+	// FormatCSV comma separated values with a header row
+	FormatCSV Format = "csv"
+	// FormatNDJSON one JSON object per line
+	FormatNDJSON Format = "ndjson"
+)
+
+// additionalFieldPrefix prefix of the columns holding the flattened additional fields
+const additionalFieldPrefix = "additional_fields."
+
+// ErrUnsupportedFormat error when the export format is unknown
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+var columns = []string{"transaction_id", "merchant_id", "status", "type", "amount", "currency", "provider", "description", "failure_reason", "platform_fee", "net_amount", "provider_fee", "fee_currency", "version", "created_at", "updated_at"}
+
+// ParseFormat returns the format given its name, where an empty name means CSV
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatNDJSON, "jsonl":
+		return FormatNDJSON, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, name)
+}
+
+// ContentType returns the media type of the format
+func (f Format) ContentType() string {
+	if f == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+
+	return "text/csv; charset=utf-8"
+}
+
+// Extension returns the file extension of the format
+func (f Format) Extension() string {
+	if f == FormatNDJSON {
+		return "ndjson"
+	}
+
+	return "csv"
+}
+
+// Writer encodes transactions one at a time, with amounts formatted in their currency and the additional
+// fields flattened into one column per key
+type Writer interface {
+	Write(*models.Transaction) error
+	// Flush writes any buffered data, which must be called once every transaction was written
+	Flush() error
+}
+
+// NewWriter constructor to encode transactions in the given format, where additionalFieldKeys are the keys
+// flattened into columns
+func NewWriter(format Format, w io.Writer, additionalFieldKeys []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w), keys: additionalFieldKeys}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w), keys: additionalFieldKeys}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	keys          []string
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(transaction *models.Transaction) error {
+	err := c.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	values := row(transaction)
+
+	record := make([]string, 0, len(columns)+len(c.keys))
+
+	for _, column := range columns {
+		record = append(record, fmt.Sprint(values[column]))
+	}
+
+	for _, key := range c.keys {
+		record = append(record, formatValue(transaction.AdditionalFields[key]))
+	}
+
+	return c.w.Write(record)
+}
+
+// Flush writes the header when no transaction was exported, so the file still describes its columns
+func (c *csvWriter) Flush() error {
+	err := c.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+
+	c.headerWritten = true
+
+	header := append([]string{}, columns...)
+
+	for _, key := range c.keys {
+		header = append(header, additionalFieldPrefix+key)
+	}
+
+	return c.w.Write(header)
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+	keys    []string
+}
+
+func (n *ndjsonWriter) Write(transaction *models.Transaction) error {
+	values := row(transaction)
+
+	for _, key := range n.keys {
+		if value, ok := transaction.AdditionalFields[key]; ok {
+			values[additionalFieldPrefix+key] = value
+		}
+	}
+
+	return n.encoder.Encode(values)
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return nil
+}
+
+func row(transaction *models.Transaction) map[string]interface{} {
+	return map[string]interface{}{
+		"transaction_id": transaction.TransactionID,
+		"merchant_id":    transaction.MerchantID,
+		"status":         transaction.Status,
+		"type":           transaction.Type,
+		"amount":         models.FormatAmount(transaction.Amount, transaction.Currency),
+		"currency":       transaction.Currency,
+		"provider":       transaction.Provider,
+		"description":    transaction.Description,
+		"failure_reason": transaction.FailureReason,
+		"platform_fee":   models.FormatAmount(transaction.PlatformFee, transaction.Currency),
+		"net_amount":     models.FormatAmount(transaction.NetAmount, transaction.Currency),
+		"provider_fee":   models.FormatAmount(transaction.ProviderFee, transaction.FeeCurrency),
+		"fee_currency":   transaction.FeeCurrency,
+		"version":        transaction.Version,
+		"created_at":     transaction.CreatedAt.Format(time.RFC3339),
+		"updated_at":     transaction.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// formatValue renders an additional field as a CSV cell, encoding nested values as JSON
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(encoded)
+}
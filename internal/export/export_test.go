@@ -0,0 +1,100 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+var exportedTransaction = &models.Transaction{
+	TransactionID: "TXN_123",
+	MerchantID:    "MCH_123",
+	Status:        models.TransactionStatusSucceeded,
+	Description:   "Order #42, express",
+	Provider:      models.PaymentProviderStripe,
+	Amount:        2050,
+	Currency:      "usd",
+	Type:          models.TransactionTypeCharge,
+	AdditionalFields: map[string]interface{}{
+		"charge_id":         "ch_123",
+		"payment_intent_id": "pi_123",
+	},
+	ProviderFee: 89,
+	PlatformFee: 50,
+	NetAmount:   2000,
+	FeeCurrency: "usd",
+	Version:     2,
+	CreatedAt:   time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	UpdatedAt:   time.Date(2024, 3, 1, 10, 5, 0, 0, time.UTC),
+}
+
+func TestCSVWriter(t *testing.T) {
+	c := require.New(t)
+
+	var buf bytes.Buffer
+
+	writer, err := NewWriter(FormatCSV, &buf, []string{"charge_id", "refund_id"})
+	c.NoError(err)
+
+	c.NoError(writer.Write(exportedTransaction))
+	c.NoError(writer.Flush())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Len(lines, 2)
+	c.Equal("transaction_id,merchant_id,status,type,amount,currency,provider,description,failure_reason,platform_fee,net_amount,provider_fee,fee_currency,version,created_at,updated_at,additional_fields.charge_id,additional_fields.refund_id", lines[0])
+	c.Equal(`TXN_123,MCH_123,succeeded,charge,20.50,usd,stripe,"Order #42, express",,0.50,20.00,0.89,usd,2,2024-03-01T10:00:00Z,2024-03-01T10:05:00Z,ch_123,`, lines[1])
+}
+
+func TestCSVWriterEmpty(t *testing.T) {
+	c := require.New(t)
+
+	var buf bytes.Buffer
+
+	writer, err := NewWriter(FormatCSV, &buf, nil)
+	c.NoError(err)
+
+	c.NoError(writer.Flush())
+	c.True(strings.HasPrefix(buf.String(), "transaction_id,"))
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	c := require.New(t)
+
+	var buf bytes.Buffer
+
+	writer, err := NewWriter(FormatNDJSON, &buf, []string{"charge_id", "payment_intent_id"})
+	c.NoError(err)
+
+	c.NoError(writer.Write(exportedTransaction))
+	c.NoError(writer.Write(exportedTransaction))
+	c.NoError(writer.Flush())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Len(lines, 2)
+
+	var decoded map[string]interface{}
+
+	c.NoError(json.Unmarshal([]byte(lines[0]), &decoded))
+	c.Equal("20.50", decoded["amount"])
+	c.Equal("pi_123", decoded["additional_fields.payment_intent_id"])
+}
+
+func TestParseFormat(t *testing.T) {
+	c := require.New(t)
+
+	format, err := ParseFormat("")
+	c.NoError(err)
+	c.Equal(FormatCSV, format)
+
+	format, err = ParseFormat("jsonl")
+	c.NoError(err)
+	c.Equal(FormatNDJSON, format)
+
+	_, err = ParseFormat("xlsx")
+	c.ErrorIs(err, ErrUnsupportedFormat)
+}
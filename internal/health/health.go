@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+)
+
+const (
+	// StatusOK status reported when the service or one of its dependencies is healthy
+	StatusOK = "ok"
+	// StatusUnavailable status reported when the service cannot take traffic
+	StatusUnavailable = "unavailable"
+)
+
+// Check reports whether a dependency is ready to be used
+type Check func(ctx context.Context) error
+
+// Report response body of the health endpoints, where the details of failed checks are only logged
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Checker serves the liveness and readiness endpoints of a service
+type Checker interface {
+	AddCheck(name string, check Check)
+	Drain()
+	HandleLiveness() http.HandlerFunc
+	HandleReadiness() http.HandlerFunc
+}
+
+type checker struct {
+	timeout  time.Duration
+	draining atomic.Bool
+	mu       sync.RWMutex
+	checks   map[string]Check
+}
+
+// NewChecker constructor of the health endpoints, where each readiness check is given at most the timeout to complete
+func NewChecker(timeout time.Duration) Checker {
+	return &checker{
+		timeout: timeout,
+		checks:  map[string]Check{},
+	}
+}
+
+// AddCheck registers a dependency verified on every readiness probe
+func (c *checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+}
+
+// Drain marks the service as not ready so load balancers stop routing new requests to it
+func (c *checker) Drain() {
+	c.draining.Store(true)
+}
+
+// HandleLiveness reports that the process is up and able to serve requests
+func (c *checker) HandleLiveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		api.WriteJSONResponse(w, http.StatusOK, Report{Status: StatusOK})
+	}
+}
+
+// HandleReadiness runs every registered check and reports whether the service can take traffic
+func (c *checker) HandleReadiness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.draining.Load() {
+			api.WriteJSONResponse(w, http.StatusServiceUnavailable, Report{
+				Status: StatusUnavailable,
+				Checks: map[string]string{"shutdown": StatusUnavailable},
+			})
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+		defer cancel()
+
+		report := c.run(ctx)
+
+		statusCode := http.StatusOK
+		if report.Status != StatusOK {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		api.WriteJSONResponse(w, statusCode, report)
+	}
+}
+
+func (c *checker) run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]string, len(checks)),
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			status := StatusOK
+
+			err := check(ctx)
+			if err != nil {
+				slog.WarnContext(ctx, "readiness check failed", slog.String("check", name), slog.Any("error", err))
+				status = StatusUnavailable
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[name] = status
+			if err != nil {
+				report.Status = StatusUnavailable
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return report
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serve(c *require.Assertions, handler http.HandlerFunc) (int, Report) {
+	recorder := httptest.NewRecorder()
+
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report Report
+
+	err := json.NewDecoder(recorder.Body).Decode(&report)
+	c.NoError(err)
+
+	return recorder.Code, report
+}
+
+func TestHandleLiveness(t *testing.T) {
+	c := require.New(t)
+
+	checker := NewChecker(time.Second)
+	checker.AddCheck("database", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	statusCode, report := serve(c, checker.HandleLiveness())
+	c.Equal(http.StatusOK, statusCode)
+	c.Equal(StatusOK, report.Status)
+}
+
+func TestHandleReadiness(t *testing.T) {
+	c := require.New(t)
+
+	checker := NewChecker(time.Second)
+	checker.AddCheck("database", func(ctx context.Context) error {
+		return nil
+	})
+	checker.AddCheck("stripe", func(ctx context.Context) error {
+		return nil
+	})
+
+	statusCode, report := serve(c, checker.HandleReadiness())
+	c.Equal(http.StatusOK, statusCode)
+	c.Equal(Report{Status: StatusOK, Checks: map[string]string{"database": StatusOK, "stripe": StatusOK}}, report)
+}
+
+func TestHandleReadinessFailedCheck(t *testing.T) {
+	c := require.New(t)
+
+	checker := NewChecker(10 * time.Millisecond)
+	checker.AddCheck("database", func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+	checker.AddCheck("stripe", func(ctx context.Context) error {
+		return nil
+	})
+
+	statusCode, report := serve(c, checker.HandleReadiness())
+	c.Equal(http.StatusServiceUnavailable, statusCode)
+	c.Equal(Report{Status: StatusUnavailable, Checks: map[string]string{"database": StatusUnavailable, "stripe": StatusOK}}, report)
+}
+
+func TestHandleReadinessDraining(t *testing.T) {
+	c := require.New(t)
+
+	checker := NewChecker(time.Second)
+	checker.Drain()
+
+	statusCode, report := serve(c, checker.HandleReadiness())
+	c.Equal(http.StatusServiceUnavailable, statusCode)
+	c.Equal(StatusUnavailable, report.Status)
+}
@@ -0,0 +1,68 @@
+package limits
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// Limits looks up the caps on the payments of merchants. The zero value caps nothing
+type Limits struct {
+	cfg config.Limits
+}
+
+// New constructor for the limits configured for the platform
+func New(cfg config.Limits) Limits {
+	return Limits{
+		cfg: cfg,
+	}
+}
+
+// For returns the limits of the merchant in the currency. The merchant plan takes precedence over the platform one,
+// and a currency limit over the plan default
+func (l Limits) For(merchantID, currency string) *models.MerchantLimits {
+	limit := planLimit(l.plan(merchantID), currency)
+
+	return &models.MerchantLimits{
+		MaxCharge:               limit.MaxCharge,
+		DailyVolume:             limit.DailyVolume,
+		MonthlyVolume:           limit.MonthlyVolume,
+		MaxRefundRatio:          limit.MaxRefundRatio,
+		RefundApprovalThreshold: limit.RefundApprovalThreshold,
+	}
+}
+
+// Currencies returns the currencies the plan of the merchant sets limits for besides its default, in lowercase
+func (l Limits) Currencies(merchantID string) []string {
+	plan := l.plan(merchantID)
+
+	currencies := make([]string, 0, len(plan.Currencies))
+	for currency := range plan.Currencies {
+		currencies = append(currencies, strings.ToLower(currency))
+	}
+
+	sort.Strings(currencies)
+
+	return currencies
+}
+
+func (l Limits) plan(merchantID string) config.LimitPlan {
+	plan, ok := l.cfg.Merchants[merchantID]
+	if !ok {
+		return l.cfg.LimitPlan
+	}
+
+	return plan
+}
+
+func planLimit(plan config.LimitPlan, currency string) config.Limit {
+	for planCurrency, limit := range plan.Currencies {
+		if strings.EqualFold(planCurrency, currency) {
+			return limit
+		}
+	}
+
+	return plan.Default
+}
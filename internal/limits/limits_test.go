@@ -0,0 +1,32 @@
+package limits
+
+import (
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFor(t *testing.T) {
+	c := require.New(t)
+
+	limits := New(config.Limits{
+		LimitPlan: config.LimitPlan{
+			Default: config.Limit{MaxCharge: 1000000},
+			Currencies: map[string]config.Limit{
+				"JPY": {MaxCharge: 100000000},
+			},
+		},
+		Merchants: map[string]config.LimitPlan{
+			"MCH_NEW": {Default: config.Limit{MaxCharge: 50000, DailyVolume: 200000, MaxRefundRatio: 10}},
+		},
+	})
+
+	c.Equal(&models.MerchantLimits{MaxCharge: 1000000}, limits.For("MCH_123", "usd"))
+	c.Equal(&models.MerchantLimits{MaxCharge: 100000000}, limits.For("MCH_123", "jpy"))
+	c.Equal(&models.MerchantLimits{MaxCharge: 50000, DailyVolume: 200000, MaxRefundRatio: 10}, limits.For("MCH_NEW", "jpy"))
+	c.Equal([]string{"jpy"}, limits.Currencies("MCH_123"))
+	c.Empty(limits.Currencies("MCH_NEW"))
+	c.Equal(&models.MerchantLimits{}, Limits{}.For("MCH_123", "usd"))
+}
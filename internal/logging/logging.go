@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// RequestIDKey attribute key for the ID of the HTTP request being served
+	RequestIDKey = "request_id"
+	// TransactionIDKey attribute key for the transaction being processed
+	TransactionIDKey = "transaction_id"
+	// EventIDKey attribute key for the payment provider event being processed
+	EventIDKey = "event_id"
+	// OriginRequestIDKey attribute key for the API request that originated a provider event
+	OriginRequestIDKey = "origin_request_id"
+	// TraceIDKey attribute key for the trace of the span active when logging
+	TraceIDKey = "trace_id"
+	// SpanIDKey attribute key for the span active when logging
+	SpanIDKey = "span_id"
+)
+
+type contextKey struct{}
+
+// New creates a JSON logger that enriches records with the attributes stored in the context
+// and redacts sensitive values
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+
+	return slog.New(contextHandler{Handler: handler})
+}
+
+// ParseLevel parses a log level name, falling back to info when it is unknown
+func ParseLevel(name string) slog.Level {
+	var level slog.Level
+
+	err := level.UnmarshalText([]byte(strings.ToUpper(name)))
+	if err != nil {
+		return slog.LevelInfo
+	}
+
+	return level
+}
+
+// With returns a copy of the context carrying attributes added to every record logged with it
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing := attrsFromContext(ctx)
+
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+
+	for _, attr := range existing {
+		if !containsKey(attrs, attr.Key) {
+			merged = append(merged, attr)
+		}
+	}
+
+	merged = append(merged, attrs...)
+
+	return context.WithValue(ctx, contextKey{}, merged)
+}
+
+// WithRequestID returns a copy of the context carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return With(ctx, slog.String(RequestIDKey, requestID))
+}
+
+// WithTransactionID returns a copy of the context carrying the transaction ID
+func WithTransactionID(ctx context.Context, transactionID string) context.Context {
+	return With(ctx, slog.String(TransactionIDKey, transactionID))
+}
+
+// WithEventID returns a copy of the context carrying the provider event ID
+func WithEventID(ctx context.Context, eventID string) context.Context {
+	return With(ctx, slog.String(EventIDKey, eventID))
+}
+
+// RequestIDFromContext returns the request ID stored in the context, if any
+func RequestIDFromContext(ctx context.Context) string {
+	for _, attr := range attrsFromContext(ctx) {
+		if attr.Key == RequestIDKey {
+			return attr.Value.String()
+		}
+	}
+
+	return ""
+}
+
+func attrsFromContext(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+
+	return attrs
+}
+
+func containsKey(attrs []slog.Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contextHandler adds the attributes stored in the context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds context attributes to the record before delegating it
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(attrsFromContext(ctx)...)
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String(TraceIDKey, spanContext.TraceID().String()),
+			slog.String(SpanIDKey, spanContext.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps the context handler when deriving a logger with attributes
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the context handler when deriving a logger with a group
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
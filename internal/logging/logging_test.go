@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decode(c *require.Assertions, buffer *bytes.Buffer) map[string]interface{} {
+	var record map[string]interface{}
+
+	err := json.Unmarshal(buffer.Bytes(), &record)
+	c.NoError(err)
+
+	buffer.Reset()
+
+	return record
+}
+
+func TestLoggerContextAttributes(t *testing.T) {
+	c := require.New(t)
+
+	buffer := &bytes.Buffer{}
+	logger := New(buffer, slog.LevelInfo)
+
+	ctx := WithRequestID(context.Background(), "REQ_123")
+	ctx = WithTransactionID(ctx, "TXN_123")
+	ctx = WithEventID(ctx, "evt_123")
+	ctx = WithTransactionID(ctx, "TXN_456")
+
+	logger.InfoContext(ctx, "payment processed")
+
+	record := decode(c, buffer)
+	c.Equal("payment processed", record["msg"])
+	c.Equal("REQ_123", record[RequestIDKey])
+	c.Equal("TXN_456", record[TransactionIDKey])
+	c.Equal("evt_123", record[EventIDKey])
+	c.Equal("REQ_123", RequestIDFromContext(ctx))
+
+	logger.DebugContext(ctx, "hidden")
+	c.Zero(buffer.Len())
+}
+
+func TestLoggerTraceAttributes(t *testing.T) {
+	c := require.New(t)
+
+	buffer := &bytes.Buffer{}
+	logger := New(buffer, slog.LevelInfo)
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	c.NoError(err)
+
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	c.NoError(err)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	logger.InfoContext(ctx, "payment processed")
+
+	record := decode(c, buffer)
+	c.Equal("4bf92f3577b34da6a3ce929d0e0e4736", record[TraceIDKey])
+	c.Equal("00f067aa0ba902b7", record[SpanIDKey])
+
+	logger.Info("payment processed")
+
+	record = decode(c, buffer)
+	c.NotContains(record, TraceIDKey)
+}
+
+func TestLoggerRedaction(t *testing.T) {
+	c := require.New(t)
+
+	buffer := &bytes.Buffer{}
+	logger := New(buffer, slog.LevelInfo).With(slog.String("email", "jane@example.com"))
+
+	logger.Info("charge failed",
+		slog.String("payment_method", "pm_card_visa"),
+		slog.String("description", "Receipt sent to jane@example.com"),
+		slog.Any("error", errors.New("customer jane@example.com declined")),
+		slog.Any("additional_fields", map[string]interface{}{
+			"charge_id":      "ch_123",
+			"customer_email": "jane@example.com",
+		}),
+		slog.Group("customer", slog.String("ip_address", "10.0.0.1")),
+	)
+
+	record := decode(c, buffer)
+	c.Equal(RedactedValue, record["email"])
+	c.Equal(RedactedValue, record["payment_method"])
+	c.Equal("Receipt sent to [REDACTED]", record["description"])
+	c.Equal("customer [REDACTED] declined", record["error"])
+	c.Equal(map[string]interface{}{"charge_id": "ch_123", "customer_email": RedactedValue}, record["additional_fields"])
+	c.Equal(map[string]interface{}{"ip_address": RedactedValue}, record["customer"])
+	c.NotContains(buffer.String(), "jane@example.com")
+}
+
+func TestLoggerRedactionOfErrorMessages(t *testing.T) {
+	c := require.New(t)
+
+	buffer := &bytes.Buffer{}
+	logger := New(buffer, slog.LevelInfo)
+
+	logger.Error("payment provider call failed",
+		slog.Any("error", errors.New("No such PaymentMethod: 'pm_1OgwgvGVGHB8I6rcAbCdEf12'; card_declined")),
+		slog.String("message", "card card_1OgwgvGVGHB8I6rcXyZ created from tok_1OgwgvGVGHB8I6rc and src_1Ogwgv"),
+	)
+
+	record := decode(c, buffer)
+	c.Equal("No such PaymentMethod: '[REDACTED]'; card_declined", record["error"], "error codes are kept")
+	c.Equal("card [REDACTED] created from [REDACTED] and [REDACTED]", record["message"])
+}
+
+func TestParseLevel(t *testing.T) {
+	c := require.New(t)
+
+	c.Equal(slog.LevelDebug, ParseLevel("debug"))
+	c.Equal(slog.LevelWarn, ParseLevel("WARN"))
+	c.Equal(slog.LevelInfo, ParseLevel("unknown"))
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/oklog/ulid/v2"
+)
+
+// RequestIDHeader header used to receive and return the request ID
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9_\-]{1,64}$`)
+
+// Middleware assigns a request ID to every request, stores it in the request context and
+// writes an access log line once the response is sent
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID.MatchString(requestID) {
+				requestID = ulid.Make().String()
+			}
+
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ctx := WithRequestID(r.Context(), requestID)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			route := r.URL.Path
+			if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+				route = routeContext.RoutePattern()
+			}
+
+			level := slog.LevelInfo
+			if ww.Status() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			logger.LogAttrs(ctx, level, "request completed",
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.Int("status", ww.Status()),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+			)
+		})
+	}
+}
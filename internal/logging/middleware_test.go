@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	c := require.New(t)
+
+	buffer := &bytes.Buffer{}
+	logger := New(buffer, slog.LevelInfo)
+
+	var requestID string
+
+	router := chi.NewRouter()
+	router.Use(Middleware(logger))
+	router.Get("/payments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		requestID = RequestIDFromContext(r.Context())
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil))
+
+	c.NotEmpty(requestID)
+	c.Equal(requestID, recorder.Result().Header.Get(RequestIDHeader))
+
+	record := decode(c, buffer)
+	c.Equal("request completed", record["msg"])
+	c.Equal(requestID, record[RequestIDKey])
+	c.Equal("/payments/{id}", record["route"])
+	c.Equal(float64(http.StatusNotFound), record["status"])
+
+	req := httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil)
+	req.Header.Set(RequestIDHeader, "upstream-123")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	c.Equal("upstream-123", requestID)
+
+	req.Header.Set(RequestIDHeader, "invalid id\n")
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	c.NotEqual("invalid id\n", requestID)
+}
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replacement for sensitive values
+const RedactedValue = "[REDACTED]"
+
+var sensitiveKeys = map[string]bool{
+	"payment_method": true,
+	"email":          true,
+	"customer_email": true,
+	"customer":       true,
+	"card":           true,
+	"card_number":    true,
+	"ip_address":     true,
+	"billing":        true,
+	"address":        true,
+	"phone":          true,
+	"authorization":  true,
+	"api_key":        true,
+	"key":            true,
+	"secret":         true,
+	"password":       true,
+	"token":          true,
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// paymentMethodPattern payment method, token and card identifiers, e.g. in provider error messages. Card IDs need
+	// their random suffix, so error codes such as card_declined are kept
+	paymentMethodPattern = regexp.MustCompile(`\b(?:(?:pm|tok|src)_[A-Za-z0-9_]+|card_[A-Za-z0-9]{14,})\b`)
+)
+
+// IsSensitiveKey reports whether values stored under the key must never be logged
+func IsSensitiveKey(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// redactAttr hides values of sensitive attributes and masks email addresses and payment method identifiers found in
+// free text
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if IsSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, RedactedValue)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redactString(attr.Value.String()))
+	case slog.KindAny:
+		return slog.Any(attr.Key, redactValue(attr.Value.Any()))
+	}
+
+	return attr
+}
+
+func redactString(value string) string {
+	value = emailPattern.ReplaceAllString(value, RedactedValue)
+
+	return paymentMethodPattern.ReplaceAllString(value, RedactedValue)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+
+		for key, item := range v {
+			if IsSensitiveKey(key) {
+				redacted[key] = RedactedValue
+				continue
+			}
+
+			redacted[key] = redactValue(item)
+		}
+
+		return redacted
+	case map[string]string:
+		redacted := make(map[string]string, len(v))
+
+		for key, item := range v {
+			if IsSensitiveKey(key) {
+				redacted[key] = RedactedValue
+				continue
+			}
+
+			redacted[key] = redactString(item)
+		}
+
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+
+		for i, item := range v {
+			redacted[i] = redactValue(item)
+		}
+
+		return redacted
+	case string:
+		return redactString(v)
+	case error:
+		return redactString(v.Error())
+	case fmt.Stringer:
+		return redactString(v.String())
+	}
+
+	return value
+}
@@ -0,0 +1,666 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// instrumentedDatabase embeds the wrapped database so operations without explicit
+// instrumentation are still forwarded
+type instrumentedDatabase struct {
+	database.Database
+}
+
+// NewDatabase decorates a database to record the latency of its operations and count written transactions
+func NewDatabase(next database.Database) database.Database {
+	return instrumentedDatabase{
+		Database: next,
+	}
+}
+
+// InsertTransaction records the latency of the wrapped call and counts the inserted transaction and its risk decision
+func (i instrumentedDatabase) InsertTransaction(ctx context.Context, transaction *models.Transaction, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.InsertTransaction(ctx, transaction, entry)
+
+	observeQuery("insert_transaction", start, err)
+
+	if err == nil {
+		ObserveTransaction(transaction)
+		ObserveRiskDecision(transaction.RiskDecision)
+	}
+
+	return err
+}
+
+// GetTransaction records the latency of the wrapped call
+func (i instrumentedDatabase) GetTransaction(ctx context.Context, transactionID string) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.Database.GetTransaction(ctx, transactionID)
+
+	observeQuery("get_transaction", start, err)
+
+	return transaction, err
+}
+
+// UpdateTransaction records the latency of the wrapped call and counts the updated transaction
+func (i instrumentedDatabase) UpdateTransaction(ctx context.Context, transactionID string, updatedTransaction *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.Database.UpdateTransaction(ctx, transactionID, updatedTransaction, entry)
+
+	observeQuery("update_transaction", start, err)
+
+	if err == nil {
+		ObserveTransaction(transaction)
+	}
+
+	return transaction, err
+}
+
+// ListTransactions records the latency of the wrapped call
+func (i instrumentedDatabase) ListTransactions(ctx context.Context, filter *models.TransactionFilter) ([]*models.Transaction, error) {
+	start := time.Now()
+
+	transactions, err := i.Database.ListTransactions(ctx, filter)
+
+	observeQuery("list_transactions", start, err)
+
+	return transactions, err
+}
+
+// StreamTransactions records the duration of the wrapped call, which includes the time spent by fn
+func (i instrumentedDatabase) StreamTransactions(ctx context.Context, filter *models.TransactionFilter, fn func(*models.Transaction) error) error {
+	start := time.Now()
+
+	err := i.Database.StreamTransactions(ctx, filter, fn)
+
+	observeQuery("stream_transactions", start, err)
+
+	return err
+}
+
+// ListAdditionalFieldKeys records the latency of the wrapped call
+func (i instrumentedDatabase) ListAdditionalFieldKeys(ctx context.Context, filter *models.TransactionFilter) ([]string, error) {
+	start := time.Now()
+
+	keys, err := i.Database.ListAdditionalFieldKeys(ctx, filter)
+
+	observeQuery("list_additional_field_keys", start, err)
+
+	return keys, err
+}
+
+// ForceTransactionStatus records the latency of the wrapped call and counts the updated transaction
+func (i instrumentedDatabase) ForceTransactionStatus(ctx context.Context, transactionID string, status models.TransactionStatus, entry *models.AuditEntry) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.Database.ForceTransactionStatus(ctx, transactionID, status, entry)
+
+	observeQuery("force_transaction_status", start, err)
+
+	if err == nil {
+		ObserveTransaction(transaction)
+	}
+
+	return transaction, err
+}
+
+// ReencryptTransactions records the latency of the wrapped call
+func (i instrumentedDatabase) ReencryptTransactions(ctx context.Context, limit int) (int, error) {
+	start := time.Now()
+
+	reencrypted, err := i.Database.ReencryptTransactions(ctx, limit)
+
+	observeQuery("reencrypt_transactions", start, err)
+
+	return reencrypted, err
+}
+
+// InsertMerchant records the latency of the wrapped call
+func (i instrumentedDatabase) InsertMerchant(ctx context.Context, merchant *models.Merchant, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.InsertMerchant(ctx, merchant, entry)
+
+	observeQuery("insert_merchant", start, err)
+
+	return err
+}
+
+// InsertAPIKey records the latency of the wrapped call
+func (i instrumentedDatabase) InsertAPIKey(ctx context.Context, key *models.APIKey) error {
+	start := time.Now()
+
+	err := i.Database.InsertAPIKey(ctx, key)
+
+	observeQuery("insert_api_key", start, err)
+
+	return err
+}
+
+// GetAPIKey records the latency of the wrapped call
+func (i instrumentedDatabase) GetAPIKey(ctx context.Context, keyID string) (*models.APIKey, error) {
+	start := time.Now()
+
+	key, err := i.Database.GetAPIKey(ctx, keyID)
+
+	observeQuery("get_api_key", start, err)
+
+	return key, err
+}
+
+// GetAPIKeyByHash records the latency of the wrapped call
+func (i instrumentedDatabase) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	start := time.Now()
+
+	key, err := i.Database.GetAPIKeyByHash(ctx, hash)
+
+	observeQuery("get_api_key_by_hash", start, err)
+
+	return key, err
+}
+
+// RotateAPIKey records the latency of the wrapped call
+func (i instrumentedDatabase) RotateAPIKey(ctx context.Context, keyID string, expiresAt time.Time, replacement *models.APIKey, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.RotateAPIKey(ctx, keyID, expiresAt, replacement, entry)
+
+	observeQuery("rotate_api_key", start, err)
+
+	return err
+}
+
+// InsertWebhookEvent records the latency of the wrapped call
+func (i instrumentedDatabase) InsertWebhookEvent(ctx context.Context, event *models.WebhookEvent) error {
+	start := time.Now()
+
+	err := i.Database.InsertWebhookEvent(ctx, event)
+
+	observeQuery("insert_webhook_event", start, err)
+
+	return err
+}
+
+// GetWebhookEvent records the latency of the wrapped call
+func (i instrumentedDatabase) GetWebhookEvent(ctx context.Context, eventID string) (*models.WebhookEvent, error) {
+	start := time.Now()
+
+	event, err := i.Database.GetWebhookEvent(ctx, eventID)
+
+	observeQuery("get_webhook_event", start, err)
+
+	return event, err
+}
+
+// RecordWebhookEventOutcome records the latency of the wrapped call
+func (i instrumentedDatabase) RecordWebhookEventOutcome(ctx context.Context, eventID string, processingErr error) error {
+	start := time.Now()
+
+	err := i.Database.RecordWebhookEventOutcome(ctx, eventID, processingErr)
+
+	observeQuery("record_webhook_event_outcome", start, err)
+
+	return err
+}
+
+// InsertConnectedAccount records the latency of the wrapped call
+func (i instrumentedDatabase) InsertConnectedAccount(ctx context.Context, account *models.ConnectedAccount, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.InsertConnectedAccount(ctx, account, entry)
+
+	observeQuery("insert_connected_account", start, err)
+
+	return err
+}
+
+// GetConnectedAccount records the latency of the wrapped call
+func (i instrumentedDatabase) GetConnectedAccount(ctx context.Context, accountID string) (*models.ConnectedAccount, error) {
+	start := time.Now()
+
+	account, err := i.Database.GetConnectedAccount(ctx, accountID)
+
+	observeQuery("get_connected_account", start, err)
+
+	return account, err
+}
+
+// ListConnectedAccounts records the latency of the wrapped call
+func (i instrumentedDatabase) ListConnectedAccounts(ctx context.Context, merchantID string) ([]*models.ConnectedAccount, error) {
+	start := time.Now()
+
+	accounts, err := i.Database.ListConnectedAccounts(ctx, merchantID)
+
+	observeQuery("list_connected_accounts", start, err)
+
+	return accounts, err
+}
+
+// ListTransfers records the latency of the wrapped call
+func (i instrumentedDatabase) ListTransfers(ctx context.Context, transactionID string) ([]*models.Transfer, error) {
+	start := time.Now()
+
+	transfers, err := i.Database.ListTransfers(ctx, transactionID)
+
+	observeQuery("list_transfers", start, err)
+
+	return transfers, err
+}
+
+// UpsertPayout records the latency of the wrapped call
+func (i instrumentedDatabase) UpsertPayout(ctx context.Context, payout *models.Payout, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.UpsertPayout(ctx, payout, entry)
+
+	observeQuery("upsert_payout", start, err)
+
+	return err
+}
+
+// GetPayout records the latency of the wrapped call
+func (i instrumentedDatabase) GetPayout(ctx context.Context, payoutID string) (*models.Payout, error) {
+	start := time.Now()
+
+	payout, err := i.Database.GetPayout(ctx, payoutID)
+
+	observeQuery("get_payout", start, err)
+
+	return payout, err
+}
+
+// ListPayouts records the latency of the wrapped call
+func (i instrumentedDatabase) ListPayouts(ctx context.Context, filter *models.PayoutFilter) ([]*models.Payout, error) {
+	start := time.Now()
+
+	payouts, err := i.Database.ListPayouts(ctx, filter)
+
+	observeQuery("list_payouts", start, err)
+
+	return payouts, err
+}
+
+// LinkPayoutTransactions records the latency of the wrapped call
+func (i instrumentedDatabase) LinkPayoutTransactions(ctx context.Context, payoutID string, items []*models.PayoutItem) (int, error) {
+	start := time.Now()
+
+	linked, err := i.Database.LinkPayoutTransactions(ctx, payoutID, items)
+
+	observeQuery("link_payout_transactions", start, err)
+
+	return linked, err
+}
+
+// ListPayoutTransactions records the latency of the wrapped call
+func (i instrumentedDatabase) ListPayoutTransactions(ctx context.Context, payoutID string) ([]*models.Transaction, error) {
+	start := time.Now()
+
+	transactions, err := i.Database.ListPayoutTransactions(ctx, payoutID)
+
+	observeQuery("list_payout_transactions", start, err)
+
+	return transactions, err
+}
+
+// CountRiskDecisions records the latency of the wrapped call
+func (i instrumentedDatabase) CountRiskDecisions(ctx context.Context, merchantID string, field models.RiskField, value string, since time.Time) (int, error) {
+	start := time.Now()
+
+	count, err := i.Database.CountRiskDecisions(ctx, merchantID, field, value, since)
+
+	observeQuery("count_risk_decisions", start, err)
+
+	return count, err
+}
+
+// GetReview records the latency of the wrapped call
+func (i instrumentedDatabase) GetReview(ctx context.Context, reviewID string) (*models.Review, error) {
+	start := time.Now()
+
+	review, err := i.Database.GetReview(ctx, reviewID)
+
+	observeQuery("get_review", start, err)
+
+	return review, err
+}
+
+// ListReviews records the latency of the wrapped call
+func (i instrumentedDatabase) ListReviews(ctx context.Context, filter *models.ReviewFilter) ([]*models.Review, error) {
+	start := time.Now()
+
+	reviews, err := i.Database.ListReviews(ctx, filter)
+
+	observeQuery("list_reviews", start, err)
+
+	return reviews, err
+}
+
+// ClaimReview records the latency of the wrapped call
+func (i instrumentedDatabase) ClaimReview(ctx context.Context, reviewID, reviewer string, until time.Time) error {
+	start := time.Now()
+
+	err := i.Database.ClaimReview(ctx, reviewID, reviewer, until)
+
+	observeQuery("claim_review", start, err)
+
+	return err
+}
+
+// ReleaseReview records the latency of the wrapped call
+func (i instrumentedDatabase) ReleaseReview(ctx context.Context, reviewID, reviewer string) error {
+	start := time.Now()
+
+	err := i.Database.ReleaseReview(ctx, reviewID, reviewer)
+
+	observeQuery("release_review", start, err)
+
+	return err
+}
+
+// DecideReview records the latency of the wrapped call
+func (i instrumentedDatabase) DecideReview(ctx context.Context, review *models.Review, decided *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.Database.DecideReview(ctx, review, decided, entry)
+
+	observeQuery("decide_review", start, err)
+
+	return transaction, err
+}
+
+// InsertRefundRequest records the latency of the wrapped call
+func (i instrumentedDatabase) InsertRefundRequest(ctx context.Context, request *models.RefundRequest, expectedVersion int, entry *models.AuditEntry) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.Database.InsertRefundRequest(ctx, request, expectedVersion, entry)
+
+	observeQuery("insert_refund_request", start, err)
+
+	return transaction, err
+}
+
+// GetRefundRequest records the latency of the wrapped call
+func (i instrumentedDatabase) GetRefundRequest(ctx context.Context, refundRequestID string) (*models.RefundRequest, error) {
+	start := time.Now()
+
+	request, err := i.Database.GetRefundRequest(ctx, refundRequestID)
+
+	observeQuery("get_refund_request", start, err)
+
+	return request, err
+}
+
+// ListRefundRequests records the latency of the wrapped call
+func (i instrumentedDatabase) ListRefundRequests(ctx context.Context, filter *models.RefundRequestFilter) ([]*models.RefundRequest, error) {
+	start := time.Now()
+
+	requests, err := i.Database.ListRefundRequests(ctx, filter)
+
+	observeQuery("list_refund_requests", start, err)
+
+	return requests, err
+}
+
+// ClaimRefundRequest records the latency of the wrapped call
+func (i instrumentedDatabase) ClaimRefundRequest(ctx context.Context, refundRequestID, operator string, until time.Time) error {
+	start := time.Now()
+
+	err := i.Database.ClaimRefundRequest(ctx, refundRequestID, operator, until)
+
+	observeQuery("claim_refund_request", start, err)
+
+	return err
+}
+
+// ReleaseRefundRequest records the latency of the wrapped call
+func (i instrumentedDatabase) ReleaseRefundRequest(ctx context.Context, refundRequestID, operator string) error {
+	start := time.Now()
+
+	err := i.Database.ReleaseRefundRequest(ctx, refundRequestID, operator)
+
+	observeQuery("release_refund_request", start, err)
+
+	return err
+}
+
+// DecideRefundRequest records the latency of the wrapped call
+func (i instrumentedDatabase) DecideRefundRequest(ctx context.Context, request *models.RefundRequest, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.DecideRefundRequest(ctx, request, entry)
+
+	observeQuery("decide_refund_request", start, err)
+
+	return err
+}
+
+// CloseRefundRequest records the latency of the wrapped call
+func (i instrumentedDatabase) CloseRefundRequest(ctx context.Context, transactionID, closedBy string, entry *models.AuditEntry) (*models.RefundRequest, error) {
+	start := time.Now()
+
+	request, err := i.Database.CloseRefundRequest(ctx, transactionID, closedBy, entry)
+
+	observeQuery("close_refund_request", start, err)
+
+	return request, err
+}
+
+// InsertBlockListEntry records the latency of the wrapped call
+func (i instrumentedDatabase) InsertBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.InsertBlockListEntry(ctx, listEntry, entry)
+
+	observeQuery("insert_block_list_entry", start, err)
+
+	return err
+}
+
+// GetBlockListEntry records the latency of the wrapped call
+func (i instrumentedDatabase) GetBlockListEntry(ctx context.Context, entryID string) (*models.BlockListEntry, error) {
+	start := time.Now()
+
+	entry, err := i.Database.GetBlockListEntry(ctx, entryID)
+
+	observeQuery("get_block_list_entry", start, err)
+
+	return entry, err
+}
+
+// ListBlockListEntries records the latency of the wrapped call
+func (i instrumentedDatabase) ListBlockListEntries(ctx context.Context, filter *models.BlockListFilter) ([]*models.BlockListEntry, error) {
+	start := time.Now()
+
+	entries, err := i.Database.ListBlockListEntries(ctx, filter)
+
+	observeQuery("list_block_list_entries", start, err)
+
+	return entries, err
+}
+
+// UpdateBlockListEntry records the latency of the wrapped call
+func (i instrumentedDatabase) UpdateBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) (*models.BlockListEntry, error) {
+	start := time.Now()
+
+	updated, err := i.Database.UpdateBlockListEntry(ctx, listEntry, entry)
+
+	observeQuery("update_block_list_entry", start, err)
+
+	return updated, err
+}
+
+// DeleteBlockListEntry records the latency of the wrapped call
+func (i instrumentedDatabase) DeleteBlockListEntry(ctx context.Context, entryID string, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.DeleteBlockListEntry(ctx, entryID, entry)
+
+	observeQuery("delete_block_list_entry", start, err)
+
+	return err
+}
+
+// MatchBlockList records the latency of the wrapped call
+func (i instrumentedDatabase) MatchBlockList(ctx context.Context, query *models.BlockListQuery) ([]*models.BlockListEntry, error) {
+	start := time.Now()
+
+	entries, err := i.Database.MatchBlockList(ctx, query)
+
+	observeQuery("match_block_list", start, err)
+
+	return entries, err
+}
+
+// ReserveUsage records the latency of the wrapped call
+func (i instrumentedDatabase) ReserveUsage(ctx context.Context, reservation *models.UsageReservation, check func(*models.Usage) error) (*models.Usage, error) {
+	start := time.Now()
+
+	usage, err := i.Database.ReserveUsage(ctx, reservation, check)
+
+	observeQuery("reserve_usage", start, err)
+
+	return usage, err
+}
+
+// ReleaseUsage records the latency of the wrapped call
+func (i instrumentedDatabase) ReleaseUsage(ctx context.Context, reservation *models.UsageReservation) error {
+	start := time.Now()
+
+	err := i.Database.ReleaseUsage(ctx, reservation)
+
+	observeQuery("release_usage", start, err)
+
+	return err
+}
+
+// ListUsage records the latency of the wrapped call
+func (i instrumentedDatabase) ListUsage(ctx context.Context, merchantID string, at time.Time) ([]*models.Usage, error) {
+	start := time.Now()
+
+	usages, err := i.Database.ListUsage(ctx, merchantID, at)
+
+	observeQuery("list_usage", start, err)
+
+	return usages, err
+}
+
+// InsertAuditEntry records the latency of the wrapped call
+func (i instrumentedDatabase) InsertAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.InsertAuditEntry(ctx, entry)
+
+	observeQuery("insert_audit_entry", start, err)
+
+	return err
+}
+
+// ListAuditEntries records the latency of the wrapped call
+func (i instrumentedDatabase) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	start := time.Now()
+
+	entries, err := i.Database.ListAuditEntries(ctx, filter)
+
+	observeQuery("list_audit_entries", start, err)
+
+	return entries, err
+}
+
+// ExportCustomerData records the latency of the wrapped call
+func (i instrumentedDatabase) ExportCustomerData(ctx context.Context, customer string) (*models.CustomerData, error) {
+	start := time.Now()
+
+	data, err := i.Database.ExportCustomerData(ctx, customer)
+
+	observeQuery("export_customer_data", start, err)
+
+	return data, err
+}
+
+// EraseCustomerData records the latency of the wrapped call
+func (i instrumentedDatabase) EraseCustomerData(ctx context.Context, erasure *models.CustomerErasure, entry *models.AuditEntry) error {
+	start := time.Now()
+
+	err := i.Database.EraseCustomerData(ctx, erasure, entry)
+
+	observeQuery("erase_customer_data", start, err)
+
+	return err
+}
+
+// ArchiveTransactions records the latency of the wrapped call
+func (i instrumentedDatabase) ArchiveTransactions(ctx context.Context, before, refundableSince time.Time, limit int) (int, error) {
+	start := time.Now()
+
+	archived, err := i.Database.ArchiveTransactions(ctx, before, refundableSince, limit)
+
+	observeQuery("archive_transactions", start, err)
+
+	return archived, err
+}
+
+// ListArchivableTransactions records the latency of the wrapped call
+func (i instrumentedDatabase) ListArchivableTransactions(ctx context.Context, before, refundableSince time.Time, limit int) ([]*models.Transaction, error) {
+	start := time.Now()
+
+	transactions, err := i.Database.ListArchivableTransactions(ctx, before, refundableSince, limit)
+
+	observeQuery("list_archivable_transactions", start, err)
+
+	return transactions, err
+}
+
+// RemoveArchivedTransactions records the latency of the wrapped call
+func (i instrumentedDatabase) RemoveArchivedTransactions(ctx context.Context, file string, transactions []*models.Transaction) ([]string, error) {
+	start := time.Now()
+
+	removed, err := i.Database.RemoveArchivedTransactions(ctx, file, transactions)
+
+	observeQuery("remove_archived_transactions", start, err)
+
+	return removed, err
+}
+
+// GetArchiveFile records the latency of the wrapped call
+func (i instrumentedDatabase) GetArchiveFile(ctx context.Context, transactionID string) (string, error) {
+	start := time.Now()
+
+	file, err := i.Database.GetArchiveFile(ctx, transactionID)
+
+	observeQuery("get_archive_file", start, err)
+
+	return file, err
+}
+
+// LockArchiveFile records the latency of the wrapped call, including fn
+func (i instrumentedDatabase) LockArchiveFile(ctx context.Context, file string, fn func() error) error {
+	start := time.Now()
+
+	err := i.Database.LockArchiveFile(ctx, file, fn)
+
+	observeQuery("lock_archive_file", start, err)
+
+	return err
+}
+
+// PruneWebhookEventPayloads records the latency of the wrapped call
+func (i instrumentedDatabase) PruneWebhookEventPayloads(ctx context.Context, before time.Time) (int, error) {
+	start := time.Now()
+
+	pruned, err := i.Database.PruneWebhookEventPayloads(ctx, before)
+
+	observeQuery("prune_webhook_event_payloads", start, err)
+
+	return pruned, err
+}
+
+func observeQuery(operation string, start time.Time, err error) {
+	databaseQueryDuration.WithLabelValues(operation, outcome(err)).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// OutcomeSuccess label value for operations that completed successfully
+	OutcomeSuccess = "success"
+	// OutcomeError label value for operations that failed
+	OutcomeError = "error"
+)
+
+var (
+	transactionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "payment_transactions_total",
+		Help: "Transactions written to the platform by type, status, provider, currency and failure reason.",
+	}, []string{"type", "status", "provider", "currency", "failure_reason"})
+
+	refundsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "payment_refunds_total",
+		Help: "Refunds requested to the payment provider by outcome.",
+	}, []string{"provider", "outcome"})
+
+	webhookEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_events_total",
+		Help: "Payment provider events received by the webhook service by type and outcome.",
+	}, []string{"provider", "type", "outcome"})
+
+	riskDecisionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "risk_decisions_total",
+		Help: "Decisions taken by the risk rules before charging a payment by outcome.",
+	}, []string{"outcome"})
+
+	outboxEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_events_published_total",
+		Help: "Outbox events published by the relay by sink, type and outcome.",
+	}, []string{"sink", "type", "outcome"})
+
+	providerRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "payment_provider_request_duration_seconds",
+		Help:    "Latency of the requests made to the payment provider.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider", "operation", "outcome"})
+
+	databaseQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "database_query_duration_seconds",
+		Help:    "Latency of the database operations.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})
+)
+
+// Handler exposes the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveTransaction counts a transaction written to the platform
+func ObserveTransaction(transaction *models.Transaction) {
+	if transaction == nil || transaction.Status == "" {
+		return
+	}
+
+	transactionsTotal.WithLabelValues(
+		string(transaction.Type),
+		string(transaction.Status),
+		string(transaction.Provider),
+		strings.ToLower(transaction.Currency),
+		transaction.FailureReason,
+	).Inc()
+}
+
+// ObserveRiskDecision counts a decision taken by the risk rules
+func ObserveRiskDecision(decision *models.RiskDecision) {
+	if decision == nil {
+		return
+	}
+
+	riskDecisionsTotal.WithLabelValues(string(decision.Outcome)).Inc()
+}
+
+// ObserveWebhookEvent counts an event received from a payment provider
+func ObserveWebhookEvent(provider, eventType, outcome string) {
+	if eventType == "" {
+		eventType = "unknown"
+	}
+
+	webhookEventsTotal.WithLabelValues(provider, eventType, outcome).Inc()
+}
+
+// ObserveOutboxEvent counts an attempt of the relay to publish an outbox event
+func ObserveOutboxEvent(sink, eventType, outcome string) {
+	outboxEventsTotal.WithLabelValues(sink, eventType, outcome).Inc()
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+
+	return OutcomeSuccess
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedDatabaseInsertTransaction(t *testing.T) {
+	c := require.New(t)
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusFailure,
+		FailureReason: "card_declined",
+		Provider:      models.PaymentProviderStripe,
+		Currency:      "USD",
+		Type:          models.TransactionTypeCharge,
+	}
+
+	counter := transactionsTotal.WithLabelValues("charge", "failure", "stripe", "usd", "card_declined")
+	before := testutil.ToFloat64(counter)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("InsertTransaction", context.Background(), transaction, (*models.AuditEntry)(nil)).Return(nil)
+
+	database := NewDatabase(&mockDatabase)
+
+	err := database.InsertTransaction(context.Background(), transaction, nil)
+	c.NoError(err)
+	c.Equal(before+1, testutil.ToFloat64(counter))
+	c.Positive(testutil.CollectAndCount(databaseQueryDuration, "database_query_duration_seconds"))
+}
+
+func TestInstrumentedDatabaseInsertTransactionFailure(t *testing.T) {
+	c := require.New(t)
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_456",
+		Status:        models.TransactionStatusSucceeded,
+		Provider:      models.PaymentProviderMock,
+		Currency:      "eur",
+		Type:          models.TransactionTypeCharge,
+	}
+
+	counter := transactionsTotal.WithLabelValues("charge", "succeeded", "mock", "eur", "")
+	before := testutil.ToFloat64(counter)
+
+	customErr := errors.New("insert failed")
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("InsertTransaction", context.Background(), transaction, (*models.AuditEntry)(nil)).Return(customErr)
+
+	database := NewDatabase(&mockDatabase)
+
+	err := database.InsertTransaction(context.Background(), transaction, nil)
+	c.ErrorIs(err, customErr)
+	c.Equal(before, testutil.ToFloat64(counter))
+}
+
+func TestInstrumentedPaymentProcessorRefundTransaction(t *testing.T) {
+	c := require.New(t)
+
+	metadata := map[string]interface{}{"charge_id": "ch_123"}
+	customErr := errors.New("refund failed")
+
+	mockPaymentProcessor := stripe.MockStripe{}
+	mockPaymentProcessor.On("RefundTransaction", context.Background(), metadata).Return(nil, customErr)
+
+	counter := refundsTotal.WithLabelValues("stripe", OutcomeError)
+	before := testutil.ToFloat64(counter)
+
+	paymentProcessor := NewPaymentProcessor(&mockPaymentProcessor, models.PaymentProviderStripe)
+
+	_, err := paymentProcessor.RefundTransaction(context.Background(), metadata)
+	c.ErrorIs(err, customErr)
+	c.Equal(before+1, testutil.ToFloat64(counter))
+}
+
+func TestObserveWebhookEvent(t *testing.T) {
+	c := require.New(t)
+
+	counter := webhookEventsTotal.WithLabelValues("stripe", "unknown", "verification_failed")
+	before := testutil.ToFloat64(counter)
+
+	ObserveWebhookEvent("stripe", "", "verification_failed")
+	c.Equal(before+1, testutil.ToFloat64(counter))
+}
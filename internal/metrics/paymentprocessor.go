@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
+)
+
+type instrumentedPaymentProcessor struct {
+	next     paymentprocessor.PaymentProcessor
+	provider string
+}
+
+// NewPaymentProcessor decorates a payment processor to record the latency of its calls and refund counts
+func NewPaymentProcessor(next paymentprocessor.PaymentProcessor, provider models.PaymentProvider) paymentprocessor.PaymentProcessor {
+	return instrumentedPaymentProcessor{
+		next:     next,
+		provider: string(provider),
+	}
+}
+
+// PerformTransaction records the latency of the wrapped call
+func (i instrumentedPaymentProcessor) PerformTransaction(ctx context.Context, input *models.TransactionInput) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.next.PerformTransaction(ctx, input)
+
+	i.observe("perform_transaction", start, err)
+
+	return transaction, err
+}
+
+// RefundTransaction records the latency of the wrapped call and counts the refund
+func (i instrumentedPaymentProcessor) RefundTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.next.RefundTransaction(ctx, metadata)
+
+	i.observe("refund_transaction", start, err)
+	refundsTotal.WithLabelValues(i.provider, outcome(err)).Inc()
+
+	return transaction, err
+}
+
+// QueryTransaction records the latency of the wrapped call
+func (i instrumentedPaymentProcessor) QueryTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.next.QueryTransaction(ctx, metadata)
+
+	i.observe("query_transaction", start, err)
+
+	return transaction, err
+}
+
+// CapturePayment records the latency of the wrapped call
+func (i instrumentedPaymentProcessor) CapturePayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.next.CapturePayment(ctx, metadata)
+
+	i.observe("capture_payment", start, err)
+
+	return transaction, err
+}
+
+// CancelPayment records the latency of the wrapped call
+func (i instrumentedPaymentProcessor) CancelPayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	start := time.Now()
+
+	transaction, err := i.next.CancelPayment(ctx, metadata)
+
+	i.observe("cancel_payment", start, err)
+
+	return transaction, err
+}
+
+// GetPaymentMethod records the latency of the wrapped call
+func (i instrumentedPaymentProcessor) GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethodDetails, error) {
+	start := time.Now()
+
+	details, err := i.next.GetPaymentMethod(ctx, paymentMethodID)
+
+	i.observe("get_payment_method", start, err)
+
+	return details, err
+}
+
+// ListPayoutItems records the latency of the wrapped call
+func (i instrumentedPaymentProcessor) ListPayoutItems(ctx context.Context, providerPayoutID string) ([]*models.PayoutItem, error) {
+	start := time.Now()
+
+	items, err := i.next.ListPayoutItems(ctx, providerPayoutID)
+
+	i.observe("list_payout_items", start, err)
+
+	return items, err
+}
+
+func (i instrumentedPaymentProcessor) observe(operation string, start time.Time, err error) {
+	providerRequestDuration.WithLabelValues(i.provider, operation, outcome(err)).Observe(time.Since(start).Seconds())
+}
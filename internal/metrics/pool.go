@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStatter interface implemented by databases backed by a pgx connection pool
+type PoolStatter interface {
+	Stat() *pgxpool.Stat
+}
+
+var (
+	poolAcquiredConnsDesc = prometheus.NewDesc("pgxpool_acquired_connections", "Connections currently acquired from the pool.", nil, nil)
+	poolIdleConnsDesc     = prometheus.NewDesc("pgxpool_idle_connections", "Idle connections in the pool.", nil, nil)
+	poolTotalConnsDesc    = prometheus.NewDesc("pgxpool_total_connections", "Total connections currently in the pool.", nil, nil)
+	poolMaxConnsDesc      = prometheus.NewDesc("pgxpool_max_connections", "Maximum size of the pool.", nil, nil)
+	poolAcquireCountDesc  = prometheus.NewDesc("pgxpool_acquire_total", "Successful acquires from the pool.", nil, nil)
+	poolAcquireTimeDesc   = prometheus.NewDesc("pgxpool_acquire_duration_seconds_total", "Total time spent acquiring connections from the pool.", nil, nil)
+	poolEmptyAcquireDesc  = prometheus.NewDesc("pgxpool_empty_acquire_total", "Acquires that had to wait for a connection because the pool was empty.", nil, nil)
+	poolCanceledDesc      = prometheus.NewDesc("pgxpool_canceled_acquire_total", "Acquires canceled by their context.", nil, nil)
+)
+
+type poolCollector struct {
+	statter PoolStatter
+}
+
+// RegisterPoolStats exposes the connection statistics of a pgx pool
+func RegisterPoolStats(statter PoolStatter) error {
+	return prometheus.Register(poolCollector{statter: statter})
+}
+
+// Describe sends the descriptors of the pool metrics
+func (p poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredConnsDesc
+	ch <- poolIdleConnsDesc
+	ch <- poolTotalConnsDesc
+	ch <- poolMaxConnsDesc
+	ch <- poolAcquireCountDesc
+	ch <- poolAcquireTimeDesc
+	ch <- poolEmptyAcquireDesc
+	ch <- poolCanceledDesc
+}
+
+// Collect reads the current pool statistics
+func (p poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := p.statter.Stat()
+	if stat == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConnsDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConnsDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConnsDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConnsDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireCountDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireTimeDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquireDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledDesc, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
@@ -0,0 +1,224 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// AuditAction type for the operations recorded in the audit trail
+type AuditAction string
+
+var (
+	// AuditActionCreatePayment action when a merchant charges a payment
+	AuditActionCreatePayment AuditAction = "transaction.create"
+	// AuditActionRefund action when a merchant or an operator refunds a transaction
+	AuditActionRefund AuditAction = "transaction.refund"
+	// AuditActionProcessEvent action when an event of the payment provider updates a transaction
+	AuditActionProcessEvent AuditAction = "transaction.process_event"
+	// AuditActionForceStatus action when an operator overrides the status of a transaction
+	AuditActionForceStatus AuditAction = "transaction.force_status"
+	// AuditActionReplayWebhookEvent action when an operator processes a stored webhook event again
+	AuditActionReplayWebhookEvent AuditAction = "webhook_event.replay"
+	// AuditActionApproveReview action when a reviewer approves the capture of a payment flagged for review
+	AuditActionApproveReview AuditAction = "review.approve"
+	// AuditActionRejectReview action when a reviewer rejects a payment flagged for review, or its review expires
+	AuditActionRejectReview AuditAction = "review.reject"
+	// AuditActionRequestRefund action when a refund over the approval threshold is held for approval
+	AuditActionRequestRefund AuditAction = "refund_request.create"
+	// AuditActionApproveRefundRequest action when an operator approves a held refund, issuing it
+	AuditActionApproveRefundRequest AuditAction = "refund_request.approve"
+	// AuditActionRejectRefundRequest action when an operator rejects a held refund
+	AuditActionRejectRefundRequest AuditAction = "refund_request.reject"
+	// AuditActionCloseRefundRequest action when the payment provider reports the refund of a held request as issued
+	AuditActionCloseRefundRequest AuditAction = "refund_request.close"
+	// AuditActionUpdatePayout action when an event of the payment provider stores or updates a payout
+	AuditActionUpdatePayout AuditAction = "payout.update"
+	// AuditActionEraseCustomerData action when an operator erases the personal data of a paying customer
+	AuditActionEraseCustomerData AuditAction = "customer.erase"
+	// AuditActionCreateMerchant action when an operator creates a merchant
+	AuditActionCreateMerchant AuditAction = "merchant.create"
+	// AuditActionRotateAPIKey action when a merchant replaces one of its API keys
+	AuditActionRotateAPIKey AuditAction = "api_key.rotate"
+	// AuditActionCreateConnectedAccount action when a marketplace merchant maps an account of one of its sellers
+	AuditActionCreateConnectedAccount AuditAction = "connected_account.create"
+	// AuditActionCreateBlockListEntry action when an operator adds a value to the block list
+	AuditActionCreateBlockListEntry AuditAction = "block_list_entry.create"
+	// AuditActionUpdateBlockListEntry action when an operator changes the reason or expiration of a block list entry
+	AuditActionUpdateBlockListEntry AuditAction = "block_list_entry.update"
+	// AuditActionDeleteBlockListEntry action when an operator removes a value from the block list
+	AuditActionDeleteBlockListEntry AuditAction = "block_list_entry.delete"
+)
+
+// AuditActorType type for the kind of actor performing an audited operation
+type AuditActorType string
+
+var (
+	// AuditActorAPIKey actor of the operations performed by merchants through the API, identified by the key ID
+	AuditActorAPIKey AuditActorType = "api_key"
+	// AuditActorOperator actor of the operations performed by operators of the platform
+	AuditActorOperator AuditActorType = "operator"
+	// AuditActorWebhook actor of the operations performed on behalf of the events of a payment provider
+	AuditActorWebhook AuditActorType = "webhook"
+	// AuditActorSystem actor of the operations performed by the platform itself, such as expiring reviews
+	AuditActorSystem AuditActorType = "system"
+)
+
+// IsValid checks if the actor type is one of the supported actor types
+func (t AuditActorType) IsValid() bool {
+	return t == AuditActorAPIKey || t == AuditActorOperator || t == AuditActorWebhook || t == AuditActorSystem
+}
+
+const (
+	// AuditResourceTransaction resource type of the entries about a transaction
+	AuditResourceTransaction = "transaction"
+	// AuditResourceWebhookEvent resource type of the entries about a stored webhook event
+	AuditResourceWebhookEvent = "webhook_event"
+	// AuditResourceReview resource type of the entries about the review of a payment
+	AuditResourceReview = "review"
+	// AuditResourceRefundRequest resource type of the entries about a refund held for approval
+	AuditResourceRefundRequest = "refund_request"
+	// AuditResourcePayout resource type of the entries about a payout
+	AuditResourcePayout = "payout"
+	// AuditResourceCustomer resource type of the entries about the data of a paying customer, identified by the ID of
+	// the erasure that pseudonymized it
+	AuditResourceCustomer = "customer"
+	// AuditResourceMerchant resource type of the entries about a merchant
+	AuditResourceMerchant = "merchant"
+	// AuditResourceAPIKey resource type of the entries about an API key
+	AuditResourceAPIKey = "api_key"
+	// AuditResourceConnectedAccount resource type of the entries about a connected account
+	AuditResourceConnectedAccount = "connected_account"
+	// AuditResourceBlockListEntry resource type of the entries about a block list entry
+	AuditResourceBlockListEntry = "block_list_entry"
+)
+
+// AuditEntry record of an operation performed by an operator, a merchant or a payment provider, along with why it was
+// performed. Entries are chained in the order they are appended, each one carrying the hash of the previous one
+type AuditEntry struct {
+	AuditID   string         `json:"audit_id"`
+	Sequence  int64          `json:"sequence"`
+	Actor     string         `json:"actor"`
+	ActorType AuditActorType `json:"actor_type"`
+	Action    AuditAction    `json:"action"`
+	// ResourceType and ResourceID identify what the operation was performed on
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   string                 `json:"resource_id"`
+	Reason       string                 `json:"reason,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	// RequestFingerprint digest of the API request that performed the operation, if any
+	RequestFingerprint string `json:"request_fingerprint,omitempty"`
+	// Before and After state of the resource around the operation
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	PreviousHash string          `json:"previous_hash,omitempty"`
+	Hash         string          `json:"hash,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// ComputeHash returns the hash of the entry chained to its previous hash. JSON values are hashed in their canonical
+// form, with sorted keys, so the hash doesn't depend on how they were stored
+func (a *AuditEntry) ComputeHash() (string, error) {
+	details, err := canonicalJSON(a.Details)
+	if err != nil {
+		return "", err
+	}
+
+	before, err := canonicalJSON(a.Before)
+	if err != nil {
+		return "", err
+	}
+
+	after, err := canonicalJSON(a.After)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal([]interface{}{
+		a.Sequence,
+		a.AuditID,
+		a.Actor,
+		a.ActorType,
+		a.Action,
+		a.ResourceType,
+		a.ResourceID,
+		a.Reason,
+		details,
+		a.RequestFingerprint,
+		before,
+		after,
+		a.CreatedAt.UTC().Format(time.RFC3339Nano),
+		a.PreviousHash,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(payload)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func canonicalJSON(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.RawMessage:
+		if len(v) == 0 {
+			return nil, nil
+		}
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return nil, nil
+		}
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var canonical interface{}
+
+	err = json.Unmarshal(raw, &canonical)
+	if err != nil {
+		return nil, err
+	}
+
+	return canonical, nil
+}
+
+// AuditSnapshot encodes the state of a resource to record it before or after an audited operation
+func AuditSnapshot(resource interface{}) json.RawMessage {
+	raw, err := json.Marshal(resource)
+	if err != nil {
+		return nil
+	}
+
+	return raw
+}
+
+// AuditFilter filter for querying the audit trail
+type AuditFilter struct {
+	Actor        string
+	ActorType    AuditActorType
+	ResourceType string
+	ResourceID   string
+	// From matches entries created at or after this moment
+	From *time.Time
+	// To matches entries created before this moment
+	To *time.Time
+	// AfterSequence matches entries appended after the one with this sequence
+	AfterSequence int64
+	// Limit maximum number of entries returned, in the order they were appended, where zero means no limit
+	Limit int
+}
+
+// AuditVerification outcome of checking the hash chain of the audit trail
+type AuditVerification struct {
+	Valid bool `json:"valid"`
+	// Entries number of entries checked
+	Entries int `json:"entries"`
+	// FirstInvalidSequence sequence of the first entry whose hash doesn't match its content or its previous entry
+	FirstInvalidSequence int64  `json:"first_invalid_sequence,omitempty"`
+	Reason               string `json:"reason,omitempty"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditEntryComputeHashSurvivesStorage(t *testing.T) {
+	c := require.New(t)
+
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 123456000, time.UTC)
+
+	written := &AuditEntry{
+		AuditID:      "AUD_123",
+		Sequence:     42,
+		Actor:        "KEY_123",
+		ActorType:    AuditActorAPIKey,
+		Action:       AuditActionRefund,
+		ResourceType: AuditResourceTransaction,
+		ResourceID:   "TXN_123",
+		Details:      map[string]interface{}{"amount": 2000, "transaction_id": "TXN_123"},
+		Before:       AuditSnapshot(&Transaction{TransactionID: "TXN_123", Status: TransactionStatusSucceeded}),
+		PreviousHash: "5d41402abc4b2a76",
+		CreatedAt:    createdAt,
+	}
+
+	// JSONB reorders keys and drops whitespace, and numbers come back as float64
+	read := *written
+	read.Details = map[string]interface{}{"transaction_id": "TXN_123", "amount": float64(2000)}
+	read.CreatedAt = createdAt.In(time.FixedZone("CST", -6*60*60))
+
+	var before map[string]interface{}
+	c.NoError(json.Unmarshal(written.Before, &before))
+
+	indented, err := json.MarshalIndent(before, "", "  ")
+	c.NoError(err)
+
+	read.Before = indented
+
+	writtenHash, err := written.ComputeHash()
+	c.NoError(err)
+
+	readHash, err := read.ComputeHash()
+	c.NoError(err)
+
+	c.Len(writtenHash, 64)
+	c.Equal(writtenHash, readHash)
+}
+
+func TestAuditEntryComputeHashDetectsChanges(t *testing.T) {
+	c := require.New(t)
+
+	entry := &AuditEntry{
+		Sequence:     1,
+		Actor:        "alice",
+		Action:       AuditActionForceStatus,
+		ResourceType: AuditResourceTransaction,
+		ResourceID:   "TXN_123",
+		Details:      map[string]interface{}{"status": "failure"},
+	}
+
+	hash, err := entry.ComputeHash()
+	c.NoError(err)
+
+	entry.Details["status"] = "succeeded"
+
+	changed, err := entry.ComputeHash()
+	c.NoError(err)
+	c.NotEqual(hash, changed)
+
+	entry.Details["status"] = "failure"
+	entry.PreviousHash = "5d41402abc4b2a76"
+
+	relinked, err := entry.ComputeHash()
+	c.NoError(err)
+	c.NotEqual(hash, relinked)
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// BlockListType type for the attribute of a payment a block list entry matches
+type BlockListType string
+
+var (
+	// BlockListTypePaymentMethod payment method ID sent to charge the payment
+	BlockListTypePaymentMethod BlockListType = "payment_method"
+	// BlockListTypeCardFingerprint fingerprint of the card, shared by every payment method created for it
+	BlockListTypeCardFingerprint BlockListType = "card_fingerprint"
+	// BlockListTypeEmail email of the paying customer
+	BlockListTypeEmail BlockListType = "email"
+	// BlockListTypeIPAddress address the customer paid from
+	BlockListTypeIPAddress BlockListType = "ip_address"
+	// BlockListTypeBIN range of the leading digits of the card number, which identify its issuer
+	BlockListTypeBIN BlockListType = "bin"
+)
+
+var (
+	// ErrInvalidBlockListType error when the type of a block list entry is unknown
+	ErrInvalidBlockListType = errors.New("invalid block list type")
+	// ErrMissingBlockListValue error when the value of a block list entry is missing
+	ErrMissingBlockListValue = errors.New("missing block list value")
+	// ErrInvalidBlockListValue error when the value of a block list entry doesn't match its type
+	ErrInvalidBlockListValue = errors.New("invalid block list value")
+)
+
+const (
+	minBINLength = 6
+	maxBINLength = 8
+)
+
+// IsValid reports whether the type is one of the known block list types
+func (t BlockListType) IsValid() bool {
+	return t == BlockListTypePaymentMethod || t == BlockListTypeCardFingerprint || t == BlockListTypeEmail ||
+		t == BlockListTypeIPAddress || t == BlockListTypeBIN
+}
+
+// IsCard reports whether matching the type requires the details of the card behind the payment method
+func (t BlockListType) IsCard() bool {
+	return t == BlockListTypeCardFingerprint || t == BlockListTypeBIN
+}
+
+// Normalize returns the value in the form stored in the block list, so matches ignore case and formatting. BIN
+// ranges are stored as "first-last", where a single BIN is a range of one
+func (t BlockListType) Normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", ErrMissingBlockListValue
+	}
+
+	switch t {
+	case BlockListTypePaymentMethod, BlockListTypeCardFingerprint:
+		return value, nil
+	case BlockListTypeEmail:
+		address, err := mail.ParseAddress(value)
+		if err != nil || address.Address != value {
+			return "", ErrInvalidBlockListValue
+		}
+
+		return strings.ToLower(value), nil
+	case BlockListTypeIPAddress:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", ErrInvalidBlockListValue
+		}
+
+		return ip.String(), nil
+	case BlockListTypeBIN:
+		return normalizeBINRange(value)
+	}
+
+	return "", ErrInvalidBlockListType
+}
+
+// normalizeBINRange validates a BIN or a range of BINs of the same length
+func normalizeBINRange(value string) (string, error) {
+	first, last, found := strings.Cut(value, "-")
+	if !found {
+		last = first
+	}
+
+	if !isBIN(first) || !isBIN(last) || len(first) != len(last) || first > last {
+		return "", ErrInvalidBlockListValue
+	}
+
+	return fmt.Sprintf("%s-%s", first, last), nil
+}
+
+func isBIN(value string) bool {
+	if len(value) < minBINLength || len(value) > maxBINLength {
+		return false
+	}
+
+	for _, digit := range value {
+		if digit < '0' || digit > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// BlockListEntry struct to store a value payments are rejected for before reaching the payment provider
+type BlockListEntry struct {
+	EntryID string        `json:"entry_id"`
+	Type    BlockListType `json:"type"`
+	Value   string        `json:"value"`
+	// Reason why the value was blocked
+	Reason string `json:"reason"`
+	// ExpiresAt moment the entry stops blocking payments, where nil blocks them until the entry is deleted
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// IsActive reports whether the entry blocks payments at the given moment
+func (e *BlockListEntry) IsActive(at time.Time) bool {
+	return e.ExpiresAt == nil || e.ExpiresAt.After(at)
+}
+
+// BlockListFilter criteria to list block list entries, where empty fields match every entry
+type BlockListFilter struct {
+	Types []BlockListType
+	// ActiveAt matches entries not expired at this moment
+	ActiveAt *time.Time
+	// Limit maximum number of entries returned, newest first, where zero means no limit
+	Limit int
+}
+
+// BlockListQuery attributes of a payment checked against the block list, where empty values are skipped
+type BlockListQuery struct {
+	PaymentMethod   string
+	CardFingerprint string
+	Email           string
+	IPAddress       string
+	BIN             string
+	// At moment the entries must be active at
+	At time.Time
+}
+
+// PaymentMethodDetails attributes of the card behind a payment method, as known by the payment provider
+type PaymentMethodDetails struct {
+	PaymentMethodID string
+	// Fingerprint identifies the card regardless of the payment method created for it
+	Fingerprint string
+	// BIN leading digits of the card number
+	BIN string
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockListTypeNormalize(t *testing.T) {
+	c := require.New(t)
+
+	testCases := []struct {
+		entryType BlockListType
+		value     string
+		expected  string
+		err       error
+	}{
+		{entryType: BlockListTypeEmail, value: " Fraudster@Example.com ", expected: "fraudster@example.com"},
+		{entryType: BlockListTypeEmail, value: "Fraudster <fraudster@example.com>", err: ErrInvalidBlockListValue},
+		{entryType: BlockListTypeIPAddress, value: "2001:DB8:0::1", expected: "2001:db8::1"},
+		{entryType: BlockListTypeIPAddress, value: "10.0.0", err: ErrInvalidBlockListValue},
+		{entryType: BlockListTypeBIN, value: "424242", expected: "424242-424242"},
+		{entryType: BlockListTypeBIN, value: "40000000-40009999", expected: "40000000-40009999"},
+		{entryType: BlockListTypeBIN, value: "400099-400000", err: ErrInvalidBlockListValue},
+		{entryType: BlockListTypeBIN, value: "4000-4000", err: ErrInvalidBlockListValue},
+		{entryType: BlockListTypeBIN, value: "400000-4000999", err: ErrInvalidBlockListValue},
+		{entryType: BlockListTypeCardFingerprint, value: "", err: ErrMissingBlockListValue},
+		{entryType: "phone", value: "555", err: ErrInvalidBlockListType},
+	}
+
+	for _, testCase := range testCases {
+		value, err := testCase.entryType.Normalize(testCase.value)
+		if testCase.err != nil {
+			c.ErrorIs(err, testCase.err, testCase.value)
+			continue
+		}
+
+		c.NoError(err, testCase.value)
+		c.Equal(testCase.expected, value)
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// currencyExponents number of decimal digits of the currencies whose minor unit isn't the cent, as defined by ISO 4217
+var currencyExponents = map[string]int{
+	"bif": 0,
+	"clp": 0,
+	"djf": 0,
+	"gnf": 0,
+	"isk": 0,
+	"jpy": 0,
+	"kmf": 0,
+	"krw": 0,
+	"mga": 0,
+	"pyg": 0,
+	"rwf": 0,
+	"ugx": 0,
+	"vnd": 0,
+	"vuv": 0,
+	"xaf": 0,
+	"xof": 0,
+	"xpf": 0,
+	"bhd": 3,
+	"iqd": 3,
+	"jod": 3,
+	"kwd": 3,
+	"lyd": 3,
+	"omr": 3,
+	"tnd": 3,
+}
+
+// CurrencyExponent returns the number of decimal digits of the currency minor unit, 2 unless stated otherwise
+func CurrencyExponent(currency string) int {
+	exponent, ok := currencyExponents[strings.ToLower(currency)]
+	if !ok {
+		return 2
+	}
+
+	return exponent
+}
+
+// FormatAmount formats an amount expressed in the currency minor unit as a decimal, e.g. 2000 usd as 20.00
+func FormatAmount(amount int, currency string) string {
+	exponent := CurrencyExponent(currency)
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.Itoa(amount)
+	if exponent == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatAmount(t *testing.T) {
+	c := require.New(t)
+
+	c.Equal("20.00", FormatAmount(2000, "usd"))
+	c.Equal("0.05", FormatAmount(5, "EUR"))
+	c.Equal("2000", FormatAmount(2000, "jpy"))
+	c.Equal("2.000", FormatAmount(2000, "kwd"))
+	c.Equal("0.007", FormatAmount(7, "bhd"))
+	c.Equal("-1.50", FormatAmount(-150, "usd"))
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ProviderReferenceFields additional fields identifying a transaction at its payment provider, which are kept when the
+// personal data of its customer is erased as they are required to reconcile and account for it
+var ProviderReferenceFields = []string{
+	"charge_id",
+	"payment_intent_id",
+	"refund_id",
+	"transfer_id",
+	"transfer_reversal_id",
+}
+
+// NormalizeCustomer returns the customer identifier as the risk decisions store it, so lookups ignore case
+func NormalizeCustomer(customer string) string {
+	return strings.ToLower(strings.TrimSpace(customer))
+}
+
+// CustomerData bundle of the data held about a paying customer, exported to answer their access requests
+type CustomerData struct {
+	Customer   string    `json:"customer"`
+	ExportedAt time.Time `json:"exported_at"`
+	// Transactions paid by the customer, with the outcome of the risk rules evaluated on them
+	Transactions  []*Transaction  `json:"transactions"`
+	RiskDecisions []*RiskDecision `json:"risk_decisions"`
+	// BlockListEntries entries listing the email, payment methods or addresses the customer paid with
+	BlockListEntries []*BlockListEntry `json:"block_list_entries"`
+	// AuditEntries operations performed on the transactions of the customer
+	AuditEntries []*AuditEntry `json:"audit_entries"`
+	// WebhookEvents provider events about the transactions of the customer, whose payloads carry their billing details
+	WebhookEvents []*WebhookEvent `json:"webhook_events"`
+}
+
+// CustomerErasure record of the erasure of the personal data of a paying customer, whose identifier is replaced
+// by the erasure ID wherever it was stored
+type CustomerErasure struct {
+	ErasureID string `json:"erasure_id"`
+	// Customer identifier of the customer, which is never stored along with the erasure
+	Customer string `json:"-"`
+	// Transactions number of transactions whose description and personal additional fields were erased
+	Transactions int `json:"transactions"`
+	// RiskDecisions number of risk decisions whose customer, payment method, address and country were erased
+	RiskDecisions int `json:"risk_decisions"`
+	// WebhookEvents number of webhook events about the transactions of the customer whose payload was dropped
+	WebhookEvents int `json:"webhook_events"`
+	// BlockListEntries number of block list entries listing the email of the customer which were deleted
+	BlockListEntries int       `json:"block_list_entries"`
+	ErasedAt         time.Time `json:"erased_at"`
+	// TransactionIDs transactions of the customer, so their copies held outside the database can be erased as well
+	TransactionIDs []string `json:"-"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventType type for the domain events published when the platform state changes
+type EventType string
+
+var (
+	// EventTypeTransactionCreated event when a transaction is stored for the first time
+	EventTypeTransactionCreated EventType = "transaction.created"
+	// EventTypeTransactionUpdated event when the status, type or fields of a transaction change
+	EventTypeTransactionUpdated EventType = "transaction.updated"
+	// EventTypeMerchantCreated event when a merchant account is created
+	EventTypeMerchantCreated EventType = "merchant.created"
+	// EventTypeAPIKeyCreated event when an API key is issued to a merchant
+	EventTypeAPIKeyCreated EventType = "api_key.created"
+	// EventTypeAPIKeyExpired event when an API key is scheduled to stop being accepted
+	EventTypeAPIKeyExpired EventType = "api_key.expired"
+	// EventTypeConnectedAccountCreated event when a merchant connects the account of one of its sellers
+	EventTypeConnectedAccountCreated EventType = "connected_account.created"
+)
+
+const (
+	// AggregateTransaction aggregate type of the events about a transaction
+	AggregateTransaction = "transaction"
+	// AggregateMerchant aggregate type of the events about a merchant
+	AggregateMerchant = "merchant"
+	// AggregateAPIKey aggregate type of the events about an API key
+	AggregateAPIKey = "api_key"
+	// AggregateConnectedAccount aggregate type of the events about a connected account
+	AggregateConnectedAccount = "connected_account"
+)
+
+// Event domain event stored in the outbox along with the change it describes. Events of the same
+// aggregate are published in the order of their sequence
+type Event struct {
+	Sequence      int64           `json:"sequence"`
+	EventID       string          `json:"event_id"`
+	Type          EventType       `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// TransferType type for the movement of funds between the platform and a connected account
+type TransferType string
+
+var (
+	// TransferTypeTransfer share of a charge sent to a connected account
+	TransferTypeTransfer TransferType = "transfer"
+	// TransferTypeReversal share of a refund taken back from a connected account
+	TransferTypeReversal TransferType = "reversal"
+)
+
+// ConnectedAccount struct to store a seller of a marketplace merchant, mapped to an account of the payment provider
+type ConnectedAccount struct {
+	AccountID         string          `json:"account_id"`
+	MerchantID        string          `json:"merchant_id"`
+	Name              string          `json:"name"`
+	Provider          PaymentProvider `json:"payment_provider"`
+	ProviderAccountID string          `json:"provider_account_id"`
+	CreatedAt         time.Time       `json:"created_at"`
+}
+
+// Transfer struct to store the share of a transaction moved to or from a connected account, in the transaction currency
+type Transfer struct {
+	TransferID    string       `json:"transfer_id"`
+	TransactionID string       `json:"transaction_id"`
+	AccountID     string       `json:"account_id"`
+	Type          TransferType `json:"type"`
+	Amount        int          `json:"amount"`
+	Currency      string       `json:"currency"`
+	// ProviderTransferID identifier of the transfer or reversal at the payment provider, once it was created
+	ProviderTransferID string    `json:"provider_transfer_id,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// ReversalAmount returns the share of the transfer taken back when refundAmount out of chargeAmount is refunded
+func (t *Transfer) ReversalAmount(refundAmount, chargeAmount int) int {
+	if chargeAmount <= 0 || refundAmount >= chargeAmount {
+		return t.Amount
+	}
+
+	return int(math.Round(float64(t.Amount) * float64(refundAmount) / float64(chargeAmount)))
+}
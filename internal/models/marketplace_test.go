@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferReversalAmount(t *testing.T) {
+	c := require.New(t)
+
+	transfer := &Transfer{Amount: 1800}
+
+	c.Equal(1800, transfer.ReversalAmount(2000, 2000))
+	c.Equal(900, transfer.ReversalAmount(1000, 2000))
+	c.Equal(600, transfer.ReversalAmount(667, 2000))
+	c.Equal(1800, transfer.ReversalAmount(500, 0))
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// APIKeyType type for the kind of API key issued to a merchant
+type APIKeyType string
+
+// APIKeyMode type for the environment an API key operates in
+type APIKeyMode string
+
+var (
+	// APIKeyTypeSecret key type with access to every merchant operation
+	APIKeyTypeSecret APIKeyType = "secret"
+	// APIKeyTypePublishable key type safe to embed in clients, limited to querying the status of payments
+	APIKeyTypePublishable APIKeyType = "publishable"
+
+	// APIKeyModeLive mode for keys operating on real payments
+	APIKeyModeLive APIKeyMode = "live"
+	// APIKeyModeTest mode for keys operating on test payments
+	APIKeyModeTest APIKeyMode = "test"
+)
+
+// Merchant struct to store a merchant account using the platform
+type Merchant struct {
+	MerchantID string    `json:"merchant_id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// APIKey struct to store a hashed API key issued to a merchant
+type APIKey struct {
+	KeyID      string     `json:"key_id"`
+	MerchantID string     `json:"merchant_id"`
+	Type       APIKeyType `json:"type"`
+	Mode       APIKeyMode `json:"mode"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// IsActive reports whether the key can still be used at the given time
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// APIKeyCredential struct to return the plaintext value of an API key right after it is issued
+type APIKeyCredential struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// MerchantCredentials struct returned when a merchant is created along with its initial API keys
+type MerchantCredentials struct {
+	Merchant
+	APIKeys []*APIKeyCredential `json:"api_keys"`
+}
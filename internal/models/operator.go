@@ -0,0 +1,120 @@
+package models
+
+import "time"
+
+// OperatorRole type for the role of a platform operator, which determines the operations they can perform
+type OperatorRole string
+
+// Permission type for an operation restricted to some operator roles
+type Permission string
+
+var (
+	// OperatorRoleViewer role of the operators looking into payments, payouts, reviews and the block list
+	OperatorRoleViewer OperatorRole = "viewer"
+	// OperatorRoleSupport role of the operators helping merchants, who work the review queue and the block list
+	OperatorRoleSupport OperatorRole = "support"
+	// OperatorRoleFinance role of the operators handling money movements, who approve refunds and export payments
+	OperatorRoleFinance OperatorRole = "finance"
+	// OperatorRoleAdmin role of the operators allowed to perform every operation
+	OperatorRoleAdmin OperatorRole = "admin"
+)
+
+var (
+	// PermissionReadTransactions permission to look up, search and reconcile the transactions of every merchant
+	PermissionReadTransactions Permission = "transactions:read"
+	// PermissionExportTransactions permission to export the transactions of every merchant
+	PermissionExportTransactions Permission = "transactions:export"
+	// PermissionRefundTransactions permission to refund the transactions of every merchant
+	PermissionRefundTransactions Permission = "transactions:refund"
+	// PermissionForceTransactionStatus permission to override the status of a transaction
+	PermissionForceTransactionStatus Permission = "transactions:force_status"
+	// PermissionReencryptTransactions permission to encrypt the additional fields of every transaction with the active key
+	PermissionReencryptTransactions Permission = "transactions:reencrypt"
+	// PermissionReplayWebhookEvents permission to process stored webhook events again
+	PermissionReplayWebhookEvents Permission = "webhook_events:replay"
+	// PermissionReadPayouts permission to list payouts and the transactions they settled
+	PermissionReadPayouts Permission = "payouts:read"
+	// PermissionReadReviews permission to list the reviews of flagged payments
+	PermissionReadReviews Permission = "reviews:read"
+	// PermissionDecideReviews permission to approve or reject the reviews of flagged payments
+	PermissionDecideReviews Permission = "reviews:decide"
+	// PermissionReadRefundRequests permission to list the refunds held for approval
+	PermissionReadRefundRequests Permission = "refund_requests:read"
+	// PermissionDecideRefundRequests permission to approve or reject the refunds held for approval
+	PermissionDecideRefundRequests Permission = "refund_requests:decide"
+	// PermissionReadBlockList permission to list the block list entries
+	PermissionReadBlockList Permission = "blocklist:read"
+	// PermissionManageBlockList permission to create, update and delete block list entries
+	PermissionManageBlockList Permission = "blocklist:write"
+	// PermissionReadAuditLog permission to query and verify the audit log
+	PermissionReadAuditLog Permission = "audit:read"
+	// PermissionExportCustomerData permission to export the data held about a paying customer
+	PermissionExportCustomerData Permission = "customers:export"
+	// PermissionEraseCustomerData permission to erase the personal data of a paying customer
+	PermissionEraseCustomerData Permission = "customers:erase"
+	// PermissionApplyRetention permission to archive old transactions and drop old webhook event payloads
+	PermissionApplyRetention Permission = "retention:apply"
+	// PermissionManageMerchants permission to create merchants
+	PermissionManageMerchants Permission = "merchants:write"
+)
+
+var viewerPermissions = []Permission{
+	PermissionReadTransactions,
+	PermissionReadPayouts,
+	PermissionReadReviews,
+	PermissionReadRefundRequests,
+	PermissionReadBlockList,
+}
+
+// rolePermissions permissions granted to each role, where admins are granted every permission
+var rolePermissions = map[OperatorRole][]Permission{
+	OperatorRoleViewer: viewerPermissions,
+	OperatorRoleSupport: append([]Permission{
+		PermissionRefundTransactions,
+		PermissionReplayWebhookEvents,
+		PermissionDecideReviews,
+		PermissionManageBlockList,
+		PermissionExportCustomerData,
+	}, viewerPermissions...),
+	OperatorRoleFinance: append([]Permission{
+		PermissionExportTransactions,
+		PermissionRefundTransactions,
+		PermissionDecideRefundRequests,
+		PermissionReadAuditLog,
+	}, viewerPermissions...),
+}
+
+// IsValid checks if the role is one of the supported operator roles
+func (r OperatorRole) IsValid() bool {
+	return r == OperatorRoleViewer || r == OperatorRoleSupport || r == OperatorRoleFinance || r == OperatorRoleAdmin
+}
+
+// Can reports whether the role is granted the permission
+func (r OperatorRole) Can(permission Permission) bool {
+	if r == OperatorRoleAdmin {
+		return true
+	}
+
+	for _, granted := range rolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Operator struct to store the identity of a platform operator carried by a signed operator token
+type Operator struct {
+	Name      string       `json:"sub"`
+	Role      OperatorRole `json:"role"`
+	IssuedAt  time.Time    `json:"iat"`
+	ExpiresAt time.Time    `json:"exp"`
+	// KeyID ID of the key the token was signed with
+	KeyID string `json:"kid"`
+}
+
+// IsActive reports whether the token of the operator can still be used at the given time
+func (o *Operator) IsActive(now time.Time) bool {
+	return now.Before(o.ExpiresAt)
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperatorRoleCan(t *testing.T) {
+	c := require.New(t)
+
+	testCases := []struct {
+		role       OperatorRole
+		permission Permission
+		allowed    bool
+	}{
+		{role: OperatorRoleViewer, permission: PermissionReadTransactions, allowed: true},
+		{role: OperatorRoleViewer, permission: PermissionRefundTransactions, allowed: false},
+		{role: OperatorRoleSupport, permission: PermissionDecideReviews, allowed: true},
+		{role: OperatorRoleSupport, permission: PermissionDecideRefundRequests, allowed: false},
+		{role: OperatorRoleSupport, permission: PermissionExportTransactions, allowed: false},
+		{role: OperatorRoleSupport, permission: PermissionExportCustomerData, allowed: true},
+		{role: OperatorRoleSupport, permission: PermissionEraseCustomerData, allowed: false},
+		{role: OperatorRoleFinance, permission: PermissionDecideRefundRequests, allowed: true},
+		{role: OperatorRoleFinance, permission: PermissionExportTransactions, allowed: true},
+		{role: OperatorRoleFinance, permission: PermissionForceTransactionStatus, allowed: false},
+		{role: OperatorRoleAdmin, permission: PermissionForceTransactionStatus, allowed: true},
+		{role: OperatorRoleAdmin, permission: PermissionManageMerchants, allowed: true},
+		{role: "root", permission: PermissionReadTransactions, allowed: false},
+	}
+
+	for _, testCase := range testCases {
+		c.Equal(testCase.allowed, testCase.role.Can(testCase.permission), "%s %s", testCase.role, testCase.permission)
+	}
+}
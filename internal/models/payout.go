@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// PayoutStatus type for status of a payout to the bank account of the platform, defined by the payment provider
+type PayoutStatus string
+
+var (
+	// PayoutStatusPending status for payout not yet submitted to the bank
+	PayoutStatusPending PayoutStatus = "pending"
+	// PayoutStatusInTransit status for payout submitted to the bank
+	PayoutStatusInTransit PayoutStatus = "in_transit"
+	// PayoutStatusPaid status for payout deposited in the bank account
+	PayoutStatusPaid PayoutStatus = "paid"
+	// PayoutStatusFailed status for payout the bank couldn't deposit
+	PayoutStatusFailed PayoutStatus = "failed"
+	// PayoutStatusCanceled status for payout canceled before it was submitted
+	PayoutStatusCanceled PayoutStatus = "canceled"
+)
+
+// IsValid reports whether the status is one of the known payout statuses
+func (s PayoutStatus) IsValid() bool {
+	return s == PayoutStatusPending || s == PayoutStatusInTransit || s.IsFinal()
+}
+
+// IsFinal reports whether the payout can't change status anymore
+func (s PayoutStatus) IsFinal() bool {
+	return s == PayoutStatusPaid || s == PayoutStatusFailed || s == PayoutStatusCanceled
+}
+
+// Payout struct to store a deposit of the provider balance to the bank account of the platform
+type Payout struct {
+	PayoutID         string          `json:"payout_id"`
+	Provider         PaymentProvider `json:"payment_provider"`
+	ProviderPayoutID string          `json:"provider_payout_id"`
+	Status           PayoutStatus    `json:"status"`
+	Amount           int             `json:"amount"`
+	Currency         string          `json:"currency"`
+	FailureReason    string          `json:"failure_reason,omitempty"`
+	ArrivalDate      time.Time       `json:"arrival_date"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// PayoutItem balance movement settled by a payout, identified by the charge or refund it comes from
+type PayoutItem struct {
+	ProviderBalanceTransactionID string `json:"provider_balance_transaction_id"`
+	// ProviderSourceID identifier of the charge or refund at the provider, matching the additional fields of a transaction
+	ProviderSourceID string `json:"provider_source_id"`
+	Amount           int    `json:"amount"`
+	Fee              int    `json:"fee"`
+	Net              int    `json:"net"`
+	Currency         string `json:"currency"`
+}
+
+// PayoutFilter criteria to list payouts, where empty fields match every payout
+type PayoutFilter struct {
+	Status PayoutStatus
+	// Limit maximum number of payouts returned, most recent first, where zero means no limit
+	Limit int
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// RefundRequestStatus type for status of a refund held until an operator approves it
+type RefundRequestStatus string
+
+var (
+	// RefundRequestStatusPending status for refund request waiting for an operator other than its requester
+	RefundRequestStatusPending RefundRequestStatus = "pending"
+	// RefundRequestStatusApproved status for refund request whose refund was issued with the provider
+	RefundRequestStatusApproved RefundRequestStatus = "approved"
+	// RefundRequestStatusRejected status for refund request that was never issued, restoring its transaction
+	RefundRequestStatusRejected RefundRequestStatus = "rejected"
+	// RefundRequestStatusClosed status for refund request whose refund the provider reported as issued outside of its
+	// approval, such as from the dashboard of the provider
+	RefundRequestStatusClosed RefundRequestStatus = "closed"
+)
+
+// IsValid reports whether the status is one of the known refund request statuses
+func (s RefundRequestStatus) IsValid() bool {
+	return s == RefundRequestStatusPending || s == RefundRequestStatusApproved || s == RefundRequestStatusRejected ||
+		s == RefundRequestStatusClosed
+}
+
+// RefundRequest struct to store a refund over the approval threshold of the merchant, which holds its transaction in
+// the refund_requested status until a second operator decides on it
+type RefundRequest struct {
+	RefundRequestID string              `json:"refund_request_id"`
+	TransactionID   string              `json:"transaction_id"`
+	MerchantID      string              `json:"merchant_id,omitempty"`
+	Status          RefundRequestStatus `json:"status"`
+	Amount          int                 `json:"amount"`
+	Currency        string              `json:"currency"`
+	// PreviousStatus status of the transaction before the refund was requested, restored if it's rejected
+	PreviousStatus TransactionStatus `json:"previous_status"`
+	// RequestedBy identity of the API key or operator requesting the refund, as recorded in the audit log
+	RequestedBy string `json:"requested_by"`
+	// RequesterType kind of actor who requested the refund. An operator can't approve the refunds they requested
+	RequesterType AuditActorType `json:"requester_type"`
+	// Reason why the refund was requested
+	Reason string `json:"reason,omitempty"`
+	// DecidedBy identity of the operator who approved or rejected the refund, or the provider that closed it
+	DecidedBy string `json:"decided_by,omitempty"`
+	// RejectionReason why the refund was rejected
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	DecidedAt       *time.Time `json:"decided_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	// Transaction refunded transaction, as left by the decision
+	Transaction *Transaction `json:"transaction,omitempty"`
+}
+
+// RefundRequestFilter criteria to list refund requests, where empty fields match every request
+type RefundRequestFilter struct {
+	Status RefundRequestStatus
+	// Limit maximum number of requests returned, oldest first, where zero means no limit
+	Limit int
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ArchiveMode type for where transactions past their retention are moved to
+type ArchiveMode string
+
+var (
+	// ArchiveModeTable mode moving transactions to the monthly partitions of the transactions_archive table
+	ArchiveModeTable ArchiveMode = "table"
+	// ArchiveModeNDJSON mode moving transactions to compressed NDJSON files, one per month of creation
+	ArchiveModeNDJSON ArchiveMode = "ndjson"
+)
+
+// IsValid checks if the archive mode is one of the supported modes
+func (m ArchiveMode) IsValid() bool {
+	return m == ArchiveModeTable || m == ArchiveModeNDJSON
+}
+
+// RetentionRun outcome of applying the retention policies once
+type RetentionRun struct {
+	ArchiveMode ArchiveMode `json:"archive_mode,omitempty"`
+	// ArchivedBefore transactions created before this time were archived, unset when transactions are kept forever
+	ArchivedBefore       *time.Time `json:"archived_before,omitempty"`
+	ArchivedTransactions int        `json:"archived_transactions"`
+	// ArchiveFiles files the transactions were appended to, when archived as NDJSON
+	ArchiveFiles []string `json:"archive_files,omitempty"`
+	// PrunedBefore payloads of webhook events received before this time were dropped, unset when they are kept forever
+	PrunedBefore          *time.Time `json:"pruned_before,omitempty"`
+	PrunedWebhookPayloads int        `json:"pruned_webhook_payloads"`
+}
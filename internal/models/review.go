@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// ReviewStatus type for status of the manual review of a payment flagged by the risk rules
+type ReviewStatus string
+
+var (
+	// ReviewStatusPending status for review waiting for a decision, while the payment is only authorized
+	ReviewStatusPending ReviewStatus = "pending"
+	// ReviewStatusApproved status for review whose payment was captured
+	ReviewStatusApproved ReviewStatus = "approved"
+	// ReviewStatusRejected status for review whose authorization was canceled, by a reviewer or once it expired
+	ReviewStatusRejected ReviewStatus = "rejected"
+)
+
+const (
+	// FailureReasonReviewRejected failure reason of transactions rejected by a reviewer
+	FailureReasonReviewRejected = "review_rejected"
+	// FailureReasonReviewExpired failure reason of transactions nobody reviewed in time
+	FailureReasonReviewExpired = "review_expired"
+	// ReviewerTimeout reviewer recorded on the reviews rejected once they expired
+	ReviewerTimeout = "system:timeout"
+)
+
+// IsValid reports whether the status is one of the known review statuses
+func (s ReviewStatus) IsValid() bool {
+	return s == ReviewStatusPending || s == ReviewStatusApproved || s == ReviewStatusRejected
+}
+
+// Review struct to store the manual review of a payment flagged by the risk rules, which holds its capture until a
+// reviewer decides on it or it expires
+type Review struct {
+	ReviewID      string       `json:"review_id"`
+	TransactionID string       `json:"transaction_id"`
+	MerchantID    string       `json:"merchant_id,omitempty"`
+	Status        ReviewStatus `json:"status"`
+	Amount        int          `json:"amount"`
+	Currency      string       `json:"currency"`
+	// Rules names of the risk rules that flagged the payment
+	Rules []string `json:"rules"`
+	// Transfer share of a destination charge moved to the connected account, only recorded once the payment is captured
+	Transfer *Transfer `json:"transfer,omitempty"`
+	// Reviewer identity of who decided on the review
+	Reviewer string `json:"reviewer,omitempty"`
+	// Reason why the payment was rejected
+	Reason string `json:"reason,omitempty"`
+	// ExpiresAt moment the review is rejected if nobody decided on it
+	ExpiresAt time.Time  `json:"expires_at"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	// Transaction reviewed payment, as left by the decision
+	Transaction *Transaction `json:"transaction,omitempty"`
+}
+
+// ReviewFilter criteria to list reviews, where empty fields match every review
+type ReviewFilter struct {
+	Status ReviewStatus
+	// ExpiredBefore matches reviews expiring before this moment
+	ExpiredBefore *time.Time
+	// Limit maximum number of reviews returned, oldest first, where zero means no limit
+	Limit int
+}
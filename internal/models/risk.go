@@ -0,0 +1,99 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// RiskOutcome type for the decision taken on a payment before charging it
+type RiskOutcome string
+
+// RiskField type for the attribute of a payment risk rules look at
+type RiskField string
+
+var (
+	// RiskOutcomeAllow outcome for payments charged right away
+	RiskOutcomeAllow RiskOutcome = "allow"
+	// RiskOutcomeReview outcome for payments a person should look at
+	RiskOutcomeReview RiskOutcome = "review"
+	// RiskOutcomeBlock outcome for payments rejected without reaching the payment provider
+	RiskOutcomeBlock RiskOutcome = "block"
+
+	// RiskFieldPaymentMethod payment method of the payment
+	RiskFieldPaymentMethod RiskField = "payment_method"
+	// RiskFieldCustomer email or identifier of the paying customer
+	RiskFieldCustomer RiskField = "customer"
+	// RiskFieldIPAddress address the customer paid from
+	RiskFieldIPAddress RiskField = "ip_address"
+	// RiskFieldCountry billing country of the payment method
+	RiskFieldCountry RiskField = "country"
+)
+
+// FailureReasonRiskBlocked failure reason of transactions blocked by a risk rule
+const FailureReasonRiskBlocked = "risk_blocked"
+
+// IsValid reports whether the outcome is one of the known risk outcomes
+func (o RiskOutcome) IsValid() bool {
+	return o == RiskOutcomeAllow || o == RiskOutcomeReview || o == RiskOutcomeBlock
+}
+
+// Severity orders outcomes from allow to block, so the most severe outcome of several rules wins
+func (o RiskOutcome) Severity() int {
+	switch o {
+	case RiskOutcomeReview:
+		return 1
+	case RiskOutcomeBlock:
+		return 2
+	}
+
+	return 0
+}
+
+// IsValid reports whether the field is one of the payment attributes known to risk rules
+func (f RiskField) IsValid() bool {
+	return f == RiskFieldPaymentMethod || f == RiskFieldCustomer || f == RiskFieldIPAddress || f == RiskFieldCountry
+}
+
+// Value returns the value of the field in the input, normalized so comparisons ignore case and, for the customer,
+// surrounding spaces
+func (f RiskField) Value(input *TransactionInput) string {
+	switch f {
+	case RiskFieldPaymentMethod:
+		return input.PaymentMethod
+	case RiskFieldCustomer:
+		return NormalizeCustomer(input.Customer)
+	case RiskFieldIPAddress:
+		return input.IPAddress
+	case RiskFieldCountry:
+		return strings.ToUpper(input.Country)
+	}
+
+	return ""
+}
+
+// RiskRuleResult outcome of evaluating a single rule, kept as the trace of a risk decision
+type RiskRuleResult struct {
+	Rule    string      `json:"rule"`
+	Type    string      `json:"type"`
+	Matched bool        `json:"matched"`
+	Action  RiskOutcome `json:"action"`
+	// Detail why the rule matched
+	Detail string `json:"detail,omitempty"`
+}
+
+// RiskDecision struct to store the outcome of the risk rules evaluated before charging a payment, along with the
+// attributes of the payment used to compute velocities
+type RiskDecision struct {
+	DecisionID    string            `json:"decision_id"`
+	TransactionID string            `json:"transaction_id"`
+	MerchantID    string            `json:"merchant_id"`
+	Outcome       RiskOutcome       `json:"outcome"`
+	Rules         []*RiskRuleResult `json:"rules"`
+	PaymentMethod string            `json:"payment_method"`
+	Customer      string            `json:"customer,omitempty"`
+	IPAddress     string            `json:"ip_address,omitempty"`
+	Country       string            `json:"country,omitempty"`
+	Amount        int               `json:"amount"`
+	Currency      string            `json:"currency"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // TransactionStatus type for status of transaction, defined by the payment provided
 type TransactionStatus string
 
@@ -16,6 +18,10 @@ var (
 	TransactionStatusFailure TransactionStatus = "failure"
 	// TransactionStatusPending status for pending transaction
 	TransactionStatusPending TransactionStatus = "pending"
+	// TransactionStatusReview status for transaction authorized but held until a reviewer decides on its capture
+	TransactionStatusReview TransactionStatus = "review"
+	// TransactionStatusRefundRequested status for transaction whose refund is held until an operator approves it
+	TransactionStatusRefundRequested TransactionStatus = "refund_requested"
 
 	// PaymentProviderStripe represents the Stripe integration
 	PaymentProviderStripe PaymentProvider = "stripe"
@@ -31,6 +37,7 @@ var (
 // Transaction struct to process and store a transaction
 type Transaction struct {
 	TransactionID    string                 `json:"transaction_id"`
+	MerchantID       string                 `json:"merchant_id,omitempty"`
 	Status           TransactionStatus      `json:"status"`
 	Description      string                 `json:"description"`
 	FailureReason    string                 `json:"failure_reason,omitempty"`
@@ -39,4 +46,86 @@ type Transaction struct {
 	Currency         string                 `json:"currency"`
 	Type             TransactionType        `json:"type"`
 	AdditionalFields map[string]interface{} `json:"additional_fields"`
+	// ProviderFee fee charged by the payment provider, in FeeCurrency, accumulated across the charge and its refund
+	ProviderFee int `json:"provider_fee"`
+	// PlatformFee fee charged by the platform to the merchant, in Currency
+	PlatformFee int `json:"platform_fee"`
+	// NetAmount amount owed to the merchant after the platform fee and the transfers to connected accounts, in Currency
+	NetAmount int `json:"net_amount"`
+	// FeeCurrency currency the payment provider settles its fee in
+	FeeCurrency string `json:"fee_currency,omitempty"`
+	// Transfers shares of the transaction moved to or from connected accounts
+	Transfers []*Transfer `json:"transfers,omitempty"`
+	// RiskDecision decision of the risk rules evaluated before charging, stored along with the transaction
+	RiskDecision *RiskDecision `json:"-"`
+	// Review manual review holding the capture of the transaction, stored along with it
+	Review *Review `json:"-"`
+	// Version incremented on every update, used to detect concurrent modifications
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Archived set when the transaction was read from the archive, which can't be updated anymore
+	Archived bool `json:"archived,omitempty"`
+}
+
+// PaymentStatus outcome of a payment, the only part of a transaction shown to publishable keys, which are shipped to
+// browsers so checkout pages can follow the payments they started
+type PaymentStatus struct {
+	TransactionID string            `json:"transaction_id"`
+	Status        TransactionStatus `json:"status"`
+	FailureReason string            `json:"failure_reason,omitempty"`
+	Amount        int               `json:"amount"`
+	Currency      string            `json:"currency"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// PaymentStatus returns the outcome of the transaction, leaving out its description, additional fields and fees
+func (t *Transaction) PaymentStatus() *PaymentStatus {
+	return &PaymentStatus{
+		TransactionID: t.TransactionID,
+		Status:        t.Status,
+		FailureReason: t.FailureReason,
+		Amount:        t.Amount,
+		Currency:      t.Currency,
+		UpdatedAt:     t.UpdatedAt,
+	}
+}
+
+// IsValid reports whether the status is one of the known transaction statuses
+func (s TransactionStatus) IsValid() bool {
+	return s == TransactionStatusSucceeded || s == TransactionStatusFailure || s == TransactionStatusPending || s == TransactionStatusReview ||
+		s == TransactionStatusRefundRequested
+}
+
+// IsValid reports whether the type is one of the known transaction types
+func (t TransactionType) IsValid() bool {
+	return t == TransactionTypeCharge || t == TransactionTypeRefund
+}
+
+// TransactionFilter criteria to list transactions, where empty fields match every transaction
+type TransactionFilter struct {
+	MerchantID string
+	Status     TransactionStatus
+	Type       TransactionType
+	Provider   PaymentProvider
+	Currency   string
+	// CreatedFrom matches transactions created at or after this moment
+	CreatedFrom *time.Time
+	// CreatedTo matches transactions created before this moment
+	CreatedTo *time.Time
+	// Search matches the transaction ID, part of the description or any provider identifier in the additional fields
+	Search string
+	// Limit maximum number of transactions returned, most recent first, where zero means no limit
+	Limit int
+}
+
+// Discrepancy difference between a transaction as stored by the platform and as reported by its payment provider
+type Discrepancy struct {
+	TransactionID  string            `json:"transaction_id"`
+	Status         TransactionStatus `json:"status"`
+	ProviderStatus TransactionStatus `json:"provider_status,omitempty"`
+	Type           TransactionType   `json:"type"`
+	ProviderType   TransactionType   `json:"provider_type,omitempty"`
+	// Error why the transaction couldn't be queried from the provider
+	Error string `json:"error,omitempty"`
 }
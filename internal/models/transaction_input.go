@@ -3,14 +3,30 @@ package models
 import (
 	"errors"
 	"fmt"
+	"net"
 )
 
 // TransactionInput inputs to perform a transaction
 type TransactionInput struct {
+	MerchantID    string `json:"merchant_id"`
 	Amount        int64  `json:"amount"`
 	Currency      string `json:"currency"`
 	PaymentMethod string `json:"payment_method"`
 	Description   string `json:"description"`
+	// TransferDestination connected account receiving the charge minus the application fee
+	TransferDestination string `json:"transfer_destination,omitempty"`
+	// ApplicationFeeAmount share of the charge kept when it's transferred to a connected account
+	ApplicationFeeAmount int64 `json:"application_fee_amount,omitempty"`
+	// DestinationProviderAccountID account of the transfer destination at the payment provider
+	DestinationProviderAccountID string `json:"-"`
+	// Customer email or identifier of the paying customer, used by risk rules
+	Customer string `json:"customer,omitempty"`
+	// IPAddress address the customer paid from, used by risk rules
+	IPAddress string `json:"ip_address,omitempty"`
+	// Country ISO code of the billing country of the payment method, used by risk rules
+	Country string `json:"country,omitempty"`
+	// CaptureManually only authorizes the payment, leaving its capture to a later decision
+	CaptureManually bool `json:"-"`
 }
 
 var (
@@ -20,6 +36,14 @@ var (
 	ErrMissingCurrency = errors.New("missing currency")
 	// ErrMissingPaymentMethod error when payment method is missing
 	ErrMissingPaymentMethod = errors.New("missing payment method")
+	// ErrInvalidApplicationFeeAmount error when application fee is negative or exceeds the amount
+	ErrInvalidApplicationFeeAmount = errors.New("invalid application fee amount")
+	// ErrMissingTransferDestination error when an application fee is given without a transfer destination
+	ErrMissingTransferDestination = errors.New("missing transfer destination")
+	// ErrInvalidIPAddress error when the IP address of the customer can't be parsed
+	ErrInvalidIPAddress = errors.New("invalid ip address")
+	// ErrInvalidCountry error when the country isn't a two-letter ISO code
+	ErrInvalidCountry = errors.New("invalid country")
 )
 
 // Validate validate the inputs required for a transaction
@@ -36,6 +60,22 @@ func (ti *TransactionInput) Validate() error {
 		return ErrMissingPaymentMethod
 	}
 
+	if ti.ApplicationFeeAmount < 0 || ti.ApplicationFeeAmount > ti.Amount {
+		return ErrInvalidApplicationFeeAmount
+	}
+
+	if ti.ApplicationFeeAmount > 0 && ti.TransferDestination == "" {
+		return ErrMissingTransferDestination
+	}
+
+	if ti.IPAddress != "" && net.ParseIP(ti.IPAddress) == nil {
+		return ErrInvalidIPAddress
+	}
+
+	if ti.Country != "" && len(ti.Country) != 2 {
+		return ErrInvalidCountry
+	}
+
 	if ti.Description == "" {
 		ti.Description = fmt.Sprintf("Transaction for payment amount of %d", ti.Amount)
 	}
@@ -24,4 +24,33 @@ func TestValidateTransactionInput(t *testing.T) {
 	input.PaymentMethod = "pm_card_visa"
 
 	c.NoError(input.Validate())
+
+	input.ApplicationFeeAmount = 2500
+
+	c.ErrorIs(input.Validate(), ErrInvalidApplicationFeeAmount)
+
+	input.ApplicationFeeAmount = 200
+
+	c.ErrorIs(input.Validate(), ErrMissingTransferDestination)
+
+	input.TransferDestination = "ACC_123"
+
+	c.NoError(input.Validate())
+}
+
+func TestValidateTransactionInputRiskAttributes(t *testing.T) {
+	c := require.New(t)
+
+	input := TransactionInput{Amount: 2000, Currency: "usd", PaymentMethod: "pm_card_visa", IPAddress: "203.0.113"}
+
+	c.ErrorIs(input.Validate(), ErrInvalidIPAddress)
+
+	input.IPAddress = "203.0.113.7"
+	input.Country = "USA"
+
+	c.ErrorIs(input.Validate(), ErrInvalidCountry)
+
+	input.Country = "us"
+
+	c.NoError(input.Validate())
 }
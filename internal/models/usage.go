@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// RefundRatioWindow days of charges and refunds the refund ratio is computed over
+const RefundRatioWindow = 30
+
+// MerchantLimits caps on the payments of a merchant in a currency, in the currency minor unit, where zero means no cap
+type MerchantLimits struct {
+	MaxCharge     int `json:"max_charge"`
+	DailyVolume   int `json:"daily_volume"`
+	MonthlyVolume int `json:"monthly_volume"`
+	// MaxRefundRatio percentage of the volume charged over the last 30 days that can be refunded
+	MaxRefundRatio float64 `json:"max_refund_ratio"`
+	// RefundApprovalThreshold amount above which refunds are held until an operator approves them
+	RefundApprovalThreshold int `json:"refund_approval_threshold"`
+}
+
+// UsageReservation amounts added to the running totals of a merchant before reaching the payment provider, and
+// released if the payment fails
+type UsageReservation struct {
+	MerchantID string
+	Currency   string
+	// Day UTC day the amounts count towards
+	Day      time.Time
+	Charged  int
+	Refunded int
+}
+
+// Usage running totals of the payments of a merchant in a currency, in the currency minor unit
+type Usage struct {
+	MerchantID string `json:"merchant_id"`
+	Currency   string `json:"currency"`
+	// DailyVolume charged during the current UTC day
+	DailyVolume int `json:"daily_volume"`
+	// MonthlyVolume charged during the current UTC month
+	MonthlyVolume int `json:"monthly_volume"`
+	// WindowVolume and WindowRefunds charged and refunded over the last 30 days
+	WindowVolume  int `json:"window_volume"`
+	WindowRefunds int `json:"window_refunds"`
+	// Limits caps applied to the merchant in the currency
+	Limits *MerchantLimits `json:"limits,omitempty"`
+}
+
+// RefundRatio percentage of the volume charged over the last 30 days that was refunded
+func (u *Usage) RefundRatio() float64 {
+	if u.WindowVolume == 0 {
+		return 0
+	}
+
+	return float64(u.WindowRefunds) * 100 / float64(u.WindowVolume)
+}
+
+// UsagePeriods returns the first UTC day of the periods the running totals are computed over at the given moment:
+// the day itself, its month, and the window of the refund ratio
+func UsagePeriods(at time.Time) (day, month, window time.Time) {
+	at = at.UTC()
+
+	day = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	month = time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+	window = day.AddDate(0, 0, -(RefundRatioWindow - 1))
+
+	return day, month, window
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEvent verified event received from a payment provider, stored so it can be inspected and replayed
+type WebhookEvent struct {
+	EventID     string          `json:"event_id"`
+	Provider    PaymentProvider `json:"provider"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at,omitempty"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+}
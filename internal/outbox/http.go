@@ -0,0 +1,65 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink constructor of the sink posting each event as JSON to the given URL. The event ID
+// is sent in the Idempotency-Key header so the receiver can discard redeliveries
+func NewHTTPSink(url string, client *http.Client) Sink {
+	return &httpSink{
+		url:    url,
+		client: client,
+	}
+}
+
+// Publish posts the event and expects a 2xx response
+func (s *httpSink) Publish(ctx context.Context, event *models.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.EventID)
+
+	response, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post event: %w", err)
+	}
+
+	defer response.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, response.StatusCode)
+	}
+
+	return nil
+}
+
+// Close releases the idle connections of the client
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/nats-io/nats.go"
+)
+
+// flushTimeout longest wait for the server to acknowledge a published event
+const flushTimeout = 5 * time.Second
+
+type natsSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATSSink constructor of the sink publishing each event to `<subject>.<event type>` on a NATS
+// compatible server. The event ID is sent in the Nats-Msg-Id header, which JetStream uses to discard
+// redeliveries
+func NewNATSSink(url, subject string) (Sink, error) {
+	conn, err := nats.Connect(url, nats.Name("online-payment-platform-relay"))
+	if err != nil {
+		return nil, fmt.Errorf("connect to nats: %w", err)
+	}
+
+	return &natsSink{
+		conn:    conn,
+		subject: subject,
+	}, nil
+}
+
+// Publish sends the event and waits for the server to acknowledge it received it
+func (s *natsSink) Publish(ctx context.Context, event *models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	msg := nats.NewMsg(fmt.Sprintf("%s.%s", s.subject, event.Type))
+	msg.Data = data
+	msg.Header.Set(nats.MsgIdHdr, event.EventID)
+
+	err = s.conn.PublishMsg(msg)
+	if err != nil {
+		return fmt.Errorf("publish event: %w", err)
+	}
+
+	// FlushWithContext requires a deadline
+	ctx, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+
+	err = s.conn.FlushWithContext(ctx)
+	if err != nil {
+		return fmt.Errorf("flush event: %w", err)
+	}
+
+	return nil
+}
+
+// Close flushes pending messages and closes the connection
+func (s *natsSink) Close() error {
+	return s.conn.Drain()
+}
@@ -0,0 +1,217 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/metrics"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxErrorLength longest publish error stored along with the event, so a verbose sink doesn't bloat the outbox
+const maxErrorLength = 500
+
+// Conn database connection used to read and mark the outbox events
+type Conn interface {
+	Begin(context.Context) (pgx.Tx, error)
+}
+
+// Relay publishes the outbox events to a sink with at-least-once delivery, in order for each aggregate
+type Relay interface {
+	Run(ctx context.Context) error
+	RelayBatch(ctx context.Context) (int, error)
+}
+
+type relay struct {
+	conn         Conn
+	sink         Sink
+	sinkName     string
+	batchSize    int
+	pollInterval time.Duration
+}
+
+// NewRelay constructor of the relay publishing the outbox events to the given sink
+func NewRelay(conn Conn, sink Sink, cfg config.Relay) Relay {
+	return &relay{
+		conn:         conn,
+		sink:         sink,
+		sinkName:     cfg.Sink,
+		batchSize:    cfg.BatchSize,
+		pollInterval: cfg.PollInterval,
+	}
+}
+
+// Run relays batches of events until ctx is done, polling the outbox whenever it is drained
+func (r *relay) Run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		published, err := r.RelayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "relay outbox events failed", slog.Any("error", err))
+		}
+
+		// keep going while there's progress, since a batch only holds the oldest pending event of each aggregate
+		if err == nil && published > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.pollInterval):
+		}
+	}
+
+	return nil
+}
+
+// RelayBatch publishes the oldest pending event of each aggregate and returns how many were published.
+//
+// The events are locked while they're published, so several relays can run side by side. An event is
+// marked as published only after the sink accepted it, so a crash in between publishes it again, and a
+// later event of the same aggregate isn't picked up until the previous one is published.
+func (r *relay) RelayBatch(ctx context.Context) (int, error) {
+	tx, err := r.conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	events, err := pendingEvents(ctx, tx, r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+
+	for _, event := range events {
+		err = r.sink.Publish(ctx, event)
+		if err != nil {
+			metrics.ObserveOutboxEvent(r.sinkName, string(event.Type), metrics.OutcomeError)
+
+			slog.WarnContext(ctx, "publish outbox event failed",
+				slog.String("event_id", event.EventID),
+				slog.String("event_type", string(event.Type)),
+				slog.Any("error", err),
+			)
+
+			err = markFailed(ctx, tx, event, err)
+			if err != nil {
+				return published, err
+			}
+
+			continue
+		}
+
+		metrics.ObserveOutboxEvent(r.sinkName, string(event.Type), metrics.OutcomeSuccess)
+
+		err = markPublished(ctx, tx, event)
+		if err != nil {
+			return published, err
+		}
+
+		published++
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return published, nil
+}
+
+func pendingEvents(ctx context.Context, tx pgx.Tx, limit int) ([]*models.Event, error) {
+	query := `
+	SELECT
+		id,
+		event_id,
+		event_type,
+		aggregate_type,
+		aggregate_id,
+		payload,
+		created_at
+	FROM outbox pending
+	WHERE published_at IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM outbox previous
+		WHERE previous.aggregate_type = pending.aggregate_type
+		AND previous.aggregate_id = pending.aggregate_id
+		AND previous.published_at IS NULL
+		AND previous.id < pending.id
+	)
+	ORDER BY id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query pending events: %w", err)
+	}
+
+	defer rows.Close()
+
+	var events []*models.Event
+
+	for rows.Next() {
+		var (
+			event   models.Event
+			payload string
+		)
+
+		err = rows.Scan(
+			&event.Sequence,
+			&event.EventID,
+			&event.Type,
+			&event.AggregateType,
+			&event.AggregateID,
+			&payload,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan pending event: %w", err)
+		}
+
+		event.Payload = []byte(payload)
+
+		events = append(events, &event)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("query pending events: %w", err)
+	}
+
+	return events, nil
+}
+
+func markPublished(ctx context.Context, tx pgx.Tx, event *models.Event) error {
+	query := `UPDATE outbox SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1`
+
+	_, err := tx.Exec(ctx, query, event.Sequence)
+	if err != nil {
+		return fmt.Errorf("mark event %s as published: %w", event.EventID, err)
+	}
+
+	return nil
+}
+
+func markFailed(ctx context.Context, tx pgx.Tx, event *models.Event, publishErr error) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE id = $2`
+
+	message := publishErr.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+
+	_, err := tx.Exec(ctx, query, message, event.Sequence)
+	if err != nil {
+		return fmt.Errorf("record failure of event %s: %w", event.EventID, err)
+	}
+
+	return nil
+}
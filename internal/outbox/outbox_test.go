@@ -0,0 +1,164 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var pendingColumns = []string{"id", "event_id", "event_type", "aggregate_type", "aggregate_id", "payload", "created_at"}
+
+func testRelayConfig() config.Relay {
+	return config.Relay{
+		Sink:         SinkStdout,
+		BatchSize:    10,
+		PollInterval: time.Second,
+	}
+}
+
+func TestRelayBatch(t *testing.T) {
+	c := require.New(t)
+
+	conn, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer conn.Close()
+
+	createdAt := time.Now()
+
+	rows := conn.NewRows(pendingColumns).
+		AddRow(int64(1), "EVT_1", models.EventTypeTransactionCreated, models.AggregateTransaction, "TXN_123", `{"transaction_id":"TXN_123"}`, createdAt).
+		AddRow(int64(2), "EVT_2", models.EventTypeMerchantCreated, models.AggregateMerchant, "MCH_123", `{"merchant_id":"MCH_123"}`, createdAt)
+
+	conn.ExpectBegin()
+	conn.ExpectQuery("FROM outbox pending").WithArgs(10).WillReturnRows(rows)
+	conn.ExpectExec("UPDATE outbox SET published_at").WithArgs(int64(1)).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	conn.ExpectExec("UPDATE outbox SET published_at").WithArgs(int64(2)).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	conn.ExpectCommit()
+
+	mockSink := MockSink{}
+
+	var published []string
+
+	mockSink.On("Publish", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		published = append(published, args.Get(1).(*models.Event).EventID)
+	}).Return(nil)
+
+	relay := NewRelay(conn, &mockSink, testRelayConfig())
+
+	count, err := relay.RelayBatch(context.Background())
+	c.NoError(err)
+	c.Equal(2, count)
+	c.Equal([]string{"EVT_1", "EVT_2"}, published)
+	c.NoError(conn.ExpectationsWereMet())
+}
+
+func TestRelayBatchPublishFailure(t *testing.T) {
+	c := require.New(t)
+
+	conn, err := pgxmock.NewPool()
+	c.NoError(err)
+
+	defer conn.Close()
+
+	rows := conn.NewRows(pendingColumns).
+		AddRow(int64(1), "EVT_1", models.EventTypeTransactionUpdated, models.AggregateTransaction, "TXN_123", `{}`, time.Now())
+
+	conn.ExpectBegin()
+	conn.ExpectQuery("FROM outbox pending").WithArgs(10).WillReturnRows(rows)
+	conn.ExpectExec("UPDATE outbox SET attempts").WithArgs("connection refused", int64(1)).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	conn.ExpectCommit()
+
+	mockSink := MockSink{}
+	mockSink.On("Publish", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+
+	relay := NewRelay(conn, &mockSink, testRelayConfig())
+
+	count, err := relay.RelayBatch(context.Background())
+	c.NoError(err)
+	c.Zero(count)
+	c.NoError(conn.ExpectationsWereMet())
+}
+
+func TestHTTPSink(t *testing.T) {
+	c := require.New(t)
+
+	var (
+		idempotencyKey string
+		received       models.Event
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey = r.Header.Get("Idempotency-Key")
+
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	defer server.Close()
+
+	event := &models.Event{
+		EventID:     "EVT_1",
+		Type:        models.EventTypeTransactionCreated,
+		AggregateID: "TXN_123",
+		Payload:     json.RawMessage(`{"transaction_id":"TXN_123"}`),
+	}
+
+	sink := NewHTTPSink(server.URL, server.Client())
+
+	err := sink.Publish(context.Background(), event)
+	c.NoError(err)
+	c.Equal("EVT_1", idempotencyKey)
+	c.Equal(event.AggregateID, received.AggregateID)
+	c.JSONEq(string(event.Payload), string(received.Payload))
+}
+
+func TestHTTPSinkUnexpectedStatus(t *testing.T) {
+	c := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer server.Close()
+
+	sink := NewHTTPSink(server.URL, server.Client())
+
+	err := sink.Publish(context.Background(), &models.Event{EventID: "EVT_1"})
+	c.ErrorIs(err, ErrUnexpectedStatus)
+}
+
+func TestWriterSink(t *testing.T) {
+	c := require.New(t)
+
+	var buffer bytes.Buffer
+
+	sink := NewWriterSink(&buffer)
+
+	err := sink.Publish(context.Background(), &models.Event{EventID: "EVT_1", Payload: json.RawMessage(`{}`)})
+	c.NoError(err)
+	c.Contains(buffer.String(), `"event_id":"EVT_1"`)
+}
+
+func TestNewSinkUnsupported(t *testing.T) {
+	c := require.New(t)
+
+	_, err := NewSink(config.Relay{Sink: "kafka"})
+	c.ErrorIs(err, ErrUnsupportedSink)
+}
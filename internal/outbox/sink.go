@@ -0,0 +1,78 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+const (
+	// SinkHTTP posts each event as JSON to an HTTP endpoint
+	SinkHTTP = "http"
+	// SinkNATS publishes each event to a NATS subject
+	SinkNATS = "nats"
+	// SinkStdout writes each event as a JSON line to stdout, meant for local development
+	SinkStdout = "stdout"
+)
+
+var (
+	// ErrUnsupportedSink error when the configured sink is unknown
+	ErrUnsupportedSink = errors.New("unsupported outbox sink")
+	// ErrUnexpectedStatus error when the HTTP sink answers with a non-2xx status code
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+)
+
+// Sink destination of the outbox events. Publish must only return once the event was accepted,
+// and consumers should deduplicate events by their ID since they can be delivered more than once
+type Sink interface {
+	Publish(ctx context.Context, event *models.Event) error
+	Close() error
+}
+
+// NewSink creates the sink selected in the configuration
+func NewSink(cfg config.Relay) (Sink, error) {
+	switch cfg.Sink {
+	case SinkHTTP:
+		return NewHTTPSink(cfg.HTTPURL, &http.Client{Timeout: 10 * time.Second}), nil
+	case SinkNATS:
+		return NewNATSSink(cfg.NATSURL, cfg.NATSSubject)
+	case SinkStdout:
+		return NewWriterSink(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSink, cfg.Sink)
+	}
+}
+
+type writerSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewWriterSink constructor of the sink writing each event as a JSON line to w
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Publish writes the event to the underlying writer
+func (s *writerSink) Publish(ctx context.Context, event *models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.encoder.Encode(event)
+}
+
+// Close is a no-op, the writer is owned by the caller
+func (s *writerSink) Close() error {
+	return nil
+}
@@ -0,0 +1,27 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSink mock implementation
+type MockSink struct {
+	mock.Mock
+}
+
+// Publish mocks operation to publish an event
+func (m *MockSink) Publish(ctx context.Context, event *models.Event) error {
+	args := m.Called(ctx, event)
+
+	return args.Error(0)
+}
+
+// Close mocks operation to close the sink
+func (m *MockSink) Close() error {
+	args := m.Called()
+
+	return args.Error(0)
+}
@@ -1,9 +1,23 @@
 package paymentprocessor
 
-import "github.com/aledeltoro/simple-online-payment-platform/internal/models"
+import (
+	"context"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
 
 // PaymentProcessor service to handle interactions with an integrated payment provider
 type PaymentProcessor interface {
-	PerformTransaction(input *models.TransactionInput) (*models.Transaction, error)
-	RefundTransaction(metadata map[string]interface{}) (*models.Transaction, error)
+	PerformTransaction(ctx context.Context, input *models.TransactionInput) (*models.Transaction, error)
+	RefundTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error)
+	// QueryTransaction fetches the current state of a transaction from the provider, given its additional fields
+	QueryTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error)
+	// CapturePayment captures the funds of a payment that was only authorized, given its additional fields
+	CapturePayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error)
+	// CancelPayment releases the funds of a payment that was only authorized, given its additional fields
+	CancelPayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error)
+	// GetPaymentMethod fetches the details of the card behind a payment method without charging it
+	GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethodDetails, error)
+	// ListPayoutItems fetches the charges and refunds settled by a payout of the provider
+	ListPayoutItems(ctx context.Context, providerPayoutID string) ([]*models.PayoutItem, error)
 }
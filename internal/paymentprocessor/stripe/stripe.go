@@ -1,13 +1,18 @@
 package stripe
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"strings"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/tracing"
 	"github.com/oklog/ulid/v2"
 	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/client"
@@ -20,6 +25,8 @@ var (
 	ErrChargeAlreadyRefunded = errors.New("charge already refunded")
 	// ErrMissingChargeID error when missing charge ID
 	ErrMissingChargeID = errors.New("missing charge ID")
+	// ErrMissingPaymentIntentID error when missing payment intent ID
+	ErrMissingPaymentIntentID = errors.New("missing payment intent ID")
 )
 
 type stripeService struct {
@@ -27,21 +34,30 @@ type stripeService struct {
 }
 
 // New initializes implementation of Stripe service
-func New() (paymentprocessor.PaymentProcessor, error) {
-	stripeKey := os.Getenv("STRIPE_SECRET_KEY")
-
-	if stripeKey == "" {
-		return nil, ErrMissingAPIKey
+func New(cfg config.Stripe) (paymentprocessor.PaymentProcessor, error) {
+	err := CheckConfiguration(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	return stripeService{
-		client: client.New(stripeKey, nil),
+		client: client.New(cfg.SecretKey, nil),
 	}, nil
 }
 
+// CheckConfiguration verifies a Stripe secret or restricted key is configured
+func CheckConfiguration(cfg config.Stripe) error {
+	if !strings.HasPrefix(cfg.SecretKey, "sk_") && !strings.HasPrefix(cfg.SecretKey, "rk_") {
+		return ErrMissingAPIKey
+	}
+
+	return nil
+}
+
 // PerformTransaction performs transaction to payment processor
-func (s stripeService) PerformTransaction(input *models.TransactionInput) (*models.Transaction, error) {
+func (s stripeService) PerformTransaction(ctx context.Context, input *models.TransactionInput) (*models.Transaction, error) {
 	transactionID := fmt.Sprintf("TXN_%s", ulid.Make().String())
+	ctx = logging.WithTransactionID(ctx, transactionID)
 
 	params := &stripe.PaymentIntentParams{
 		Amount:        stripe.Int64(input.Amount),
@@ -58,17 +74,52 @@ func (s stripeService) PerformTransaction(input *models.TransactionInput) (*mode
 		},
 	}
 
+	if input.MerchantID != "" {
+		params.Metadata["merchant_id"] = input.MerchantID
+	}
+
+	// destination charge, where Stripe transfers the amount minus the application fee to the connected account
+	if input.DestinationProviderAccountID != "" {
+		params.TransferData = &stripe.PaymentIntentTransferDataParams{
+			Destination: stripe.String(input.DestinationProviderAccountID),
+		}
+		params.ApplicationFeeAmount = stripe.Int64(input.ApplicationFeeAmount)
+	}
+
+	// the card is only authorized, holding the funds until the payment intent is captured or canceled
+	if input.CaptureManually {
+		params.CaptureMethod = stripe.String(string(stripe.PaymentIntentCaptureMethodManual))
+	}
+
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		params.Metadata["request_id"] = requestID
+	}
+
+	tracing.Inject(ctx, params.Metadata)
+
+	// the balance transaction of the charge carries the fee charged by Stripe
+	params.AddExpand("latest_charge.balance_transaction")
+
+	params.Context = ctx
+
 	var stripeErr *stripe.Error
 
 	result, err := s.client.PaymentIntents.New(params)
 	if err != nil {
-		if errors.As(err, &stripeErr) && stripeErr.Type == stripe.ErrorTypeCard {
+		if errors.As(err, &stripeErr) && stripeErr.Type == stripe.ErrorTypeCard && stripeErr.PaymentIntent != nil {
+			slog.WarnContext(ctx, "stripe declined payment intent", slog.String("failure_reason", string(stripeErr.Code)))
+
 			return parseFailedTransaction(stripeErr, transactionID), nil
 		}
 
-		return nil, api.NewInternalServerError(fmt.Errorf("performing transaction: %s", stripeErr.Code))
+		slog.ErrorContext(ctx, "create stripe payment intent failed", slog.Any("error", err))
+
+		// canceled or timed out requests fail without a Stripe error
+		return nil, api.NewInternalServerError(fmt.Errorf("performing transaction: %w", err))
 	}
 
+	slog.InfoContext(ctx, "stripe payment intent created", slog.String("payment_intent_id", result.ID))
+
 	transaction := &models.Transaction{
 		TransactionID: transactionID,
 		Status:        models.TransactionStatusPending,
@@ -78,14 +129,36 @@ func (s stripeService) PerformTransaction(input *models.TransactionInput) (*mode
 		Currency:      string(result.Currency),
 		Type:          models.TransactionTypeCharge,
 		AdditionalFields: map[string]interface{}{
-			"charge_id":         result.LatestCharge.ID,
 			"payment_intent_id": result.ID,
 		},
 	}
 
+	if result.Status == stripe.PaymentIntentStatusRequiresCapture {
+		transaction.Status = models.TransactionStatusReview
+	}
+
+	if result.LatestCharge != nil {
+		transaction.AdditionalFields["charge_id"] = result.LatestCharge.ID
+		transaction.ProviderFee, transaction.FeeCurrency = balanceTransactionFee(result.LatestCharge.BalanceTransaction)
+
+		if result.LatestCharge.Transfer != nil {
+			transaction.AdditionalFields["transfer_id"] = result.LatestCharge.Transfer.ID
+		}
+	}
+
 	return transaction, nil
 }
 
+// balanceTransactionFee returns the fee charged by Stripe on a balance transaction, which isn't available until
+// the funds are captured
+func balanceTransactionFee(balanceTransaction *stripe.BalanceTransaction) (int, string) {
+	if balanceTransaction == nil {
+		return 0, ""
+	}
+
+	return int(balanceTransaction.Fee), string(balanceTransaction.Currency)
+}
+
 func parseFailedTransaction(stripeErr *stripe.Error, transactionID string) *models.Transaction {
 	transaction := &models.Transaction{
 		TransactionID: transactionID,
@@ -97,16 +170,19 @@ func parseFailedTransaction(stripeErr *stripe.Error, transactionID string) *mode
 		Currency:      string(stripeErr.PaymentIntent.Currency),
 		Type:          models.TransactionTypeCharge,
 		AdditionalFields: map[string]interface{}{
-			"charge_id":         stripeErr.PaymentIntent.LatestCharge.ID,
 			"payment_intent_id": stripeErr.PaymentIntent.ID,
 		},
 	}
 
+	if stripeErr.PaymentIntent.LatestCharge != nil {
+		transaction.AdditionalFields["charge_id"] = stripeErr.PaymentIntent.LatestCharge.ID
+	}
+
 	return transaction
 }
 
 // RefundTransaction performs refund to payment processor
-func (s stripeService) RefundTransaction(metadata map[string]interface{}) (*models.Transaction, error) {
+func (s stripeService) RefundTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
 	chargeID, ok := metadata["charge_id"].(string)
 	if !ok {
 		return nil, ErrMissingChargeID
@@ -116,6 +192,26 @@ func (s stripeService) RefundTransaction(metadata map[string]interface{}) (*mode
 		Charge: stripe.String(chargeID),
 	}
 
+	// the transfer to the connected account is reversed in proportion to the refunded amount
+	if _, ok := metadata["transfer_id"].(string); ok {
+		params.ReverseTransfer = stripe.Bool(true)
+	}
+
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		params.AddMetadata("request_id", requestID)
+	}
+
+	carrier := map[string]string{}
+	tracing.Inject(ctx, carrier)
+
+	for key, value := range carrier {
+		params.AddMetadata(key, value)
+	}
+
+	params.AddExpand("balance_transaction")
+
+	params.Context = ctx
+
 	var stripeErr *stripe.Error
 
 	result, err := s.client.Refunds.New(params)
@@ -124,9 +220,13 @@ func (s stripeService) RefundTransaction(metadata map[string]interface{}) (*mode
 			return nil, api.NewInvalidRequestError(ErrChargeAlreadyRefunded)
 		}
 
+		slog.ErrorContext(ctx, "create stripe refund failed", slog.Any("error", err))
+
 		return nil, api.NewInternalServerError(fmt.Errorf("performing refund: %w", err))
 	}
 
+	slog.InfoContext(ctx, "stripe refund created", slog.String("refund_id", result.ID))
+
 	transaction := &models.Transaction{
 		Status: models.TransactionStatusPending,
 		Type:   models.TransactionTypeRefund,
@@ -137,5 +237,206 @@ func (s stripeService) RefundTransaction(metadata map[string]interface{}) (*mode
 		},
 	}
 
+	transaction.ProviderFee, transaction.FeeCurrency = balanceTransactionFee(result.BalanceTransaction)
+
+	if result.TransferReversal != nil {
+		transaction.AdditionalFields["transfer_reversal_id"] = result.TransferReversal.ID
+	}
+
 	return transaction, nil
 }
+
+// QueryTransaction fetches the payment intent of a transaction along with its latest charge
+func (s stripeService) QueryTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	paymentIntentID, ok := metadata["payment_intent_id"].(string)
+	if !ok || paymentIntentID == "" {
+		return nil, ErrMissingPaymentIntentID
+	}
+
+	params := &stripe.PaymentIntentParams{}
+	params.AddExpand("latest_charge")
+	params.Context = ctx
+
+	result, err := s.client.PaymentIntents.Get(paymentIntentID, params)
+	if err != nil {
+		slog.ErrorContext(ctx, "retrieve stripe payment intent failed", slog.String("payment_intent_id", paymentIntentID), slog.Any("error", err))
+
+		return nil, api.NewInternalServerError(fmt.Errorf("querying transaction: %w", err))
+	}
+
+	return parsePaymentIntent(result), nil
+}
+
+// CapturePayment captures the funds of an authorized payment intent, returning its status along with the fee charged
+// by Stripe
+func (s stripeService) CapturePayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	paymentIntentID, ok := metadata["payment_intent_id"].(string)
+	if !ok || paymentIntentID == "" {
+		return nil, ErrMissingPaymentIntentID
+	}
+
+	params := &stripe.PaymentIntentCaptureParams{}
+	params.AddExpand("latest_charge.balance_transaction")
+	params.Context = ctx
+
+	result, err := s.client.PaymentIntents.Capture(paymentIntentID, params)
+	if err != nil {
+		slog.ErrorContext(ctx, "capture stripe payment intent failed", slog.String("payment_intent_id", paymentIntentID), slog.Any("error", err))
+
+		return nil, api.NewInternalServerError(fmt.Errorf("capturing payment: %w", err))
+	}
+
+	slog.InfoContext(ctx, "stripe payment intent captured", slog.String("payment_intent_id", result.ID))
+
+	transaction := parsePaymentIntent(result)
+
+	if result.LatestCharge != nil {
+		transaction.ProviderFee, transaction.FeeCurrency = balanceTransactionFee(result.LatestCharge.BalanceTransaction)
+
+		// Stripe only transfers the funds of a destination charge once they are captured
+		if result.LatestCharge.Transfer != nil {
+			transaction.AdditionalFields["transfer_id"] = result.LatestCharge.Transfer.ID
+		}
+	}
+
+	return transaction, nil
+}
+
+// CancelPayment cancels an authorized payment intent, releasing the funds held on the card
+func (s stripeService) CancelPayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	paymentIntentID, ok := metadata["payment_intent_id"].(string)
+	if !ok || paymentIntentID == "" {
+		return nil, ErrMissingPaymentIntentID
+	}
+
+	params := &stripe.PaymentIntentCancelParams{}
+	params.Context = ctx
+
+	result, err := s.client.PaymentIntents.Cancel(paymentIntentID, params)
+	if err != nil {
+		slog.ErrorContext(ctx, "cancel stripe payment intent failed", slog.String("payment_intent_id", paymentIntentID), slog.Any("error", err))
+
+		return nil, api.NewInternalServerError(fmt.Errorf("canceling payment: %w", err))
+	}
+
+	slog.InfoContext(ctx, "stripe payment intent canceled", slog.String("payment_intent_id", result.ID))
+
+	return parsePaymentIntent(result), nil
+}
+
+// GetPaymentMethod retrieves a payment method to read the fingerprint and BIN of its card. Payment methods unknown to
+// Stripe are returned without card details, leaving the charge to report them
+func (s stripeService) GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethodDetails, error) {
+	params := &stripe.PaymentMethodParams{}
+	params.Context = ctx
+
+	details := &models.PaymentMethodDetails{
+		PaymentMethodID: paymentMethodID,
+	}
+
+	result, err := s.client.PaymentMethods.Get(paymentMethodID, params)
+	if err != nil {
+		var stripeErr *stripe.Error
+		if errors.As(err, &stripeErr) && stripeErr.Code == stripe.ErrorCodeResourceMissing {
+			return details, nil
+		}
+
+		slog.ErrorContext(ctx, "retrieve stripe payment method failed", slog.Any("error", err))
+
+		return nil, api.NewInternalServerError(fmt.Errorf("retrieving payment method: %w", err))
+	}
+
+	if result.Card != nil {
+		details.Fingerprint = result.Card.Fingerprint
+		details.BIN = result.Card.IIN
+	}
+
+	return details, nil
+}
+
+// ListPayoutItems lists the balance transactions of a payout coming from charges and refunds
+func (s stripeService) ListPayoutItems(ctx context.Context, providerPayoutID string) ([]*models.PayoutItem, error) {
+	params := &stripe.BalanceTransactionListParams{
+		Payout: stripe.String(providerPayoutID),
+	}
+	params.Context = ctx
+
+	items := []*models.PayoutItem{}
+
+	i := s.client.BalanceTransactions.List(params)
+	for i.Next() {
+		balanceTransaction := i.BalanceTransaction()
+
+		if balanceTransaction.Source == nil || !isPayoutItemType(balanceTransaction.Type) {
+			continue
+		}
+
+		items = append(items, &models.PayoutItem{
+			ProviderBalanceTransactionID: balanceTransaction.ID,
+			ProviderSourceID:             balanceTransaction.Source.ID,
+			Amount:                       int(balanceTransaction.Amount),
+			Fee:                          int(balanceTransaction.Fee),
+			Net:                          int(balanceTransaction.Net),
+			Currency:                     string(balanceTransaction.Currency),
+		})
+	}
+
+	err := i.Err()
+	if err != nil {
+		slog.ErrorContext(ctx, "list stripe balance transactions failed", slog.String("payout_id", providerPayoutID), slog.Any("error", err))
+
+		return nil, api.NewInternalServerError(fmt.Errorf("listing payout items: %w", err))
+	}
+
+	return items, nil
+}
+
+// isPayoutItemType reports whether the balance transaction type comes from a charge or a refund
+func isPayoutItemType(balanceTransactionType stripe.BalanceTransactionType) bool {
+	switch balanceTransactionType {
+	case stripe.BalanceTransactionTypeCharge, stripe.BalanceTransactionTypePayment,
+		stripe.BalanceTransactionTypeRefund, stripe.BalanceTransactionTypePaymentRefund:
+		return true
+	}
+
+	return false
+}
+
+// parsePaymentIntent maps the state of a payment intent to the status and type of its transaction
+func parsePaymentIntent(paymentIntent *stripe.PaymentIntent) *models.Transaction {
+	transaction := &models.Transaction{
+		Status:      models.TransactionStatusPending,
+		Description: paymentIntent.Description,
+		Provider:    models.PaymentProviderStripe,
+		Amount:      int(paymentIntent.Amount),
+		Currency:    string(paymentIntent.Currency),
+		Type:        models.TransactionTypeCharge,
+		AdditionalFields: map[string]interface{}{
+			"payment_intent_id": paymentIntent.ID,
+		},
+	}
+
+	switch paymentIntent.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		transaction.Status = models.TransactionStatusSucceeded
+	case stripe.PaymentIntentStatusRequiresCapture:
+		transaction.Status = models.TransactionStatusReview
+	case stripe.PaymentIntentStatusCanceled, stripe.PaymentIntentStatusRequiresPaymentMethod:
+		transaction.Status = models.TransactionStatusFailure
+
+		if paymentIntent.LastPaymentError != nil {
+			transaction.FailureReason = string(paymentIntent.LastPaymentError.Code)
+		}
+	}
+
+	if paymentIntent.LatestCharge != nil {
+		transaction.AdditionalFields["charge_id"] = paymentIntent.LatestCharge.ID
+
+		if paymentIntent.LatestCharge.Refunded {
+			transaction.Type = models.TransactionTypeRefund
+			transaction.Status = models.TransactionStatusSucceeded
+		}
+	}
+
+	return transaction
+}
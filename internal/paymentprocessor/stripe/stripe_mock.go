@@ -2,6 +2,7 @@ package stripe
 
 import (
 	"bytes"
+	"context"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/stretchr/testify/mock"
@@ -15,8 +16,8 @@ type MockStripe struct {
 }
 
 // PerformTransaction mock implementation
-func (m *MockStripe) PerformTransaction(input *models.TransactionInput) (*models.Transaction, error) {
-	args := m.Called(input)
+func (m *MockStripe) PerformTransaction(ctx context.Context, input *models.TransactionInput) (*models.Transaction, error) {
+	args := m.Called(ctx, input)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -26,8 +27,8 @@ func (m *MockStripe) PerformTransaction(input *models.TransactionInput) (*models
 }
 
 // QueryTransaction mock implementation
-func (m *MockStripe) QueryTransaction(id string) (*models.Transaction, error) {
-	args := m.Called(id)
+func (m *MockStripe) QueryTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	args := m.Called(ctx, metadata)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -37,8 +38,8 @@ func (m *MockStripe) QueryTransaction(id string) (*models.Transaction, error) {
 }
 
 // RefundTransaction mock implementation
-func (m *MockStripe) RefundTransaction(metadata map[string]interface{}) (*models.Transaction, error) {
-	args := m.Called(metadata)
+func (m *MockStripe) RefundTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	args := m.Called(ctx, metadata)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -47,6 +48,50 @@ func (m *MockStripe) RefundTransaction(metadata map[string]interface{}) (*models
 	return args.Get(0).(*models.Transaction), args.Error(1)
 }
 
+// CapturePayment mock implementation
+func (m *MockStripe) CapturePayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	args := m.Called(ctx, metadata)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.Transaction), args.Error(1)
+}
+
+// CancelPayment mock implementation
+func (m *MockStripe) CancelPayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	args := m.Called(ctx, metadata)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.Transaction), args.Error(1)
+}
+
+// GetPaymentMethod mock implementation
+func (m *MockStripe) GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethodDetails, error) {
+	args := m.Called(ctx, paymentMethodID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.PaymentMethodDetails), args.Error(1)
+}
+
+// ListPayoutItems mock implementation
+func (m *MockStripe) ListPayoutItems(ctx context.Context, providerPayoutID string) ([]*models.PayoutItem, error) {
+	args := m.Called(ctx, providerPayoutID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.PayoutItem), args.Error(1)
+}
+
 // mockStripeBackend mock for Stripe Backend interface
 type mockStripeBackend struct {
 	mock.Mock
@@ -1,8 +1,10 @@
 package stripe
 
 import (
+	"context"
 	"testing"
 
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -13,9 +15,7 @@ import (
 func TestNew(t *testing.T) {
 	c := require.New(t)
 
-	t.Setenv("STRIPE_SECRET_KEY", "secret_key")
-
-	stripeService, err := New()
+	stripeService, err := New(config.Stripe{SecretKey: "sk_test_123"})
 	c.NoError(err)
 	c.IsType(stripeService, stripeService)
 }
@@ -23,11 +23,20 @@ func TestNew(t *testing.T) {
 func TestNewErrMissingAPIKey(t *testing.T) {
 	c := require.New(t)
 
-	stripeService, err := New()
+	stripeService, err := New(config.Stripe{})
 	c.Nil(stripeService)
 	c.ErrorIs(err, ErrMissingAPIKey)
 }
 
+func TestCheckConfiguration(t *testing.T) {
+	c := require.New(t)
+
+	c.ErrorIs(CheckConfiguration(config.Stripe{}), ErrMissingAPIKey)
+	c.ErrorIs(CheckConfiguration(config.Stripe{SecretKey: "pk_test_123"}), ErrMissingAPIKey)
+	c.NoError(CheckConfiguration(config.Stripe{SecretKey: "rk_test_123"}))
+	c.NoError(CheckConfiguration(config.Stripe{SecretKey: "sk_test_123"}))
+}
+
 func TestPerformTransaction(t *testing.T) {
 	c := require.New(t)
 
@@ -42,6 +51,8 @@ func TestPerformTransaction(t *testing.T) {
 			"charge_id":         "charge_id",
 			"payment_intent_id": "payment_intent_id",
 		},
+		ProviderFee: 88,
+		FeeCurrency: "usd",
 	}
 
 	expectedInput := &models.TransactionInput{
@@ -68,6 +79,10 @@ func TestPerformTransaction(t *testing.T) {
 			Currency:    stripe.Currency(expectedTransaction.Currency),
 			LatestCharge: &stripe.Charge{
 				ID: "charge_id",
+				BalanceTransaction: &stripe.BalanceTransaction{
+					Fee:      88,
+					Currency: "usd",
+				},
 			},
 		}
 	}).Return(nil)
@@ -78,7 +93,7 @@ func TestPerformTransaction(t *testing.T) {
 		client: mockStripeClient,
 	}
 
-	transaction, err := service.PerformTransaction(expectedInput)
+	transaction, err := service.PerformTransaction(context.Background(), expectedInput)
 	c.NoError(err)
 
 	transaction.TransactionID = ""
@@ -86,6 +101,93 @@ func TestPerformTransaction(t *testing.T) {
 	c.Equal(expectedTransaction, transaction)
 }
 
+func TestPerformTransactionDestinationCharge(t *testing.T) {
+	c := require.New(t)
+
+	input := &models.TransactionInput{
+		Amount:                       2000,
+		Currency:                     "usd",
+		PaymentMethod:                "pm_card_visa",
+		Description:                  "Testing stripe service",
+		TransferDestination:          "ACC_123",
+		ApplicationFeeAmount:         200,
+		DestinationProviderAccountID: "acct_123",
+	}
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(func(params *stripe.PaymentIntentParams) bool {
+		return *params.TransferData.Destination == "acct_123" && *params.ApplicationFeeAmount == 200
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		mockPaymentIntentResult := args.Get(4).(*stripe.PaymentIntent)
+
+		*mockPaymentIntentResult = stripe.PaymentIntent{
+			ID:       "payment_intent_id",
+			Amount:   2000,
+			Currency: "usd",
+			LatestCharge: &stripe.Charge{
+				ID:       "charge_id",
+				Transfer: &stripe.Transfer{ID: "tr_123"},
+			},
+		}
+	}).Return(nil)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	transaction, err := service.PerformTransaction(context.Background(), input)
+	c.NoError(err)
+	c.Equal("tr_123", transaction.AdditionalFields["transfer_id"])
+}
+
+func TestPerformTransactionCaptureManually(t *testing.T) {
+	c := require.New(t)
+
+	input := &models.TransactionInput{
+		Amount:          2000,
+		Currency:        "usd",
+		PaymentMethod:   "pm_card_visa",
+		Description:     "Testing stripe service",
+		CaptureManually: true,
+	}
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(func(params *stripe.PaymentIntentParams) bool {
+		return *params.CaptureMethod == "manual"
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		mockPaymentIntentResult := args.Get(4).(*stripe.PaymentIntent)
+
+		*mockPaymentIntentResult = stripe.PaymentIntent{
+			ID:           "payment_intent_id",
+			Status:       stripe.PaymentIntentStatusRequiresCapture,
+			Amount:       2000,
+			Currency:     "usd",
+			LatestCharge: &stripe.Charge{ID: "charge_id"},
+		}
+	}).Return(nil)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	transaction, err := service.PerformTransaction(context.Background(), input)
+	c.NoError(err)
+	c.Equal(models.TransactionStatusReview, transaction.Status)
+	c.Zero(transaction.ProviderFee)
+}
+
 func TestPerformTransactionCardError(t *testing.T) {
 	c := require.New(t)
 
@@ -141,12 +243,55 @@ func TestPerformTransactionCardError(t *testing.T) {
 		client: mockStripeClient,
 	}
 
-	transaction, err := service.PerformTransaction(expectedInput)
+	transaction, err := service.PerformTransaction(context.Background(), expectedInput)
 	c.NoError(err)
 	c.Equal(expectedTransaction.Status, transaction.Status)
 	c.Equal(expectedTransaction.FailureReason, transaction.FailureReason)
 }
 
+func TestPerformTransactionCanceled(t *testing.T) {
+	c := require.New(t)
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(context.Canceled)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	_, err := service.PerformTransaction(context.Background(), &models.TransactionInput{Amount: 2000, Currency: "usd", PaymentMethod: "pm_card_visa"})
+	c.ErrorIs(err, context.Canceled, "errors other than Stripe errors are wrapped")
+}
+
+func TestPerformTransactionWithoutCharge(t *testing.T) {
+	c := require.New(t)
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(4).(*stripe.PaymentIntent) = stripe.PaymentIntent{ID: "payment_intent_id", Amount: 2000, Currency: "usd"}
+	}).Return(nil)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	transaction, err := service.PerformTransaction(context.Background(), &models.TransactionInput{Amount: 2000, Currency: "usd", PaymentMethod: "pm_card_visa"})
+	c.NoError(err)
+	c.Equal(map[string]interface{}{"payment_intent_id": "payment_intent_id"}, transaction.AdditionalFields)
+}
+
 func TestRefundTransaction(t *testing.T) {
 	c := require.New(t)
 
@@ -158,6 +303,8 @@ func TestRefundTransaction(t *testing.T) {
 			"payment_intent_id": "payment_intent_id",
 			"refund_id":         "refund_id",
 		},
+		ProviderFee: 15,
+		FeeCurrency: "usd",
 	}
 
 	stripeBackendMock := new(mockStripeBackend)
@@ -178,6 +325,10 @@ func TestRefundTransaction(t *testing.T) {
 			PaymentIntent: &stripe.PaymentIntent{
 				ID: "payment_intent_id",
 			},
+			BalanceTransaction: &stripe.BalanceTransaction{
+				Fee:      15,
+				Currency: "usd",
+			},
 		}
 	}).Return(nil)
 
@@ -187,11 +338,46 @@ func TestRefundTransaction(t *testing.T) {
 		client: mockStripeClient,
 	}
 
-	updatedTransaction, err := service.RefundTransaction(expectedTransaction.AdditionalFields)
+	updatedTransaction, err := service.RefundTransaction(context.Background(), expectedTransaction.AdditionalFields)
 	c.NoError(err)
 	c.Equal(expectedTransaction, updatedTransaction)
 }
 
+func TestRefundTransactionReverseTransfer(t *testing.T) {
+	c := require.New(t)
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(func(params *stripe.RefundParams) bool {
+		return params.ReverseTransfer != nil && *params.ReverseTransfer
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		mockRefund := args.Get(4).(*stripe.Refund)
+
+		*mockRefund = stripe.Refund{
+			ID:               "refund_id",
+			Charge:           &stripe.Charge{ID: "charge_id"},
+			PaymentIntent:    &stripe.PaymentIntent{ID: "payment_intent_id"},
+			TransferReversal: &stripe.TransferReversal{ID: "trr_123"},
+		}
+	}).Return(nil)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	transaction, err := service.RefundTransaction(context.Background(), map[string]interface{}{
+		"charge_id":   "charge_id",
+		"transfer_id": "tr_123",
+	})
+	c.NoError(err)
+	c.Equal("trr_123", transaction.AdditionalFields["transfer_reversal_id"])
+}
+
 func TestRefundTransactionAlreadyRefunded(t *testing.T) {
 	c := require.New(t)
 
@@ -225,7 +411,7 @@ func TestRefundTransactionAlreadyRefunded(t *testing.T) {
 		client: mockStripeClient,
 	}
 
-	updatedTransaction, err := service.RefundTransaction(expectedTransaction.AdditionalFields)
+	updatedTransaction, err := service.RefundTransaction(context.Background(), expectedTransaction.AdditionalFields)
 	c.Nil(updatedTransaction)
 	c.ErrorIs(err, ErrChargeAlreadyRefunded)
 }
@@ -235,6 +421,267 @@ func TestRefundTransactionMissingChargeID(t *testing.T) {
 
 	service := stripeService{}
 
-	_, err := service.RefundTransaction(map[string]interface{}{})
+	_, err := service.RefundTransaction(context.Background(), map[string]interface{}{})
 	c.ErrorIs(err, ErrMissingChargeID)
 }
+
+func TestQueryTransactionRefunded(t *testing.T) {
+	c := require.New(t)
+
+	expectedTransaction := &models.Transaction{
+		Status:      models.TransactionStatusSucceeded,
+		Description: "Testing stripe service",
+		Provider:    models.PaymentProviderStripe,
+		Amount:      2000,
+		Currency:    "usd",
+		Type:        models.TransactionTypeRefund,
+		AdditionalFields: map[string]interface{}{
+			"charge_id":         "charge_id",
+			"payment_intent_id": "payment_intent_id",
+		},
+	}
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", "GET", "/v1/payment_intents/payment_intent_id", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mockPaymentIntentResult := args.Get(4).(*stripe.PaymentIntent)
+
+		*mockPaymentIntentResult = stripe.PaymentIntent{
+			ID:          "payment_intent_id",
+			Status:      stripe.PaymentIntentStatusSucceeded,
+			Description: expectedTransaction.Description,
+			Amount:      int64(expectedTransaction.Amount),
+			Currency:    stripe.Currency(expectedTransaction.Currency),
+			LatestCharge: &stripe.Charge{
+				ID:       "charge_id",
+				Refunded: true,
+			},
+		}
+	}).Return(nil)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	transaction, err := service.QueryTransaction(context.Background(), map[string]interface{}{"payment_intent_id": "payment_intent_id"})
+	c.NoError(err)
+	c.Equal(expectedTransaction, transaction)
+}
+
+func TestQueryTransactionFailed(t *testing.T) {
+	c := require.New(t)
+
+	transaction := parsePaymentIntent(&stripe.PaymentIntent{
+		ID:     "payment_intent_id",
+		Status: stripe.PaymentIntentStatusRequiresPaymentMethod,
+		LastPaymentError: &stripe.Error{
+			Code: stripe.ErrorCodeCardDeclined,
+		},
+	})
+
+	c.Equal(models.TransactionStatusFailure, transaction.Status)
+	c.Equal(models.TransactionTypeCharge, transaction.Type)
+	c.Equal("card_declined", transaction.FailureReason)
+}
+
+func TestQueryTransactionMissingPaymentIntentID(t *testing.T) {
+	c := require.New(t)
+
+	service := stripeService{}
+
+	_, err := service.QueryTransaction(context.Background(), map[string]interface{}{"charge_id": "charge_id"})
+	c.ErrorIs(err, ErrMissingPaymentIntentID)
+}
+
+func TestCapturePayment(t *testing.T) {
+	c := require.New(t)
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", "POST", "/v1/payment_intents/payment_intent_id/capture", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mockPaymentIntentResult := args.Get(4).(*stripe.PaymentIntent)
+
+		*mockPaymentIntentResult = stripe.PaymentIntent{
+			ID:       "payment_intent_id",
+			Status:   stripe.PaymentIntentStatusSucceeded,
+			Amount:   2000,
+			Currency: "usd",
+			LatestCharge: &stripe.Charge{
+				ID:                 "charge_id",
+				BalanceTransaction: &stripe.BalanceTransaction{Fee: 88, Currency: "usd"},
+			},
+		}
+	}).Return(nil)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	transaction, err := service.CapturePayment(context.Background(), map[string]interface{}{"payment_intent_id": "payment_intent_id"})
+	c.NoError(err)
+	c.Equal(models.TransactionStatusSucceeded, transaction.Status)
+	c.Equal(88, transaction.ProviderFee)
+	c.Equal("usd", transaction.FeeCurrency)
+}
+
+func TestCancelPayment(t *testing.T) {
+	c := require.New(t)
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", "POST", "/v1/payment_intents/payment_intent_id/cancel", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mockPaymentIntentResult := args.Get(4).(*stripe.PaymentIntent)
+
+		*mockPaymentIntentResult = stripe.PaymentIntent{
+			ID:       "payment_intent_id",
+			Status:   stripe.PaymentIntentStatusCanceled,
+			Amount:   2000,
+			Currency: "usd",
+		}
+	}).Return(nil)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	transaction, err := service.CancelPayment(context.Background(), map[string]interface{}{"payment_intent_id": "payment_intent_id"})
+	c.NoError(err)
+	c.Equal(models.TransactionStatusFailure, transaction.Status)
+}
+
+func TestCancelPaymentMissingPaymentIntentID(t *testing.T) {
+	c := require.New(t)
+
+	service := stripeService{}
+
+	_, err := service.CancelPayment(context.Background(), map[string]interface{}{})
+	c.ErrorIs(err, ErrMissingPaymentIntentID)
+}
+
+func TestGetPaymentMethod(t *testing.T) {
+	c := require.New(t)
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", "GET", "/v1/payment_methods/pm_card_visa", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mockPaymentMethodResult := args.Get(4).(*stripe.PaymentMethod)
+
+		*mockPaymentMethodResult = stripe.PaymentMethod{
+			ID: "pm_card_visa",
+			Card: &stripe.PaymentMethodCard{
+				Fingerprint: "fp_123",
+				IIN:         "42424242",
+			},
+		}
+	}).Return(nil)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	details, err := service.GetPaymentMethod(context.Background(), "pm_card_visa")
+	c.NoError(err)
+	c.Equal("fp_123", details.Fingerprint)
+	c.Equal("42424242", details.BIN)
+}
+
+func TestGetPaymentMethodMissing(t *testing.T) {
+	c := require.New(t)
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("Call", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&stripe.Error{
+		Type: stripe.ErrorTypeInvalidRequest,
+		Code: stripe.ErrorCodeResourceMissing,
+	})
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	details, err := service.GetPaymentMethod(context.Background(), "pm_unknown")
+	c.NoError(err)
+	c.Equal("pm_unknown", details.PaymentMethodID)
+	c.Empty(details.Fingerprint)
+}
+
+func TestListPayoutItems(t *testing.T) {
+	c := require.New(t)
+
+	stripeBackendMock := new(mockStripeBackend)
+	stripeTestBackends := &stripe.Backends{
+		API:     stripeBackendMock,
+		Connect: stripeBackendMock,
+		Uploads: stripeBackendMock,
+	}
+
+	stripeBackendMock.On("CallRaw", "GET", "/v1/balance_transactions", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mockListResult := args.Get(4).(*stripe.BalanceTransactionList)
+
+		*mockListResult = stripe.BalanceTransactionList{
+			Data: []*stripe.BalanceTransaction{
+				{
+					ID:       "txn_charge",
+					Type:     stripe.BalanceTransactionTypeCharge,
+					Amount:   2000,
+					Fee:      88,
+					Net:      1912,
+					Currency: stripe.CurrencyUSD,
+					Source:   &stripe.BalanceTransactionSource{ID: "charge_id"},
+				},
+				{
+					ID:       "txn_refund",
+					Type:     stripe.BalanceTransactionTypeRefund,
+					Amount:   -2000,
+					Net:      -2000,
+					Currency: stripe.CurrencyUSD,
+					Source:   &stripe.BalanceTransactionSource{ID: "refund_id"},
+				},
+				{
+					ID:       "txn_payout",
+					Type:     stripe.BalanceTransactionTypePayout,
+					Amount:   -1912,
+					Net:      -1912,
+					Currency: stripe.CurrencyUSD,
+					Source:   &stripe.BalanceTransactionSource{ID: "po_123"},
+				},
+			},
+		}
+	}).Return(nil)
+
+	service := stripeService{
+		client: client.New("sk_test", stripeTestBackends),
+	}
+
+	items, err := service.ListPayoutItems(context.Background(), "po_123")
+	c.NoError(err)
+	c.Equal([]*models.PayoutItem{
+		{ProviderBalanceTransactionID: "txn_charge", ProviderSourceID: "charge_id", Amount: 2000, Fee: 88, Net: 1912, Currency: "usd"},
+		{ProviderBalanceTransactionID: "txn_refund", ProviderSourceID: "refund_id", Amount: -2000, Net: -2000, Currency: "usd"},
+	}, items)
+}
@@ -0,0 +1,50 @@
+package pricing
+
+import (
+	"math"
+	"strings"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+)
+
+// Pricing computes the fees the platform charges merchants. The zero value charges no fees
+type Pricing struct {
+	cfg config.Pricing
+}
+
+// New constructor for the pricing configured for the platform
+func New(cfg config.Pricing) Pricing {
+	return Pricing{
+		cfg: cfg,
+	}
+}
+
+// ApplicationFee returns the fee charged to the merchant for a charge of the given amount, in the currency minor
+// unit. The merchant plan takes precedence over the platform one, and a currency fee over the plan default
+func (p Pricing) ApplicationFee(merchantID string, amount int, currency string) int {
+	plan, ok := p.cfg.Merchants[merchantID]
+	if !ok {
+		plan = p.cfg.PricingPlan
+	}
+
+	fee := planFee(plan, currency)
+
+	applicationFee := int(math.Round(float64(amount)*fee.Percentage/100)) + fee.Fixed
+
+	// the fee can't take more than the charged amount
+	if applicationFee > amount {
+		return amount
+	}
+
+	return applicationFee
+}
+
+func planFee(plan config.PricingPlan, currency string) config.Fee {
+	for planCurrency, fee := range plan.Currencies {
+		if strings.EqualFold(planCurrency, currency) {
+			return fee
+		}
+	}
+
+	return plan.Default
+}
@@ -0,0 +1,35 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplicationFee(t *testing.T) {
+	c := require.New(t)
+
+	pricing := New(config.Pricing{
+		PricingPlan: config.PricingPlan{
+			Default: config.Fee{Percentage: 2.9, Fixed: 30},
+			Currencies: map[string]config.Fee{
+				"jpy": {Percentage: 3.6},
+			},
+		},
+		Merchants: map[string]config.PricingPlan{
+			"MCH_VIP": {Default: config.Fee{Percentage: 1.5}},
+		},
+	})
+
+	c.Equal(88, pricing.ApplicationFee("MCH_123", 2000, "usd"))
+	c.Equal(72, pricing.ApplicationFee("MCH_123", 2000, "JPY"))
+	c.Equal(30, pricing.ApplicationFee("MCH_VIP", 2000, "usd"))
+	c.Equal(20, pricing.ApplicationFee("MCH_123", 20, "usd"))
+}
+
+func TestApplicationFeeZeroValue(t *testing.T) {
+	c := require.New(t)
+
+	c.Equal(0, Pricing{}.ApplicationFee("MCH_123", 2000, "usd"))
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+)
+
+// ErrRateLimitExceeded error when a client exceeded its request quota
+var ErrRateLimitExceeded = errors.New("rate limit exceeded")
+
+// Middleware throttles requests per merchant, so rotating an API key doesn't reset its quota, or per client IP for
+// requests without an API key, applying separate limits to writes and reads
+func Middleware(store Store, writeLimit, readLimit Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit, scope := readLimit, "read"
+			if isWrite(r.Method) {
+				limit, scope = writeLimit, "write"
+			}
+
+			if !take(w, r, store, clientKey(r)+":"+scope, limit) {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IPMiddleware throttles every request per client IP whatever its method. It runs before authentication, so clients
+// sending invalid API keys are throttled too
+func IPMiddleware(store Store, limit Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !take(w, r, store, "ip:"+clientIP(r)+":all", limit) {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// take consumes a token of the bucket, writing the rate limit headers, and answers the request when it is throttled
+func take(w http.ResponseWriter, r *http.Request, store Store, key string, limit Limit) bool {
+	result, err := store.Take(r.Context(), key, limit)
+	if err != nil {
+		slog.WarnContext(r.Context(), "rate limiter store failed, allowing request", slog.Any("error", err))
+		return true
+	}
+
+	header := w.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", seconds(result.ResetAfter))
+
+	if !result.Allowed {
+		header.Set("Retry-After", seconds(result.RetryAfter))
+		api.WriteErrorResponse(w, api.NewRateLimitedError(ErrRateLimitExceeded))
+
+		return false
+	}
+
+	return true
+}
+
+func clientKey(r *http.Request) string {
+	if key, ok := auth.APIKeyFromContext(r.Context()); ok {
+		return "merchant:" + key.MerchantID
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
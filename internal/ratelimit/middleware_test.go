@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	return Result{}, errors.New("store unavailable")
+}
+
+func TestMiddleware(t *testing.T) {
+	c := require.New(t)
+
+	writeLimit := Limit{Requests: 1, Period: time.Minute}
+	readLimit := Limit{Requests: 2, Period: time.Minute}
+
+	handler := Middleware(NewMemoryStore(), writeLimit, readLimit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	key := &models.APIKey{KeyID: "KEY_123"}
+
+	newRequest := func(method string) *http.Request {
+		req := httptest.NewRequest(method, "/payments", nil)
+
+		return req.WithContext(auth.WithAPIKey(req.Context(), key))
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest(http.MethodPost))
+
+	response := recorder.Result()
+	c.Equal(http.StatusOK, response.StatusCode)
+	c.Equal("1", response.Header.Get("RateLimit-Limit"))
+	c.Equal("0", response.Header.Get("RateLimit-Remaining"))
+	c.Equal("60", response.Header.Get("RateLimit-Reset"))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest(http.MethodPost))
+
+	response = recorder.Result()
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusTooManyRequests, response.StatusCode)
+	c.Equal("60", response.Header.Get("Retry-After"))
+
+	var apiErr api.APIErr
+
+	err := json.NewDecoder(response.Body).Decode(&apiErr)
+	c.NoError(err)
+	c.Equal(api.ErrCodeRateLimited, apiErr.Code())
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest(http.MethodGet))
+	c.Equal(http.StatusOK, recorder.Result().StatusCode)
+	c.Equal("2", recorder.Result().Header.Get("RateLimit-Limit"))
+}
+
+func TestMiddlewareKeyedByMerchant(t *testing.T) {
+	c := require.New(t)
+
+	limit := Limit{Requests: 1, Period: time.Minute}
+	handler := Middleware(NewMemoryStore(), limit, limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	newRequest := func(key *models.APIKey) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		return req.WithContext(auth.WithAPIKey(req.Context(), key))
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest(&models.APIKey{KeyID: "KEY_1", MerchantID: "MCH_123"}))
+	c.Equal(http.StatusOK, recorder.Result().StatusCode)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest(&models.APIKey{KeyID: "KEY_2", MerchantID: "MCH_123"}))
+	c.Equal(http.StatusTooManyRequests, recorder.Result().StatusCode, "a rotated key shares the quota of the merchant")
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequest(&models.APIKey{KeyID: "KEY_3", MerchantID: "MCH_456"}))
+	c.Equal(http.StatusOK, recorder.Result().StatusCode)
+}
+
+func TestIPMiddleware(t *testing.T) {
+	c := require.New(t)
+
+	handler := IPMiddleware(NewMemoryStore(), Limit{Requests: 2, Period: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		req := httptest.NewRequest(method, "/payments", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Authorization", "Bearer sk_test_invalid")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		c.Equal(http.StatusOK, recorder.Result().StatusCode)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil)
+	req.RemoteAddr = "10.0.0.1:5678"
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	c.Equal(http.StatusTooManyRequests, recorder.Result().StatusCode, "writes and reads share the quota of the IP")
+}
+
+func TestMiddlewareKeyedByClientIP(t *testing.T) {
+	c := require.New(t)
+
+	limit := Limit{Requests: 1, Period: time.Minute}
+
+	handler := Middleware(NewMemoryStore(), limit, limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "/merchants", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	c.Equal(http.StatusOK, recorder.Result().StatusCode)
+
+	req.RemoteAddr = "10.0.0.1:5678"
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	c.Equal(http.StatusTooManyRequests, recorder.Result().StatusCode)
+
+	req.RemoteAddr = "10.0.0.2:1234"
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	c.Equal(http.StatusOK, recorder.Result().StatusCode)
+}
+
+func TestMiddlewareStoreFailure(t *testing.T) {
+	c := require.New(t)
+
+	limit := Limit{Requests: 1, Period: time.Minute}
+
+	handler := Middleware(failingStore{}, limit, limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/payments", nil))
+	c.Equal(http.StatusOK, recorder.Result().StatusCode)
+}
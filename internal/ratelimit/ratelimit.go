@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrInvalidLimit error when a limit allows no request or refills over no time, which has no refill rate
+var ErrInvalidLimit = errors.New("rate limit must allow at least one request per positive period")
+
+// Limit defines the capacity of a token bucket and the period it takes to refill completely
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Result outcome of taking a token from a bucket
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// Store interface to keep the state of the rate limiter buckets
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens    float64
+	period    time.Duration
+	updatedAt time.Time
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	takes   int
+}
+
+const sweepInterval = 1000
+
+// NewMemoryStore initializes an in-process token bucket store
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take consumes a token from the bucket identified by key, refilling it based on the time elapsed
+func (s *memoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return Result{}, ErrInvalidLimit
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	capacity := float64(limit.Requests)
+	refillRate := capacity / limit.Period.Seconds()
+
+	s.takes++
+	if s.takes%sweepInterval == 0 {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, period: limit.Period, updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updatedAt).Seconds()*refillRate)
+	b.updatedAt = now
+
+	result := Result{
+		Limit: limit.Requests,
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / refillRate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = secondsToDuration((capacity - b.tokens) / refillRate)
+
+	return result, nil
+}
+
+// sweep removes buckets that have been idle long enough to be full again
+func (s *memoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.updatedAt) > b.period {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	c := require.New(t)
+
+	now := time.Date(2024, 2, 8, 12, 0, 0, 0, time.UTC)
+
+	store := NewMemoryStore().(*memoryStore)
+	store.now = func() time.Time { return now }
+
+	limit := Limit{Requests: 2, Period: 10 * time.Second}
+
+	result, err := store.Take(context.Background(), "key", limit)
+	c.NoError(err)
+	c.True(result.Allowed)
+	c.Equal(2, result.Limit)
+	c.Equal(1, result.Remaining)
+
+	result, err = store.Take(context.Background(), "key", limit)
+	c.NoError(err)
+	c.True(result.Allowed)
+	c.Equal(0, result.Remaining)
+	c.Equal(10*time.Second, result.ResetAfter)
+
+	result, err = store.Take(context.Background(), "key", limit)
+	c.NoError(err)
+	c.False(result.Allowed)
+	c.Equal(5*time.Second, result.RetryAfter)
+
+	result, err = store.Take(context.Background(), "other", limit)
+	c.NoError(err)
+	c.True(result.Allowed)
+
+	now = now.Add(5 * time.Second)
+
+	result, err = store.Take(context.Background(), "key", limit)
+	c.NoError(err)
+	c.True(result.Allowed)
+	c.Equal(0, result.Remaining)
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	c := require.New(t)
+
+	now := time.Date(2024, 2, 8, 12, 0, 0, 0, time.UTC)
+
+	store := NewMemoryStore().(*memoryStore)
+	store.now = func() time.Time { return now }
+
+	_, err := store.Take(context.Background(), "idle", Limit{Requests: 1, Period: time.Second})
+	c.NoError(err)
+
+	now = now.Add(time.Minute)
+
+	store.sweep(now)
+	c.NotContains(store.buckets, "idle")
+}
+
+func TestMemoryStoreTakeInvalidLimit(t *testing.T) {
+	c := require.New(t)
+
+	store := NewMemoryStore()
+
+	_, err := store.Take(context.Background(), "key", Limit{Requests: 0, Period: time.Minute})
+	c.ErrorIs(err, ErrInvalidLimit)
+
+	_, err = store.Take(context.Background(), "key", Limit{Requests: 1})
+	c.ErrorIs(err, ErrInvalidLimit)
+}
@@ -0,0 +1,195 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/oklog/ulid/v2"
+)
+
+// Engine evaluates the risk rules of the platform before a payment is charged. The rules are read from a file,
+// which is reloaded when it changes
+type Engine struct {
+	path  string
+	store database.RiskStore
+	rules atomic.Pointer[Rules]
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// New constructor for the engine evaluating the rules of the given file, where an empty path allows every payment
+func New(path string, store database.RiskStore) (*Engine, error) {
+	engine := &Engine{
+		path:  path,
+		store: store,
+	}
+
+	engine.rules.Store(&Rules{})
+
+	if path == "" {
+		return engine, nil
+	}
+
+	_, err := engine.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+// Reload reads the rules file again when it changed since it was last read, keeping the current rules when the
+// new ones are invalid
+func (e *Engine) Reload() (bool, error) {
+	if e.path == "" {
+		return false, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return false, fmt.Errorf("stat risk rules file: %w", err)
+	}
+
+	if info.ModTime().Equal(e.modTime) {
+		return false, nil
+	}
+
+	rules, err := LoadRules(e.path)
+	if err != nil {
+		return false, err
+	}
+
+	e.rules.Store(rules)
+	e.modTime = info.ModTime()
+
+	return true, nil
+}
+
+// Watch reloads the rules file every interval until the context is canceled
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if e.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := e.Reload()
+			if err != nil {
+				slog.ErrorContext(ctx, "reload risk rules failed, keeping the current rules", slog.Any("error", err))
+				continue
+			}
+
+			if reloaded {
+				slog.InfoContext(ctx, "risk rules reloaded", slog.Int("rules", len(e.rules.Load().Rules)))
+			}
+		}
+	}
+}
+
+// Evaluate runs every rule against the payment and decides on the most severe action of the matching ones. The
+// decision keeps the result of every rule as its trace
+func (e *Engine) Evaluate(ctx context.Context, input *models.TransactionInput) (*models.RiskDecision, error) {
+	now := time.Now().UTC()
+
+	decision := &models.RiskDecision{
+		DecisionID:    fmt.Sprintf("RSK_%s", ulid.Make().String()),
+		MerchantID:    input.MerchantID,
+		Outcome:       models.RiskOutcomeAllow,
+		Rules:         []*models.RiskRuleResult{},
+		PaymentMethod: models.RiskFieldPaymentMethod.Value(input),
+		Customer:      models.RiskFieldCustomer.Value(input),
+		IPAddress:     models.RiskFieldIPAddress.Value(input),
+		Country:       models.RiskFieldCountry.Value(input),
+		Amount:        int(input.Amount),
+		Currency:      strings.ToLower(input.Currency),
+		CreatedAt:     now,
+	}
+
+	for _, rule := range e.rules.Load().Rules {
+		result, err := e.evaluateRule(ctx, rule, input, now)
+		if err != nil {
+			return nil, err
+		}
+
+		decision.Rules = append(decision.Rules, result)
+
+		if result.Matched && rule.Action.Severity() > decision.Outcome.Severity() {
+			decision.Outcome = rule.Action
+		}
+	}
+
+	return decision, nil
+}
+
+func (e *Engine) evaluateRule(ctx context.Context, rule Rule, input *models.TransactionInput, now time.Time) (*models.RiskRuleResult, error) {
+	result := &models.RiskRuleResult{
+		Rule:   rule.Name,
+		Type:   string(rule.Type),
+		Action: rule.Action,
+	}
+
+	switch rule.Type {
+	case RuleTypeAmount:
+		if rule.matchesCurrency(input.Currency) && input.Amount > rule.MaxAmount {
+			result.Matched = true
+			result.Detail = fmt.Sprintf("amount %d above %d", input.Amount, rule.MaxAmount)
+		}
+	case RuleTypeVelocity:
+		value := rule.Field.Value(input)
+		if value == "" {
+			break
+		}
+
+		count, err := e.store.CountRiskDecisions(ctx, input.MerchantID, rule.Field, value, now.Add(-rule.Window))
+		if err != nil {
+			return nil, err
+		}
+
+		if count >= rule.MaxCount {
+			result.Matched = true
+			result.Detail = fmt.Sprintf("%d payments with the same %s in %s", count, rule.Field, rule.Window)
+		}
+	case RuleTypeCurrencyCountry:
+		country := models.RiskFieldCountry.Value(input)
+		if country == "" {
+			break
+		}
+
+		countries, ok := rule.Countries[strings.ToLower(input.Currency)]
+		if ok && !slices.ContainsFunc(countries, func(expected string) bool { return strings.EqualFold(expected, country) }) {
+			result.Matched = true
+			result.Detail = fmt.Sprintf("currency %s not expected for country %s", strings.ToLower(input.Currency), country)
+		}
+	case RuleTypeBlockList:
+		value := rule.Field.Value(input)
+		if value == "" {
+			break
+		}
+
+		if slices.ContainsFunc(rule.Values, func(listed string) bool { return strings.EqualFold(listed, value) }) {
+			result.Matched = true
+			result.Detail = fmt.Sprintf("%s is listed", rule.Field)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,183 @@
+package risk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `
+rules:
+  - name: large_usd_charge
+    type: amount
+    action: review
+    currency: usd
+    max_amount: 100000
+  - name: card_testing
+    type: velocity
+    action: block
+    field: payment_method
+    max_count: 3
+    window: 1h
+  - name: unexpected_country
+    type: currency_country
+    action: review
+    countries:
+      mxn: [MX]
+  - name: known_fraudsters
+    type: block_list
+    action: block
+    field: customer
+    values: [fraud@example.com]
+`
+
+func newTestEngine(t *testing.T, store *postgres.MockPostgres) *Engine {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, testRules)
+
+	engine, err := New(path, store)
+	require.NoError(t, err)
+
+	return engine
+}
+
+func TestEvaluateAllow(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("CountRiskDecisions", mock.Anything, "MCH_123", models.RiskFieldPaymentMethod, "pm_card_visa", mock.Anything).Return(1, nil)
+
+	engine := newTestEngine(t, &mockDatabase)
+
+	decision, err := engine.Evaluate(context.Background(), &models.TransactionInput{
+		MerchantID:    "MCH_123",
+		Amount:        2000,
+		Currency:      "usd",
+		PaymentMethod: "pm_card_visa",
+		Customer:      " Jane@Example.com ",
+		Country:       "us",
+	})
+	c.NoError(err)
+	c.Equal(models.RiskOutcomeAllow, decision.Outcome)
+	c.Contains(decision.DecisionID, "RSK_")
+	c.Len(decision.Rules, 4)
+	c.Equal("jane@example.com", decision.Customer)
+	c.Equal("US", decision.Country)
+
+	for _, result := range decision.Rules {
+		c.False(result.Matched, result.Rule)
+	}
+}
+
+func TestEvaluateMostSevereOutcomeWins(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("CountRiskDecisions", mock.Anything, "", models.RiskFieldPaymentMethod, "pm_card_visa", mock.Anything).Return(0, nil)
+
+	engine := newTestEngine(t, &mockDatabase)
+
+	decision, err := engine.Evaluate(context.Background(), &models.TransactionInput{
+		Amount:        150000,
+		Currency:      "usd",
+		PaymentMethod: "pm_card_visa",
+		Customer:      "FRAUD@example.com",
+	})
+	c.NoError(err)
+	c.Equal(models.RiskOutcomeBlock, decision.Outcome)
+	c.True(decision.Rules[0].Matched)
+	c.Equal("amount 150000 above 100000", decision.Rules[0].Detail)
+	c.True(decision.Rules[3].Matched)
+}
+
+func TestEvaluateVelocity(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("CountRiskDecisions", mock.Anything, "", models.RiskFieldPaymentMethod, "pm_card_visa", mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) >= time.Hour && time.Since(since) < time.Hour+time.Minute
+	})).Return(3, nil)
+
+	engine := newTestEngine(t, &mockDatabase)
+
+	decision, err := engine.Evaluate(context.Background(), &models.TransactionInput{Amount: 2000, Currency: "usd", PaymentMethod: "pm_card_visa"})
+	c.NoError(err)
+	c.Equal(models.RiskOutcomeBlock, decision.Outcome)
+	c.Equal("3 payments with the same payment_method in 1h0m0s", decision.Rules[1].Detail)
+}
+
+func TestEvaluateCurrencyCountry(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("CountRiskDecisions", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+
+	engine := newTestEngine(t, &mockDatabase)
+
+	decision, err := engine.Evaluate(context.Background(), &models.TransactionInput{Amount: 2000, Currency: "MXN", PaymentMethod: "pm_card_visa", Country: "us"})
+	c.NoError(err)
+	c.Equal(models.RiskOutcomeReview, decision.Outcome)
+	c.Equal("currency mxn not expected for country US", decision.Rules[2].Detail)
+
+	decision, err = engine.Evaluate(context.Background(), &models.TransactionInput{Amount: 2000, Currency: "mxn", PaymentMethod: "pm_card_visa", Country: "mx"})
+	c.NoError(err)
+	c.Equal(models.RiskOutcomeAllow, decision.Outcome)
+}
+
+func TestEvaluateWithoutRules(t *testing.T) {
+	c := require.New(t)
+
+	engine, err := New("", &postgres.MockPostgres{})
+	c.NoError(err)
+
+	decision, err := engine.Evaluate(context.Background(), &models.TransactionInput{Amount: 2000, Currency: "usd", PaymentMethod: "pm_card_visa"})
+	c.NoError(err)
+	c.Equal(models.RiskOutcomeAllow, decision.Outcome)
+	c.Empty(decision.Rules)
+}
+
+func TestReload(t *testing.T) {
+	c := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, testRules)
+
+	engine, err := New(path, &postgres.MockPostgres{})
+	c.NoError(err)
+
+	reloaded, err := engine.Reload()
+	c.NoError(err)
+	c.False(reloaded)
+
+	writeRules(t, path, "rules:\n  - name: large_charge\n    type: amount\n    action: block\n    max_amount: 500\n")
+	c.NoError(os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	reloaded, err = engine.Reload()
+	c.NoError(err)
+	c.True(reloaded)
+	c.Len(engine.rules.Load().Rules, 1)
+
+	// invalid rules are rejected, keeping the ones loaded before
+	writeRules(t, path, "rules:\n  - name: large_charge\n    type: amount\n    action: allow\n")
+	c.NoError(os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+
+	_, err = engine.Reload()
+	c.Error(err)
+	c.Len(engine.rules.Load().Rules, 1)
+}
+
+func TestNewInvalidRulesFile(t *testing.T) {
+	c := require.New(t)
+
+	_, err := New(filepath.Join(t.TempDir(), "missing.yaml"), &postgres.MockPostgres{})
+	c.Error(err)
+}
@@ -0,0 +1,147 @@
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleType type for the check performed by a rule
+type RuleType string
+
+var (
+	// RuleTypeAmount matches payments above an amount, optionally in a single currency
+	RuleTypeAmount RuleType = "amount"
+	// RuleTypeVelocity matches payments sharing a field value with too many recent payments
+	RuleTypeVelocity RuleType = "velocity"
+	// RuleTypeCurrencyCountry matches payments whose currency isn't expected for their billing country
+	RuleTypeCurrencyCountry RuleType = "currency_country"
+	// RuleTypeBlockList matches payments whose field value is listed
+	RuleTypeBlockList RuleType = "block_list"
+)
+
+// Rules set of rules evaluated before charging a payment
+type Rules struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule check performed on a payment, taking its action when it matches
+type Rule struct {
+	Name   string             `yaml:"name"`
+	Type   RuleType           `yaml:"type"`
+	Action models.RiskOutcome `yaml:"action"`
+	// Currency restricts an amount rule to payments in this currency
+	Currency string `yaml:"currency"`
+	// MaxAmount highest amount allowed by an amount rule, in the currency minor unit
+	MaxAmount int64 `yaml:"max_amount"`
+	// Field payment attribute checked by velocity and block list rules
+	Field models.RiskField `yaml:"field"`
+	// MaxCount payments sharing the field value allowed within the window before a velocity rule matches
+	MaxCount int `yaml:"max_count"`
+	// Window period a velocity rule counts payments over
+	Window time.Duration `yaml:"window"`
+	// Values listed by a block list rule
+	Values []string `yaml:"values"`
+	// Countries billing countries expected for each currency by a currency country rule
+	Countries map[string][]string `yaml:"countries"`
+}
+
+// LoadRules reads and validates the rules of a YAML file
+func LoadRules(path string) (*Rules, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open risk rules file: %w", err)
+	}
+
+	defer file.Close()
+
+	rules := &Rules{}
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(rules)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decode risk rules file %s: %w", path, err)
+	}
+
+	err = rules.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid risk rules file %s:\n%w", path, err)
+	}
+
+	return rules, nil
+}
+
+// validate checks every rule, naming the invalid ones
+func (r *Rules) validate() error {
+	var errs []error
+
+	names := map[string]bool{}
+
+	for i, rule := range r.Rules {
+		invalid := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Errorf("rules[%d] %s: %s", i, rule.Name, fmt.Sprintf(format, args...)))
+		}
+
+		if rule.Name == "" {
+			invalid("name is required")
+		}
+
+		if names[rule.Name] {
+			invalid("name is duplicated")
+		}
+
+		names[rule.Name] = true
+
+		if rule.Action != models.RiskOutcomeReview && rule.Action != models.RiskOutcomeBlock {
+			invalid("action must be review or block, got %q", rule.Action)
+		}
+
+		switch rule.Type {
+		case RuleTypeAmount:
+			if rule.MaxAmount <= 0 {
+				invalid("max_amount must be a positive integer")
+			}
+		case RuleTypeVelocity:
+			if !rule.Field.IsValid() {
+				invalid("field must be payment_method, customer, ip_address or country, got %q", rule.Field)
+			}
+
+			if rule.MaxCount <= 0 {
+				invalid("max_count must be a positive integer")
+			}
+
+			if rule.Window <= 0 {
+				invalid("window must be a positive duration")
+			}
+		case RuleTypeCurrencyCountry:
+			if len(rule.Countries) == 0 {
+				invalid("countries is required")
+			}
+		case RuleTypeBlockList:
+			if !rule.Field.IsValid() {
+				invalid("field must be payment_method, customer, ip_address or country, got %q", rule.Field)
+			}
+
+			if len(rule.Values) == 0 {
+				invalid("values is required")
+			}
+		default:
+			invalid("type must be amount, velocity, currency_country or block_list, got %q", rule.Type)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// matchesCurrency reports whether the rule applies to payments in the given currency
+func (r Rule) matchesCurrency(currency string) bool {
+	return r.Currency == "" || strings.EqualFold(r.Currency, currency)
+}
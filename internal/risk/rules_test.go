@@ -0,0 +1,86 @@
+package risk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRules(t *testing.T, path, content string) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	require.NoError(t, err)
+}
+
+func TestLoadRules(t *testing.T) {
+	c := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+
+	writeRules(t, path, `
+rules:
+  - name: large_usd_charge
+    type: amount
+    action: review
+    currency: usd
+    max_amount: 100000
+  - name: card_testing
+    type: velocity
+    action: block
+    field: payment_method
+    max_count: 5
+    window: 1h
+`)
+
+	rules, err := LoadRules(path)
+	c.NoError(err)
+	c.Len(rules.Rules, 2)
+	c.Equal(models.RiskOutcomeReview, rules.Rules[0].Action)
+	c.Equal(time.Hour, rules.Rules[1].Window)
+}
+
+func TestLoadRulesInvalid(t *testing.T) {
+	c := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+
+	writeRules(t, path, `
+rules:
+  - name: card_testing
+    type: velocity
+    action: allow
+    field: card
+  - name: card_testing
+    type: unknown
+    action: block
+`)
+
+	_, err := LoadRules(path)
+	c.ErrorContains(err, "action must be review or block")
+	c.ErrorContains(err, `field must be payment_method, customer, ip_address or country, got "card"`)
+	c.ErrorContains(err, "window must be a positive duration")
+	c.ErrorContains(err, "name is duplicated")
+	c.ErrorContains(err, `type must be amount, velocity, currency_country or block_list, got "unknown"`)
+}
+
+func TestLoadRulesUnknownField(t *testing.T) {
+	c := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+
+	writeRules(t, path, `
+rules:
+  - name: large_charge
+    type: amount
+    action: review
+    maximum: 100000
+`)
+
+	_, err := LoadRules(path)
+	c.ErrorContains(err, "field maximum not found")
+}
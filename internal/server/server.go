@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Run listens on the server address and serves requests until ctx is cancelled, then shuts down gracefully
+func Run(ctx context.Context, server *http.Server, shutdownTimeout time.Duration) error {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", server.Addr, err)
+	}
+
+	return Serve(ctx, server, listener, shutdownTimeout)
+}
+
+// Serve serves requests from the listener until ctx is cancelled. It then stops accepting connections
+// and waits up to the shutdown timeout for in-flight requests to complete
+func Serve(ctx context.Context, server *http.Server, listener net.Listener, shutdownTimeout time.Duration) error {
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	slog.InfoContext(ctx, "listening", slog.String("address", listener.Addr().String()))
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.InfoContext(ctx, "shutting down", slog.Duration("timeout", shutdownTimeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	err = <-serveErr
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	c := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.NoError(err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error, 1)
+
+	go func() {
+		served <- Serve(ctx, server, listener, 5*time.Second)
+	}()
+
+	responses := make(chan *http.Response, 1)
+
+	go func() {
+		response, _ := http.Get("http://" + listener.Addr().String())
+		responses <- response
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case <-served:
+		c.Fail("server stopped before the in-flight request completed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	response := <-responses
+	c.NotNil(response)
+
+	defer response.Body.Close()
+
+	c.Equal(http.StatusCreated, response.StatusCode)
+	c.NoError(<-served)
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	c := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.NoError(err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error, 1)
+
+	go func() {
+		served <- Serve(ctx, server, listener, 10*time.Millisecond)
+	}()
+
+	go http.Get("http://" + listener.Addr().String())
+
+	<-started
+	cancel()
+
+	c.ErrorIs(<-served, context.DeadlineExceeded)
+}
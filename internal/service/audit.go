@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// auditVerificationPageSize entries read at a time while verifying the audit trail
+const auditVerificationPageSize = 1000
+
+// AuditService interface to implement business logic to query the audit trail and detect tampering with it
+type AuditService interface {
+	ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error)
+	VerifyAuditLog(ctx context.Context) (*models.AuditVerification, error)
+}
+
+type auditService struct {
+	database database.AuditStore
+}
+
+// NewAuditService constructor for the service querying the audit trail
+func NewAuditService(database database.AuditStore) AuditService {
+	return auditService{
+		database: database,
+	}
+}
+
+// ListAuditEntries lists the audit entries matching the filter in the order they were appended
+func (a auditService) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	return a.database.ListAuditEntries(ctx, filter)
+}
+
+// VerifyAuditLog walks the whole audit trail checking its hash chain, stopping at the first entry that was modified,
+// removed or appended out of the chain
+func (a auditService) VerifyAuditLog(ctx context.Context) (*models.AuditVerification, error) {
+	chain := &audit.Chain{}
+	verification := &models.AuditVerification{Valid: true}
+	filter := &models.AuditFilter{Limit: auditVerificationPageSize}
+
+	for {
+		entries, err := a.database.ListAuditEntries(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			verification.Entries++
+
+			err = chain.Check(entry)
+			if err != nil {
+				verification.Valid = false
+				verification.FirstInvalidSequence = entry.Sequence
+				verification.Reason = err.Error()
+
+				slog.ErrorContext(ctx, "audit log verification failed", slog.Int64("sequence", entry.Sequence), slog.Any("error", err))
+
+				return verification, nil
+			}
+
+			filter.AfterSequence = entry.Sequence
+		}
+
+		if len(entries) < auditVerificationPageSize {
+			return verification, nil
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testAuditTrail(t *testing.T, size int) []*models.AuditEntry {
+	entries := []*models.AuditEntry{}
+	previousHash := ""
+
+	for i := 1; i <= size; i++ {
+		entry := &models.AuditEntry{
+			AuditID:      fmt.Sprintf("AUD_%d", i),
+			Sequence:     int64(i),
+			Actor:        "alice",
+			ActorType:    models.AuditActorOperator,
+			Action:       models.AuditActionForceStatus,
+			ResourceType: models.AuditResourceTransaction,
+			ResourceID:   "TXN_123",
+			PreviousHash: previousHash,
+			CreatedAt:    time.Date(2024, time.March, 1, 10, 0, i, 0, time.UTC),
+		}
+
+		hash, err := entry.ComputeHash()
+		require.NoError(t, err)
+
+		entry.Hash = hash
+		previousHash = hash
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func afterSequence(sequence int64) interface{} {
+	return mock.MatchedBy(func(filter *models.AuditFilter) bool {
+		return filter.AfterSequence == sequence && filter.Limit == auditVerificationPageSize
+	})
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	entries := testAuditTrail(t, auditVerificationPageSize+2)
+
+	mockDatabase.On("ListAuditEntries", mock.Anything, afterSequence(0)).Return(entries[:auditVerificationPageSize], nil)
+	mockDatabase.On("ListAuditEntries", mock.Anything, afterSequence(auditVerificationPageSize)).Return(entries[auditVerificationPageSize:], nil)
+
+	auditService := NewAuditService(&mockDatabase)
+
+	verification, err := auditService.VerifyAuditLog(context.Background())
+	c.NoError(err)
+	c.True(verification.Valid)
+	c.Equal(auditVerificationPageSize+2, verification.Entries)
+	c.Zero(verification.FirstInvalidSequence)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestVerifyAuditLogTampered(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	entries := testAuditTrail(t, 5)
+	entries[2].Reason = "customer complaint"
+
+	mockDatabase.On("ListAuditEntries", mock.Anything, afterSequence(0)).Return(entries, nil)
+
+	auditService := NewAuditService(&mockDatabase)
+
+	verification, err := auditService.VerifyAuditLog(context.Background())
+	c.NoError(err)
+	c.False(verification.Valid)
+	c.Equal(3, verification.Entries)
+	c.Equal(int64(3), verification.FirstInvalidSequence)
+	c.NotEmpty(verification.Reason)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestVerifyAuditLogDatabaseError(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("ListAuditEntries", mock.Anything, afterSequence(0)).Return(nil, api.NewInternalServerError(errors.New("connection refused")))
+
+	auditService := NewAuditService(&mockDatabase)
+
+	verification, err := auditService.VerifyAuditLog(context.Background())
+	c.Error(err)
+	c.Nil(verification)
+
+	mockDatabase.AssertExpectations(t)
+}
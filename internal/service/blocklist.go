@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	// ErrMissingBlockListEntryID error when block list entry ID is missing
+	ErrMissingBlockListEntryID = api.NewInvalidRequestError(errors.New("missing block list entry id"))
+	// ErrBlockListExpirationInPast error when a block list entry would already be expired
+	ErrBlockListExpirationInPast = api.NewInvalidRequestError(errors.New("expiration in the past"))
+	// ErrPaymentBlocked error when the payment matched an active block list entry. It doesn't tell which value was
+	// blocked, so it can't be used to probe the block list
+	ErrPaymentBlocked = api.NewPaymentBlockedError(errors.New("payment rejected by the block list"))
+)
+
+// BlockListService interface to implement the management of the values payments are rejected for
+type BlockListService interface {
+	CreateBlockListEntry(ctx context.Context, entry *models.BlockListEntry) (*models.BlockListEntry, error)
+	GetBlockListEntry(ctx context.Context, entryID string) (*models.BlockListEntry, error)
+	ListBlockListEntries(ctx context.Context, filter *models.BlockListFilter) ([]*models.BlockListEntry, error)
+	// UpdateBlockListEntry replaces the reason and expiration of the entry
+	UpdateBlockListEntry(ctx context.Context, entry *models.BlockListEntry) (*models.BlockListEntry, error)
+	DeleteBlockListEntry(ctx context.Context, entryID string) error
+}
+
+type blockListService struct {
+	database database.BlockListStore
+}
+
+// NewBlockListService constructor for block list service
+func NewBlockListService(database database.BlockListStore) BlockListService {
+	return blockListService{
+		database: database,
+	}
+}
+
+// CreateBlockListEntry adds the value to the block list, normalized so matches ignore case and formatting
+func (b blockListService) CreateBlockListEntry(ctx context.Context, entry *models.BlockListEntry) (*models.BlockListEntry, error) {
+	if !entry.Type.IsValid() {
+		return nil, api.NewInvalidRequestError(models.ErrInvalidBlockListType)
+	}
+
+	value, err := entry.Type.Normalize(entry.Value)
+	if err != nil {
+		return nil, api.NewInvalidRequestError(err)
+	}
+
+	now := time.Now().UTC()
+
+	err = validateBlockListEntry(entry, now)
+	if err != nil {
+		return nil, err
+	}
+
+	created := &models.BlockListEntry{
+		EntryID:   fmt.Sprintf("BLK_%s", ulid.Make().String()),
+		Type:      entry.Type,
+		Value:     value,
+		Reason:    entry.Reason,
+		ExpiresAt: entry.ExpiresAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = b.database.InsertBlockListEntry(ctx, created, &models.AuditEntry{
+		Action:       models.AuditActionCreateBlockListEntry,
+		ResourceType: models.AuditResourceBlockListEntry,
+		ResourceID:   created.EntryID,
+		Reason:       created.Reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "block list entry created", slog.String("entry_id", created.EntryID), slog.String("type", string(created.Type)))
+
+	return created, nil
+}
+
+// GetBlockListEntry fetches a block list entry
+func (b blockListService) GetBlockListEntry(ctx context.Context, entryID string) (*models.BlockListEntry, error) {
+	if entryID == "" {
+		return nil, ErrMissingBlockListEntryID
+	}
+
+	return b.database.GetBlockListEntry(ctx, entryID)
+}
+
+// ListBlockListEntries lists the block list entries matching the filter, newest first
+func (b blockListService) ListBlockListEntries(ctx context.Context, filter *models.BlockListFilter) ([]*models.BlockListEntry, error) {
+	return b.database.ListBlockListEntries(ctx, filter)
+}
+
+// UpdateBlockListEntry replaces the reason and expiration of a block list entry, where a nil expiration blocks
+// payments until the entry is deleted
+func (b blockListService) UpdateBlockListEntry(ctx context.Context, entry *models.BlockListEntry) (*models.BlockListEntry, error) {
+	if entry.EntryID == "" {
+		return nil, ErrMissingBlockListEntryID
+	}
+
+	err := validateBlockListEntry(entry, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return b.database.UpdateBlockListEntry(ctx, entry, &models.AuditEntry{
+		Action:       models.AuditActionUpdateBlockListEntry,
+		ResourceType: models.AuditResourceBlockListEntry,
+		ResourceID:   entry.EntryID,
+		Reason:       entry.Reason,
+	})
+}
+
+// DeleteBlockListEntry removes an entry from the block list
+func (b blockListService) DeleteBlockListEntry(ctx context.Context, entryID string) error {
+	if entryID == "" {
+		return ErrMissingBlockListEntryID
+	}
+
+	err := b.database.DeleteBlockListEntry(ctx, entryID, &models.AuditEntry{
+		Action:       models.AuditActionDeleteBlockListEntry,
+		ResourceType: models.AuditResourceBlockListEntry,
+		ResourceID:   entryID,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "block list entry deleted", slog.String("entry_id", entryID))
+
+	return nil
+}
+
+// validateBlockListEntry validates the reason and expiration of an entry
+func validateBlockListEntry(entry *models.BlockListEntry, now time.Time) error {
+	if entry.Reason == "" {
+		return ErrMissingReason
+	}
+
+	if entry.ExpiresAt != nil && !entry.ExpiresAt.After(now) {
+		return ErrBlockListExpirationInPast
+	}
+
+	return nil
+}
+
+// checkBlockList rejects payments matching an active block list entry. The values sent with the payment are checked
+// first, so blocked payment methods, customers and addresses never reach the payment provider. Card fingerprints and
+// BINs are only known to the payment provider, so when the block list holds active card entries the card behind the
+// payment method is retrieved with a read-only lookup, which neither charges nor authorizes it, before deciding. It's
+// the only call to the payment provider made before a payment is blocked
+func (o onlinePaymentService) checkBlockList(ctx context.Context, input *models.TransactionInput) error {
+	now := time.Now().UTC()
+
+	query := &models.BlockListQuery{
+		PaymentMethod: input.PaymentMethod,
+		Email:         models.NormalizeCustomer(input.Customer),
+		At:            now,
+	}
+
+	if input.IPAddress != "" {
+		query.IPAddress = net.ParseIP(input.IPAddress).String()
+	}
+
+	err := o.matchBlockList(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	cardEntries, err := o.database.ListBlockListEntries(ctx, &models.BlockListFilter{
+		Types:    []models.BlockListType{models.BlockListTypeCardFingerprint, models.BlockListTypeBIN},
+		ActiveAt: &now,
+		Limit:    1,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(cardEntries) == 0 {
+		return nil
+	}
+
+	details, err := o.paymentProcessor.GetPaymentMethod(ctx, input.PaymentMethod)
+	if err != nil {
+		return err
+	}
+
+	if details.Fingerprint == "" && details.BIN == "" {
+		return nil
+	}
+
+	return o.matchBlockList(ctx, &models.BlockListQuery{
+		CardFingerprint: details.Fingerprint,
+		BIN:             details.BIN,
+		At:              now,
+	})
+}
+
+// matchBlockList fails with ErrPaymentBlocked when the query matches an active block list entry
+func (o onlinePaymentService) matchBlockList(ctx context.Context, query *models.BlockListQuery) error {
+	matches, err := o.database.MatchBlockList(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+
+	slog.WarnContext(ctx, "payment matched block list",
+		slog.String("entry_id", matches[0].EntryID),
+		slog.String("type", string(matches[0].Type)),
+		slog.Int("matches", len(matches)),
+	)
+
+	return ErrPaymentBlocked
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessPaymentBlocked(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("MatchBlockList", mock.Anything, mock.MatchedBy(func(query *models.BlockListQuery) bool {
+		return query.PaymentMethod == "pm_card_visa" && query.Email == "fraudster@example.com" && query.IPAddress == "2001:db8::1"
+	})).Return([]*models.BlockListEntry{
+		{EntryID: "BLK_123", Type: models.BlockListTypeEmail, Value: "fraudster@example.com"},
+	}, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	input := &models.TransactionInput{
+		Amount:        2000,
+		Currency:      "usd",
+		PaymentMethod: "pm_card_visa",
+		Customer:      "Fraudster@Example.com",
+		IPAddress:     "2001:DB8:0::1",
+	}
+
+	_, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.ErrorIs(err, ErrPaymentBlocked)
+
+	mockPaymentProcessor.AssertNotCalled(t, "GetPaymentMethod", mock.Anything, mock.Anything)
+	mockPaymentProcessor.AssertNotCalled(t, "PerformTransaction", mock.Anything, mock.Anything)
+}
+
+func TestProcessPaymentBlockedCardFingerprint(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("MatchBlockList", mock.Anything, mock.MatchedBy(func(query *models.BlockListQuery) bool {
+		return query.PaymentMethod == "pm_card_visa"
+	})).Return([]*models.BlockListEntry{}, nil)
+	mockDatabase.On("ListBlockListEntries", mock.Anything, mock.MatchedBy(func(filter *models.BlockListFilter) bool {
+		return len(filter.Types) == 2 && filter.ActiveAt != nil && filter.Limit == 1
+	})).Return([]*models.BlockListEntry{{EntryID: "BLK_123", Type: models.BlockListTypeCardFingerprint}}, nil)
+	mockPaymentProcessor.On("GetPaymentMethod", mock.Anything, "pm_card_visa").Return(&models.PaymentMethodDetails{
+		PaymentMethodID: "pm_card_visa",
+		Fingerprint:     "fp_123",
+		BIN:             "424242",
+	}, nil)
+	mockDatabase.On("MatchBlockList", mock.Anything, mock.MatchedBy(func(query *models.BlockListQuery) bool {
+		return query.PaymentMethod == "" && query.CardFingerprint == "fp_123" && query.BIN == "424242"
+	})).Return([]*models.BlockListEntry{{EntryID: "BLK_123", Type: models.BlockListTypeCardFingerprint, Value: "fp_123"}}, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	input := &models.TransactionInput{Amount: 2000, Currency: "usd", PaymentMethod: "pm_card_visa"}
+
+	_, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.ErrorIs(err, ErrPaymentBlocked)
+
+	mockPaymentProcessor.AssertNotCalled(t, "PerformTransaction", mock.Anything, mock.Anything)
+}
+
+func TestCreateBlockListEntry(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("InsertBlockListEntry", mock.Anything, mock.MatchedBy(func(entry *models.BlockListEntry) bool {
+		return entry.Type == models.BlockListTypeBIN && entry.Value == "424242-424242" && entry.Reason == "card testing"
+	}), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionCreateBlockListEntry && strings.HasPrefix(entry.ResourceID, "BLK_") && entry.Reason == "card testing"
+	})).Return(nil)
+
+	blockListService := NewBlockListService(&mockDatabase)
+
+	entry, err := blockListService.CreateBlockListEntry(context.Background(), &models.BlockListEntry{
+		Type:   models.BlockListTypeBIN,
+		Value:  "424242",
+		Reason: "card testing",
+	})
+	c.NoError(err)
+	c.Contains(entry.EntryID, "BLK_")
+	c.Nil(entry.ExpiresAt)
+}
+
+func TestCreateBlockListEntryInvalid(t *testing.T) {
+	c := require.New(t)
+
+	blockListService := NewBlockListService(&postgres.MockPostgres{})
+
+	_, err := blockListService.CreateBlockListEntry(context.Background(), &models.BlockListEntry{Type: "phone", Value: "555", Reason: "fraud"})
+	c.ErrorIs(err, models.ErrInvalidBlockListType)
+
+	_, err = blockListService.CreateBlockListEntry(context.Background(), &models.BlockListEntry{Type: models.BlockListTypeBIN, Value: "4242-4243", Reason: "fraud"})
+	c.ErrorIs(err, models.ErrInvalidBlockListValue)
+
+	_, err = blockListService.CreateBlockListEntry(context.Background(), &models.BlockListEntry{Type: models.BlockListTypeEmail, Value: "fraudster@example.com"})
+	c.ErrorIs(err, ErrMissingReason)
+
+	expired := time.Now().Add(-time.Hour)
+
+	_, err = blockListService.CreateBlockListEntry(context.Background(), &models.BlockListEntry{Type: models.BlockListTypeEmail, Value: "fraudster@example.com", Reason: "fraud", ExpiresAt: &expired})
+	c.ErrorIs(err, ErrBlockListExpirationInPast)
+}
+
+func TestUpdateBlockListEntry(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	entry := &models.BlockListEntry{EntryID: "BLK_123", Reason: "card testing"}
+
+	mockDatabase.On("UpdateBlockListEntry", mock.Anything, entry, mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionUpdateBlockListEntry && entry.ResourceID == "BLK_123" && entry.Reason == "card testing"
+	})).Return(&models.BlockListEntry{EntryID: "BLK_123", Type: models.BlockListTypeEmail, Reason: "card testing"}, nil)
+
+	blockListService := NewBlockListService(&mockDatabase)
+
+	updated, err := blockListService.UpdateBlockListEntry(context.Background(), entry)
+	c.NoError(err)
+	c.Equal("card testing", updated.Reason)
+}
+
+func TestDeleteBlockListEntry(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("DeleteBlockListEntry", mock.Anything, "BLK_123", mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionDeleteBlockListEntry && entry.ResourceType == models.AuditResourceBlockListEntry && entry.ResourceID == "BLK_123"
+	})).Return(nil)
+
+	blockListService := NewBlockListService(&mockDatabase)
+
+	err := blockListService.DeleteBlockListEntry(context.Background(), "BLK_123")
+	c.NoError(err)
+
+	mockDatabase.AssertExpectations(t)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/oklog/ulid/v2"
+)
+
+// ErrMissingCustomer error when the customer identifier is missing
+var ErrMissingCustomer = api.NewInvalidRequestError(errors.New("missing customer"))
+
+// CustomerDataService interface to implement the access and erasure requests of paying customers
+type CustomerDataService interface {
+	ExportCustomerData(ctx context.Context, customer string) (*models.CustomerData, error)
+	EraseCustomerData(ctx context.Context, actor, customer, reason string) (*models.CustomerErasure, error)
+}
+
+type customerDataService struct {
+	database database.DataSubjectStore
+}
+
+// NewCustomerDataService constructor for the service answering the requests of paying customers about their data
+func NewCustomerDataService(database database.DataSubjectStore) CustomerDataService {
+	return customerDataService{
+		database: database,
+	}
+}
+
+// ExportCustomerData bundles the transactions, risk decisions, block list entries, audit entries and webhook events of
+// the customer, identified by the email or ID their payments were made with
+func (c customerDataService) ExportCustomerData(ctx context.Context, customer string) (*models.CustomerData, error) {
+	err := auth.Authorize(ctx, models.PermissionExportCustomerData)
+	if err != nil {
+		return nil, err
+	}
+
+	customer = models.NormalizeCustomer(customer)
+	if customer == "" {
+		return nil, ErrMissingCustomer
+	}
+
+	data, err := c.database.ExportCustomerData(ctx, customer)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "customer data exported",
+		slog.Int("transactions", len(data.Transactions)),
+		slog.Int("risk_decisions", len(data.RiskDecisions)),
+		slog.Int("webhook_events", len(data.WebhookEvents)),
+	)
+
+	return data, nil
+}
+
+// EraseCustomerData erases the personal data of the customer, keeping amounts, IDs and provider references so their
+// payments can still be accounted for. The erasure is recorded in the audit trail under its own ID, as the customer
+// identifier itself is never stored again
+func (c customerDataService) EraseCustomerData(ctx context.Context, actor, customer, reason string) (*models.CustomerErasure, error) {
+	err := auth.Authorize(ctx, models.PermissionEraseCustomerData)
+	if err != nil {
+		return nil, err
+	}
+
+	if actor == "" {
+		return nil, ErrMissingActor
+	}
+
+	if reason == "" {
+		return nil, ErrMissingReason
+	}
+
+	customer = models.NormalizeCustomer(customer)
+	if customer == "" {
+		return nil, ErrMissingCustomer
+	}
+
+	ctx = audit.WithActor(ctx, models.AuditActorOperator, actor)
+
+	erasure := &models.CustomerErasure{
+		ErasureID: fmt.Sprintf("ERS_%s", ulid.Make().String()),
+		Customer:  customer,
+		ErasedAt:  time.Now().UTC(),
+	}
+
+	entry := &models.AuditEntry{
+		Actor:        actor,
+		Action:       models.AuditActionEraseCustomerData,
+		ResourceType: models.AuditResourceCustomer,
+		ResourceID:   erasure.ErasureID,
+		Reason:       reason,
+	}
+
+	err = c.database.EraseCustomerData(ctx, erasure, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.WarnContext(ctx, "customer data erased",
+		slog.String("actor", actor),
+		slog.String("erasure_id", erasure.ErasureID),
+		slog.Int("transactions", erasure.Transactions),
+		slog.Int("risk_decisions", erasure.RiskDecisions),
+		slog.Int("webhook_events", erasure.WebhookEvents),
+		slog.Int("block_list_entries", erasure.BlockListEntries),
+	)
+
+	return erasure, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportCustomerData(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("ExportCustomerData", mock.Anything, "jane@example.com").Return(&models.CustomerData{
+		Customer:     "jane@example.com",
+		Transactions: []*models.Transaction{{TransactionID: "TXN_123"}},
+	}, nil)
+
+	customerDataService := NewCustomerDataService(&mockDatabase)
+
+	data, err := customerDataService.ExportCustomerData(operatorContext(models.OperatorRoleSupport), " Jane@Example.com ")
+	c.NoError(err)
+	c.Len(data.Transactions, 1)
+
+	_, err = customerDataService.ExportCustomerData(operatorContext(models.OperatorRoleSupport), " ")
+	c.Equal(ErrMissingCustomer, err)
+
+	_, err = customerDataService.ExportCustomerData(operatorContext(models.OperatorRoleFinance), "jane@example.com")
+	c.ErrorIs(err, auth.ErrPermissionDenied)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestEraseCustomerData(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("EraseCustomerData", mock.Anything, mock.MatchedBy(func(erasure *models.CustomerErasure) bool {
+		return erasure.Customer == "jane@example.com" && strings.HasPrefix(erasure.ErasureID, "ERS_")
+	}), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionEraseCustomerData &&
+			entry.ResourceType == models.AuditResourceCustomer &&
+			strings.HasPrefix(entry.ResourceID, "ERS_") &&
+			entry.Reason == "right to erasure request"
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.CustomerErasure).Transactions = 2
+	}).Return(nil)
+
+	customerDataService := NewCustomerDataService(&mockDatabase)
+
+	erasure, err := customerDataService.EraseCustomerData(operatorContext(models.OperatorRoleAdmin), "ops", "Jane@Example.com", "right to erasure request")
+	c.NoError(err)
+	c.Equal(2, erasure.Transactions)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestEraseCustomerDataInvalidInput(t *testing.T) {
+	c := require.New(t)
+
+	customerDataService := NewCustomerDataService(&postgres.MockPostgres{})
+
+	_, err := customerDataService.EraseCustomerData(operatorContext(models.OperatorRoleAdmin), "ops", "jane@example.com", "")
+	c.Equal(ErrMissingReason, err)
+
+	_, err = customerDataService.EraseCustomerData(operatorContext(models.OperatorRoleAdmin), "ops", "", "right to erasure request")
+	c.Equal(ErrMissingCustomer, err)
+
+	_, err = customerDataService.EraseCustomerData(operatorContext(models.OperatorRoleSupport), "ops", "jane@example.com", "right to erasure request")
+	c.ErrorIs(err, auth.ErrPermissionDenied)
+}
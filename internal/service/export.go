@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/export"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// ExportPayments writes the transactions of the merchant matching the filter to w, in the given format
+func (o onlinePaymentService) ExportPayments(ctx context.Context, merchantID string, filter *models.TransactionFilter, format export.Format, w io.Writer) error {
+	if merchantID == "" {
+		return ErrMissingMerchantID
+	}
+
+	filter.MerchantID = merchantID
+
+	return exportTransactions(ctx, o.database, filter, format, w)
+}
+
+// ExportTransactions writes the transactions of every merchant matching the filter to w, in the given format
+func (o operatorService) ExportTransactions(ctx context.Context, filter *models.TransactionFilter, format export.Format, w io.Writer) error {
+	err := auth.Authorize(ctx, models.PermissionExportTransactions)
+	if err != nil {
+		return err
+	}
+
+	return exportTransactions(ctx, o.database, filter, format, w)
+}
+
+// exportTransactions streams the transactions matching the filter to w, so exports run in constant memory. Nothing
+// is written to w when the export fails before the first transaction is read
+func exportTransactions(ctx context.Context, db database.Database, filter *models.TransactionFilter, format export.Format, w io.Writer) error {
+	keys, err := db.ListAdditionalFieldKeys(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	writer, err := export.NewWriter(format, w, keys)
+	if err != nil {
+		return api.NewInvalidRequestError(err)
+	}
+
+	exported := 0
+
+	err = db.StreamTransactions(ctx, filter, func(transaction *models.Transaction) error {
+		exported++
+
+		return writer.Write(transaction)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "transactions exported",
+		slog.String("merchant_id", filter.MerchantID),
+		slog.String("format", string(format)),
+		slog.Int("transactions", exported),
+	)
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/limits"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+var (
+	// ErrMaxChargeExceeded error when a charge is over the max single charge of the merchant
+	ErrMaxChargeExceeded = errors.New("max charge")
+	// ErrDailyVolumeExceeded error when a charge takes the merchant over its daily volume
+	ErrDailyVolumeExceeded = errors.New("daily volume")
+	// ErrMonthlyVolumeExceeded error when a charge takes the merchant over its monthly volume
+	ErrMonthlyVolumeExceeded = errors.New("monthly volume")
+	// ErrRefundRatioExceeded error when a refund takes the merchant over its max refund ratio
+	ErrRefundRatioExceeded = errors.New("refund ratio")
+)
+
+// UsageService interface to implement business logic to report the running totals merchants are limited on
+type UsageService interface {
+	ListUsage(ctx context.Context, merchantID string) ([]*models.Usage, error)
+}
+
+type usageService struct {
+	database database.UsageStore
+	limits   limits.Limits
+}
+
+// NewUsageService constructor for the service reporting the usage of merchants
+func NewUsageService(database database.UsageStore, merchantLimits limits.Limits) UsageService {
+	return usageService{
+		database: database,
+		limits:   merchantLimits,
+	}
+}
+
+// ListUsage returns the running totals of the merchant, along with its limits, in every currency it has activity in
+// or a limit configured for
+func (u usageService) ListUsage(ctx context.Context, merchantID string) ([]*models.Usage, error) {
+	if merchantID == "" {
+		return nil, ErrMissingMerchantID
+	}
+
+	usages, err := u.database.ListUsage(ctx, merchantID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	listed := map[string]bool{}
+
+	for _, usage := range usages {
+		listed[usage.Currency] = true
+	}
+
+	for _, currency := range u.limits.Currencies(merchantID) {
+		if !listed[currency] {
+			usages = append(usages, &models.Usage{MerchantID: merchantID, Currency: currency})
+		}
+	}
+
+	for _, usage := range usages {
+		usage.Limits = u.limits.For(merchantID, usage.Currency)
+	}
+
+	return usages, nil
+}
+
+// reserveUsage adds the amounts to the running totals of the merchant before they reach the payment provider, failing
+// with a limit_exceeded error when they take the merchant over one of its limits. Nil limits only record the amounts
+func reserveUsage(ctx context.Context, db database.UsageStore, reservation *models.UsageReservation, merchantLimits *models.MerchantLimits) error {
+	reservation.Currency = strings.ToLower(reservation.Currency)
+
+	_, err := db.ReserveUsage(ctx, reservation, func(usage *models.Usage) error {
+		if merchantLimits == nil {
+			return nil
+		}
+
+		return checkLimits(merchantLimits, reservation, usage)
+	})
+
+	return err
+}
+
+// releaseUsage subtracts the amounts of a payment that didn't go through from the running totals of the merchant. The
+// payment already failed, so failing to release its amounts is reported without failing the operation
+func releaseUsage(ctx context.Context, db database.UsageStore, reservation *models.UsageReservation) {
+	reservation.Currency = strings.ToLower(reservation.Currency)
+
+	err := db.ReleaseUsage(ctx, reservation)
+	if err != nil {
+		slog.ErrorContext(ctx, "release usage failed", slog.String("merchant_id", reservation.MerchantID), slog.String("currency", reservation.Currency), slog.Any("error", err))
+	}
+}
+
+// checkLimits compares the running totals, which already include the reservation, against the limits of the merchant,
+// where zero means no limit
+func checkLimits(merchantLimits *models.MerchantLimits, reservation *models.UsageReservation, usage *models.Usage) error {
+	currency := reservation.Currency
+
+	if reservation.Charged > 0 {
+		if merchantLimits.MaxCharge > 0 && reservation.Charged > merchantLimits.MaxCharge {
+			return api.NewLimitExceededError(fmt.Errorf("%w of %d %s", ErrMaxChargeExceeded, merchantLimits.MaxCharge, currency))
+		}
+
+		if merchantLimits.DailyVolume > 0 && usage.DailyVolume > merchantLimits.DailyVolume {
+			return api.NewLimitExceededError(fmt.Errorf("%w of %d %s", ErrDailyVolumeExceeded, merchantLimits.DailyVolume, currency))
+		}
+
+		if merchantLimits.MonthlyVolume > 0 && usage.MonthlyVolume > merchantLimits.MonthlyVolume {
+			return api.NewLimitExceededError(fmt.Errorf("%w of %d %s", ErrMonthlyVolumeExceeded, merchantLimits.MonthlyVolume, currency))
+		}
+	}
+
+	// the ratio is undefined when nothing was charged in the window, as happens when refunding charges older than the
+	// window, so it's only checked against some volume
+	if reservation.Refunded > 0 && merchantLimits.MaxRefundRatio > 0 && usage.WindowVolume > 0 && float64(usage.WindowRefunds)*100 > merchantLimits.MaxRefundRatio*float64(usage.WindowVolume) {
+		return api.NewLimitExceededError(fmt.Errorf("%w of %g%% over %d days", ErrRefundRatioExceeded, merchantLimits.MaxRefundRatio, models.RefundRatioWindow))
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/limits"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckLimits(t *testing.T) {
+	merchantLimits := &models.MerchantLimits{MaxCharge: 10000, DailyVolume: 50000, MonthlyVolume: 500000, MaxRefundRatio: 10}
+
+	tests := []struct {
+		name        string
+		reservation *models.UsageReservation
+		usage       *models.Usage
+		err         error
+		message     string
+	}{
+		{
+			name:        "within limits",
+			reservation: &models.UsageReservation{Currency: "usd", Charged: 10000},
+			usage:       &models.Usage{DailyVolume: 50000, MonthlyVolume: 500000},
+		},
+		{
+			name:        "max charge",
+			reservation: &models.UsageReservation{Currency: "usd", Charged: 10001},
+			usage:       &models.Usage{DailyVolume: 10001, MonthlyVolume: 10001},
+			err:         ErrMaxChargeExceeded,
+			message:     "(422) Limit exceeded: max charge of 10000 usd",
+		},
+		{
+			name:        "daily volume",
+			reservation: &models.UsageReservation{Currency: "usd", Charged: 2000},
+			usage:       &models.Usage{DailyVolume: 50001, MonthlyVolume: 50001},
+			err:         ErrDailyVolumeExceeded,
+			message:     "(422) Limit exceeded: daily volume of 50000 usd",
+		},
+		{
+			name:        "monthly volume",
+			reservation: &models.UsageReservation{Currency: "usd", Charged: 2000},
+			usage:       &models.Usage{DailyVolume: 2000, MonthlyVolume: 500001},
+			err:         ErrMonthlyVolumeExceeded,
+			message:     "(422) Limit exceeded: monthly volume of 500000 usd",
+		},
+		{
+			name:        "refund ratio",
+			reservation: &models.UsageReservation{Currency: "usd", Refunded: 2000},
+			usage:       &models.Usage{WindowVolume: 100000, WindowRefunds: 10001},
+			err:         ErrRefundRatioExceeded,
+			message:     "(422) Limit exceeded: refund ratio of 10% over 30 days",
+		},
+		{
+			name:        "refund without volume",
+			reservation: &models.UsageReservation{Currency: "usd", Refunded: 2000},
+			usage:       &models.Usage{WindowRefunds: 2000},
+		},
+		{
+			name:        "refunds skip volume limits",
+			reservation: &models.UsageReservation{Currency: "usd", Refunded: 2000},
+			usage:       &models.Usage{DailyVolume: 50001, WindowVolume: 100000, WindowRefunds: 10000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := require.New(t)
+
+			err := checkLimits(merchantLimits, tt.reservation, tt.usage)
+			if tt.err == nil {
+				c.NoError(err)
+				return
+			}
+
+			c.ErrorIs(err, tt.err)
+			c.Equal(tt.message, err.Error())
+		})
+	}
+}
+
+func TestProcessPaymentLimitExceeded(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("ReserveUsage", mock.Anything, mock.MatchedBy(func(reservation *models.UsageReservation) bool {
+		return reservation.MerchantID == "MCH_123" && reservation.Currency == "usd" && reservation.Charged == 2000
+	}), mock.Anything).Return(&models.Usage{DailyVolume: 6000, MonthlyVolume: 6000}, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+		limits: limits.New(config.Limits{
+			LimitPlan: config.LimitPlan{Default: config.Limit{DailyVolume: 5000}},
+		}),
+	}
+
+	_, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", &models.TransactionInput{
+		Amount:        2000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+	})
+	c.ErrorIs(err, ErrDailyVolumeExceeded)
+
+	mockPaymentProcessor.AssertNotCalled(t, "PerformTransaction", mock.Anything, mock.Anything)
+}
+
+func TestProcessPaymentDeclinedReleasesUsage(t *testing.T) {
+	c := require.New(t)
+
+	input := &models.TransactionInput{
+		Amount:        2000,
+		Currency:      "usd",
+		PaymentMethod: "pm_card_chargeDeclined",
+	}
+
+	declinedTransaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusFailure,
+		FailureReason: "card_declined",
+		Amount:        2000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("ReserveUsage", mock.Anything, mock.Anything, mock.Anything).Return(&models.Usage{DailyVolume: 2000}, nil)
+	mockPaymentProcessor.On("PerformTransaction", mock.Anything, input).Return(declinedTransaction, nil)
+	mockDatabase.On("ReleaseUsage", mock.Anything, mock.MatchedBy(func(reservation *models.UsageReservation) bool {
+		return reservation.MerchantID == "MCH_123" && reservation.Charged == 2000
+	})).Return(nil)
+	mockDatabase.On("InsertTransaction", mock.Anything, declinedTransaction, mock.Anything).Return(nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	transaction, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.NoError(err)
+	c.Equal(models.TransactionStatusFailure, transaction.Status)
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestRefundPaymentRefundRatioExceeded(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := &models.Transaction{
+		TransactionID:    "TXN_123",
+		MerchantID:       "MCH_123",
+		Status:           models.TransactionStatusSucceeded,
+		Amount:           2000,
+		Currency:         "usd",
+		Type:             models.TransactionTypeCharge,
+		AdditionalFields: map[string]interface{}{"charge_id": "ch_123"},
+	}
+
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(transaction, nil)
+	mockDatabase.On("ReserveUsage", mock.Anything, mock.MatchedBy(func(reservation *models.UsageReservation) bool {
+		return reservation.Refunded == 2000 && reservation.Charged == 0
+	}), mock.Anything).Return(&models.Usage{WindowVolume: 10000, WindowRefunds: 2000}, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+		limits: limits.New(config.Limits{
+			Merchants: map[string]config.LimitPlan{
+				"MCH_123": {Currencies: map[string]config.Limit{"usd": {MaxRefundRatio: 5}}},
+			},
+		}),
+	}
+
+	_, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "TXN_123", 0)
+	c.ErrorIs(err, ErrRefundRatioExceeded)
+
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
+}
+
+func TestListUsage(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("ListUsage", mock.Anything, "MCH_123", mock.Anything).Return([]*models.Usage{
+		{MerchantID: "MCH_123", Currency: "usd", DailyVolume: 2000, MonthlyVolume: 2000, WindowVolume: 2000},
+	}, nil)
+
+	usageService := NewUsageService(&mockDatabase, limits.New(config.Limits{
+		LimitPlan: config.LimitPlan{
+			Default:    config.Limit{DailyVolume: 100000},
+			Currencies: map[string]config.Limit{"EUR": {DailyVolume: 50000}},
+		},
+	}))
+
+	usages, err := usageService.ListUsage(context.Background(), "MCH_123")
+	c.NoError(err)
+	c.Len(usages, 2)
+	c.Equal("usd", usages[0].Currency)
+	c.Equal(&models.MerchantLimits{DailyVolume: 100000}, usages[0].Limits)
+	c.Equal("eur", usages[1].Currency)
+	c.Equal(0, usages[1].DailyVolume)
+	c.Equal(&models.MerchantLimits{DailyVolume: 50000}, usages[1].Limits)
+}
+
+func TestListUsageMissingMerchantID(t *testing.T) {
+	c := require.New(t)
+
+	usageService := NewUsageService(&postgres.MockPostgres{}, limits.Limits{})
+
+	_, err := usageService.ListUsage(context.Background(), "")
+	c.ErrorIs(err, ErrMissingMerchantID)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	// ErrMissingAccountName error when connected account name is missing
+	ErrMissingAccountName = api.NewInvalidRequestError(errors.New("missing account name"))
+	// ErrInvalidProviderAccountID error when the connected account isn't a Stripe Connect account
+	ErrInvalidProviderAccountID = api.NewInvalidRequestError(errors.New("invalid provider account id"))
+)
+
+// MarketplaceService interface to implement business logic for the connected accounts of marketplace merchants
+type MarketplaceService interface {
+	CreateConnectedAccount(ctx context.Context, merchantID, name, providerAccountID string) (*models.ConnectedAccount, error)
+	ListConnectedAccounts(ctx context.Context, merchantID string) ([]*models.ConnectedAccount, error)
+}
+
+type marketplaceService struct {
+	database database.MarketplaceStore
+}
+
+// NewMarketplaceService constructor for marketplace service
+func NewMarketplaceService(database database.MarketplaceStore) MarketplaceService {
+	return marketplaceService{
+		database: database,
+	}
+}
+
+// CreateConnectedAccount maps a Stripe Connect account of one of the merchant's sellers to a connected account
+func (m marketplaceService) CreateConnectedAccount(ctx context.Context, merchantID, name, providerAccountID string) (*models.ConnectedAccount, error) {
+	if merchantID == "" {
+		return nil, ErrMissingMerchantID
+	}
+
+	if name == "" {
+		return nil, ErrMissingAccountName
+	}
+
+	if !strings.HasPrefix(providerAccountID, "acct_") {
+		return nil, ErrInvalidProviderAccountID
+	}
+
+	account := &models.ConnectedAccount{
+		AccountID:         fmt.Sprintf("ACC_%s", ulid.Make().String()),
+		MerchantID:        merchantID,
+		Name:              name,
+		Provider:          models.PaymentProviderStripe,
+		ProviderAccountID: providerAccountID,
+		CreatedAt:         time.Now().UTC(),
+	}
+
+	err := m.database.InsertConnectedAccount(ctx, account, &models.AuditEntry{
+		Action:       models.AuditActionCreateConnectedAccount,
+		ResourceType: models.AuditResourceConnectedAccount,
+		ResourceID:   account.AccountID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// ListConnectedAccounts lists the connected accounts of the merchant
+func (m marketplaceService) ListConnectedAccounts(ctx context.Context, merchantID string) ([]*models.ConnectedAccount, error) {
+	if merchantID == "" {
+		return nil, ErrMissingMerchantID
+	}
+
+	return m.database.ListConnectedAccounts(ctx, merchantID)
+}
+
+// getMerchantConnectedAccount fetches a connected account, hiding it from merchants other than its owner
+func getMerchantConnectedAccount(ctx context.Context, db database.MarketplaceStore, merchantID, accountID string) (*models.ConnectedAccount, error) {
+	account, err := db.GetConnectedAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.MerchantID != merchantID {
+		return nil, api.NewResourceNotFoundError(database.ErrConnectedAccountNotFound, "connected_account")
+	}
+
+	return account, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateConnectedAccount(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("InsertConnectedAccount", mock.Anything, mock.MatchedBy(func(account *models.ConnectedAccount) bool {
+		return account.MerchantID == "MCH_123" && account.ProviderAccountID == "acct_123" && account.Provider == models.PaymentProviderStripe
+	}), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionCreateConnectedAccount && entry.ResourceType == models.AuditResourceConnectedAccount
+	})).Return(nil)
+
+	marketplaceService := NewMarketplaceService(&mockDatabase)
+
+	account, err := marketplaceService.CreateConnectedAccount(context.Background(), "MCH_123", "Seller", "acct_123")
+	c.NoError(err)
+	c.Contains(account.AccountID, "ACC_")
+	c.Equal("Seller", account.Name)
+}
+
+func TestCreateConnectedAccountInvalidInput(t *testing.T) {
+	c := require.New(t)
+
+	marketplaceService := NewMarketplaceService(&postgres.MockPostgres{})
+
+	_, err := marketplaceService.CreateConnectedAccount(context.Background(), "MCH_123", "", "acct_123")
+	c.ErrorIs(err, ErrMissingAccountName)
+
+	_, err = marketplaceService.CreateConnectedAccount(context.Background(), "MCH_123", "Seller", "cus_123")
+	c.ErrorIs(err, ErrInvalidProviderAccountID)
+
+	_, err = marketplaceService.CreateConnectedAccount(context.Background(), "", "Seller", "acct_123")
+	c.ErrorIs(err, ErrMissingMerchantID)
+}
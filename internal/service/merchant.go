@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	// ErrMissingMerchantName error when merchant name is missing
+	ErrMissingMerchantName = api.NewInvalidRequestError(errors.New("missing merchant name"))
+	// ErrMissingAPIKeyID error when API key ID is missing
+	ErrMissingAPIKeyID = api.NewInvalidRequestError(errors.New("missing api key id"))
+)
+
+var issuedKeys = []struct {
+	keyType models.APIKeyType
+	mode    models.APIKeyMode
+}{
+	{models.APIKeyTypeSecret, models.APIKeyModeTest},
+	{models.APIKeyTypePublishable, models.APIKeyModeTest},
+	{models.APIKeyTypeSecret, models.APIKeyModeLive},
+	{models.APIKeyTypePublishable, models.APIKeyModeLive},
+}
+
+// MerchantService interface to implement business logic for merchant accounts and their API keys
+type MerchantService interface {
+	CreateMerchant(ctx context.Context, name string) (*models.MerchantCredentials, error)
+	RotateAPIKey(ctx context.Context, merchantID, keyID string) (*models.APIKeyCredential, error)
+}
+
+type merchantService struct {
+	database            database.MerchantStore
+	rotationGracePeriod time.Duration
+}
+
+// NewMerchantService constructor for merchant service, rotated keys remain valid during the grace period
+func NewMerchantService(database database.MerchantStore, rotationGracePeriod time.Duration) MerchantService {
+	return merchantService{
+		database:            database,
+		rotationGracePeriod: rotationGracePeriod,
+	}
+}
+
+// CreateMerchant creates a merchant along with secret and publishable keys for both modes
+func (m merchantService) CreateMerchant(ctx context.Context, name string) (*models.MerchantCredentials, error) {
+	if name == "" {
+		return nil, ErrMissingMerchantName
+	}
+
+	merchant := models.Merchant{
+		MerchantID: fmt.Sprintf("MCH_%s", ulid.Make().String()),
+		Name:       name,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	err := m.database.InsertMerchant(ctx, &merchant, &models.AuditEntry{
+		Action:       models.AuditActionCreateMerchant,
+		ResourceType: models.AuditResourceMerchant,
+		ResourceID:   merchant.MerchantID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := &models.MerchantCredentials{
+		Merchant: merchant,
+	}
+
+	for _, issued := range issuedKeys {
+		credential, err := m.issueAPIKey(ctx, merchant.MerchantID, issued.keyType, issued.mode)
+		if err != nil {
+			return nil, err
+		}
+
+		credentials.APIKeys = append(credentials.APIKeys, credential)
+	}
+
+	return credentials, nil
+}
+
+// RotateAPIKey issues a replacement for an active key and keeps the old one valid until the grace period ends. Keys
+// already rotated or expired can't be rotated again, so every key has at most one replacement
+func (m merchantService) RotateAPIKey(ctx context.Context, merchantID, keyID string) (*models.APIKeyCredential, error) {
+	if merchantID == "" {
+		return nil, ErrMissingMerchantID
+	}
+
+	if keyID == "" {
+		return nil, ErrMissingAPIKeyID
+	}
+
+	key, err := m.database.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.MerchantID != merchantID {
+		return nil, api.NewResourceNotFoundError(database.ErrAPIKeyNotFound, "api_key")
+	}
+
+	if key.ExpiresAt != nil {
+		return nil, api.NewConflictError(database.ErrAPIKeyRotated)
+	}
+
+	credential, err := auth.GenerateAPIKey(merchantID, key.Type, key.Mode)
+	if err != nil {
+		return nil, api.NewInternalServerError(err)
+	}
+
+	expiresAt := time.Now().UTC().Add(m.rotationGracePeriod)
+
+	err = m.database.RotateAPIKey(ctx, keyID, expiresAt, &credential.APIKey, &models.AuditEntry{
+		Action:       models.AuditActionRotateAPIKey,
+		ResourceType: models.AuditResourceAPIKey,
+		ResourceID:   keyID,
+		Details:      map[string]interface{}{"replacement_key_id": credential.APIKey.KeyID, "expires_at": expiresAt},
+		Before:       models.AuditSnapshot(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+func (m merchantService) issueAPIKey(ctx context.Context, merchantID string, keyType models.APIKeyType, mode models.APIKeyMode) (*models.APIKeyCredential, error) {
+	credential, err := auth.GenerateAPIKey(merchantID, keyType, mode)
+	if err != nil {
+		return nil, api.NewInternalServerError(err)
+	}
+
+	err = m.database.InsertAPIKey(ctx, &credential.APIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMerchant(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("InsertMerchant", context.Background(), mock.AnythingOfType("*models.Merchant"), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionCreateMerchant && entry.ResourceType == models.AuditResourceMerchant && strings.HasPrefix(entry.ResourceID, "MCH_")
+	})).Return(nil)
+	mockDatabase.On("InsertAPIKey", context.Background(), mock.AnythingOfType("*models.APIKey")).Return(nil)
+
+	merchantService := NewMerchantService(&mockDatabase, time.Hour)
+
+	credentials, err := merchantService.CreateMerchant(context.Background(), "Acme")
+	c.NoError(err)
+	c.Equal("Acme", credentials.Name)
+	c.True(strings.HasPrefix(credentials.MerchantID, "MCH_"))
+	c.Len(credentials.APIKeys, 4)
+
+	for _, credential := range credentials.APIKeys {
+		c.Equal(credentials.MerchantID, credential.MerchantID)
+		c.Equal(auth.HashAPIKey(credential.Key), credential.Hash)
+	}
+
+	mockDatabase.AssertNumberOfCalls(t, "InsertAPIKey", 4)
+}
+
+func TestCreateMerchantMissingName(t *testing.T) {
+	c := require.New(t)
+
+	merchantService := NewMerchantService(nil, time.Hour)
+
+	_, err := merchantService.CreateMerchant(context.Background(), "")
+	c.ErrorIs(err, ErrMissingMerchantName)
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	existingKey := &models.APIKey{
+		KeyID:      "KEY_123",
+		MerchantID: "MCH_123",
+		Type:       models.APIKeyTypeSecret,
+		Mode:       models.APIKeyModeTest,
+	}
+
+	mockDatabase.On("GetAPIKey", context.Background(), "KEY_123").Return(existingKey, nil)
+	mockDatabase.On("RotateAPIKey", context.Background(), "KEY_123", mock.AnythingOfType("time.Time"), mock.AnythingOfType("*models.APIKey"), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionRotateAPIKey && entry.ResourceID == "KEY_123" && entry.Details["replacement_key_id"] != nil
+	})).Return(nil)
+
+	merchantService := NewMerchantService(&mockDatabase, time.Hour)
+
+	credential, err := merchantService.RotateAPIKey(context.Background(), "MCH_123", "KEY_123")
+	c.NoError(err)
+	c.NotEqual("KEY_123", credential.KeyID)
+	c.Equal(existingKey.Type, credential.Type)
+	c.Equal(existingKey.Mode, credential.Mode)
+	c.True(strings.HasPrefix(credential.Key, "sk_test_"))
+
+	expiresAt := mockDatabase.Calls[1].Arguments.Get(2).(time.Time)
+	c.WithinDuration(time.Now().Add(time.Hour), expiresAt, time.Minute)
+	c.Equal(&credential.APIKey, mockDatabase.Calls[1].Arguments.Get(3))
+}
+
+func TestRotateAPIKeyAlreadyRotated(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	mockDatabase.On("GetAPIKey", context.Background(), "KEY_123").Return(&models.APIKey{
+		KeyID:      "KEY_123",
+		MerchantID: "MCH_123",
+		Type:       models.APIKeyTypeSecret,
+		Mode:       models.APIKeyModeTest,
+		ExpiresAt:  &expiresAt,
+	}, nil)
+
+	merchantService := NewMerchantService(&mockDatabase, time.Hour)
+
+	_, err := merchantService.RotateAPIKey(context.Background(), "MCH_123", "KEY_123")
+	c.ErrorIs(err, database.ErrAPIKeyRotated, "keys still in their grace period aren't rotated again")
+	mockDatabase.AssertNotCalled(t, "RotateAPIKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRotateAPIKeyOtherMerchant(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	existingKey := &models.APIKey{
+		KeyID:      "KEY_123",
+		MerchantID: "MCH_456",
+		Type:       models.APIKeyTypeSecret,
+		Mode:       models.APIKeyModeTest,
+	}
+
+	mockDatabase.On("GetAPIKey", context.Background(), "KEY_123").Return(existingKey, nil)
+
+	merchantService := NewMerchantService(&mockDatabase, time.Hour)
+
+	_, err := merchantService.RotateAPIKey(context.Background(), "MCH_123", "KEY_123")
+	c.ErrorIs(err, database.ErrAPIKeyNotFound)
+	mockDatabase.AssertNotCalled(t, "RotateAPIKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
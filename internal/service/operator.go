@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/events"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/export"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/limits"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
+)
+
+var (
+	// ErrMissingActor error when the operator performing an operation is unknown
+	ErrMissingActor = api.NewInvalidRequestError(errors.New("missing actor"))
+	// ErrMissingReason error when the reason of an operation that requires it is missing
+	ErrMissingReason = api.NewInvalidRequestError(errors.New("missing reason"))
+	// ErrMissingEventID error when webhook event ID is missing
+	ErrMissingEventID = api.NewInvalidRequestError(errors.New("missing event id"))
+	// ErrInvalidStatus error when the status is not a known transaction status
+	ErrInvalidStatus = api.NewInvalidRequestError(errors.New("invalid transaction status"))
+	// ErrWebhookEventPayloadPruned error when the payload of a webhook event was dropped by the retention policy
+	ErrWebhookEventPayloadPruned = api.NewConflictError(errors.New("webhook event payload pruned"))
+)
+
+// OperatorService interface to implement the operations performed by the platform operators, across every merchant
+type OperatorService interface {
+	GetTransaction(ctx context.Context, transactionID string) (*models.Transaction, error)
+	ListTransactions(ctx context.Context, filter *models.TransactionFilter) ([]*models.Transaction, error)
+	RefundTransaction(ctx context.Context, actor, transactionID, reason string) (*models.Transaction, error)
+	ReplayWebhookEvent(ctx context.Context, actor, eventID, reason string) (*models.WebhookEvent, error)
+	Reconcile(ctx context.Context, filter *models.TransactionFilter) ([]*models.Discrepancy, error)
+	ForceTransactionStatus(ctx context.Context, actor, transactionID string, status models.TransactionStatus, reason string) (*models.Transaction, error)
+	ExportTransactions(ctx context.Context, filter *models.TransactionFilter, format export.Format, w io.Writer) error
+	ReencryptTransactions(ctx context.Context) (int, error)
+}
+
+// reencryptBatchSize transactions re-encrypted in each database transaction, which bounds how long their rows stay locked
+const reencryptBatchSize = 100
+
+type operatorService struct {
+	database         database.Database
+	paymentProcessor paymentprocessor.PaymentProcessor
+	limits           limits.Limits
+}
+
+// NewOperatorService constructor for operator service
+func NewOperatorService(database database.Database, paymentProcessor paymentprocessor.PaymentProcessor, merchantLimits limits.Limits) OperatorService {
+	return operatorService{
+		database:         database,
+		paymentProcessor: paymentProcessor,
+		limits:           merchantLimits,
+	}
+}
+
+// GetTransaction fetches a transaction of any merchant
+func (o operatorService) GetTransaction(ctx context.Context, transactionID string) (*models.Transaction, error) {
+	err := auth.Authorize(ctx, models.PermissionReadTransactions)
+	if err != nil {
+		return nil, err
+	}
+
+	if transactionID == "" {
+		return nil, ErrMissingTransactionID
+	}
+
+	return o.database.GetTransaction(ctx, transactionID)
+}
+
+// ListTransactions fetches the transactions matching the filter, most recent first
+func (o operatorService) ListTransactions(ctx context.Context, filter *models.TransactionFilter) ([]*models.Transaction, error) {
+	err := auth.Authorize(ctx, models.PermissionReadTransactions)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.database.ListTransactions(ctx, filter)
+}
+
+// RefundTransaction refunds a transaction of any merchant and records who requested it in the audit trail
+func (o operatorService) RefundTransaction(ctx context.Context, actor, transactionID, reason string) (*models.Transaction, error) {
+	err := auth.Authorize(ctx, models.PermissionRefundTransactions)
+	if err != nil {
+		return nil, err
+	}
+
+	if actor == "" {
+		return nil, ErrMissingActor
+	}
+
+	ctx = audit.WithActor(ctx, models.AuditActorOperator, actor)
+
+	transaction, err := o.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// refunds over the approval threshold of the merchant need a second operator, and record the request instead
+	if requiresApproval(transaction, o.limits.For(transaction.MerchantID, transaction.Currency)) {
+		return requestRefund(ctx, o.database, transaction, reason, nil)
+	}
+
+	// refunds issued by operators count towards the refund ratio of the merchant without being limited by it
+	return refundTransaction(ctx, o.database, o.paymentProcessor, transaction, nil, reason)
+}
+
+// ReplayWebhookEvent processes a stored webhook event again, recording the outcome of the attempt and who requested it
+func (o operatorService) ReplayWebhookEvent(ctx context.Context, actor, eventID, reason string) (*models.WebhookEvent, error) {
+	err := auth.Authorize(ctx, models.PermissionReplayWebhookEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	if actor == "" {
+		return nil, ErrMissingActor
+	}
+
+	ctx = audit.WithActor(ctx, models.AuditActorOperator, actor)
+
+	if eventID == "" {
+		return nil, ErrMissingEventID
+	}
+
+	event, err := o.database.GetWebhookEvent(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(event.Payload) == 0 {
+		return nil, ErrWebhookEventPayloadPruned
+	}
+
+	eventHandler, err := events.NewStoredEvent(event, o.database, o.paymentProcessor)
+	if err != nil {
+		return nil, err
+	}
+
+	processErr := eventHandler.ProcessEvent(ctx)
+
+	err = o.database.RecordWebhookEventOutcome(ctx, eventID, processErr)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{"succeeded": processErr == nil}
+	if processErr != nil {
+		details["error"] = processErr.Error()
+	}
+
+	err = o.database.InsertAuditEntry(ctx, &models.AuditEntry{
+		Actor:        actor,
+		Action:       models.AuditActionReplayWebhookEvent,
+		ResourceType: models.AuditResourceWebhookEvent,
+		ResourceID:   eventID,
+		Reason:       reason,
+		Details:      details,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if processErr != nil {
+		return nil, processErr
+	}
+
+	return o.database.GetWebhookEvent(ctx, eventID)
+}
+
+// Reconcile compares the transactions matching the filter with their state in the payment provider, returning the
+// transactions whose status or type differ, along with the ones that couldn't be queried
+func (o operatorService) Reconcile(ctx context.Context, filter *models.TransactionFilter) ([]*models.Discrepancy, error) {
+	err := auth.Authorize(ctx, models.PermissionReadTransactions)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := o.database.ListTransactions(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies := []*models.Discrepancy{}
+
+	for _, transaction := range transactions {
+		discrepancy := &models.Discrepancy{
+			TransactionID: transaction.TransactionID,
+			Status:        transaction.Status,
+			Type:          transaction.Type,
+		}
+
+		providerTransaction, err := o.paymentProcessor.QueryTransaction(ctx, transaction.AdditionalFields)
+		if err != nil {
+			discrepancy.Error = err.Error()
+			discrepancies = append(discrepancies, discrepancy)
+
+			continue
+		}
+
+		if providerTransaction.Status == transaction.Status && providerTransaction.Type == transaction.Type {
+			continue
+		}
+
+		discrepancy.ProviderStatus = providerTransaction.Status
+		discrepancy.ProviderType = providerTransaction.Type
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	slog.InfoContext(ctx, "transactions reconciled",
+		slog.Int("transactions", len(transactions)),
+		slog.Int("discrepancies", len(discrepancies)),
+	)
+
+	return discrepancies, nil
+}
+
+// ForceTransactionStatus overrides the status of a transaction without involving its provider. The reason is
+// mandatory and kept in the audit trail along with the previous status
+func (o operatorService) ForceTransactionStatus(ctx context.Context, actor, transactionID string, status models.TransactionStatus, reason string) (*models.Transaction, error) {
+	err := auth.Authorize(ctx, models.PermissionForceTransactionStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	if actor == "" {
+		return nil, ErrMissingActor
+	}
+
+	if reason == "" {
+		return nil, ErrMissingReason
+	}
+
+	if !status.IsValid() {
+		return nil, ErrInvalidStatus
+	}
+
+	ctx = audit.WithActor(ctx, models.AuditActorOperator, actor)
+
+	transaction, err := o.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &models.AuditEntry{
+		Actor:        actor,
+		Action:       models.AuditActionForceStatus,
+		ResourceType: models.AuditResourceTransaction,
+		ResourceID:   transactionID,
+		Reason:       reason,
+		Details: map[string]interface{}{
+			"previous_status": transaction.Status,
+			"status":          status,
+		},
+		Before: models.AuditSnapshot(transaction),
+	}
+
+	updatedTransaction, err := o.database.ForceTransactionStatus(ctx, transactionID, status, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.WarnContext(logging.WithTransactionID(ctx, transactionID), "transaction status forced",
+		slog.String("actor", actor),
+		slog.String("previous_status", string(transaction.Status)),
+		slog.String("status", string(status)),
+	)
+
+	return updatedTransaction, nil
+}
+
+// ReencryptTransactions encrypts with the active key the encrypted additional fields of every transaction still
+// stored with an older key or in plaintext, in batches, archived ones included, returning how many transactions
+// were updated. Outbox payloads, audit log snapshots and archive files keep the envelopes they were written with, so
+// older keys must still be kept
+func (o operatorService) ReencryptTransactions(ctx context.Context) (int, error) {
+	err := auth.Authorize(ctx, models.PermissionReencryptTransactions)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+
+	for {
+		reencrypted, err := o.database.ReencryptTransactions(ctx, reencryptBatchSize)
+		total += reencrypted
+
+		if err != nil {
+			return total, err
+		}
+
+		if reencrypted < reencryptBatchSize {
+			break
+		}
+	}
+
+	slog.InfoContext(ctx, "transactions reencrypted", slog.Int("transactions", total))
+
+	return total, nil
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/export"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// operatorContext returns a context carrying an operator authenticated with the role
+func operatorContext(role models.OperatorRole) context.Context {
+	return auth.WithOperator(context.Background(), &models.Operator{Name: "ops", Role: role})
+}
+
+func TestOperatorRefundTransaction(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusSucceeded,
+		Type:          models.TransactionTypeCharge,
+		Amount:        2000,
+		Currency:      "usd",
+		AdditionalFields: map[string]interface{}{
+			"charge_id": "ch_123",
+		},
+	}
+
+	refundedTransaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusSucceeded,
+		Type:          models.TransactionTypeRefund,
+	}
+
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(transaction, nil)
+	// refunds issued by operators are recorded even when the merchant is over its refund ratio
+	mockDatabase.On("ReserveUsage", mock.Anything, mock.MatchedBy(func(reservation *models.UsageReservation) bool {
+		return reservation.MerchantID == "MCH_123" && reservation.Refunded == 2000 && reservation.Charged == 0
+	}), mock.Anything).Return(&models.Usage{WindowRefunds: 2000}, nil)
+	mockPaymentProcessor.On("RefundTransaction", mock.Anything, transaction.AdditionalFields).Return(refundedTransaction, nil)
+	mockDatabase.On("UpdateTransaction", mock.Anything, "TXN_123", refundedTransaction, mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionRefund && entry.ResourceID == "TXN_123" && entry.Reason == "customer complaint" && entry.Before != nil
+	})).Return(refundedTransaction, nil)
+
+	operatorService := operatorService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	result, err := operatorService.RefundTransaction(operatorContext(models.OperatorRoleAdmin), "ops", "TXN_123", "customer complaint")
+	c.NoError(err)
+	c.Equal(refundedTransaction, result)
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestOperatorForceTransactionStatus(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusPending,
+		Version:       3,
+	}
+
+	forcedTransaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusFailure,
+		Version:       4,
+	}
+
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(transaction, nil)
+	mockDatabase.On("ForceTransactionStatus", mock.Anything, "TXN_123", models.TransactionStatusFailure, mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionForceStatus && entry.Reason == "stuck after provider outage" && entry.Details["previous_status"] == models.TransactionStatusPending
+	})).Return(forcedTransaction, nil)
+
+	operatorService := operatorService{
+		database: &mockDatabase,
+	}
+
+	result, err := operatorService.ForceTransactionStatus(operatorContext(models.OperatorRoleAdmin), "ops", "TXN_123", models.TransactionStatusFailure, "stuck after provider outage")
+	c.NoError(err)
+	c.Equal(forcedTransaction, result)
+}
+
+func TestOperatorForceTransactionStatusInvalidInput(t *testing.T) {
+	c := require.New(t)
+
+	operatorService := operatorService{}
+
+	_, err := operatorService.ForceTransactionStatus(operatorContext(models.OperatorRoleAdmin), "ops", "TXN_123", models.TransactionStatusFailure, "")
+	c.ErrorIs(err, ErrMissingReason)
+
+	_, err = operatorService.ForceTransactionStatus(operatorContext(models.OperatorRoleAdmin), "ops", "TXN_123", "refunded", "typo")
+	c.ErrorIs(err, ErrInvalidStatus)
+
+	_, err = operatorService.ForceTransactionStatus(operatorContext(models.OperatorRoleAdmin), "", "TXN_123", models.TransactionStatusFailure, "typo")
+	c.ErrorIs(err, ErrMissingActor)
+}
+
+func TestOperatorForceTransactionStatusForbidden(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	operatorService := operatorService{database: &mockDatabase}
+
+	_, err := operatorService.ForceTransactionStatus(operatorContext(models.OperatorRoleFinance), "ops", "TXN_123", models.TransactionStatusFailure, "stuck after provider outage")
+
+	var apiErr api.APIError
+
+	c.ErrorAs(err, &apiErr)
+	c.Equal(api.ErrCodeForbidden, apiErr.Code())
+	c.ErrorIs(err, auth.ErrPermissionDenied)
+
+	_, err = operatorService.ForceTransactionStatus(context.Background(), "ops", "TXN_123", models.TransactionStatusFailure, "stuck after provider outage")
+
+	c.ErrorAs(err, &apiErr)
+	c.Equal(api.ErrCodeUnauthorized, apiErr.Code())
+
+	mockDatabase.AssertNotCalled(t, "GetTransaction", mock.Anything, mock.Anything)
+}
+
+func TestOperatorReencryptTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("ReencryptTransactions", mock.Anything, reencryptBatchSize).Return(reencryptBatchSize, nil).Once()
+	mockDatabase.On("ReencryptTransactions", mock.Anything, reencryptBatchSize).Return(3, nil).Once()
+
+	operatorService := operatorService{database: &mockDatabase}
+
+	reencrypted, err := operatorService.ReencryptTransactions(operatorContext(models.OperatorRoleAdmin))
+	c.NoError(err)
+	c.Equal(reencryptBatchSize+3, reencrypted)
+
+	_, err = operatorService.ReencryptTransactions(operatorContext(models.OperatorRoleFinance))
+	c.ErrorIs(err, auth.ErrPermissionDenied)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestOperatorReconcile(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	matching := &models.Transaction{
+		TransactionID:    "TXN_1",
+		Status:           models.TransactionStatusSucceeded,
+		Type:             models.TransactionTypeCharge,
+		AdditionalFields: map[string]interface{}{"payment_intent_id": "pi_1"},
+	}
+
+	stale := &models.Transaction{
+		TransactionID:    "TXN_2",
+		Status:           models.TransactionStatusPending,
+		Type:             models.TransactionTypeCharge,
+		AdditionalFields: map[string]interface{}{"payment_intent_id": "pi_2"},
+	}
+
+	unknown := &models.Transaction{
+		TransactionID:    "TXN_3",
+		Status:           models.TransactionStatusSucceeded,
+		Type:             models.TransactionTypeCharge,
+		AdditionalFields: map[string]interface{}{},
+	}
+
+	filter := &models.TransactionFilter{MerchantID: "MCH_123"}
+
+	mockDatabase.On("ListTransactions", mock.Anything, filter).Return([]*models.Transaction{matching, stale, unknown}, nil)
+	mockPaymentProcessor.On("QueryTransaction", mock.Anything, matching.AdditionalFields).Return(&models.Transaction{Status: models.TransactionStatusSucceeded, Type: models.TransactionTypeCharge}, nil)
+	mockPaymentProcessor.On("QueryTransaction", mock.Anything, stale.AdditionalFields).Return(&models.Transaction{Status: models.TransactionStatusSucceeded, Type: models.TransactionTypeRefund}, nil)
+	mockPaymentProcessor.On("QueryTransaction", mock.Anything, unknown.AdditionalFields).Return(nil, errors.New("missing payment intent ID"))
+
+	operatorService := operatorService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	discrepancies, err := operatorService.Reconcile(operatorContext(models.OperatorRoleAdmin), filter)
+	c.NoError(err)
+	c.Equal([]*models.Discrepancy{
+		{
+			TransactionID:  "TXN_2",
+			Status:         models.TransactionStatusPending,
+			ProviderStatus: models.TransactionStatusSucceeded,
+			Type:           models.TransactionTypeCharge,
+			ProviderType:   models.TransactionTypeRefund,
+		},
+		{
+			TransactionID: "TXN_3",
+			Status:        models.TransactionStatusSucceeded,
+			Type:          models.TransactionTypeCharge,
+			Error:         "missing payment intent ID",
+		},
+	}, discrepancies)
+}
+
+func TestOperatorReplayWebhookEventMissingEventID(t *testing.T) {
+	c := require.New(t)
+
+	operatorService := operatorService{}
+
+	_, err := operatorService.ReplayWebhookEvent(operatorContext(models.OperatorRoleAdmin), "ops", "", "")
+	c.ErrorIs(err, ErrMissingEventID)
+}
+
+func TestOperatorReplayWebhookEventPayloadPruned(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("GetWebhookEvent", mock.Anything, "evt_123").Return(&models.WebhookEvent{EventID: "evt_123", Provider: models.PaymentProviderStripe}, nil)
+
+	operatorService := operatorService{database: &mockDatabase}
+
+	_, err := operatorService.ReplayWebhookEvent(operatorContext(models.OperatorRoleAdmin), "ops", "evt_123", "")
+	c.Equal(ErrWebhookEventPayloadPruned, err)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestExportTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	filter := &models.TransactionFilter{Status: models.TransactionStatusSucceeded}
+
+	transactions := []*models.Transaction{
+		{TransactionID: "TXN_2", Amount: 1500, Currency: "jpy", AdditionalFields: map[string]interface{}{"charge_id": "ch_2"}},
+		{TransactionID: "TXN_1", Amount: 1500, Currency: "usd", AdditionalFields: map[string]interface{}{}},
+	}
+
+	mockDatabase.On("ListAdditionalFieldKeys", mock.Anything, filter).Return([]string{"charge_id"}, nil)
+	mockDatabase.On("StreamTransactions", mock.Anything, filter, mock.Anything).Return(transactions, nil)
+
+	operatorService := operatorService{
+		database: &mockDatabase,
+	}
+
+	var buf bytes.Buffer
+
+	err := operatorService.ExportTransactions(operatorContext(models.OperatorRoleAdmin), filter, export.FormatCSV, &buf)
+	c.NoError(err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Len(lines, 3)
+	c.True(strings.HasSuffix(lines[0], ",additional_fields.charge_id"))
+	c.True(strings.HasPrefix(lines[1], "TXN_2,,,,1500,jpy,"))
+	c.True(strings.HasSuffix(lines[1], ",ch_2"))
+	c.True(strings.HasPrefix(lines[2], "TXN_1,,,,15.00,usd,"))
+}
+
+func TestExportPaymentsScopedToMerchant(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	expectedFilter := &models.TransactionFilter{MerchantID: "MCH_123"}
+
+	mockDatabase.On("ListAdditionalFieldKeys", mock.Anything, expectedFilter).Return([]string{}, nil)
+	mockDatabase.On("StreamTransactions", mock.Anything, expectedFilter, mock.Anything).Return(nil, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database: &mockDatabase,
+	}
+
+	var buf bytes.Buffer
+
+	err := onlinePaymentService.ExportPayments(context.Background(), "MCH_123", &models.TransactionFilter{MerchantID: "MCH_OTHER"}, export.FormatNDJSON, &buf)
+	c.NoError(err)
+	c.Empty(buf.String())
+	mockDatabase.AssertExpectations(t)
+}
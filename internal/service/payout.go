@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// PayoutService interface to implement business logic to reconcile the payouts of the platform with its transactions
+type PayoutService interface {
+	ListPayouts(ctx context.Context, filter *models.PayoutFilter) ([]*models.Payout, error)
+	ListPayoutTransactions(ctx context.Context, payoutID string) ([]*models.Transaction, error)
+}
+
+type payoutService struct {
+	database database.PayoutStore
+}
+
+// NewPayoutService constructor for payout service
+func NewPayoutService(database database.PayoutStore) PayoutService {
+	return payoutService{
+		database: database,
+	}
+}
+
+// ListPayouts lists the payouts matching the filter, most recent first
+func (p payoutService) ListPayouts(ctx context.Context, filter *models.PayoutFilter) ([]*models.Payout, error) {
+	return p.database.ListPayouts(ctx, filter)
+}
+
+// ListPayoutTransactions lists the transactions settled by a payout, failing when the payout doesn't exist
+func (p payoutService) ListPayoutTransactions(ctx context.Context, payoutID string) ([]*models.Transaction, error) {
+	_, err := p.database.GetPayout(ctx, payoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.database.ListPayoutTransactions(ctx, payoutID)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPayoutTransactions(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	transactions := []*models.Transaction{{TransactionID: "TXN_123"}}
+
+	mockDatabase.On("GetPayout", mock.Anything, "PO_123").Return(&models.Payout{PayoutID: "PO_123"}, nil)
+	mockDatabase.On("ListPayoutTransactions", mock.Anything, "PO_123").Return(transactions, nil)
+
+	payoutService := NewPayoutService(&mockDatabase)
+
+	result, err := payoutService.ListPayoutTransactions(context.Background(), "PO_123")
+	c.NoError(err)
+	c.Equal(transactions, result)
+}
+
+func TestListPayoutTransactionsPayoutNotFound(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("GetPayout", mock.Anything, "PO_123").Return(nil, api.NewResourceNotFoundError(database.ErrPayoutNotFound, "payout"))
+
+	payoutService := NewPayoutService(&mockDatabase)
+
+	_, err := payoutService.ListPayoutTransactions(context.Background(), "PO_123")
+	c.ErrorIs(err, database.ErrPayoutNotFound)
+
+	mockDatabase.AssertNotCalled(t, "ListPayoutTransactions", mock.Anything, mock.Anything)
+}
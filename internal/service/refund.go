@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	// ErrRefundAlreadyRequested error when the refund of the transaction is already waiting for approval
+	ErrRefundAlreadyRequested = api.NewConflictError(errors.New("refund already requested"))
+	// ErrMissingRefundRequestID error when refund request ID is missing
+	ErrMissingRefundRequestID = api.NewInvalidRequestError(errors.New("missing refund request id"))
+	// ErrMissingApprover error when the identity of the operator deciding on a refund request is missing
+	ErrMissingApprover = api.NewInvalidRequestError(errors.New("missing approver"))
+	// ErrSelfApproval error when the requester of a refund tries to approve it
+	ErrSelfApproval = api.NewInvalidRequestError(errors.New("refund requester can't approve it"))
+)
+
+// refundRequestClaimTimeout how long an operator holds a refund request while the provider issues its refund
+const refundRequestClaimTimeout = 2 * time.Minute
+
+// RefundRequestService interface to implement the approval of refunds over the approval threshold of the merchant
+type RefundRequestService interface {
+	ListRefundRequests(ctx context.Context, filter *models.RefundRequestFilter) ([]*models.RefundRequest, error)
+	ApproveRefundRequest(ctx context.Context, approver, refundRequestID string) (*models.RefundRequest, error)
+	RejectRefundRequest(ctx context.Context, approver, refundRequestID, reason string) (*models.RefundRequest, error)
+}
+
+type refundRequestService struct {
+	database         database.Database
+	paymentProcessor paymentprocessor.PaymentProcessor
+}
+
+// NewRefundRequestService constructor for refund request service
+func NewRefundRequestService(database database.Database, paymentProcessor paymentprocessor.PaymentProcessor) RefundRequestService {
+	return refundRequestService{
+		database:         database,
+		paymentProcessor: paymentProcessor,
+	}
+}
+
+// ListRefundRequests lists the refund requests matching the filter, oldest first
+func (r refundRequestService) ListRefundRequests(ctx context.Context, filter *models.RefundRequestFilter) ([]*models.RefundRequest, error) {
+	return r.database.ListRefundRequests(ctx, filter)
+}
+
+// ApproveRefundRequest issues the held refund with the provider, as long as the approver isn't the operator who
+// requested it
+func (r refundRequestService) ApproveRefundRequest(ctx context.Context, approver, refundRequestID string) (*models.RefundRequest, error) {
+	ctx = audit.WithActor(ctx, models.AuditActorOperator, approver)
+
+	request, transaction, err := r.pendingRefundRequest(ctx, approver, refundRequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.RequesterType == models.AuditActorOperator && approver == request.RequestedBy {
+		return nil, ErrSelfApproval
+	}
+
+	ctx = logging.WithTransactionID(ctx, transaction.TransactionID)
+
+	err = r.claim(ctx, request, approver)
+	if err != nil {
+		return nil, err
+	}
+
+	// the amount was reserved against the limits of the merchant when the refund was requested, and stays reserved
+	// while the request is pending, even when the provider fails
+	refunded, err := r.paymentProcessor.RefundTransaction(ctx, transaction.AdditionalFields)
+	if err != nil {
+		r.release(ctx, request, approver)
+		return nil, err
+	}
+
+	refundedTransaction, err := recordRefund(ctx, r.database, transaction, refunded, request.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.decide(ctx, request, models.RefundRequestStatusApproved, approver, "", models.AuditActionApproveRefundRequest)
+	if err != nil {
+		// the provider already issued the refund, so a request left pending, still claimed, is closed by the webhook of
+		// the refund
+		slog.ErrorContext(ctx, "record refund request decision failed", slog.String("refund_request_id", request.RefundRequestID), slog.Any("error", err))
+
+		return nil, err
+	}
+
+	request.Transaction = refundedTransaction
+
+	return request, nil
+}
+
+// RejectRefundRequest drops the held refund without reaching the provider, restoring the status of its transaction
+func (r refundRequestService) RejectRefundRequest(ctx context.Context, approver, refundRequestID, reason string) (*models.RefundRequest, error) {
+	if reason == "" {
+		return nil, ErrMissingReason
+	}
+
+	ctx = audit.WithActor(ctx, models.AuditActorOperator, approver)
+
+	request, _, err := r.pendingRefundRequest(ctx, approver, refundRequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = logging.WithTransactionID(ctx, request.TransactionID)
+
+	// claimed like approvals, so a rejection can't restore the transaction while its refund is being issued
+	err = r.claim(ctx, request, approver)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.decide(ctx, request, models.RefundRequestStatusRejected, approver, reason, models.AuditActionRejectRefundRequest)
+	if err != nil {
+		r.release(ctx, request, approver)
+		return nil, err
+	}
+
+	releaseUsage(ctx, r.database, requestReservation(request))
+
+	request.Transaction, err = r.database.GetTransaction(ctx, request.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// pendingRefundRequest fetches a refund request still waiting for a decision along with its transaction
+func (r refundRequestService) pendingRefundRequest(ctx context.Context, approver, refundRequestID string) (*models.RefundRequest, *models.Transaction, error) {
+	if approver == "" {
+		return nil, nil, ErrMissingApprover
+	}
+
+	if refundRequestID == "" {
+		return nil, nil, ErrMissingRefundRequestID
+	}
+
+	request, err := r.database.GetRefundRequest(ctx, refundRequestID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if request.Status != models.RefundRequestStatusPending {
+		return nil, nil, api.NewConflictError(database.ErrRefundRequestAlreadyDecided)
+	}
+
+	transaction, err := r.database.GetTransaction(ctx, request.TransactionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return request, transaction, nil
+}
+
+// claim holds the refund request for the operator while the provider issues its refund, so a concurrent decision fails
+// before reaching the provider
+func (r refundRequestService) claim(ctx context.Context, request *models.RefundRequest, operator string) error {
+	return r.database.ClaimRefundRequest(ctx, request.RefundRequestID, operator, time.Now().Add(refundRequestClaimTimeout))
+}
+
+// release gives up the claim on a refund request left undecided, so it can be retried right away rather than once
+// the claim lapses
+func (r refundRequestService) release(ctx context.Context, request *models.RefundRequest, operator string) {
+	err := r.database.ReleaseRefundRequest(ctx, request.RefundRequestID, operator)
+	if err != nil {
+		slog.ErrorContext(ctx, "release refund request failed", slog.String("refund_request_id", request.RefundRequestID), slog.Any("error", err))
+	}
+}
+
+// decide records the decision on the refund request along with its audit entry
+func (r refundRequestService) decide(ctx context.Context, request *models.RefundRequest, status models.RefundRequestStatus, approver, reason string, action models.AuditAction) error {
+	decidedAt := time.Now().UTC()
+
+	request.Status = status
+	request.DecidedBy = approver
+	request.RejectionReason = reason
+	request.DecidedAt = &decidedAt
+
+	err := r.database.DecideRefundRequest(ctx, request, &models.AuditEntry{
+		Actor:        approver,
+		Action:       action,
+		ResourceType: models.AuditResourceRefundRequest,
+		ResourceID:   request.RefundRequestID,
+		Reason:       reason,
+		Details:      map[string]interface{}{"transaction_id": request.TransactionID, "requested_by": request.RequestedBy},
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "refund request decided",
+		slog.String("refund_request_id", request.RefundRequestID),
+		slog.String("status", string(status)),
+		slog.String("decided_by", approver),
+	)
+
+	return nil
+}
+
+// requiresApproval reports whether the refund of the transaction is over the approval threshold of the merchant
+func requiresApproval(transaction *models.Transaction, merchantLimits *models.MerchantLimits) bool {
+	return merchantLimits.RefundApprovalThreshold > 0 && transaction.Amount > merchantLimits.RefundApprovalThreshold
+}
+
+// requestRefund holds the refund of the transaction until an operator other than the requester approves it. The
+// requester is the actor of the context, which is the API key for refunds requested through the API. The amount is
+// reserved against the limits of the merchant right away, enforced unless merchantLimits is nil
+func requestRefund(ctx context.Context, db database.Database, transaction *models.Transaction, reason string, merchantLimits *models.MerchantLimits) (*models.Transaction, error) {
+	ctx = logging.WithTransactionID(ctx, transaction.TransactionID)
+
+	err := checkRefundable(transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	requesterType, requestedBy := audit.ActorFromContext(ctx)
+
+	request := &models.RefundRequest{
+		RefundRequestID: fmt.Sprintf("RFR_%s", ulid.Make().String()),
+		TransactionID:   transaction.TransactionID,
+		MerchantID:      transaction.MerchantID,
+		Status:          models.RefundRequestStatusPending,
+		Amount:          transaction.Amount,
+		Currency:        transaction.Currency,
+		PreviousStatus:  transaction.Status,
+		RequestedBy:     requestedBy,
+		RequesterType:   requesterType,
+		Reason:          reason,
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	reservation := requestReservation(request)
+
+	err = reserveUsage(ctx, db, reservation, merchantLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	requestedTransaction, err := db.InsertRefundRequest(ctx, request, transaction.Version, &models.AuditEntry{
+		Action:       models.AuditActionRequestRefund,
+		ResourceType: models.AuditResourceRefundRequest,
+		ResourceID:   request.RefundRequestID,
+		Reason:       reason,
+		Details:      map[string]interface{}{"transaction_id": transaction.TransactionID, "amount": transaction.Amount},
+		Before:       models.AuditSnapshot(transaction),
+	})
+	if err != nil {
+		releaseUsage(ctx, db, reservation)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "refund requested", slog.String("refund_request_id", request.RefundRequestID), slog.String("requested_by", requestedBy), slog.String("requester_type", string(requesterType)))
+
+	return requestedTransaction, nil
+}
+
+// requestReservation amount of the refund request counted towards the usage of the merchant on the day it was requested
+func requestReservation(request *models.RefundRequest) *models.UsageReservation {
+	return &models.UsageReservation{
+		MerchantID: request.MerchantID,
+		Currency:   request.Currency,
+		Day:        request.CreatedAt,
+		Refunded:   request.Amount,
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/limits"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func pendingTestRefundRequest() *models.RefundRequest {
+	return &models.RefundRequest{
+		RefundRequestID: "RFR_123",
+		TransactionID:   "TXN_123",
+		MerchantID:      "MCH_123",
+		Status:          models.RefundRequestStatusPending,
+		Amount:          150000,
+		Currency:        "usd",
+		PreviousStatus:  models.TransactionStatusSucceeded,
+		RequestedBy:     "KEY_123",
+		RequesterType:   models.AuditActorAPIKey,
+		CreatedAt:       time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func refundRequestedTestTransaction() *models.Transaction {
+	return &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusRefundRequested,
+		Amount:        150000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+		Version:       2,
+		AdditionalFields: map[string]interface{}{
+			"charge_id": "ch_123",
+		},
+	}
+}
+
+func TestRefundPaymentOverApprovalThreshold(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusSucceeded,
+		Amount:        150000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+		Version:       1,
+	}
+
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(transaction, nil)
+	mockDatabase.On("ReserveUsage", mock.Anything, mock.MatchedBy(func(reservation *models.UsageReservation) bool {
+		return reservation.Refunded == 150000 && reservation.Charged == 0
+	}), mock.Anything).Return(&models.Usage{}, nil)
+	mockDatabase.On("InsertRefundRequest", mock.Anything, mock.MatchedBy(func(request *models.RefundRequest) bool {
+		return request.Status == models.RefundRequestStatusPending && request.RequestedBy == "KEY_123" && request.RequesterType == models.AuditActorAPIKey && request.PreviousStatus == models.TransactionStatusSucceeded
+	}), 1, mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionRequestRefund && entry.ResourceType == models.AuditResourceRefundRequest
+	})).Return(refundRequestedTestTransaction(), nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+		limits: limits.New(config.Limits{
+			LimitPlan: config.LimitPlan{Default: config.Limit{RefundApprovalThreshold: 100000}},
+		}),
+	}
+
+	ctx := audit.WithActor(context.Background(), models.AuditActorAPIKey, "KEY_123")
+
+	refundedTransaction, err := onlinePaymentService.RefundPayment(ctx, "MCH_123", "TXN_123", 0)
+	c.NoError(err)
+	c.Equal(models.TransactionStatusRefundRequested, refundedTransaction.Status)
+
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
+}
+
+func TestRefundPaymentAlreadyRequested(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(refundRequestedTestTransaction(), nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &stripe.MockStripe{},
+	}
+
+	_, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "TXN_123", 0)
+	c.ErrorIs(err, ErrRefundAlreadyRequested)
+}
+
+func TestApproveRefundRequest(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := refundRequestedTestTransaction()
+
+	refundedTransaction := &models.Transaction{
+		Status: models.TransactionStatusSucceeded,
+		Type:   models.TransactionTypeRefund,
+		AdditionalFields: map[string]interface{}{
+			"charge_id": "ch_123",
+			"refund_id": "re_123",
+		},
+	}
+
+	mockDatabase.On("GetRefundRequest", mock.Anything, "RFR_123").Return(pendingTestRefundRequest(), nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(transaction, nil)
+	mockDatabase.On("ClaimRefundRequest", mock.Anything, "RFR_123", "alice", mock.AnythingOfType("time.Time")).Return(nil)
+	mockPaymentProcessor.On("RefundTransaction", mock.Anything, transaction.AdditionalFields).Return(refundedTransaction, nil)
+	mockDatabase.On("UpdateTransaction", mock.Anything, "TXN_123", refundedTransaction, mock.Anything).Return(&models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusSucceeded, Type: models.TransactionTypeRefund}, nil)
+	mockDatabase.On("DecideRefundRequest", mock.Anything, mock.MatchedBy(func(request *models.RefundRequest) bool {
+		return request.Status == models.RefundRequestStatusApproved && request.DecidedBy == "alice" && request.DecidedAt != nil
+	}), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Actor == "alice" && entry.Action == models.AuditActionApproveRefundRequest && entry.ResourceID == "RFR_123"
+	})).Return(nil)
+
+	refundRequestService := NewRefundRequestService(&mockDatabase, &mockPaymentProcessor)
+
+	request, err := refundRequestService.ApproveRefundRequest(context.Background(), "alice", "RFR_123")
+	c.NoError(err)
+	c.Equal(models.RefundRequestStatusApproved, request.Status)
+	c.Equal(models.TransactionTypeRefund, request.Transaction.Type)
+
+	mockDatabase.AssertNotCalled(t, "ReleaseUsage", mock.Anything, mock.Anything)
+	mockDatabase.AssertNotCalled(t, "ReleaseRefundRequest", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApproveRefundRequestClaimed(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("GetRefundRequest", mock.Anything, "RFR_123").Return(pendingTestRefundRequest(), nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(refundRequestedTestTransaction(), nil)
+	mockDatabase.On("ClaimRefundRequest", mock.Anything, "RFR_123", "alice", mock.AnythingOfType("time.Time")).Return(api.NewConflictError(database.ErrRefundRequestClaimed))
+
+	refundRequestService := NewRefundRequestService(&mockDatabase, &mockPaymentProcessor)
+
+	_, err := refundRequestService.ApproveRefundRequest(context.Background(), "alice", "RFR_123")
+	c.ErrorIs(err, database.ErrRefundRequestClaimed)
+
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
+}
+
+func TestApproveRefundRequestProviderFailure(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := refundRequestedTestTransaction()
+	providerErr := api.NewInternalServerError(errors.New("charge already refunded"))
+
+	mockDatabase.On("GetRefundRequest", mock.Anything, "RFR_123").Return(pendingTestRefundRequest(), nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(transaction, nil)
+	mockDatabase.On("ClaimRefundRequest", mock.Anything, "RFR_123", "alice", mock.AnythingOfType("time.Time")).Return(nil)
+	mockPaymentProcessor.On("RefundTransaction", mock.Anything, transaction.AdditionalFields).Return(nil, providerErr)
+	mockDatabase.On("ReleaseRefundRequest", mock.Anything, "RFR_123", "alice").Return(nil)
+
+	refundRequestService := NewRefundRequestService(&mockDatabase, &mockPaymentProcessor)
+
+	_, err := refundRequestService.ApproveRefundRequest(context.Background(), "alice", "RFR_123")
+	c.ErrorIs(err, providerErr)
+
+	mockDatabase.AssertExpectations(t)
+	mockDatabase.AssertNotCalled(t, "ReleaseUsage", mock.Anything, mock.Anything)
+	mockDatabase.AssertNotCalled(t, "DecideRefundRequest", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApproveRefundRequestSelfApproval(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	request := pendingTestRefundRequest()
+	request.RequestedBy = "alice"
+	request.RequesterType = models.AuditActorOperator
+
+	mockDatabase.On("GetRefundRequest", mock.Anything, "RFR_123").Return(request, nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(refundRequestedTestTransaction(), nil)
+
+	refundRequestService := NewRefundRequestService(&mockDatabase, &mockPaymentProcessor)
+
+	_, err := refundRequestService.ApproveRefundRequest(context.Background(), "alice", "RFR_123")
+	c.ErrorIs(err, ErrSelfApproval)
+
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
+}
+
+func TestApproveRefundRequestAlreadyDecided(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	request := pendingTestRefundRequest()
+	request.Status = models.RefundRequestStatusRejected
+
+	mockDatabase.On("GetRefundRequest", mock.Anything, "RFR_123").Return(request, nil)
+
+	refundRequestService := NewRefundRequestService(&mockDatabase, &mockPaymentProcessor)
+
+	_, err := refundRequestService.ApproveRefundRequest(context.Background(), "alice", "RFR_123")
+	c.ErrorIs(err, database.ErrRefundRequestAlreadyDecided)
+
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
+}
+
+func TestRejectRefundRequest(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("GetRefundRequest", mock.Anything, "RFR_123").Return(pendingTestRefundRequest(), nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(refundRequestedTestTransaction(), nil).Once()
+	mockDatabase.On("ClaimRefundRequest", mock.Anything, "RFR_123", "alice", mock.AnythingOfType("time.Time")).Return(nil)
+	mockDatabase.On("DecideRefundRequest", mock.Anything, mock.MatchedBy(func(request *models.RefundRequest) bool {
+		return request.Status == models.RefundRequestStatusRejected && request.RejectionReason == "goods shipped"
+	}), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionRejectRefundRequest && entry.Reason == "goods shipped"
+	})).Return(nil)
+	mockDatabase.On("ReleaseUsage", mock.Anything, mock.MatchedBy(func(reservation *models.UsageReservation) bool {
+		return reservation.Refunded == 150000 && reservation.MerchantID == "MCH_123"
+	})).Return(nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(&models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusSucceeded}, nil).Once()
+
+	refundRequestService := NewRefundRequestService(&mockDatabase, &mockPaymentProcessor)
+
+	request, err := refundRequestService.RejectRefundRequest(context.Background(), "alice", "RFR_123", "goods shipped")
+	c.NoError(err)
+	c.Equal(models.RefundRequestStatusRejected, request.Status)
+	c.Equal(models.TransactionStatusSucceeded, request.Transaction.Status)
+
+	mockDatabase.AssertExpectations(t)
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
+}
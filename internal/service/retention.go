@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/archive"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+)
+
+// RetentionService interface to apply the retention policies of transactions and webhook events
+type RetentionService interface {
+	ApplyRetention(ctx context.Context) (*models.RetentionRun, error)
+}
+
+// archiveBatchSize transactions archived in each database transaction, which bounds how long their rows stay locked
+const archiveBatchSize = 500
+
+type retentionService struct {
+	database  database.RetentionStore
+	retention config.Retention
+	files     *archive.Files
+}
+
+// NewRetentionService constructor for the service applying the retention policies, where files are the archive files
+// transactions are exported to in the ndjson archive mode
+func NewRetentionService(database database.RetentionStore, retention config.Retention, files *archive.Files) RetentionService {
+	return retentionService{
+		database:  database,
+		retention: retention,
+		files:     files,
+	}
+}
+
+// ApplyRetention archives the settled transactions older than the transaction retention and drops the payloads of the
+// processed webhook events older than the webhook payload retention, in batches. A retention of zero keeps everything
+func (r retentionService) ApplyRetention(ctx context.Context) (*models.RetentionRun, error) {
+	err := auth.Authorize(ctx, models.PermissionApplyRetention)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	run := &models.RetentionRun{
+		ArchiveMode: r.retention.ArchiveMode,
+	}
+
+	if r.retention.TransactionMonths > 0 {
+		before := now.AddDate(0, -r.retention.TransactionMonths, 0)
+		run.ArchivedBefore = &before
+
+		// succeeded charges are archived once they can no longer be refunded, as archived transactions can't be updated
+		refundableSince := now.Add(-r.retention.RefundWindow)
+
+		if r.retention.ArchiveMode == models.ArchiveModeNDJSON {
+			err = r.exportTransactions(ctx, run, refundableSince)
+		} else {
+			err = r.archiveTransactions(ctx, run, refundableSince)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.retention.WebhookPayloads > 0 {
+		before := now.Add(-r.retention.WebhookPayloads)
+		run.PrunedBefore = &before
+
+		run.PrunedWebhookPayloads, err = r.database.PruneWebhookEventPayloads(ctx, before)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slog.InfoContext(ctx, "retention applied",
+		slog.String("archive_mode", string(run.ArchiveMode)),
+		slog.Int("archived_transactions", run.ArchivedTransactions),
+		slog.Int("archive_files", len(run.ArchiveFiles)),
+		slog.Int("pruned_webhook_payloads", run.PrunedWebhookPayloads),
+	)
+
+	return run, nil
+}
+
+// archiveTransactions moves the transactions to the archive tables, keeping the charges still refundable
+func (r retentionService) archiveTransactions(ctx context.Context, run *models.RetentionRun, refundableSince time.Time) error {
+	for {
+		archived, err := r.database.ArchiveTransactions(ctx, *run.ArchivedBefore, refundableSince, archiveBatchSize)
+		run.ArchivedTransactions += archived
+
+		if err != nil {
+			return err
+		}
+
+		if archived < archiveBatchSize {
+			return nil
+		}
+	}
+}
+
+// exportTransactions writes the transactions to the archive file of the month they were created in before removing
+// them from the database, so an interrupted run leaves them in both places rather than in neither. Each file is
+// written holding its lock, so an erasure never rewrites it in between. Charges still refundable are kept
+func (r retentionService) exportTransactions(ctx context.Context, run *models.RetentionRun, refundableSince time.Time) error {
+	files := map[string]bool{}
+
+	for {
+		transactions, err := r.database.ListArchivableTransactions(ctx, *run.ArchivedBefore, refundableSince, archiveBatchSize)
+		if err != nil {
+			return err
+		}
+
+		removed := 0
+
+		for _, batch := range groupByMonth(transactions) {
+			file := archive.Name(batch[0].CreatedAt)
+
+			var ids []string
+
+			err = r.database.LockArchiveFile(ctx, file, func() error {
+				ids, err = r.exportBatch(ctx, file, batch)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			if !files[file] {
+				files[file] = true
+				run.ArchiveFiles = append(run.ArchiveFiles, file)
+			}
+
+			removed += len(ids)
+		}
+
+		run.ArchivedTransactions += removed
+
+		// transactions updated while they were exported are left for the next run rather than listed again
+		if len(transactions) < archiveBatchSize || removed == 0 {
+			return nil
+		}
+	}
+}
+
+// exportBatch appends the transactions to their archive file and removes them from the database. The copies of the
+// transactions updated since they were listed are discarded from the file, as the update may be the erasure of their
+// customer, which already rewrote the file
+func (r retentionService) exportBatch(ctx context.Context, file string, batch []*models.Transaction) ([]string, error) {
+	_, err := r.files.Append(batch[0].CreatedAt, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := r.database.RemoveArchivedTransactions(ctx, file, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == len(batch) {
+		return ids, nil
+	}
+
+	removed := map[string]bool{}
+
+	for _, id := range ids {
+		removed[id] = true
+	}
+
+	var stale []*models.Transaction
+
+	for _, transaction := range batch {
+		if !removed[transaction.TransactionID] {
+			stale = append(stale, transaction)
+		}
+	}
+
+	return ids, r.files.Discard(file, stale)
+}
+
+// groupByMonth splits the transactions, sorted by creation, by the month they were created in
+func groupByMonth(transactions []*models.Transaction) [][]*models.Transaction {
+	var groups [][]*models.Transaction
+
+	for _, transaction := range transactions {
+		last := len(groups) - 1
+
+		if last >= 0 && archive.Name(groups[last][0].CreatedAt) == archive.Name(transaction.CreatedAt) {
+			groups[last] = append(groups[last], transaction)
+			continue
+		}
+
+		groups = append(groups, []*models.Transaction{transaction})
+	}
+
+	return groups
+}
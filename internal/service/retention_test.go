@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/archive"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/auth"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRetentionTable(t *testing.T) {
+	c := require.New(t)
+
+	// succeeded charges are kept for as long as they can be refunded
+	refundableSince := mock.MatchedBy(func(refundableSince time.Time) bool {
+		return time.Since(refundableSince).Round(time.Hour) == 180*24*time.Hour
+	})
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("ArchiveTransactions", mock.Anything, mock.AnythingOfType("time.Time"), refundableSince, archiveBatchSize).Return(archiveBatchSize, nil).Once()
+	mockDatabase.On("ArchiveTransactions", mock.Anything, mock.AnythingOfType("time.Time"), refundableSince, archiveBatchSize).Return(20, nil).Once()
+	mockDatabase.On("PruneWebhookEventPayloads", mock.Anything, mock.AnythingOfType("time.Time")).Return(7, nil)
+
+	retentionService := NewRetentionService(&mockDatabase, config.Retention{
+		TransactionMonths: 13,
+		ArchiveMode:       models.ArchiveModeTable,
+		WebhookPayloads:   30 * 24 * time.Hour,
+		RefundWindow:      180 * 24 * time.Hour,
+	}, nil)
+
+	run, err := retentionService.ApplyRetention(operatorContext(models.OperatorRoleAdmin))
+	c.NoError(err)
+	c.Equal(archiveBatchSize+20, run.ArchivedTransactions)
+	c.Equal(7, run.PrunedWebhookPayloads)
+	c.WithinDuration(time.Now().AddDate(0, -13, 0), *run.ArchivedBefore, time.Minute)
+	c.WithinDuration(time.Now().AddDate(0, 0, -30), *run.PrunedBefore, time.Minute)
+
+	_, err = retentionService.ApplyRetention(operatorContext(models.OperatorRoleFinance))
+	c.ErrorIs(err, auth.ErrPermissionDenied)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestApplyRetentionNDJSON(t *testing.T) {
+	c := require.New(t)
+
+	march := &models.Transaction{TransactionID: "TXN_1", Version: 1, CreatedAt: time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)}
+	april := &models.Transaction{TransactionID: "TXN_2", Version: 3, CreatedAt: time.Date(2024, 4, 1, 1, 0, 0, 0, time.UTC)}
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("ListArchivableTransactions", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), archiveBatchSize).Return([]*models.Transaction{march, april}, nil)
+	mockDatabase.On("LockArchiveFile", mock.Anything, "transactions-2024-03.ndjson.gz").Return(nil)
+	mockDatabase.On("LockArchiveFile", mock.Anything, "transactions-2024-04.ndjson.gz").Return(nil)
+	mockDatabase.On("RemoveArchivedTransactions", mock.Anything, "transactions-2024-03.ndjson.gz", []*models.Transaction{march}).Return([]string{"TXN_1"}, nil)
+	mockDatabase.On("RemoveArchivedTransactions", mock.Anything, "transactions-2024-04.ndjson.gz", []*models.Transaction{april}).Return([]string{}, nil)
+
+	files := archive.NewFiles(t.TempDir(), nil)
+
+	retentionService := NewRetentionService(&mockDatabase, config.Retention{
+		TransactionMonths: 6,
+		ArchiveMode:       models.ArchiveModeNDJSON,
+	}, files)
+
+	run, err := retentionService.ApplyRetention(operatorContext(models.OperatorRoleAdmin))
+	c.NoError(err)
+	c.Equal(1, run.ArchivedTransactions, "transactions updated while exported are kept")
+	c.Equal([]string{"transactions-2024-03.ndjson.gz", "transactions-2024-04.ndjson.gz"}, run.ArchiveFiles)
+	c.Nil(run.PrunedBefore, "webhook payloads are kept forever by default")
+
+	transaction, err := files.Find(context.Background(), "transactions-2024-03.ndjson.gz", "TXN_1")
+	c.NoError(err)
+	c.Equal(1, transaction.Version)
+
+	_, err = files.Find(context.Background(), "transactions-2024-04.ndjson.gz", "TXN_2")
+	c.ErrorIs(err, database.ErrTransactionNotFound, "stale copies are discarded")
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestApplyRetentionDisabled(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	run, err := NewRetentionService(&mockDatabase, config.Default().Retention, nil).ApplyRetention(operatorContext(models.OperatorRoleAdmin))
+	c.NoError(err)
+	c.Nil(run.ArchivedBefore)
+	c.Zero(run.ArchivedTransactions)
+
+	mockDatabase.AssertExpectations(t)
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
+)
+
+var (
+	// ErrMissingReviewer error when the identity of the reviewer deciding on a review is missing
+	ErrMissingReviewer = api.NewInvalidRequestError(errors.New("missing reviewer"))
+	// ErrMissingReviewID error when review ID is missing
+	ErrMissingReviewID = api.NewInvalidRequestError(errors.New("missing review id"))
+	// ErrReviewExpired error when approving a review past its expiration, which is left to be rejected
+	ErrReviewExpired = api.NewConflictError(errors.New("review expired"))
+)
+
+const (
+	// reviewExpiredReason reason recorded on the reviews rejected once they expired
+	reviewExpiredReason = "review window expired"
+	// reviewClaimTimeout how long a reviewer holds a review while the provider captures or cancels its payment
+	reviewClaimTimeout = 2 * time.Minute
+)
+
+// ReviewService interface to implement the manual review of payments flagged by the risk rules
+type ReviewService interface {
+	ListReviews(ctx context.Context, filter *models.ReviewFilter) ([]*models.Review, error)
+	ApproveReview(ctx context.Context, reviewer, reviewID string) (*models.Review, error)
+	RejectReview(ctx context.Context, reviewer, reviewID, reason string) (*models.Review, error)
+	// ExpireReviews rejects the pending reviews past their expiration, returning how many were rejected
+	ExpireReviews(ctx context.Context) (int, error)
+}
+
+type reviewService struct {
+	database         database.Database
+	paymentProcessor paymentprocessor.PaymentProcessor
+}
+
+// NewReviewService constructor for review service
+func NewReviewService(database database.Database, paymentProcessor paymentprocessor.PaymentProcessor) ReviewService {
+	return reviewService{
+		database:         database,
+		paymentProcessor: paymentProcessor,
+	}
+}
+
+// ListReviews lists the reviews matching the filter, oldest first
+func (r reviewService) ListReviews(ctx context.Context, filter *models.ReviewFilter) ([]*models.Review, error) {
+	return r.database.ListReviews(ctx, filter)
+}
+
+// ApproveReview captures the payment under review, recording who approved it
+func (r reviewService) ApproveReview(ctx context.Context, reviewer, reviewID string) (*models.Review, error) {
+	ctx = audit.WithActor(ctx, models.AuditActorOperator, reviewer)
+
+	review, transaction, err := r.pendingReview(ctx, reviewer, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(review.ExpiresAt) {
+		return nil, ErrReviewExpired
+	}
+
+	ctx = logging.WithTransactionID(ctx, transaction.TransactionID)
+
+	err = r.claim(ctx, review, reviewer)
+	if err != nil {
+		return nil, err
+	}
+
+	captured, err := r.paymentProcessor.CapturePayment(ctx, transaction.AdditionalFields)
+	if err != nil {
+		r.release(ctx, review, reviewer)
+		return nil, err
+	}
+
+	decided := &models.Transaction{
+		Status:           captured.Status,
+		FailureReason:    captured.FailureReason,
+		AdditionalFields: captured.AdditionalFields,
+		ProviderFee:      captured.ProviderFee,
+		FeeCurrency:      captured.FeeCurrency,
+		PlatformFee:      transaction.PlatformFee,
+		NetAmount:        transaction.NetAmount,
+	}
+
+	// the funds of a destination charge only reach the connected account once they are captured
+	if review.Transfer != nil && captured.Status == models.TransactionStatusSucceeded {
+		transfer := *review.Transfer
+		transfer.ProviderTransferID, _ = captured.AdditionalFields["transfer_id"].(string)
+		transfer.CreatedAt = time.Now().UTC()
+
+		decided.Transfers = []*models.Transfer{&transfer}
+	}
+
+	return r.decide(ctx, review, models.ReviewStatusApproved, reviewer, "", decided, models.AuditActionApproveReview)
+}
+
+// RejectReview cancels the authorization of the payment under review, recording who rejected it and why
+func (r reviewService) RejectReview(ctx context.Context, reviewer, reviewID, reason string) (*models.Review, error) {
+	if reason == "" {
+		return nil, ErrMissingReason
+	}
+
+	ctx = audit.WithActor(ctx, models.AuditActorOperator, reviewer)
+
+	review, transaction, err := r.pendingReview(ctx, reviewer, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.reject(ctx, review, transaction, reviewer, reason, models.FailureReasonReviewRejected)
+}
+
+// ExpireReviews rejects every pending review past its expiration. A review that fails to be rejected is logged and
+// left for the next run
+func (r reviewService) ExpireReviews(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+
+	reviews, err := r.database.ListReviews(ctx, &models.ReviewFilter{
+		Status:        models.ReviewStatusPending,
+		ExpiredBefore: &now,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+
+	for _, review := range reviews {
+		reviewCtx := audit.WithActor(logging.WithTransactionID(ctx, review.TransactionID), models.AuditActorSystem, models.ReviewerTimeout)
+
+		transaction, err := r.database.GetTransaction(reviewCtx, review.TransactionID)
+		if err == nil {
+			_, err = r.reject(reviewCtx, review, transaction, models.ReviewerTimeout, reviewExpiredReason, models.FailureReasonReviewExpired)
+		}
+
+		if err != nil {
+			slog.ErrorContext(reviewCtx, "expire review failed", slog.String("review_id", review.ReviewID), slog.Any("error", err))
+			continue
+		}
+
+		expired++
+	}
+
+	return expired, nil
+}
+
+// SweepExpiredReviews rejects the expired reviews every interval until the context is canceled
+func SweepExpiredReviews(ctx context.Context, reviews ReviewService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := reviews.ExpireReviews(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "expire reviews failed", slog.Any("error", err))
+				continue
+			}
+
+			if expired > 0 {
+				slog.InfoContext(ctx, "expired reviews rejected", slog.Int("reviews", expired))
+			}
+		}
+	}
+}
+
+// pendingReview fetches a review still waiting for a decision along with its transaction
+func (r reviewService) pendingReview(ctx context.Context, reviewer, reviewID string) (*models.Review, *models.Transaction, error) {
+	if reviewer == "" {
+		return nil, nil, ErrMissingReviewer
+	}
+
+	if reviewID == "" {
+		return nil, nil, ErrMissingReviewID
+	}
+
+	review, err := r.database.GetReview(ctx, reviewID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if review.Status != models.ReviewStatusPending {
+		return nil, nil, api.NewConflictError(database.ErrReviewAlreadyDecided)
+	}
+
+	transaction, err := r.database.GetTransaction(ctx, review.TransactionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return review, transaction, nil
+}
+
+// reject cancels the authorization of the payment under review. Failed charges don't move any funds, so the platform
+// fee is dropped, and the transfer of a destination charge, never recorded while uncaptured, has nothing to reverse
+func (r reviewService) reject(ctx context.Context, review *models.Review, transaction *models.Transaction, reviewer, reason, failureReason string) (*models.Review, error) {
+	err := r.claim(ctx, review, reviewer)
+	if err != nil {
+		return nil, err
+	}
+
+	canceled, err := r.paymentProcessor.CancelPayment(ctx, transaction.AdditionalFields)
+	if err != nil {
+		r.release(ctx, review, reviewer)
+		return nil, err
+	}
+
+	decided := &models.Transaction{
+		Status:           models.TransactionStatusFailure,
+		FailureReason:    failureReason,
+		AdditionalFields: canceled.AdditionalFields,
+	}
+
+	decidedReview, err := r.decide(ctx, review, models.ReviewStatusRejected, reviewer, reason, decided, models.AuditActionRejectReview)
+	if err != nil {
+		return nil, err
+	}
+
+	// the canceled authorization no longer counts towards the volume of the merchant on the day it was charged
+	releaseUsage(ctx, r.database, &models.UsageReservation{
+		MerchantID: transaction.MerchantID,
+		Currency:   transaction.Currency,
+		Day:        transaction.CreatedAt,
+		Charged:    transaction.Amount,
+	})
+
+	return decidedReview, nil
+}
+
+// claim holds the review for the reviewer while the provider captures or cancels its payment, so a concurrent decision
+// fails before reaching the provider
+func (r reviewService) claim(ctx context.Context, review *models.Review, reviewer string) error {
+	return r.database.ClaimReview(ctx, review.ReviewID, reviewer, time.Now().Add(reviewClaimTimeout))
+}
+
+// release gives up the claim on a review the provider failed to decide on, so it can be retried right away rather
+// than once the claim lapses
+func (r reviewService) release(ctx context.Context, review *models.Review, reviewer string) {
+	err := r.database.ReleaseReview(ctx, review.ReviewID, reviewer)
+	if err != nil {
+		slog.ErrorContext(ctx, "release review failed", slog.String("review_id", review.ReviewID), slog.Any("error", err))
+	}
+}
+
+// decide records the decision on the review, once the provider captured or canceled the payment, along with its audit entry
+func (r reviewService) decide(ctx context.Context, review *models.Review, status models.ReviewStatus, reviewer, reason string, decided *models.Transaction, action models.AuditAction) (*models.Review, error) {
+	decidedAt := time.Now().UTC()
+
+	review.Status = status
+	review.Reviewer = reviewer
+	review.Reason = reason
+	review.DecidedAt = &decidedAt
+
+	transaction, err := r.database.DecideReview(ctx, review, decided, &models.AuditEntry{
+		Actor:        reviewer,
+		Action:       action,
+		ResourceType: models.AuditResourceReview,
+		ResourceID:   review.ReviewID,
+		Reason:       reason,
+		Details:      map[string]interface{}{"transaction_id": review.TransactionID},
+	})
+	if err != nil {
+		// the provider already captured or canceled the payment, which reconciliation reports until the review is decided
+		slog.ErrorContext(ctx, "record review decision failed", slog.String("review_id", review.ReviewID), slog.String("status", string(status)), slog.Any("error", err))
+
+		return nil, err
+	}
+
+	review.Transaction = transaction
+
+	slog.InfoContext(ctx, "review decided",
+		slog.String("review_id", review.ReviewID),
+		slog.String("status", string(status)),
+		slog.String("reviewer", reviewer),
+		slog.String("transaction_status", string(transaction.Status)),
+	)
+
+	return review, nil
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func pendingTestReview(expiresAt time.Time) *models.Review {
+	return &models.Review{
+		ReviewID:      "REV_123",
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.ReviewStatusPending,
+		Amount:        150000,
+		Currency:      "usd",
+		Rules:         []string{"large_charge"},
+		ExpiresAt:     expiresAt,
+	}
+}
+
+func reviewedTestTransaction() *models.Transaction {
+	return &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusReview,
+		Amount:        150000,
+		Currency:      "usd",
+		PlatformFee:   4380,
+		NetAmount:     145620,
+		AdditionalFields: map[string]interface{}{
+			"payment_intent_id": "pi_123",
+		},
+	}
+}
+
+func TestApproveReview(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := reviewedTestTransaction()
+
+	pending := pendingTestReview(time.Now().Add(time.Hour))
+	pending.Transfer = &models.Transfer{TransferID: "TRF_123", TransactionID: "TXN_123", AccountID: "ACC_123", Type: models.TransferTypeTransfer, Amount: 135000, Currency: "usd"}
+
+	mockDatabase.On("GetReview", mock.Anything, "REV_123").Return(pending, nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(transaction, nil)
+	mockDatabase.On("ClaimReview", mock.Anything, "REV_123", "alice", mock.AnythingOfType("time.Time")).Return(nil)
+	mockPaymentProcessor.On("CapturePayment", mock.Anything, transaction.AdditionalFields).Return(&models.Transaction{
+		Status:           models.TransactionStatusSucceeded,
+		AdditionalFields: map[string]interface{}{"payment_intent_id": "pi_123", "charge_id": "ch_123", "transfer_id": "tr_123"},
+		ProviderFee:      4380,
+		FeeCurrency:      "usd",
+	}, nil)
+	mockDatabase.On("DecideReview", mock.Anything, mock.MatchedBy(func(review *models.Review) bool {
+		return review.Status == models.ReviewStatusApproved && review.Reviewer == "alice" && review.DecidedAt != nil
+	}), mock.MatchedBy(func(decided *models.Transaction) bool {
+		return decided.Status == models.TransactionStatusSucceeded && decided.ProviderFee == 4380 && decided.PlatformFee == 4380 && decided.NetAmount == 145620 &&
+			len(decided.Transfers) == 1 && decided.Transfers[0].TransferID == "TRF_123" && decided.Transfers[0].ProviderTransferID == "tr_123"
+	}), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Actor == "alice" && entry.Action == models.AuditActionApproveReview && entry.ResourceID == "REV_123"
+	})).Return(&models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusSucceeded}, nil)
+
+	reviewService := NewReviewService(&mockDatabase, &mockPaymentProcessor)
+
+	review, err := reviewService.ApproveReview(context.Background(), "alice", "REV_123")
+	c.NoError(err)
+	c.Equal(models.ReviewStatusApproved, review.Status)
+	c.Equal(models.TransactionStatusSucceeded, review.Transaction.Status)
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestApproveReviewClaimed(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("GetReview", mock.Anything, "REV_123").Return(pendingTestReview(time.Now().Add(time.Hour)), nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(reviewedTestTransaction(), nil)
+	mockDatabase.On("ClaimReview", mock.Anything, "REV_123", "alice", mock.AnythingOfType("time.Time")).Return(api.NewConflictError(database.ErrReviewClaimed))
+
+	reviewService := NewReviewService(&mockDatabase, &mockPaymentProcessor)
+
+	_, err := reviewService.ApproveReview(context.Background(), "alice", "REV_123")
+	c.ErrorIs(err, database.ErrReviewClaimed)
+
+	mockPaymentProcessor.AssertNotCalled(t, "CapturePayment", mock.Anything, mock.Anything)
+}
+
+func TestApproveReviewExpired(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("GetReview", mock.Anything, "REV_123").Return(pendingTestReview(time.Now().Add(-time.Minute)), nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(reviewedTestTransaction(), nil)
+
+	reviewService := NewReviewService(&mockDatabase, &mockPaymentProcessor)
+
+	_, err := reviewService.ApproveReview(context.Background(), "alice", "REV_123")
+	c.ErrorIs(err, ErrReviewExpired)
+
+	mockPaymentProcessor.AssertNotCalled(t, "CapturePayment", mock.Anything, mock.Anything)
+}
+
+func TestApproveReviewAlreadyDecided(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	review := pendingTestReview(time.Now().Add(time.Hour))
+	review.Status = models.ReviewStatusRejected
+
+	mockDatabase.On("GetReview", mock.Anything, "REV_123").Return(review, nil)
+
+	reviewService := NewReviewService(&mockDatabase, &mockPaymentProcessor)
+
+	_, err := reviewService.ApproveReview(context.Background(), "alice", "REV_123")
+	c.ErrorIs(err, database.ErrReviewAlreadyDecided)
+
+	mockPaymentProcessor.AssertNotCalled(t, "CapturePayment", mock.Anything, mock.Anything)
+}
+
+func TestApproveReviewMissingReviewer(t *testing.T) {
+	c := require.New(t)
+
+	reviewService := NewReviewService(&postgres.MockPostgres{}, &stripe.MockStripe{})
+
+	_, err := reviewService.ApproveReview(context.Background(), "", "REV_123")
+	c.ErrorIs(err, ErrMissingReviewer)
+}
+
+func TestRejectReview(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := reviewedTestTransaction()
+
+	mockDatabase.On("GetReview", mock.Anything, "REV_123").Return(pendingTestReview(time.Now().Add(time.Hour)), nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(transaction, nil)
+	mockDatabase.On("ClaimReview", mock.Anything, "REV_123", "alice", mock.AnythingOfType("time.Time")).Return(nil)
+	mockPaymentProcessor.On("CancelPayment", mock.Anything, transaction.AdditionalFields).Return(&models.Transaction{
+		Status:           models.TransactionStatusFailure,
+		AdditionalFields: map[string]interface{}{"payment_intent_id": "pi_123"},
+	}, nil)
+	mockDatabase.On("DecideReview", mock.Anything, mock.MatchedBy(func(review *models.Review) bool {
+		return review.Status == models.ReviewStatusRejected && review.Reviewer == "alice" && review.Reason == "stolen card"
+	}), mock.MatchedBy(func(decided *models.Transaction) bool {
+		return decided.Status == models.TransactionStatusFailure && decided.FailureReason == models.FailureReasonReviewRejected &&
+			decided.PlatformFee == 0 && decided.NetAmount == 0 && len(decided.Transfers) == 0
+	}), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Actor == "alice" && entry.Action == models.AuditActionRejectReview && entry.Reason == "stolen card"
+	})).Return(&models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusFailure}, nil)
+	mockDatabase.On("ReleaseUsage", mock.Anything, mock.MatchedBy(func(reservation *models.UsageReservation) bool {
+		return reservation.MerchantID == "MCH_123" && reservation.Currency == "usd" && reservation.Charged == 150000
+	})).Return(nil)
+
+	reviewService := NewReviewService(&mockDatabase, &mockPaymentProcessor)
+
+	review, err := reviewService.RejectReview(context.Background(), "alice", "REV_123", "stolen card")
+	c.NoError(err)
+	c.Equal(models.ReviewStatusRejected, review.Status)
+	c.Equal(models.TransactionStatusFailure, review.Transaction.Status)
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestRejectReviewMissingReason(t *testing.T) {
+	c := require.New(t)
+
+	reviewService := NewReviewService(&postgres.MockPostgres{}, &stripe.MockStripe{})
+
+	_, err := reviewService.RejectReview(context.Background(), "alice", "REV_123", "")
+	c.ErrorIs(err, ErrMissingReason)
+}
+
+func TestExpireReviews(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	expired := pendingTestReview(time.Now().Add(-time.Minute))
+	failing := pendingTestReview(time.Now().Add(-time.Minute))
+	failing.ReviewID = "REV_456"
+	failing.TransactionID = "TXN_456"
+
+	failingTransaction := reviewedTestTransaction()
+	failingTransaction.TransactionID = "TXN_456"
+	failingTransaction.AdditionalFields = map[string]interface{}{"payment_intent_id": "pi_456"}
+
+	mockDatabase.On("ListReviews", mock.Anything, mock.MatchedBy(func(filter *models.ReviewFilter) bool {
+		return filter.Status == models.ReviewStatusPending && filter.ExpiredBefore != nil
+	})).Return([]*models.Review{expired, failing}, nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(reviewedTestTransaction(), nil)
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_456").Return(failingTransaction, nil)
+	mockDatabase.On("ClaimReview", mock.Anything, mock.Anything, models.ReviewerTimeout, mock.AnythingOfType("time.Time")).Return(nil)
+	mockDatabase.On("ReleaseReview", mock.Anything, "REV_456", models.ReviewerTimeout).Return(nil)
+	mockPaymentProcessor.On("CancelPayment", mock.Anything, map[string]interface{}{"payment_intent_id": "pi_123"}).Return(&models.Transaction{
+		Status:           models.TransactionStatusFailure,
+		AdditionalFields: map[string]interface{}{"payment_intent_id": "pi_123"},
+	}, nil)
+	mockPaymentProcessor.On("CancelPayment", mock.Anything, map[string]interface{}{"payment_intent_id": "pi_456"}).Return(nil, api.NewInternalServerError(errors.New("stripe unavailable")))
+	mockDatabase.On("DecideReview", mock.Anything, mock.MatchedBy(func(review *models.Review) bool {
+		return review.ReviewID == "REV_123" && review.Reviewer == models.ReviewerTimeout
+	}), mock.MatchedBy(func(decided *models.Transaction) bool {
+		return decided.FailureReason == models.FailureReasonReviewExpired
+	}), mock.Anything).Return(&models.Transaction{TransactionID: "TXN_123", Status: models.TransactionStatusFailure}, nil)
+	mockDatabase.On("ReleaseUsage", mock.Anything, mock.Anything).Return(nil)
+
+	reviewService := NewReviewService(&mockDatabase, &mockPaymentProcessor)
+
+	count, err := reviewService.ExpireReviews(context.Background())
+	c.NoError(err)
+	c.Equal(1, count)
+	mockDatabase.AssertNumberOfCalls(t, "DecideReview", 1)
+	mockDatabase.AssertCalled(t, "ReleaseReview", mock.Anything, "REV_456", models.ReviewerTimeout)
+}
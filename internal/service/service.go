@@ -3,94 +3,434 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
 
 	"github.com/aledeltoro/simple-online-payment-platform/internal/api"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/export"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/limits"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/logging"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/pricing"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/risk"
+	"github.com/oklog/ulid/v2"
 )
 
 var (
 	// ErrMissingTransactionID error when transaction ID is missing
 	ErrMissingTransactionID = api.NewInvalidRequestError(errors.New("missing transaction id"))
+	// ErrMissingMerchantID error when merchant ID is missing
+	ErrMissingMerchantID = api.NewUnauthorizedError(errors.New("missing merchant id"))
+	// ErrVersionMismatch error when transaction doesn't match the version expected by the client
+	ErrVersionMismatch = api.NewPreconditionFailedError(errors.New("transaction version mismatch"))
+	// ErrApplicationFeeBelowPlatformFee error when the application fee of a destination charge doesn't cover the platform fee
+	ErrApplicationFeeBelowPlatformFee = api.NewInvalidRequestError(errors.New("application fee amount below platform fee"))
+	// ErrTransactionUnderReview error when the transaction can't change until its review is decided
+	ErrTransactionUnderReview = api.NewConflictError(errors.New("transaction under review"))
+	// ErrTransactionArchived error when the transaction was archived by the retention policy and can't change anymore
+	ErrTransactionArchived = api.NewConflictError(errors.New("transaction archived"))
 )
 
 // OnlinePaymentService interface to implement business logic for the online payment platform
 type OnlinePaymentService interface {
-	ProcessPayment(ctx context.Context, amount int64, currency, paymentMethod, description string) (*models.Transaction, error)
-	QueryPayment(ctx context.Context, transactionID string) (*models.Transaction, error)
-	RefundPayment(ctx context.Context, transactionID string) (*models.Transaction, error)
+	ProcessPayment(ctx context.Context, merchantID string, input *models.TransactionInput) (*models.Transaction, error)
+	QueryPayment(ctx context.Context, merchantID, transactionID string) (*models.Transaction, error)
+	RefundPayment(ctx context.Context, merchantID, transactionID string, expectedVersion int) (*models.Transaction, error)
+	ExportPayments(ctx context.Context, merchantID string, filter *models.TransactionFilter, format export.Format, w io.Writer) error
 }
 
 type onlinePaymentService struct {
 	database         database.Database
 	paymentProcessor paymentprocessor.PaymentProcessor
+	pricing          pricing.Pricing
+	// risk engine evaluated before charging, where nil skips the risk step
+	risk *risk.Engine
+	// reviewTimeout how long a payment flagged for review waits for a decision
+	reviewTimeout time.Duration
+	limits        limits.Limits
 }
 
 // NewOnlinePaymentService constructor for online payment service
-func NewOnlinePaymentService(database database.Database, paymentProcessor paymentprocessor.PaymentProcessor) OnlinePaymentService {
+func NewOnlinePaymentService(database database.Database, paymentProcessor paymentprocessor.PaymentProcessor, pricing pricing.Pricing, risk *risk.Engine, reviewTimeout time.Duration, merchantLimits limits.Limits) OnlinePaymentService {
 	return onlinePaymentService{
 		database:         database,
 		paymentProcessor: paymentProcessor,
+		pricing:          pricing,
+		risk:             risk,
+		reviewTimeout:    reviewTimeout,
+		limits:           merchantLimits,
 	}
 }
 
 // ProcessPayment handles business logic to process a payment
-func (o onlinePaymentService) ProcessPayment(ctx context.Context, amount int64, currency, paymentMethod, description string) (*models.Transaction, error) {
-	input := &models.TransactionInput{
-		Amount:        amount,
-		Currency:      currency,
-		PaymentMethod: paymentMethod,
-		Description:   description,
+func (o onlinePaymentService) ProcessPayment(ctx context.Context, merchantID string, input *models.TransactionInput) (*models.Transaction, error) {
+	if merchantID == "" {
+		return nil, ErrMissingMerchantID
 	}
 
+	input.MerchantID = merchantID
+
 	err := input.Validate()
 	if err != nil {
 		return nil, api.NewInvalidRequestError(err)
 	}
 
-	transaction, err := o.paymentProcessor.PerformTransaction(input)
+	err = o.checkBlockList(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
-	err = o.database.InsertTransaction(ctx, transaction)
+	var destination *models.ConnectedAccount
+
+	if input.TransferDestination != "" {
+		destination, err = o.prepareDestinationCharge(ctx, merchantID, input)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var decision *models.RiskDecision
+
+	if o.risk != nil {
+		decision, err = o.risk.Evaluate(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var transaction *models.Transaction
+
+	// payments flagged for review are only authorized, so a reviewer decides whether they are captured
+	input.CaptureManually = decision != nil && decision.Outcome == models.RiskOutcomeReview
+
+	if decision != nil && decision.Outcome == models.RiskOutcomeBlock {
+		transaction = blockedTransaction(input)
+	} else {
+		reservation := &models.UsageReservation{MerchantID: merchantID, Currency: input.Currency, Day: time.Now().UTC(), Charged: int(input.Amount)}
+
+		err = reserveUsage(ctx, o.database, reservation, o.limits.For(merchantID, input.Currency))
+		if err != nil {
+			return nil, err
+		}
+
+		transaction, err = o.paymentProcessor.PerformTransaction(ctx, input)
+		if err != nil {
+			releaseUsage(ctx, o.database, reservation)
+			return nil, err
+		}
+
+		// declined charges don't count towards the volume of the merchant
+		if transaction.Status == models.TransactionStatusFailure {
+			releaseUsage(ctx, o.database, reservation)
+		}
+	}
+
+	ctx = logging.WithTransactionID(ctx, transaction.TransactionID)
+	transaction.MerchantID = merchantID
+
+	if decision != nil {
+		decision.TransactionID = transaction.TransactionID
+		transaction.RiskDecision = decision
+
+		if decision.Outcome != models.RiskOutcomeAllow {
+			slog.WarnContext(ctx, "payment flagged by risk rules", slog.String("outcome", string(decision.Outcome)), slog.Any("rules", matchedRules(decision)))
+		}
+	}
+
+	// declined authorizations have nothing left to review
+	if transaction.Status == models.TransactionStatusReview {
+		transaction.Review = o.newReview(transaction, decision)
+	}
+
+	// failed charges don't move any funds, so there's nothing to charge the merchant
+	if transaction.Status != models.TransactionStatusFailure {
+		transaction.PlatformFee = o.pricing.ApplicationFee(merchantID, transaction.Amount, transaction.Currency)
+		transaction.NetAmount = transaction.Amount - transaction.PlatformFee
+
+		if destination != nil {
+			providerTransferID, _ := transaction.AdditionalFields["transfer_id"].(string)
+
+			transfer := newTransfer(transaction, destination.AccountID, models.TransferTypeTransfer, transaction.Amount-int(input.ApplicationFeeAmount), providerTransferID)
+			transaction.NetAmount -= transfer.Amount
+
+			// authorizations under review move no funds, so their transfer is only recorded once they are captured
+			if transaction.Review != nil {
+				transaction.Review.Transfer = transfer
+			} else {
+				transaction.Transfers = []*models.Transfer{transfer}
+			}
+		}
+	}
+
+	err = o.database.InsertTransaction(ctx, transaction, &models.AuditEntry{
+		Action:       models.AuditActionCreatePayment,
+		ResourceType: models.AuditResourceTransaction,
+		ResourceID:   transaction.TransactionID,
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	slog.InfoContext(ctx, "payment processed",
+		slog.String("status", string(transaction.Status)),
+		slog.String("failure_reason", transaction.FailureReason),
+		slog.Int("amount", transaction.Amount),
+		slog.String("currency", transaction.Currency),
+		slog.Int("platform_fee", transaction.PlatformFee),
+	)
+
 	return transaction, nil
 }
 
-// QueryPayment handles business logic to query a payment
-func (o onlinePaymentService) QueryPayment(ctx context.Context, transactionID string) (*models.Transaction, error) {
-	if transactionID == "" {
-		return nil, ErrMissingTransactionID
+// blockedTransaction builds the failed transaction of a payment blocked by the risk rules, which never reached the
+// payment provider
+func blockedTransaction(input *models.TransactionInput) *models.Transaction {
+	return &models.Transaction{
+		TransactionID:    fmt.Sprintf("TXN_%s", ulid.Make().String()),
+		Status:           models.TransactionStatusFailure,
+		Description:      input.Description,
+		FailureReason:    models.FailureReasonRiskBlocked,
+		Provider:         models.PaymentProviderStripe,
+		Amount:           int(input.Amount),
+		Currency:         input.Currency,
+		Type:             models.TransactionTypeCharge,
+		AdditionalFields: map[string]interface{}{},
+	}
+}
+
+// newReview builds the pending review of an authorized payment, which expires once the review timeout elapses
+func (o onlinePaymentService) newReview(transaction *models.Transaction, decision *models.RiskDecision) *models.Review {
+	now := time.Now().UTC()
+
+	return &models.Review{
+		ReviewID:      fmt.Sprintf("REV_%s", ulid.Make().String()),
+		TransactionID: transaction.TransactionID,
+		MerchantID:    transaction.MerchantID,
+		Status:        models.ReviewStatusPending,
+		Amount:        transaction.Amount,
+		Currency:      transaction.Currency,
+		Rules:         matchedRules(decision),
+		ExpiresAt:     now.Add(o.reviewTimeout),
+		CreatedAt:     now,
+	}
+}
+
+// matchedRules names the rules that matched in a risk decision
+func matchedRules(decision *models.RiskDecision) []string {
+	rules := []string{}
+
+	for _, result := range decision.Rules {
+		if result.Matched {
+			rules = append(rules, result.Rule)
+		}
 	}
 
-	return o.database.GetTransaction(ctx, transactionID)
+	return rules
 }
 
-// RefundPayment handles business logic to refund a payment
-func (o onlinePaymentService) RefundPayment(ctx context.Context, transactionID string) (*models.Transaction, error) {
-	if transactionID == "" {
-		return nil, ErrMissingTransactionID
+// prepareDestinationCharge resolves the connected account receiving the charge, which must belong to the merchant, and
+// defaults the application fee to the platform fee, the least the merchant can keep
+func (o onlinePaymentService) prepareDestinationCharge(ctx context.Context, merchantID string, input *models.TransactionInput) (*models.ConnectedAccount, error) {
+	destination, err := getMerchantConnectedAccount(ctx, o.database, merchantID, input.TransferDestination)
+	if err != nil {
+		return nil, err
 	}
 
-	transaction, err := o.database.GetTransaction(ctx, transactionID)
+	platformFee := int64(o.pricing.ApplicationFee(merchantID, int(input.Amount), input.Currency))
+
+	if input.ApplicationFeeAmount == 0 {
+		input.ApplicationFeeAmount = platformFee
+	}
+
+	if input.ApplicationFeeAmount < platformFee {
+		return nil, ErrApplicationFeeBelowPlatformFee
+	}
+
+	input.DestinationProviderAccountID = destination.ProviderAccountID
+
+	return destination, nil
+}
+
+// QueryPayment handles business logic to query a payment along with its transfers
+func (o onlinePaymentService) QueryPayment(ctx context.Context, merchantID, transactionID string) (*models.Transaction, error) {
+	transaction, err := o.getMerchantTransaction(ctx, merchantID, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.Transfers, err = o.database.ListTransfers(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// RefundPayment handles business logic to refund a payment. When expectedVersion is not zero, the
+// refund is only performed if the transaction is still at that version. Refunds over the approval threshold of the
+// merchant are held until an operator approves them
+func (o onlinePaymentService) RefundPayment(ctx context.Context, merchantID, transactionID string, expectedVersion int) (*models.Transaction, error) {
+	transaction, err := o.getMerchantTransaction(ctx, merchantID, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if expectedVersion != 0 && transaction.Version != expectedVersion {
+		return nil, ErrVersionMismatch
+	}
+
+	merchantLimits := o.limits.For(merchantID, transaction.Currency)
+
+	if requiresApproval(transaction, merchantLimits) {
+		return requestRefund(ctx, o.database, transaction, "", merchantLimits)
+	}
+
+	return refundTransaction(ctx, o.database, o.paymentProcessor, transaction, merchantLimits, "")
+}
+
+// refundTransaction issues the refund of a transaction with its provider and stores the result. The refund counts
+// towards the refund ratio of the merchant, enforced unless merchantLimits is nil
+func refundTransaction(ctx context.Context, db database.Database, paymentProcessor paymentprocessor.PaymentProcessor, transaction *models.Transaction, merchantLimits *models.MerchantLimits, reason string) (*models.Transaction, error) {
+	ctx = logging.WithTransactionID(ctx, transaction.TransactionID)
+
+	err := checkRefundable(transaction)
 	if err != nil {
 		return nil, err
 	}
 
-	refundedTransaction, err := o.paymentProcessor.RefundTransaction(transaction.AdditionalFields)
+	reservation := &models.UsageReservation{MerchantID: transaction.MerchantID, Currency: transaction.Currency, Day: time.Now().UTC(), Refunded: transaction.Amount}
+
+	err = reserveUsage(ctx, db, reservation, merchantLimits)
 	if err != nil {
 		return nil, err
 	}
 
-	updatedTransaction, err := o.database.UpdateTransaction(ctx, transactionID, refundedTransaction)
+	return issueRefund(ctx, db, paymentProcessor, transaction, reservation, reason)
+}
+
+// checkRefundable rejects refunds of transactions waiting for a decision or archived
+func checkRefundable(transaction *models.Transaction) error {
+	if transaction.Archived {
+		return ErrTransactionArchived
+	}
+
+	switch transaction.Status {
+	case models.TransactionStatusReview:
+		// the funds of a payment under review were never captured, rejecting its review releases them instead
+		return ErrTransactionUnderReview
+	case models.TransactionStatusRefundRequested:
+		return ErrRefundAlreadyRequested
+	}
+
+	return nil
+}
+
+// issueRefund refunds the transaction with its provider, releasing the reservation of its amount if the provider
+// fails, and stores the result along with its audit entry
+func issueRefund(ctx context.Context, db database.Database, paymentProcessor paymentprocessor.PaymentProcessor, transaction *models.Transaction, reservation *models.UsageReservation, reason string) (*models.Transaction, error) {
+	refundedTransaction, err := paymentProcessor.RefundTransaction(ctx, transaction.AdditionalFields)
 	if err != nil {
+		releaseUsage(ctx, db, reservation)
 		return nil, err
 	}
 
+	return recordRefund(ctx, db, transaction, refundedTransaction, reason)
+}
+
+// recordRefund stores the refund the provider issued for the transaction, along with the reversals of its transfers
+// and its audit entry
+func recordRefund(ctx context.Context, db database.Database, transaction, refundedTransaction *models.Transaction, reason string) (*models.Transaction, error) {
+	var err error
+
+	// the provider reverses the transfers of destination charges along with the refund
+	if _, ok := transaction.AdditionalFields["transfer_id"]; ok {
+		refundedTransaction.Transfers, err = transferReversals(ctx, db, transaction, refundedTransaction)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the refund was already issued by the provider, so a concurrent update must not discard it
+	entry := &models.AuditEntry{
+		Action:       models.AuditActionRefund,
+		ResourceType: models.AuditResourceTransaction,
+		ResourceID:   transaction.TransactionID,
+		Reason:       reason,
+	}
+
+	updatedTransaction, err := database.UpdateTransactionWithRetry(ctx, db, transaction.TransactionID, transaction, entry, func(current *models.Transaction) *models.Transaction {
+		return refundedTransaction
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "payment refunded", slog.String("status", string(updatedTransaction.Status)))
+
 	return updatedTransaction, nil
 }
+
+// transferReversals builds the reversals of the transfers of a transaction, proportional to the refunded amount.
+// Refunds are issued for the whole amount of the charge
+func transferReversals(ctx context.Context, db database.Database, transaction, refundedTransaction *models.Transaction) ([]*models.Transfer, error) {
+	transfers, err := db.ListTransfers(ctx, transaction.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	providerReversalID, _ := refundedTransaction.AdditionalFields["transfer_reversal_id"].(string)
+
+	reversals := []*models.Transfer{}
+
+	for _, transfer := range transfers {
+		if transfer.Type != models.TransferTypeTransfer {
+			continue
+		}
+
+		amount := transfer.ReversalAmount(transaction.Amount, transaction.Amount)
+
+		reversals = append(reversals, newTransfer(transaction, transfer.AccountID, models.TransferTypeReversal, amount, providerReversalID))
+	}
+
+	return reversals, nil
+}
+
+// newTransfer builds a transfer of the transaction to or from a connected account
+func newTransfer(transaction *models.Transaction, accountID string, transferType models.TransferType, amount int, providerTransferID string) *models.Transfer {
+	return &models.Transfer{
+		TransferID:         fmt.Sprintf("TRF_%s", ulid.Make().String()),
+		TransactionID:      transaction.TransactionID,
+		AccountID:          accountID,
+		Type:               transferType,
+		Amount:             amount,
+		Currency:           transaction.Currency,
+		ProviderTransferID: providerTransferID,
+		CreatedAt:          time.Now().UTC(),
+	}
+}
+
+// getMerchantTransaction fetches a transaction, hiding it from merchants other than its owner
+func (o onlinePaymentService) getMerchantTransaction(ctx context.Context, merchantID, transactionID string) (*models.Transaction, error) {
+	if merchantID == "" {
+		return nil, ErrMissingMerchantID
+	}
+
+	if transactionID == "" {
+		return nil, ErrMissingTransactionID
+	}
+
+	transaction, err := o.database.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if transaction.MerchantID != merchantID {
+		return nil, api.NewResourceNotFoundError(database.ErrTransactionNotFound, "transaction")
+	}
+
+	return transaction, nil
+}
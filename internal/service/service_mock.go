@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"io"
 
+	"github.com/aledeltoro/simple-online-payment-platform/internal/export"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,8 +15,8 @@ type MockOnlinePaymentService struct {
 }
 
 // ProcessPayment mock implementation
-func (m *MockOnlinePaymentService) ProcessPayment(ctx context.Context, amount int64, currency, paymentMethod, description string) (*models.Transaction, error) {
-	args := m.Called(ctx, amount, currency, paymentMethod, description)
+func (m *MockOnlinePaymentService) ProcessPayment(ctx context.Context, merchantID string, input *models.TransactionInput) (*models.Transaction, error) {
+	args := m.Called(ctx, merchantID, input)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -24,8 +26,8 @@ func (m *MockOnlinePaymentService) ProcessPayment(ctx context.Context, amount in
 }
 
 // QueryPayment mock implementation
-func (m *MockOnlinePaymentService) QueryPayment(ctx context.Context, transactionID string) (*models.Transaction, error) {
-	args := m.Called(ctx, transactionID)
+func (m *MockOnlinePaymentService) QueryPayment(ctx context.Context, merchantID, transactionID string) (*models.Transaction, error) {
+	args := m.Called(ctx, merchantID, transactionID)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -35,8 +37,8 @@ func (m *MockOnlinePaymentService) QueryPayment(ctx context.Context, transaction
 }
 
 // RefundPayment mock implementation
-func (m *MockOnlinePaymentService) RefundPayment(ctx context.Context, transactionID string) (*models.Transaction, error) {
-	args := m.Called(ctx, transactionID)
+func (m *MockOnlinePaymentService) RefundPayment(ctx context.Context, merchantID, transactionID string, expectedVersion int) (*models.Transaction, error) {
+	args := m.Called(ctx, merchantID, transactionID, expectedVersion)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -44,3 +46,304 @@ func (m *MockOnlinePaymentService) RefundPayment(ctx context.Context, transactio
 
 	return args.Get(0).(*models.Transaction), args.Error(1)
 }
+
+// ExportPayments mock implementation, writing the content the mock returns
+func (m *MockOnlinePaymentService) ExportPayments(ctx context.Context, merchantID string, filter *models.TransactionFilter, format export.Format, w io.Writer) error {
+	args := m.Called(ctx, merchantID, filter, format)
+
+	if content := args.String(0); content != "" {
+		_, _ = io.WriteString(w, content)
+	}
+
+	return args.Error(1)
+}
+
+// MockMerchantService mock object for merchant service implementation
+type MockMerchantService struct {
+	mock.Mock
+}
+
+// CreateMerchant mock implementation
+func (m *MockMerchantService) CreateMerchant(ctx context.Context, name string) (*models.MerchantCredentials, error) {
+	args := m.Called(ctx, name)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.MerchantCredentials), args.Error(1)
+}
+
+// RotateAPIKey mock implementation
+func (m *MockMerchantService) RotateAPIKey(ctx context.Context, merchantID, keyID string) (*models.APIKeyCredential, error) {
+	args := m.Called(ctx, merchantID, keyID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.APIKeyCredential), args.Error(1)
+}
+
+// MockMarketplaceService mock object for marketplace service implementation
+type MockMarketplaceService struct {
+	mock.Mock
+}
+
+// CreateConnectedAccount mock implementation
+func (m *MockMarketplaceService) CreateConnectedAccount(ctx context.Context, merchantID, name, providerAccountID string) (*models.ConnectedAccount, error) {
+	args := m.Called(ctx, merchantID, name, providerAccountID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.ConnectedAccount), args.Error(1)
+}
+
+// ListConnectedAccounts mock implementation
+func (m *MockMarketplaceService) ListConnectedAccounts(ctx context.Context, merchantID string) ([]*models.ConnectedAccount, error) {
+	args := m.Called(ctx, merchantID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.ConnectedAccount), args.Error(1)
+}
+
+// MockPayoutService mock object for payout service implementation
+type MockPayoutService struct {
+	mock.Mock
+}
+
+// ListPayouts mock implementation
+func (m *MockPayoutService) ListPayouts(ctx context.Context, filter *models.PayoutFilter) ([]*models.Payout, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Payout), args.Error(1)
+}
+
+// ListPayoutTransactions mock implementation
+func (m *MockPayoutService) ListPayoutTransactions(ctx context.Context, payoutID string) ([]*models.Transaction, error) {
+	args := m.Called(ctx, payoutID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Transaction), args.Error(1)
+}
+
+// MockRefundRequestService mock object for refund request service implementation
+type MockRefundRequestService struct {
+	mock.Mock
+}
+
+// ListRefundRequests mock implementation
+func (m *MockRefundRequestService) ListRefundRequests(ctx context.Context, filter *models.RefundRequestFilter) ([]*models.RefundRequest, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.RefundRequest), args.Error(1)
+}
+
+// ApproveRefundRequest mock implementation
+func (m *MockRefundRequestService) ApproveRefundRequest(ctx context.Context, approver, refundRequestID string) (*models.RefundRequest, error) {
+	args := m.Called(ctx, approver, refundRequestID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.RefundRequest), args.Error(1)
+}
+
+// RejectRefundRequest mock implementation
+func (m *MockRefundRequestService) RejectRefundRequest(ctx context.Context, approver, refundRequestID, reason string) (*models.RefundRequest, error) {
+	args := m.Called(ctx, approver, refundRequestID, reason)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.RefundRequest), args.Error(1)
+}
+
+// MockUsageService mock object for usage service implementation
+type MockUsageService struct {
+	mock.Mock
+}
+
+// ListUsage mock implementation
+func (m *MockUsageService) ListUsage(ctx context.Context, merchantID string) ([]*models.Usage, error) {
+	args := m.Called(ctx, merchantID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Usage), args.Error(1)
+}
+
+// MockReviewService mock object for review service implementation
+type MockReviewService struct {
+	mock.Mock
+}
+
+// ListReviews mock implementation
+func (m *MockReviewService) ListReviews(ctx context.Context, filter *models.ReviewFilter) ([]*models.Review, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.Review), args.Error(1)
+}
+
+// ApproveReview mock implementation
+func (m *MockReviewService) ApproveReview(ctx context.Context, reviewer, reviewID string) (*models.Review, error) {
+	args := m.Called(ctx, reviewer, reviewID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.Review), args.Error(1)
+}
+
+// RejectReview mock implementation
+func (m *MockReviewService) RejectReview(ctx context.Context, reviewer, reviewID, reason string) (*models.Review, error) {
+	args := m.Called(ctx, reviewer, reviewID, reason)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.Review), args.Error(1)
+}
+
+// ExpireReviews mock implementation
+func (m *MockReviewService) ExpireReviews(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+
+	return args.Int(0), args.Error(1)
+}
+
+// MockBlockListService mock object for block list service implementation
+type MockBlockListService struct {
+	mock.Mock
+}
+
+// CreateBlockListEntry mock implementation
+func (m *MockBlockListService) CreateBlockListEntry(ctx context.Context, entry *models.BlockListEntry) (*models.BlockListEntry, error) {
+	args := m.Called(ctx, entry)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.BlockListEntry), args.Error(1)
+}
+
+// GetBlockListEntry mock implementation
+func (m *MockBlockListService) GetBlockListEntry(ctx context.Context, entryID string) (*models.BlockListEntry, error) {
+	args := m.Called(ctx, entryID)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.BlockListEntry), args.Error(1)
+}
+
+// ListBlockListEntries mock implementation
+func (m *MockBlockListService) ListBlockListEntries(ctx context.Context, filter *models.BlockListFilter) ([]*models.BlockListEntry, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.BlockListEntry), args.Error(1)
+}
+
+// UpdateBlockListEntry mock implementation
+func (m *MockBlockListService) UpdateBlockListEntry(ctx context.Context, entry *models.BlockListEntry) (*models.BlockListEntry, error) {
+	args := m.Called(ctx, entry)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.BlockListEntry), args.Error(1)
+}
+
+// DeleteBlockListEntry mock implementation
+func (m *MockBlockListService) DeleteBlockListEntry(ctx context.Context, entryID string) error {
+	args := m.Called(ctx, entryID)
+
+	return args.Error(0)
+}
+
+// MockAuditService mock object for audit service implementation
+type MockAuditService struct {
+	mock.Mock
+}
+
+// ListAuditEntries mock implementation
+func (m *MockAuditService) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	args := m.Called(ctx, filter)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*models.AuditEntry), args.Error(1)
+}
+
+// VerifyAuditLog mock implementation
+func (m *MockAuditService) VerifyAuditLog(ctx context.Context) (*models.AuditVerification, error) {
+	args := m.Called(ctx)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.AuditVerification), args.Error(1)
+}
+
+// MockCustomerDataService mock object for customer data service implementation
+type MockCustomerDataService struct {
+	mock.Mock
+}
+
+// ExportCustomerData mock implementation
+func (m *MockCustomerDataService) ExportCustomerData(ctx context.Context, customer string) (*models.CustomerData, error) {
+	args := m.Called(ctx, customer)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.CustomerData), args.Error(1)
+}
+
+// EraseCustomerData mock implementation
+func (m *MockCustomerDataService) EraseCustomerData(ctx context.Context, actor, customer, reason string) (*models.CustomerErasure, error) {
+	args := m.Called(ctx, actor, customer, reason)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*models.CustomerErasure), args.Error(1)
+}
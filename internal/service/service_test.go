@@ -4,14 +4,34 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/aledeltoro/simple-online-payment-platform/internal/audit"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
 	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor/stripe"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/pricing"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/risk"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
+// emptyBlockList sets up the database so payments match no block list entry
+func emptyBlockList(mockDatabase *postgres.MockPostgres) {
+	mockDatabase.On("MatchBlockList", mock.Anything, mock.Anything).Return([]*models.BlockListEntry{}, nil)
+	mockDatabase.On("ListBlockListEntries", mock.Anything, mock.Anything).Return([]*models.BlockListEntry{}, nil)
+}
+
+func unlimitedUsage(mockDatabase *postgres.MockPostgres) {
+	mockDatabase.On("ReserveUsage", mock.Anything, mock.Anything, mock.Anything).Return(&models.Usage{}, nil)
+	mockDatabase.On("ReleaseUsage", mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
 func TestProcessPayment(t *testing.T) {
 	c := require.New(t)
 
@@ -38,27 +58,359 @@ func TestProcessPayment(t *testing.T) {
 	}
 
 	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	unlimitedUsage(&mockDatabase)
 	mockPaymentProcessor := stripe.MockStripe{}
 
-	mockPaymentProcessor.On("PerformTransaction", input).Return(expectedTransaction, nil)
-	mockDatabase.On("InsertTransaction", context.Background(), expectedTransaction).Return(nil)
+	mockPaymentProcessor.On("PerformTransaction", context.Background(), input).Return(expectedTransaction, nil)
+	mockDatabase.On("InsertTransaction", mock.Anything, expectedTransaction, mock.Anything).Return(nil)
 
 	onlinePaymentService := onlinePaymentService{
 		database:         &mockDatabase,
 		paymentProcessor: &mockPaymentProcessor,
 	}
 
-	transaction, err := onlinePaymentService.ProcessPayment(context.Background(), input.Amount, input.Currency, input.PaymentMethod, input.Description)
+	transaction, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
 	c.NoError(err)
 	c.Equal(expectedTransaction, transaction)
 }
 
+func TestProcessPaymentRecordsAuditEntry(t *testing.T) {
+	c := require.New(t)
+
+	input := &models.TransactionInput{
+		Amount:        2000,
+		Currency:      "usd",
+		PaymentMethod: "card_pm_visa",
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	unlimitedUsage(&mockDatabase)
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockPaymentProcessor.On("PerformTransaction", mock.Anything, input).Return(&models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusSucceeded,
+		Amount:        2000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+	}, nil)
+	mockDatabase.On("InsertTransaction", mock.Anything, mock.MatchedBy(func(transaction *models.Transaction) bool {
+		return transaction.MerchantID == "MCH_123"
+	}), mock.MatchedBy(func(entry *models.AuditEntry) bool {
+		return entry.Action == models.AuditActionCreatePayment &&
+			entry.ResourceType == models.AuditResourceTransaction &&
+			entry.ResourceID == "TXN_123" &&
+			entry.Before == nil
+	})).Return(nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	ctx := audit.WithRequestFingerprint(audit.WithActor(context.Background(), models.AuditActorAPIKey, "KEY_123"), "3f1c")
+
+	_, err := onlinePaymentService.ProcessPayment(ctx, "MCH_123", input)
+	c.NoError(err)
+
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestProcessPaymentPlatformFee(t *testing.T) {
+	c := require.New(t)
+
+	input := &models.TransactionInput{
+		Amount:        2000,
+		Currency:      "usd",
+		PaymentMethod: "card_pm_visa",
+		Description:   "Transaction for payment maount of 2000",
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	unlimitedUsage(&mockDatabase)
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockPaymentProcessor.On("PerformTransaction", context.Background(), input).Return(&models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusPending,
+		Amount:        2000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+		ProviderFee:   88,
+		FeeCurrency:   "usd",
+	}, nil)
+	mockDatabase.On("InsertTransaction", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+		pricing: pricing.New(config.Pricing{
+			PricingPlan: config.PricingPlan{Default: config.Fee{Percentage: 2, Fixed: 10}},
+		}),
+	}
+
+	transaction, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.NoError(err)
+	c.Equal(50, transaction.PlatformFee)
+	c.Equal(1950, transaction.NetAmount)
+	c.Equal(88, transaction.ProviderFee)
+}
+
+func newTestRiskEngine(t *testing.T, store database.RiskStore, rules string) *risk.Engine {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+
+	err := os.WriteFile(path, []byte(rules), 0o600)
+	require.NoError(t, err)
+
+	engine, err := risk.New(path, store)
+	require.NoError(t, err)
+
+	return engine
+}
+
+func TestProcessPaymentRiskBlocked(t *testing.T) {
+	c := require.New(t)
+
+	input := &models.TransactionInput{
+		Amount:        2000,
+		Currency:      "usd",
+		PaymentMethod: "pm_card_visa",
+		Customer:      "fraud@example.com",
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("InsertTransaction", mock.Anything, mock.MatchedBy(func(transaction *models.Transaction) bool {
+		return transaction.Status == models.TransactionStatusFailure && transaction.FailureReason == models.FailureReasonRiskBlocked &&
+			transaction.RiskDecision.Outcome == models.RiskOutcomeBlock && transaction.RiskDecision.TransactionID == transaction.TransactionID
+	}), mock.Anything).Return(nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+		pricing: pricing.New(config.Pricing{
+			PricingPlan: config.PricingPlan{Default: config.Fee{Percentage: 2}},
+		}),
+		risk: newTestRiskEngine(t, &mockDatabase, `
+rules:
+  - name: known_fraudsters
+    type: block_list
+    action: block
+    field: customer
+    values: [fraud@example.com]
+`),
+	}
+
+	transaction, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.NoError(err)
+	c.Contains(transaction.TransactionID, "TXN_")
+	c.Equal("MCH_123", transaction.MerchantID)
+	c.Zero(transaction.PlatformFee)
+
+	mockPaymentProcessor.AssertNotCalled(t, "PerformTransaction", mock.Anything, mock.Anything)
+}
+
+func TestProcessPaymentRiskReview(t *testing.T) {
+	c := require.New(t)
+
+	input := &models.TransactionInput{
+		Amount:        150000,
+		Currency:      "usd",
+		PaymentMethod: "pm_card_visa",
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	unlimitedUsage(&mockDatabase)
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockPaymentProcessor.On("PerformTransaction", mock.Anything, mock.MatchedBy(func(input *models.TransactionInput) bool {
+		return input.CaptureManually
+	})).Return(&models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusReview,
+		Amount:        150000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+	}, nil)
+	mockDatabase.On("InsertTransaction", mock.Anything, mock.MatchedBy(func(transaction *models.Transaction) bool {
+		return transaction.RiskDecision.Outcome == models.RiskOutcomeReview && transaction.RiskDecision.TransactionID == "TXN_123"
+	}), mock.Anything).Return(nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+		risk: newTestRiskEngine(t, &mockDatabase, `
+rules:
+  - name: large_charge
+    type: amount
+    action: review
+    max_amount: 100000
+`),
+		reviewTimeout: time.Hour,
+	}
+
+	transaction, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.NoError(err)
+	c.Equal(models.TransactionStatusReview, transaction.Status)
+	c.Len(transaction.RiskDecision.Rules, 1)
+	c.True(transaction.RiskDecision.Rules[0].Matched)
+
+	c.NotNil(transaction.Review)
+	c.Equal(models.ReviewStatusPending, transaction.Review.Status)
+	c.Equal("TXN_123", transaction.Review.TransactionID)
+	c.Equal("MCH_123", transaction.Review.MerchantID)
+	c.Equal([]string{"large_charge"}, transaction.Review.Rules)
+	c.WithinDuration(time.Now().Add(time.Hour), transaction.Review.ExpiresAt, time.Minute)
+}
+
+func TestProcessPaymentRiskReviewDeclined(t *testing.T) {
+	c := require.New(t)
+
+	input := &models.TransactionInput{
+		Amount:        150000,
+		Currency:      "usd",
+		PaymentMethod: "pm_card_chargeDeclined",
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	unlimitedUsage(&mockDatabase)
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockPaymentProcessor.On("PerformTransaction", mock.Anything, input).Return(&models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusFailure,
+		FailureReason: "card_declined",
+		Amount:        150000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+	}, nil)
+	mockDatabase.On("InsertTransaction", mock.Anything, mock.MatchedBy(func(transaction *models.Transaction) bool {
+		return transaction.Review == nil
+	}), mock.Anything).Return(nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+		risk: newTestRiskEngine(t, &mockDatabase, `
+rules:
+  - name: large_charge
+    type: amount
+    action: review
+    max_amount: 100000
+`),
+		reviewTimeout: time.Hour,
+	}
+
+	transaction, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.NoError(err)
+	c.Equal(models.TransactionStatusFailure, transaction.Status)
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestProcessPaymentDestinationCharge(t *testing.T) {
+	c := require.New(t)
+
+	input := &models.TransactionInput{
+		Amount:              2000,
+		Currency:            "usd",
+		PaymentMethod:       "card_pm_visa",
+		Description:         "Transaction for payment maount of 2000",
+		TransferDestination: "ACC_123",
+	}
+
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	unlimitedUsage(&mockDatabase)
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("GetConnectedAccount", mock.Anything, "ACC_123").Return(&models.ConnectedAccount{
+		AccountID:         "ACC_123",
+		MerchantID:        "MCH_123",
+		ProviderAccountID: "acct_123",
+	}, nil)
+	mockPaymentProcessor.On("PerformTransaction", context.Background(), mock.MatchedBy(func(input *models.TransactionInput) bool {
+		return input.DestinationProviderAccountID == "acct_123" && input.ApplicationFeeAmount == 50
+	})).Return(&models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusSucceeded,
+		Amount:        2000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+		AdditionalFields: map[string]interface{}{
+			"charge_id":   "ch_123",
+			"transfer_id": "tr_123",
+		},
+	}, nil)
+	mockDatabase.On("InsertTransaction", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+		pricing: pricing.New(config.Pricing{
+			PricingPlan: config.PricingPlan{Default: config.Fee{Percentage: 2, Fixed: 10}},
+		}),
+	}
+
+	transaction, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.NoError(err)
+	c.Equal(50, transaction.PlatformFee)
+	c.Equal(0, transaction.NetAmount)
+	c.Len(transaction.Transfers, 1)
+	c.Equal("ACC_123", transaction.Transfers[0].AccountID)
+	c.Equal(models.TransferTypeTransfer, transaction.Transfers[0].Type)
+	c.Equal(1950, transaction.Transfers[0].Amount)
+	c.Equal("tr_123", transaction.Transfers[0].ProviderTransferID)
+}
+
+func TestProcessPaymentDestinationChargeInvalidAccount(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+
+	mockDatabase.On("GetConnectedAccount", mock.Anything, "ACC_123").Return(&models.ConnectedAccount{
+		AccountID:  "ACC_123",
+		MerchantID: "MCH_OTHER",
+	}, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database: &mockDatabase,
+		pricing: pricing.New(config.Pricing{
+			PricingPlan: config.PricingPlan{Default: config.Fee{Percentage: 2, Fixed: 10}},
+		}),
+	}
+
+	input := &models.TransactionInput{Amount: 2000, Currency: "usd", PaymentMethod: "card_pm_visa", TransferDestination: "ACC_123"}
+
+	_, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.ErrorIs(err, database.ErrConnectedAccountNotFound)
+
+	mockDatabase.On("GetConnectedAccount", mock.Anything, "ACC_456").Return(&models.ConnectedAccount{
+		AccountID:  "ACC_456",
+		MerchantID: "MCH_123",
+	}, nil)
+
+	input = &models.TransactionInput{Amount: 2000, Currency: "usd", PaymentMethod: "card_pm_visa", TransferDestination: "ACC_456", ApplicationFeeAmount: 20}
+
+	_, err = onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
+	c.ErrorIs(err, ErrApplicationFeeBelowPlatformFee)
+}
+
 func TestProcessPaymentInvalidInput(t *testing.T) {
 	c := require.New(t)
 
 	onlinePaymentService := onlinePaymentService{}
 
-	_, err := onlinePaymentService.ProcessPayment(context.Background(), -12, "", "", "")
+	_, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", &models.TransactionInput{Amount: -12})
 	c.ErrorIs(err, models.ErrInvalidAmount)
 }
 
@@ -72,17 +424,21 @@ func TestProcessPaymentPerformTransactionFailure(t *testing.T) {
 		Description:   "Transaction for payment maount of 2000",
 	}
 
+	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	unlimitedUsage(&mockDatabase)
 	mockPaymentProcessor := stripe.MockStripe{}
 
 	customErr := fmt.Errorf("performing transaction: card_declined")
 
-	mockPaymentProcessor.On("PerformTransaction", input).Return(nil, customErr)
+	mockPaymentProcessor.On("PerformTransaction", context.Background(), input).Return(nil, customErr)
 
 	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
 		paymentProcessor: &mockPaymentProcessor,
 	}
 
-	_, err := onlinePaymentService.ProcessPayment(context.Background(), input.Amount, input.Currency, input.PaymentMethod, input.Description)
+	_, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
 	c.ErrorIs(err, customErr)
 }
 
@@ -112,19 +468,21 @@ func TestProcessPaymentInsertTransactionFailure(t *testing.T) {
 	}
 
 	mockDatabase := postgres.MockPostgres{}
+	emptyBlockList(&mockDatabase)
+	unlimitedUsage(&mockDatabase)
 	mockPaymentProcessor := stripe.MockStripe{}
 
 	customErr := fmt.Errorf("inserting transaction: operation failed")
 
-	mockPaymentProcessor.On("PerformTransaction", input).Return(expectedTransaction, nil)
-	mockDatabase.On("InsertTransaction", context.Background(), expectedTransaction).Return(customErr)
+	mockPaymentProcessor.On("PerformTransaction", context.Background(), input).Return(expectedTransaction, nil)
+	mockDatabase.On("InsertTransaction", mock.Anything, expectedTransaction, mock.Anything).Return(customErr)
 
 	onlinePaymentService := onlinePaymentService{
 		database:         &mockDatabase,
 		paymentProcessor: &mockPaymentProcessor,
 	}
 
-	_, err := onlinePaymentService.ProcessPayment(context.Background(), input.Amount, input.Currency, input.PaymentMethod, input.Description)
+	_, err := onlinePaymentService.ProcessPayment(context.Background(), "MCH_123", input)
 	c.ErrorIs(err, customErr)
 }
 
@@ -155,14 +513,17 @@ func TestQueryPayment(t *testing.T) {
 		},
 	}
 
+	expectedTransaction.MerchantID = "MCH_123"
+
 	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(expectedTransaction, nil)
+	mockDatabase.On("ListTransfers", context.Background(), "TXN_123").Return([]*models.Transfer{}, nil)
 
 	onlinePaymentService := onlinePaymentService{
 		database:         &mockDatabase,
 		paymentProcessor: nil,
 	}
 
-	transaction, err := onlinePaymentService.QueryPayment(context.Background(), "TXN_123")
+	transaction, err := onlinePaymentService.QueryPayment(context.Background(), "MCH_123", "TXN_123")
 	c.NoError(err)
 	c.Equal(expectedTransaction, transaction)
 }
@@ -172,7 +533,7 @@ func TestQueryPaymentMissingTransactionID(t *testing.T) {
 
 	onlinePaymentService := onlinePaymentService{}
 
-	_, err := onlinePaymentService.QueryPayment(context.Background(), "")
+	_, err := onlinePaymentService.QueryPayment(context.Background(), "MCH_123", "")
 	c.ErrorIs(err, ErrMissingTransactionID)
 }
 
@@ -195,6 +556,7 @@ func TestRefundPayment(t *testing.T) {
 
 	expectedTransaction := &models.Transaction{
 		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
 		Status:        models.TransactionStatusSucceeded,
 		Description:   "Transaction for payment amount of 2000",
 		FailureReason: "",
@@ -210,25 +572,183 @@ func TestRefundPayment(t *testing.T) {
 	}
 
 	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(expectedTransaction, nil)
-	mockPaymentProcessor.On("RefundTransaction", expectedTransaction.AdditionalFields).Return(refundedTransaction, nil)
-	mockDatabase.On("UpdateTransaction", context.Background(), "TXN_123", refundedTransaction).Return(expectedTransaction, nil)
+	mockPaymentProcessor.On("RefundTransaction", mock.Anything, expectedTransaction.AdditionalFields).Return(refundedTransaction, nil)
+	mockDatabase.On("UpdateTransaction", mock.Anything, "TXN_123", refundedTransaction, mock.Anything).Return(expectedTransaction, nil)
+	unlimitedUsage(&mockDatabase)
 
 	onlinePaymentService := onlinePaymentService{
 		database:         &mockDatabase,
 		paymentProcessor: &mockPaymentProcessor,
 	}
 
-	transaction, err := onlinePaymentService.RefundPayment(context.Background(), "TXN_123")
+	transaction, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "TXN_123", 0)
 	c.NoError(err)
 	c.Equal(expectedTransaction, transaction)
 }
 
+func TestRefundPaymentReversesTransfers(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusSucceeded,
+		Amount:        2000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+		AdditionalFields: map[string]interface{}{
+			"charge_id":   "ch_123",
+			"transfer_id": "tr_123",
+		},
+	}
+
+	refundedTransaction := &models.Transaction{
+		Status: models.TransactionStatusPending,
+		Type:   models.TransactionTypeRefund,
+		AdditionalFields: map[string]interface{}{
+			"charge_id":            "ch_123",
+			"refund_id":            "re_123",
+			"transfer_reversal_id": "trr_123",
+		},
+	}
+
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return(transaction, nil)
+	unlimitedUsage(&mockDatabase)
+	mockPaymentProcessor.On("RefundTransaction", mock.Anything, transaction.AdditionalFields).Return(refundedTransaction, nil)
+	mockDatabase.On("ListTransfers", mock.Anything, "TXN_123").Return([]*models.Transfer{
+		{TransferID: "TRF_1", TransactionID: "TXN_123", AccountID: "ACC_123", Type: models.TransferTypeTransfer, Amount: 1800, Currency: "usd"},
+	}, nil)
+	mockDatabase.On("UpdateTransaction", mock.Anything, "TXN_123", mock.MatchedBy(func(updatedTransaction *models.Transaction) bool {
+		if len(updatedTransaction.Transfers) != 1 {
+			return false
+		}
+
+		reversal := updatedTransaction.Transfers[0]
+
+		return reversal.Type == models.TransferTypeReversal && reversal.AccountID == "ACC_123" && reversal.Amount == 1800 && reversal.ProviderTransferID == "trr_123"
+	}), mock.Anything).Return(refundedTransaction, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	_, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "TXN_123", 0)
+	c.NoError(err)
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestQueryPaymentOtherMerchant(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_456",
+		Status:        models.TransactionStatusSucceeded,
+	}
+
+	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(transaction, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database: &mockDatabase,
+	}
+
+	_, err := onlinePaymentService.QueryPayment(context.Background(), "MCH_123", "TXN_123")
+	c.ErrorIs(err, database.ErrTransactionNotFound)
+}
+
+func TestQueryPaymentMissingMerchantID(t *testing.T) {
+	c := require.New(t)
+
+	onlinePaymentService := onlinePaymentService{}
+
+	_, err := onlinePaymentService.QueryPayment(context.Background(), "", "TXN_123")
+	c.ErrorIs(err, ErrMissingMerchantID)
+}
+
+func TestRefundPaymentOtherMerchant(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_456",
+		Status:        models.TransactionStatusSucceeded,
+		AdditionalFields: map[string]interface{}{
+			"charge_id": "ch_123",
+		},
+	}
+
+	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(transaction, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	_, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "TXN_123", 0)
+	c.ErrorIs(err, database.ErrTransactionNotFound)
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
+}
+
+func TestRefundPaymentUnderReview(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(&models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusReview,
+	}, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	_, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "TXN_123", 0)
+	c.ErrorIs(err, ErrTransactionUnderReview)
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
+}
+
+func TestRefundPaymentArchived(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(&models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusSucceeded,
+		Archived:      true,
+	}, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	_, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "TXN_123", 0)
+	c.ErrorIs(err, ErrTransactionArchived)
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
+}
+
 func TestRefundPaymentMissingTransactionID(t *testing.T) {
 	c := require.New(t)
 
 	onlinePaymentService := onlinePaymentService{}
 
-	_, err := onlinePaymentService.RefundPayment(context.Background(), "")
+	_, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "", 0)
 	c.ErrorIs(err, ErrMissingTransactionID)
 }
 
@@ -240,6 +760,7 @@ func TestRefunPaymentTransactionRefundFailure(t *testing.T) {
 
 	expectedTransaction := &models.Transaction{
 		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
 		Status:        models.TransactionStatusSucceeded,
 		Description:   "Transaction for payment amount of 2000",
 		FailureReason: "",
@@ -257,13 +778,43 @@ func TestRefunPaymentTransactionRefundFailure(t *testing.T) {
 	customErr := errors.New("refunding transaction: charge already refunded")
 
 	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(expectedTransaction, nil)
-	mockPaymentProcessor.On("RefundTransaction", expectedTransaction.AdditionalFields).Return(nil, customErr)
+	mockPaymentProcessor.On("RefundTransaction", mock.Anything, expectedTransaction.AdditionalFields).Return(nil, customErr)
+	mockDatabase.On("ReserveUsage", mock.Anything, mock.Anything, mock.Anything).Return(&models.Usage{}, nil)
+	mockDatabase.On("ReleaseUsage", mock.Anything, mock.MatchedBy(func(reservation *models.UsageReservation) bool {
+		return reservation.MerchantID == "MCH_123" && reservation.Currency == "usd" && reservation.Refunded == 2000
+	})).Return(nil)
 
 	onlinePaymentService := onlinePaymentService{
 		database:         &mockDatabase,
 		paymentProcessor: &mockPaymentProcessor,
 	}
 
-	_, err := onlinePaymentService.RefundPayment(context.Background(), "TXN_123")
+	_, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "TXN_123", 0)
 	c.ErrorIs(err, customErr)
+	mockDatabase.AssertExpectations(t)
+}
+
+func TestRefundPaymentVersionMismatch(t *testing.T) {
+	c := require.New(t)
+
+	mockDatabase := postgres.MockPostgres{}
+	mockPaymentProcessor := stripe.MockStripe{}
+
+	transaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		MerchantID:    "MCH_123",
+		Status:        models.TransactionStatusSucceeded,
+		Version:       3,
+	}
+
+	mockDatabase.On("GetTransaction", context.Background(), "TXN_123").Return(transaction, nil)
+
+	onlinePaymentService := onlinePaymentService{
+		database:         &mockDatabase,
+		paymentProcessor: &mockPaymentProcessor,
+	}
+
+	_, err := onlinePaymentService.RefundPayment(context.Background(), "MCH_123", "TXN_123", 2)
+	c.ErrorIs(err, ErrVersionMismatch)
+	mockPaymentProcessor.AssertNotCalled(t, "RefundTransaction", mock.Anything, mock.Anything)
 }
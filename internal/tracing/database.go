@@ -0,0 +1,704 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedDatabase embeds the wrapped database so operations without explicit
+// instrumentation are still forwarded
+type tracedDatabase struct {
+	database.Database
+	tracer trace.Tracer
+}
+
+// NewDatabase decorates a database to trace each of its operations
+func NewDatabase(next database.Database) database.Database {
+	return tracedDatabase{
+		Database: next,
+		tracer:   tracer(),
+	}
+}
+
+// InsertTransaction traces the wrapped call with the inserted transaction
+func (t tracedDatabase) InsertTransaction(ctx context.Context, transaction *models.Transaction, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "insert_transaction", TransactionAttributes(transaction)...)
+
+	err := t.Database.InsertTransaction(ctx, transaction, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// GetTransaction traces the wrapped call
+func (t tracedDatabase) GetTransaction(ctx context.Context, transactionID string) (*models.Transaction, error) {
+	ctx, span := t.start(ctx, "get_transaction", attribute.String("payment.transaction_id", transactionID))
+
+	transaction, err := t.Database.GetTransaction(ctx, transactionID)
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// UpdateTransaction traces the wrapped call with the resulting transaction
+func (t tracedDatabase) UpdateTransaction(ctx context.Context, transactionID string, updatedTransaction *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error) {
+	ctx, span := t.start(ctx, "update_transaction", attribute.String("payment.transaction_id", transactionID))
+
+	transaction, err := t.Database.UpdateTransaction(ctx, transactionID, updatedTransaction, entry)
+	if transaction != nil {
+		span.SetAttributes(TransactionAttributes(transaction)...)
+	}
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// ListTransactions traces the wrapped call with the number of transactions found
+func (t tracedDatabase) ListTransactions(ctx context.Context, filter *models.TransactionFilter) ([]*models.Transaction, error) {
+	ctx, span := t.start(ctx, "list_transactions", attribute.String("merchant.id", filter.MerchantID))
+
+	transactions, err := t.Database.ListTransactions(ctx, filter)
+
+	span.SetAttributes(attribute.Int("db.rows", len(transactions)))
+
+	End(span, err)
+
+	return transactions, err
+}
+
+// StreamTransactions traces the wrapped call with the number of transactions streamed
+func (t tracedDatabase) StreamTransactions(ctx context.Context, filter *models.TransactionFilter, fn func(*models.Transaction) error) error {
+	ctx, span := t.start(ctx, "stream_transactions", attribute.String("merchant.id", filter.MerchantID))
+
+	streamed := 0
+
+	err := t.Database.StreamTransactions(ctx, filter, func(transaction *models.Transaction) error {
+		streamed++
+
+		return fn(transaction)
+	})
+
+	span.SetAttributes(attribute.Int("db.rows", streamed))
+
+	End(span, err)
+
+	return err
+}
+
+// ListAdditionalFieldKeys traces the wrapped call
+func (t tracedDatabase) ListAdditionalFieldKeys(ctx context.Context, filter *models.TransactionFilter) ([]string, error) {
+	ctx, span := t.start(ctx, "list_additional_field_keys", attribute.String("merchant.id", filter.MerchantID))
+
+	keys, err := t.Database.ListAdditionalFieldKeys(ctx, filter)
+
+	End(span, err)
+
+	return keys, err
+}
+
+// ForceTransactionStatus traces the wrapped call with the resulting transaction
+func (t tracedDatabase) ForceTransactionStatus(ctx context.Context, transactionID string, status models.TransactionStatus, entry *models.AuditEntry) (*models.Transaction, error) {
+	ctx, span := t.start(ctx, "force_transaction_status", attribute.String("payment.transaction_id", transactionID))
+
+	transaction, err := t.Database.ForceTransactionStatus(ctx, transactionID, status, entry)
+	if transaction != nil {
+		span.SetAttributes(TransactionAttributes(transaction)...)
+	}
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// ReencryptTransactions traces the wrapped call with the number of transactions updated
+func (t tracedDatabase) ReencryptTransactions(ctx context.Context, limit int) (int, error) {
+	ctx, span := t.start(ctx, "reencrypt_transactions", attribute.Int("db.limit", limit))
+
+	reencrypted, err := t.Database.ReencryptTransactions(ctx, limit)
+	span.SetAttributes(attribute.Int("db.rows", reencrypted))
+
+	End(span, err)
+
+	return reencrypted, err
+}
+
+// InsertMerchant traces the wrapped call
+func (t tracedDatabase) InsertMerchant(ctx context.Context, merchant *models.Merchant, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "insert_merchant", attribute.String("merchant.id", merchant.MerchantID))
+
+	err := t.Database.InsertMerchant(ctx, merchant, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// InsertAPIKey traces the wrapped call
+func (t tracedDatabase) InsertAPIKey(ctx context.Context, key *models.APIKey) error {
+	ctx, span := t.start(ctx, "insert_api_key", attribute.String("merchant.id", key.MerchantID))
+
+	err := t.Database.InsertAPIKey(ctx, key)
+
+	End(span, err)
+
+	return err
+}
+
+// GetAPIKey traces the wrapped call
+func (t tracedDatabase) GetAPIKey(ctx context.Context, keyID string) (*models.APIKey, error) {
+	ctx, span := t.start(ctx, "get_api_key")
+
+	key, err := t.Database.GetAPIKey(ctx, keyID)
+
+	End(span, err)
+
+	return key, err
+}
+
+// GetAPIKeyByHash traces the wrapped call
+func (t tracedDatabase) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	ctx, span := t.start(ctx, "get_api_key_by_hash")
+
+	key, err := t.Database.GetAPIKeyByHash(ctx, hash)
+
+	End(span, err)
+
+	return key, err
+}
+
+// RotateAPIKey traces the wrapped call
+func (t tracedDatabase) RotateAPIKey(ctx context.Context, keyID string, expiresAt time.Time, replacement *models.APIKey, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "rotate_api_key")
+
+	err := t.Database.RotateAPIKey(ctx, keyID, expiresAt, replacement, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// InsertWebhookEvent traces the wrapped call
+func (t tracedDatabase) InsertWebhookEvent(ctx context.Context, event *models.WebhookEvent) error {
+	ctx, span := t.start(ctx, "insert_webhook_event", attribute.String("event.id", event.EventID), attribute.String("event.type", event.Type))
+
+	err := t.Database.InsertWebhookEvent(ctx, event)
+
+	End(span, err)
+
+	return err
+}
+
+// GetWebhookEvent traces the wrapped call
+func (t tracedDatabase) GetWebhookEvent(ctx context.Context, eventID string) (*models.WebhookEvent, error) {
+	ctx, span := t.start(ctx, "get_webhook_event", attribute.String("event.id", eventID))
+
+	event, err := t.Database.GetWebhookEvent(ctx, eventID)
+
+	End(span, err)
+
+	return event, err
+}
+
+// RecordWebhookEventOutcome traces the wrapped call
+func (t tracedDatabase) RecordWebhookEventOutcome(ctx context.Context, eventID string, processingErr error) error {
+	ctx, span := t.start(ctx, "record_webhook_event_outcome", attribute.String("event.id", eventID))
+
+	err := t.Database.RecordWebhookEventOutcome(ctx, eventID, processingErr)
+
+	End(span, err)
+
+	return err
+}
+
+// InsertConnectedAccount traces the wrapped call
+func (t tracedDatabase) InsertConnectedAccount(ctx context.Context, account *models.ConnectedAccount, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "insert_connected_account", attribute.String("merchant.id", account.MerchantID))
+
+	err := t.Database.InsertConnectedAccount(ctx, account, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// GetConnectedAccount traces the wrapped call
+func (t tracedDatabase) GetConnectedAccount(ctx context.Context, accountID string) (*models.ConnectedAccount, error) {
+	ctx, span := t.start(ctx, "get_connected_account", attribute.String("connected_account.id", accountID))
+
+	account, err := t.Database.GetConnectedAccount(ctx, accountID)
+
+	End(span, err)
+
+	return account, err
+}
+
+// ListConnectedAccounts traces the wrapped call
+func (t tracedDatabase) ListConnectedAccounts(ctx context.Context, merchantID string) ([]*models.ConnectedAccount, error) {
+	ctx, span := t.start(ctx, "list_connected_accounts", attribute.String("merchant.id", merchantID))
+
+	accounts, err := t.Database.ListConnectedAccounts(ctx, merchantID)
+
+	End(span, err)
+
+	return accounts, err
+}
+
+// ListTransfers traces the wrapped call
+func (t tracedDatabase) ListTransfers(ctx context.Context, transactionID string) ([]*models.Transfer, error) {
+	ctx, span := t.start(ctx, "list_transfers", attribute.String("payment.transaction_id", transactionID))
+
+	transfers, err := t.Database.ListTransfers(ctx, transactionID)
+
+	End(span, err)
+
+	return transfers, err
+}
+
+// UpsertPayout traces the wrapped call
+func (t tracedDatabase) UpsertPayout(ctx context.Context, payout *models.Payout, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "upsert_payout", attribute.String("payout.provider_id", payout.ProviderPayoutID))
+
+	err := t.Database.UpsertPayout(ctx, payout, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// GetPayout traces the wrapped call
+func (t tracedDatabase) GetPayout(ctx context.Context, payoutID string) (*models.Payout, error) {
+	ctx, span := t.start(ctx, "get_payout", attribute.String("payout.id", payoutID))
+
+	payout, err := t.Database.GetPayout(ctx, payoutID)
+
+	End(span, err)
+
+	return payout, err
+}
+
+// ListPayouts traces the wrapped call
+func (t tracedDatabase) ListPayouts(ctx context.Context, filter *models.PayoutFilter) ([]*models.Payout, error) {
+	ctx, span := t.start(ctx, "list_payouts")
+
+	payouts, err := t.Database.ListPayouts(ctx, filter)
+
+	End(span, err)
+
+	return payouts, err
+}
+
+// LinkPayoutTransactions traces the wrapped call
+func (t tracedDatabase) LinkPayoutTransactions(ctx context.Context, payoutID string, items []*models.PayoutItem) (int, error) {
+	ctx, span := t.start(ctx, "link_payout_transactions", attribute.String("payout.id", payoutID), attribute.Int("payout.items", len(items)))
+
+	linked, err := t.Database.LinkPayoutTransactions(ctx, payoutID, items)
+
+	End(span, err)
+
+	return linked, err
+}
+
+// ListPayoutTransactions traces the wrapped call
+func (t tracedDatabase) ListPayoutTransactions(ctx context.Context, payoutID string) ([]*models.Transaction, error) {
+	ctx, span := t.start(ctx, "list_payout_transactions", attribute.String("payout.id", payoutID))
+
+	transactions, err := t.Database.ListPayoutTransactions(ctx, payoutID)
+
+	End(span, err)
+
+	return transactions, err
+}
+
+// CountRiskDecisions traces the wrapped call
+func (t tracedDatabase) CountRiskDecisions(ctx context.Context, merchantID string, field models.RiskField, value string, since time.Time) (int, error) {
+	ctx, span := t.start(ctx, "count_risk_decisions", attribute.String("risk.field", string(field)))
+
+	count, err := t.Database.CountRiskDecisions(ctx, merchantID, field, value, since)
+
+	End(span, err)
+
+	return count, err
+}
+
+// GetReview traces the wrapped call
+func (t tracedDatabase) GetReview(ctx context.Context, reviewID string) (*models.Review, error) {
+	ctx, span := t.start(ctx, "get_review", attribute.String("review.id", reviewID))
+
+	review, err := t.Database.GetReview(ctx, reviewID)
+
+	End(span, err)
+
+	return review, err
+}
+
+// ListReviews traces the wrapped call
+func (t tracedDatabase) ListReviews(ctx context.Context, filter *models.ReviewFilter) ([]*models.Review, error) {
+	ctx, span := t.start(ctx, "list_reviews")
+
+	reviews, err := t.Database.ListReviews(ctx, filter)
+
+	End(span, err)
+
+	return reviews, err
+}
+
+// ClaimReview traces the wrapped call
+func (t tracedDatabase) ClaimReview(ctx context.Context, reviewID, reviewer string, until time.Time) error {
+	ctx, span := t.start(ctx, "claim_review", attribute.String("review.id", reviewID))
+
+	err := t.Database.ClaimReview(ctx, reviewID, reviewer, until)
+
+	End(span, err)
+
+	return err
+}
+
+// ReleaseReview traces the wrapped call
+func (t tracedDatabase) ReleaseReview(ctx context.Context, reviewID, reviewer string) error {
+	ctx, span := t.start(ctx, "release_review", attribute.String("review.id", reviewID))
+
+	err := t.Database.ReleaseReview(ctx, reviewID, reviewer)
+
+	End(span, err)
+
+	return err
+}
+
+// DecideReview traces the wrapped call
+func (t tracedDatabase) DecideReview(ctx context.Context, review *models.Review, decided *models.Transaction, entry *models.AuditEntry) (*models.Transaction, error) {
+	ctx, span := t.start(ctx, "decide_review",
+		attribute.String("review.id", review.ReviewID),
+		attribute.String("review.status", string(review.Status)),
+		attribute.String("payment.transaction_id", review.TransactionID),
+	)
+
+	transaction, err := t.Database.DecideReview(ctx, review, decided, entry)
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// InsertRefundRequest traces the wrapped call
+func (t tracedDatabase) InsertRefundRequest(ctx context.Context, request *models.RefundRequest, expectedVersion int, entry *models.AuditEntry) (*models.Transaction, error) {
+	ctx, span := t.start(ctx, "insert_refund_request",
+		attribute.String("refund_request.id", request.RefundRequestID),
+		attribute.String("payment.transaction_id", request.TransactionID),
+	)
+
+	transaction, err := t.Database.InsertRefundRequest(ctx, request, expectedVersion, entry)
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// GetRefundRequest traces the wrapped call
+func (t tracedDatabase) GetRefundRequest(ctx context.Context, refundRequestID string) (*models.RefundRequest, error) {
+	ctx, span := t.start(ctx, "get_refund_request", attribute.String("refund_request.id", refundRequestID))
+
+	request, err := t.Database.GetRefundRequest(ctx, refundRequestID)
+
+	End(span, err)
+
+	return request, err
+}
+
+// ListRefundRequests traces the wrapped call
+func (t tracedDatabase) ListRefundRequests(ctx context.Context, filter *models.RefundRequestFilter) ([]*models.RefundRequest, error) {
+	ctx, span := t.start(ctx, "list_refund_requests")
+
+	requests, err := t.Database.ListRefundRequests(ctx, filter)
+
+	End(span, err)
+
+	return requests, err
+}
+
+// ClaimRefundRequest traces the wrapped call
+func (t tracedDatabase) ClaimRefundRequest(ctx context.Context, refundRequestID, operator string, until time.Time) error {
+	ctx, span := t.start(ctx, "claim_refund_request", attribute.String("refund_request.id", refundRequestID))
+
+	err := t.Database.ClaimRefundRequest(ctx, refundRequestID, operator, until)
+
+	End(span, err)
+
+	return err
+}
+
+// ReleaseRefundRequest traces the wrapped call
+func (t tracedDatabase) ReleaseRefundRequest(ctx context.Context, refundRequestID, operator string) error {
+	ctx, span := t.start(ctx, "release_refund_request", attribute.String("refund_request.id", refundRequestID))
+
+	err := t.Database.ReleaseRefundRequest(ctx, refundRequestID, operator)
+
+	End(span, err)
+
+	return err
+}
+
+// CloseRefundRequest traces the wrapped call
+func (t tracedDatabase) CloseRefundRequest(ctx context.Context, transactionID, closedBy string, entry *models.AuditEntry) (*models.RefundRequest, error) {
+	ctx, span := t.start(ctx, "close_refund_request", attribute.String("payment.transaction_id", transactionID))
+
+	request, err := t.Database.CloseRefundRequest(ctx, transactionID, closedBy, entry)
+
+	End(span, err)
+
+	return request, err
+}
+
+// DecideRefundRequest traces the wrapped call
+func (t tracedDatabase) DecideRefundRequest(ctx context.Context, request *models.RefundRequest, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "decide_refund_request",
+		attribute.String("refund_request.id", request.RefundRequestID),
+		attribute.String("refund_request.status", string(request.Status)),
+		attribute.String("payment.transaction_id", request.TransactionID),
+	)
+
+	err := t.Database.DecideRefundRequest(ctx, request, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// InsertBlockListEntry traces the wrapped call
+func (t tracedDatabase) InsertBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "insert_block_list_entry",
+		attribute.String("block_list.entry_id", listEntry.EntryID),
+		attribute.String("block_list.type", string(listEntry.Type)),
+	)
+
+	err := t.Database.InsertBlockListEntry(ctx, listEntry, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// GetBlockListEntry traces the wrapped call
+func (t tracedDatabase) GetBlockListEntry(ctx context.Context, entryID string) (*models.BlockListEntry, error) {
+	ctx, span := t.start(ctx, "get_block_list_entry", attribute.String("block_list.entry_id", entryID))
+
+	entry, err := t.Database.GetBlockListEntry(ctx, entryID)
+
+	End(span, err)
+
+	return entry, err
+}
+
+// ListBlockListEntries traces the wrapped call
+func (t tracedDatabase) ListBlockListEntries(ctx context.Context, filter *models.BlockListFilter) ([]*models.BlockListEntry, error) {
+	ctx, span := t.start(ctx, "list_block_list_entries")
+
+	entries, err := t.Database.ListBlockListEntries(ctx, filter)
+
+	End(span, err)
+
+	return entries, err
+}
+
+// UpdateBlockListEntry traces the wrapped call
+func (t tracedDatabase) UpdateBlockListEntry(ctx context.Context, listEntry *models.BlockListEntry, entry *models.AuditEntry) (*models.BlockListEntry, error) {
+	ctx, span := t.start(ctx, "update_block_list_entry", attribute.String("block_list.entry_id", listEntry.EntryID))
+
+	updated, err := t.Database.UpdateBlockListEntry(ctx, listEntry, entry)
+
+	End(span, err)
+
+	return updated, err
+}
+
+// DeleteBlockListEntry traces the wrapped call
+func (t tracedDatabase) DeleteBlockListEntry(ctx context.Context, entryID string, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "delete_block_list_entry", attribute.String("block_list.entry_id", entryID))
+
+	err := t.Database.DeleteBlockListEntry(ctx, entryID, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// MatchBlockList traces the wrapped call
+func (t tracedDatabase) MatchBlockList(ctx context.Context, query *models.BlockListQuery) ([]*models.BlockListEntry, error) {
+	ctx, span := t.start(ctx, "match_block_list")
+
+	entries, err := t.Database.MatchBlockList(ctx, query)
+
+	End(span, err)
+
+	return entries, err
+}
+
+// ReserveUsage traces the wrapped call
+func (t tracedDatabase) ReserveUsage(ctx context.Context, reservation *models.UsageReservation, check func(*models.Usage) error) (*models.Usage, error) {
+	ctx, span := t.start(ctx, "reserve_usage",
+		attribute.String("merchant.id", reservation.MerchantID),
+		attribute.String("payment.currency", reservation.Currency),
+	)
+
+	usage, err := t.Database.ReserveUsage(ctx, reservation, check)
+
+	End(span, err)
+
+	return usage, err
+}
+
+// ReleaseUsage traces the wrapped call
+func (t tracedDatabase) ReleaseUsage(ctx context.Context, reservation *models.UsageReservation) error {
+	ctx, span := t.start(ctx, "release_usage",
+		attribute.String("merchant.id", reservation.MerchantID),
+		attribute.String("payment.currency", reservation.Currency),
+	)
+
+	err := t.Database.ReleaseUsage(ctx, reservation)
+
+	End(span, err)
+
+	return err
+}
+
+// ListUsage traces the wrapped call
+func (t tracedDatabase) ListUsage(ctx context.Context, merchantID string, at time.Time) ([]*models.Usage, error) {
+	ctx, span := t.start(ctx, "list_usage", attribute.String("merchant.id", merchantID))
+
+	usages, err := t.Database.ListUsage(ctx, merchantID, at)
+
+	End(span, err)
+
+	return usages, err
+}
+
+// InsertAuditEntry traces the wrapped call
+func (t tracedDatabase) InsertAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "insert_audit_entry", attribute.String("audit.action", string(entry.Action)))
+
+	err := t.Database.InsertAuditEntry(ctx, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// ListAuditEntries traces the wrapped call
+func (t tracedDatabase) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	ctx, span := t.start(ctx, "list_audit_entries", attribute.String("audit.resource_type", filter.ResourceType))
+
+	entries, err := t.Database.ListAuditEntries(ctx, filter)
+
+	End(span, err)
+
+	return entries, err
+}
+
+// ExportCustomerData traces the wrapped call, leaving the customer out of the span
+func (t tracedDatabase) ExportCustomerData(ctx context.Context, customer string) (*models.CustomerData, error) {
+	ctx, span := t.start(ctx, "export_customer_data")
+
+	data, err := t.Database.ExportCustomerData(ctx, customer)
+
+	End(span, err)
+
+	return data, err
+}
+
+// EraseCustomerData traces the wrapped call, leaving the customer out of the span
+func (t tracedDatabase) EraseCustomerData(ctx context.Context, erasure *models.CustomerErasure, entry *models.AuditEntry) error {
+	ctx, span := t.start(ctx, "erase_customer_data", attribute.String("customer.erasure_id", erasure.ErasureID))
+
+	err := t.Database.EraseCustomerData(ctx, erasure, entry)
+
+	End(span, err)
+
+	return err
+}
+
+// ArchiveTransactions traces the wrapped call with the number of transactions archived
+func (t tracedDatabase) ArchiveTransactions(ctx context.Context, before, refundableSince time.Time, limit int) (int, error) {
+	ctx, span := t.start(ctx, "archive_transactions", attribute.Int("db.limit", limit))
+
+	archived, err := t.Database.ArchiveTransactions(ctx, before, refundableSince, limit)
+	span.SetAttributes(attribute.Int("db.rows", archived))
+
+	End(span, err)
+
+	return archived, err
+}
+
+// ListArchivableTransactions traces the wrapped call with the number of transactions fetched
+func (t tracedDatabase) ListArchivableTransactions(ctx context.Context, before, refundableSince time.Time, limit int) ([]*models.Transaction, error) {
+	ctx, span := t.start(ctx, "list_archivable_transactions", attribute.Int("db.limit", limit))
+
+	transactions, err := t.Database.ListArchivableTransactions(ctx, before, refundableSince, limit)
+	span.SetAttributes(attribute.Int("db.rows", len(transactions)))
+
+	End(span, err)
+
+	return transactions, err
+}
+
+// RemoveArchivedTransactions traces the wrapped call with the number of transactions removed
+func (t tracedDatabase) RemoveArchivedTransactions(ctx context.Context, file string, transactions []*models.Transaction) ([]string, error) {
+	ctx, span := t.start(ctx, "remove_archived_transactions", attribute.String("archive.file", file))
+
+	removed, err := t.Database.RemoveArchivedTransactions(ctx, file, transactions)
+	span.SetAttributes(attribute.Int("db.rows", len(removed)))
+
+	End(span, err)
+
+	return removed, err
+}
+
+// GetArchiveFile traces the wrapped call
+func (t tracedDatabase) GetArchiveFile(ctx context.Context, transactionID string) (string, error) {
+	ctx, span := t.start(ctx, "get_archive_file", attribute.String("payment.transaction_id", transactionID))
+
+	file, err := t.Database.GetArchiveFile(ctx, transactionID)
+
+	End(span, err)
+
+	return file, err
+}
+
+// LockArchiveFile traces the wrapped call with the archive file, including fn
+func (t tracedDatabase) LockArchiveFile(ctx context.Context, file string, fn func() error) error {
+	ctx, span := t.start(ctx, "lock_archive_file", attribute.String("archive.file", file))
+
+	err := t.Database.LockArchiveFile(ctx, file, fn)
+
+	End(span, err)
+
+	return err
+}
+
+// PruneWebhookEventPayloads traces the wrapped call with the number of payloads dropped
+func (t tracedDatabase) PruneWebhookEventPayloads(ctx context.Context, before time.Time) (int, error) {
+	ctx, span := t.start(ctx, "prune_webhook_event_payloads")
+
+	pruned, err := t.Database.PruneWebhookEventPayloads(ctx, before)
+	span.SetAttributes(attribute.Int("db.rows", pruned))
+
+	End(span, err)
+
+	return pruned, err
+}
+
+func (t tracedDatabase) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, semconv.DBSystemPostgreSQL, semconv.DBOperation(operation))
+
+	return t.tracer.Start(ctx, "database."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
@@ -0,0 +1,174 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/paymentprocessor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedPaymentProcessor struct {
+	next     paymentprocessor.PaymentProcessor
+	provider string
+	tracer   trace.Tracer
+}
+
+// NewPaymentProcessor decorates a payment processor to trace each call to the provider
+func NewPaymentProcessor(next paymentprocessor.PaymentProcessor, provider models.PaymentProvider) paymentprocessor.PaymentProcessor {
+	return tracedPaymentProcessor{
+		next:     next,
+		provider: string(provider),
+		tracer:   tracer(),
+	}
+}
+
+// PerformTransaction traces the wrapped call with the requested amount and resulting status
+func (t tracedPaymentProcessor) PerformTransaction(ctx context.Context, input *models.TransactionInput) (*models.Transaction, error) {
+	ctx, span := t.tracer.Start(ctx, "payment_processor.perform_transaction",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("payment.provider", t.provider),
+			attribute.String("merchant.id", input.MerchantID),
+			attribute.Int64("payment.amount", input.Amount),
+			attribute.String("payment.currency", input.Currency),
+		),
+	)
+
+	transaction, err := t.next.PerformTransaction(ctx, input)
+	if transaction != nil {
+		span.SetAttributes(TransactionAttributes(transaction)...)
+	}
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// RefundTransaction traces the wrapped call with the resulting status
+func (t tracedPaymentProcessor) RefundTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	ctx, span := t.tracer.Start(ctx, "payment_processor.refund_transaction",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("payment.provider", t.provider)),
+	)
+
+	transaction, err := t.next.RefundTransaction(ctx, metadata)
+	if transaction != nil {
+		span.SetAttributes(TransactionAttributes(transaction)...)
+	}
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// QueryTransaction traces the wrapped call with the status reported by the provider
+func (t tracedPaymentProcessor) QueryTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	ctx, span := t.tracer.Start(ctx, "payment_processor.query_transaction",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("payment.provider", t.provider)),
+	)
+
+	transaction, err := t.next.QueryTransaction(ctx, metadata)
+	if transaction != nil {
+		span.SetAttributes(TransactionAttributes(transaction)...)
+	}
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// CapturePayment traces the wrapped call with the status reported by the provider
+func (t tracedPaymentProcessor) CapturePayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	ctx, span := t.tracer.Start(ctx, "payment_processor.capture_payment",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("payment.provider", t.provider)),
+	)
+
+	transaction, err := t.next.CapturePayment(ctx, metadata)
+	if transaction != nil {
+		span.SetAttributes(TransactionAttributes(transaction)...)
+	}
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// CancelPayment traces the wrapped call with the status reported by the provider
+func (t tracedPaymentProcessor) CancelPayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	ctx, span := t.tracer.Start(ctx, "payment_processor.cancel_payment",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("payment.provider", t.provider)),
+	)
+
+	transaction, err := t.next.CancelPayment(ctx, metadata)
+	if transaction != nil {
+		span.SetAttributes(TransactionAttributes(transaction)...)
+	}
+
+	End(span, err)
+
+	return transaction, err
+}
+
+// GetPaymentMethod traces the wrapped call, leaving the card details out of the span
+func (t tracedPaymentProcessor) GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethodDetails, error) {
+	ctx, span := t.tracer.Start(ctx, "payment_processor.get_payment_method",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("payment.provider", t.provider)),
+	)
+
+	details, err := t.next.GetPaymentMethod(ctx, paymentMethodID)
+
+	End(span, err)
+
+	return details, err
+}
+
+// ListPayoutItems traces the wrapped call with the number of items of the payout
+func (t tracedPaymentProcessor) ListPayoutItems(ctx context.Context, providerPayoutID string) ([]*models.PayoutItem, error) {
+	ctx, span := t.tracer.Start(ctx, "payment_processor.list_payout_items",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("payment.provider", t.provider),
+			attribute.String("payout.provider_id", providerPayoutID),
+		),
+	)
+
+	items, err := t.next.ListPayoutItems(ctx, providerPayoutID)
+	if err == nil {
+		span.SetAttributes(attribute.Int("payout.items", len(items)))
+	}
+
+	End(span, err)
+
+	return items, err
+}
+
+// TransactionAttributes describes a transaction as span attributes, skipping unknown values
+func TransactionAttributes(transaction *models.Transaction) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{}
+
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, attribute.String(key, value))
+		}
+	}
+
+	add("payment.transaction_id", transaction.TransactionID)
+	add("merchant.id", transaction.MerchantID)
+	add("payment.provider", string(transaction.Provider))
+	add("payment.currency", transaction.Currency)
+	add("payment.type", string(transaction.Type))
+	add("payment.status", string(transaction.Status))
+	add("payment.failure_reason", transaction.FailureReason)
+
+	if transaction.Amount != 0 {
+		attrs = append(attrs, attribute.Int("payment.amount", transaction.Amount))
+	}
+
+	return attrs
+}
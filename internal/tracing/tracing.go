@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/aledeltoro/simple-online-payment-platform/internal/tracing"
+
+const (
+	// ExporterOTLP exports spans to an OTLP/HTTP collector
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans to stderr, meant for local development
+	ExporterStdout = "stdout"
+	// ExporterNone disables span export
+	ExporterNone = "none"
+)
+
+// ErrUnsupportedExporter error when the configured span exporter is unknown
+var ErrUnsupportedExporter = errors.New("unsupported traces exporter")
+
+// ShutdownFunc flushes pending spans and releases the exporter
+type ShutdownFunc func(context.Context) error
+
+// Init configures the global tracer provider and the W3C trace context propagator. The OTLP exporter
+// is configured through the standard OTEL_EXPORTER_OTLP_* variables
+func Init(ctx context.Context, serviceName string, cfg config.Tracing) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, err := newExporter(ctx, cfg.Exporter)
+	if err != nil {
+		return nil, err
+	}
+
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case ExporterOTLP:
+		return otlptracehttp.New(ctx)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedExporter, name)
+	}
+}
+
+// Middleware starts a server span for each HTTP request, named after the matched chi route
+func Middleware(serviceName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		nameSpan := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			routeContext := chi.RouteContext(r.Context())
+			if routeContext == nil || routeContext.RoutePattern() == "" {
+				return
+			}
+
+			route := routeContext.RoutePattern()
+
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(fmt.Sprintf("%s %s", r.Method, route))
+			span.SetAttributes(semconv.HTTPRoute(route))
+		})
+
+		return otelhttp.NewHandler(nameSpan, serviceName)
+	}
+}
+
+// Inject writes the trace context of the span in ctx into the given metadata
+func Inject(ctx context.Context, metadata map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(metadata))
+}
+
+// Extract returns the span context stored in the given metadata, which is invalid when there is none
+func Extract(metadata map[string]string) trace.SpanContext {
+	return trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(metadata)))
+}
+
+// StartLinked starts a span linked to the trace stored in the given metadata, so work triggered
+// asynchronously by a provider can be correlated with the request that originated it
+func StartLinked(ctx context.Context, name string, metadata map[string]string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	options := []trace.SpanStartOption{trace.WithAttributes(attrs...)}
+
+	if origin := Extract(metadata); origin.IsValid() {
+		options = append(options, trace.WithLinks(trace.Link{SpanContext: origin}))
+	}
+
+	return tracer().Start(ctx, name, options...)
+}
+
+// End records the error, if any, on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
@@ -0,0 +1,224 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aledeltoro/simple-online-payment-platform/internal/config"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/database/postgres"
+	"github.com/aledeltoro/simple-online-payment-platform/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type mockPaymentProcessor struct {
+	mock.Mock
+}
+
+func (m *mockPaymentProcessor) PerformTransaction(ctx context.Context, input *models.TransactionInput) (*models.Transaction, error) {
+	args := m.Called(ctx, input)
+
+	transaction, _ := args.Get(0).(*models.Transaction)
+
+	return transaction, args.Error(1)
+}
+
+func (m *mockPaymentProcessor) RefundTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	args := m.Called(ctx, metadata)
+
+	transaction, _ := args.Get(0).(*models.Transaction)
+
+	return transaction, args.Error(1)
+}
+
+func (m *mockPaymentProcessor) QueryTransaction(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	args := m.Called(ctx, metadata)
+
+	transaction, _ := args.Get(0).(*models.Transaction)
+
+	return transaction, args.Error(1)
+}
+
+func (m *mockPaymentProcessor) CapturePayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	args := m.Called(ctx, metadata)
+
+	transaction, _ := args.Get(0).(*models.Transaction)
+
+	return transaction, args.Error(1)
+}
+
+func (m *mockPaymentProcessor) CancelPayment(ctx context.Context, metadata map[string]interface{}) (*models.Transaction, error) {
+	args := m.Called(ctx, metadata)
+
+	transaction, _ := args.Get(0).(*models.Transaction)
+
+	return transaction, args.Error(1)
+}
+
+func (m *mockPaymentProcessor) GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethodDetails, error) {
+	args := m.Called(ctx, paymentMethodID)
+
+	details, _ := args.Get(0).(*models.PaymentMethodDetails)
+
+	return details, args.Error(1)
+}
+
+func (m *mockPaymentProcessor) ListPayoutItems(ctx context.Context, providerPayoutID string) ([]*models.PayoutItem, error) {
+	args := m.Called(ctx, providerPayoutID)
+
+	items, _ := args.Get(0).([]*models.PayoutItem)
+
+	return items, args.Error(1)
+}
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	})
+
+	return recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	values := map[attribute.Key]attribute.Value{}
+
+	for _, attr := range span.Attributes() {
+		values[attr.Key] = attr.Value
+	}
+
+	return values
+}
+
+func TestTracedPaymentProcessorPerformTransaction(t *testing.T) {
+	c := require.New(t)
+
+	recorder := setupRecorder(t)
+
+	input := &models.TransactionInput{
+		MerchantID:    "MCH_123",
+		Amount:        2000,
+		Currency:      "usd",
+		PaymentMethod: "pm_card_visa",
+	}
+
+	expectedTransaction := &models.Transaction{
+		TransactionID: "TXN_123",
+		Status:        models.TransactionStatusPending,
+		Provider:      models.PaymentProviderStripe,
+		Amount:        2000,
+		Currency:      "usd",
+		Type:          models.TransactionTypeCharge,
+	}
+
+	paymentProcessorMock := &mockPaymentProcessor{}
+	paymentProcessorMock.On("PerformTransaction", mock.Anything, input).Return(expectedTransaction, nil)
+
+	paymentProcessor := NewPaymentProcessor(paymentProcessorMock, models.PaymentProviderStripe)
+
+	transaction, err := paymentProcessor.PerformTransaction(context.Background(), input)
+	c.NoError(err)
+	c.Equal(expectedTransaction, transaction)
+
+	spans := recorder.Ended()
+	c.Len(spans, 1)
+	c.Equal("payment_processor.perform_transaction", spans[0].Name())
+
+	values := attributes(spans[0])
+	c.Equal(int64(2000), values["payment.amount"].AsInt64())
+	c.Equal("usd", values["payment.currency"].AsString())
+	c.Equal("stripe", values["payment.provider"].AsString())
+	c.Equal("pending", values["payment.status"].AsString())
+	c.Equal("MCH_123", values["merchant.id"].AsString())
+}
+
+func TestTracedDatabaseGetTransactionFailure(t *testing.T) {
+	c := require.New(t)
+
+	recorder := setupRecorder(t)
+
+	customErr := errors.New("connection refused")
+
+	mockDatabase := postgres.MockPostgres{}
+	mockDatabase.On("GetTransaction", mock.Anything, "TXN_123").Return((*models.Transaction)(nil), customErr)
+
+	database := NewDatabase(&mockDatabase)
+
+	_, err := database.GetTransaction(context.Background(), "TXN_123")
+	c.ErrorIs(err, customErr)
+
+	spans := recorder.Ended()
+	c.Len(spans, 1)
+	c.Equal("database.get_transaction", spans[0].Name())
+	c.Equal(codes.Error, spans[0].Status().Code)
+	c.Equal("postgresql", attributes(spans[0])["db.system"].AsString())
+}
+
+func TestStartLinked(t *testing.T) {
+	c := require.New(t)
+
+	recorder := setupRecorder(t)
+
+	ctx, origin := otel.Tracer("test").Start(context.Background(), "origin")
+
+	metadata := map[string]string{"transaction_id": "TXN_123"}
+	Inject(ctx, metadata)
+	origin.End()
+
+	c.Contains(metadata, "traceparent")
+	c.Equal(origin.SpanContext().TraceID(), Extract(metadata).TraceID())
+
+	_, span := StartLinked(context.Background(), "events.process_stripe_event", metadata)
+	span.End()
+
+	spans := recorder.Ended()
+	c.Len(spans, 2)
+	c.Len(spans[1].Links(), 1)
+	c.Equal(origin.SpanContext().TraceID(), spans[1].Links()[0].SpanContext.TraceID())
+
+	_, span = StartLinked(context.Background(), "events.process_stripe_event", map[string]string{})
+	span.End()
+
+	c.Empty(recorder.Ended()[2].Links())
+}
+
+func TestMiddlewareNamesSpanAfterRoute(t *testing.T) {
+	c := require.New(t)
+
+	recorder := setupRecorder(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware("api"))
+	r.Get("/payments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/payments/TXN_123", nil))
+
+	spans := recorder.Ended()
+	c.Len(spans, 1)
+	c.Equal("GET /payments/{id}", spans[0].Name())
+	c.Equal("/payments/{id}", attributes(spans[0])["http.route"].AsString())
+}
+
+func TestInitUnsupportedExporter(t *testing.T) {
+	c := require.New(t)
+
+	_, err := Init(context.Background(), "api", config.Tracing{Exporter: "zipkin"})
+	c.ErrorIs(err, ErrUnsupportedExporter)
+}